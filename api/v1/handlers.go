@@ -0,0 +1,290 @@
+// Package v1 holds the HTTP handlers behind the /v1/oeis/* routes shared by
+// the oeis and sequences servers: the raw OEIS summary files, b-files, and
+// per-field List downloads. The two servers differ only in how they fetch
+// and serve a file, so those parts are injected via Deps rather than
+// hard-coded here.
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loda-lang/loda-api/storage"
+	"github.com/loda-lang/loda-api/util"
+)
+
+// Deps wires the parts of handler behavior that differ between the servers
+// mounting these routes.
+type Deps struct {
+	HttpClient            *http.Client
+	OeisDir               string
+	Website               string
+	SummaryUpdateInterval time.Duration
+	BfileUpdateInterval   time.Duration
+	// Serve writes the file at path to the response.
+	Serve func(w http.ResponseWriter, req *http.Request, path string)
+	// AfterSummaryFetch runs once right after a summary file is freshly
+	// fetched, before it is served. It may be nil.
+	AfterSummaryFetch func(path string) error
+	// Fetch downloads url into localFile, aborting if ctx (the serving
+	// request's context) is cancelled or expires first. Defaults to
+	// util.FetchFileCtx when nil. OeisServer injects fetchWithFallback
+	// instead, which adds bounded retries, a per-URL cooldown, and reports
+	// failures as plain errors instead of killing the process.
+	Fetch func(ctx context.Context, httpClient *http.Client, url string, localFile string) error
+	// Metrics, if set, receives upstream-fetch outcomes and cached-file
+	// ages so a server can expose them as its own Prometheus collectors
+	// without this package depending on Prometheus directly.
+	Metrics FetchMetrics
+}
+
+// FetchMetrics receives upstream-fetch outcomes and cached-file ages from
+// NewSummaryHandler and NewBFileHandler. Leave Deps.Metrics nil to opt out.
+type FetchMetrics interface {
+	// ObserveFetch records the outcome of a single fetch attempt (or
+	// non-attempt, for FetchOutcomeHit) and how long it took.
+	ObserveFetch(outcome string, duration time.Duration)
+	// ObserveCacheAge records how old the file being served was at serve
+	// time.
+	ObserveCacheAge(age time.Duration)
+}
+
+// Fetch outcomes reported to FetchMetrics.ObserveFetch.
+const (
+	FetchOutcomeHit       = "hit"       // the cached file was already recent enough; no fetch attempted
+	FetchOutcomeRefreshed = "refreshed" // a fetch was attempted and succeeded
+	FetchOutcomeFailed    = "failed"    // a fetch was attempted and failed
+)
+
+func (d Deps) observeFetch(outcome string, duration time.Duration) {
+	if d.Metrics != nil {
+		d.Metrics.ObserveFetch(outcome, duration)
+	}
+}
+
+func (d Deps) observeCacheAge(path string) {
+	if d.Metrics == nil {
+		return
+	}
+	if info, err := os.Stat(path); err == nil {
+		d.Metrics.ObserveCacheAge(time.Since(info.ModTime()))
+	}
+}
+
+func (d Deps) fetch(ctx context.Context, url, localFile string) error {
+	if d.Fetch != nil {
+		return d.Fetch(ctx, d.HttpClient, url, localFile)
+	}
+	return util.FetchFileCtx(ctx, d.HttpClient, url, localFile)
+}
+
+// serveStale reports whether path still has a previously cached copy
+// despite fetchErr, in which case it marks the response stale per RFC 7234
+// §5.5.1 so the caller serves that copy rather than failing the request.
+// Without a cached copy it responds 503 Service Unavailable; either way the
+// server keeps running instead of being killed by a single bad upstream
+// response.
+func serveStale(w http.ResponseWriter, path string, fetchErr error) bool {
+	if !util.FileExists(path) {
+		log.Printf("Fetch failed and no cached copy of %s exists: %v", path, fetchErr)
+		util.WriteHttpStatus(w, http.StatusServiceUnavailable, "Service Unavailable")
+		return false
+	}
+	log.Printf("Fetch failed, serving stale cached copy of %s: %v", path, fetchErr)
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	return true
+}
+
+func NewSummaryHandler(d Deps, filename string) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		path := filepath.Join(d.OeisDir, filename)
+		if !util.IsFileRecent(path, d.SummaryUpdateInterval) {
+			start := time.Now()
+			err := d.fetch(req.Context(), d.Website+filename, path)
+			if err != nil {
+				d.observeFetch(FetchOutcomeFailed, time.Since(start))
+				if !serveStale(w, path, err) {
+					return
+				}
+			} else {
+				d.observeFetch(FetchOutcomeRefreshed, time.Since(start))
+				if d.AfterSummaryFetch != nil {
+					if err := d.AfterSummaryFetch(path); err != nil {
+						if !serveStale(w, path, err) {
+							return
+						}
+					}
+				}
+			}
+		} else {
+			d.observeFetch(FetchOutcomeHit, 0)
+		}
+		if notModified(w, req, path) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		d.observeCacheAge(path)
+		d.Serve(w, req, path)
+	}
+	return http.HandlerFunc(f)
+}
+
+func NewBFileHandler(d Deps) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		params := mux.Vars(req)
+		id := params["id"]
+		if len(id) != 6 {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		dir := filepath.Join(d.OeisDir, "b", id[0:3])
+		os.MkdirAll(dir, os.ModePerm)
+		filename := fmt.Sprintf("b%s.txt.gz", id)
+		path := filepath.Join(dir, filename)
+		if !util.IsFileRecent(path, d.BfileUpdateInterval) {
+			url := fmt.Sprintf("%sA%s/b%s.txt", d.Website, id, id)
+			txtpath := filepath.Join(dir, fmt.Sprintf("b%s.txt", id))
+			start := time.Now()
+			if err := d.fetch(req.Context(), url, txtpath); err != nil {
+				d.observeFetch(FetchOutcomeFailed, time.Since(start))
+				if !serveStale(w, path, err) {
+					return
+				}
+			} else if err := util.CompressFileAtomic(txtpath, path); err != nil {
+				d.observeFetch(FetchOutcomeFailed, time.Since(start))
+				if !serveStale(w, path, err) {
+					return
+				}
+			} else {
+				d.observeFetch(FetchOutcomeRefreshed, time.Since(start))
+			}
+		} else {
+			d.observeFetch(FetchOutcomeHit, 0)
+		}
+		if notModified(w, req, path) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		d.observeCacheAge(path)
+		d.Serve(w, req, path)
+	}
+	return http.HandlerFunc(f)
+}
+
+// notModified sets the ETag and Last-Modified response headers for path
+// and reports whether the request's If-None-Match or If-Modified-Since
+// header already matches, in which case the caller should respond 304
+// instead of serving the body. A missing or unreadable file is treated as
+// not matching, so the caller falls through to its normal error handling.
+func notModified(w http.ResponseWriter, req *http.Request, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	etag, err := fileETag(path)
+	if err != nil {
+		return false
+	}
+	modTime := info.ModTime()
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		return match == etag || match == "*"
+	}
+	if since := req.Header.Get("If-Modified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil {
+			return !modTime.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
+
+// fileETag returns a weak cache key for path's contents: the file's sha256
+// hash, hex-encoded and truncated to keep the header short, wrapped in the
+// quotes the ETag/If-None-Match syntax expects.
+func fileETag(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%q", hex.EncodeToString(h.Sum(nil))[:16]), nil
+}
+
+func NewListHandler(l *storage.List) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		l.ServeGzip(w, req)
+	}
+	return http.HandlerFunc(f)
+}
+
+// DeltaStatus is the JSON body returned by NewListDeltaHandler when the
+// requested session has rotated out of the bounded delta journal.
+type DeltaStatus struct {
+	Status string `json:"status"`
+}
+
+// NewListDeltaHandler serves /v1/oeis/{list}.delta?since=<session>: the
+// gzipped fields flushed into l since the given session, plus the new
+// session id in the X-Loda-Delta-Session header so the client can poll
+// with it next time. A missing or zero since fetches everything the
+// (bounded) journal still retains. If since is older than that, it
+// responds 409 with a DeltaStatus{"full-resync-required"} so the client
+// falls back to downloading the full list.
+func NewListDeltaHandler(l *storage.List) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		since, _ := strconv.ParseInt(req.URL.Query().Get("since"), 10, 64)
+		var buf bytes.Buffer
+		newSession, err := l.ReadDeltasSince(req.Context(), since, &buf)
+		if errors.Is(err, storage.ErrDeltaResyncRequired) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(DeltaStatus{Status: "full-resync-required"})
+			return
+		}
+		if err != nil {
+			util.WriteHttpInternalServerError(w)
+			log.Printf("Error reading %s deltas since %d: %v", l.Name, since, err)
+			return
+		}
+		w.Header().Set("X-Loda-Delta-Session", strconv.FormatInt(newSession, 10))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNewSummaryHandler_ServesFreshFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "names.gz"), []byte("names"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	d := Deps{
+		OeisDir:               dir,
+		SummaryUpdateInterval: time.Hour,
+		Serve: func(w http.ResponseWriter, req *http.Request, path string) {
+			w.Write([]byte("served"))
+		},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/oeis/names.gz", nil)
+	w := httptest.NewRecorder()
+	NewSummaryHandler(d, "names.gz").ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Body.String() != "served" {
+		t.Errorf("body = %q, want %q", w.Body.String(), "served")
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("expected an ETag header to be set")
+	}
+	if w.Header().Get("Last-Modified") == "" {
+		t.Error("expected a Last-Modified header to be set")
+	}
+}
+
+func TestNewSummaryHandler_IfNoneMatchReturnsNotModified(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "names.gz")
+	if err := os.WriteFile(path, []byte("names"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	d := Deps{
+		OeisDir:               dir,
+		SummaryUpdateInterval: time.Hour,
+		Serve: func(w http.ResponseWriter, req *http.Request, path string) {
+			t.Error("Serve should not be called for a matching If-None-Match")
+		},
+	}
+
+	etag, err := fileETag(path)
+	if err != nil {
+		t.Fatalf("fileETag failed: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/oeis/names.gz", nil)
+	req.Header.Set("If-None-Match", etag)
+	w := httptest.NewRecorder()
+	NewSummaryHandler(d, "names.gz").ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestNewSummaryHandler_IfModifiedSinceReturnsNotModified(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "names.gz")
+	if err := os.WriteFile(path, []byte("names"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	d := Deps{
+		OeisDir:               dir,
+		SummaryUpdateInterval: time.Hour,
+		Serve: func(w http.ResponseWriter, req *http.Request, path string) {
+			t.Error("Serve should not be called for a matching If-Modified-Since")
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/oeis/names.gz", nil)
+	req.Header.Set("If-Modified-Since", info.ModTime().Add(time.Second).UTC().Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	NewSummaryHandler(d, "names.gz").ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestNewBFileHandler_IfNoneMatchReturnsNotModified(t *testing.T) {
+	dir := t.TempDir()
+	bdir := filepath.Join(dir, "b", "000")
+	if err := os.MkdirAll(bdir, os.ModePerm); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	path := filepath.Join(bdir, "b000045.txt.gz")
+	if err := os.WriteFile(path, []byte("b-file"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	d := Deps{
+		OeisDir:             dir,
+		BfileUpdateInterval: time.Hour,
+		Serve: func(w http.ResponseWriter, req *http.Request, path string) {
+			t.Error("Serve should not be called for a matching If-None-Match")
+		},
+	}
+
+	etag, err := fileETag(path)
+	if err != nil {
+		t.Fatalf("fileETag failed: %v", err)
+	}
+	router := mux.NewRouter()
+	router.Handle("/v1/oeis/b{id:[0-9]+}.txt.gz", NewBFileHandler(d))
+	req := httptest.NewRequest(http.MethodGet, "/v1/oeis/b000045.txt.gz", nil)
+	req.Header.Set("If-None-Match", etag)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
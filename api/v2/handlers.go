@@ -0,0 +1,100 @@
+// Package v2 holds the HTTP handlers behind the /v2/sequences/* routes,
+// backed by a shared.DataIndex built from the submission corpus.
+package v2
+
+import (
+	"iter"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+)
+
+// IndexProvider returns the DataIndex backing the sequence endpoints,
+// building or reloading it lazily.
+type IndexProvider interface {
+	Index() *shared.DataIndex
+}
+
+func NewSequenceHandler(p IndexProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		params := mux.Vars(req)
+		idStr := params["id"]
+		uid, err := util.NewUIDFromString(idStr)
+		if err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		seq := shared.FindSequenceById(p.Index(), uid)
+		if seq == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		util.WriteJsonResponse(w, seq)
+	})
+}
+
+func NewSequenceSearchHandler(p IndexProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		q := req.URL.Query().Get("q")
+		limit, skip, shuffle, orderBy := util.ParseListParams(req, 10, 100, shared.SequenceOrderByRegistry)
+		minScore, _ := strconv.ParseFloat(req.URL.Query().Get("min_score"), 64)
+		sortBy := shared.SequenceSortBy(req.URL.Query().Get("sort"))
+
+		if req.URL.Query().Get("format") == "ndjson" {
+			seq, _ := shared.SearchSequencesIter(p.Index(), q, skip, shuffle, minScore, sortBy, orderBy)
+			util.WriteNDJSONStream(w, req, searchItemSeq(seq, limit))
+			return
+		}
+
+		results, total := shared.SearchSequences(p.Index(), q, limit, skip, shuffle, minScore, sortBy, orderBy)
+		resp := shared.SearchResult{
+			Total: total,
+		}
+		for _, seq := range results {
+			resp.Results = append(resp.Results, shared.SearchItem{
+				Id:       seq.Id.String(),
+				Name:     seq.Name,
+				Keywords: shared.DecodeKeywords(seq.Keywords),
+				Score:    seq.Score,
+			})
+		}
+		util.WriteJsonResponse(w, resp)
+	})
+}
+
+// searchItemSeq adapts results (already skip-applied by SearchSequencesIter)
+// into an iter.Seq[any] of shared.SearchItem, the same shape the JSON
+// endpoint's SearchResult.Results uses, stopping after limit matches (0
+// meaning unlimited) since SearchSequencesIter itself doesn't know about
+// "limit" the way SearchSequences does.
+func searchItemSeq(results iter.Seq[shared.ScoredSequence], limit int) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		n := 0
+		for seq := range results {
+			if limit > 0 && n >= limit {
+				return
+			}
+			item := shared.SearchItem{
+				Id:       seq.Id.String(),
+				Name:     seq.Name,
+				Keywords: shared.DecodeKeywords(seq.Keywords),
+				Score:    seq.Score,
+			}
+			if !yield(item) {
+				return
+			}
+			n++
+		}
+	}
+}
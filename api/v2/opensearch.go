@@ -0,0 +1,108 @@
+package v2
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+)
+
+// suggestLimit caps how many rows an OpenSearch Suggestions response
+// returns, per the format's typeahead-dropdown use case.
+const suggestLimit = 10
+
+// openSearchDescription is an OpenSearch 1.1 description document; see
+// https://github.com/dewitt/opensearch/blob/master/opensearch-1-1-draft-6/opensearch.xsd
+type openSearchDescription struct {
+	XMLName       xml.Name        `xml:"OpenSearchDescription"`
+	Xmlns         string          `xml:"xmlns,attr"`
+	ShortName     string          `xml:"ShortName"`
+	Description   string          `xml:"Description"`
+	InputEncoding string          `xml:"InputEncoding"`
+	Url           []openSearchUrl `xml:"Url"`
+}
+
+type openSearchUrl struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// NewOpenSearchHandler serves an OpenSearch 1.1 description document at
+// e.g. /opensearch.xml, advertising htmlURLTemplate (the browser's search
+// results page) and suggestURLTemplate (the JSON suggestions endpoint this
+// package implements). Both templates are served as-is and must already
+// contain the literal "{searchTerms}" placeholder the browser substitutes.
+func NewOpenSearchHandler(shortName, description, htmlURLTemplate, suggestURLTemplate string) http.Handler {
+	doc := openSearchDescription{
+		Xmlns:         "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:     shortName,
+		Description:   description,
+		InputEncoding: "UTF-8",
+		Url: []openSearchUrl{
+			{Type: "text/html", Template: htmlURLTemplate},
+			{Type: "application/x-suggestions+json", Template: suggestURLTemplate},
+		},
+	}
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err) // doc is a static literal; marshaling it can't fail
+	}
+	body = append([]byte(xml.Header), body...)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/opensearchdescription+xml")
+		w.Write(body)
+	})
+}
+
+// NewSequenceSuggestHandler serves OpenSearch Suggestions for sequences: it
+// runs the "q" query param through SearchSequences, same as regular search
+// (so it respects the same keyword filtering), and returns at most
+// suggestLimit matches as [query, names, ids, urls].
+func NewSequenceSuggestHandler(p IndexProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		q := req.URL.Query().Get("q")
+		results, _ := shared.SearchSequences(p.Index(), q, suggestLimit, 0, false, 0, "", nil)
+		names := make([]string, len(results))
+		ids := make([]string, len(results))
+		urls := make([]string, len(results))
+		for i, seq := range results {
+			names[i] = seq.Name
+			ids[i] = seq.Id.String()
+			urls[i] = fmt.Sprintf("https://oeis.org/%s", seq.Id.String())
+		}
+		util.WriteOpenSearchSuggestions(w, q, names, ids, urls)
+	})
+}
+
+// NewProgramSuggestHandler is NewSequenceSuggestHandler for programs: it
+// runs the "q" query param through SearchPrograms and returns at most
+// suggestLimit matches as [query, names, ids, urls].
+func NewProgramSuggestHandler(p IndexProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		q := req.URL.Query().Get("q")
+		results, _ := shared.SearchPrograms(p.Index(), q, suggestLimit, 0, false)
+		names := make([]string, len(results))
+		ids := make([]string, len(results))
+		urls := make([]string, len(results))
+		for i, prog := range results {
+			names[i] = prog.Name
+			ids[i] = prog.Id.String()
+			urls[i] = fmt.Sprintf("https://api.loda-lang.org/v2/programs/%s", prog.Id.String())
+		}
+		util.WriteOpenSearchSuggestions(w, q, names, ids, urls)
+	})
+}
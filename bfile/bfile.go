@@ -0,0 +1,310 @@
+// Package bfile manages OEIS b-file removal, restoration, and auditing for
+// the programs API. It replaces the old inline os.Remove-and-forget logic in
+// ProgramsServer with a subsystem that survives restarts, supports undo
+// within the protection window, and keeps an audit trail.
+package bfile
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+const (
+	// ProtectionDuration is how long a removed b-file is kept in the trash
+	// and protected from re-removal, mirroring the previous 24h window.
+	ProtectionDuration = 24 * time.Hour
+
+	// NumRemovalsPerSubmitter bounds how many b-files a single submitter may
+	// remove, independent of the NumSubmissionsPerUser submission quota.
+	NumRemovalsPerSubmitter = 20
+
+	removalsFile = "bfile_removals.json"
+	auditLogFile = "bfile_audit.jsonl"
+	trashDirName = ".trash"
+
+	bfileIDLength     = 7 // e.g. "A000045"
+	bfileIDPrefix     = 'A'
+	bfileDirPrefixLen = 3
+)
+
+// AuditEntry is a single append-only audit log record for a b-file action.
+type AuditEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Submitter   string    `json:"submitter"`
+	Id          string    `json:"id"`
+	Action      string    `json:"action"` // "remove" or "restore"
+	PriorSha256 string    `json:"priorSha256,omitempty"`
+}
+
+// Metadata describes the current state of a b-file.
+type Metadata struct {
+	Id           string    `json:"id"`
+	Exists       bool      `json:"exists"`
+	Size         int64     `json:"size,omitempty"`
+	ModTime      time.Time `json:"modTime,omitempty"`
+	TermCount    int       `json:"termCount,omitempty"`
+	LastModifier string    `json:"lastModifier,omitempty"`
+}
+
+// Store manages b-file removal, restoration, quotas, and audit logging.
+type Store struct {
+	dataDir string
+
+	mutex           sync.Mutex
+	removals        map[string]time.Time // id -> removal time, persisted to disk
+	removalsPerUser map[string]int       // submitter -> removal count
+}
+
+// NewStore creates a Store rooted at dataDir. Call Load once at startup to
+// restore removal state persisted by a previous process.
+func NewStore(dataDir string) *Store {
+	return &Store{
+		dataDir:         dataDir,
+		removals:        make(map[string]time.Time),
+		removalsPerUser: make(map[string]int),
+	}
+}
+
+// Load restores persisted removal timestamps so protection windows survive a
+// restart. A missing file is not an error (fresh install).
+func (s *Store) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := os.ReadFile(filepath.Join(s.dataDir, removalsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read bfile removals: %w", err)
+	}
+	var removals map[string]time.Time
+	if err := json.Unmarshal(data, &removals); err != nil {
+		return fmt.Errorf("cannot decode bfile removals: %w", err)
+	}
+	s.removals = removals
+	return nil
+}
+
+// persistRemovals writes the removals map atomically. Caller must hold mutex.
+func (s *Store) persistRemovals() error {
+	path := filepath.Join(s.dataDir, removalsFile)
+	tmp := path + ".tmp"
+	data, err := json.MarshalIndent(s.removals, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal bfile removals: %w", err)
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("cannot write bfile removals: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ClearUserQuotas resets per-submitter removal counts, mirroring the
+// submission quota reset that ProgramsServer already runs periodically.
+func (s *Store) ClearUserQuotas() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removalsPerUser = make(map[string]int)
+}
+
+func bfilePath(dataDir, id string) (string, error) {
+	if len(id) != bfileIDLength || id[0] != bfileIDPrefix {
+		return "", fmt.Errorf("invalid sequence ID format: %s", id)
+	}
+	numericId := id[1:]
+	dir := filepath.Join(dataDir, "seqs", "oeis", "b", numericId[0:bfileDirPrefixLen])
+	filename := fmt.Sprintf("b%s.txt.gz", numericId)
+	return filepath.Join(dir, filename), nil
+}
+
+func (s *Store) trashPath(id string, ts time.Time) string {
+	numericId := id[1:]
+	dir := filepath.Join(s.dataDir, "seqs", "oeis", "b", trashDirName, numericId)
+	filename := fmt.Sprintf("%d.txt.gz", ts.UnixNano())
+	return filepath.Join(dir, filename)
+}
+
+func sha256OfFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Remove moves a b-file into the trash (instead of deleting it outright) so
+// it can be undone with Restore within ProtectionDuration, enforces the 24h
+// re-removal lock and the per-submitter removal quota, and appends an audit
+// log entry.
+func (s *Store) Remove(submitter, id string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.removalsPerUser[submitter] >= NumRemovalsPerSubmitter {
+		return "", fmt.Errorf("too many b-file removals by %s", submitter)
+	}
+	if lastRemoval, exists := s.removals[id]; exists {
+		if time.Since(lastRemoval) < ProtectionDuration {
+			remaining := ProtectionDuration - time.Since(lastRemoval)
+			return "", fmt.Errorf("b-file is protected for %.0f more hours", remaining.Hours())
+		}
+	}
+
+	path, err := bfilePath(s.dataDir, id)
+	if err != nil {
+		return "", fmt.Errorf("invalid b-file ID: %w", err)
+	}
+	if !util.FileExists(path) {
+		return "", fmt.Errorf("b-file does not exist")
+	}
+	priorSha, _ := sha256OfFile(path)
+
+	now := time.Now()
+	trashPath := s.trashPath(id, now)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return "", fmt.Errorf("cannot create trash directory: %w", err)
+	}
+	if err := os.Rename(path, trashPath); err != nil {
+		return "", fmt.Errorf("cannot move b-file to trash: %w", err)
+	}
+
+	s.removals[id] = now
+	s.removalsPerUser[submitter]++
+	if err := s.persistRemovals(); err != nil {
+		return "", fmt.Errorf("cannot persist removal state: %w", err)
+	}
+	s.appendAudit(AuditEntry{Timestamp: now, Submitter: submitter, Id: id, Action: "remove", PriorSha256: priorSha})
+	return "B-file removed", nil
+}
+
+// Restore undoes a removal within the protection window by moving the most
+// recently trashed copy of id back into place.
+func (s *Store) Restore(submitter, id string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lastRemoval, exists := s.removals[id]
+	if !exists || time.Since(lastRemoval) >= ProtectionDuration {
+		return "", fmt.Errorf("no recent removal to restore for %s", id)
+	}
+	numericId := id[1:]
+	trashDir := filepath.Join(s.dataDir, "seqs", "oeis", "b", trashDirName, numericId)
+	entries, err := os.ReadDir(trashDir)
+	if err != nil || len(entries) == 0 {
+		return "", fmt.Errorf("no trashed b-file found for %s", id)
+	}
+	// The trash filename is a UnixNano timestamp, so the lexicographically
+	// largest entry is the most recent removal.
+	latest := entries[0]
+	for _, e := range entries {
+		if e.Name() > latest.Name() {
+			latest = e
+		}
+	}
+	trashedPath := filepath.Join(trashDir, latest.Name())
+
+	path, err := bfilePath(s.dataDir, id)
+	if err != nil {
+		return "", fmt.Errorf("invalid b-file ID: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("cannot create b-file directory: %w", err)
+	}
+	if err := os.Rename(trashedPath, path); err != nil {
+		return "", fmt.Errorf("cannot restore b-file: %w", err)
+	}
+
+	delete(s.removals, id)
+	if err := s.persistRemovals(); err != nil {
+		return "", fmt.Errorf("cannot persist removal state: %w", err)
+	}
+	priorSha, _ := sha256OfFile(path)
+	s.appendAudit(AuditEntry{Timestamp: time.Now(), Submitter: submitter, Id: id, Action: "restore", PriorSha256: priorSha})
+	return "B-file restored", nil
+}
+
+// Metadata returns current on-disk information about a b-file.
+func (s *Store) Metadata(id string) (Metadata, error) {
+	path, err := bfilePath(s.dataDir, id)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("invalid b-file ID: %w", err)
+	}
+	meta := Metadata{Id: id}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, fmt.Errorf("cannot stat b-file: %w", err)
+	}
+	meta.Exists = true
+	meta.Size = info.Size()
+	meta.ModTime = info.ModTime()
+	if modifier, ok := s.lastModifier(id); ok {
+		meta.LastModifier = modifier
+	}
+	return meta, nil
+}
+
+// lastModifier returns the submitter of the most recent audit entry for id.
+func (s *Store) lastModifier(id string) (string, bool) {
+	history, err := s.History(id)
+	if err != nil || len(history) == 0 {
+		return "", false
+	}
+	return history[len(history)-1].Submitter, true
+}
+
+// History returns the audit log entries for id, oldest first.
+func (s *Store) History(id string) ([]AuditEntry, error) {
+	file, err := os.Open(filepath.Join(s.dataDir, auditLogFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open bfile audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Id == id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// appendAudit appends entry to the JSONL audit log. Failures are not fatal to
+// the calling remove/restore operation, but are returned so callers may log
+// them.
+func (s *Store) appendAudit(entry AuditEntry) error {
+	f, err := os.OpenFile(filepath.Join(s.dataDir, auditLogFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open bfile audit log: %w", err)
+	}
+	defer f.Close()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cannot marshal audit entry: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write audit entry: %w", err)
+	}
+	return nil
+}
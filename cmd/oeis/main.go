@@ -1,20 +1,41 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	v1 "github.com/loda-lang/loda-api/api/v1"
 	"github.com/loda-lang/loda-api/cmd"
+	"github.com/loda-lang/loda-api/crawler"
+	"github.com/loda-lang/loda-api/storage"
 	"github.com/loda-lang/loda-api/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// fetchMaxAttempts bounds how many times fetchWithFallback retries a
+// transient failure before giving up and starting the URL's cooldown.
+const fetchMaxAttempts = 4
+
+// fetchBaseBackoff is the delay before the first retry; each further retry
+// doubles it, plus a random jitter of the same magnitude. It's a var
+// rather than a const so tests can shrink it.
+var fetchBaseBackoff = 500 * time.Millisecond
+
+// fetchCooldownTTL is how long a URL that exhausted its retries is skipped
+// on subsequent requests, so a hot failing path doesn't hammer OEIS.
+const fetchCooldownTTL = 1 * time.Minute
+
 type OeisServer struct {
 	oeisDir                string
 	bfileUpdateInterval    time.Duration
@@ -24,10 +45,20 @@ type OeisServer struct {
 	crawlerRestartPause    time.Duration
 	crawlerFlushInterval   int
 	crawlerIdsCacheSize    int
-	crawlerStopped         chan bool
-	crawler                *Crawler
+	crawler                *crawler.Crawler
 	httpClient             *http.Client
-	lists                  []*List
+	lists                  []*storage.List
+
+	fdMutex       sync.Mutex
+	fetchDeadline time.Duration
+	fetchCancel   *crawler.FetchCancel
+	runCancel     context.CancelFunc
+	crawlerWG     sync.WaitGroup
+
+	cooldownMutex  sync.Mutex
+	fetchCooldowns map[string]time.Time
+
+	metrics *Metrics
 }
 
 const (
@@ -45,10 +76,10 @@ var (
 	}
 )
 
-func NewOeisServer(oeisDir string, updateInterval time.Duration) *OeisServer {
+func NewOeisServer(oeisDir string, updateInterval time.Duration, insecureSkipVerify bool) *OeisServer {
 	httpClient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
 		},
 		CheckRedirect: func(r *http.Request, via []*http.Request) error {
 			r.URL.Opaque = r.URL.Path
@@ -56,9 +87,9 @@ func NewOeisServer(oeisDir string, updateInterval time.Duration) *OeisServer {
 		},
 	}
 	i := 0
-	lists := make([]*List, len(ListNames))
+	lists := make([]*storage.List, len(ListNames))
 	for key, name := range ListNames {
-		lists[i] = NewList(key, name, oeisDir)
+		lists[i] = storage.NewList(key, name, oeisDir)
 		i++
 	}
 	return &OeisServer{
@@ -70,95 +101,155 @@ func NewOeisServer(oeisDir string, updateInterval time.Duration) *OeisServer {
 		crawlerRestartPause:    1 * time.Minute,
 		crawlerFlushInterval:   100,
 		crawlerIdsCacheSize:    1000,
-		crawlerStopped:         make(chan bool),
-		crawler:                NewCrawler(httpClient),
+		crawler:                crawler.NewCrawler(httpClient),
 		httpClient:             httpClient,
 		lists:                  lists,
+		fetchDeadline:          30 * time.Second,
+		fetchCancel:            crawler.NewFetchCancel(),
+		fetchCooldowns:         make(map[string]time.Time),
+		metrics:                NewMetrics(),
 	}
 }
 
-func newSummaryHandler(s *OeisServer, filename string) http.Handler {
-	f := func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		path := filepath.Join(s.oeisDir, filename)
-		if !util.IsFileRecent(path, s.summaryUpdateInterval) {
-			err := util.FetchFile(s.httpClient, OeisWebsite+filename, path)
-			if err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatal(err)
-			}
-		}
-		util.ServeBinary(w, req, path)
+// SetFetchDeadline configures how long a single OEIS fetch or list flush
+// may run before it is cancelled, mirroring the net.Conn SetDeadline API.
+// It takes effect immediately: any fetch or flush currently in flight is
+// woken up via fetchCancel so it picks up the new deadline right away.
+func (s *OeisServer) SetFetchDeadline(d time.Duration) {
+	s.fdMutex.Lock()
+	s.fetchDeadline = d
+	s.fdMutex.Unlock()
+	s.fetchCancel.Cancel()
+}
+
+func (s *OeisServer) getFetchDeadline() time.Duration {
+	s.fdMutex.Lock()
+	defer s.fdMutex.Unlock()
+	return s.fetchDeadline
+}
+
+func (s *OeisServer) v1Deps() v1.Deps {
+	return v1.Deps{
+		HttpClient:            s.httpClient,
+		OeisDir:               s.oeisDir,
+		Website:               OeisWebsite,
+		SummaryUpdateInterval: s.summaryUpdateInterval,
+		BfileUpdateInterval:   s.bfileUpdateInterval,
+		Serve:                 util.ServeBinary,
+		Fetch:                 s.fetchWithFallback,
+		Metrics:               s.metrics,
 	}
-	return http.HandlerFunc(f)
 }
 
-func newBFileHandler(s *OeisServer) http.Handler {
-	f := func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
+// fetchWithFallback is the v1.Deps.Fetch implementation for OeisServer: it
+// retries a failed download up to fetchMaxAttempts times with exponential
+// backoff and jitter, then puts url into cooldown for fetchCooldownTTL so a
+// hot failing path can't hammer OEIS with a fresh round of retries on every
+// incoming request. The caller (api/v1) decides what to do with a returned
+// error — typically falling back to a stale cached copy rather than failing
+// the request outright. ctx is the serving request's context; it's checked
+// between retries so a client that gives up doesn't keep a retry loop
+// running on its behalf.
+func (s *OeisServer) fetchWithFallback(ctx context.Context, httpClient *http.Client, url string, localFile string) error {
+	if until, cooling := s.fetchCooldownUntil(url); cooling {
+		return fmt.Errorf("%s is in cooldown until %s after repeated failures", url, until.Format(time.RFC3339))
+	}
+	var lastErr error
+	for attempt := 0; attempt < fetchMaxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := fetchBaseBackoff * time.Duration(int64(1)<<uint(attempt-1))
+			select {
+			case <-time.After(backoff + time.Duration(rand.Int63n(int64(backoff)))):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
-		params := mux.Vars(req)
-		id := params["id"]
-		if len(id) != 6 {
-			util.WriteHttpBadRequest(w)
-			return
+		retryable, err := fetchOnce(ctx, httpClient, url, localFile)
+		if err == nil {
+			s.clearFetchCooldown(url)
+			return nil
 		}
-		dir := filepath.Join(s.oeisDir, "b", id[0:3])
-		os.MkdirAll(dir, os.ModePerm)
-		filename := fmt.Sprintf("b%s.txt.gz", id)
-		path := filepath.Join(dir, filename)
-		if !util.IsFileRecent(path, s.bfileUpdateInterval) {
-			url := fmt.Sprintf("%sA%s/b%s.txt", OeisWebsite, id, id)
-			txtpath := filepath.Join(dir, fmt.Sprintf("b%s.txt", id))
-			err := util.FetchFile(s.httpClient, url, txtpath)
-			if err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatal(err)
-			}
-			err = exec.Command("gzip", "-f", txtpath).Run()
-			if err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatalf("Error executing gzip: %v", err)
-			}
+		lastErr = err
+		log.Printf("Fetch attempt %d/%d for %s failed: %v", attempt+1, fetchMaxAttempts, url, err)
+		if !retryable {
+			break
 		}
-		util.ServeBinary(w, req, path)
 	}
-	return http.HandlerFunc(f)
+	s.setFetchCooldown(url)
+	return lastErr
 }
 
-func newListHandler(l *List) http.Handler {
-	f := func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		l.ServeGzip(w, req)
+// fetchOnce performs a single download attempt and reports whether the
+// failure is worth retrying: a 5xx response or a network-level error is
+// presumed transient, while a 4xx means the resource genuinely isn't there
+// and retrying would just waste time hammering OEIS.
+func fetchOnce(ctx context.Context, httpClient *http.Client, url string, localFile string) (retryable bool, err error) {
+	return util.FetchFileWithOptions(ctx, httpClient, url, localFile, util.FetchFileOptions{})
+}
+
+func (s *OeisServer) fetchCooldownUntil(url string) (time.Time, bool) {
+	s.cooldownMutex.Lock()
+	defer s.cooldownMutex.Unlock()
+	until, ok := s.fetchCooldowns[url]
+	if !ok || time.Now().After(until) {
+		return time.Time{}, false
 	}
-	return http.HandlerFunc(f)
+	return until, true
+}
+
+func (s *OeisServer) setFetchCooldown(url string) {
+	s.cooldownMutex.Lock()
+	defer s.cooldownMutex.Unlock()
+	s.fetchCooldowns[url] = time.Now().Add(fetchCooldownTTL)
 }
 
-func (s *OeisServer) Run(port int) {
+func (s *OeisServer) clearFetchCooldown(url string) {
+	s.cooldownMutex.Lock()
+	defer s.cooldownMutex.Unlock()
+	delete(s.fetchCooldowns, url)
+}
+
+// Run serves the OeisServer's routes until ctx is cancelled, at which point
+// it shuts the HTTP server down gracefully and returns instead of blocking
+// forever in http.ListenAndServe.
+func (s *OeisServer) Run(ctx context.Context, port int) error {
+	deps := s.v1Deps()
 	router := mux.NewRouter()
-	router.Handle("/v1/oeis/names.gz", newSummaryHandler(s, "names.gz"))
-	router.Handle("/v1/oeis/stripped.gz", newSummaryHandler(s, "stripped.gz"))
-	router.Handle("/v1/oeis/b{id:[0-9]+}.txt.gz", newBFileHandler(s))
+	router.Use(util.RequestMetricsMiddleware(s.metrics))
+	router.Handle("/v1/oeis/names.gz", v1.NewSummaryHandler(deps, "names.gz"))
+	router.Handle("/v1/oeis/stripped.gz", v1.NewSummaryHandler(deps, "stripped.gz"))
+	router.Handle("/v1/oeis/b{id:[0-9]+}.txt.gz", v1.NewBFileHandler(deps))
 	for _, l := range s.lists {
-		router.Handle(fmt.Sprintf("/v1/oeis/%s.gz", l.name), newListHandler(l))
+		router.Handle(fmt.Sprintf("/v1/oeis/%s.gz", l.Name), v1.NewListHandler(l))
+		router.Handle(fmt.Sprintf("/v1/oeis/%s.delta", l.Name), v1.NewListDeltaHandler(l))
 	}
+	router.Handle("/metrics", promhttp.HandlerFor(s.metrics.Registry, promhttp.HandlerOpts{}))
 	router.NotFoundHandler = http.HandlerFunc(util.HandleNotFound)
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: router}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
 	log.Printf("Using data dir %s", s.oeisDir)
 	log.Printf("Listening on port %d", port)
-	http.ListenAndServe(fmt.Sprintf(":%d", port), router)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
 
+// StopCrawler cancels the running crawl, including any fetch or flush
+// currently in flight, and schedules it to start again after
+// crawlerRestartInterval plus crawlerRestartPause. It does not wait for the
+// crawl goroutine to drain; use Shutdown for a final, non-restarting stop.
 func (s *OeisServer) StopCrawler() {
 	log.Print("Stopping crawler")
-	s.crawlerStopped <- true
+	if s.runCancel != nil {
+		s.runCancel()
+	}
+	s.fetchCancel.Cancel()
 	restartTimer := time.NewTimer(s.crawlerRestartInterval)
 	go func() {
 		<-restartTimer.C
@@ -167,55 +258,98 @@ func (s *OeisServer) StopCrawler() {
 	}()
 }
 
+// Shutdown stops the crawler for good: it cancels any in-flight fetch or
+// flush, waits for the crawl goroutine to drain, and flushes each list one
+// last time so buffered fields aren't lost. Unlike StopCrawler, it does not
+// schedule a restart; it's meant to be called once, from the SIGINT/SIGTERM
+// handler in main.
+func (s *OeisServer) Shutdown() {
+	log.Print("Shutting down crawler")
+	if s.runCancel != nil {
+		s.runCancel()
+	}
+	s.fetchCancel.Cancel()
+	s.crawlerWG.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), s.getFetchDeadline())
+	defer cancel()
+	for _, l := range s.lists {
+		if err := l.Flush(ctx); err != nil {
+			log.Printf("Error flushing list %s: %v", l.Name, err)
+		}
+	}
+}
+
 func (s *OeisServer) StartCrawler() {
-	err := s.crawler.Init()
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.crawler.Init(ctx)
 	if err != nil {
 		log.Printf("Error initializing crawler: %v", err)
+		cancel()
 		return
 	}
 	fetchTicker := time.NewTicker(s.crawlerFetchInterval)
-	s.crawlerStopped = make(chan bool)
+	s.runCancel = cancel
+	s.crawlerWG.Add(1)
 	go func() {
+		defer s.crawlerWG.Done()
+		defer fetchTicker.Stop()
 		for {
 			select {
-			case <-s.crawlerStopped:
+			case <-ctx.Done():
 				return
 			case <-fetchTicker.C:
-				if s.crawler.numFetched%s.crawlerFlushInterval == 0 {
-					if s.crawler.numFetched > 0 {
+				fetchCtx, cancelFetch := context.WithTimeout(ctx, s.getFetchDeadline())
+				// SetFetchDeadline/StopCrawler wake a pending fetch or flush
+				// by closing fetchCancel; bridge that into fetchCtx so the
+				// in-flight call actually unblocks.
+				go func() {
+					select {
+					case <-s.fetchCancel.Done():
+						cancelFetch()
+					case <-fetchCtx.Done():
+					}
+				}()
+
+				if s.crawler.NumFetched%s.crawlerFlushInterval == 0 {
+					if s.crawler.NumFetched > 0 {
 						// Flush the lists
 						for _, l := range s.lists {
-							err := l.Flush()
+							err := l.Flush(fetchCtx)
 							if err != nil {
-								log.Printf("Error flushing list %s: %v", l.name, err)
+								log.Printf("Error flushing list %s: %v", l.Name, err)
+								cancelFetch()
 								s.StopCrawler()
+								return
 							}
 						}
 					}
 				}
-				if s.crawler.numFetched%s.crawlerIdsCacheSize == 0 {
+				if s.crawler.NumFetched%s.crawlerIdsCacheSize == 0 {
 					// Find the missing ids
 					for _, l := range s.lists {
-						if l.name == "offsets" {
-							ids, _, err := l.FindMissingIds(s.crawler.maxId, s.crawlerIdsCacheSize)
+						if l.Name == "offsets" {
+							ids, _, err := l.FindMissingIds(fetchCtx, s.crawler.MaxId, s.crawlerIdsCacheSize)
 							if err != nil {
+								cancelFetch()
 								s.StopCrawler()
+								return
 							}
-							s.crawler.missingIds = ids
+							s.crawler.MissingIds = ids
 							break
 						}
 					}
 				}
 				// Fetch the next sequence
-				fields, _, err := s.crawler.FetchNext()
+				fields, _, err := s.crawler.FetchNext(fetchCtx)
+				cancelFetch()
 				if err != nil {
 					log.Printf("Error fetching fields: %v", err)
 					s.StopCrawler()
-				} else {
-					// Update the lists with the new fields
-					for _, l := range s.lists {
-						l.Update(fields)
-					}
+					return
+				}
+				// Update the lists with the new fields
+				for _, l := range s.lists {
+					l.Update(fields)
 				}
 			}
 		}
@@ -224,10 +358,26 @@ func (s *OeisServer) StartCrawler() {
 
 func main() {
 	setup := cmd.GetSetup("oeis")
+	if err := setup.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	util.MustDirExist(setup.DataDir)
 	oeisDir := filepath.Join(setup.DataDir, "oeis")
 	os.MkdirAll(oeisDir, os.ModePerm)
-	s := NewOeisServer(oeisDir, setup.UpdateInterval)
+	s := NewOeisServer(oeisDir, setup.UpdateInterval, setup.Oeis.InsecureSkipVerify)
 	s.StartCrawler()
-	s.Run(8080)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Print("Received shutdown signal, draining crawler")
+		s.Shutdown()
+		cancel()
+	}()
+
+	if err := s.Run(ctx, 8080); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	fetchBaseBackoff = time.Millisecond
+}
+
+func TestFetchWithFallback_SucceedsOnFirstTry(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	s := NewOeisServer(t.TempDir(), 0, false)
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	assert.NoError(t, s.fetchWithFallback(context.Background(), s.httpClient, upstream.URL, dst))
+
+	got, err := os.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(got))
+}
+
+func TestFetchWithFallback_RetriesOn5xxThenSucceeds(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	s := NewOeisServer(t.TempDir(), 0, false)
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	assert.NoError(t, s.fetchWithFallback(context.Background(), s.httpClient, upstream.URL, dst))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestFetchWithFallback_DoesNotRetry4xx(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	s := NewOeisServer(t.TempDir(), 0, false)
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	err := s.fetchWithFallback(context.Background(), s.httpClient, upstream.URL, dst)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestFetchWithFallback_CoolsDownAfterExhaustingRetries(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	s := NewOeisServer(t.TempDir(), 0, false)
+	dst := filepath.Join(t.TempDir(), "out.txt")
+
+	assert.Error(t, s.fetchWithFallback(context.Background(), s.httpClient, upstream.URL, dst))
+	callsAfterFirstRound := atomic.LoadInt32(&calls)
+	assert.Equal(t, int32(fetchMaxAttempts), callsAfterFirstRound)
+
+	// A second call while the URL is cooling down shouldn't hit upstream again.
+	assert.Error(t, s.fetchWithFallback(context.Background(), s.httpClient, upstream.URL, dst))
+	assert.Equal(t, callsAfterFirstRound, atomic.LoadInt32(&calls))
+}
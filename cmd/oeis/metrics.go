@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors published by an OeisServer at
+// /metrics. It implements util.HTTPMetrics (requests per route/status,
+// bytes served) and v1.FetchMetrics (upstream fetch outcomes, cached-file
+// age), so both the request middleware in Run and the handlers in api/v1
+// can report into it without either package importing Prometheus itself.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	RequestsTotal    *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	BytesServedTotal *prometheus.CounterVec
+	FetchTotal       *prometheus.CounterVec
+	FetchDuration    prometheus.Histogram
+	CacheAgeSeconds  prometheus.Histogram
+}
+
+// NewMetrics creates and registers the Prometheus collectors used by an
+// OeisServer.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_oeis_requests_total",
+			Help: "Total number of requests, by route, method, and status code.",
+		}, []string{"route", "method", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "loda_oeis_request_duration_seconds",
+			Help:    "Duration of requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		BytesServedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_oeis_bytes_served_total",
+			Help: "Total bytes written to responses, by route.",
+		}, []string{"route"}),
+		FetchTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_oeis_fetch_total",
+			Help: "Total upstream fetch attempts, by outcome (hit, refreshed, failed).",
+		}, []string{"outcome"}),
+		FetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "loda_oeis_fetch_duration_seconds",
+			Help:    "Duration of upstream fetch attempts that were not served from a fresh cache.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		CacheAgeSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "loda_oeis_cache_age_seconds",
+			Help:    "Age of the cached file being served, measured at serve time.",
+			Buckets: prometheus.ExponentialBuckets(60, 4, 8), // 1m .. ~71 days
+		}),
+	}
+	m.Registry.MustRegister(
+		m.RequestsTotal,
+		m.RequestDuration,
+		m.BytesServedTotal,
+		m.FetchTotal,
+		m.FetchDuration,
+		m.CacheAgeSeconds,
+	)
+	return m
+}
+
+// ObserveRequest implements util.HTTPMetrics.
+func (m *Metrics) ObserveRequest(route, method string, status int, bytes int64, duration time.Duration) {
+	m.RequestsTotal.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.RequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+	m.BytesServedTotal.WithLabelValues(route).Add(float64(bytes))
+}
+
+// ObserveFetch implements v1.FetchMetrics.
+func (m *Metrics) ObserveFetch(outcome string, duration time.Duration) {
+	m.FetchTotal.WithLabelValues(outcome).Inc()
+	if duration > 0 {
+		m.FetchDuration.Observe(duration.Seconds())
+	}
+}
+
+// ObserveCacheAge implements v1.FetchMetrics.
+func (m *Metrics) ObserveCacheAge(age time.Duration) {
+	m.CacheAgeSeconds.Observe(age.Seconds())
+}
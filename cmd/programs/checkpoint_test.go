@@ -40,13 +40,13 @@ func TestCheckpoint_WriteAndLoad_JSON(t *testing.T) {
 		},
 	}
 
-	// Write checkpoint
+	// Write checkpoint (compacts the submission WAL into a snapshot)
 	err = server.writeCheckpoint()
 	assert.NoError(t, err)
 
-	// Verify the checkpoint file exists
-	checkpointPath := filepath.Join(tmpDir, CheckpointFile)
-	_, err = os.Stat(checkpointPath)
+	// Verify the snapshot file exists
+	snapPath := filepath.Join(tmpDir, SubmissionsSnapFile)
+	_, err = os.Stat(snapPath)
 	assert.NoError(t, err)
 
 	// Create a new server and load the checkpoint
@@ -116,7 +116,7 @@ func TestCheckpoint_MissingFile(t *testing.T) {
 
 func TestCheckSubmit_DuplicateAdd(t *testing.T) {
 	// Create a test server
-	server := NewProgramsServer("", nil, nil)
+	server := NewProgramsServer(t.TempDir(), nil, nil)
 
 	// Create a submission with mode "add"
 	id1, _ := util.NewUIDFromString("A000045")
@@ -150,7 +150,7 @@ func TestCheckSubmit_DuplicateAdd(t *testing.T) {
 
 func TestCheckSubmit_DuplicateRemove(t *testing.T) {
 	// Create a test server
-	server := NewProgramsServer("", nil, nil)
+	server := NewProgramsServer(t.TempDir(), nil, nil)
 
 	// Create a submission with mode "remove"
 	id1, _ := util.NewUIDFromString("A000045")
@@ -184,7 +184,7 @@ func TestCheckSubmit_DuplicateRemove(t *testing.T) {
 
 func TestCheckSubmit_DuplicateUpdate(t *testing.T) {
 	// Create a test server
-	server := NewProgramsServer("", nil, nil)
+	server := NewProgramsServer(t.TempDir(), nil, nil)
 
 	// Create a submission with mode "update"
 	id1, _ := util.NewUIDFromString("A000045")
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -11,15 +13,19 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	v2 "github.com/loda-lang/loda-api/api/v2"
+	"github.com/loda-lang/loda-api/bfile"
 	"github.com/loda-lang/loda-api/cmd"
 	"github.com/loda-lang/loda-api/shared"
 	"github.com/loda-lang/loda-api/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -29,20 +35,24 @@ const (
 	NumSubmissionsPerUser   = 100
 	MaxProgramLength        = 100000
 	MaxNumParallelEval      = 10
+	MaxEvalTimeout          = 30 * time.Second
+	DefaultEvalTimeout      = 10 * time.Second
 	NumTermsCheck           = 8
 	CheckpointInterval      = 10 * time.Minute
 	UpdateInterval          = 24 * time.Hour
 	CheckSessionInterval    = 24 * time.Hour
-	BFileProtectionDuration = 24 * time.Hour
 	CheckpointFile          = "checkpoint.json"
 	CheckpointFileLegacy    = "checkpoint.txt"
 	ProgramSeparator        = "=============================="
-	// B-file ID format constants
-	BFileIDLength    = 7  // Expected length of b-file ID (e.g., "A000045")
-	BFileIDPrefix    = 'A'
-	BFileDirPrefixLen = 3  // Number of digits used for directory prefix
 )
 
+// OperationResult is the JSON body returned for a single submission outcome
+// (accepted, rejected, b-file removed/restored, etc.).
+type OperationResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
 type ProgramsServer struct {
 	dataDir               string
 	influxDbClient        *util.InfluxDbClient
@@ -52,11 +62,12 @@ type ProgramsServer struct {
 	submissions           []shared.Submission // Unified submissions (v1 and v2)
 	submissionsPerProfile map[string]int
 	submissionsPerUser    map[string]int
-	bfileRemovals         map[string]time.Time // Tracks b-file removal times for 24h protection
+	bfiles                *bfile.Store
+	wal                   *SubmissionWAL
+	metrics               *Metrics
 	dataIndexMutex        sync.Mutex
 	submissionsMutex      sync.Mutex
 	updateMutex           sync.Mutex
-	bfileRemovalsMutex    sync.Mutex
 }
 
 func NewProgramsServer(dataDir string, influxDbClient *util.InfluxDbClient, lodaTool *LODATool) *ProgramsServer {
@@ -68,7 +79,9 @@ func NewProgramsServer(dataDir string, influxDbClient *util.InfluxDbClient, loda
 		submissions:           []shared.Submission{},
 		submissionsPerProfile: make(map[string]int),
 		submissionsPerUser:    make(map[string]int),
-		bfileRemovals:         make(map[string]time.Time),
+		bfiles:                bfile.NewStore(dataDir),
+		wal:                   NewSubmissionWAL(dataDir),
+		metrics:               NewMetrics(),
 	}
 }
 
@@ -106,33 +119,50 @@ func (s *ProgramsServer) checkSubmit(submission shared.Submission) (bool, Operat
 	s.checkSession()
 	if len(s.submissions) > NumSubmissionsMax {
 		log.Print("Maximum number of submissions exceeded")
-		return false, OperationResult{Status: "error", Message: "Too many total submissions"}
+		return false, s.rejectSubmission(submission, "Too many total submissions")
 	}
 	if s.submissionsPerUser[submission.Submitter] >= NumSubmissionsPerUser {
 		log.Printf("Rejected submission from %s", submission.Submitter)
-		return false, OperationResult{Status: "error", Message: "Too many user submissions"}
+		return false, s.rejectSubmission(submission, "Too many user submissions")
 	}
 	// Skip duplicate check for remove mode
 	if submission.Mode != shared.ModeRemove {
 		for _, p := range s.submissions {
 			if slices.Equal(p.Operations, submission.Operations) {
-				return false, OperationResult{Status: "error", Message: "Duplicate submission"}
+				return false, s.rejectSubmission(submission, "Duplicate submission")
 			}
 		}
 	}
 	return true, OperationResult{}
 }
 
-func (s *ProgramsServer) doSubmit(submission shared.Submission) OperationResult {
-	profile := submission.MinerProfile
-	if len(profile) == 0 {
-		profile = "unknown"
+// rejectSubmission records a failed submission in the Prometheus
+// submissions counter and returns the corresponding error result.
+func (s *ProgramsServer) rejectSubmission(submission shared.Submission, message string) OperationResult {
+	s.metrics.SubmissionsTotal.WithLabelValues(submissionProfile(submission), string(submission.Mode), string(submission.Type), "error").Inc()
+	return OperationResult{Status: "error", Message: message}
+}
+
+func submissionProfile(submission shared.Submission) string {
+	if len(submission.MinerProfile) == 0 {
+		return "unknown"
 	}
+	return submission.MinerProfile
+}
+
+func (s *ProgramsServer) doSubmit(submission shared.Submission) OperationResult {
+	s.metrics.SubmissionsInflight.Inc()
+	defer s.metrics.SubmissionsInflight.Dec()
+	profile := submissionProfile(submission)
 	s.submissionsMutex.Lock()
 	defer s.submissionsMutex.Unlock()
+	if err := s.wal.Append(submission); err != nil {
+		log.Printf("Failed to append submission to WAL: %v", err)
+	}
 	s.submissions = append(s.submissions, submission)
 	s.submissionsPerUser[submission.Submitter]++
 	s.submissionsPerProfile[profile]++
+	s.metrics.SubmissionsTotal.WithLabelValues(profile, string(submission.Mode), string(submission.Type), "success").Inc()
 	msg := fmt.Sprintf("Accepted submission from %s (%d/%d); profile %s (%d)",
 		submission.Submitter, s.submissionsPerUser[submission.Submitter], NumSubmissionsPerUser,
 		profile, s.submissionsPerProfile[profile])
@@ -140,62 +170,68 @@ func (s *ProgramsServer) doSubmit(submission shared.Submission) OperationResult
 	return OperationResult{Status: "success", Message: "Accepted submission"}
 }
 
-// getBFilePath returns the path to a b-file for the given sequence ID.
-// The ID should be in format "A<6digits>" (e.g., "A000045").
-func (s *ProgramsServer) getBFilePath(id string) (string, error) {
-	if len(id) != BFileIDLength || id[0] != BFileIDPrefix {
-		return "", fmt.Errorf("invalid sequence ID format: %s", id)
-	}
-	numericId := id[1:] // e.g., "000045"
-	dir := filepath.Join(s.dataDir, "seqs", "oeis", "b", numericId[0:BFileDirPrefixLen])
-	filename := fmt.Sprintf("b%s.txt.gz", numericId)
-	return filepath.Join(dir, filename), nil
-}
-
-// removeBFile removes a b-file and returns an OperationResult.
-// B-files are protected for 24 hours after removal.
+// removeBFile removes a b-file (moving it to the trash) and returns an
+// OperationResult. See the bfile package for the persisted protection window,
+// quotas, and audit logging this now goes through.
 func (s *ProgramsServer) removeBFile(submission shared.Submission) OperationResult {
 	idStr := submission.Id.String()
-
-	// Check 24h protection
-	s.bfileRemovalsMutex.Lock()
-	if lastRemoval, exists := s.bfileRemovals[idStr]; exists {
-		if time.Since(lastRemoval) < BFileProtectionDuration {
-			s.bfileRemovalsMutex.Unlock()
-			remaining := BFileProtectionDuration - time.Since(lastRemoval)
-			protectionMsg := fmt.Sprintf("B-file is protected for %.0f more hours", remaining.Hours())
-			log.Printf("%s: %s", protectionMsg, idStr)
-			return OperationResult{Status: "error", Message: protectionMsg}
-		}
+	msg, err := s.bfiles.Remove(submission.Submitter, idStr)
+	if err != nil {
+		log.Printf("Failed to remove b-file %s: %v", idStr, err)
+		return OperationResult{Status: "error", Message: err.Error()}
 	}
-	s.bfileRemovalsMutex.Unlock()
+	log.Printf("Removed b-file %s by %s", idStr, submission.Submitter)
+	s.metrics.BFileRemovalsTotal.Inc()
+	return OperationResult{Status: "success", Message: msg}
+}
 
-	// Get the b-file path
-	bfilePath, err := s.getBFilePath(idStr)
+// restoreBFile undoes a prior b-file removal within its protection window.
+func (s *ProgramsServer) restoreBFile(submission shared.Submission) OperationResult {
+	idStr := submission.Id.String()
+	msg, err := s.bfiles.Restore(submission.Submitter, idStr)
 	if err != nil {
-		log.Printf("Invalid b-file ID: %v", err)
-		return OperationResult{Status: "error", Message: "Invalid b-file ID"}
+		log.Printf("Failed to restore b-file %s: %v", idStr, err)
+		return OperationResult{Status: "error", Message: err.Error()}
 	}
+	log.Printf("Restored b-file %s by %s", idStr, submission.Submitter)
+	return OperationResult{Status: "success", Message: msg}
+}
 
-	// Check if the file exists
-	if !util.FileExists(bfilePath) {
-		log.Printf("B-file does not exist: %s", bfilePath)
-		return OperationResult{Status: "error", Message: "B-file does not exist"}
+// newBFileByIdHandler returns metadata about a b-file: size, mtime, term
+// count, and last modifier.
+func newBFileByIdHandler(s *ProgramsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		id := mux.Vars(req)["id"]
+		meta, err := s.bfiles.Metadata(id)
+		if err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		util.WriteJsonResponse(w, meta)
 	}
+	return http.HandlerFunc(f)
+}
 
-	// Remove the file
-	if err := os.Remove(bfilePath); err != nil {
-		log.Printf("Failed to remove b-file %s: %v", bfilePath, err)
-		return OperationResult{Status: "error", Message: "Failed to remove b-file"}
+// newBFileHistoryHandler returns the audit log entries for a b-file.
+func newBFileHistoryHandler(s *ProgramsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		id := mux.Vars(req)["id"]
+		history, err := s.bfiles.History(id)
+		if err != nil {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		util.WriteJsonResponse(w, history)
 	}
-
-	// Record the removal time for 24h protection
-	s.bfileRemovalsMutex.Lock()
-	s.bfileRemovals[idStr] = time.Now()
-	s.bfileRemovalsMutex.Unlock()
-
-	log.Printf("Removed b-file %s by %s", idStr, submission.Submitter)
-	return OperationResult{Status: "success", Message: "B-file removed"}
+	return http.HandlerFunc(f)
 }
 
 func newPostHandler(s *ProgramsServer) http.Handler {
@@ -277,7 +313,7 @@ func newProgramByIdHandler(s *ProgramsServer) http.Handler {
 			return
 		}
 		idx := s.getDataIndex()
-		p := shared.FindProgramById(idx.Programs, uid)
+		p := shared.FindProgramById(idx, uid)
 		if p == nil {
 			log.Printf("Program ID not found: %v", uid.String())
 			w.WriteHeader(http.StatusNotFound)
@@ -372,6 +408,28 @@ func logProgramAction(action string, p *shared.Program) {
 	log.Print(msg)
 }
 
+// evalContext derives a context for an evaluation-style request from
+// req.Context(), applying an optional "?timeout=<duration>" override capped
+// at MaxEvalTimeout. It returns the context, its cancel func, and false if
+// the timeout parameter was malformed (in which case a Bad Request response
+// has already been written).
+func evalContext(w http.ResponseWriter, req *http.Request) (context.Context, context.CancelFunc, bool) {
+	timeout := DefaultEvalTimeout
+	if t := req.URL.Query().Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			util.WriteHttpBadRequest(w)
+			return nil, nil, false
+		}
+		timeout = d
+	}
+	if timeout > MaxEvalTimeout {
+		timeout = MaxEvalTimeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return ctx, cancel, true
+}
+
 func newProgramEvalHandler(s *ProgramsServer) http.Handler {
 	f := func(w http.ResponseWriter, req *http.Request) {
 		p, ok := readProgramFromBody(w, req)
@@ -396,10 +454,17 @@ func newProgramEvalHandler(s *ProgramsServer) http.Handler {
 				return
 			}
 		}
+		ctx, cancel, ok := evalContext(w, req)
+		if !ok {
+			return
+		}
+		defer cancel()
 		logProgramAction("Evaluating", &p)
 
 		// Call LODA tool and get result object
-		result := s.lodaTool.Eval(p, numTerms)
+		evalStart := time.Now()
+		result := s.lodaTool.Eval(ctx, p, numTerms)
+		s.metrics.EvalDuration.Observe(time.Since(evalStart).Seconds())
 		if result.Status == "error" {
 			log.Printf("Evaluation failed: %v", result.Message)
 		}
@@ -408,6 +473,92 @@ func newProgramEvalHandler(s *ProgramsServer) http.Handler {
 	return http.HandlerFunc(f)
 }
 
+// StreamProgressInterval is how many terms are computed between "progress"
+// SSE frames on /v2/programs/eval/stream.
+const StreamProgressInterval = 10
+
+func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to marshal SSE event %s: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	flusher.Flush()
+}
+
+// newProgramEvalStreamHandler streams computed terms as Server-Sent Events,
+// so clients evaluating expensive programs get incremental progress instead
+// of waiting for the full batch.
+func newProgramEvalStreamHandler(s *ProgramsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		p, ok := readProgramFromBody(w, req)
+		if !ok {
+			return
+		}
+		numTerms := 8
+		if t := req.URL.Query().Get("t"); t != "" {
+			if v, err := strconv.Atoi(t); err == nil && v > 0 && v <= 10000 {
+				numTerms = v
+			} else {
+				util.WriteHttpBadRequest(w)
+				return
+			}
+		}
+		if o := req.URL.Query().Get("o"); o != "" {
+			if v, err := strconv.Atoi(o); err == nil {
+				p.SetOffset(v)
+			} else {
+				util.WriteHttpBadRequest(w)
+				return
+			}
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		ctx, cancel, ok := evalContext(w, req)
+		if !ok {
+			return
+		}
+		defer cancel()
+		logProgramAction("Streaming evaluation of", &p)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		termEvents, errc := s.lodaTool.EvalStream(ctx, p, numTerms)
+		total := 0
+	loop:
+		for {
+			select {
+			case event, ok := <-termEvents:
+				if !ok {
+					break loop
+				}
+				writeSSEEvent(w, flusher, "term", event)
+				total++
+				if total%StreamProgressInterval == 0 {
+					writeSSEEvent(w, flusher, "progress", map[string]interface{}{"computed": total, "requested": numTerms})
+				}
+			case <-req.Context().Done():
+				break loop
+			}
+		}
+		if err := <-errc; err != nil {
+			log.Printf("Streaming evaluation failed: %v", err)
+			writeSSEEvent(w, flusher, "error", map[string]string{"message": err.Error()})
+			return
+		}
+		writeSSEEvent(w, flusher, "done", map[string]interface{}{"total": total})
+	}
+	return http.HandlerFunc(f)
+}
+
 func newProgramExportHandler(s *ProgramsServer) http.Handler {
 	f := func(w http.ResponseWriter, req *http.Request) {
 		p, ok := readProgramFromBody(w, req)
@@ -419,10 +570,15 @@ func newProgramExportHandler(s *ProgramsServer) http.Handler {
 		if format == "" {
 			format = "loda"
 		}
+		ctx, cancel, ok := evalContext(w, req)
+		if !ok {
+			return
+		}
+		defer cancel()
 		logProgramAction("Exporting", &p)
 
 		// Call LODA tool and get result object
-		result := s.lodaTool.Export(p, format)
+		result := s.lodaTool.Export(ctx, p, format)
 		if result.Status == "error" {
 			log.Printf("Export failed: %v", result.Message)
 		}
@@ -431,19 +587,21 @@ func newProgramExportHandler(s *ProgramsServer) http.Handler {
 	return http.HandlerFunc(f)
 }
 
+// writeCheckpoint compacts the submission WAL into submissions.snap plus a
+// fresh empty WAL, via atomic rename. Unlike the old approach of
+// re-serializing s.submissions under submissionsMutex on every tick, the
+// mutex is only held long enough to snapshot the slice; the (potentially
+// large) JSON encode and rename happen outside it.
 func (s *ProgramsServer) writeCheckpoint() error {
+	start := time.Now()
 	s.submissionsMutex.Lock()
-	defer s.submissionsMutex.Unlock()
-	f, err := os.Create(filepath.Join(s.dataDir, CheckpointFile))
-	if err != nil {
-		return fmt.Errorf("cannot open checkpoint file: %v", err)
-	}
-	defer f.Close()
-	encoder := json.NewEncoder(f)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(s.submissions); err != nil {
-		return fmt.Errorf("cannot write to checkpoint file: %v", err)
+	submissions := make([]shared.Submission, len(s.submissions))
+	copy(submissions, s.submissions)
+	s.submissionsMutex.Unlock()
+	if err := s.wal.Compact(submissions); err != nil {
+		return fmt.Errorf("cannot compact submissions WAL: %v", err)
 	}
+	s.metrics.CheckpointWriteDuration.Observe(time.Since(start).Seconds())
 	return nil
 }
 
@@ -463,16 +621,40 @@ func (s *ProgramsServer) checkSession() {
 	}
 }
 
+// publishMetrics mirrors the current Prometheus counters into InfluxDB, if
+// configured. It is a no-op if InfluxDB is not configured, so removing that
+// config still leaves full observability via the /metrics endpoint. Unlike
+// the old fire-and-forget push, it reads from the same registry /metrics
+// scrapes, so an unreachable InfluxDB can no longer lose data.
 func (s *ProgramsServer) publishMetrics() {
-	s.submissionsMutex.Lock()
-	defer s.submissionsMutex.Unlock()
-	totalCount := 0
-	for profile, count := range s.submissionsPerProfile {
-		labels := map[string]string{"kind": "submitted", "profile": profile}
-		s.influxDbClient.Write("programs", labels, count)
-		totalCount += count
+	if s.influxDbClient == nil {
+		return
+	}
+	metricFamilies, err := s.metrics.Registry.Gather()
+	if err != nil {
+		log.Printf("Failed to gather metrics for InfluxDB: %v", err)
+		return
+	}
+	for _, mf := range metricFamilies {
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			var value float64
+			switch {
+			case metric.Counter != nil:
+				value = metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				value = metric.Gauge.GetValue()
+			case metric.Histogram != nil:
+				value = metric.Histogram.GetSampleSum()
+			default:
+				continue
+			}
+			s.influxDbClient.Write(mf.GetName(), labels, int(value))
+		}
 	}
-	s.submissionsPerProfile = make(map[string]int)
 }
 
 func (s *ProgramsServer) clearUserStats() {
@@ -509,6 +691,7 @@ func (s *ProgramsServer) resetDataIndex() {
 	s.dataIndexMutex.Lock()
 	s.dataIndex = nil
 	s.dataIndexMutex.Unlock()
+	s.metrics.DataIndexLoaded.Set(0)
 	runtime.GC()
 }
 
@@ -523,12 +706,33 @@ func (s *ProgramsServer) getDataIndex() *shared.DataIndex {
 			log.Fatalf("Failed to load data index: %v", err)
 		}
 		s.dataIndex = idx
+		s.metrics.DataIndexLoaded.Set(1)
 		runtime.GC()
 	}
 	return s.dataIndex
 }
 
+// Index returns the server's DataIndex, lazily building and caching it. It
+// implements api/v2's IndexProvider, e.g. for the OpenSearch suggestions
+// handler.
+func (s *ProgramsServer) Index() *shared.DataIndex {
+	return s.getDataIndex()
+}
+
+// loadCheckpoint replays submissions.snap plus submissions.wal. If neither
+// exists yet, it falls back to the pre-WAL checkpoint.json format, and from
+// there to the legacy checkpoint.txt format, so upgrades migrate cleanly.
 func (s *ProgramsServer) loadCheckpoint() {
+	submissions, err := s.wal.Load()
+	if err == nil && len(submissions) > 0 {
+		s.submissions = submissions
+		log.Printf("Loaded %v submissions from WAL", len(s.submissions))
+		return
+	}
+	if err != nil {
+		log.Printf("Cannot load submissions WAL: %v, trying legacy formats", err)
+	}
+
 	checkpointPath := filepath.Join(s.dataDir, CheckpointFile)
 	file, err := os.Open(checkpointPath)
 	if err != nil {
@@ -587,13 +791,25 @@ func newV2SubmissionsGetHandler(s *ProgramsServer) http.Handler {
 			util.WriteHttpMethodNotAllowed(w)
 			return
 		}
-		limit, skip, _ := util.ParseLimitSkipShuffle(req, 10, 100)
+		limit, skip, _, orderBy := util.ParseListParams(req, 10, 100, shared.SubmissionOrderByRegistry)
 
 		// Get filter parameters
 		modeFilter := req.URL.Query().Get("mode")
 		typeFilter := req.URL.Query().Get("type")
 		submitterFilter := req.URL.Query().Get("submitter")
 
+		// The "filter" parameter additionally accepts a glob pattern of the
+		// form "<seqId>/<ops>/<submitter>", e.g. "A00004?/mov,add/alice".
+		var submissionFilter *shared.Filter
+		if pattern := req.URL.Query().Get("filter"); pattern != "" {
+			f, err := shared.NewSubmissionFilter(pattern)
+			if err != nil {
+				util.WriteHttpBadRequest(w)
+				return
+			}
+			submissionFilter = f
+		}
+
 		s.submissionsMutex.Lock()
 		defer s.submissionsMutex.Unlock()
 
@@ -612,9 +828,19 @@ func newV2SubmissionsGetHandler(s *ProgramsServer) http.Handler {
 			if submitterFilter != "" && sub.Submitter != submitterFilter {
 				continue
 			}
+			// Filter by the seqId/ops/submitter glob pattern if specified
+			if submissionFilter != nil && !submissionFilter.Match(sub) {
+				continue
+			}
 			filtered = append(filtered, sub)
 		}
 
+		if orderBy != nil {
+			sort.SliceStable(filtered, func(i, j int) bool {
+				return orderBy(filtered[i], filtered[j]) < 0
+			})
+		}
+
 		total := len(filtered)
 		results := []shared.Submission{}
 
@@ -632,6 +858,11 @@ func newV2SubmissionsGetHandler(s *ProgramsServer) http.Handler {
 			results = filtered[start:end]
 		}
 
+		if req.URL.Query().Get("format") == "ndjson" {
+			util.WriteNDJSONStream(w, req, util.SliceSeq(results))
+			return
+		}
+
 		resp := shared.SubmissionsResult{
 			Session: s.session.Unix(),
 			Total:   total,
@@ -688,8 +919,14 @@ func newV2SubmissionsPostHandler(s *ProgramsServer) http.Handler {
 			res := s.doSubmit(submission)
 			util.WriteJsonResponse(w, res)
 		case shared.TypeBFile:
-			// Only remove mode is allowed for b-files (already validated in UnmarshalJSON)
-			res := s.removeBFile(submission)
+			// Only remove and restore modes are allowed for b-files (already
+			// validated in UnmarshalJSON)
+			var res OperationResult
+			if submission.Mode == shared.ModeRestore {
+				res = s.restoreBFile(submission)
+			} else {
+				res = s.removeBFile(submission)
+			}
 			util.WriteJsonResponse(w, res)
 		default:
 			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Unsupported submission type"})
@@ -706,6 +943,10 @@ func (s *ProgramsServer) Run(port int) {
 	}
 
 	s.loadCheckpoint()
+	if err := s.bfiles.Load(); err != nil {
+		log.Printf("Failed to load b-file removal state: %v", err)
+	}
+	s.metrics.SessionStartTimestamp.Set(float64(s.session.Unix()))
 
 	// schedule background tasks
 	checkpointTicker := time.NewTicker(CheckpointInterval)
@@ -714,6 +955,7 @@ func (s *ProgramsServer) Run(port int) {
 		for range checkpointTicker.C {
 			s.publishMetrics()
 			s.clearUserStats()
+			s.bfiles.ClearUserQuotas()
 			s.writeCheckpoint()
 		}
 	}()
@@ -734,21 +976,72 @@ func (s *ProgramsServer) Run(port int) {
 	router.Handle("/v1/programs/", postHandler)
 	router.Handle("/v1/programs/{index:[0-9]+}", newGetHandler(s))
 	router.Handle("/v1/checkpoint", newCheckpointHandler(s))
+	// /v1/checkpoint/compact is an alias for /v1/checkpoint: writeCheckpoint
+	// now compacts the submission WAL into a snapshot, so both routes trigger
+	// the same operation.
+	router.Handle("/v1/checkpoint/compact", newCheckpointHandler(s))
 	router.Handle("/v2/programs/{id:[A-Z][0-9]+}", newProgramByIdHandler(s))
 	router.Handle("/v2/programs/search", newProgramSearchHandler(s))
+	router.Handle("/v2/programs/suggest", v2.NewProgramSuggestHandler(s))
+	router.Handle("/opensearch.xml", v2.NewOpenSearchHandler(
+		"LODA Programs",
+		"Search the LODA program database",
+		"https://loda-lang.org/programs/?q={searchTerms}",
+		"https://api.loda-lang.org/v2/programs/suggest?q={searchTerms}",
+	))
 	router.Handle("/v2/programs/eval", newProgramEvalHandler(s))
+	router.Handle("/v2/programs/eval/stream", newProgramEvalStreamHandler(s))
 	router.Handle("/v2/programs/export", newProgramExportHandler(s))
 	router.Handle("/v2/submissions", newV2SubmissionsGetHandler(s)).Methods(http.MethodGet)
 	router.Handle("/v2/submissions", newV2SubmissionsPostHandler(s)).Methods(http.MethodPost)
+	router.Handle("/v2/bfiles/{id:[A-Z][0-9]+}", newBFileByIdHandler(s))
+	router.Handle("/v2/bfiles/{id:[A-Z][0-9]+}/history", newBFileHistoryHandler(s))
+	router.Handle("/metrics", promhttp.HandlerFor(s.metrics.Registry, promhttp.HandlerOpts{}))
 	router.NotFoundHandler = http.HandlerFunc(util.HandleNotFound)
 	log.Printf("Listening on port %d", port)
 	http.ListenAndServe(fmt.Sprintf(":%d", port), util.CORSHandler(router))
 }
 
+var (
+	replayFlag = flag.Bool("replay", false, "replay the on-disk checkpoint offline, print matching submissions, and exit instead of starting the server")
+	filterFlag = flag.String("filter", "", "seqId/ops/submitter glob pattern (see shared.NewSubmissionFilter) restricting which submissions -replay prints")
+)
+
+// replayCheckpoint loads the on-disk checkpoint/WAL without starting the
+// server, optionally restricts it to submissions matching pattern, and
+// prints one line per matching submission for offline inspection.
+func replayCheckpoint(dataDir string, pattern string) {
+	s := NewProgramsServer(dataDir, nil, nil)
+	s.loadCheckpoint()
+	var filter *shared.Filter
+	if pattern != "" {
+		f, err := shared.NewSubmissionFilter(pattern)
+		if err != nil {
+			log.Fatalf("Invalid filter: %v", err)
+		}
+		filter = f
+	}
+	for _, sub := range s.submissions {
+		if filter != nil && !filter.Match(sub) {
+			continue
+		}
+		fmt.Printf("%s %s %s %s\n", sub.Id.String(), sub.Mode, sub.Type, sub.Submitter)
+	}
+}
+
 func main() {
+	flag.Parse()
+	os.Args = append(os.Args[:1], flag.Args()...)
 	setup := cmd.GetSetup("programs")
-	u, p := util.ParseAuthInfo(setup.InfluxDbAuth)
-	i := util.NewInfluxDbClient(setup.InfluxDbHost, u, p)
+	if err := setup.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if *replayFlag {
+		replayCheckpoint(setup.DataDir, *filterFlag)
+		return
+	}
+	u, p := util.ParseAuthInfo(setup.InfluxDB.Auth)
+	i := util.NewInfluxDbClient(setup.InfluxDB.Host, u, p)
 	t := NewLODATool(setup.DataDir, MaxNumParallelEval)
 	s := NewProgramsServer(setup.DataDir, i, t)
 	s.Run(8081)
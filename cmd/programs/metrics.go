@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors published by a ProgramsServer. It
+// is pull-based: operators scrape /metrics directly instead of depending on
+// the fire-and-forget InfluxDB pushes the old publishMetrics made, so a
+// down or misconfigured InfluxDB no longer loses data.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	SubmissionsTotal        *prometheus.CounterVec
+	SubmissionsInflight     prometheus.Gauge
+	EvalDuration            prometheus.Histogram
+	BFileRemovalsTotal      prometheus.Counter
+	CheckpointWriteDuration prometheus.Histogram
+	DataIndexLoaded         prometheus.Gauge
+	SessionStartTimestamp   prometheus.Gauge
+}
+
+// NewMetrics creates and registers the Prometheus collectors used by a
+// ProgramsServer.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		SubmissionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_submissions_total",
+			Help: "Total number of submissions, by miner profile, mode, type and outcome.",
+		}, []string{"profile", "mode", "type", "status"}),
+		SubmissionsInflight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_submissions_inflight",
+			Help: "Number of submissions currently being validated and applied.",
+		}),
+		EvalDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "loda_eval_duration_seconds",
+			Help:    "Duration of LODATool.Eval calls.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		BFileRemovalsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loda_bfile_removals_total",
+			Help: "Total number of b-file removals.",
+		}),
+		CheckpointWriteDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "loda_checkpoint_write_duration_seconds",
+			Help:    "Duration of submission checkpoint writes.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		DataIndexLoaded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_data_index_loaded",
+			Help: "Whether the OEIS data index is currently loaded in memory (1) or not (0).",
+		}),
+		SessionStartTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_session_start_timestamp_seconds",
+			Help: "Unix timestamp of the current submissions session start.",
+		}),
+	}
+	m.Registry.MustRegister(
+		m.SubmissionsTotal,
+		m.SubmissionsInflight,
+		m.EvalDuration,
+		m.BFileRemovalsTotal,
+		m.CheckpointWriteDuration,
+		m.DataIndexLoaded,
+		m.SessionStartTimestamp,
+	)
+	return m
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCacheMaxEntries and defaultCacheTTL are the result cache defaults
+// used when NewLODATool is called without WithCache.
+const (
+	defaultCacheMaxEntries = 10000
+	defaultCacheTTL        = 24 * time.Hour
+)
+
+// resultCacheEntry is the on-disk JSON representation of one cached Eval or
+// Export result, stored under dataDir/cache/{eval,export}/<hash>.json.
+type resultCacheEntry struct {
+	LodaVersion string    `json:"loda_version"`
+	CreatedAt   time.Time `json:"created_at"`
+	Status      string    `json:"status"`
+	Message     string    `json:"message,omitempty"`
+	Terms       []string  `json:"terms,omitempty"`
+	Output      string    `json:"output,omitempty"`
+}
+
+// CacheStats reports Prometheus-style counters (hits and misses only ever
+// increase; evictions only ever increase) for one result cache, as returned
+// by LODATool.Stats().
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// resultCache is a persistent, content-addressed cache of loda results,
+// backed by one JSON file per entry under dir. LRU order is tracked only for
+// entries touched since this process started -- a fresh process starts with
+// a cold in-memory index even though the entry files themselves survive a
+// restart, so get() always falls back to reading the file from disk.
+type resultCache struct {
+	dir        string
+	maxEntries int
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	index map[string]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+// newResultCache creates the cache directory dataDir/cache/kind (if missing)
+// and returns a resultCache backed by it.
+func newResultCache(dataDir, kind string, maxEntries int, ttl time.Duration) *resultCache {
+	dir := filepath.Join(dataDir, "cache", kind)
+	os.MkdirAll(dir, 0755)
+	return &resultCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		index:      map[string]*list.Element{},
+	}
+}
+
+// cacheKey builds the content-addressed key for a cache lookup: the program
+// code's hash plus the params that affect its result (numTerms for Eval,
+// format for Export).
+func cacheKey(code, params string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:]) + "|" + params
+}
+
+// get looks up key, returning (entry, true) only if a fresh, current-version
+// entry exists. A stale (wrong loda version) or expired entry counts as a
+// miss and is removed so it doesn't keep costing a failed disk read.
+func (c *resultCache) get(key, lodaVersion string) (resultCacheEntry, bool) {
+	data, err := os.ReadFile(c.filePath(key))
+	if err != nil {
+		atomic.AddUint64(&c.misses, 1)
+		return resultCacheEntry{}, false
+	}
+	var entry resultCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		atomic.AddUint64(&c.misses, 1)
+		c.remove(key)
+		return resultCacheEntry{}, false
+	}
+	if entry.LodaVersion != lodaVersion {
+		atomic.AddUint64(&c.misses, 1)
+		c.remove(key)
+		return resultCacheEntry{}, false
+	}
+	if c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl {
+		atomic.AddUint64(&c.misses, 1)
+		c.remove(key)
+		return resultCacheEntry{}, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.touch(key)
+	return entry, true
+}
+
+// put writes entry to disk and records key as the most recently used,
+// evicting the least recently used entries past maxEntries.
+func (c *resultCache) put(key string, entry resultCacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	path := c.filePath(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	c.touch(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		oldestKey := oldest.Value.(string)
+		c.order.Remove(oldest)
+		delete(c.index, oldestKey)
+		os.Remove(c.filePath(oldestKey))
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// touch marks key as the most recently used entry in the in-memory LRU
+// index, adding it if this is the first time this process has seen it.
+func (c *resultCache) touch(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.index[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.index[key] = c.order.PushFront(key)
+}
+
+// remove deletes key's entry file and drops it from the in-memory index.
+func (c *resultCache) remove(key string) {
+	os.Remove(c.filePath(key))
+	c.mu.Lock()
+	if el, ok := c.index[key]; ok {
+		c.order.Remove(el)
+		delete(c.index, key)
+	}
+	c.mu.Unlock()
+}
+
+// filePath returns the on-disk path for key, named after its own hash so
+// that a key containing arbitrary params (e.g. an export format) still maps
+// to a filesystem-safe file name.
+func (c *resultCache) filePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// stats returns a snapshot of the cache's hit/miss/eviction counters.
+func (c *resultCache) stats() CacheStats {
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+	}
+}
@@ -186,6 +186,48 @@ func TestV2SubmissionsGetHandler_Pagination(t *testing.T) {
 	assert.Equal(t, 5, len(result.Results))
 }
 
+// TestV2SubmissionsGetHandler_OrderBy tests the order= query parameter
+func TestV2SubmissionsGetHandler_OrderBy(t *testing.T) {
+	id1, _ := util.NewUIDFromString("A000045")
+	id2, _ := util.NewUIDFromString("A000142")
+
+	s := &ProgramsServer{
+		submissions: []shared.Submission{
+			{
+				Id:        id1,
+				Submitter: "bob",
+				Content:   "mov $0,1",
+				Mode:      shared.ModeAdd,
+				Type:      shared.TypeProgram,
+			},
+			{
+				Id:        id2,
+				Submitter: "alice",
+				Content:   "mul $0,2",
+				Mode:      shared.ModeUpdate,
+				Type:      shared.TypeProgram,
+			},
+		},
+		submissionsPerProfile: make(map[string]int),
+		submissionsPerUser:    make(map[string]int),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/submissions?order=submitter", nil)
+	w := httptest.NewRecorder()
+
+	handler := newV2SubmissionsGetHandler(s)
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result shared.SubmissionsResult
+	err := json.NewDecoder(w.Body).Decode(&result)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result.Results))
+	assert.Equal(t, "alice", result.Results[0].Submitter)
+	assert.Equal(t, "bob", result.Results[1].Submitter)
+}
+
 // TestV2SubmissionsPostHandler_MissingFields tests validation
 func TestV2SubmissionsPostHandler_MissingFields(t *testing.T) {
 	s := &ProgramsServer{
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/loda-lang/loda-api/shared"
@@ -19,7 +20,24 @@ import (
 )
 
 // SupportedExportFormats defines the export formats supported by the LODA tool
-var SupportedExportFormats = []string{"formula", "pari", "loda", "range"}
+var SupportedExportFormats = []string{"formula", "pari", "loda", "range", "pari-native", "mathematica", "sympy", "latex"}
+
+// formulaExportFormats maps the export formats that are rendered in-process
+// from program.Formula via a shared.FormulaPrinter, instead of being handed
+// to the loda binary. "pari-native" is distinct from "pari" (which asks loda
+// itself to export PARI/GP code): it renders the already-extracted Formula
+// through shared.ExprToPari, including idioms like PARI's "\" integer-division
+// operator that loda's own exporter doesn't produce.
+var formulaExportFormats = map[string]shared.FormulaPrinter{
+	"pari-native": shared.PariPrinter{},
+	"mathematica": shared.MathematicaPrinter{},
+	"sympy":       shared.SymPyPrinter{},
+	"latex":       shared.LatexPrinter{},
+}
+
+// TerminationGracePeriod is how long a subprocess is given to exit after
+// SIGTERM before it is forcibly killed with SIGKILL.
+const TerminationGracePeriod = 2 * time.Second
 
 type EvalResult struct {
 	Status  string   `json:"status"`
@@ -33,17 +51,89 @@ type ExportResult struct {
 	Output  string `json:"output"`
 }
 
+// TermEvent describes a single term computed by a streaming evaluation.
+type TermEvent struct {
+	Index   int    `json:"index"`
+	Value   string `json:"value"`
+	Elapsed int64  `json:"elapsedMs"`
+}
+
 type LODATool struct {
-	dataDir string
-	evalSem chan struct{}
+	dataDir            string
+	evalSem            chan struct{}
+	maxNumParallelEval int
+
+	// pool is a persistent loda worker pool, started by Install once the
+	// loda binary is in place. It stays nil -- and Exec/Eval/Export keep
+	// using evalSem and fork+exec -- when the installed binary predates
+	// the pool protocol (see initWorkerPool).
+	pool *workerPool
+
+	// evalCache and exportCache short-circuit Eval and Export for a program
+	// and params combination (numTerms or format) that was already computed
+	// with the currently installed loda binary. See result_cache.go.
+	evalCache   *resultCache
+	exportCache *resultCache
+
+	versionMu   sync.RWMutex
+	lodaVersion string
+}
+
+// LODAToolOption configures optional LODATool behavior, currently limited to
+// sizing the result cache; see WithCache.
+type LODAToolOption func(*LODATool)
+
+// WithCache overrides the result cache's default size and TTL. A maxEntries
+// of 0 disables the entry cap and a ttl of 0 disables expiry, in both cases
+// relying solely on loda-version invalidation.
+func WithCache(maxEntries int, ttl time.Duration) LODAToolOption {
+	return func(t *LODATool) {
+		t.evalCache.maxEntries = maxEntries
+		t.evalCache.ttl = ttl
+		t.exportCache.maxEntries = maxEntries
+		t.exportCache.ttl = ttl
+	}
 }
 
-func NewLODATool(dataDir string, maxNumParallelEval int) *LODATool {
+func NewLODATool(dataDir string, maxNumParallelEval int, opts ...LODAToolOption) *LODATool {
 	evalSem := make(chan struct{}, maxNumParallelEval)
-	return &LODATool{
-		dataDir: dataDir,
-		evalSem: evalSem,
+	t := &LODATool{
+		dataDir:            dataDir,
+		evalSem:            evalSem,
+		maxNumParallelEval: maxNumParallelEval,
+		evalCache:          newResultCache(dataDir, "eval", defaultCacheMaxEntries, defaultCacheTTL),
+		exportCache:        newResultCache(dataDir, "export", defaultCacheMaxEntries, defaultCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
+}
+
+// currentLodaVersion returns the fingerprint Install last recorded for the
+// installed loda binary, used to key cache entries so an upgrade invalidates
+// every result cached under the old binary.
+func (t *LODATool) currentLodaVersion() string {
+	t.versionMu.RLock()
+	defer t.versionMu.RUnlock()
+	return t.lodaVersion
+}
+
+func (t *LODATool) setLodaVersion(v string) {
+	t.versionMu.Lock()
+	t.lodaVersion = v
+	t.versionMu.Unlock()
+}
+
+// Stats reports hit/miss/eviction counters for the Eval and Export result
+// caches, so operators can size the cache via WithCache.
+type ToolStats struct {
+	Eval   CacheStats `json:"eval"`
+	Export CacheStats `json:"export"`
+}
+
+func (t *LODATool) Stats() ToolStats {
+	return ToolStats{Eval: t.evalCache.stats(), Export: t.exportCache.stats()}
 }
 
 func (t *LODATool) Install() error {
@@ -101,10 +191,49 @@ func (t *LODATool) Install() error {
 			return fmt.Errorf("failed to clone loda-programs: %w", err)
 		}
 	}
+	if version, err := fingerprintLodaBinary(lodaExec); err != nil {
+		log.Printf("failed to fingerprint loda binary, result cache disabled until next Install: %v", err)
+	} else {
+		t.setLodaVersion(version)
+	}
+	t.initWorkerPool(lodaExec)
 	return nil
 }
 
-// Exec runs the loda command. If timeout > 0, enforces a timeout. Accepts args as variadic.
+// fingerprintLodaBinary returns a cheap fingerprint of the loda executable's
+// modification time and size, used as the cache version: whenever Install's
+// upgrade step replaces the binary with a new one, the fingerprint changes
+// and every previously cached result is treated as stale.
+func fingerprintLodaBinary(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// initWorkerPool starts a pool of maxNumParallelEval persistent loda worker
+// subprocesses (see worker_pool.go) once the loda binary is in place, so
+// that Exec/Eval/Export can submit jobs to long-lived workers instead of
+// fork+exec'ing a fresh loda process per request. If the installed binary
+// doesn't understand the pool protocol, t.pool stays nil and every call
+// keeps using today's one-shot exec path; this is logged once here rather
+// than failing Install, since an older loda binary is a supported
+// configuration, not an error.
+func (t *LODATool) initWorkerPool(lodaExec string) {
+	pool, err := newWorkerPool(lodaExec, t.dataDir, t.maxNumParallelEval)
+	if err != nil {
+		log.Printf("loda binary does not support the persistent worker pool, falling back to one-shot exec per request: %v", err)
+		return
+	}
+	t.pool = pool
+}
+
+// Exec runs the loda command. If timeout > 0, enforces a timeout. Accepts
+// args as variadic. Unlike Eval and Export, Exec is intentionally left on
+// the one-shot exec path rather than routed through the worker pool: its
+// only caller is Install's "upgrade" self-maintenance check, which has no
+// associated program and runs once at startup, not per request.
 func (t *LODATool) Exec(timeout time.Duration, args ...string) (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -171,6 +300,86 @@ func (t *LODATool) Exec(timeout time.Duration, args ...string) (string, error) {
 	return outputBuilder.String(), err
 }
 
+// execContext runs the loda command, aborting it when ctx is done. On
+// cancellation or deadline expiry, the subprocess is sent SIGTERM; if it has
+// not exited after TerminationGracePeriod it is killed with SIGKILL.
+func (t *LODATool) execContext(ctx context.Context, args ...string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	lodaExec := filepath.Join(homeDir, "bin", "loda")
+	if !util.FileExists(lodaExec) {
+		return "", fmt.Errorf("loda executable not found at: %s", lodaExec)
+	}
+
+	cmd := exec.Command(lodaExec, args...)
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, "LODA_HOME="+t.dataDir)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start command: %w", err)
+	}
+
+	var outputBuilder strings.Builder
+	var wg sync.WaitGroup
+	stream := func(r io.Reader) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			outputBuilder.WriteString(line + "\n")
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, "|", 2)
+			if len(parts) == 2 {
+				log.Print(parts[1])
+			} else {
+				log.Print(line)
+			}
+		}
+	}
+	wg.Add(2)
+	go func() { defer wg.Done(); stream(stdout) }()
+	go func() { defer wg.Done(); stream(stderr) }()
+
+	// Cancel channel: closed once the process has exited, so the watcher
+	// goroutine below can stop waiting on ctx.Done().
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("Terminating loda process (pid %d): %v", cmd.Process.Pid, ctx.Err())
+			cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-done:
+			case <-time.After(TerminationGracePeriod):
+				cmd.Process.Kill()
+			}
+		case <-done:
+		}
+	}()
+
+	wg.Wait()
+	err = cmd.Wait()
+	close(done)
+
+	if ctx.Err() != nil {
+		return outputBuilder.String(), ctx.Err()
+	}
+	return outputBuilder.String(), err
+}
+
 // writeProgramToTempFile creates a temporary file and writes the program code to it.
 // Returns the file path and a cleanup function. The cleanup function should be called
 // to remove the temporary file when done.
@@ -194,8 +403,57 @@ func (t *LODATool) writeProgramToTempFile(program shared.Program, prefix string)
 }
 
 // Eval evaluates a LODA program and returns a Result with status, message, and terms.
-func (t *LODATool) Eval(program shared.Program, numTerms int) EvalResult {
-	t.evalSem <- struct{}{}
+// The evaluation is bound by ctx: if ctx is cancelled or its deadline expires
+// before the evaluation finishes, Eval returns an EvalResult with Status
+// "timeout" instead of blocking or returning a 500. When t.pool is up, the
+// job runs on a persistent loda worker instead of a freshly forked process;
+// otherwise it falls back to the one-shot exec path. Results are cached by
+// program code and numTerms (see evalCache); a cache hit skips all of the
+// above.
+func (t *LODATool) Eval(ctx context.Context, program shared.Program, numTerms int) EvalResult {
+	version := t.currentLodaVersion()
+	key := cacheKey(program.Code, strconv.Itoa(numTerms))
+	if entry, ok := t.evalCache.get(key, version); ok {
+		return EvalResult{Status: entry.Status, Message: entry.Message, Terms: entry.Terms}
+	}
+	result := t.evalUncached(ctx, program, numTerms)
+	if result.Status == "success" || result.Status == "error" {
+		t.evalCache.put(key, resultCacheEntry{
+			LodaVersion: version,
+			CreatedAt:   time.Now(),
+			Status:      result.Status,
+			Message:     result.Message,
+			Terms:       result.Terms,
+		})
+	}
+	return result
+}
+
+// evalUncached is Eval's original pool-then-exec body, run on every cache miss.
+func (t *LODATool) evalUncached(ctx context.Context, program shared.Program, numTerms int) EvalResult {
+	if t.pool != nil {
+		args := []string{"eval", "-t", strconv.Itoa(numTerms)}
+		resp, err := t.pool.submit(ctx, "eval", program.Code, args)
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return EvalResult{Status: "timeout", Message: "Evaluation timed out"}
+		}
+		if err == nil {
+			var jobErr error
+			if resp.Status != "ok" {
+				jobErr = fmt.Errorf("loda worker reported status %q", resp.Status)
+			}
+			return parseEvalOutput(resp.Output, jobErr)
+		}
+		// The worker that had this job died mid-flight; fall back to the
+		// one-shot path instead of failing the request outright.
+		log.Printf("loda worker pool job failed, falling back to one-shot exec: %v", err)
+	}
+
+	select {
+	case t.evalSem <- struct{}{}:
+	case <-ctx.Done():
+		return EvalResult{Status: "timeout", Message: "Too many parallel evaluations"}
+	}
 	defer func() { <-t.evalSem }()
 	tmpfilePath, cleanup, err := t.writeProgramToTempFile(program, "loda_eval_")
 	if err != nil {
@@ -207,12 +465,24 @@ func (t *LODATool) Eval(program shared.Program, numTerms int) EvalResult {
 	}
 	defer cleanup()
 	args := []string{"eval", tmpfilePath, "-t", strconv.Itoa(numTerms)}
-	output, execErr := t.Exec(10*time.Second, args...)
+	output, execErr := t.execContext(ctx, args...)
+	if execErr == context.DeadlineExceeded || execErr == context.Canceled {
+		return EvalResult{Status: "timeout", Message: "Evaluation timed out"}
+	}
+	return parseEvalOutput(output, execErr)
+}
+
+// parseEvalOutput turns the raw stdout of a loda eval -- whether run as a
+// one-shot subprocess or as a pool job -- into an EvalResult. On success,
+// output is a single line of comma-separated terms. On failure, the first
+// line (if present) is still a best-effort, possibly partial, term list,
+// and the second line is the error message; jobErr is used as the message
+// only when output doesn't carry one.
+func parseEvalOutput(output string, jobErr error) EvalResult {
 	var terms []string
 	status := "success"
 	message := ""
-	if execErr != nil {
-		// If error, check if output has two lines: terms and error message
+	if jobErr != nil {
 		lines := strings.SplitN(output, "\n", 3)
 		if len(lines) >= 2 {
 			terms = strings.Split(lines[0], ",")
@@ -221,11 +491,10 @@ func (t *LODATool) Eval(program shared.Program, numTerms int) EvalResult {
 			}
 			message = strings.TrimSpace(lines[1])
 		} else {
-			message = execErr.Error()
+			message = jobErr.Error()
 		}
 		status = "error"
 	} else {
-		// Success: output is terms (single line)
 		terms = strings.Split(strings.TrimSpace(output), ",")
 		for i := range terms {
 			terms[i] = strings.TrimSpace(terms[i])
@@ -238,11 +507,171 @@ func (t *LODATool) Eval(program shared.Program, numTerms int) EvalResult {
 	}
 }
 
+// EvalFormula is a fast path for Eval: when a closed-form Formula for
+// program is available -- either already extracted into program.Formula or
+// obtained via Export(program, "formula") -- and shared.EvaluateFormula can
+// compute it, the terms are calculated in-process with math/big, skipping
+// the semaphore, temp file and loda subprocess that Eval otherwise requires.
+// It falls back to Eval on any export, parse, or evaluation failure.
+func (t *LODATool) EvalFormula(ctx context.Context, program shared.Program, numTerms int) EvalResult {
+	if ctx.Err() != nil {
+		return EvalResult{Status: "timeout", Message: "Evaluation timed out"}
+	}
+	formulaText := program.Formula
+	if formulaText == "" {
+		exported := t.Export(ctx, program, "formula")
+		if exported.Status != "success" {
+			return t.Eval(ctx, program, numTerms)
+		}
+		formulaText = exported.Output
+	}
+	formula, err := shared.ParseFormulaLine(formulaText)
+	if err != nil || formula == nil {
+		return t.Eval(ctx, program, numTerms)
+	}
+	values, err := shared.EvaluateFormula(formula, numTerms)
+	if err != nil {
+		return t.Eval(ctx, program, numTerms)
+	}
+	terms := make([]string, len(values))
+	for i, v := range values {
+		terms[i] = v.String()
+	}
+	return EvalResult{Status: "success", Terms: terms}
+}
+
+// EvalStream evaluates a LODA program like Eval, but streams one TermEvent per
+// computed term as soon as the loda subprocess emits it (via its "-s" streaming
+// mode, one term per stdout line) rather than waiting for the whole batch. The
+// returned channels are closed once evaluation finishes; at most one error is
+// ever sent on the error channel. Cancelling ctx terminates the subprocess
+// (SIGTERM, then SIGKILL after TerminationGracePeriod) and closes both channels.
+func (t *LODATool) EvalStream(ctx context.Context, program shared.Program, numTerms int) (<-chan TermEvent, <-chan error) {
+	events := make(chan TermEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		select {
+		case t.evalSem <- struct{}{}:
+		case <-ctx.Done():
+			errs <- ctx.Err()
+			return
+		}
+		defer func() { <-t.evalSem }()
+
+		tmpfilePath, cleanup, err := t.writeProgramToTempFile(program, "loda_eval_")
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer cleanup()
+
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			errs <- err
+			return
+		}
+		lodaExec := filepath.Join(homeDir, "bin", "loda")
+		if !util.FileExists(lodaExec) {
+			errs <- fmt.Errorf("loda executable not found at: %s", lodaExec)
+			return
+		}
+
+		args := []string{"eval", tmpfilePath, "-t", strconv.Itoa(numTerms), "-s"}
+		cmd := exec.Command(lodaExec, args...)
+		cmd.Env = os.Environ()
+		cmd.Env = append(cmd.Env, "LODA_HOME="+t.dataDir)
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			errs <- err
+			return
+		}
+		cmd.Stderr = nil
+
+		if err := cmd.Start(); err != nil {
+			errs <- err
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				cmd.Process.Signal(syscall.SIGTERM)
+				select {
+				case <-done:
+				case <-time.After(TerminationGracePeriod):
+					cmd.Process.Kill()
+				}
+			case <-done:
+			}
+		}()
+
+		start := time.Now()
+		scanner := bufio.NewScanner(stdout)
+		index := 0
+		for scanner.Scan() {
+			value := strings.TrimSpace(scanner.Text())
+			if value == "" {
+				continue
+			}
+			event := TermEvent{
+				Index:   index,
+				Value:   value,
+				Elapsed: time.Since(start).Milliseconds(),
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				close(done)
+				errs <- ctx.Err()
+				return
+			}
+			index++
+		}
+
+		close(done)
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			errs <- err
+		} else if ctx.Err() != nil {
+			errs <- ctx.Err()
+		}
+	}()
+
+	return events, errs
+}
+
 // Export exports a LODA program to various formats using the loda export command.
-// Supported formats are defined in SupportedExportFormats variable.
-func (t *LODATool) Export(program shared.Program, format string) ExportResult {
-	t.evalSem <- struct{}{}
-	defer func() { <-t.evalSem }()
+// Supported formats are defined in SupportedExportFormats variable. The export
+// is bound by ctx the same way Eval is. Results are cached by program code and
+// format (see exportCache); a cache hit skips everything below, including
+// format validation.
+func (t *LODATool) Export(ctx context.Context, program shared.Program, format string) ExportResult {
+	version := t.currentLodaVersion()
+	key := cacheKey(program.Code, format)
+	if entry, ok := t.exportCache.get(key, version); ok {
+		return ExportResult{Status: entry.Status, Message: entry.Message, Output: entry.Output}
+	}
+	result := t.exportUncached(ctx, program, format)
+	if result.Status == "success" || result.Status == "error" {
+		t.exportCache.put(key, resultCacheEntry{
+			LodaVersion: version,
+			CreatedAt:   time.Now(),
+			Status:      result.Status,
+			Message:     result.Message,
+			Output:      result.Output,
+		})
+	}
+	return result
+}
+
+// exportUncached is Export's original format-validation, printer, and
+// pool-then-exec body, run on every cache miss.
+func (t *LODATool) exportUncached(ctx context.Context, program shared.Program, format string) ExportResult {
 	// Validate format
 	isValid := false
 	for _, f := range SupportedExportFormats {
@@ -258,6 +687,28 @@ func (t *LODATool) Export(program shared.Program, format string) ExportResult {
 			Output:  "",
 		}
 	}
+
+	if printer, ok := formulaExportFormats[format]; ok {
+		return t.exportViaPrinter(ctx, program, printer)
+	}
+
+	if t.pool != nil {
+		resp, err := t.pool.submit(ctx, "export", program.Code, []string{"export", "-o", format})
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return ExportResult{Status: "timeout", Message: "Export timed out"}
+		}
+		if err == nil {
+			return parseExportOutput(resp.Output, resp.Status != "ok")
+		}
+		log.Printf("loda worker pool job failed, falling back to one-shot exec: %v", err)
+	}
+
+	select {
+	case t.evalSem <- struct{}{}:
+	case <-ctx.Done():
+		return ExportResult{Status: "timeout", Message: "Too many parallel evaluations"}
+	}
+	defer func() { <-t.evalSem }()
 	tmpfilePath, cleanup, err := t.writeProgramToTempFile(program, "loda_export_")
 	if err != nil {
 		return ExportResult{
@@ -268,15 +719,51 @@ func (t *LODATool) Export(program shared.Program, format string) ExportResult {
 	}
 	defer cleanup()
 	args := []string{"export", "-o", format, tmpfilePath}
-	output, execErr := t.Exec(10*time.Second, args...)
+	output, execErr := t.execContext(ctx, args...)
+	if execErr == context.DeadlineExceeded || execErr == context.Canceled {
+		return ExportResult{Status: "timeout", Message: "Export timed out"}
+	}
+	if execErr != nil && strings.TrimSpace(output) == "" {
+		return ExportResult{Status: "error", Message: execErr.Error(), Output: ""}
+	}
+	return parseExportOutput(output, execErr != nil)
+}
+
+// exportViaPrinter renders program through printer, obtaining the Formula to
+// print from program.Formula if already set or, failing that, by asking loda
+// to export it (the same "formula" export EvalFormula falls back to).
+func (t *LODATool) exportViaPrinter(ctx context.Context, program shared.Program, printer shared.FormulaPrinter) ExportResult {
+	formulaText := program.Formula
+	if formulaText == "" {
+		exported := t.Export(ctx, program, "formula")
+		if exported.Status != "success" {
+			return exported
+		}
+		formulaText = exported.Output
+	}
+	formula, err := shared.ParseFormulaLine(formulaText)
+	if err != nil {
+		return ExportResult{Status: "error", Message: err.Error()}
+	}
+	if formula == nil {
+		return ExportResult{Status: "error", Message: "no formula available for this program"}
+	}
+	output, err := printer.Print(formula)
+	if err != nil {
+		return ExportResult{Status: "error", Message: err.Error()}
+	}
+	return ExportResult{Status: "success", Output: output}
+}
+
+// parseExportOutput turns the raw stdout of a loda export -- whether run as
+// a one-shot subprocess or as a pool job -- into an ExportResult. On
+// failure, output itself carries the error message.
+func parseExportOutput(output string, isError bool) ExportResult {
 	status := "success"
 	message := ""
-	if execErr != nil {
+	if isError {
 		status = "error"
-		message = execErr.Error()
-		if output != "" {
-			message = strings.TrimSpace(output)
-		}
+		message = strings.TrimSpace(output)
 	}
 	return ExportResult{
 		Status:  status,
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/loda-lang/loda-api/shared"
 )
@@ -19,7 +21,7 @@ func TestExportValidFormats(t *testing.T) {
 	validFormats := []string{"formula", "pari", "loda", "range"}
 	for _, format := range validFormats {
 		t.Run(format, func(t *testing.T) {
-			result := tool.Export(program, format)
+			result := tool.Export(context.Background(), program, format)
 			// We expect the export to succeed (status can be success or error depending on LODA availability)
 			// Just check that the result has expected fields
 			if result.Status != "success" && result.Status != "error" {
@@ -36,7 +38,7 @@ func TestExportInvalidFormat(t *testing.T) {
 	program := shared.Program{}
 	program.SetCode("mov $0,1\nadd $0,1")
 
-	result := tool.Export(program, "invalid")
+	result := tool.Export(context.Background(), program, "invalid")
 	if result.Status != "error" {
 		t.Errorf("Expected status 'error' for invalid format, got '%s'", result.Status)
 	}
@@ -52,7 +54,7 @@ func TestExportEmptyProgram(t *testing.T) {
 	program := shared.Program{}
 	program.SetCode("")
 
-	result := tool.Export(program, "loda")
+	result := tool.Export(context.Background(), program, "loda")
 	// Empty program should still create temp file and attempt export
 	if result.Status != "success" && result.Status != "error" {
 		t.Errorf("Expected status to be 'success' or 'error', got '%s'", result.Status)
@@ -75,9 +77,109 @@ func TestExportWithSetupFile(t *testing.T) {
 	program.SetCode("mov $0,1\nadd $0,1")
 
 	// Test with a valid format
-	result := tool.Export(program, "loda")
+	result := tool.Export(context.Background(), program, "loda")
 	// Export should work or fail gracefully
 	if result.Status != "success" && result.Status != "error" {
 		t.Errorf("Expected status to be 'success' or 'error', got '%s'", result.Status)
 	}
 }
+
+// TestEvalFormulaFastPath checks that EvalFormula computes terms in-process,
+// without touching the loda binary, when the program carries a Formula that
+// shared.EvaluateFormula understands.
+func TestEvalFormulaFastPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewLODATool(tmpDir, 1)
+
+	program := shared.Program{}
+	program.SetCode("; Formula: a(n) = a(n-1)+a(n-2), a(0) = 0, a(1) = 1\nmov $0,1\nadd $0,1")
+
+	result := tool.EvalFormula(context.Background(), program, 6)
+	if result.Status != "success" {
+		t.Fatalf("Expected status 'success', got '%s' (%s)", result.Status, result.Message)
+	}
+	want := []string{"0", "1", "1", "2", "3", "5"}
+	if len(result.Terms) != len(want) {
+		t.Fatalf("Expected %d terms, got %d: %v", len(want), len(result.Terms), result.Terms)
+	}
+	for i, term := range want {
+		if result.Terms[i] != term {
+			t.Errorf("term %d = %q, want %q", i, result.Terms[i], term)
+		}
+	}
+}
+
+// TestEvalFormulaFallback checks that EvalFormula falls back to Eval (and
+// thus to the loda binary, whose presence isn't guaranteed here) when the
+// program has no usable Formula.
+func TestEvalFormulaFallback(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewLODATool(tmpDir, 1)
+
+	program := shared.Program{}
+	program.SetCode("mov $0,1\nadd $0,1")
+
+	result := tool.EvalFormula(context.Background(), program, 5)
+	if result.Status != "success" && result.Status != "error" {
+		t.Errorf("Expected status to be 'success' or 'error', got '%s'", result.Status)
+	}
+}
+
+// TestExportCachesResult checks that a second Export call for the same
+// program and format is served from the result cache without recomputing,
+// and that the cached result matches the one the first call returned.
+func TestExportCachesResult(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewLODATool(tmpDir, 1)
+
+	program := shared.Program{}
+	program.SetCode("mov $0,1\nadd $0,1")
+
+	first := tool.Export(context.Background(), program, "loda")
+	second := tool.Export(context.Background(), program, "loda")
+	if second.Status != first.Status || second.Message != first.Message || second.Output != first.Output {
+		t.Errorf("cached Export() = %+v, want it to match the first call %+v", second, first)
+	}
+	stats := tool.Stats().Export
+	if stats.Hits != 1 {
+		t.Errorf("Stats().Export.Hits = %d, want 1", stats.Hits)
+	}
+}
+
+// TestEvalCachesResult checks the same caching behavior as
+// TestExportCachesResult, but for Eval.
+func TestEvalCachesResult(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewLODATool(tmpDir, 1)
+
+	program := shared.Program{}
+	program.SetCode("mov $0,1\nadd $0,1")
+
+	first := tool.Eval(context.Background(), program, 5)
+	second := tool.Eval(context.Background(), program, 5)
+	if second.Status != first.Status || second.Message != first.Message {
+		t.Errorf("cached Eval() = %+v, want it to match the first call %+v", second, first)
+	}
+	stats := tool.Stats().Eval
+	if stats.Hits != 1 {
+		t.Errorf("Stats().Eval.Hits = %d, want 1", stats.Hits)
+	}
+}
+
+// TestWithCacheOption checks that WithCache overrides the default cache size.
+func TestWithCacheOption(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewLODATool(tmpDir, 1, WithCache(1, time.Hour))
+
+	a := shared.Program{}
+	a.SetCode("mov $0,1")
+	b := shared.Program{}
+	b.SetCode("mov $0,2")
+
+	tool.Export(context.Background(), a, "loda")
+	tool.Export(context.Background(), b, "loda")
+
+	if stats := tool.Stats().Export; stats.Evictions != 1 {
+		t.Errorf("Stats().Export.Evictions = %d, want 1 after exceeding WithCache(1, ...)'s limit", stats.Evictions)
+	}
+}
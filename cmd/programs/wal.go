@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loda-lang/loda-api/shared"
+)
+
+const (
+	SubmissionsWALFile  = "submissions.wal"
+	SubmissionsSnapFile = "submissions.snap"
+
+	// WALSegmentMaxBytes and WALSegmentMaxAge bound how large or how old the
+	// active WAL segment may grow before Append rotates it into a sealed,
+	// read-only segment and starts a fresh one. Keeping segments small bounds
+	// how much a single torn write can cost and how long replay takes before
+	// the next Compact.
+	WALSegmentMaxBytes = 8 * 1024 * 1024
+	WALSegmentMaxAge   = 1 * time.Hour
+
+	walSealedSuffix = ".wal."
+)
+
+// SubmissionWAL is an append-only, length-prefix-framed, CRC-checked
+// write-ahead log of accepted submissions. Each Append is fsync'd
+// individually, so a crash loses at most one unflushed record. The active
+// segment rotates by size or age into a sealed segment (submissions.wal.N),
+// and Compact periodically folds the snapshot plus all segments into a fresh
+// snapshot and removes the now-obsolete sealed segments.
+type SubmissionWAL struct {
+	dataDir string
+
+	// maxSegmentBytes and maxSegmentAge default to WALSegmentMaxBytes and
+	// WALSegmentMaxAge; tests override them to force deterministic rotation
+	// without waiting on real time or writing megabytes of records.
+	maxSegmentBytes int64
+	maxSegmentAge   time.Duration
+
+	mutex   sync.Mutex
+	file    *os.File
+	size    int64
+	opened  time.Time
+	nextSeq int64
+}
+
+func NewSubmissionWAL(dataDir string) *SubmissionWAL {
+	return &SubmissionWAL{
+		dataDir:         dataDir,
+		maxSegmentBytes: WALSegmentMaxBytes,
+		maxSegmentAge:   WALSegmentMaxAge,
+	}
+}
+
+func (w *SubmissionWAL) walPath() string {
+	return filepath.Join(w.dataDir, SubmissionsWALFile)
+}
+
+func (w *SubmissionWAL) snapPath() string {
+	return filepath.Join(w.dataDir, SubmissionsSnapFile)
+}
+
+func (w *SubmissionWAL) sealedPath(seq int64) string {
+	return filepath.Join(w.dataDir, fmt.Sprintf("%s%s%d", SubmissionsWALFile, walSealedSuffix, seq))
+}
+
+// sealedSegments returns the paths of all sealed WAL segments in dataDir,
+// ordered oldest (lowest sequence number) first.
+func (w *SubmissionWAL) sealedSegments() ([]string, error) {
+	matches, err := filepath.Glob(w.walPath() + walSealedSuffix + "*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot list sealed WAL segments: %w", err)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return sealedSeq(matches[i]) < sealedSeq(matches[j])
+	})
+	return matches, nil
+}
+
+// sealedSeq extracts the sequence number suffix from a sealed segment path,
+// or -1 if it cannot be parsed (which sorts it first, out of the way).
+func sealedSeq(path string) int64 {
+	idx := strings.LastIndex(path, walSealedSuffix)
+	if idx < 0 {
+		return -1
+	}
+	seq, err := strconv.ParseInt(path[idx+len(walSealedSuffix):], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return seq
+}
+
+// appendRecord frames payload as a 4-byte big-endian length prefix, the
+// payload itself, and a trailing 4-byte big-endian CRC32 checksum of the
+// payload, so torn writes and bit-level corruption are both detectable on
+// replay.
+func appendRecord(w io.Writer, payload []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return fmt.Errorf("cannot write WAL record header: %w", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return fmt.Errorf("cannot write WAL record: %w", err)
+	}
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(trailer[:]); err != nil {
+		return fmt.Errorf("cannot write WAL record checksum: %w", err)
+	}
+	return nil
+}
+
+// Append frames submission as a length-prefixed, CRC-checked JSON record,
+// fsyncs it to the active WAL segment, and rotates the segment if it has
+// grown too large or too old.
+func (w *SubmissionWAL) Append(submission shared.Submission) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		if err := w.openActiveSegment(); err != nil {
+			return err
+		}
+	}
+	payload, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("cannot marshal submission: %w", err)
+	}
+	if err := appendRecord(w.file, payload); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync WAL file: %w", err)
+	}
+	w.size += int64(4 + len(payload) + 4)
+	if w.size >= w.maxSegmentBytes || time.Since(w.opened) >= w.maxSegmentAge {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openActiveSegment opens (or creates) the active WAL segment. Caller must
+// hold mutex.
+func (w *SubmissionWAL) openActiveSegment() error {
+	f, err := os.OpenFile(w.walPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open WAL file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot stat WAL file: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	w.opened = time.Now()
+	return nil
+}
+
+// rotate seals the current active segment under a sequence-numbered name and
+// opens a fresh, empty active segment. Caller must hold mutex.
+func (w *SubmissionWAL) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("cannot close WAL segment before rotation: %w", err)
+	}
+	w.file = nil
+	seq := w.nextSeq
+	w.nextSeq++
+	if err := os.Rename(w.walPath(), w.sealedPath(seq)); err != nil {
+		return fmt.Errorf("cannot seal WAL segment: %w", err)
+	}
+	return w.openActiveSegment()
+}
+
+// readRecords reads length-prefixed, CRC-checked submission records from r
+// and returns them along with the byte offset of the first torn record
+// (incomplete header, body, or checksum, e.g. from a crash mid-append), or -1
+// if the stream ended cleanly. Callers use that offset to truncate the
+// underlying file.
+func readRecords(r io.Reader) ([]shared.Submission, int64) {
+	submissions := []shared.Submission{}
+	reader := bufio.NewReader(r)
+	var offset int64
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			if err != io.EOF {
+				log.Printf("Truncating torn WAL record header at offset %d: %v", offset, err)
+				return submissions, offset
+			}
+			return submissions, -1
+		}
+		length := binary.BigEndian.Uint32(header[:])
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			log.Printf("Truncating torn WAL record body at offset %d: %v", offset, err)
+			return submissions, offset
+		}
+		var trailer [4]byte
+		if _, err := io.ReadFull(reader, trailer[:]); err != nil {
+			log.Printf("Truncating torn WAL record checksum at offset %d: %v", offset, err)
+			return submissions, offset
+		}
+		recordLen := int64(len(header)) + int64(length) + int64(len(trailer))
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(trailer[:]) {
+			log.Printf("Skipping corrupt WAL record at offset %d: checksum mismatch", offset)
+			offset += recordLen
+			continue
+		}
+		var submission shared.Submission
+		if err := json.Unmarshal(payload, &submission); err != nil {
+			log.Printf("Skipping corrupt WAL record at offset %d: %v", offset, err)
+		} else {
+			submissions = append(submissions, submission)
+		}
+		offset += recordLen
+	}
+}
+
+// readSegment opens path and returns its replayed submissions, logging (but
+// not truncating) any torn trailing record. Truncation only makes sense for
+// the active segment, which is handled by Load.
+func readSegment(path string) []shared.Submission {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Cannot open WAL segment %s: %v", path, err)
+		return nil
+	}
+	defer f.Close()
+	submissions, tornAt := readRecords(f)
+	if tornAt >= 0 {
+		log.Printf("Sealed WAL segment %s has a torn trailing record at offset %d", path, tornAt)
+	}
+	return submissions
+}
+
+// Load replays the snapshot (if any), followed by all sealed WAL segments in
+// sequence order, followed by the active WAL segment, returning the
+// combined, ordered list of submissions. As a startup integrity check, a torn
+// final record in the active segment (e.g. from a crash mid-append) is
+// dropped and the segment is truncated to the last complete record so future
+// appends aren't confused by trailing garbage.
+func (w *SubmissionWAL) Load() ([]shared.Submission, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	submissions := []shared.Submission{}
+	if snap, err := os.Open(w.snapPath()); err == nil {
+		defer snap.Close()
+		decoder := json.NewDecoder(snap)
+		if err := decoder.Decode(&submissions); err != nil {
+			log.Printf("Cannot decode submissions snapshot: %v", err)
+			submissions = []shared.Submission{}
+		}
+	}
+
+	sealed, err := w.sealedSegments()
+	if err != nil {
+		return submissions, err
+	}
+	for _, path := range sealed {
+		submissions = append(submissions, readSegment(path)...)
+		if seq := sealedSeq(path); seq >= w.nextSeq {
+			w.nextSeq = seq + 1
+		}
+	}
+
+	walFile, err := os.OpenFile(w.walPath(), os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return submissions, nil
+		}
+		return submissions, fmt.Errorf("cannot open WAL file: %w", err)
+	}
+	defer walFile.Close()
+	walSubmissions, tornAt := readRecords(walFile)
+	if tornAt >= 0 {
+		if err := walFile.Truncate(tornAt); err != nil {
+			log.Printf("Cannot truncate torn WAL file: %v", err)
+		}
+	}
+	return append(submissions, walSubmissions...), nil
+}
+
+// Compact folds the given submissions into a fresh snapshot file, replaces
+// the active WAL segment with an empty one, and deletes any sealed segments,
+// all via atomic rename so a crash mid-compaction cannot leave a partially
+// written snapshot or WAL in place.
+func (w *SubmissionWAL) Compact(submissions []shared.Submission) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	tmpSnap := w.snapPath() + ".tmp"
+	f, err := os.Create(tmpSnap)
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot tmp file: %w", err)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(submissions); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot sync snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close snapshot: %w", err)
+	}
+	if err := os.Rename(tmpSnap, w.snapPath()); err != nil {
+		return fmt.Errorf("cannot rename snapshot: %w", err)
+	}
+
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	tmpWal := w.walPath() + ".tmp"
+	tf, err := os.Create(tmpWal)
+	if err != nil {
+		return fmt.Errorf("cannot create WAL tmp file: %w", err)
+	}
+	tf.Close()
+	if err := os.Rename(tmpWal, w.walPath()); err != nil {
+		return fmt.Errorf("cannot rename WAL: %w", err)
+	}
+
+	sealed, err := w.sealedSegments()
+	if err != nil {
+		return err
+	}
+	for _, path := range sealed {
+		if err := os.Remove(path); err != nil {
+			log.Printf("Cannot remove obsolete WAL segment %s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// workerPoolProbeTimeout bounds how long newWorkerPool waits for a freshly
+// started worker to answer a ping before concluding that the installed loda
+// binary does not speak the persistent-worker protocol.
+const workerPoolProbeTimeout = 2 * time.Second
+
+// workerCancelDrainTimeout bounds how long a cancelled job's eventual,
+// discarded response is waited for before its slot in the worker's pending
+// map is freed, so a worker that ignores cancellation can't leak it forever.
+const workerCancelDrainTimeout = 5 * time.Second
+
+// workerRequest is one line of the line-oriented JSON protocol written to a
+// pooled loda worker's stdin. Cmd is "ping", "eval", "export", or "cancel".
+// Program carries the full program source inline, so a job never touches
+// disk the way the one-shot exec path's temp file does.
+type workerRequest struct {
+	ID      string   `json:"id"`
+	Cmd     string   `json:"cmd"`
+	Program string   `json:"program,omitempty"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// workerResponse is one line of JSON read back from a pooled worker's
+// stdout. A worker may emit any number of Status "log" lines for a given ID
+// before its final Status ("ok", "error", or "cancelled") response.
+type workerResponse struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Output  string `json:"output"`
+	LogLine string `json:"log_line,omitempty"`
+}
+
+// lodaWorker wraps one persistent "loda pool" subprocess and the read loop
+// that demultiplexes its stdout back to the goroutines waiting on pending
+// request IDs.
+type lodaWorker struct {
+	idx int
+	cmd *exec.Cmd
+
+	writeMu sync.Mutex // guards writes to stdin
+	stdin   io.WriteCloser
+
+	mu      sync.Mutex // guards pending
+	pending map[string]chan workerResponse
+
+	exitOnce sync.Once
+	exited   chan struct{} // closed once the worker's process or read loop has died
+}
+
+// startWorker launches a new "loda pool" subprocess at pool slot idx and
+// begins demultiplexing its stdout.
+func startWorker(idx int, lodaExec, dataDir string) (*lodaWorker, error) {
+	cmd := exec.Command(lodaExec, "pool")
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, "LODA_HOME="+dataDir)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start loda worker: %w", err)
+	}
+
+	w := &lodaWorker{
+		idx:     idx,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[string]chan workerResponse{},
+		exited:  make(chan struct{}),
+	}
+	go w.readLoop(stdout)
+	go w.waitLoop()
+	return w, nil
+}
+
+func (w *lodaWorker) markExited() {
+	w.exitOnce.Do(func() { close(w.exited) })
+}
+
+// waitLoop reaps the worker process so it never becomes a zombie, and marks
+// the worker dead once it exits for any reason.
+func (w *lodaWorker) waitLoop() {
+	w.cmd.Wait()
+	w.markExited()
+}
+
+// readLoop demultiplexes the worker's stdout, one JSON response per line,
+// back to whichever do() call is waiting on that response's ID. A line that
+// fails to parse means the worker and this process have fallen out of sync
+// on protocol framing, so the worker is considered dead; the pool restarts
+// it rather than risk misrouting a later job's response.
+func (w *lodaWorker) readLoop(stdout io.Reader) {
+	defer w.markExited()
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var resp workerResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			log.Printf("loda worker %d: protocol desync, restarting: %v", w.idx, err)
+			return
+		}
+		if resp.Status == "log" {
+			log.Print(resp.LogLine)
+			continue
+		}
+		w.mu.Lock()
+		ch, ok := w.pending[resp.ID]
+		if ok {
+			delete(w.pending, resp.ID)
+		}
+		w.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// do submits req to w and waits for its matching response. If ctx is done
+// before the worker replies, a "cancel" job for the same ID is sent so the
+// worker can abort the in-flight computation, and do returns ctx.Err()
+// immediately rather than blocking on the worker's eventual acknowledgement.
+func (w *lodaWorker) do(ctx context.Context, req workerRequest) (workerResponse, error) {
+	respCh := make(chan workerResponse, 1)
+	w.mu.Lock()
+	w.pending[req.ID] = respCh
+	w.mu.Unlock()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return workerResponse{}, err
+	}
+	w.writeMu.Lock()
+	_, err = w.stdin.Write(append(data, '\n'))
+	w.writeMu.Unlock()
+	if err != nil {
+		w.markExited()
+		return workerResponse{}, fmt.Errorf("loda worker %d: write failed: %w", w.idx, err)
+	}
+
+	select {
+	case resp := <-respCh:
+		return resp, nil
+	case <-w.exited:
+		return workerResponse{}, fmt.Errorf("loda worker %d exited", w.idx)
+	case <-ctx.Done():
+		w.sendCancel(req.ID)
+		go w.drainCancelled(req.ID, respCh)
+		return workerResponse{}, ctx.Err()
+	}
+}
+
+func (w *lodaWorker) sendCancel(id string) {
+	data, err := json.Marshal(workerRequest{ID: id, Cmd: "cancel"})
+	if err != nil {
+		return
+	}
+	w.writeMu.Lock()
+	w.stdin.Write(append(data, '\n'))
+	w.writeMu.Unlock()
+}
+
+// drainCancelled waits for the discarded response to a cancelled job (or
+// gives up after workerCancelDrainTimeout) and then frees its slot in the
+// pending map, so a worker that never acknowledges cancellation can't leak
+// it indefinitely.
+func (w *lodaWorker) drainCancelled(id string, respCh chan workerResponse) {
+	select {
+	case <-respCh:
+	case <-w.exited:
+	case <-time.After(workerCancelDrainTimeout):
+	}
+	w.mu.Lock()
+	delete(w.pending, id)
+	w.mu.Unlock()
+}
+
+// close terminates the worker process without waiting for it to drain any
+// in-flight job; used when tearing down a pool.
+func (w *lodaWorker) close() {
+	w.stdin.Close()
+	if w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+}
+
+// workerPool is a fixed-size set of persistent loda worker subprocesses
+// (size == LODATool's maxNumParallelEval) that replaces fork+exec per
+// request with long-lived processes speaking the workerRequest/workerResponse
+// protocol over stdin/stdout. available is both the free-worker queue and
+// the concurrency limiter that evalSem used to be.
+type workerPool struct {
+	lodaExec string
+	dataDir  string
+
+	available chan *lodaWorker
+
+	mu      sync.Mutex // guards workers during restarts
+	workers []*lodaWorker
+
+	nextID uint64
+}
+
+// newWorkerPool starts size persistent loda worker subprocesses and probes
+// the first one with a ping job. If the installed loda binary doesn't
+// understand the "pool" subcommand or its protocol, an error is returned so
+// the caller can fall back to the one-shot exec path.
+func newWorkerPool(lodaExec, dataDir string, size int) (*workerPool, error) {
+	if size <= 0 {
+		size = 1
+	}
+
+	probe, err := startWorker(0, lodaExec, dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start loda worker: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), workerPoolProbeTimeout)
+	defer cancel()
+	resp, err := probe.do(ctx, workerRequest{ID: "probe", Cmd: "ping"})
+	if err != nil {
+		probe.close()
+		return nil, fmt.Errorf("loda worker did not answer ping: %w", err)
+	}
+	if resp.Status != "ok" {
+		probe.close()
+		return nil, fmt.Errorf("loda worker returned unexpected ping status %q", resp.Status)
+	}
+
+	p := &workerPool{
+		lodaExec:  lodaExec,
+		dataDir:   dataDir,
+		available: make(chan *lodaWorker, size),
+		workers:   make([]*lodaWorker, size),
+	}
+	p.workers[0] = probe
+	p.available <- probe
+	for i := 1; i < size; i++ {
+		w, err := startWorker(i, lodaExec, dataDir)
+		if err != nil {
+			log.Printf("failed to start loda worker %d: %v", i, err)
+			continue
+		}
+		p.workers[i] = w
+		p.available <- w
+	}
+	return p, nil
+}
+
+// submit checks out a worker, submits a job built from cmd/program/args, and
+// returns its response. If ctx is done before a worker is free, it returns
+// ctx.Err() without starting a job. A worker that dies mid-job is not
+// returned to the pool; restart is scheduled in the background so submit
+// itself never blocks on respawning a subprocess.
+func (p *workerPool) submit(ctx context.Context, cmd, program string, args []string) (workerResponse, error) {
+	select {
+	case w := <-p.available:
+		id := strconv.FormatUint(atomic.AddUint64(&p.nextID, 1), 10)
+		resp, err := w.do(ctx, workerRequest{ID: id, Cmd: cmd, Program: program, Args: args})
+		select {
+		case <-w.exited:
+			go p.restart(w)
+		default:
+			p.available <- w
+		}
+		return resp, err
+	case <-ctx.Done():
+		return workerResponse{}, ctx.Err()
+	}
+}
+
+// restart replaces a dead worker at old's pool slot. It retries once after a
+// short delay before giving up and shrinking the pool's effective capacity
+// by one, logging either way so an operator can see it happen.
+func (p *workerPool) restart(old *lodaWorker) {
+	log.Printf("loda worker %d exited unexpectedly or desynced; restarting", old.idx)
+	for attempt := 1; attempt <= 2; attempt++ {
+		w, err := startWorker(old.idx, p.lodaExec, p.dataDir)
+		if err == nil {
+			p.mu.Lock()
+			p.workers[old.idx] = w
+			p.mu.Unlock()
+			p.available <- w
+			return
+		}
+		log.Printf("failed to restart loda worker %d (attempt %d): %v", old.idx, attempt, err)
+		time.Sleep(time.Second)
+	}
+	log.Printf("giving up on restarting loda worker %d; pool capacity reduced by one", old.idx)
+}
+
+// close terminates every worker in the pool. Intended for tests; production
+// LODATools live for the lifetime of the process.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, w := range p.workers {
+		if w != nil {
+			w.close()
+		}
+	}
+}
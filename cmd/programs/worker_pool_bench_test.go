@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// BenchmarkEvalOneShot simulates today's fork+exec-per-request model: start a
+// fresh fake-loda subprocess, submit one job, read its response, and tear it
+// down again. Compare against BenchmarkEvalPool to see the throughput that
+// reusing persistent workers buys back.
+func BenchmarkEvalOneShot(b *testing.B) {
+	exe := helperPathB(b, "echo")
+	for i := 0; i < b.N; i++ {
+		w, err := startWorker(0, exe, b.TempDir())
+		if err != nil {
+			b.Fatalf("startWorker failed: %v", err)
+		}
+		if _, err := w.do(context.Background(), workerRequest{ID: "1", Cmd: "eval", Program: "mov $0,1"}); err != nil {
+			b.Fatalf("job failed: %v", err)
+		}
+		w.close()
+	}
+}
+
+// BenchmarkEvalPool submits the same job to a warm workerPool of persistent
+// workers, paying the process-start cost once instead of once per job.
+func BenchmarkEvalPool(b *testing.B) {
+	exe := helperPathB(b, "echo")
+	pool, err := newWorkerPool(exe, b.TempDir(), 4)
+	if err != nil {
+		b.Fatalf("newWorkerPool failed: %v", err)
+	}
+	defer pool.close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pool.submit(context.Background(), "eval", "mov $0,1", nil); err != nil {
+			b.Fatalf("submit failed: %v", err)
+		}
+	}
+}
+
+// helperPathB points lodaExec at this same test binary, re-invoked as the
+// fake loda pool in mode (see runFakeLodaPool in worker_pool_test.go).
+func helperPathB(b *testing.B, mode string) string {
+	b.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		b.Fatalf("failed to locate test binary: %v", err)
+	}
+	b.Setenv(helperProcessEnv, "1")
+	b.Setenv(helperModeEnv, mode)
+	return exe
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The tests below exercise workerPool's protocol handling against a fake
+// "loda pool" subprocess rather than the real loda binary, whose presence
+// and pool-protocol support can't be assumed in this environment. The fake
+// is this same test binary, re-invoked as a subprocess and diverted into
+// runFakeLodaPool by an environment variable -- the same helper-process
+// pattern os/exec's own tests use.
+const (
+	helperProcessEnv = "LODA_API_WANT_HELPER_PROCESS"
+	helperModeEnv    = "LODA_API_HELPER_MODE"
+	helperMarkerEnv  = "LODA_API_HELPER_MARKER"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperProcessEnv) == "1" {
+		runFakeLodaPool()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeLodaPool stands in for `loda pool`: it reads workerRequest lines
+// from stdin and writes workerResponse lines to stdout, behaving according
+// to helperModeEnv so each test can exercise one aspect of workerPool
+// (a clean probe, an unsupported binary, a worker that desyncs, one that
+// crashes mid-job, or one that only finishes a job once cancelled).
+func runFakeLodaPool() {
+	mode := os.Getenv(helperModeEnv)
+	cancelled := make(chan string, 16)
+	jobs := make(chan workerRequest, 16)
+
+	go func() {
+		defer close(jobs)
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if line != "" {
+				if mode == "desync" {
+					fmt.Println("not-json")
+				} else {
+					var req workerRequest
+					if jsonErr := json.Unmarshal([]byte(line), &req); jsonErr == nil {
+						if req.Cmd == "cancel" {
+							cancelled <- req.ID
+						} else {
+							jobs <- req
+						}
+					}
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for req := range jobs {
+		switch mode {
+		case "unsupported":
+			writeFakeResponse(workerResponse{ID: req.ID, Status: "error", Output: "unsupported command"})
+		case "die-once":
+			if req.Cmd == "ping" {
+				writeFakeResponse(workerResponse{ID: req.ID, Status: "ok"})
+				continue
+			}
+			marker := os.Getenv(helperMarkerEnv)
+			if _, err := os.Stat(marker); os.IsNotExist(err) {
+				os.WriteFile(marker, []byte("crashed"), 0644)
+				os.Exit(1)
+			}
+			writeFakeResponse(workerResponse{ID: req.ID, Status: "ok", Output: req.Cmd + ":" + req.Program})
+		case "slow-cancel":
+			if req.Cmd == "ping" {
+				writeFakeResponse(workerResponse{ID: req.ID, Status: "ok"})
+				continue
+			}
+			select {
+			case <-cancelled:
+				writeFakeResponse(workerResponse{ID: req.ID, Status: "cancelled"})
+			case <-time.After(5 * time.Second):
+				writeFakeResponse(workerResponse{ID: req.ID, Status: "ok", Output: "too slow"})
+			}
+		default: // "echo"
+			if req.Cmd == "ping" {
+				writeFakeResponse(workerResponse{ID: req.ID, Status: "ok"})
+				continue
+			}
+			writeFakeResponse(workerResponse{ID: req.ID, Status: "ok", Output: req.Cmd + ":" + req.Program})
+		}
+	}
+}
+
+func writeFakeResponse(resp workerResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// helperPath sets up the current test binary to run as a fake loda pool in
+// the given mode when started, and returns its path for use as lodaExec.
+func helperPath(t *testing.T, mode string) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to locate test binary: %v", err)
+	}
+	t.Setenv(helperProcessEnv, "1")
+	t.Setenv(helperModeEnv, mode)
+	return exe
+}
+
+func TestNewWorkerPoolProbeSuccess(t *testing.T) {
+	exe := helperPath(t, "echo")
+	pool, err := newWorkerPool(exe, t.TempDir(), 3)
+	if err != nil {
+		t.Fatalf("newWorkerPool failed: %v", err)
+	}
+	defer pool.close()
+	if len(pool.available) != 3 {
+		t.Errorf("got %d available workers, want 3", len(pool.available))
+	}
+}
+
+func TestNewWorkerPoolFallsBackWhenUnsupported(t *testing.T) {
+	exe := helperPath(t, "unsupported")
+	if _, err := newWorkerPool(exe, t.TempDir(), 1); err == nil {
+		t.Fatal("expected an error when the ping response status isn't ok")
+	}
+}
+
+func TestNewWorkerPoolFallsBackOnDesync(t *testing.T) {
+	exe := helperPath(t, "desync")
+	if _, err := newWorkerPool(exe, t.TempDir(), 1); err == nil {
+		t.Fatal("expected an error when the worker's output can't be parsed as JSON")
+	}
+}
+
+func TestWorkerPoolSubmitRunsJob(t *testing.T) {
+	exe := helperPath(t, "echo")
+	pool, err := newWorkerPool(exe, t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("newWorkerPool failed: %v", err)
+	}
+	defer pool.close()
+
+	resp, err := pool.submit(context.Background(), "eval", "mov $0,1", []string{"-t", "5"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if resp.Status != "ok" || resp.Output != "eval:mov $0,1" {
+		t.Errorf("got %+v, want status ok and output %q", resp, "eval:mov $0,1")
+	}
+}
+
+func TestWorkerPoolSubmitCancelsOnContextDone(t *testing.T) {
+	exe := helperPath(t, "slow-cancel")
+	pool, err := newWorkerPool(exe, t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("newWorkerPool failed: %v", err)
+	}
+	defer pool.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = pool.submit(ctx, "eval", "mov $0,1", nil)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want context.DeadlineExceeded", err)
+	}
+
+	// The worker itself is still alive (it only ever replies "cancelled" or
+	// exits after a real timeout), so it should have been returned to the
+	// pool rather than discarded.
+	select {
+	case <-pool.available:
+	case <-time.After(2 * time.Second):
+		t.Error("worker was not returned to the pool after its job was cancelled")
+	}
+}
+
+func TestWorkerPoolRestartsDeadWorker(t *testing.T) {
+	exe := helperPath(t, "die-once")
+	t.Setenv(helperMarkerEnv, filepath.Join(t.TempDir(), "crashed"))
+
+	pool, err := newWorkerPool(exe, t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("newWorkerPool failed: %v", err)
+	}
+	defer pool.close()
+
+	if _, err := pool.submit(context.Background(), "eval", "mov $0,1", nil); err == nil {
+		t.Fatal("expected an error from the job that crashed its worker")
+	}
+
+	// restart runs in the background; poll briefly for the replacement
+	// worker to pick up the next job successfully.
+	deadline := time.Now().Add(3 * time.Second)
+	var resp workerResponse
+	for time.Now().Before(deadline) {
+		resp, err = pool.submit(context.Background(), "eval", "mov $0,2", nil)
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("pool did not recover after restarting its dead worker: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Errorf("got status %q, want ok", resp.Status)
+	}
+}
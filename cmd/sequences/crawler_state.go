@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// crawlerStateFile is the name of the JSON file SequencesServer persists its
+// CrawlerState to under dataDir, so a restart resumes the crawl instead of
+// starting over from a fresh Init.
+const crawlerStateFile = "crawler_state.json"
+
+// CrawlerState is everything StartCrawler needs to resume a crawl across a
+// restart: the crawler's own walk position (CurrentId, StepSize, MaxId,
+// NumFetched, MissingIds) plus, for each list, the NumFetched value as of
+// that list's last successful Flush, so an operator can tell how far each
+// list lags behind the crawl.
+type CrawlerState struct {
+	CurrentId   int            `json:"current_id"`
+	StepSize    int            `json:"step_size"`
+	MaxId       int            `json:"max_id"`
+	NumFetched  int            `json:"num_fetched"`
+	MissingIds  []int          `json:"missing_ids"`
+	ListCursors map[string]int `json:"list_cursors"`
+}
+
+// crawlerStatePath returns where a SequencesServer rooted at dataDir
+// persists its CrawlerState.
+func crawlerStatePath(dataDir string) string {
+	return filepath.Join(dataDir, crawlerStateFile)
+}
+
+// loadCrawlerState reads the CrawlerState last saved under dataDir. It
+// returns ok == false (with no error) if none has been saved yet, which
+// StartCrawler treats as a fresh crawl.
+func loadCrawlerState(dataDir string) (state CrawlerState, ok bool, err error) {
+	data, err := os.ReadFile(crawlerStatePath(dataDir))
+	if os.IsNotExist(err) {
+		return CrawlerState{}, false, nil
+	}
+	if err != nil {
+		return CrawlerState{}, false, fmt.Errorf("failed to read crawler state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return CrawlerState{}, false, fmt.Errorf("failed to parse crawler state: %w", err)
+	}
+	return state, true, nil
+}
+
+// saveCrawlerState writes state under dataDir via a temp file plus atomic
+// rename, matching the wal package's metadata-save pattern.
+func saveCrawlerState(dataDir string, state CrawlerState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal crawler state: %w", err)
+	}
+	path := crawlerStatePath(dataDir)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write crawler state: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// CrawlerEventType identifies what happened in a CrawlerEvent.
+type CrawlerEventType string
+
+const (
+	CrawlerEventFetch  CrawlerEventType = "fetch"
+	CrawlerEventFlush  CrawlerEventType = "flush"
+	CrawlerEventReinit CrawlerEventType = "reinit"
+	CrawlerEventError  CrawlerEventType = "error"
+)
+
+// CrawlerEvent is one entry in the crawler's event stream: an operator
+// dashboard or the /metrics endpoint can tail Events (and, if enabled, the
+// JSONL file under dataDir) to render live crawl progress without scraping
+// logs.
+type CrawlerEvent struct {
+	Type    CrawlerEventType `json:"type"`
+	Time    time.Time        `json:"time"`
+	SeqId   int              `json:"seq_id,omitempty"`
+	List    string           `json:"list,omitempty"`
+	Message string           `json:"message,omitempty"`
+}
+
+// crawlerEventLogFile is the JSONL file an eventLogger appends each
+// CrawlerEvent to, under dataDir.
+const crawlerEventLogFile = "crawler_events.jsonl"
+
+// crawlerEventPublisher fans out CrawlerEvents to an in-memory channel (for
+// live consumers such as the /metrics endpoint) and, best-effort, appends
+// them as JSONL to dataDir/crawlerEventLogFile for an operator to tail or
+// replay after the fact.
+type crawlerEventPublisher struct {
+	Events chan CrawlerEvent
+
+	mutex   sync.Mutex
+	logFile *os.File
+}
+
+// newCrawlerEventPublisher creates a crawlerEventPublisher whose JSONL log
+// lives under dataDir. Events is buffered so a slow or absent consumer
+// never blocks a publish; once full, the oldest unread event is dropped
+// before enqueuing, since the log file remains the record of truth.
+func newCrawlerEventPublisher(dataDir string) *crawlerEventPublisher {
+	p := &crawlerEventPublisher{Events: make(chan CrawlerEvent, 256)}
+	f, err := os.OpenFile(filepath.Join(dataDir, crawlerEventLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Crawler event log disabled: %v", err)
+	} else {
+		p.logFile = f
+	}
+	return p
+}
+
+// publish records event to the JSONL log (if open) and enqueues it on
+// Events, dropping the oldest queued event rather than blocking if no
+// consumer is draining it.
+func (p *crawlerEventPublisher) publish(event CrawlerEvent) {
+	p.mutex.Lock()
+	if p.logFile != nil {
+		if data, err := json.Marshal(event); err == nil {
+			if _, err := p.logFile.Write(append(data, '\n')); err != nil {
+				log.Printf("Failed to append crawler event: %v", err)
+			}
+		}
+	}
+	p.mutex.Unlock()
+	select {
+	case p.Events <- event:
+	default:
+		select {
+		case <-p.Events:
+		default:
+		}
+		select {
+		case p.Events <- event:
+		default:
+		}
+	}
+}
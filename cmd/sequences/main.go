@@ -1,13 +1,13 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -15,9 +15,14 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	v1 "github.com/loda-lang/loda-api/api/v1"
+	v2 "github.com/loda-lang/loda-api/api/v2"
 	"github.com/loda-lang/loda-api/cmd"
+	"github.com/loda-lang/loda-api/crawler"
 	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/storage"
 	"github.com/loda-lang/loda-api/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type SequencesServer struct {
@@ -32,17 +37,29 @@ type SequencesServer struct {
 	crawlerIdsCacheSize   int
 	crawlerIdsFetchRatio  float64
 	crawlerStopped        chan bool
-	crawler               *Crawler
+	crawler               *crawler.Crawler
+	backend               *crawler.MultiBackend
 	dataIndex             *shared.DataIndex
 	httpClient            *http.Client
-	lists                 []*List
+	lists                 []*storage.List
+	listCursors           map[string]int
+	events                *crawlerEventPublisher
 	dataIndexMutex        sync.Mutex
+	metrics               *Metrics
 }
 
 const (
 	OeisWebsite string = "https://oeis.org/"
 )
 
+// backendRequestsPerSecond and backendBurst bound the combined rate of OEIS
+// fetches this server makes, whether triggered by the crawler or by a
+// cache-miss v1 request, since both go through the same backend.
+const (
+	backendRequestsPerSecond = 2
+	backendBurst             = 4
+)
+
 var (
 	ListNames = map[string]string{
 		"A": "authors",
@@ -65,11 +82,12 @@ func NewSequencesServer(dataDir string, oeisDir string, updateInterval time.Dura
 		},
 	}
 	i := 0
-	lists := make([]*List, len(ListNames))
+	lists := make([]*storage.List, len(ListNames))
 	for key, name := range ListNames {
-		lists[i] = NewList(key, name, oeisDir)
+		lists[i] = storage.NewList(key, name, oeisDir)
 		i++
 	}
+	backend := crawler.NewMultiBackend(httpClient, []string{OeisWebsite}, backendRequestsPerSecond, backendBurst)
 	return &SequencesServer{
 		dataDir:               dataDir,
 		oeisDir:               oeisDir,
@@ -82,18 +100,25 @@ func NewSequencesServer(dataDir string, oeisDir string, updateInterval time.Dura
 		crawlerIdsCacheSize:   1000,
 		crawlerIdsFetchRatio:  0.5,
 		crawlerStopped:        make(chan bool),
-		crawler:               NewCrawler(httpClient),
+		crawler:               crawler.NewCrawlerWithBackend(backend),
+		backend:               backend,
 		dataIndex:             nil,
 		dataIndexMutex:        sync.Mutex{},
 		httpClient:            httpClient,
 		lists:                 lists,
+		listCursors:           map[string]int{},
+		events:                newCrawlerEventPublisher(dataDir),
+		metrics:               NewMetrics(),
 	}
 }
 
-func GetIndex(s *SequencesServer) *shared.DataIndex {
+// Index returns the server's DataIndex, lazily building and caching it. It
+// implements api/v2's IndexProvider.
+func (s *SequencesServer) Index() *shared.DataIndex {
 	s.dataIndexMutex.Lock()
 	defer s.dataIndexMutex.Unlock()
 	if s.dataIndex == nil {
+		s.metrics.DataIndexTotal.WithLabelValues("miss").Inc()
 		idx := shared.NewDataIndex(s.dataDir)
 		err := idx.Load()
 		if err != nil {
@@ -104,136 +129,59 @@ func GetIndex(s *SequencesServer) *shared.DataIndex {
 		idx.Programs = nil
 		runtime.GC()
 		s.dataIndex = idx
+	} else {
+		s.metrics.DataIndexTotal.WithLabelValues("hit").Inc()
 	}
 	return s.dataIndex
 }
 
-func newSummaryHandler(s *SequencesServer, filename string) http.Handler {
-	f := func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		path := filepath.Join(s.oeisDir, filename)
-		if !util.IsFileRecent(path, s.summaryUpdateInterval) {
-			err := util.FetchFile(s.httpClient, OeisWebsite+filename, path)
-			if err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatal(err)
-			}
-			cmd := exec.Command("gunzip", "-f", "-k", path)
-			if err := cmd.Run(); err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatalf("Error executing gunzip: %v", err)
-			}
-		}
-		util.ServeBinary(w, req, path)
-	}
-	return http.HandlerFunc(f)
-}
-
-func newBFileHandler(s *SequencesServer) http.Handler {
-	f := func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		params := mux.Vars(req)
-		id := params["id"]
-		if len(id) != 6 {
-			util.WriteHttpBadRequest(w)
-			return
-		}
-		dir := filepath.Join(s.oeisDir, "b", id[0:3])
-		os.MkdirAll(dir, os.ModePerm)
-		filename := fmt.Sprintf("b%s.txt.gz", id)
-		path := filepath.Join(dir, filename)
-		if !util.IsFileRecent(path, s.bfileUpdateInterval) {
-			url := fmt.Sprintf("%sA%s/b%s.txt", OeisWebsite, id, id)
-			txtpath := filepath.Join(dir, fmt.Sprintf("b%s.txt", id))
-			err := util.FetchFile(s.httpClient, url, txtpath)
-			if err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatal(err)
-			}
-			err = exec.Command("gzip", "-f", txtpath).Run()
-			if err != nil {
-				util.WriteHttpInternalServerError(w)
-				log.Fatalf("Error executing gzip: %v", err)
-			}
-		}
-		util.ServeBinary(w, req, path)
-	}
-	return http.HandlerFunc(f)
+// fetchWithMirrorFallback is the v1.Deps.Fetch implementation for
+// SequencesServer: it delegates to s.backend, so a names.gz/b-file
+// cache-miss fetch shares the same mirror list, rate limiter, and retry
+// schedule as the crawler instead of hitting OEIS independently. ctx is
+// the serving request's context, so a client that gives up (or the
+// TimeoutMiddleware deadline expiring) aborts the fetch instead of
+// leaving it to run to completion unobserved.
+func (s *SequencesServer) fetchWithMirrorFallback(ctx context.Context, httpClient *http.Client, url string, localFile string) error {
+	return s.backend.FetchURL(ctx, url, localFile)
 }
 
-func newListHandler(l *List) http.Handler {
-	f := func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		l.ServeGzip(w, req)
+func (s *SequencesServer) v1Deps() v1.Deps {
+	return v1.Deps{
+		HttpClient:            s.httpClient,
+		OeisDir:               s.oeisDir,
+		Website:               OeisWebsite,
+		SummaryUpdateInterval: s.summaryUpdateInterval,
+		BfileUpdateInterval:   s.bfileUpdateInterval,
+		Serve:                 util.ServeCompressedFile,
+		AfterSummaryFetch:     util.DecompressFile,
+		Fetch:                 s.fetchWithMirrorFallback,
+		Metrics:               s.metrics,
 	}
-	return http.HandlerFunc(f)
-}
-
-func (s *SequencesServer) SequenceHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		params := mux.Vars(req)
-		idStr := params["id"]
-		uid, err := util.NewUIDFromString(idStr)
-		if err != nil {
-			util.WriteHttpBadRequest(w)
-			return
-		}
-		seq := shared.FindSequenceById(GetIndex(s), uid)
-		if seq == nil {
-			w.WriteHeader(http.StatusNotFound)
-			return
-		}
-		util.WriteJsonResponse(w, seq)
-	})
-}
-
-func (s *SequencesServer) SequenceSearchHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
-			util.WriteHttpMethodNotAllowed(w)
-			return
-		}
-		q := req.URL.Query().Get("q")
-		limit, skip, shuffle := util.ParseLimitSkipShuffle(req, 10, 100)
-		results, total := shared.SearchSequences(GetIndex(s), q, limit, skip, shuffle)
-		resp := shared.SearchResult{
-			Total: total,
-		}
-		for _, seq := range results {
-			resp.Results = append(resp.Results, shared.SearchItem{
-				Id:       seq.Id.String(),
-				Name:     seq.Name,
-				Keywords: shared.DecodeKeywords(seq.Keywords),
-			})
-		}
-		util.WriteJsonResponse(w, resp)
-	})
 }
 
 func (s *SequencesServer) Run(port int) {
+	deps := s.v1Deps()
 	router := mux.NewRouter()
-	router.Handle("/v1/oeis/names.gz", newSummaryHandler(s, "names.gz"))
-	router.Handle("/v1/oeis/stripped.gz", newSummaryHandler(s, "stripped.gz"))
-	router.Handle("/v1/oeis/b{id:[0-9]+}.txt.gz", newBFileHandler(s))
+	router.Handle("/v1/oeis/names.gz", v1.NewSummaryHandler(deps, "names.gz"))
+	router.Handle("/v1/oeis/stripped.gz", v1.NewSummaryHandler(deps, "stripped.gz"))
+	router.Handle("/v1/oeis/b{id:[0-9]+}.txt.gz", v1.NewBFileHandler(deps))
 	for _, l := range s.lists {
-		router.Handle(fmt.Sprintf("/v1/oeis/%s.gz", l.name), newListHandler(l))
+		router.Handle(fmt.Sprintf("/v1/oeis/%s.gz", l.Name), v1.NewListHandler(l))
+		router.Handle(fmt.Sprintf("/v1/oeis/%s.delta", l.Name), v1.NewListDeltaHandler(l))
 	}
-	router.Handle("/v2/sequences/search", s.SequenceSearchHandler())
-	router.Handle("/v2/sequences/{id:[A-Z][0-9]+}", s.SequenceHandler())
+	router.Handle("/v2/sequences/search", v2.NewSequenceSearchHandler(s))
+	router.Handle("/v2/sequences/suggest", v2.NewSequenceSuggestHandler(s))
+	router.Handle("/v2/sequences/{id:[A-Z][0-9]+}", v2.NewSequenceHandler(s))
+	router.Handle("/opensearch.xml", v2.NewOpenSearchHandler(
+		"LODA Sequences",
+		"Search the LODA sequence database",
+		"https://loda-lang.org/sequences/?q={searchTerms}",
+		"https://api.loda-lang.org/v2/sequences/suggest?q={searchTerms}",
+	))
+	router.Handle("/metrics", promhttp.HandlerFor(s.metrics.Registry, promhttp.HandlerOpts{}))
 	router.NotFoundHandler = http.HandlerFunc(util.HandleNotFound)
+	router.Use(util.RequestMetricsMiddleware(s.metrics))
 
 	// Start goroutine to reset dataIndex to nil at summaryUpdateInterval
 	go func() {
@@ -244,6 +192,7 @@ func (s *SequencesServer) Run(port int) {
 			s.dataIndexMutex.Lock()
 			s.dataIndex = nil
 			s.dataIndexMutex.Unlock()
+			s.metrics.DataIndexTotal.WithLabelValues("reset").Inc()
 			log.Printf("Reset data index")
 		}
 	}()
@@ -255,6 +204,9 @@ func (s *SequencesServer) Run(port int) {
 
 func (s *SequencesServer) StopCrawler() {
 	log.Print("Stopping crawler")
+	if err := s.saveCrawlerState(); err != nil {
+		log.Printf("Error saving crawler state: %v", err)
+	}
 	s.crawlerStopped <- true
 	restartTimer := time.NewTimer(s.crawlerRestartPause)
 	go func() {
@@ -263,9 +215,23 @@ func (s *SequencesServer) StopCrawler() {
 	}()
 }
 
+// saveCrawlerState persists the crawler's current walk position and each
+// list's flush cursor (s.listCursors), so a restarted StartCrawler can
+// resume instead of starting over from a fresh Init.
+func (s *SequencesServer) saveCrawlerState() error {
+	return saveCrawlerState(s.dataDir, CrawlerState{
+		CurrentId:   s.crawler.CurrentId,
+		StepSize:    s.crawler.StepSize,
+		MaxId:       s.crawler.MaxId,
+		NumFetched:  s.crawler.NumFetched,
+		MissingIds:  s.crawler.MissingIds,
+		ListCursors: s.listCursors,
+	})
+}
+
 // filterValidKeywordsFields filters out unknown keywords from fields with key 'K'.
-func filterValidKeywordsFields(fields []Field) []Field {
-	filteredFields := make([]Field, 0, len(fields))
+func filterValidKeywordsFields(fields []crawler.Field) []crawler.Field {
+	filteredFields := make([]crawler.Field, 0, len(fields))
 	for _, field := range fields {
 		if field.Key == "K" {
 			var validKeywords []string
@@ -288,8 +254,22 @@ func filterValidKeywordsFields(fields []Field) []Field {
 }
 
 func (s *SequencesServer) StartCrawler() {
-	err := s.crawler.Init()
+	state, ok, err := loadCrawlerState(s.dataDir)
 	if err != nil {
+		log.Printf("Error loading crawler state, starting fresh: %v", err)
+		ok = false
+	}
+	if ok {
+		log.Printf("Resuming crawler from saved state: current ID %d, %d fetched so far", state.CurrentId, state.NumFetched)
+		s.crawler.CurrentId = state.CurrentId
+		s.crawler.StepSize = state.StepSize
+		s.crawler.MaxId = state.MaxId
+		s.crawler.NumFetched = state.NumFetched
+		s.crawler.MissingIds = state.MissingIds
+		if state.ListCursors != nil {
+			s.listCursors = state.ListCursors
+		}
+	} else if err := s.crawler.Init(context.Background()); err != nil {
 		log.Printf("Error initializing crawler: %v", err)
 		return
 	}
@@ -309,59 +289,85 @@ func (s *SequencesServer) StartCrawler() {
 
 // handleCrawlerTick contains the logic for each fetchTicker tick in StartCrawler
 func (s *SequencesServer) handleCrawlerTick() {
-	if s.crawler.numFetched > 0 {
+	ctx := context.Background()
+	if s.crawler.NumFetched > 0 {
 		// Regularly flush the lists
-		if s.crawler.numFetched%s.crawlerFlushInterval == 0 {
+		if s.crawler.NumFetched%s.crawlerFlushInterval == 0 {
 			for _, l := range s.lists {
-				deduplicate := l.name == "offsets"
-				err := l.Flush(deduplicate)
+				err := l.Flush(ctx)
 				if err != nil {
-					log.Printf("Error flushing list %s: %v", l.name, err)
+					log.Printf("Error flushing list %s: %v", l.Name, err)
+					s.events.publish(CrawlerEvent{Type: CrawlerEventError, Time: time.Now(), List: l.Name, Message: err.Error()})
 					s.StopCrawler()
 					continue
 				}
+				s.listCursors[l.Name] = s.crawler.NumFetched
+				s.metrics.CrawlerFlushTotal.Inc()
+				s.events.publish(CrawlerEvent{Type: CrawlerEventFlush, Time: time.Now(), List: l.Name})
+			}
+			if err := s.saveCrawlerState(); err != nil {
+				log.Printf("Error saving crawler state: %v", err)
 			}
 		}
 		// Regularly re-initialize the crawler
-		if s.crawler.numFetched%s.crawlerReinitInterval == 0 {
-			err := s.crawler.Init()
+		if s.crawler.NumFetched%s.crawlerReinitInterval == 0 {
+			err := s.crawler.Init(ctx)
 			if err != nil {
 				log.Printf("Error re-initializing crawler: %v", err)
+				s.events.publish(CrawlerEvent{Type: CrawlerEventError, Time: time.Now(), Message: err.Error()})
 				s.StopCrawler()
 				return
 			}
+			s.metrics.CrawlerReinitTotal.Inc()
+			s.events.publish(CrawlerEvent{Type: CrawlerEventReinit, Time: time.Now()})
 		}
 	}
-	if s.crawler.numFetched%s.crawlerIdsCacheSize == 0 && rand.Float64() < s.crawlerIdsFetchRatio {
+	if s.crawler.NumFetched%s.crawlerIdsCacheSize == 0 && rand.Float64() < s.crawlerIdsFetchRatio {
 		// Find the missing ids
 		for _, l := range s.lists {
-			if l.name == "offsets" {
-				ids, _, err := l.FindMissingIds(s.crawler.maxId, s.crawlerIdsCacheSize)
+			if l.Name == "offsets" {
+				ids, _, err := l.FindMissingIds(ctx, s.crawler.MaxId, s.crawlerIdsCacheSize)
 				if err != nil {
 					s.StopCrawler()
 					return
 				}
-				s.crawler.missingIds = ids
+				s.crawler.MissingIds = ids
+				s.metrics.CrawlerIdsRefillTotal.Inc()
 				break
 			}
 		}
 	}
 	// Fetch the next sequence
-	fields, _, err := s.crawler.FetchNext()
+	fields, _, err := s.crawler.FetchNext(ctx)
 	if err != nil {
 		log.Printf("Error fetching fields: %v", err)
+		s.events.publish(CrawlerEvent{Type: CrawlerEventError, Time: time.Now(), SeqId: s.crawler.CurrentId, Message: err.Error()})
 		s.StopCrawler()
 		return
 	}
+	s.metrics.CrawlerFetchedTotal.Inc()
+	s.metrics.CrawlerMaxId.Set(float64(s.crawler.MaxId))
+	s.events.publish(CrawlerEvent{Type: CrawlerEventFetch, Time: time.Now(), SeqId: s.crawler.CurrentId})
 	// Update the lists with the new fields
 	filteredFields := filterValidKeywordsFields(fields)
 	for _, l := range s.lists {
 		l.Update(filteredFields)
+		matched := 0
+		for _, field := range filteredFields {
+			if field.Key == l.Key() {
+				matched++
+			}
+		}
+		s.metrics.ListUpdatesTotal.WithLabelValues(l.Name).Add(float64(matched))
+		s.metrics.ListSize.WithLabelValues(l.Name).Set(float64(l.Len()))
 	}
 }
 
 func main() {
 	setup := cmd.GetSetup("sequences")
+	if err := setup.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	util.MustDirExist(setup.DataDir)
 	oeisDir := filepath.Join(setup.DataDir, "seqs", "oeis")
 	os.MkdirAll(oeisDir, os.ModePerm)
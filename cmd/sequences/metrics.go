@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors published by a SequencesServer at
+// /metrics. It implements util.HTTPMetrics (requests per route/status,
+// bytes served) and v1.FetchMetrics (upstream fetch outcomes, cached-file
+// age), so both the request middleware in Run and the v1 handlers serving
+// names/stripped/b-files can report into it without either package
+// importing Prometheus itself. It also tracks the crawler and dataIndex
+// cache, so operators can alert on a stalled crawl or a broken OEIS
+// upstream without scraping logs.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	RequestsTotal    *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	BytesServedTotal *prometheus.CounterVec
+	FetchTotal       *prometheus.CounterVec
+	FetchDuration    prometheus.Histogram
+	CacheAgeSeconds  prometheus.Histogram
+
+	DataIndexTotal *prometheus.CounterVec
+
+	CrawlerFetchedTotal   prometheus.Counter
+	CrawlerReinitTotal    prometheus.Counter
+	CrawlerFlushTotal     prometheus.Counter
+	CrawlerIdsRefillTotal prometheus.Counter
+	CrawlerMaxId          prometheus.Gauge
+	ListUpdatesTotal      *prometheus.CounterVec
+	ListSize              *prometheus.GaugeVec
+}
+
+// NewMetrics creates and registers the Prometheus collectors used by a
+// SequencesServer.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_sequences_requests_total",
+			Help: "Total number of requests, by route, method, and status code.",
+		}, []string{"route", "method", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "loda_sequences_request_duration_seconds",
+			Help:    "Duration of requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		BytesServedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_sequences_bytes_served_total",
+			Help: "Total bytes written to responses, by route.",
+		}, []string{"route"}),
+		FetchTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_sequences_fetch_total",
+			Help: "Total upstream fetch attempts, by outcome (hit, refreshed, failed).",
+		}, []string{"outcome"}),
+		FetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "loda_sequences_fetch_duration_seconds",
+			Help:    "Duration of upstream fetch attempts that were not served from a fresh cache.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		CacheAgeSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "loda_sequences_cache_age_seconds",
+			Help:    "Age of the cached file being served, measured at serve time.",
+			Buckets: prometheus.ExponentialBuckets(60, 4, 8), // 1m .. ~71 days
+		}),
+		DataIndexTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_sequences_data_index_total",
+			Help: "Total DataIndex lookups, by outcome (hit, miss, reset).",
+		}, []string{"outcome"}),
+		CrawlerFetchedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loda_sequences_crawler_fetched_total",
+			Help: "Total number of sequences fetched by the crawler.",
+		}),
+		CrawlerReinitTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loda_sequences_crawler_reinit_total",
+			Help: "Total number of crawler re-initializations.",
+		}),
+		CrawlerFlushTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loda_sequences_crawler_flush_total",
+			Help: "Total number of crawler-triggered list flushes.",
+		}),
+		CrawlerIdsRefillTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loda_sequences_crawler_ids_refill_total",
+			Help: "Total number of times the missing-ids cache was refilled.",
+		}),
+		CrawlerMaxId: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_sequences_crawler_max_id",
+			Help: "The highest sequence ID known to the crawler.",
+		}),
+		ListUpdatesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_sequences_list_updates_total",
+			Help: "Total number of fields buffered into a list, by list name.",
+		}, []string{"list"}),
+		ListSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "loda_sequences_list_buffered_fields",
+			Help: "Number of fields currently buffered in a list, awaiting flush.",
+		}, []string{"list"}),
+	}
+	m.Registry.MustRegister(
+		m.RequestsTotal,
+		m.RequestDuration,
+		m.BytesServedTotal,
+		m.FetchTotal,
+		m.FetchDuration,
+		m.CacheAgeSeconds,
+		m.DataIndexTotal,
+		m.CrawlerFetchedTotal,
+		m.CrawlerReinitTotal,
+		m.CrawlerFlushTotal,
+		m.CrawlerIdsRefillTotal,
+		m.CrawlerMaxId,
+		m.ListUpdatesTotal,
+		m.ListSize,
+	)
+	return m
+}
+
+// ObserveRequest implements util.HTTPMetrics.
+func (m *Metrics) ObserveRequest(route, method string, status int, bytes int64, duration time.Duration) {
+	m.RequestsTotal.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.RequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+	m.BytesServedTotal.WithLabelValues(route).Add(float64(bytes))
+}
+
+// ObserveFetch implements v1.FetchMetrics.
+func (m *Metrics) ObserveFetch(outcome string, duration time.Duration) {
+	m.FetchTotal.WithLabelValues(outcome).Inc()
+	if duration > 0 {
+		m.FetchDuration.Observe(duration.Seconds())
+	}
+}
+
+// ObserveCacheAge implements v1.FetchMetrics.
+func (m *Metrics) ObserveCacheAge(age time.Duration) {
+	m.CacheAgeSeconds.Observe(age.Seconds())
+}
@@ -2,38 +2,274 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/loda-lang/loda-api/util"
 )
 
+// InfluxDBSetup configures the optional InfluxDB push target, loaded from
+// the [influxdb] table in setup.txt.
+type InfluxDBSetup struct {
+	Host string
+	Auth string
+}
+
+// LogSetup configures logging, loaded from the [log] table in setup.txt.
+type LogSetup struct {
+	Dir   string
+	Level string
+}
+
+// CPUHoursSetup configures the /v1/cpuhours WAL, loaded from the
+// [cpuhours] table in setup.txt.
+type CPUHoursSetup struct {
+	WALSync    string
+	HMACSecret string
+}
+
+// SubmissionsSetup configures the /v2/submissions endpoints, loaded from
+// the [submissions] table in setup.txt.
+type SubmissionsSetup struct {
+	// AnonymousMode lets submissions through without a bearer token,
+	// keyed off the client-supplied submitter field. Defaults to true so
+	// existing deployments keep working until they opt into token auth.
+	AnonymousMode bool
+
+	// AuthURL, if set, is an external service consulted before a
+	// submission is stored; see cmd/submissions/preauth.go. Leaving it
+	// empty disables pre-authorization entirely.
+	AuthURL string
+
+	// AuthFailOpen controls what happens when AuthURL can't be reached or
+	// returns garbage: true lets the submission through, false rejects it.
+	// Defaults to false, since a pre-authorization check that can be
+	// silently bypassed by taking down the auth service isn't much of a
+	// check.
+	AuthFailOpen bool
+
+	// RateLimitPerMinute bounds how many /v2/submissions POSTs a single
+	// authenticated submitter may make per minute. Anonymous POSTs share a
+	// much stricter bucket, keyed by client IP, at a tenth of this rate.
+	// Defaults to 60.
+	RateLimitPerMinute int
+}
+
+// OeisSetup configures the OeisServer's upstream HTTP client, loaded from
+// the [oeis] table in setup.txt.
+type OeisSetup struct {
+	// InsecureSkipVerify disables TLS certificate verification for
+	// requests to oeis.org. Defaults to false, since oeis.org has a valid
+	// certificate; this exists only for testing against a mirror that
+	// doesn't.
+	InsecureSkipVerify bool
+}
+
+// LodaSetup holds the runtime configuration read by GetSetup. DataDir
+// always comes from the command-line argument, never from the file.
 type LodaSetup struct {
 	DataDir        string
 	UpdateInterval time.Duration
-	InfluxDbHost   string
-	InfluxDbAuth   string
+	InfluxDB       InfluxDBSetup
+	Log            LogSetup
+	CPUHours       CPUHoursSetup
+	Submissions    SubmissionsSetup
+	Oeis           OeisSetup
+
+	// RequestTimeout bounds how long an HTTP handler wrapped in
+	// util.TimeoutMiddleware may run before its context is cancelled and
+	// the client gets a timeout response.
+	RequestTimeout time.Duration
+
+	// RefreshDrainTimeout bounds how long a single RefreshQueue.DequeueAll
+	// call may run before it stops scanning and leaves the rest of the
+	// queue for the next call.
+	RefreshDrainTimeout time.Duration
+
+	// CrawlerFetchTimeout bounds how long a single OEIS fetch may run
+	// before its request context is cancelled.
+	CrawlerFetchTimeout time.Duration
+
+	// problems accumulates every unknown key and type mismatch found while
+	// parsing setup.txt as TOML, for Validate to report together. It stays
+	// empty when setup.txt is the legacy key=value format, since that
+	// format has no concept of unknown keys or typed fields.
+	problems []string
 }
 
+// tomlSetup mirrors LodaSetup's shape for decoding, but every leaf is
+// interface{} so toml.Decode never fails on a type mismatch; parseTOML
+// type-checks each field itself so it can collect every mismatch instead
+// of stopping at the first one.
+type tomlSetup struct {
+	UpdateInterval      interface{} `toml:"update_interval"`
+	RequestTimeout      interface{} `toml:"request_timeout"`
+	RefreshDrainTimeout interface{} `toml:"refresh_drain_timeout"`
+	CrawlerFetchTimeout interface{} `toml:"crawler_fetch_timeout"`
+	InfluxDB            struct {
+		Host interface{} `toml:"host"`
+		Auth interface{} `toml:"auth"`
+	} `toml:"influxdb"`
+	Log struct {
+		Dir   interface{} `toml:"dir"`
+		Level interface{} `toml:"level"`
+	} `toml:"log"`
+	CPUHours struct {
+		WALSync    interface{} `toml:"wal_sync"`
+		HMACSecret interface{} `toml:"hmac_secret"`
+	} `toml:"cpuhours"`
+	Submissions struct {
+		AnonymousMode      interface{} `toml:"anonymous_mode"`
+		AuthURL            interface{} `toml:"auth_url"`
+		AuthFailOpen       interface{} `toml:"auth_fail_open"`
+		RateLimitPerMinute interface{} `toml:"rate_limit_per_minute"`
+	} `toml:"submissions"`
+	Oeis struct {
+		InsecureSkipVerify interface{} `toml:"insecure_skip_verify"`
+	} `toml:"oeis"`
+}
+
+// GetSetup reads dataDir/setup.txt (the sole command-line argument) into a
+// LodaSetup. setup.txt is parsed as TOML; if it isn't valid TOML at all, it
+// falls back to the legacy line-based key=value format so deployments that
+// haven't migrated yet keep working. Either way, LODA_* environment
+// variables are then applied on top, and the resulting log directory (from
+// either source) switches logging from stderr to a file under app's name.
 func GetSetup(app string) LodaSetup {
 	if len(os.Args) != 2 {
 		log.Fatal("Invalid command-line arguments. Please pass the data directory as argument.")
 	}
 	dataDir := os.Args[1]
 	setup := LodaSetup{
-		DataDir:        dataDir,
-		UpdateInterval: 24 * time.Hour, // default value
+		DataDir:             dataDir,
+		UpdateInterval:      24 * time.Hour,                                                // default value
+		CPUHours:            CPUHoursSetup{WALSync: "interval"},                            // default value
+		Submissions:         SubmissionsSetup{AnonymousMode: true, RateLimitPerMinute: 60}, // default value
+		RequestTimeout:      30 * time.Second,                                              // default value
+		RefreshDrainTimeout: 10 * time.Second,                                              // default value
+		CrawlerFetchTimeout: 30 * time.Second,                                              // default value
 	}
 	setupPath := filepath.Join(dataDir, "setup.txt")
-	file, err := os.Open(setupPath)
+	data, err := os.ReadFile(setupPath)
 	if err != nil {
 		log.Fatalf("Failed to open: %v", err)
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
+	if err := setup.parseTOML(data); err != nil {
+		log.Printf("%s is not valid TOML, falling back to legacy key=value parsing: %v", setupPath, err)
+		setup.parseLegacy(data)
+	}
+	setup.applyEnvOverrides()
+	if setup.Log.Dir != "" {
+		util.InitLog(filepath.Join(setup.Log.Dir, app))
+	}
+	return setup
+}
+
+// parseTOML decodes data as TOML into setup, collecting every unknown key
+// and type mismatch into setup.problems instead of stopping at the first
+// one. It returns a non-nil error only when data isn't valid TOML at all,
+// which callers treat as a signal to fall back to the legacy parser.
+func (s *LodaSetup) parseTOML(data []byte) error {
+	var raw tomlSetup
+	meta, err := toml.Decode(string(data), &raw)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, key := range meta.Undecoded() {
+		problems = append(problems, fmt.Sprintf("unknown config key: %s", key.String()))
+	}
+	durationField(&s.UpdateInterval, raw.UpdateInterval, "update_interval", &problems)
+	durationField(&s.RequestTimeout, raw.RequestTimeout, "request_timeout", &problems)
+	durationField(&s.RefreshDrainTimeout, raw.RefreshDrainTimeout, "refresh_drain_timeout", &problems)
+	durationField(&s.CrawlerFetchTimeout, raw.CrawlerFetchTimeout, "crawler_fetch_timeout", &problems)
+	stringField(&s.InfluxDB.Host, raw.InfluxDB.Host, "influxdb.host", &problems)
+	stringField(&s.InfluxDB.Auth, raw.InfluxDB.Auth, "influxdb.auth", &problems)
+	stringField(&s.Log.Dir, raw.Log.Dir, "log.dir", &problems)
+	stringField(&s.Log.Level, raw.Log.Level, "log.level", &problems)
+	stringField(&s.CPUHours.WALSync, raw.CPUHours.WALSync, "cpuhours.wal_sync", &problems)
+	stringField(&s.CPUHours.HMACSecret, raw.CPUHours.HMACSecret, "cpuhours.hmac_secret", &problems)
+	boolField(&s.Submissions.AnonymousMode, raw.Submissions.AnonymousMode, "submissions.anonymous_mode", &problems)
+	stringField(&s.Submissions.AuthURL, raw.Submissions.AuthURL, "submissions.auth_url", &problems)
+	boolField(&s.Submissions.AuthFailOpen, raw.Submissions.AuthFailOpen, "submissions.auth_fail_open", &problems)
+	intField(&s.Submissions.RateLimitPerMinute, raw.Submissions.RateLimitPerMinute, "submissions.rate_limit_per_minute", &problems)
+	boolField(&s.Oeis.InsecureSkipVerify, raw.Oeis.InsecureSkipVerify, "oeis.insecure_skip_verify", &problems)
+	s.problems = problems
+	return nil
+}
+
+// stringField assigns raw to *dst if raw is a string. raw == nil means the
+// key was absent, so *dst is left at its default; any other type is a
+// mismatch, recorded in *problems without touching *dst.
+func stringField(dst *string, raw interface{}, path string, problems *[]string) {
+	if raw == nil {
+		return
+	}
+	value, ok := raw.(string)
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s: expected a string, got %T", path, raw))
+		return
+	}
+	*dst = value
+}
+
+// durationField is like stringField, but additionally parses the string as
+// a time.Duration (e.g. "24h"), recording a problem if that fails too.
+func durationField(dst *time.Duration, raw interface{}, path string, problems *[]string) {
+	if raw == nil {
+		return
+	}
+	value, ok := raw.(string)
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s: expected a duration string, got %T", path, raw))
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		*problems = append(*problems, fmt.Sprintf("%s: invalid duration %q: %v", path, value, err))
+		return
+	}
+	*dst = d
+}
+
+// boolField is like stringField, but for a bool leaf.
+func boolField(dst *bool, raw interface{}, path string, problems *[]string) {
+	if raw == nil {
+		return
+	}
+	value, ok := raw.(bool)
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s: expected a bool, got %T", path, raw))
+		return
+	}
+	*dst = value
+}
+
+// intField is like stringField, but for an integer leaf. TOML decodes bare
+// integers as int64 regardless of the Go field's width.
+func intField(dst *int, raw interface{}, path string, problems *[]string) {
+	if raw == nil {
+		return
+	}
+	value, ok := raw.(int64)
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s: expected an integer, got %T", path, raw))
+		return
+	}
+	*dst = int(value)
+}
+
+// parseLegacy parses data as the pre-TOML setup.txt format: one KEY=value
+// pair per line, unknown keys silently ignored.
+func (s *LodaSetup) parseLegacy(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	scanner.Split(bufio.ScanLines)
 	for scanner.Scan() {
 		entry := strings.Split(scanner.Text(), "=")
@@ -42,21 +278,151 @@ func GetSetup(app string) LodaSetup {
 		}
 		key := strings.TrimSpace(entry[0])
 		value := strings.TrimSpace(entry[1])
-		switch {
-		case key == "LODA_LOG_DIR":
-			util.InitLog(filepath.Join(value, app))
-		case key == "LODA_UPDATE_INTERVAL":
+		switch key {
+		case "LODA_LOG_DIR":
+			s.Log.Dir = value
+		case "LODA_LOG_LEVEL":
+			s.Log.Level = value
+		case "LODA_UPDATE_INTERVAL":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Printf("Invalid duration: %s", value)
+			} else {
+				s.UpdateInterval = d
+			}
+		case "LODA_INFLUXDB_HOST":
+			s.InfluxDB.Host = value
+		case "LODA_INFLUXDB_AUTH":
+			s.InfluxDB.Auth = value
+		case "LODA_CPU_HOURS_WAL_SYNC":
+			s.CPUHours.WALSync = value
+		case "LODA_CPU_HOURS_HMAC_SECRET":
+			s.CPUHours.HMACSecret = value
+		case "LODA_SUBMISSIONS_ANONYMOUS_MODE":
+			s.Submissions.AnonymousMode = value == "true"
+		case "LODA_SUBMISSIONS_AUTH_URL":
+			s.Submissions.AuthURL = value
+		case "LODA_SUBMISSIONS_AUTH_FAIL_OPEN":
+			s.Submissions.AuthFailOpen = value == "true"
+		case "LODA_SUBMISSIONS_RATE_LIMIT_PER_MINUTE":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				log.Printf("Invalid integer: %s", value)
+			} else {
+				s.Submissions.RateLimitPerMinute = n
+			}
+		case "LODA_OEIS_INSECURE_SKIP_VERIFY":
+			s.Oeis.InsecureSkipVerify = value == "true"
+		case "LODA_REQUEST_TIMEOUT":
 			d, err := time.ParseDuration(value)
 			if err != nil {
 				log.Printf("Invalid duration: %s", value)
 			} else {
-				setup.UpdateInterval = d
+				s.RequestTimeout = d
+			}
+		case "LODA_REFRESH_DRAIN_TIMEOUT":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Printf("Invalid duration: %s", value)
+			} else {
+				s.RefreshDrainTimeout = d
+			}
+		case "LODA_CRAWLER_FETCH_TIMEOUT":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Printf("Invalid duration: %s", value)
+			} else {
+				s.CrawlerFetchTimeout = d
 			}
-		case key == "LODA_INFLUXDB_HOST":
-			setup.InfluxDbHost = value
-		case key == "LODA_INFLUXDB_AUTH":
-			setup.InfluxDbAuth = value
 		}
 	}
-	return setup
+}
+
+// applyEnvOverrides lets LODA_* environment variables override whatever
+// setup.txt set, using the same keys as the legacy format. It runs after
+// both parseTOML and parseLegacy, so an env var always wins over the file.
+func (s *LodaSetup) applyEnvOverrides() {
+	if v := os.Getenv("LODA_LOG_DIR"); v != "" {
+		s.Log.Dir = v
+	}
+	if v := os.Getenv("LODA_LOG_LEVEL"); v != "" {
+		s.Log.Level = v
+	}
+	if v := os.Getenv("LODA_UPDATE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("Invalid LODA_UPDATE_INTERVAL: %s", v)
+		} else {
+			s.UpdateInterval = d
+		}
+	}
+	if v := os.Getenv("LODA_INFLUXDB_HOST"); v != "" {
+		s.InfluxDB.Host = v
+	}
+	if v := os.Getenv("LODA_INFLUXDB_AUTH"); v != "" {
+		s.InfluxDB.Auth = v
+	}
+	if v := os.Getenv("LODA_CPU_HOURS_WAL_SYNC"); v != "" {
+		s.CPUHours.WALSync = v
+	}
+	if v := os.Getenv("LODA_CPU_HOURS_HMAC_SECRET"); v != "" {
+		s.CPUHours.HMACSecret = v
+	}
+	if v := os.Getenv("LODA_SUBMISSIONS_ANONYMOUS_MODE"); v != "" {
+		s.Submissions.AnonymousMode = v == "true"
+	}
+	if v := os.Getenv("LODA_SUBMISSIONS_AUTH_URL"); v != "" {
+		s.Submissions.AuthURL = v
+	}
+	if v := os.Getenv("LODA_SUBMISSIONS_AUTH_FAIL_OPEN"); v != "" {
+		s.Submissions.AuthFailOpen = v == "true"
+	}
+	if v := os.Getenv("LODA_SUBMISSIONS_RATE_LIMIT_PER_MINUTE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Printf("Invalid LODA_SUBMISSIONS_RATE_LIMIT_PER_MINUTE: %s", v)
+		} else {
+			s.Submissions.RateLimitPerMinute = n
+		}
+	}
+	if v := os.Getenv("LODA_OEIS_INSECURE_SKIP_VERIFY"); v != "" {
+		s.Oeis.InsecureSkipVerify = v == "true"
+	}
+	if v := os.Getenv("LODA_REQUEST_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("Invalid LODA_REQUEST_TIMEOUT: %s", v)
+		} else {
+			s.RequestTimeout = d
+		}
+	}
+	if v := os.Getenv("LODA_REFRESH_DRAIN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("Invalid LODA_REFRESH_DRAIN_TIMEOUT: %s", v)
+		} else {
+			s.RefreshDrainTimeout = d
+		}
+	}
+	if v := os.Getenv("LODA_CRAWLER_FETCH_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("Invalid LODA_CRAWLER_FETCH_TIMEOUT: %s", v)
+		} else {
+			s.CrawlerFetchTimeout = d
+		}
+	}
+}
+
+// Validate reports every unknown key and type mismatch found while parsing
+// setup.txt as TOML, joined into a single error, so a misconfigured
+// deployment can fix everything in one pass instead of one restart per
+// mistake. Callers decide what to do with it, e.g. log.Fatal it during
+// startup or just log.Print it and run with defaults. It returns nil if
+// setup.txt parsed cleanly, or was the legacy key=value format.
+func (s *LodaSetup) Validate() error {
+	if len(s.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid setup.txt:\n  %s", strings.Join(s.problems, "\n  "))
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// cpuHoursSignatureHeader carries the HMAC-SHA256 of the raw request
+	// body, hex-encoded and prefixed with "sha256=".
+	cpuHoursSignatureHeader = "X-LODA-Signature"
+	// cpuHoursTimestampHeader carries the Unix timestamp (seconds) the
+	// request was signed at, checked against cpuHoursSignatureWindow to
+	// bound how long a captured request can be replayed.
+	cpuHoursTimestampHeader = "X-LODA-Timestamp"
+	cpuHoursSignatureWindow = 5 * time.Minute
+
+	// cpuHoursRatePerMinute and cpuHoursRateBurst bound how often a single
+	// source IP may call newCpuHourHandler.
+	cpuHoursRatePerMinute = 60
+	cpuHoursRateBurst     = 60
+
+	// cpuHoursDailyCapPerSource bounds the cpuHours a single source IP may
+	// accumulate per UTC day, so a single compromised or misbehaving key
+	// cannot inflate the totals without limit.
+	cpuHoursDailyCapPerSource = 10000
+)
+
+// CPUHoursAuth guards newCpuHourHandler: it verifies the HMAC request
+// signature and replay window, and rate-limits and quota-caps submissions
+// per source IP. If no secret is configured, signature verification is
+// skipped (e.g. for local/dev setups), mirroring how InfluxDbHost is an
+// optional sink elsewhere in StatsServer.
+type CPUHoursAuth struct {
+	secret []byte
+
+	mutex    sync.Mutex
+	limiters map[string]*rate.Limiter
+	dayUTC   string
+	dayUsage map[string]int
+}
+
+func NewCPUHoursAuth(secret string) *CPUHoursAuth {
+	return &CPUHoursAuth{
+		secret:   []byte(secret),
+		limiters: make(map[string]*rate.Limiter),
+		dayUsage: make(map[string]int),
+	}
+}
+
+// VerifySignature checks that sigHeader is the hex-encoded HMAC-SHA256 of
+// body under the configured secret, and that tsHeader is within
+// cpuHoursSignatureWindow of now. A missing or empty secret disables the
+// check entirely.
+func (a *CPUHoursAuth) VerifySignature(body []byte, sigHeader, tsHeader string) error {
+	if len(a.secret) == 0 {
+		return nil
+	}
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid %s header", cpuHoursTimestampHeader)
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age < 0 {
+		age = -age
+	}
+	if age > cpuHoursSignatureWindow {
+		return fmt.Errorf("%s outside the %s replay window", cpuHoursTimestampHeader, cpuHoursSignatureWindow)
+	}
+	const prefix = "sha256="
+	if !strings.HasPrefix(sigHeader, prefix) {
+		return fmt.Errorf("missing or malformed %s header", cpuHoursSignatureHeader)
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sigHeader, prefix))
+	if err != nil {
+		return fmt.Errorf("malformed %s header: %w", cpuHoursSignatureHeader, err)
+	}
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write(body)
+	want := mac.Sum(nil)
+	if !hmac.Equal(got, want) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// Allow reports whether source may make another request right now, per its
+// 60 req/min token bucket. A new bucket is created on first use.
+func (a *CPUHoursAuth) Allow(source string) bool {
+	a.mutex.Lock()
+	limiter, ok := a.limiters[source]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(cpuHoursRatePerMinute)/60, cpuHoursRateBurst)
+		a.limiters[source] = limiter
+	}
+	a.mutex.Unlock()
+	return limiter.Allow()
+}
+
+// ReserveDailyCap reports whether source may accumulate another hours worth
+// of cpuHours without exceeding cpuHoursDailyCapPerSource for the current
+// UTC day, and if so records it. The per-source counters reset whenever the
+// UTC date rolls over.
+func (a *CPUHoursAuth) ReserveDailyCap(source string, hours int) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	today := time.Now().UTC().Format("2006-01-02")
+	if today != a.dayUTC {
+		a.dayUTC = today
+		a.dayUsage = make(map[string]int)
+	}
+	if a.dayUsage[source]+hours > cpuHoursDailyCapPerSource {
+		return false
+	}
+	a.dayUsage[source] += hours
+	return true
+}
+
+// sourceIP extracts the client IP from req.RemoteAddr, stripping the port.
+func sourceIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCPUHoursAuth_VerifySignature(t *testing.T) {
+	auth := NewCPUHoursAuth("s3cret")
+	body := []byte(`{"platform":"linux","version":"v1.2","cpuHours":3}`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	assert.NoError(t, auth.VerifySignature(body, sign("s3cret", body), ts))
+	assert.Error(t, auth.VerifySignature(body, sign("wrong", body), ts))
+	assert.Error(t, auth.VerifySignature(body, "not-a-signature", ts))
+	assert.Error(t, auth.VerifySignature(body, sign("s3cret", body), "not-a-timestamp"))
+}
+
+func TestCPUHoursAuth_VerifySignature_ReplayWindow(t *testing.T) {
+	auth := NewCPUHoursAuth("s3cret")
+	body := []byte(`{"platform":"linux","version":"v1.2","cpuHours":3}`)
+	staleTs := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	assert.Error(t, auth.VerifySignature(body, sign("s3cret", body), staleTs))
+}
+
+func TestCPUHoursAuth_VerifySignature_NoSecretDisablesCheck(t *testing.T) {
+	auth := NewCPUHoursAuth("")
+	body := []byte(`{"platform":"linux"}`)
+	assert.NoError(t, auth.VerifySignature(body, "", ""))
+}
+
+func TestCPUHoursAuth_Allow(t *testing.T) {
+	auth := NewCPUHoursAuth("")
+	for i := 0; i < cpuHoursRateBurst; i++ {
+		assert.True(t, auth.Allow("1.2.3.4"), "request %d should be within burst", i)
+	}
+	assert.False(t, auth.Allow("1.2.3.4"), "burst exceeded")
+	assert.True(t, auth.Allow("5.6.7.8"), "a different source has its own bucket")
+}
+
+func TestCPUHoursAuth_ReserveDailyCap(t *testing.T) {
+	auth := NewCPUHoursAuth("")
+	assert.True(t, auth.ReserveDailyCap("1.2.3.4", cpuHoursDailyCapPerSource))
+	assert.False(t, auth.ReserveDailyCap("1.2.3.4", 1), "cap already exhausted for today")
+	assert.True(t, auth.ReserveDailyCap("5.6.7.8", 1), "a different source has its own cap")
+}
+
+func TestSourceIP(t *testing.T) {
+	cases := map[string]string{
+		"1.2.3.4:5678":            "1.2.3.4",
+		"[2001:db8::1]:5678":      "2001:db8::1",
+		"not-a-valid-remote-addr": "not-a-valid-remote-addr",
+	}
+	for remoteAddr, want := range cases {
+		req := httptest.NewRequest("POST", "/v1/cpuhours", nil)
+		req.RemoteAddr = remoteAddr
+		assert.Equal(t, want, sourceIP(req), "remoteAddr=%q", remoteAddr)
+	}
+}
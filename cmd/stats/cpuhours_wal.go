@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CPUHoursWALSyncPolicy controls how aggressively CPUHoursWAL.Append fsyncs
+// the WAL file, trading durability for throughput.
+type CPUHoursWALSyncPolicy string
+
+const (
+	// WALSyncAlways fsyncs after every append: a crash loses at most the
+	// record currently being written.
+	WALSyncAlways CPUHoursWALSyncPolicy = "sync"
+	// WALSyncInterval fsyncs at most once per walSyncInterval.
+	WALSyncInterval CPUHoursWALSyncPolicy = "interval"
+	// WALSyncNone never calls Sync explicitly, relying on the OS to flush
+	// eventually; fastest, but a crash or power loss can lose more records.
+	WALSyncNone CPUHoursWALSyncPolicy = "none"
+)
+
+const (
+	CPUHoursWALFile = "cpuhours.wal"
+
+	// walSyncInterval is how often Append fsyncs under WALSyncInterval.
+	walSyncInterval = 1 * time.Second
+)
+
+// cpuHoursWALRecord is a single accepted CPU-hour submission, as appended to
+// the WAL by newCpuHourHandler.
+type cpuHoursWALRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Platform  string    `json:"platform"`
+	Version   string    `json:"version"`
+	Hours     int       `json:"hours"`
+}
+
+// CPUHoursWAL is an append-only, newline-delimited JSON log of accepted
+// CPU-hour submissions, kept under dataDir/stats/cpuhours.wal. It lets
+// StatsServer rebuild cpuHoursByPlatform on startup instead of silently
+// dropping whatever publishMetrics hasn't yet pushed to InfluxDB.
+type CPUHoursWAL struct {
+	path       string
+	syncPolicy CPUHoursWALSyncPolicy
+
+	mutex      sync.Mutex
+	file       *os.File
+	lastSynced time.Time
+}
+
+func NewCPUHoursWAL(dataDir string, syncPolicy CPUHoursWALSyncPolicy) *CPUHoursWAL {
+	return &CPUHoursWAL{
+		path:       filepath.Join(dataDir, "stats", CPUHoursWALFile),
+		syncPolicy: syncPolicy,
+	}
+}
+
+// Append writes a record for the given CPU-hour submission, syncing it to
+// disk per w.syncPolicy.
+func (w *CPUHoursWAL) Append(platform, version string, hours int) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	payload, err := json.Marshal(cpuHoursWALRecord{
+		Timestamp: time.Now(),
+		Platform:  platform,
+		Version:   version,
+		Hours:     hours,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal CPU-hours WAL record: %w", err)
+	}
+	if _, err := w.file.Write(append(payload, '\n')); err != nil {
+		return fmt.Errorf("cannot write CPU-hours WAL record: %w", err)
+	}
+	switch w.syncPolicy {
+	case WALSyncAlways:
+		return w.file.Sync()
+	case WALSyncInterval:
+		if time.Since(w.lastSynced) >= walSyncInterval {
+			if err := w.file.Sync(); err != nil {
+				return fmt.Errorf("cannot sync CPU-hours WAL: %w", err)
+			}
+			w.lastSynced = time.Now()
+		}
+	}
+	return nil
+}
+
+// open opens (or creates) the WAL file for appending. Caller must hold mutex.
+func (w *CPUHoursWAL) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), os.ModePerm); err != nil {
+		return fmt.Errorf("cannot create CPU-hours WAL directory: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open CPU-hours WAL: %w", err)
+	}
+	w.file = f
+	w.lastSynced = time.Now()
+	return nil
+}
+
+// Load replays the WAL, returning the platform -> version -> hours totals it
+// records. A torn trailing record (e.g. from a crash mid-append) is logged
+// and ignored.
+func (w *CPUHoursWAL) Load() (map[string]map[string]int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	totals := make(map[string]map[string]int)
+	f, err := os.Open(w.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return totals, nil
+		}
+		return totals, fmt.Errorf("cannot open CPU-hours WAL: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record cpuHoursWALRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("Skipping corrupt CPU-hours WAL record: %v", err)
+			continue
+		}
+		if totals[record.Platform] == nil {
+			totals[record.Platform] = make(map[string]int)
+		}
+		totals[record.Platform][record.Version] += record.Hours
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error scanning CPU-hours WAL: %v", err)
+	}
+	return totals, nil
+}
+
+// Rotate atomically truncates the WAL to empty via a temp-file-plus-rename
+// swap, so a crash mid-rotation cannot leave a half-truncated file in place.
+// Callers call this once publishMetrics has durably pushed the WAL's
+// contents elsewhere.
+func (w *CPUHoursWAL) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("cannot close CPU-hours WAL before rotation: %w", err)
+		}
+		w.file = nil
+	}
+	tmp := w.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("cannot create CPU-hours WAL tmp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close CPU-hours WAL tmp file: %w", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("cannot rename CPU-hours WAL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCPUHoursWAL_AppendAndLoad(t *testing.T) {
+	dataDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dataDir, "stats"), os.ModePerm))
+	wal := NewCPUHoursWAL(dataDir, WALSyncAlways)
+
+	assert.NoError(t, wal.Append("linux", "v1.2", 3))
+	assert.NoError(t, wal.Append("windows", "v1.2", 2))
+	assert.NoError(t, wal.Append("linux", "v1.2", 1))
+
+	totals, err := wal.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, totals["linux"]["v1.2"])
+	assert.Equal(t, 2, totals["windows"]["v1.2"])
+}
+
+func TestCPUHoursWAL_LoadMissingFile(t *testing.T) {
+	wal := NewCPUHoursWAL(t.TempDir(), WALSyncAlways)
+	totals, err := wal.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, totals)
+}
+
+func TestCPUHoursWAL_RotateTruncatesAndReopens(t *testing.T) {
+	dataDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dataDir, "stats"), os.ModePerm))
+	wal := NewCPUHoursWAL(dataDir, WALSyncAlways)
+
+	assert.NoError(t, wal.Append("linux", "v1.2", 5))
+	assert.NoError(t, wal.Rotate())
+
+	totals, err := wal.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, totals, "rotation should truncate previously recorded entries")
+
+	assert.NoError(t, wal.Append("linux", "v1.2", 1))
+	totals, err = wal.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, totals["linux"]["v1.2"], "WAL should accept appends again after rotation")
+}
+
+func TestCPUHoursWAL_SkipsCorruptTrailingRecord(t *testing.T) {
+	dataDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dataDir, "stats"), os.ModePerm))
+	wal := NewCPUHoursWAL(dataDir, WALSyncAlways)
+	assert.NoError(t, wal.Append("linux", "v1.2", 3))
+
+	f, err := os.OpenFile(wal.path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("{not valid json\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	totals, err := wal.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, totals["linux"]["v1.2"], "the valid record should still replay")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -14,26 +15,50 @@ import (
 	"github.com/loda-lang/loda-api/cmd"
 	"github.com/loda-lang/loda-api/shared"
 	"github.com/loda-lang/loda-api/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type StatsServer struct {
-	dataDir            string
-	openApiSpec        []byte
-	summary            *Summary
-	submitters         []*shared.Submitter
-	influxDbClient     *util.InfluxDbClient
-	cpuHours           int
-	cpuHoursByPlatform map[string]map[string]int // platform -> version -> cpuHours
-	mutex              sync.Mutex
+	dataDir                  string
+	openApiSpec              []byte
+	summary                  *Summary
+	submitters               []*shared.Submitter
+	influxDbClient           *util.InfluxDbClient
+	metrics                  *Metrics
+	cpuHoursWAL              *CPUHoursWAL
+	cpuHoursAuth             *CPUHoursAuth
+	cpuHours                 int
+	cpuHoursByPlatform       map[string]map[string]int // platform -> version -> cpuHours, monotonic, backs the Prometheus counter
+	cpuHoursPushedByPlatform map[string]map[string]int // platform -> version -> cpuHours already pushed to InfluxDB
+	mutex                    sync.Mutex
 }
 
-func NewStatsServer(influxDbClient *util.InfluxDbClient, openApiSpec []byte, dataDir string) *StatsServer {
+// NewStatsServer creates a StatsServer, replaying any CPU-hour submissions
+// accepted since the last publishMetrics tick from the on-disk WAL (see
+// CPUHoursWAL) so a restart between ticks doesn't silently drop them.
+func NewStatsServer(influxDbClient *util.InfluxDbClient, openApiSpec []byte, dataDir string, walSyncPolicy CPUHoursWALSyncPolicy, cpuHoursHMACSecret string) *StatsServer {
+	wal := NewCPUHoursWAL(dataDir, walSyncPolicy)
+	cpuHoursByPlatform, err := wal.Load()
+	if err != nil {
+		log.Printf("Failed to load CPU-hours WAL: %v", err)
+		cpuHoursByPlatform = make(map[string]map[string]int)
+	}
+	metrics := NewMetrics()
+	for plat, vers := range cpuHoursByPlatform {
+		for ver, hours := range vers {
+			metrics.CPUHoursTotal.WithLabelValues(plat, ver).Add(float64(hours))
+		}
+	}
 	return &StatsServer{
-		dataDir:            dataDir,
-		openApiSpec:        openApiSpec,
-		influxDbClient:     influxDbClient,
-		cpuHours:           0,
-		cpuHoursByPlatform: make(map[string]map[string]int),
+		dataDir:                  dataDir,
+		openApiSpec:              openApiSpec,
+		influxDbClient:           influxDbClient,
+		metrics:                  metrics,
+		cpuHoursWAL:              wal,
+		cpuHoursAuth:             NewCPUHoursAuth(cpuHoursHMACSecret),
+		cpuHours:                 0,
+		cpuHoursByPlatform:       cpuHoursByPlatform,
+		cpuHoursPushedByPlatform: make(map[string]map[string]int),
 	}
 }
 
@@ -47,6 +72,9 @@ func (s *StatsServer) loadSummary() {
 		log.Printf("Loaded summary: %d sequences, %d programs, %d formulas",
 			summary.NumSequences, summary.NumPrograms, summary.NumFormulas)
 		s.summary = summary
+		s.metrics.Sequences.Set(float64(summary.NumSequences))
+		s.metrics.Programs.Set(float64(summary.NumPrograms))
+		s.metrics.Formulas.Set(float64(summary.NumFormulas))
 	}
 }
 
@@ -61,6 +89,7 @@ func (s *StatsServer) loadSubmitters() {
 	} else {
 		log.Printf("Loaded %d submitters", len(submitters))
 		s.submitters = submitters
+		s.metrics.Submitters.Set(float64(len(submitters)))
 	}
 }
 
@@ -70,6 +99,25 @@ func newCpuHourHandler(s *StatsServer) http.Handler {
 			util.WriteHttpMethodNotAllowed(w)
 			return
 		}
+		source := sourceIP(req)
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if err := s.cpuHoursAuth.VerifySignature(body, req.Header.Get(cpuHoursSignatureHeader), req.Header.Get(cpuHoursTimestampHeader)); err != nil {
+			log.Printf("Rejected CPU-hours submission from %s: %v", source, err)
+			s.metrics.CPUHoursRejectedTotal.WithLabelValues("unauthorized").Inc()
+			util.WriteHttpUnauthorized(w)
+			return
+		}
+		if !s.cpuHoursAuth.Allow(source) {
+			s.metrics.CPUHoursRejectedTotal.WithLabelValues("rate_limited").Inc()
+			util.WriteHttpTooManyRequests(w)
+			return
+		}
+
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
 
@@ -79,8 +127,7 @@ func newCpuHourHandler(s *StatsServer) http.Handler {
 			Version  string `json:"version"`
 			CPUHours int    `json:"cpuHours"`
 		}
-		decoder := json.NewDecoder(req.Body)
-		err := decoder.Decode(&payload)
+		err = json.Unmarshal(body, &payload)
 		if err == nil && (payload.Platform != "" || payload.Version != "" || payload.CPUHours > 0) {
 			plat := payload.Platform
 			ver := payload.Version
@@ -94,20 +141,42 @@ func newCpuHourHandler(s *StatsServer) http.Handler {
 			if ver == "" {
 				ver = "unknown"
 			}
+			if !s.cpuHoursAuth.ReserveDailyCap(source, hours) {
+				s.metrics.CPUHoursRejectedTotal.WithLabelValues("daily_cap").Inc()
+				util.WriteHttpTooManyRequests(w)
+				return
+			}
 			if s.cpuHoursByPlatform[plat] == nil {
 				s.cpuHoursByPlatform[plat] = make(map[string]int)
 			}
 			s.cpuHoursByPlatform[plat][ver] += hours
+			s.metrics.CPUHoursTotal.WithLabelValues(plat, ver).Add(float64(hours))
+			if err := s.cpuHoursWAL.Append(plat, ver, hours); err != nil {
+				log.Printf("Failed to append CPU-hours WAL: %v", err)
+			}
 			util.WriteHttpCreated(w, "Metric received (json)")
 			return
 		}
 		// Fallback: legacy increment
+		if !s.cpuHoursAuth.ReserveDailyCap(source, 1) {
+			s.metrics.CPUHoursRejectedTotal.WithLabelValues("daily_cap").Inc()
+			util.WriteHttpTooManyRequests(w)
+			return
+		}
 		s.cpuHours += 1
 		util.WriteHttpCreated(w, "Metric received")
 	}
 	return http.HandlerFunc(f)
 }
 
+// publishMetrics pushes cpuHours to InfluxDB, for backward compatibility
+// with dashboards that still read from it. cpuHoursByPlatform itself backs
+// the Prometheus loda_cpu_hours_total counter exposed at /v2/metrics, so it
+// must only ever grow; the amount already pushed to InfluxDB is tracked
+// separately in cpuHoursPushedByPlatform, and only the delta since the last
+// push is written. Once the push succeeds, the CPU-hours WAL is rotated:
+// everything it recorded is now safely in InfluxDB, so a restart no longer
+// needs to replay it.
 func (s *StatsServer) publishMetrics() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -117,16 +186,23 @@ func (s *StatsServer) publishMetrics() {
 		s.influxDbClient.Write("cpuhours", labels, s.cpuHours)
 		s.cpuHours = 0
 	}
-	// Publish per-platform/version
+	// Publish per-platform/version deltas
 	for plat, vers := range s.cpuHoursByPlatform {
 		for ver, hours := range vers {
-			if hours > 0 {
+			delta := hours - s.cpuHoursPushedByPlatform[plat][ver]
+			if delta > 0 {
 				labels := map[string]string{"platform": plat, "version": ver}
-				s.influxDbClient.Write("cpuhours", labels, hours)
-				s.cpuHoursByPlatform[plat][ver] = 0
+				s.influxDbClient.Write("cpuhours", labels, delta)
+				if s.cpuHoursPushedByPlatform[plat] == nil {
+					s.cpuHoursPushedByPlatform[plat] = make(map[string]int)
+				}
+				s.cpuHoursPushedByPlatform[plat][ver] = hours
 			}
 		}
 	}
+	if err := s.cpuHoursWAL.Rotate(); err != nil {
+		log.Printf("Failed to rotate CPU-hours WAL: %v", err)
+	}
 }
 
 func newOpenAPIHandler(s *StatsServer) http.Handler {
@@ -269,6 +345,7 @@ func (s *StatsServer) Run(port int) {
 	router.Handle("/v2/stats/summary", newSummaryHandler(s))
 	router.Handle("/v2/stats/keywords", newKeywordsHandler())
 	router.Handle("/v2/stats/submitters", newSubmittersHandler(s))
+	router.Handle("/v2/metrics", promhttp.HandlerFor(s.metrics.Registry, promhttp.HandlerOpts{}))
 	router.NotFoundHandler = http.HandlerFunc(util.HandleNotFound)
 	log.Printf("Listening on port %d", port)
 	http.ListenAndServe(fmt.Sprintf(":%d", port), router)
@@ -276,14 +353,17 @@ func (s *StatsServer) Run(port int) {
 
 func main() {
 	setup := cmd.GetSetup("stats")
+	if err := setup.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	util.MustDirExist(setup.DataDir)
 	openApiPath := filepath.Join(setup.DataDir, "openapi.v2.yaml")
 	openApiSpec, err := os.ReadFile(openApiPath)
 	if err != nil {
 		log.Fatalf("Failed to read OpenAPI spec: %v", err)
 	}
-	u, p := util.ParseAuthInfo(setup.InfluxDbAuth)
-	i := util.NewInfluxDbClient(setup.InfluxDbHost, u, p)
-	s := NewStatsServer(i, openApiSpec, setup.DataDir)
+	u, p := util.ParseAuthInfo(setup.InfluxDB.Auth)
+	i := util.NewInfluxDbClient(setup.InfluxDB.Host, u, p)
+	s := NewStatsServer(i, openApiSpec, setup.DataDir, CPUHoursWALSyncPolicy(setup.CPUHours.WALSync), setup.CPUHours.HMACSecret)
 	s.Run(8082)
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors published by a StatsServer at
+// /v2/metrics, alongside the existing InfluxDB push.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	CPUHoursTotal         *prometheus.CounterVec
+	CPUHoursRejectedTotal *prometheus.CounterVec
+	Sequences             prometheus.Gauge
+	Programs              prometheus.Gauge
+	Formulas              prometheus.Gauge
+	Submitters            prometheus.Gauge
+}
+
+// NewMetrics creates and registers the Prometheus collectors used by a
+// StatsServer.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		CPUHoursTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_cpu_hours_total",
+			Help: "Total CPU hours contributed, by platform and version.",
+		}, []string{"platform", "version"}),
+		CPUHoursRejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loda_cpu_hours_rejected_total",
+			Help: "CPU-hour submissions rejected by newCpuHourHandler, by reason.",
+		}, []string{"reason"}),
+		Sequences: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_sequences",
+			Help: "Number of OEIS sequences in the current summary.",
+		}),
+		Programs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_programs",
+			Help: "Number of LODA programs in the current summary.",
+		}),
+		Formulas: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_formulas",
+			Help: "Number of formulas in the current summary.",
+		}),
+		Submitters: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loda_submitters",
+			Help: "Number of known submitters.",
+		}),
+	}
+	m.Registry.MustRegister(
+		m.CPUHoursTotal,
+		m.CPUHoursRejectedTotal,
+		m.Sequences,
+		m.Programs,
+		m.Formulas,
+		m.Submitters,
+	)
+	return m
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+)
+
+// makeSyntheticCheckpointSubmissions builds n submissions with varied ids
+// and a multi-line program body, realistic enough to exercise
+// writeCheckpoint/loadCheckpoint the way the real submissions corpus would.
+func makeSyntheticCheckpointSubmissions(n int) []shared.Submission {
+	submissions := make([]shared.Submission, n)
+	for i := 0; i < n; i++ {
+		id, err := util.NewUIDFromString(fmt.Sprintf("A%06d", i+1))
+		if err != nil {
+			panic(err)
+		}
+		content := fmt.Sprintf("mov $1,%d\nlpb $0\n  sub $0,1\n  add $1,$1\nlpe\nmov $0,$1\n", i%97)
+		submissions[i] = shared.Submission{
+			Id:        id,
+			Mode:      shared.ModeAdd,
+			Type:      shared.TypeProgram,
+			Content:   content,
+			Submitter: fmt.Sprintf("miner%d", i%50),
+		}
+	}
+	return submissions
+}
+
+func benchmarkWriteCheckpoint(b *testing.B, format string) {
+	tmpDir, err := os.MkdirTemp("", "checkpoint-bench-*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oeisDir := filepath.Join(tmpDir, "seqs", "oeis")
+	os.MkdirAll(oeisDir, os.ModePerm)
+
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	cfg, fingerprint := server.config.Get()
+	cfg.CheckpointFormat = format
+	if _, _, err := server.config.Update(cfg, fingerprint); err != nil {
+		b.Fatal(err)
+	}
+	server.submissions = makeSyntheticCheckpointSubmissions(50_000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := server.writeCheckpoint(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteCheckpoint_JSON(b *testing.B) {
+	benchmarkWriteCheckpoint(b, CheckpointFormatJSON)
+}
+
+func BenchmarkWriteCheckpoint_GzipJSON(b *testing.B) {
+	benchmarkWriteCheckpoint(b, CheckpointFormatGzipJSON)
+}
+
+func benchmarkLoadCheckpoint(b *testing.B, format string) {
+	tmpDir, err := os.MkdirTemp("", "checkpoint-bench-*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oeisDir := filepath.Join(tmpDir, "seqs", "oeis")
+	os.MkdirAll(oeisDir, os.ModePerm)
+
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	cfg, fingerprint := server.config.Get()
+	cfg.CheckpointFormat = format
+	if _, _, err := server.config.Update(cfg, fingerprint); err != nil {
+		b.Fatal(err)
+	}
+	server.submissions = makeSyntheticCheckpointSubmissions(50_000)
+	if err := server.writeCheckpoint(); err != nil {
+		b.Fatal(err)
+	}
+
+	checkpointPath := filepath.Join(tmpDir, CheckpointFile)
+	if format == CheckpointFormatGzipJSON {
+		checkpointPath += ".gz"
+	}
+	if fi, err := os.Stat(checkpointPath); err == nil {
+		b.Logf("%s: %d bytes on disk for %d submissions", filepath.Base(checkpointPath), fi.Size(), len(server.submissions))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reloaded := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+		reloaded.loadCheckpoint()
+	}
+}
+
+func BenchmarkLoadCheckpoint_JSON(b *testing.B) {
+	benchmarkLoadCheckpoint(b, CheckpointFormatJSON)
+}
+
+func BenchmarkLoadCheckpoint_GzipJSON(b *testing.B) {
+	benchmarkLoadCheckpoint(b, CheckpointFormatGzipJSON)
+}
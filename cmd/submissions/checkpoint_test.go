@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -21,8 +22,13 @@ func TestCheckpoint_WriteAndLoad_JSON(t *testing.T) {
 	oeisDir := filepath.Join(tmpDir, "seqs", "oeis")
 	os.MkdirAll(oeisDir, os.ModePerm)
 
-	// Create a test server
-	server := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	// Create a test server, forcing the legacy plain-JSON checkpoint format
+	// since that's specifically what this test exercises.
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	cfg, fingerprint := server.config.Get()
+	cfg.CheckpointFormat = CheckpointFormatJSON
+	_, _, err = server.config.Update(cfg, fingerprint)
+	assert.NoError(t, err)
 
 	// Add some test submissions
 	id1, _ := util.NewUIDFromString("A000045")
@@ -55,7 +61,7 @@ func TestCheckpoint_WriteAndLoad_JSON(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Create a new server and load the checkpoint
-	server2 := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	server2 := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 	server2.loadCheckpoint()
 
 	// Verify the loaded submissions match
@@ -84,16 +90,89 @@ func TestCheckpoint_MissingFile(t *testing.T) {
 	os.MkdirAll(oeisDir, os.ModePerm)
 
 	// Create a server and try to load a non-existent checkpoint
-	server := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 	server.loadCheckpoint()
 
 	// Should not crash, just have empty submissions
 	assert.Equal(t, 0, len(server.submissions))
 }
 
+// TestCheckpoint_WriteAndLoad_GzipDefault checks the default
+// CheckpointFormatGzipJSON path: writeCheckpoint must produce
+// checkpoint.json.gz rather than the legacy plain file, and a fresh server
+// must be able to load it back.
+func TestCheckpoint_WriteAndLoad_GzipDefault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "checkpoint-gzip-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	oeisDir := filepath.Join(tmpDir, "seqs", "oeis")
+	os.MkdirAll(oeisDir, os.ModePerm)
+
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	id, _ := util.NewUIDFromString("A000045")
+	server.submissions = []shared.Submission{
+		{Id: id, Mode: shared.ModeAdd, Type: shared.TypeProgram, Content: "mov $0,1\n", Submitter: "alice"},
+	}
+
+	assert.NoError(t, server.writeCheckpoint())
+
+	checkpointPath := filepath.Join(tmpDir, CheckpointFile)
+	_, err = os.Stat(checkpointPath)
+	assert.True(t, os.IsNotExist(err), "the legacy plain checkpoint must not be written by default")
+	_, err = os.Stat(checkpointPath + ".gz")
+	assert.NoError(t, err, "the gzip checkpoint must be written by default")
+
+	server2 := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	server2.loadCheckpoint()
+	assert.Equal(t, 1, len(server2.submissions))
+	assert.Equal(t, "A000045", server2.submissions[0].Id.String())
+	assert.Equal(t, "mov $0,1\n", server2.submissions[0].Content)
+}
+
+// TestCheckpoint_LoadsLegacyPlainJSON_AndMigrates checks that a checkpoint
+// left behind by a binary that predates CheckpointFormatGzipJSON still
+// loads, and that the next writeCheckpoint migrates it to gzip.
+func TestCheckpoint_LoadsLegacyPlainJSON_AndMigrates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "checkpoint-migrate-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	oeisDir := filepath.Join(tmpDir, "seqs", "oeis")
+	os.MkdirAll(oeisDir, os.ModePerm)
+
+	legacy := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	cfg, fingerprint := legacy.config.Get()
+	cfg.CheckpointFormat = CheckpointFormatJSON
+	_, _, err = legacy.config.Update(cfg, fingerprint)
+	assert.NoError(t, err)
+	id, _ := util.NewUIDFromString("A000045")
+	legacy.submissions = []shared.Submission{
+		{Id: id, Mode: shared.ModeAdd, Type: shared.TypeProgram, Content: "mov $0,1\n", Submitter: "alice"},
+	}
+	assert.NoError(t, legacy.writeCheckpoint())
+
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+	server.loadCheckpoint()
+	assert.Equal(t, 1, len(server.submissions))
+	assert.Equal(t, "A000045", server.submissions[0].Id.String())
+
+	assert.NoError(t, server.writeCheckpoint())
+	checkpointPath := filepath.Join(tmpDir, CheckpointFile)
+	_, err = os.Stat(checkpointPath)
+	assert.True(t, os.IsNotExist(err), "writeCheckpoint must migrate away the legacy plain file once re-saved under the new default")
+	_, err = os.Stat(checkpointPath + ".gz")
+	assert.NoError(t, err)
+}
+
 func TestCheckSubmit_DuplicateAdd(t *testing.T) {
+	// Create a temporary directory for testing
+	tmpDir, err := os.MkdirTemp("", "checksubmit-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
 	// Create a test server
-	server := NewSubmissionsServer("", "", nil)
+	server := NewSubmissionsServer(tmpDir, "", nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 
 	// Create a submission with mode "add"
 	id1, _ := util.NewUIDFromString("A000045")
@@ -125,7 +204,7 @@ func TestCheckSubmit_DuplicateAdd(t *testing.T) {
 	assert.Equal(t, "Duplicate submission", result.Message)
 }
 
-func TestRefreshSequence_DeletesBFile(t *testing.T) {
+func TestRefreshSequence_MarksBFileStaleInsteadOfDeletingIt(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "refresh-test-*")
 	assert.NoError(t, err)
@@ -136,7 +215,7 @@ func TestRefreshSequence_DeletesBFile(t *testing.T) {
 	os.MkdirAll(oeisDir, os.ModePerm)
 
 	// Create a test server
-	server := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 
 	// Create a test b-file
 	id, _ := util.NewUIDFromString("A000045")
@@ -152,18 +231,25 @@ func TestRefreshSequence_DeletesBFile(t *testing.T) {
 	// Create a refresh submission
 	submission := shared.Submission{
 		Id:        id,
-		Mode:      shared.ModeRefresh,
+		Mode:      shared.ModeUpdate,
 		Type:      shared.TypeSequence,
 		Submitter: "tester",
 	}
 
 	// Execute refresh
-	result := server.refreshSequence(submission)
+	result := server.refreshSequence(context.Background(), submission)
 	assert.Equal(t, "success", result.Status)
 
-	// Verify b-file was deleted
-	_, err = os.Stat(bfilePath)
-	assert.True(t, os.IsNotExist(err), "B-file should be deleted after refresh")
+	// The b-file must still be present and readable immediately after
+	// refresh, so a racing reader never observes a cache miss, but its
+	// mtime must be backdated into the past so the b-file fetcher treats
+	// it as stale and refetches it.
+	info, err := os.Stat(bfilePath)
+	assert.NoError(t, err, "B-file should still exist after refresh")
+	content, err := os.ReadFile(bfilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "test content", string(content))
+	assert.True(t, info.ModTime().Before(time.Now().Add(-24*time.Hour)), "B-file mtime should be backdated well into the past")
 }
 
 func TestRefreshSequence_NoBFile(t *testing.T) {
@@ -177,22 +263,22 @@ func TestRefreshSequence_NoBFile(t *testing.T) {
 	os.MkdirAll(oeisDir, os.ModePerm)
 
 	// Create a test server
-	server := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 
 	// Create a refresh submission (no b-file exists)
 	id, _ := util.NewUIDFromString("A000045")
 	submission := shared.Submission{
 		Id:        id,
-		Mode:      shared.ModeRefresh,
+		Mode:      shared.ModeUpdate,
 		Type:      shared.TypeSequence,
 		Submitter: "tester",
 	}
 
 	// Execute refresh - should succeed even without b-file
-	result := server.refreshSequence(submission)
+	result := server.refreshSequence(context.Background(), submission)
 	assert.Equal(t, "success", result.Status)
 }
-func TestRefreshSequence_RateLimitPerHour(t *testing.T) {
+func TestRefreshSequence_RateLimitPerSubmitter(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "refresh-ratelimit-test-*")
 	assert.NoError(t, err)
@@ -203,33 +289,35 @@ func TestRefreshSequence_RateLimitPerHour(t *testing.T) {
 	os.MkdirAll(oeisDir, os.ModePerm)
 
 	// Create a test server
-	server := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 
 	// Create a refresh submission
 	id, _ := util.NewUIDFromString("A000045")
 	submission := shared.Submission{
 		Id:        id,
-		Mode:      shared.ModeRefresh,
+		Mode:      shared.ModeUpdate,
 		Type:      shared.TypeSequence,
 		Submitter: "tester",
 	}
 
-	// Fill up the rate limit (200 submissions)
-	for i := 0; i < SequenceRefreshLimitPerHour; i++ {
-		result := server.refreshSequence(submission)
+	// Exhaust the submitter's burst allowance.
+	for i := 0; i < refreshRateLimiterBurst; i++ {
+		result := server.refreshSequence(context.Background(), submission)
 		assert.Equal(t, "success", result.Status, "Submission %d should succeed", i+1)
 	}
 
-	// Next submission should be rejected due to rate limit
-	result := server.refreshSequence(submission)
+	// The next submission should be rejected: the bucket refills far slower
+	// (200/hour) than the burst was drained.
+	result := server.refreshSequence(context.Background(), submission)
 	assert.Equal(t, "error", result.Status, "Submission should be rejected due to rate limit")
 	assert.Contains(t, result.Message, "Rate limit exceeded")
 	assert.Contains(t, result.Message, "200")
+	assert.True(t, result.retryAfter > 0, "retryAfter should be set on a rejected refresh")
 }
 
-func TestRefreshSequence_RateLimitExpiry(t *testing.T) {
+func TestRefreshSequence_RateLimitIsolatedPerSubmitter(t *testing.T) {
 	// Create a temporary directory for testing
-	tmpDir, err := os.MkdirTemp("", "refresh-ratelimit-expiry-test-*")
+	tmpDir, err := os.MkdirTemp("", "refresh-ratelimit-isolation-test-*")
 	assert.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
@@ -238,33 +326,22 @@ func TestRefreshSequence_RateLimitExpiry(t *testing.T) {
 	os.MkdirAll(oeisDir, os.ModePerm)
 
 	// Create a test server
-	server := NewSubmissionsServer(tmpDir, oeisDir, nil)
+	server := NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
 
-	// Create a refresh submission
 	id, _ := util.NewUIDFromString("A000045")
-	submission := shared.Submission{
-		Id:        id,
-		Mode:      shared.ModeRefresh,
-		Type:      shared.TypeSequence,
-		Submitter: "tester",
-	}
+	noisySubmission := shared.Submission{Id: id, Mode: shared.ModeUpdate, Type: shared.TypeSequence, Submitter: "noisy"}
+	quietSubmission := shared.Submission{Id: id, Mode: shared.ModeUpdate, Type: shared.TypeSequence, Submitter: "quiet"}
 
-	// Add two submissions with timestamps 1 hour and 1 second ago
-	// This simulates that one is outside the 1-hour window
-	now := time.Now()
-	server.submissionsMutex.Lock()
-	server.refreshSubmissions = []time.Time{
-		now.Add(-61 * time.Minute), // More than 1 hour ago, should be cleaned up
-		now.Add(-59 * time.Minute), // Less than 1 hour ago, should stay
+	// Drain "noisy"'s bucket, then one more to confirm it's now rejected.
+	for i := 0; i < refreshRateLimiterBurst; i++ {
+		result := server.refreshSequence(context.Background(), noisySubmission)
+		assert.Equal(t, "success", result.Status)
 	}
-	server.submissionsMutex.Unlock()
-
-	// Refresh should only count the one submission within the hour
-	result := server.refreshSequence(submission)
-	assert.Equal(t, "success", result.Status, "Submission should succeed after old timestamp expires")
+	result := server.refreshSequence(context.Background(), noisySubmission)
+	assert.Equal(t, "error", result.Status, "noisy should now be rate-limited")
 
-	// Verify the timestamp was cleaned up (should only have 2 submissions now: the old one and the new one)
-	server.submissionsMutex.Lock()
-	assert.Equal(t, 2, len(server.refreshSubmissions), "Old timestamp should have been cleaned up")
-	server.submissionsMutex.Unlock()
+	// "quiet" has never refreshed before, so it should still have its full
+	// burst allowance: one noisy submitter can't eat into another's quota.
+	result = server.refreshSequence(context.Background(), quietSubmission)
+	assert.Equal(t, "success", result.Status, "quiet's allowance should be unaffected by noisy's")
 }
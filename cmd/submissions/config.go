@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the YAML file ConfigStore persists CrawlerConfig to, so an
+// operator can tune it via GitOps (edit the file, POST /v2/config/reload)
+// as an alternative to PUT /v2/config.
+const ConfigFile = "config.yaml"
+
+// CrawlerConfig holds every crawler and submission-rate knob that's safe to
+// change at runtime, without restarting the server.
+type CrawlerConfig struct {
+	FetchInterval               time.Duration `json:"fetch_interval" yaml:"fetch_interval"`
+	FlushInterval               int           `json:"flush_interval" yaml:"flush_interval"`
+	ReinitInterval              int           `json:"reinit_interval" yaml:"reinit_interval"`
+	IdsCacheSize                int           `json:"ids_cache_size" yaml:"ids_cache_size"`
+	IdsFetchRatio               float64       `json:"ids_fetch_ratio" yaml:"ids_fetch_ratio"`
+	MaxQueueSize                int           `json:"max_queue_size" yaml:"max_queue_size"`
+	RestartPause                time.Duration `json:"restart_pause" yaml:"restart_pause"`
+	SequenceRefreshLimitPerHour int           `json:"sequence_refresh_limit_per_hour" yaml:"sequence_refresh_limit_per_hour"`
+	NumSubmissionsPerUser       int           `json:"num_submissions_per_user" yaml:"num_submissions_per_user"`
+
+	// RequireAuth rejects unauthenticated POST /v2/submissions requests
+	// even when the server was started in anonymous mode. It defaults to
+	// false so existing miners keep working; an operator flips it once
+	// bearer tokens have been rolled out to them.
+	RequireAuth bool `json:"require_auth" yaml:"require_auth"`
+
+	// CheckpointFormat selects the on-disk encoding writeCheckpoint uses:
+	// CheckpointFormatJSON for the legacy plain checkpoint.json, or
+	// CheckpointFormatGzipJSON (the default) to gzip it as
+	// checkpoint.json.gz, which loadCheckpoint always prefers when both are
+	// present. An operator can set this back to "json" to opt out of
+	// compression, e.g. to keep the checkpoint diffable on disk.
+	CheckpointFormat string `json:"checkpoint_format" yaml:"checkpoint_format"`
+}
+
+// ErrConfigFingerprintMismatch is returned by ConfigStore.Update when the
+// caller's fingerprint is stale, i.e. someone else updated the config since
+// the caller last fetched it.
+var ErrConfigFingerprintMismatch = errors.New("config fingerprint mismatch")
+
+// ErrInvalidConfig is returned by ConfigStore.Update when newConfig fails
+// validateConfig.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// validateConfig rejects a config that would put the server in a broken
+// state if applied, e.g. SequenceRefreshLimitPerHour <= 0, which would make
+// TokenBucketRateLimiter.Allow divide by zero.
+func validateConfig(config CrawlerConfig) error {
+	if config.SequenceRefreshLimitPerHour <= 0 {
+		return fmt.Errorf("%w: sequence_refresh_limit_per_hour must be positive, got %d", ErrInvalidConfig, config.SequenceRefreshLimitPerHour)
+	}
+	return nil
+}
+
+// configFingerprint is the SHA-256 of config's canonical JSON encoding,
+// given to clients by GET /v2/config so a later PUT can prove it saw the
+// version it's replacing.
+func configFingerprint(config CrawlerConfig) string {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		panic(fmt.Sprintf("cannot marshal config for fingerprinting: %v", err))
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ConfigStore is a YAML-on-disk, JSON-over-HTTP store for CrawlerConfig,
+// guarding updates with the fingerprint-based optimistic-concurrency
+// protocol used by GET/PUT /v2/config: two operators racing to update it
+// can't silently stomp on each other's change.
+type ConfigStore struct {
+	path string
+
+	mutex    sync.RWMutex
+	config   CrawlerConfig
+	onChange func(CrawlerConfig)
+}
+
+// NewConfigStore creates a ConfigStore backed by dataDir, loading its YAML
+// file if one already exists, or writing defaults to it if not.
+func NewConfigStore(dataDir string, defaults CrawlerConfig) *ConfigStore {
+	cs := &ConfigStore{
+		path:   filepath.Join(dataDir, ConfigFile),
+		config: defaults,
+	}
+	if raw, err := os.ReadFile(cs.path); err == nil {
+		var loaded CrawlerConfig
+		if err := yaml.Unmarshal(raw, &loaded); err != nil {
+			log.Printf("Cannot parse %s, using defaults: %v", cs.path, err)
+		} else {
+			cs.config = loaded
+		}
+	} else if err := cs.save(); err != nil {
+		log.Printf("Cannot write initial %s: %v", cs.path, err)
+	}
+	return cs
+}
+
+// OnChange registers fn to be called, with the newly applied config, after
+// every successful Update or Reload. Only one callback is supported; it's
+// how StartCrawler's fetch ticker picks up a changed FetchInterval without
+// a restart.
+func (cs *ConfigStore) OnChange(fn func(CrawlerConfig)) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.onChange = fn
+}
+
+// save writes cs.config to disk as YAML via a temp file plus atomic
+// rename. Caller must hold mutex.
+func (cs *ConfigStore) save() error {
+	raw, err := yaml.Marshal(cs.config)
+	if err != nil {
+		return fmt.Errorf("cannot marshal config: %w", err)
+	}
+	tmpPath := cs.path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0644); err != nil {
+		return fmt.Errorf("cannot write config file: %w", err)
+	}
+	return os.Rename(tmpPath, cs.path)
+}
+
+// Get returns the current config and its fingerprint.
+func (cs *ConfigStore) Get() (CrawlerConfig, string) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	return cs.config, configFingerprint(cs.config)
+}
+
+// Update replaces the config with newConfig, but only if expectedFingerprint
+// still matches what's currently stored; otherwise it returns
+// ErrConfigFingerprintMismatch and leaves the config untouched.
+func (cs *ConfigStore) Update(newConfig CrawlerConfig, expectedFingerprint string) (CrawlerConfig, string, error) {
+	if err := validateConfig(newConfig); err != nil {
+		return CrawlerConfig{}, "", err
+	}
+	cs.mutex.Lock()
+	if configFingerprint(cs.config) != expectedFingerprint {
+		cs.mutex.Unlock()
+		return CrawlerConfig{}, "", ErrConfigFingerprintMismatch
+	}
+	previous := cs.config
+	cs.config = newConfig
+	if err := cs.save(); err != nil {
+		cs.config = previous
+		cs.mutex.Unlock()
+		return CrawlerConfig{}, "", err
+	}
+	onChange := cs.onChange
+	cs.mutex.Unlock()
+	if onChange != nil {
+		onChange(newConfig)
+	}
+	return newConfig, configFingerprint(newConfig), nil
+}
+
+// Reload re-reads the on-disk YAML file, replacing the in-memory config
+// with whatever it finds, so an operator can push a new config file (a
+// GitOps workflow) instead of calling Update directly.
+func (cs *ConfigStore) Reload() (CrawlerConfig, string, error) {
+	raw, err := os.ReadFile(cs.path)
+	if err != nil {
+		return CrawlerConfig{}, "", fmt.Errorf("cannot read config file: %w", err)
+	}
+	var loaded CrawlerConfig
+	if err := yaml.Unmarshal(raw, &loaded); err != nil {
+		return CrawlerConfig{}, "", fmt.Errorf("cannot parse config file: %w", err)
+	}
+	cs.mutex.Lock()
+	cs.config = loaded
+	onChange := cs.onChange
+	cs.mutex.Unlock()
+	if onChange != nil {
+		onChange(loaded)
+	}
+	return loaded, configFingerprint(loaded), nil
+}
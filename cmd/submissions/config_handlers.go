@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// ConfigResponse is the shape returned by GET /v2/config and, on success,
+// by PUT /v2/config and POST /v2/config/reload.
+type ConfigResponse struct {
+	Config      CrawlerConfig `json:"config"`
+	Fingerprint string        `json:"fingerprint"`
+}
+
+// configUpdateRequest is the body PUT /v2/config expects: the config to
+// apply, plus the fingerprint the caller last saw, so ConfigStore.Update
+// can detect a racing update.
+type configUpdateRequest struct {
+	Config      CrawlerConfig `json:"config"`
+	Fingerprint string        `json:"fingerprint"`
+}
+
+// newV2ConfigGetHandler handles GET requests for /v2/config, returning the
+// current crawler config and its fingerprint.
+func newV2ConfigGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		cfg, fingerprint := s.config.Get()
+		util.WriteJsonResponse(w, ConfigResponse{Config: cfg, Fingerprint: fingerprint})
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2ConfigPutHandler handles PUT requests for /v2/config. The caller
+// must send the fingerprint it last fetched; a mismatch (someone else
+// updated the config since) returns 409 Conflict instead of silently
+// overwriting their change.
+func newV2ConfigPutHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		defer req.Body.Close()
+		var update configUpdateRequest
+		if err := json.NewDecoder(req.Body).Decode(&update); err != nil {
+			log.Printf("Invalid config update JSON: %v", err)
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		cfg, fingerprint, err := s.config.Update(update.Config, update.Fingerprint)
+		if err != nil {
+			if errors.Is(err, ErrConfigFingerprintMismatch) {
+				util.WriteHttpConflict(w)
+				return
+			}
+			if errors.Is(err, ErrInvalidConfig) {
+				log.Printf("Rejected invalid config update: %v", err)
+				util.WriteHttpBadRequest(w)
+				return
+			}
+			log.Printf("Cannot update config: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		util.WriteJsonResponse(w, ConfigResponse{Config: cfg, Fingerprint: fingerprint})
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2ConfigReloadPostHandler handles POST requests for
+// /v2/config/reload, re-reading the on-disk YAML config file, for GitOps
+// workflows that push a new file instead of calling PUT /v2/config.
+func newV2ConfigReloadPostHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		cfg, fingerprint, err := s.config.Reload()
+		if err != nil {
+			log.Printf("Cannot reload config: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		util.WriteJsonResponse(w, ConfigResponse{Config: cfg, Fingerprint: fingerprint})
+	}
+	return http.HandlerFunc(f)
+}
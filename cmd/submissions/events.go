@@ -0,0 +1,164 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// EventRingBufferSize bounds how many past events eventBroadcaster keeps
+	// around for Last-Event-ID replay on GET /v2/events.
+	EventRingBufferSize = 200
+	// EventSubscriberBufferSize is how many events a single /v2/events
+	// connection can be behind before it's considered slow.
+	EventSubscriberBufferSize = 32
+	// EventHeartbeatInterval is how often GET /v2/events writes a comment
+	// line to keep idle connections open through proxies.
+	EventHeartbeatInterval = 15 * time.Second
+)
+
+// Event is one entry on the /v2/events stream: a submission outcome, a
+// crawler progress note, or an operation status change. ID is monotonic
+// per-broadcaster and is what a client's Last-Event-ID refers to.
+type Event struct {
+	ID   int64       `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// submissionEventData is the Data payload of a submission.accepted or
+// submission.rejected Event.
+type submissionEventData struct {
+	Submitter string `json:"submitter"`
+	Type      string `json:"type,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// crawlerFetchedEventData is the Data payload of a crawler.fetched Event.
+type crawlerFetchedEventData struct {
+	Id      string        `json:"id"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// eventBroadcaster is a small in-process pub/sub: it fans Publish calls out
+// to every subscriber channel and keeps a ring buffer of recent events so a
+// reconnecting client can replay what it missed. It's also the single place
+// per-profile submission counts are kept, since publishMetrics previously
+// tracked those itself purely to feed InfluxDB.
+//
+// A slow subscriber never back-pressures Publish: a full channel gets a
+// "dropped" event instead of the real one, on a best-effort basis, so a lazy
+// dashboard can't stall doSubmit.
+type eventBroadcaster struct {
+	mutex         sync.Mutex
+	subscribers   map[chan Event]bool
+	nextID        int64
+	ring          []Event
+	profileCounts map[string]int
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{
+		subscribers:   make(map[chan Event]bool),
+		profileCounts: make(map[string]int),
+	}
+}
+
+// Add registers ch to receive every future Publish call, until Remove is
+// called with the same channel.
+func (b *eventBroadcaster) Add(ch chan Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers[ch] = true
+}
+
+// Remove unregisters ch. It does not close ch; the caller owns that.
+func (b *eventBroadcaster) Remove(ch chan Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// Publish fans out an event of the given type to every current subscriber
+// and appends it to the replay ring buffer. It never blocks: a subscriber
+// whose buffer is full is sent a "dropped" event instead, also on a
+// best-effort basis.
+func (b *eventBroadcaster) Publish(eventType string, data interface{}) Event {
+	b.mutex.Lock()
+	b.nextID++
+	ev := Event{ID: b.nextID, Type: eventType, Time: time.Now(), Data: data}
+	b.ring = append(b.ring, ev)
+	if len(b.ring) > EventRingBufferSize {
+		b.ring = b.ring[len(b.ring)-EventRingBufferSize:]
+	}
+	subs := make([]chan Event, 0, len(b.subscribers))
+	for ch := range b.subscribers {
+		subs = append(subs, ch)
+	}
+	b.mutex.Unlock()
+
+	for _, ch := range subs {
+		select {
+		case ch <- ev:
+		default:
+			// Subscriber's buffer is full: make room by dropping its oldest
+			// buffered event, then tell it so, instead of blocking Publish
+			// on a slow reader. The client can use Since (via Last-Event-ID)
+			// to catch up on whatever it missed.
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- Event{ID: ev.ID, Type: "dropped"}:
+			default:
+			}
+		}
+	}
+	return ev
+}
+
+// PublishSubmissionAccepted publishes a submission.accepted event and bumps
+// data.Profile's running count, which publishMetrics drains on its own
+// ticker to push to InfluxDB.
+func (b *eventBroadcaster) PublishSubmissionAccepted(data submissionEventData) Event {
+	b.mutex.Lock()
+	b.profileCounts[data.Profile]++
+	b.mutex.Unlock()
+	return b.Publish("submission.accepted", data)
+}
+
+// ProfileCount returns how many submission.accepted events profile has
+// accumulated since the last DrainProfileCounts.
+func (b *eventBroadcaster) ProfileCount(profile string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.profileCounts[profile]
+}
+
+// DrainProfileCounts returns the accumulated per-profile submission counts
+// and resets them, for publishMetrics' periodic InfluxDB push.
+func (b *eventBroadcaster) DrainProfileCounts() map[string]int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	counts := b.profileCounts
+	b.profileCounts = make(map[string]int)
+	return counts
+}
+
+// Since returns every ring-buffered event with an ID greater than
+// lastEventID, for replaying what a reconnecting client missed.
+func (b *eventBroadcaster) Since(lastEventID int64) []Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var out []Event
+	for _, ev := range b.ring {
+		if ev.ID > lastEventID {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
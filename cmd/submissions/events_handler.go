@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// writeEventSSE writes ev as one SSE frame: an "id:" line (so the browser's
+// EventSource keeps Last-Event-ID current), an "event:" line, and a "data:"
+// line carrying its JSON encoding.
+func writeEventSSE(w http.ResponseWriter, flusher http.Flusher, ev Event) {
+	payload, err := json.Marshal(ev.Data)
+	if err != nil {
+		log.Printf("Failed to marshal event %s: %v", ev.Type, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, payload)
+	flusher.Flush()
+}
+
+// newV2EventsGetHandler handles GET requests for /v2/events, streaming
+// submission and crawler activity as Server-Sent Events. A client
+// reconnecting with a Last-Event-ID header is first caught up from the
+// broadcaster's replay buffer, then streamed live events and a heartbeat
+// comment every EventHeartbeatInterval so idle connections survive proxies.
+func newV2EventsGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+
+		var lastEventID int64
+		if h := req.Header.Get("Last-Event-ID"); h != "" {
+			if v, err := strconv.ParseInt(h, 10, 64); err == nil {
+				lastEventID = v
+			}
+		}
+
+		ch := make(chan Event, EventSubscriberBufferSize)
+		s.events.Add(ch)
+		defer s.events.Remove(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for _, ev := range s.events.Since(lastEventID) {
+			writeEventSSE(w, flusher, ev)
+		}
+
+		heartbeat := time.NewTicker(EventHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case ev := <-ch:
+				writeEventSSE(w, flusher, ev)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}
+	return http.HandlerFunc(f)
+}
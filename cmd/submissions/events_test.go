@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBroadcaster_PublishDeliversToSubscriber(t *testing.T) {
+	b := newEventBroadcaster()
+	ch := make(chan Event, 1)
+	b.Add(ch)
+	defer b.Remove(ch)
+
+	ev := b.Publish("crawler.stopped", nil)
+	assert.Equal(t, int64(1), ev.ID)
+	received := <-ch
+	assert.Equal(t, ev, received)
+}
+
+func TestEventBroadcaster_RemoveStopsDelivery(t *testing.T) {
+	b := newEventBroadcaster()
+	ch := make(chan Event, 1)
+	b.Add(ch)
+	b.Remove(ch)
+
+	b.Publish("crawler.stopped", nil)
+	select {
+	case <-ch:
+		t.Fatal("expected no event after Remove")
+	default:
+	}
+}
+
+func TestEventBroadcaster_FullSubscriberGetsDroppedInstead(t *testing.T) {
+	b := newEventBroadcaster()
+	ch := make(chan Event, 1)
+	b.Add(ch)
+	defer b.Remove(ch)
+
+	b.Publish("crawler.reinit", nil) // fills the buffer
+	b.Publish("crawler.reinit", nil) // buffer full: evicts the above and leaves a "dropped" event instead
+
+	select {
+	case ev := <-ch:
+		assert.Equal(t, "dropped", ev.Type)
+	default:
+		t.Fatal("expected a dropped event")
+	}
+}
+
+func TestEventBroadcaster_SinceReplaysOnlyNewerEvents(t *testing.T) {
+	b := newEventBroadcaster()
+	first := b.Publish("crawler.reinit", nil)
+	second := b.Publish("crawler.reinit", nil)
+	third := b.Publish("crawler.reinit", nil)
+
+	replay := b.Since(first.ID)
+	assert.Equal(t, []Event{second, third}, replay)
+	assert.Empty(t, b.Since(third.ID))
+}
+
+func TestEventBroadcaster_ProfileCountsTrackAndDrain(t *testing.T) {
+	b := newEventBroadcaster()
+	b.PublishSubmissionAccepted(submissionEventData{Submitter: "alice", Profile: "default"})
+	b.PublishSubmissionAccepted(submissionEventData{Submitter: "bob", Profile: "default"})
+	b.PublishSubmissionAccepted(submissionEventData{Submitter: "alice", Profile: "custom"})
+
+	assert.Equal(t, 2, b.ProfileCount("default"))
+	assert.Equal(t, 1, b.ProfileCount("custom"))
+
+	counts := b.DrainProfileCounts()
+	assert.Equal(t, map[string]int{"default": 2, "custom": 1}, counts)
+	assert.Equal(t, 0, b.ProfileCount("default"))
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -8,34 +10,86 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/loda-lang/loda-api/cmd"
 	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/shared/operations"
+	"github.com/loda-lang/loda-api/shared/wal"
 	"github.com/loda-lang/loda-api/util"
 )
 
 const (
-	NumSubmissionsLow           = 1000
-	NumSubmissionsHigh          = 2000
-	NumSubmissionsMax           = 50000
-	NumSubmissionsPerUser       = 100
-	MaxProgramLength            = 100000
-	CheckpointInterval          = 10 * time.Minute
-	CheckSessionInterval        = 24 * time.Hour
-	CheckpointFile              = "checkpoint.json"
-	OeisWebsite                 = "https://oeis.org/"
-	SequenceRefreshLimitPerHour = 200
+	NumSubmissionsLow    = 1000
+	NumSubmissionsHigh   = 2000
+	NumSubmissionsMax    = 50000
+	MaxProgramLength     = 100000
+	CheckpointInterval   = 10 * time.Minute
+	CheckSessionInterval = 24 * time.Hour
+	CheckpointFile       = "checkpoint.json"
+	SubmissionsWalName   = "submissions_wal"
+	OeisWebsite          = "https://oeis.org/"
+
+	// CheckpointFormatJSON and CheckpointFormatGzipJSON are the values
+	// CrawlerConfig.CheckpointFormat accepts; see writeCheckpoint and
+	// loadCheckpoint.
+	CheckpointFormatJSON     = "json"
+	CheckpointFormatGzipJSON = "gzip-json"
+
+	// DefaultSequenceRefreshLimitPerHour and DefaultNumSubmissionsPerUser
+	// seed CrawlerConfig the first time a server starts; after that they're
+	// tunable at runtime via GET/PUT /v2/config. SequenceRefreshLimitPerHour
+	// is now a per-submitter rate (see refreshRateLimiter), not a global one.
+	DefaultSequenceRefreshLimitPerHour = 200
+	DefaultNumSubmissionsPerUser       = 100
+
+	// refreshRateLimiterBurst lets a submitter use up to this many refreshes
+	// in one burst, consuming from their hourly allowance at once instead of
+	// being forced to pace one-per-(3600/limit)-seconds.
+	refreshRateLimiterBurst = 10
+
+	// refreshRateLimiterIdleTTL bounds how long an idle submitter's refresh
+	// bucket is kept in memory before the janitor reclaims it.
+	refreshRateLimiterIdleTTL = 24 * time.Hour
+
+	// ServerReadHeaderTimeout and ServerIdleTimeout bound how long the HTTP
+	// server waits on a slow client, so one can't tie up a connection slot
+	// forever. Neither applies once a handler has started writing a
+	// response, so they don't affect /v2/events' long-lived SSE streams.
+	ServerReadHeaderTimeout = 10 * time.Second
+	ServerIdleTimeout       = 120 * time.Second
+
+	// ServerShutdownGracePeriod is how long Run waits, on SIGINT/SIGTERM,
+	// for in-flight requests to finish before srv.Shutdown gives up and
+	// closes their connections outright.
+	ServerShutdownGracePeriod = 30 * time.Second
 )
 
 type OperationResult struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
+	// Position is the 1-based position a sequence refresh was queued at, set
+	// only by refreshSequence on success.
+	Position int `json:"position,omitempty"`
+	// Operation tracks an async submission (so far, just sequence
+	// refreshes) accepted by refreshSequence, so the caller can poll
+	// GET /v2/operations/{id} for its outcome instead of assuming success.
+	Operation *operations.Operation `json:"operation,omitempty"`
+	// retryAfter is set by refreshSequence when it rejects a submission for
+	// being rate-limited, so newV2SubmissionsPostHandler can also surface it
+	// as a Retry-After header. Deliberately unexported: it's a hint to the
+	// same package's HTTP layer, not part of the JSON API.
+	retryAfter time.Duration
 }
 
 type SubmissionsServer struct {
@@ -44,24 +98,43 @@ type SubmissionsServer struct {
 	influxDbClient        *util.InfluxDbClient
 	session               time.Time
 	submissions           []shared.Submission // Unified submissions (v1 and v2)
-	submissionsPerProfile map[string]int
 	submissionsPerUser    map[string]int
-	refreshSubmissions    []time.Time // Tracks sequence refresh submission timestamps for rate limiting
+	refreshRateLimiter    RateLimiter           // Per-submitter token bucket for sequence refreshes (see refreshSequence)
+	tokenUsage            map[int64][]time.Time // Per-token submission timestamps, for tokens with a PerHourLimit/PerDayLimit
 	httpClient            *http.Client
 	crawler               *shared.Crawler
 	lists                 []*shared.List
-	crawlerFetchInterval  time.Duration
-	crawlerRestartPause   time.Duration
-	crawlerFlushInterval  int
-	crawlerReinitInterval int
-	crawlerIdsCacheSize   int
-	crawlerIdsFetchRatio  float64
-	crawlerMaxQueueSize   int
-	crawlerStopped        chan bool
+	crawlerFetchDeadline  time.Duration
+	runCancel             context.CancelFunc
+	crawlerWG             sync.WaitGroup
+	fetchTickerMutex      sync.Mutex
+	fetchTicker           *time.Ticker
 	submissionsMutex      sync.Mutex
+	submitterStore        *shared.SubmitterStore
+	anonymousMode         bool
+	requestTimeout        time.Duration
+	authURL               string
+	authFailOpen          bool
+	submissionRateLimiter *SubmissionRateLimiter
+	operations            *operations.Store
+	wal                   *wal.WAL
+	config                *ConfigStore
+	events                *eventBroadcaster
+	checkpointLoaded      int32                               // 1 once loadCheckpoint has returned; read by /readyz
+	crawlerReady          int32                               // 1 once StartCrawler's first Init has succeeded; read by /readyz
+	uploadSessions        map[string]*submissionUploadSession // in-progress chunked uploads, keyed by session id; see upload_session.go
+}
+
+// checkpointSnapshot is the JSON shape written to CheckpointFile: every
+// piece of in-memory state writeCheckpoint needs to make durable besides
+// what's already captured per-submission in the write-ahead log.
+type checkpointSnapshot struct {
+	Submissions        []shared.Submission   `json:"submissions"`
+	SubmissionsPerUser map[string]int        `json:"submissions_per_user"`
+	TokenUsage         map[int64][]time.Time `json:"token_usage"`
 }
 
-func NewSubmissionsServer(dataDir string, oeisDir string, influxDbClient *util.InfluxDbClient) *SubmissionsServer {
+func NewSubmissionsServer(dataDir string, oeisDir string, influxDbClient *util.InfluxDbClient, anonymousMode bool, requestTimeout, refreshDrainTimeout, crawlerFetchDeadline time.Duration, authURL string, authFailOpen bool, rateLimitPerMinute int) *SubmissionsServer {
 	httpClient := &http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -77,26 +150,60 @@ func NewSubmissionsServer(dataDir string, oeisDir string, influxDbClient *util.I
 		lists[i] = shared.NewList(key, name, oeisDir)
 		i++
 	}
-	return &SubmissionsServer{
+	recentFetchTTL := 24 * time.Hour
+	s := &SubmissionsServer{
 		dataDir:               dataDir,
 		oeisDir:               oeisDir,
 		influxDbClient:        influxDbClient,
 		session:               time.Now(),
 		submissions:           []shared.Submission{},
-		submissionsPerProfile: make(map[string]int),
 		submissionsPerUser:    make(map[string]int),
-		refreshSubmissions:    []time.Time{},
+		refreshRateLimiter:    NewTokenBucketRateLimiter(DefaultSequenceRefreshLimitPerHour, refreshRateLimiterBurst, refreshRateLimiterIdleTTL),
+		tokenUsage:            make(map[int64][]time.Time),
 		httpClient:            httpClient,
-		crawler:               shared.NewCrawler(httpClient),
+		crawler:               shared.NewCrawler(httpClient, dataDir, recentFetchTTL, refreshDrainTimeout, crawlerFetchDeadline, nil),
 		lists:                 lists,
-		crawlerFetchInterval:  1 * time.Minute,
-		crawlerRestartPause:   24 * time.Hour,
-		crawlerFlushInterval:  100,
-		crawlerReinitInterval: 2000,
-		crawlerIdsCacheSize:   1000,
-		crawlerIdsFetchRatio:  0.5,
-		crawlerMaxQueueSize:   10000,
-		crawlerStopped:        make(chan bool),
+		crawlerFetchDeadline:  crawlerFetchDeadline,
+		submitterStore:        shared.NewSubmitterStore(dataDir),
+		anonymousMode:         anonymousMode,
+		requestTimeout:        requestTimeout,
+		authURL:               authURL,
+		authFailOpen:          authFailOpen,
+		submissionRateLimiter: NewSubmissionRateLimiter(rateLimitPerMinute),
+		operations:            operations.NewStore(dataDir),
+		wal:                   wal.New(dataDir, SubmissionsWalName, wal.DefaultMaxSegmentBytes),
+		events:                newEventBroadcaster(),
+		uploadSessions:        make(map[string]*submissionUploadSession),
+	}
+	s.config = NewConfigStore(dataDir, CrawlerConfig{
+		FetchInterval:               1 * time.Minute,
+		FlushInterval:               100,
+		ReinitInterval:              2000,
+		IdsCacheSize:                1000,
+		IdsFetchRatio:               0.5,
+		MaxQueueSize:                10000,
+		RestartPause:                24 * time.Hour,
+		SequenceRefreshLimitPerHour: DefaultSequenceRefreshLimitPerHour,
+		NumSubmissionsPerUser:       DefaultNumSubmissionsPerUser,
+		CheckpointFormat:            CheckpointFormatGzipJSON,
+	})
+	s.config.OnChange(s.handleConfigChange)
+	return s
+}
+
+// handleConfigChange reacts to a runtime config update (via PUT /v2/config
+// or POST /v2/config/reload), resetting the crawler's fetch ticker so a
+// changed FetchInterval takes effect without a restart. The other knobs in
+// cfg are re-read from s.config directly wherever they're used, so they
+// need no such nudge.
+func (s *SubmissionsServer) handleConfigChange(cfg CrawlerConfig) {
+	s.fetchTickerMutex.Lock()
+	defer s.fetchTickerMutex.Unlock()
+	if s.fetchTicker != nil {
+		s.fetchTicker.Reset(cfg.FetchInterval)
+	}
+	if limiter, ok := s.refreshRateLimiter.(*TokenBucketRateLimiter); ok {
+		limiter.SetRate(float64(cfg.SequenceRefreshLimitPerHour), refreshRateLimiterBurst)
 	}
 }
 
@@ -107,36 +214,110 @@ func (s *SubmissionsServer) checkSubmit(submission shared.Submission) (bool, Ope
 	s.checkSession()
 	if len(s.submissions) > NumSubmissionsMax {
 		log.Print("Maximum number of submissions exceeded")
-		return false, OperationResult{Status: "error", Message: "Too many total submissions"}
+		return s.rejectSubmissionLocked(submission, "Too many total submissions")
 	}
-	if s.submissionsPerUser[submission.Submitter] >= NumSubmissionsPerUser {
+	cfg, _ := s.config.Get()
+	if s.submissionsPerUser[submission.Submitter] >= cfg.NumSubmissionsPerUser {
 		log.Printf("Rejected submission from %s", submission.Submitter)
-		return false, OperationResult{Status: "error", Message: "Too many user submissions"}
+		return s.rejectSubmissionLocked(submission, "Too many user submissions")
 	}
 	// Skip duplicate check for remove mode
 	if submission.Mode != shared.ModeRemove {
 		for _, p := range s.submissions {
 			if slices.Equal(p.Operations, submission.Operations) {
-				return false, OperationResult{Status: "error", Message: "Duplicate submission"}
+				return s.rejectSubmissionLocked(submission, "Duplicate submission")
 			}
 		}
 	}
 	return true, OperationResult{}
 }
 
-func (s *SubmissionsServer) doSubmit(submission shared.Submission) OperationResult {
+// rejectSubmissionLocked publishes a submission.rejected event for reason
+// and returns the (false, OperationResult) pair checkSubmit/refreshSequence
+// hand back to their caller. Caller must hold submissionsMutex.
+func (s *SubmissionsServer) rejectSubmissionLocked(submission shared.Submission, reason string) (bool, OperationResult) {
+	s.events.Publish("submission.rejected", submissionEventData{
+		Submitter: submission.Submitter,
+		Type:      string(submission.Type),
+		Mode:      string(submission.Mode),
+		Reason:    reason,
+	})
+	return false, OperationResult{Status: "error", Message: reason}
+}
+
+// applySubmissionLocked records submission in memory: appending it to the
+// submissions slice and bumping its per-user/per-profile counters. It
+// returns the profile the submission was counted under. Caller must hold
+// submissionsMutex.
+func (s *SubmissionsServer) applySubmissionLocked(submission shared.Submission) string {
 	profile := submission.MinerProfile
 	if len(profile) == 0 {
 		profile = "unknown"
 	}
-	s.submissionsMutex.Lock()
-	defer s.submissionsMutex.Unlock()
 	s.submissions = append(s.submissions, submission)
 	s.submissionsPerUser[submission.Submitter]++
-	s.submissionsPerProfile[profile]++
+	return profile
+}
+
+// checkTokenQuota enforces a bearer token's per-hour/per-day submission
+// quota (either limit 0 means unlimited) against its rolling 24h usage
+// history, recording this attempt if it's allowed. Unlike
+// refreshSequence's token-bucket rate limiting, the per-hour and per-day
+// limits here don't collapse onto a single refill rate, so this keeps its
+// own sliding-window list per token.
+func (s *SubmissionsServer) checkTokenQuota(tokenID int64, perHourLimit, perDayLimit int) (bool, string) {
+	if perHourLimit == 0 && perDayLimit == 0 {
+		return true, ""
+	}
+	s.submissionsMutex.Lock()
+	defer s.submissionsMutex.Unlock()
+	now := time.Now()
+	dayAgo := now.Add(-24 * time.Hour)
+	hourAgo := now.Add(-1 * time.Hour)
+	var kept []time.Time
+	hourCount, dayCount := 0, 0
+	for _, ts := range s.tokenUsage[tokenID] {
+		if !ts.After(dayAgo) {
+			continue
+		}
+		kept = append(kept, ts)
+		dayCount++
+		if ts.After(hourAgo) {
+			hourCount++
+		}
+	}
+	if perHourLimit > 0 && hourCount >= perHourLimit {
+		s.tokenUsage[tokenID] = kept
+		return false, fmt.Sprintf("Token rate limit exceeded: maximum %d submissions per hour", perHourLimit)
+	}
+	if perDayLimit > 0 && dayCount >= perDayLimit {
+		s.tokenUsage[tokenID] = kept
+		return false, fmt.Sprintf("Token rate limit exceeded: maximum %d submissions per day", perDayLimit)
+	}
+	s.tokenUsage[tokenID] = append(kept, now)
+	return true, ""
+}
+
+func (s *SubmissionsServer) doSubmit(submission shared.Submission) OperationResult {
+	s.submissionsMutex.Lock()
+	defer s.submissionsMutex.Unlock()
+	// Fsync the submission to the write-ahead log before acknowledging it,
+	// so a crash between here and the next checkpoint can't lose it.
+	if err := s.wal.Append(submission); err != nil {
+		log.Printf("Failed to append submission to write-ahead log: %v", err)
+		return OperationResult{Status: "error", Message: "Cannot persist submission"}
+	}
+	profile := s.applySubmissionLocked(submission)
+	cfg, _ := s.config.Get()
+	s.events.PublishSubmissionAccepted(submissionEventData{
+		Submitter: submission.Submitter,
+		Type:      string(submission.Type),
+		Mode:      string(submission.Mode),
+		Profile:   profile,
+	})
 	msg := fmt.Sprintf("Accepted submission from %s (%d/%d); profile %s (%d)",
-		submission.Submitter, s.submissionsPerUser[submission.Submitter], NumSubmissionsPerUser,
-		profile, s.submissionsPerProfile[profile])
+		submission.Submitter, s.submissionsPerUser[submission.Submitter], cfg.NumSubmissionsPerUser,
+		profile, s.events.ProfileCount(profile))
 	log.Print(msg)
 	return OperationResult{Status: "success", Message: "Accepted submission"}
 }
@@ -151,77 +332,173 @@ func (s *SubmissionsServer) getBFilePath(id util.UID) string {
 	return filepath.Join(dir, filename)
 }
 
-// refreshSequence adds a sequence ID to the crawler's next IDs queue
-// and deletes the b-file if it exists
-func (s *SubmissionsServer) refreshSequence(submission shared.Submission) OperationResult {
-	idStr := submission.Id.String()
+// bfileEpoch is the sentinel mtime refreshSequence backdates a b-file to,
+// marking it stale without deleting it: util.IsFileRecent (used by the b-file
+// HTTP handler to decide whether to refetch from OEIS) compares mtime
+// against a TTL, and nothing predates this sentinel, so it's always treated
+// as older than any configured TTL.
+var bfileEpoch = time.Unix(0, 0)
 
-	// Check rate limit (200 per hour)
-	s.submissionsMutex.Lock()
-	now := time.Now()
-	oneHourAgo := now.Add(-1 * time.Hour)
+// refreshSequence adds a sequence ID to the crawler's next IDs queue and
+// marks its b-file stale, rather than deleting it outright, by backdating
+// its modification time to bfileEpoch. A reader hitting the b-file endpoint
+// while the refetch is still pending gets the old-but-valid bytes instead
+// of a cache miss; the next request past BfileUpdateInterval triggers the
+// refetch, and a storm of refresh submissions for the same ID collapses
+// into that single refetch instead of each deleting and re-fetching in
+// turn.
+func (s *SubmissionsServer) refreshSequence(ctx context.Context, submission shared.Submission) OperationResult {
+	idStr := submission.Id.String()
+	cfg, _ := s.config.Get()
 
-	// Remove timestamps older than 1 hour
-	validRefreshes := []time.Time{}
-	for _, ts := range s.refreshSubmissions {
-		if ts.After(oneHourAgo) {
-			validRefreshes = append(validRefreshes, ts)
-		}
+	// Check the submitter's refresh rate limit (cfg.SequenceRefreshLimitPerHour
+	// per submitter per hour). An empty submitter (anonymous refresh, if the
+	// server allows it) shares one bucket so it can't bypass the limit by
+	// omitting the field.
+	key := submission.Submitter
+	if key == "" {
+		key = "anonymous"
 	}
-	s.refreshSubmissions = validRefreshes
-
-	// Check if we've exceeded the limit
-	if len(s.refreshSubmissions) >= SequenceRefreshLimitPerHour {
-		s.submissionsMutex.Unlock()
-		remaining := s.refreshSubmissions[0].Add(1 * time.Hour).Sub(now)
-		remainingSeconds := int(remaining.Seconds())
-		msg := fmt.Sprintf("Rate limit exceeded: maximum %d sequence refreshes per hour. Please try again in %d seconds.", SequenceRefreshLimitPerHour, remainingSeconds)
+	if allowed, retryAfter := s.refreshRateLimiter.Allow(key); !allowed {
+		remainingSeconds := int(retryAfter.Round(time.Second).Seconds())
+		msg := fmt.Sprintf("Rate limit exceeded: maximum %d sequence refreshes per hour. Please try again in %d seconds.", cfg.SequenceRefreshLimitPerHour, remainingSeconds)
 		log.Printf("%s: %s", msg, submission.Submitter)
-		return OperationResult{Status: "error", Message: msg}
+		s.events.Publish("submission.rejected", submissionEventData{
+			Submitter: submission.Submitter,
+			Type:      string(submission.Type),
+			Mode:      string(submission.Mode),
+			Reason:    msg,
+		})
+		return OperationResult{Status: "error", Message: msg, retryAfter: retryAfter}
 	}
 
-	// Record the refresh submission
-	s.refreshSubmissions = append(s.refreshSubmissions, now)
-	s.submissionsMutex.Unlock()
-
-	// Delete the b-file if it exists
+	// Mark the b-file stale instead of deleting it, so concurrent readers
+	// keep seeing a valid (if outdated) file until it's refetched.
 	bfilePath := s.getBFilePath(submission.Id)
 	if util.FileExists(bfilePath) {
-		if err := os.Remove(bfilePath); err != nil {
-			log.Printf("Warning: Failed to remove b-file %s during refresh: %v", bfilePath, err)
-			// Continue with refresh even if b-file deletion fails
+		if err := os.Chtimes(bfilePath, bfileEpoch, bfileEpoch); err != nil {
+			log.Printf("Warning: Failed to mark b-file %s stale during refresh: %v", bfilePath, err)
+			// Continue with refresh even if marking the b-file stale fails
 		} else {
-			log.Printf("Deleted b-file for sequence %s during refresh", idStr)
+			log.Printf("Marked b-file for sequence %s stale during refresh", idStr)
 		}
 	}
 
 	// Add to crawler queue
-	success := s.crawler.AddNextId(int(submission.Id.Number()), s.crawlerMaxQueueSize)
-	if !success {
-		log.Printf("Failed to add sequence %s to crawler queue (queue full)", idStr)
-		return OperationResult{Status: "error", Message: "Crawler queue is full, please retry later"}
+	position, err := s.crawler.AddNextId(ctx, int(submission.Id.Number()), cfg.MaxQueueSize)
+	if err != nil {
+		log.Printf("Failed to add sequence %s to crawler queue: %v", idStr, err)
+		return OperationResult{Status: "error", Message: err.Error()}
 	}
 
-	log.Printf("Added sequence %s to crawler queue by %s", idStr, submission.Submitter)
-	return OperationResult{Status: "success", Message: fmt.Sprintf("Sequence %s added to crawler queue", idStr)}
+	result := OperationResult{Status: "success", Message: fmt.Sprintf("Sequence %s added to crawler queue", idStr), Position: position}
+	if op, opErr := s.operations.Create(submission.Submitter, submission.Id.Number(), position); opErr != nil {
+		log.Printf("Failed to create operation for sequence %s: %v", idStr, opErr)
+	} else {
+		result.Operation = &op
+		s.events.Publish("operation.updated", op)
+	}
+
+	log.Printf("Added sequence %s to crawler queue by %s (position %d)", idStr, submission.Submitter, position)
+	return result
 }
 
+// writeCheckpoint snapshots every submission accepted so far, plus the
+// counters that go with them, to CheckpointFile or CheckpointFile+".gz",
+// depending on the configured CheckpointFormat. Once the snapshot is
+// durably on disk it covers everything the write-ahead log held, so the
+// WAL is truncated: loadCheckpoint only ever has to replay records
+// appended after this point.
 func (s *SubmissionsServer) writeCheckpoint() error {
 	s.submissionsMutex.Lock()
 	defer s.submissionsMutex.Unlock()
-	f, err := os.Create(filepath.Join(s.dataDir, CheckpointFile))
+	snapshot := checkpointSnapshot{
+		Submissions:        s.submissions,
+		SubmissionsPerUser: s.submissionsPerUser,
+		TokenUsage:         s.tokenUsage,
+	}
+	raw, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
-		return fmt.Errorf("cannot open checkpoint file: %v", err)
+		return fmt.Errorf("cannot marshal checkpoint: %v", err)
+	}
+	cfg, _ := s.config.Get()
+	checkpointPath := filepath.Join(s.dataDir, CheckpointFile)
+	gzCheckpointPath := checkpointPath + ".gz"
+	if cfg.CheckpointFormat == CheckpointFormatJSON {
+		tmpPath := checkpointPath + ".tmp"
+		if err := os.WriteFile(tmpPath, raw, 0644); err != nil {
+			return fmt.Errorf("cannot write checkpoint file: %v", err)
+		}
+		if err := os.Rename(tmpPath, checkpointPath); err != nil {
+			return fmt.Errorf("cannot rename checkpoint file: %v", err)
+		}
+		// An operator may have switched back from gzip-json; drop the stale
+		// compressed snapshot so loadCheckpoint doesn't prefer it next time.
+		os.Remove(gzCheckpointPath)
+	} else {
+		tmpPath := gzCheckpointPath + ".tmp"
+		tmpFile, err := os.Create(tmpPath)
+		if err != nil {
+			return fmt.Errorf("cannot create checkpoint file: %v", err)
+		}
+		gz := util.GetGzipWriter(tmpFile)
+		_, writeErr := gz.Write(raw)
+		gzCloseErr := gz.Close()
+		util.PutGzipWriter(gz)
+		if writeErr == nil {
+			writeErr = gzCloseErr
+		}
+		if fileCloseErr := tmpFile.Close(); writeErr == nil {
+			writeErr = fileCloseErr
+		}
+		if writeErr != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("cannot write checkpoint file: %v", writeErr)
+		}
+		if err := os.Rename(tmpPath, gzCheckpointPath); err != nil {
+			return fmt.Errorf("cannot rename checkpoint file: %v", err)
+		}
+		os.Remove(checkpointPath)
 	}
-	defer f.Close()
-	encoder := json.NewEncoder(f)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(s.submissions); err != nil {
-		return fmt.Errorf("cannot write to checkpoint file: %v", err)
+	if err := s.wal.Truncate(); err != nil {
+		return fmt.Errorf("cannot truncate submissions write-ahead log: %v", err)
 	}
 	return nil
 }
 
+// readCheckpointSnapshot decodes the checkpoint at gzPath if it exists,
+// else falls back to plainPath. It returns a zero snapshot and an empty
+// path, with no error, if neither file exists yet (e.g. a brand new
+// server). The returned path is whichever one was actually read, for
+// logging.
+func readCheckpointSnapshot(gzPath, plainPath string) (checkpointSnapshot, string, error) {
+	var snapshot checkpointSnapshot
+	if file, err := os.Open(gzPath); err == nil {
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return snapshot, "", fmt.Errorf("cannot open gzip reader for %s: %w", gzPath, err)
+		}
+		defer gz.Close()
+		if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+			return snapshot, "", fmt.Errorf("cannot decode checkpoint %s: %w", gzPath, err)
+		}
+		return snapshot, gzPath, nil
+	}
+	file, err := os.Open(plainPath)
+	if os.IsNotExist(err) {
+		return snapshot, "", nil
+	}
+	if err != nil {
+		return snapshot, "", fmt.Errorf("cannot open %s: %w", plainPath, err)
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(&snapshot); err != nil {
+		return snapshot, "", fmt.Errorf("cannot decode checkpoint %s: %w", plainPath, err)
+	}
+	return snapshot, plainPath, nil
+}
+
 func (s *SubmissionsServer) checkSession() {
 	if len(s.submissions) < NumSubmissionsHigh {
 		return
@@ -238,16 +515,16 @@ func (s *SubmissionsServer) checkSession() {
 	}
 }
 
+// publishMetrics pushes the per-profile submission counts accumulated by
+// s.events (via PublishSubmissionAccepted) since the last call to InfluxDB,
+// resetting them. The counts live on the broadcaster rather than in a
+// separate field here, since it's already the thing counting
+// submission.accepted events.
 func (s *SubmissionsServer) publishMetrics() {
-	s.submissionsMutex.Lock()
-	defer s.submissionsMutex.Unlock()
-	totalCount := 0
-	for profile, count := range s.submissionsPerProfile {
+	for profile, count := range s.events.DrainProfileCounts() {
 		labels := map[string]string{"kind": "submitted", "profile": profile}
 		s.influxDbClient.Write("programs", labels, count)
-		totalCount += count
 	}
-	s.submissionsPerProfile = make(map[string]int)
 }
 
 func (s *SubmissionsServer) clearUserStats() {
@@ -256,22 +533,60 @@ func (s *SubmissionsServer) clearUserStats() {
 	s.submissionsPerUser = make(map[string]int)
 }
 
+// loadCheckpoint loads the latest snapshot written by writeCheckpoint, then
+// replays every submission the write-ahead log has recorded since (the ones
+// accepted after that snapshot, or all of them if no snapshot exists yet),
+// so no acknowledged submission is lost across a restart. Before reading
+// anything, it clears out any "*.tmp"/"*.lock" file a prior crash left
+// behind mid-write, so a half-written checkpoint or WAL meta file from the
+// last time the process died can't be mistaken for live state.
+//
+// It prefers CheckpointFile+".gz" if present, falling back to the legacy
+// plain CheckpointFile so a checkpoint written by a binary that predates
+// CheckpointFormatGzipJSON still loads; the next writeCheckpoint then
+// migrates it to whatever format is currently configured.
 func (s *SubmissionsServer) loadCheckpoint() {
+	util.RemoveStaleTempFiles(s.dataDir)
+	util.RemoveStaleTempFiles(s.sessionsDir())
+	s.loadUploadSessions()
 	checkpointPath := filepath.Join(s.dataDir, CheckpointFile)
-	file, err := os.Open(checkpointPath)
-	if err != nil {
-		log.Printf("Cannot load checkpoint %s", checkpointPath)
-		return
+	gzCheckpointPath := checkpointPath + ".gz"
+	if snapshot, path, err := readCheckpointSnapshot(gzCheckpointPath, checkpointPath); err != nil {
+		log.Printf("Cannot load checkpoint: %v", err)
+	} else if path != "" {
+		log.Printf("Loaded %v submissions from checkpoint %s", len(snapshot.Submissions), path)
+		s.submissions = snapshot.Submissions
+		s.submissionsPerUser = snapshot.SubmissionsPerUser
+		s.tokenUsage = snapshot.TokenUsage
 	}
-	defer file.Close()
-	log.Printf("Loading checkpoint %s", checkpointPath)
-	s.submissions = []shared.Submission{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&s.submissions); err != nil {
-		log.Printf("Cannot decode checkpoint JSON: %v", err)
-		return
+	if s.submissions == nil {
+		s.submissions = []shared.Submission{}
+	}
+	if s.submissionsPerUser == nil {
+		s.submissionsPerUser = make(map[string]int)
+	}
+	if s.tokenUsage == nil {
+		s.tokenUsage = make(map[int64][]time.Time)
+	}
+
+	s.submissionsMutex.Lock()
+	defer s.submissionsMutex.Unlock()
+	replayed := 0
+	if err := s.wal.Replay(func(payload []byte) error {
+		var submission shared.Submission
+		if err := json.Unmarshal(payload, &submission); err != nil {
+			return fmt.Errorf("cannot decode wal record: %w", err)
+		}
+		s.applySubmissionLocked(submission)
+		replayed++
+		return nil
+	}); err != nil {
+		log.Printf("Cannot replay submissions write-ahead log: %v", err)
+	}
+	if replayed > 0 {
+		log.Printf("Replayed %d submission(s) from write-ahead log", replayed)
 	}
-	log.Printf("Loaded %v submissions from checkpoint", len(s.submissions))
+	atomic.StoreInt32(&s.checkpointLoaded, 1)
 }
 
 // newV2SubmissionsGetHandler handles GET requests for v2/submissions
@@ -281,7 +596,7 @@ func newV2SubmissionsGetHandler(s *SubmissionsServer) http.Handler {
 			util.WriteHttpMethodNotAllowed(w)
 			return
 		}
-		limit, skip, _ := util.ParseLimitSkipShuffle(req, 10, 100)
+		limit, skip, _, orderBy := util.ParseListParams(req, 10, 100, shared.SubmissionOrderByRegistry)
 
 		// Get filter parameters
 		modeFilter := req.URL.Query().Get("mode")
@@ -309,6 +624,12 @@ func newV2SubmissionsGetHandler(s *SubmissionsServer) http.Handler {
 			filtered = append(filtered, sub)
 		}
 
+		if orderBy != nil {
+			sort.SliceStable(filtered, func(i, j int) bool {
+				return orderBy(filtered[i], filtered[j]) < 0
+			})
+		}
+
 		total := len(filtered)
 		results := []shared.Submission{}
 
@@ -326,6 +647,11 @@ func newV2SubmissionsGetHandler(s *SubmissionsServer) http.Handler {
 			results = filtered[start:end]
 		}
 
+		if req.URL.Query().Get("format") == "ndjson" {
+			util.WriteNDJSONStream(w, req, util.SliceSeq(results))
+			return
+		}
+
 		resp := shared.SubmissionsResult{
 			Session: s.session.Unix(),
 			Total:   total,
@@ -359,6 +685,23 @@ func newV2SubmissionsPostHandler(s *SubmissionsServer) http.Handler {
 			return
 		}
 
+		// A slow disk write below can outlast the client: bail out here
+		// instead of recording a submission the caller already gave up on.
+		if req.Context().Err() != nil {
+			return
+		}
+
+		submission, res, err := s.authorizeSubmission(req, submission)
+		if err != nil {
+			log.Printf("Submission pre-authorization failed: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		if res.Status == "error" {
+			util.WriteJsonResponse(w, res)
+			return
+		}
+
 		// Handle different submission types
 		switch submission.Type {
 		case shared.TypeProgram:
@@ -383,10 +726,12 @@ func newV2SubmissionsPostHandler(s *SubmissionsServer) http.Handler {
 			util.WriteJsonResponse(w, res)
 		case shared.TypeSequence:
 			// Only refresh mode is allowed for sequences (already validated in UnmarshalJSON)
-			res := s.refreshSequence(submission)
+			res := s.refreshSequence(req.Context(), submission)
 			if res.Status == "success" {
 				// Record submission if refresh was successful
 				s.doSubmit(submission)
+			} else if res.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(res.retryAfter.Round(time.Second)/time.Second)))
 			}
 			util.WriteJsonResponse(w, res)
 		default:
@@ -397,10 +742,18 @@ func newV2SubmissionsPostHandler(s *SubmissionsServer) http.Handler {
 	return http.HandlerFunc(f)
 }
 
+// StopCrawler cancels the running crawl, including any fetch currently in
+// flight, and schedules it to start again after crawlerRestartPause. It does
+// not wait for the crawl goroutine to drain; use Shutdown for a final,
+// non-restarting stop.
 func (s *SubmissionsServer) StopCrawler() {
 	log.Print("Stopping crawler")
-	s.crawlerStopped <- true
-	restartTimer := time.NewTimer(s.crawlerRestartPause)
+	s.events.Publish("crawler.stopped", nil)
+	if s.runCancel != nil {
+		s.runCancel()
+	}
+	cfg, _ := s.config.Get()
+	restartTimer := time.NewTimer(cfg.RestartPause)
 	go func() {
 		<-restartTimer.C
 		s.StartCrawler()
@@ -432,32 +785,46 @@ func filterValidKeywordsFields(fields []shared.Field) []shared.Field {
 }
 
 func (s *SubmissionsServer) StartCrawler() {
-	err := s.crawler.Init()
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.crawler.Init(ctx)
 	if err != nil {
 		log.Printf("Error initializing crawler: %v", err)
+		cancel()
 		return
 	}
-	fetchTicker := time.NewTicker(s.crawlerFetchInterval)
-	s.crawlerStopped = make(chan bool)
+	atomic.StoreInt32(&s.crawlerReady, 1)
+	cfg, _ := s.config.Get()
+	fetchTicker := time.NewTicker(cfg.FetchInterval)
+	s.fetchTickerMutex.Lock()
+	s.fetchTicker = fetchTicker
+	s.fetchTickerMutex.Unlock()
+	s.runCancel = cancel
+	s.crawlerWG.Add(1)
 	go func() {
+		defer s.crawlerWG.Done()
+		defer fetchTicker.Stop()
 		for {
 			select {
-			case <-s.crawlerStopped:
+			case <-ctx.Done():
 				return
 			case <-fetchTicker.C:
-				s.handleCrawlerTick()
+				s.handleCrawlerTick(ctx)
 			}
 		}
 	}()
 }
 
-// handleCrawlerTick contains the logic for each fetchTicker tick in StartCrawler
-func (s *SubmissionsServer) handleCrawlerTick() {
+// handleCrawlerTick contains the logic for each fetchTicker tick in
+// StartCrawler. ctx is cancelled by StopCrawler/Shutdown, which aborts any
+// fetch or list I/O currently in flight instead of leaving it to run to
+// completion.
+func (s *SubmissionsServer) handleCrawlerTick(ctx context.Context) {
+	cfg, _ := s.config.Get()
 	if s.crawler.NumFetched() > 0 {
 		// Regularly flush the lists
-		if s.crawler.NumFetched()%s.crawlerFlushInterval == 0 {
+		if s.crawler.NumFetched()%cfg.FlushInterval == 0 {
 			for _, l := range s.lists {
-				err := l.Flush()
+				err := l.Flush(ctx, false)
 				if err != nil {
 					log.Printf("Error flushing list %s: %v", l.Name(), err)
 					s.StopCrawler()
@@ -466,36 +833,50 @@ func (s *SubmissionsServer) handleCrawlerTick() {
 			}
 		}
 		// Regularly re-initialize the crawler
-		if s.crawler.NumFetched()%s.crawlerReinitInterval == 0 {
-			err := s.crawler.Init()
+		if s.crawler.NumFetched()%cfg.ReinitInterval == 0 {
+			err := s.crawler.Init(ctx)
 			if err != nil {
 				log.Printf("Error re-initializing crawler: %v", err)
 				s.StopCrawler()
 				return
 			}
+			s.events.Publish("crawler.reinit", nil)
 		}
 	}
-	if s.crawler.NumFetched()%s.crawlerIdsCacheSize == 0 && rand.Float64() < s.crawlerIdsFetchRatio {
+	if s.crawler.NumFetched()%cfg.IdsCacheSize == 0 && rand.Float64() < cfg.IdsFetchRatio {
 		// Find the missing ids
 		for _, l := range s.lists {
 			if l.Name() == "offsets" {
-				ids, _, err := l.FindMissingIds(s.crawler.MaxId(), s.crawlerIdsCacheSize)
+				ids, _, err := l.FindMissingIds(ctx, s.crawler.MaxId(), cfg.IdsCacheSize)
 				if err != nil {
 					s.StopCrawler()
 					return
 				}
-				s.crawler.SetNextIds(ids)
+				s.crawler.SetNextIds(ctx, ids)
 				break
 			}
 		}
 	}
 	// Fetch the next sequence
-	fields, _, err := s.crawler.FetchNext()
+	fetchStart := time.Now()
+	fields, seqId, _, err := s.crawler.FetchNext(ctx)
+	elapsed := time.Since(fetchStart)
 	if err != nil {
 		log.Printf("Error fetching fields: %v", err)
+		if compErr := s.operations.CompleteBySeqId(int64(seqId), err); compErr != nil {
+			log.Printf("Error updating operation for sequence %d: %v", seqId, compErr)
+		}
 		s.StopCrawler()
 		return
 	}
+	if uid, uidErr := util.NewUID('A', int64(seqId)); uidErr != nil {
+		log.Printf("Failed to build UID for fetched sequence %d: %v", seqId, uidErr)
+	} else {
+		s.events.Publish("crawler.fetched", crawlerFetchedEventData{Id: uid.String(), Elapsed: elapsed})
+	}
+	if compErr := s.operations.CompleteBySeqId(int64(seqId), nil); compErr != nil {
+		log.Printf("Error updating operation for sequence %d: %v", seqId, compErr)
+	}
 	// Update the lists with the new fields
 	filteredFields := filterValidKeywordsFields(fields)
 	for _, l := range s.lists {
@@ -503,6 +884,35 @@ func (s *SubmissionsServer) handleCrawlerTick() {
 	}
 }
 
+// Shutdown stops the crawler for good: it cancels any in-flight fetch and
+// waits, bounded by ctx, for the crawl goroutine to drain, then flushes each
+// list one last time so buffered fields aren't lost. Unlike StopCrawler, it
+// does not schedule a restart; it's meant to be called once, from the
+// SIGINT/SIGTERM handler in main.
+func (s *SubmissionsServer) Shutdown(ctx context.Context) error {
+	log.Print("Shutting down crawler")
+	if s.runCancel != nil {
+		s.runCancel()
+	}
+	drained := make(chan struct{})
+	go func() {
+		s.crawlerWG.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.crawler.Checkpoint(ctx)
+	for _, l := range s.lists {
+		if err := l.Flush(ctx, false); err != nil {
+			log.Printf("Error flushing list %s: %v", l.Name(), err)
+		}
+	}
+	return nil
+}
+
 // newV2SubmissionsCheckpointPostHandler handles POST requests for v2/submissions/checkpoint
 func newV2SubmissionsCheckpointPostHandler(s *SubmissionsServer) http.Handler {
 	f := func(w http.ResponseWriter, req *http.Request) {
@@ -520,7 +930,69 @@ func newV2SubmissionsCheckpointPostHandler(s *SubmissionsServer) http.Handler {
 	return http.HandlerFunc(f)
 }
 
-func (s *SubmissionsServer) Run(port int) {
+// newV2SequencesQueueGetHandler handles GET requests for v2/sequences/queue,
+// reporting how many sequences are pending in each crawler priority band.
+func newV2SequencesQueueGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		util.WriteJsonResponse(w, s.crawler.QueueDepth())
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2SubmissionsWalStatsGetHandler handles GET requests for
+// /v2/submissions/wal/stats, reporting the submissions write-ahead log's
+// segment count, total bytes, and last-fsync time for operators.
+func newV2SubmissionsWalStatsGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		util.WriteJsonResponse(w, s.wal.Stats())
+	}
+	return http.HandlerFunc(f)
+}
+
+// newHealthzGetHandler handles GET /healthz: it reports 200 as long as the
+// process is up, for an orchestrator's liveness probe.
+func newHealthzGetHandler() http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.HandlerFunc(f)
+}
+
+// newReadyzGetHandler handles GET /readyz: it reports 200 only once
+// loadCheckpoint has finished and the crawler's first Init has succeeded, so
+// an orchestrator can sequence a rollout to wait for a new instance to have
+// caught up before routing traffic to it.
+func newReadyzGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		if atomic.LoadInt32(&s.checkpointLoaded) == 0 || atomic.LoadInt32(&s.crawlerReady) == 0 {
+			util.WriteHttpStatus(w, http.StatusServiceUnavailable, "Not ready")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.HandlerFunc(f)
+}
+
+// Run serves the SubmissionsServer's routes until ctx is cancelled, at which
+// point it shuts the HTTP server down gracefully and returns instead of
+// blocking forever in http.ListenAndServe.
+func (s *SubmissionsServer) Run(ctx context.Context, port int) error {
 	s.loadCheckpoint()
 
 	// schedule background tasks
@@ -531,27 +1003,95 @@ func (s *SubmissionsServer) Run(port int) {
 			s.publishMetrics()
 			s.clearUserStats()
 			s.writeCheckpoint()
+			s.pruneExpiredUploadSessions()
+			if err := s.operations.Prune(); err != nil {
+				log.Printf("Error pruning operations: %v", err)
+			}
 		}
 	}()
 
 	// start web server
+	//
+	// Every route is wrapped in the per-request deadline middleware except
+	// /v2/events: it's a long-lived SSE stream, so bounding it the same way
+	// would cut every subscriber off after s.requestTimeout.
+	timeout := util.TimeoutMiddleware(s.requestTimeout)
 	router := mux.NewRouter()
-	router.Handle("/v2/submissions", newV2SubmissionsGetHandler(s)).Methods(http.MethodGet)
-	router.Handle("/v2/submissions", newV2SubmissionsPostHandler(s)).Methods(http.MethodPost)
-	router.Handle("/v2/submissions/checkpoint", newV2SubmissionsCheckpointPostHandler(s)).Methods(http.MethodPost)
+	router.Handle("/v2/submissions", timeout(newV2SubmissionsGetHandler(s))).Methods(http.MethodGet)
+	router.Handle("/v2/submissions", timeout(requireSubmitterAuth(s, newV2SubmissionsPostHandler(s)))).Methods(http.MethodPost)
+	router.Handle("/v2/submissions/checkpoint", timeout(newV2SubmissionsCheckpointPostHandler(s))).Methods(http.MethodPost)
+	router.Handle("/submit/start", timeout(requireSubmitterAuth(s, newSubmitStartPostHandler(s)))).Methods(http.MethodPost)
+	router.Handle("/submit/chunk", timeout(newSubmitChunkPutHandler(s))).Methods(http.MethodPut)
+	router.Handle("/submit/finish", timeout(newSubmitFinishPostHandler(s))).Methods(http.MethodPost)
+	router.Handle("/v2/sequences/queue", timeout(newV2SequencesQueueGetHandler(s))).Methods(http.MethodGet)
+	router.Handle("/v2/submissions/wal/stats", timeout(newV2SubmissionsWalStatsGetHandler(s))).Methods(http.MethodGet)
+	router.Handle("/v2/operations", timeout(newV2OperationsListGetHandler(s))).Methods(http.MethodGet)
+	router.Handle("/v2/operations/{id}", timeout(newV2OperationGetHandler(s))).Methods(http.MethodGet)
+	router.Handle("/v2/operations/{id}", timeout(newV2OperationDeleteHandler(s))).Methods(http.MethodDelete)
+	router.Handle("/v2/config", timeout(newV2ConfigGetHandler(s))).Methods(http.MethodGet)
+	router.Handle("/v2/config", timeout(newV2ConfigPutHandler(s))).Methods(http.MethodPut)
+	router.Handle("/v2/config/reload", timeout(newV2ConfigReloadPostHandler(s))).Methods(http.MethodPost)
+	router.Handle("/v2/users", timeout(newV2UsersPostHandler(s.submitterStore))).Methods(http.MethodPost)
+	router.Handle("/v2/tokens/revoke", timeout(newV2TokensRevokePostHandler(s.submitterStore))).Methods(http.MethodPost)
+	router.Handle("/v2/tokens", timeout(requireAdminAuth(s, newV2TokensGetHandler(s)))).Methods(http.MethodGet)
+	router.Handle("/v2/tokens", timeout(requireAdminAuth(s, newV2TokensPostHandler(s)))).Methods(http.MethodPost)
+	router.Handle("/v2/tokens/{id}", timeout(requireAdminAuth(s, newV2TokenDeleteHandler(s)))).Methods(http.MethodDelete)
+	router.Handle("/v2/events", newV2EventsGetHandler(s)).Methods(http.MethodGet)
+	router.Handle("/healthz", newHealthzGetHandler()).Methods(http.MethodGet)
+	router.Handle("/readyz", newReadyzGetHandler(s)).Methods(http.MethodGet)
 	router.NotFoundHandler = http.HandlerFunc(util.HandleNotFound)
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: util.CORSHandler(router),
+		// WriteTimeout is deliberately left unset: it would apply to
+		// /v2/events too, cutting every SSE subscriber off after that many
+		// seconds regardless of activity.
+		ReadHeaderTimeout: ServerReadHeaderTimeout,
+		IdleTimeout:       ServerIdleTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		log.Print("Shutting down HTTP server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ServerShutdownGracePeriod)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
 	log.Printf("Listening on port %d", port)
-	http.ListenAndServe(fmt.Sprintf(":%d", port), util.CORSHandler(router))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	if err := s.writeCheckpoint(); err != nil {
+		log.Printf("Final checkpoint failed: %v", err)
+	}
+	return nil
 }
 
 func main() {
 	setup := cmd.GetSetup("submissions")
+	if err := setup.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	util.MustDirExist(setup.DataDir)
 	oeisDir := filepath.Join(setup.DataDir, "seqs", "oeis")
 	os.MkdirAll(oeisDir, os.ModePerm)
-	u, p := util.ParseAuthInfo(setup.InfluxDbAuth)
-	i := util.NewInfluxDbClient(setup.InfluxDbHost, u, p)
-	s := NewSubmissionsServer(setup.DataDir, oeisDir, i)
+	u, p := util.ParseAuthInfo(setup.InfluxDB.Auth)
+	i := util.NewInfluxDbClient(setup.InfluxDB.Host, u, p)
+	s := NewSubmissionsServer(setup.DataDir, oeisDir, i, setup.Submissions.AnonymousMode, setup.RequestTimeout, setup.RefreshDrainTimeout, setup.CrawlerFetchTimeout, setup.Submissions.AuthURL, setup.Submissions.AuthFailOpen, setup.Submissions.RateLimitPerMinute)
 	s.StartCrawler()
-	s.Run(8084)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Print("Received shutdown signal, draining crawler")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), setup.RequestTimeout)
+		defer shutdownCancel()
+		s.Shutdown(shutdownCtx)
+		cancel()
+	}()
+
+	if err := s.Run(ctx, 8084); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/loda-lang/loda-api/shared/operations"
+	"github.com/loda-lang/loda-api/util"
+)
+
+// newV2OperationGetHandler handles GET requests for /v2/operations/{id},
+// returning the operation's current status, queue position, and timestamps.
+func newV2OperationGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		id := mux.Vars(req)["id"]
+		op, ok := s.operations.Get(id)
+		if !ok {
+			util.WriteHttpNotFound(w)
+			return
+		}
+		util.WriteJsonResponse(w, op)
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2OperationsListGetHandler handles GET requests for /v2/operations,
+// optionally filtered by the "submitter" query parameter.
+func newV2OperationsListGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		submitter := req.URL.Query().Get("submitter")
+		ops := s.operations.List(submitter)
+		resp := struct {
+			Total   int                    `json:"total"`
+			Results []operations.Operation `json:"results"`
+		}{Total: len(ops), Results: ops}
+		util.WriteJsonResponse(w, resp)
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2OperationDeleteHandler handles DELETE requests for
+// /v2/operations/{id}: if the operation is still pending, it's marked
+// cancelled and its sequence ID is pulled out of the crawler's
+// high-priority queue so it never gets fetched.
+func newV2OperationDeleteHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		id := mux.Vars(req)["id"]
+		op, ok := s.operations.Get(id)
+		if !ok {
+			util.WriteHttpNotFound(w)
+			return
+		}
+		cancelled, err := s.operations.Cancel(id)
+		if err != nil {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		if !cancelled {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Operation is no longer pending"})
+			return
+		}
+		s.crawler.RemoveHighId(req.Context(), int(op.SeqId))
+		op.Status = operations.StatusCancelled
+		s.events.Publish("operation.updated", op)
+		util.WriteJsonResponse(w, OperationResult{Status: "success", Message: "Operation cancelled"})
+	}
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewV2SubmissionsPostHandler_SkipsSubmitOnCancelledContext(t *testing.T) {
+	server := NewSubmissionsServer("", "", nil, true, 30*time.Second, 10*time.Second, 30*time.Second, "", false, 60)
+
+	id, _ := util.NewUIDFromString("A000045")
+	submission := shared.Submission{
+		Id:         id,
+		Mode:       shared.ModeAdd,
+		Type:       shared.TypeProgram,
+		Content:    "mov $0,1\nadd $0,2\n",
+		Submitter:  "alice",
+		Operations: []string{"mov", "add"},
+	}
+	body, err := submission.MarshalJSON()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewReader(body)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	newV2SubmissionsPostHandler(server).ServeHTTP(rec, req)
+
+	assert.Equal(t, 0, len(server.submissions))
+}
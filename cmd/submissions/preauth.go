@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/loda-lang/loda-api/shared"
+)
+
+// submissionAuthHeaders lists the inbound request headers forwarded to the
+// external pre-authorization service, so it can apply its own auth and
+// rate-limiting decisions without loda-api re-implementing them.
+var submissionAuthHeaders = []string{"Authorization", "X-Forwarded-For", "User-Agent"}
+
+// submissionAuthDecision is the JSON response expected from authURL.
+type submissionAuthDecision struct {
+	Allowed            bool   `json:"allowed"`
+	SubmitterOverride  string `json:"submitter_override"`
+	RateLimitRemaining int    `json:"rate_limit_remaining"`
+	Reason             string `json:"reason"`
+}
+
+// errSubmissionAuthUnavailable marks a pre-authorization failure that
+// should surface to the client as a 500, as opposed to an explicit
+// allowed=false verdict, which is reported as a normal OperationResult.
+var errSubmissionAuthUnavailable = errors.New("submission pre-authorization service unavailable")
+
+// authorizeSubmission delegates the accept/reject decision for submission
+// to the external service at s.authURL, mirroring gitlab-workhorse's
+// preAuthorizeHandler pattern: the submission JSON is POSTed there along
+// with a handful of inbound headers, and its verdict decides whether the
+// submission is stored at all. A disabled check (empty authURL) always
+// allows. A transport failure or non-2xx response is fail-open or
+// fail-closed per s.authFailOpen; a decoded allowed=true may carry a
+// submitter_override, which is applied to the returned submission.
+func (s *SubmissionsServer) authorizeSubmission(req *http.Request, submission shared.Submission) (shared.Submission, OperationResult, error) {
+	if s.authURL == "" {
+		return submission, OperationResult{}, nil
+	}
+
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return submission, OperationResult{}, err
+	}
+	authReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, s.authURL, bytes.NewReader(body))
+	if err != nil {
+		return submission, OperationResult{}, err
+	}
+	authReq.Header.Set("Content-Type", "application/json")
+	for _, h := range submissionAuthHeaders {
+		if v := req.Header.Get(h); v != "" {
+			authReq.Header.Set(h, v)
+		}
+	}
+
+	resp, err := s.httpClient.Do(authReq)
+	if err != nil {
+		log.Printf("Submission pre-authorization request to %s failed: %v", s.authURL, err)
+		if s.authFailOpen {
+			return submission, OperationResult{}, nil
+		}
+		return submission, OperationResult{}, errSubmissionAuthUnavailable
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("Submission pre-authorization service returned %s", resp.Status)
+		return submission, OperationResult{}, errSubmissionAuthUnavailable
+	}
+
+	var decision submissionAuthDecision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		log.Printf("Cannot decode submission pre-authorization response: %v", err)
+		return submission, OperationResult{}, errSubmissionAuthUnavailable
+	}
+	if !decision.Allowed {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "Rejected by pre-authorization service"
+		}
+		return submission, OperationResult{Status: "error", Message: reason}, nil
+	}
+	if decision.SubmitterOverride != "" {
+		submission.Submitter = decision.SubmitterOverride
+	}
+	return submission, OperationResult{}, nil
+}
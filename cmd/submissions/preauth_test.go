@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPreauthTestServer(t *testing.T, authURL string, authFailOpen bool) *SubmissionsServer {
+	tmpDir, err := os.MkdirTemp("", "preauth-test-*")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	oeisDir := filepath.Join(tmpDir, "seqs", "oeis")
+	os.MkdirAll(oeisDir, os.ModePerm)
+
+	return NewSubmissionsServer(tmpDir, oeisDir, nil, true, 30*time.Second, 10*time.Second, 30*time.Second, authURL, authFailOpen, 60)
+}
+
+func postSubmission(handler http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthorizeSubmission_NoAuthURLLeavesBehaviorUnchanged(t *testing.T) {
+	server := newPreauthTestServer(t, "", false)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, server.submissions, 1)
+}
+
+func TestAuthorizeSubmission_AllowedStoresSubmission(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"allowed": true})
+	}))
+	defer auth.Close()
+
+	server := newPreauthTestServer(t, auth.URL, false)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, server.submissions, 1)
+}
+
+func TestAuthorizeSubmission_AllowedAppliesSubmitterOverride(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"allowed": true, "submitter_override": "bob"})
+	}))
+	defer auth.Close()
+
+	server := newPreauthTestServer(t, auth.URL, false)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, server.submissions, 1)
+	assert.Equal(t, "bob", server.submissions[0].Submitter)
+}
+
+func TestAuthorizeSubmission_DeniedRejectsSubmission(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"allowed": false, "reason": "blocked by policy"})
+	}))
+	defer auth.Close()
+
+	server := newPreauthTestServer(t, auth.URL, false)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, server.submissions)
+
+	var res OperationResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "error", res.Status)
+	assert.Equal(t, "blocked by policy", res.Message)
+}
+
+func TestAuthorizeSubmission_FailClosedOnServiceError(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer auth.Close()
+
+	server := newPreauthTestServer(t, auth.URL, false)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, server.submissions)
+}
+
+func TestAuthorizeSubmission_FailOpenOnUnreachableService(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	unreachable := auth.URL
+	auth.Close()
+
+	server := newPreauthTestServer(t, unreachable, true)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, server.submissions, 1)
+}
+
+func TestAuthorizeSubmission_FailClosedOnUnreachableService(t *testing.T) {
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	unreachable := auth.URL
+	auth.Close()
+
+	server := newPreauthTestServer(t, unreachable, false)
+	handler := newV2SubmissionsPostHandler(server)
+
+	rec := postSubmission(handler, `{"id":"A000045","mode":"add","type":"program","content":"mov $0,1\n","submitter":"alice"}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, server.submissions)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter grants or denies a request for key — an opaque identity such
+// as a submitter name — reporting how long to wait before the next Allow
+// is likely to succeed when it doesn't. refreshSequence uses one of these
+// instead of a single global sliding-window list, so one noisy submitter's
+// refreshes no longer eat into everyone else's allowance.
+type RateLimiter interface {
+	Allow(key string) (allowed bool, retryAfter time.Duration)
+}
+
+// refreshRateLimiterShards is how many independent mutex-guarded buckets
+// TokenBucketRateLimiter spreads its keys across, so concurrent refreshes
+// from unrelated submitters don't serialize on one lock.
+const refreshRateLimiterShards = 16
+
+// tokenBucket is one key's token-bucket state: the tokens available as of
+// lastRefill, extrapolated forward by elapsed*ratePerSecond on each Allow
+// before deciding.
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+type rateLimiterShard struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// TokenBucketRateLimiter is an in-memory RateLimiter: every key gets its
+// own bucket of capacity tokens, refilling at ratePerHour/3600 tokens per
+// second. Buckets are sharded across refreshRateLimiterShards mutexes so
+// unrelated keys don't contend, and a background janitor evicts buckets
+// that have sat full and untouched for longer than idleTTL, bounding
+// memory on a long-running server that's seen many distinct submitters.
+type TokenBucketRateLimiter struct {
+	// rateMutex guards ratePerSecond/capacity, which SetRate updates at
+	// runtime (PUT /v2/config) without touching any bucket's earned tokens.
+	rateMutex     sync.RWMutex
+	ratePerSecond float64
+	capacity      float64
+	idleTTL       time.Duration
+
+	shards [refreshRateLimiterShards]*rateLimiterShard
+	stop   chan struct{}
+}
+
+// NewTokenBucketRateLimiter creates a TokenBucketRateLimiter allowing
+// ratePerHour requests per hour per key, up to capacity in a single burst,
+// and starts its janitor goroutine, which every idleTTL/2 (minimum one
+// minute) evicts buckets that have sat full and idle for longer than
+// idleTTL. Call Stop to shut the janitor down.
+func NewTokenBucketRateLimiter(ratePerHour, capacity float64, idleTTL time.Duration) *TokenBucketRateLimiter {
+	l := &TokenBucketRateLimiter{
+		ratePerSecond: ratePerHour / 3600,
+		capacity:      capacity,
+		idleTTL:       idleTTL,
+		stop:          make(chan struct{}),
+	}
+	for i := range l.shards {
+		l.shards[i] = &rateLimiterShard{buckets: make(map[string]*tokenBucket)}
+	}
+	go l.runJanitor()
+	return l
+}
+
+// SetRate updates the refill rate and burst capacity future Allow calls
+// apply, without resetting any bucket's already-accumulated tokens, so a
+// runtime config change takes effect immediately.
+func (l *TokenBucketRateLimiter) SetRate(ratePerHour, capacity float64) {
+	l.rateMutex.Lock()
+	defer l.rateMutex.Unlock()
+	l.ratePerSecond = ratePerHour / 3600
+	l.capacity = capacity
+}
+
+// Stop shuts down the janitor goroutine. Safe to call once.
+func (l *TokenBucketRateLimiter) Stop() {
+	close(l.stop)
+}
+
+func (l *TokenBucketRateLimiter) shardFor(key string) *rateLimiterShard {
+	return l.shards[fnv32(key)%refreshRateLimiterShards]
+}
+
+// Allow implements RateLimiter.
+func (l *TokenBucketRateLimiter) Allow(key string) (bool, time.Duration) {
+	l.rateMutex.RLock()
+	ratePerSecond, capacity := l.ratePerSecond, l.capacity
+	l.rateMutex.RUnlock()
+
+	shard := l.shardFor(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	now := time.Now()
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, lastRefill: now}
+		shard.buckets[key] = b
+	}
+	if ratePerSecond <= 0 {
+		// A non-positive rate has no meaningful refill, so don't divide by
+		// it; deny outright rather than let a misconfigured rate silently
+		// behave as "unlimited". ConfigStore.Update already rejects this at
+		// the source, but Allow stays defensive in case a future caller
+		// constructs a limiter directly.
+		return false, time.Hour
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = min(capacity, b.tokens+elapsed*ratePerSecond)
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / ratePerSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *TokenBucketRateLimiter) runJanitor() {
+	interval := l.idleTTL / 2
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.evictIdle()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// evictIdle removes any bucket that's both full (so evicting it loses no
+// earned-but-unused allowance) and untouched for longer than idleTTL.
+func (l *TokenBucketRateLimiter) evictIdle() {
+	l.rateMutex.RLock()
+	capacity := l.capacity
+	l.rateMutex.RUnlock()
+	cutoff := time.Now().Add(-l.idleTTL)
+	for _, shard := range l.shards {
+		shard.mutex.Lock()
+		for key, b := range shard.buckets {
+			if b.tokens >= capacity && b.lastRefill.Before(cutoff) {
+				delete(shard.buckets, key)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
+
+// numBuckets reports how many buckets are currently held across all
+// shards; used by tests to confirm the janitor actually evicts.
+func (l *TokenBucketRateLimiter) numBuckets() int {
+	n := 0
+	for _, shard := range l.shards {
+		shard.mutex.Lock()
+		n += len(shard.buckets)
+		shard.mutex.Unlock()
+	}
+	return n
+}
+
+// fnv32 is a small, dependency-free string hash (FNV-1a) used only to
+// spread keys across TokenBucketRateLimiter's shards; it doesn't need to be
+// cryptographically strong, just well-distributed.
+func fnv32(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+	return h
+}
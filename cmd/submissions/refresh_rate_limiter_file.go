@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// FileRateLimiter is a RateLimiter whose bucket state lives in one JSON
+// file per key under dir, so multiple loda-api instances sharing dir (e.g.
+// a data directory on shared storage) enforce one combined limit instead
+// of each process getting its own allowance — the "pluggable storage"
+// counterpart to TokenBucketRateLimiter's in-memory default. A real
+// SQLite- or Redis-backed store would need a driver dependency this module
+// doesn't have and can't add in this tree, so this gets the same
+// cross-process sharing out of what the standard library already
+// provides: an flock'd read-refill-write cycle per key, using the same
+// tmp-file-then-rename pattern writeCheckpoint uses for atomicity.
+type FileRateLimiter struct {
+	dir           string
+	ratePerSecond float64
+	capacity      float64
+}
+
+// fileRateLimiterState is the JSON shape persisted per key.
+type fileRateLimiterState struct {
+	Tokens     float64   `json:"tokens"`
+	LastRefill time.Time `json:"last_refill"`
+}
+
+// NewFileRateLimiter creates a FileRateLimiter that persists its buckets as
+// JSON files under dir, creating dir if it doesn't already exist.
+func NewFileRateLimiter(dir string, ratePerHour, capacity float64) (*FileRateLimiter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create rate limiter directory: %w", err)
+	}
+	return &FileRateLimiter{dir: dir, ratePerSecond: ratePerHour / 3600, capacity: capacity}, nil
+}
+
+// Allow implements RateLimiter. A storage error (the directory became
+// unwritable, the lock couldn't be taken) fails open rather than blocking
+// a legitimate refresh on an infrastructure problem.
+func (l *FileRateLimiter) Allow(key string) (bool, time.Duration) {
+	path := filepath.Join(l.dir, url.QueryEscape(key)+".json")
+	lockFile, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return true, 0
+	}
+	defer lockFile.Close()
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX); err != nil {
+		return true, 0
+	}
+	defer syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+
+	state := fileRateLimiterState{Tokens: l.capacity, LastRefill: time.Now()}
+	if raw, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(raw, &state)
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(state.LastRefill).Seconds()
+	state.Tokens = min(l.capacity, state.Tokens+elapsed*l.ratePerSecond)
+	state.LastRefill = now
+
+	allowed := state.Tokens >= 1
+	var retryAfter time.Duration
+	if allowed {
+		state.Tokens--
+	} else {
+		retryAfter = time.Duration((1 - state.Tokens) / l.ratePerSecond * float64(time.Second))
+	}
+
+	if raw, err := json.Marshal(state); err == nil {
+		tmpPath := path + ".tmp"
+		if os.WriteFile(tmpPath, raw, 0644) == nil {
+			os.Rename(tmpPath, path)
+		}
+	}
+	return allowed, retryAfter
+}
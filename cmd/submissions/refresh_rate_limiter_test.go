@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketRateLimiter_AllowsUpToCapacityThenRejects(t *testing.T) {
+	l := NewTokenBucketRateLimiter(60, 3, time.Hour)
+	defer l.Stop()
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := l.Allow("alice")
+		assert.True(t, allowed, "call %d should be allowed within capacity", i+1)
+	}
+	allowed, retryAfter := l.Allow("alice")
+	assert.False(t, allowed, "call beyond capacity should be rejected")
+	assert.True(t, retryAfter > 0, "a rejected call should report a positive retryAfter")
+}
+
+func TestTokenBucketRateLimiter_RefillsOverTime(t *testing.T) {
+	// 3600/hour == 1/sec, so waiting just over a second should refill
+	// exactly one token.
+	l := NewTokenBucketRateLimiter(3600, 1, time.Hour)
+	defer l.Stop()
+
+	allowed, _ := l.Allow("alice")
+	assert.True(t, allowed)
+	allowed, _ = l.Allow("alice")
+	assert.False(t, allowed, "bucket of capacity 1 should be empty after one Allow")
+
+	time.Sleep(1100 * time.Millisecond)
+	allowed, _ = l.Allow("alice")
+	assert.True(t, allowed, "bucket should have refilled after waiting past the refill interval")
+}
+
+func TestTokenBucketRateLimiter_ConcurrentSubmittersAreIsolated(t *testing.T) {
+	l := NewTokenBucketRateLimiter(60, 5, time.Hour)
+	defer l.Stop()
+
+	keys := []string{"alice", "bob", "carol", "dave"}
+	var wg sync.WaitGroup
+	allowedCount := make([]int64, len(keys))
+
+	for i, key := range keys {
+		for n := 0; n < 20; n++ {
+			wg.Add(1)
+			go func(i int, key string) {
+				defer wg.Done()
+				if allowed, _ := l.Allow(key); allowed {
+					atomic.AddInt64(&allowedCount[i], 1)
+				}
+			}(i, key)
+		}
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		assert.Equal(t, int64(5), allowedCount[i], "key %q should have allowed exactly its capacity, regardless of the other keys' traffic", key)
+	}
+}
+
+func TestTokenBucketRateLimiter_SetRatePreservesAccumulatedTokens(t *testing.T) {
+	l := NewTokenBucketRateLimiter(60, 5, time.Hour)
+	defer l.Stop()
+
+	allowed, _ := l.Allow("alice")
+	assert.True(t, allowed)
+
+	l.SetRate(120, 10)
+	allowed, _ = l.Allow("alice")
+	assert.True(t, allowed, "the bucket should keep its remaining tokens across a SetRate")
+}
+
+func TestTokenBucketRateLimiter_EvictIdleRemovesOnlyFullIdleBuckets(t *testing.T) {
+	l := NewTokenBucketRateLimiter(3600, 5, 10*time.Millisecond)
+	defer l.Stop()
+
+	// "full" stays untouched and full, so it's both idle and evictable.
+	allowed, _ := l.Allow("full")
+	assert.True(t, allowed)
+	l.shardFor("full").buckets["full"].tokens = 5
+
+	// "drained" is idle but not full, so it must survive eviction (the
+	// janitor only reclaims buckets whose earned allowance isn't lost).
+	allowed, _ = l.Allow("drained")
+	assert.True(t, allowed)
+	l.shardFor("drained").buckets["drained"].tokens = 0
+
+	time.Sleep(20 * time.Millisecond)
+
+	// "fresh" is touched just before evictIdle runs, so it isn't idle yet.
+	allowed, _ = l.Allow("fresh")
+	assert.True(t, allowed)
+	l.shardFor("fresh").buckets["fresh"].tokens = 5
+
+	l.evictIdle()
+
+	assert.Equal(t, 2, l.numBuckets(), "only the idle, full bucket should have been evicted")
+	assert.NotNil(t, l.shardFor("drained").buckets["drained"], "an idle but non-full bucket must not be evicted")
+	assert.NotNil(t, l.shardFor("fresh").buckets["fresh"], "a recently-touched bucket must not be evicted")
+	assert.Nil(t, l.shardFor("full").buckets["full"], "an idle, full bucket should have been evicted")
+}
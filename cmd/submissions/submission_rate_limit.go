@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// submissionAnonymousRateDivisor is how much stricter the shared,
+	// IP-keyed bucket for anonymous POSTs is relative to an authenticated
+	// submitter's own bucket.
+	submissionAnonymousRateDivisor = 10
+
+	// submissionGlobalRateMultiplier caps total POST volume across all
+	// submitters combined, relative to a single submitter's rate, so no set
+	// of cooperating or compromised accounts can overwhelm the crawler
+	// queue between them.
+	submissionGlobalRateMultiplier = 10
+)
+
+// SubmissionRateLimiter enforces a token-bucket rate limit per authenticated
+// submitter, a much stricter shared bucket for anonymous POSTs keyed by
+// client IP, and a global cap across all submitters combined. It mirrors
+// CPUHoursAuth's per-source limiting in cmd/stats.
+type SubmissionRateLimiter struct {
+	perMinute int
+
+	mutex     sync.Mutex
+	limiters  map[string]*rate.Limiter
+	anonymous map[string]*rate.Limiter
+	global    *rate.Limiter
+}
+
+// NewSubmissionRateLimiter creates a SubmissionRateLimiter allowing
+// perMinute requests per minute for an authenticated submitter. Anonymous
+// POSTs share a bucket at 1/submissionAnonymousRateDivisor of that rate,
+// keyed by client IP; the global bucket caps everyone combined at
+// submissionGlobalRateMultiplier times that rate.
+func NewSubmissionRateLimiter(perMinute int) *SubmissionRateLimiter {
+	return &SubmissionRateLimiter{
+		perMinute: perMinute,
+		limiters:  make(map[string]*rate.Limiter),
+		anonymous: make(map[string]*rate.Limiter),
+		global:    rate.NewLimiter(rate.Limit(perMinute*submissionGlobalRateMultiplier)/60, perMinute*submissionGlobalRateMultiplier),
+	}
+}
+
+// Allow reports whether a POST from submitter (empty for anonymous,
+// keying the check off sourceIP instead) may proceed right now, consuming a
+// token if so. retryAfter is only meaningful when allowed is false.
+func (l *SubmissionRateLimiter) Allow(submitter, sourceIP string) (allowed bool, retryAfter time.Duration) {
+	limiter := l.identityLimiter(submitter, sourceIP)
+	if ok, delay := reserve(limiter); !ok {
+		return false, delay
+	}
+	if ok, delay := reserve(l.global); !ok {
+		return false, delay
+	}
+	return true, 0
+}
+
+// identityLimiter returns the per-submitter bucket for submitter, or the
+// shared per-IP bucket for anonymous POSTs (submitter == ""), creating it on
+// first use.
+func (l *SubmissionRateLimiter) identityLimiter(submitter, sourceIP string) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if submitter == "" {
+		limiter, ok := l.anonymous[sourceIP]
+		if !ok {
+			anonPerMinute := l.perMinute / submissionAnonymousRateDivisor
+			if anonPerMinute < 1 {
+				anonPerMinute = 1
+			}
+			limiter = rate.NewLimiter(rate.Limit(anonPerMinute)/60, anonPerMinute)
+			l.anonymous[sourceIP] = limiter
+		}
+		return limiter
+	}
+	limiter, ok := l.limiters[submitter]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.perMinute)/60, l.perMinute)
+		l.limiters[submitter] = limiter
+	}
+	return limiter
+}
+
+// reserve atomically takes a token from limiter if one is available right
+// now, leaving the reservation cancelled (so the bucket isn't drained) when
+// it isn't, and reports the delay until one would be.
+func reserve(limiter *rate.Limiter) (bool, time.Duration) {
+	r := limiter.Reserve()
+	if !r.OK() {
+		return false, 0
+	}
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// sourceIP extracts the client IP from req.RemoteAddr, stripping the port.
+func sourceIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+)
+
+const bearerPrefix = "Bearer "
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>"
+// header, if present.
+func bearerToken(req *http.Request) (string, bool) {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(header, bearerPrefix), true
+}
+
+// scopeForSubmissionType returns the scope a bearer token must carry to
+// post a submission of the given "type" field, mirroring the split between
+// refreshSequence and the program add/update/remove path.
+func scopeForSubmissionType(submissionType string) shared.SubmitterScope {
+	if submissionType == string(shared.TypeSequence) {
+		return shared.ScopeRefreshSequence
+	}
+	return shared.ScopeSubmitProgram
+}
+
+// requireSubmitterAuth wraps next, rejecting POSTs whose submitter isn't
+// backed by a valid bearer token, unless s.anonymousMode is set and
+// s.config's RequireAuth flag hasn't overridden it. A token's authenticated
+// submitter name always wins: it's written into the body's "submitter"
+// field before forwarding to next (rejecting outright if the client
+// supplied a different one), so downstream accounting keys off the
+// authenticated user rather than whatever string the client sent.
+//
+// An authenticated token also has to carry the scope matching the
+// submission's "type" field, and clear both its own per-hour/per-day quota
+// (if the token has one) and s.submissionRateLimiter: an authenticated
+// submitter draws from its own bucket, while anonymous requests share a
+// much stricter bucket keyed by client IP, so neither path can flood the
+// crawler queue.
+func requireSubmitterAuth(s *SubmissionsServer, next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if !ok {
+			cfg, _ := s.config.Get()
+			if !s.anonymousMode || cfg.RequireAuth {
+				util.WriteHttpUnauthorized(w)
+				return
+			}
+			if allowed, retryAfter := s.submissionRateLimiter.Allow("", sourceIP(req)); !allowed {
+				util.WriteHttpTooManyRequestsRetryAfter(w, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, req)
+			return
+		}
+		user, tok, err := s.submitterStore.AuthenticateToken(token)
+		if err != nil {
+			log.Printf("Rejected submission: %v", err)
+			util.WriteHttpUnauthorized(w)
+			return
+		}
+		if allowed, retryAfter := s.submissionRateLimiter.Allow(user.SubmitterName, sourceIP(req)); !allowed {
+			util.WriteHttpTooManyRequestsRetryAfter(w, retryAfter)
+			return
+		}
+
+		defer req.Body.Close()
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		var submissionType string
+		if raw, ok := fields["type"]; ok {
+			if err := json.Unmarshal(raw, &submissionType); err != nil {
+				util.WriteHttpBadRequest(w)
+				return
+			}
+		}
+		if !tok.HasScope(scopeForSubmissionType(submissionType)) {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Token is missing the required scope"})
+			return
+		}
+		if ok, msg := s.checkTokenQuota(tok.ID, tok.PerHourLimit, tok.PerDayLimit); !ok {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: msg})
+			return
+		}
+		if raw, ok := fields["submitter"]; ok {
+			var submitter string
+			if err := json.Unmarshal(raw, &submitter); err != nil {
+				util.WriteHttpBadRequest(w)
+				return
+			}
+			if submitter != "" && submitter != user.SubmitterName {
+				util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Submitter does not match authenticated user"})
+				return
+			}
+		}
+		nameJSON, err := json.Marshal(user.SubmitterName)
+		if err != nil {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		fields["submitter"] = nameJSON
+		rewritten, err := json.Marshal(fields)
+		if err != nil {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(rewritten))
+		req.ContentLength = int64(len(rewritten))
+		next.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(f)
+}
+
+// requireAdminAuth wraps next, rejecting any request whose bearer token
+// doesn't carry the admin scope: missing/unknown tokens get 401, a valid
+// token lacking admin gets 403. It guards the /v2/tokens CRUD surface,
+// which mints and inspects other users' tokens.
+func requireAdminAuth(s *SubmissionsServer, next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if !ok {
+			util.WriteHttpUnauthorized(w)
+			return
+		}
+		_, tok, err := s.submitterStore.AuthenticateToken(token)
+		if err != nil {
+			log.Printf("Rejected admin request: %v", err)
+			util.WriteHttpUnauthorized(w)
+			return
+		}
+		if !tok.HasScope(shared.ScopeAdmin) {
+			util.WriteHttpForbidden(w)
+			return
+		}
+		next.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2UsersPostHandler handles POST requests for /v2/users: it registers a
+// new submitter user and returns a fresh bearer token for it. In a real
+// deployment the token would be emailed to the user instead of returned
+// directly; for now it's also logged so it can be recovered from the
+// server log if the response is lost.
+func newV2UsersPostHandler(store *shared.SubmitterStore) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		var body struct {
+			Email         string `json:"email"`
+			SubmitterName string `json:"submitter_name"`
+		}
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if body.Email == "" || body.SubmitterName == "" {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Missing email or submitter_name"})
+			return
+		}
+		token, err := store.Register(body.Email, body.SubmitterName)
+		if err != nil {
+			log.Printf("Cannot register submitter user: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		log.Printf("Registered submitter %s (%s); token: %s", body.SubmitterName, body.Email, token)
+		util.WriteJsonResponse(w, struct {
+			Status string `json:"status"`
+			Token  string `json:"token"`
+		}{Status: "success", Token: token})
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2TokensRevokePostHandler handles POST requests for
+// /v2/tokens/revoke: it revokes the bearer token passed in the request
+// body, so it can no longer authenticate submissions.
+func newV2TokensRevokePostHandler(store *shared.SubmitterStore) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		var body struct {
+			Token string `json:"token"`
+		}
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if body.Token == "" {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Missing token"})
+			return
+		}
+		if err := store.RevokeToken(body.Token); err != nil {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Unknown token"})
+			return
+		}
+		util.WriteJsonResponse(w, OperationResult{Status: "success", Message: "Token revoked"})
+	}
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSubmissionsServer builds a SubmissionsServer backed by tempDir for
+// requireSubmitterAuth tests, with a rate limiter sized to rateLimitPerMinute
+// so individual tests can exercise SubmissionRateLimiter without waiting out
+// the default.
+func newTestSubmissionsServer(tempDir string, anonymousMode bool, rateLimitPerMinute int) *SubmissionsServer {
+	s := NewSubmissionsServer(tempDir, "", nil, anonymousMode, 30*time.Second, 10*time.Second, 30*time.Second, "", false, rateLimitPerMinute)
+	s.submissionRateLimiter = NewSubmissionRateLimiter(rateLimitPerMinute)
+	return s
+}
+
+func echoSubmitterHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Submitter string `json:"submitter"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		w.Write([]byte(body.Submitter))
+	})
+}
+
+func TestRequireSubmitterAuth_AnonymousModeAllowsNoToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"anyone"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "anyone", rec.Body.String())
+}
+
+func TestRequireSubmitterAuth_RejectsMissingTokenWhenNotAnonymous(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"anyone"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireSubmitterAuth_RejectsWhenConfigRequiresAuthEvenInAnonymousMode(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+	cfg, fingerprint := s.config.Get()
+	cfg.RequireAuth = true
+	_, _, err = s.config.Update(cfg, fingerprint)
+	assert.NoError(t, err)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"anyone"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireSubmitterAuth_RejectsUnknownToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"anyone"}`))
+	req.Header.Set("Authorization", "Bearer bogus")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireSubmitterAuth_RejectsMismatchedSubmitter(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	token, err := s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"someone-else"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res OperationResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "error", res.Status)
+}
+
+func TestRequireSubmitterAuth_FillsInAuthenticatedSubmitter(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	token, err := s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"id":"A000045"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", rec.Body.String())
+}
+
+func TestRequireSubmitterAuth_RejectsRevokedToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	token, err := s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+	assert.NoError(t, s.submitterStore.RevokeToken(token))
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"alice"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireSubmitterAuth_RejectsMissingScope(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	_, err = s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+	token, _, err := s.submitterStore.IssueToken("alice@example.com", []shared.SubmitterScope{shared.ScopeSubmitProgram}, 0, 0)
+	assert.NoError(t, err)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"alice","type":"sequence"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res OperationResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "error", res.Status)
+	assert.Contains(t, res.Message, "scope")
+}
+
+func TestRequireSubmitterAuth_RejectsOverPerTokenHourlyQuota(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 600)
+	_, err = s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+	token, _, err := s.submitterStore.IssueToken("alice@example.com", []shared.SubmitterScope{shared.ScopeSubmitProgram}, 1, 0)
+	assert.NoError(t, err)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"alice"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var res OperationResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "error", res.Status)
+	assert.Contains(t, res.Message, "Token rate limit exceeded")
+}
+
+func TestRequireSubmitterAuth_RejectsOverRateLimit(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 1)
+	token, err := s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"alice"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestRequireSubmitterAuth_AnonymousSharesStricterBucket(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, submissionAnonymousRateDivisor)
+
+	handler := requireSubmitterAuth(s, echoSubmitterHandler())
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v2/submissions", bytes.NewBufferString(`{"submitter":"anyone"}`))
+		req.RemoteAddr = "203.0.113.1:1234"
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestNewV2UsersPostHandler_RegistersAndReturnsToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	store := shared.NewSubmitterStore(tempDir)
+
+	handler := newV2UsersPostHandler(store)
+	req := httptest.NewRequest(http.MethodPost, "/v2/users", bytes.NewBufferString(`{"email":"alice@example.com","submitter_name":"alice"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res struct {
+		Status string `json:"status"`
+		Token  string `json:"token"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "success", res.Status)
+	assert.NotEmpty(t, res.Token)
+
+	user, err := store.Authenticate(res.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.SubmitterName)
+}
+
+func TestNewV2TokensRevokePostHandler_Revokes(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-auth-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	store := shared.NewSubmitterStore(tempDir)
+	token, err := store.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := newV2TokensRevokePostHandler(store)
+	body, _ := json.Marshal(map[string]string{"token": token})
+	req := httptest.NewRequest(http.MethodPost, "/v2/tokens/revoke", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, err = store.Authenticate(token)
+	assert.Error(t, err)
+}
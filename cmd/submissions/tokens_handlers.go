@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+)
+
+// tokenIssueRequest is the body POST /v2/tokens expects: the email of an
+// already-registered submitter user (see newV2UsersPostHandler), the
+// scopes to grant the new token, and its optional per-hour/per-day
+// submission quotas (0 means unlimited).
+type tokenIssueRequest struct {
+	OwnerEmail   string                  `json:"owner_email"`
+	Scopes       []shared.SubmitterScope `json:"scopes"`
+	PerHourLimit int                     `json:"per_hour_limit"`
+	PerDayLimit  int                     `json:"per_day_limit"`
+}
+
+// tokenIssueResponse is the one time a freshly minted token's secret is
+// returned; every later inspection of it goes through SubmitterToken,
+// whose TokenHash is never marshalled.
+type tokenIssueResponse struct {
+	Token string                `json:"token"`
+	Info  shared.SubmitterToken `json:"info"`
+}
+
+// newV2TokensGetHandler handles GET requests for /v2/tokens: it lists
+// every token in the store, admin scope required. Raw secrets are never
+// included, since SubmitterToken never marshals its hash.
+func newV2TokensGetHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		util.WriteJsonResponse(w, s.submitterStore.ListTokens())
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2TokensPostHandler handles POST requests for /v2/tokens: it mints a
+// new, scoped and quota-limited token for an already-registered submitter
+// user, admin scope required. The raw token is returned only in this
+// response, same as /v2/users.
+func newV2TokensPostHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		var body tokenIssueRequest
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if body.OwnerEmail == "" || len(body.Scopes) == 0 {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Missing owner_email or scopes"})
+			return
+		}
+		token, info, err := s.submitterStore.IssueToken(body.OwnerEmail, body.Scopes, body.PerHourLimit, body.PerDayLimit)
+		if err != nil {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: err.Error()})
+			return
+		}
+		log.Printf("Issued token %d for %s (scopes: %v)", info.ID, body.OwnerEmail, body.Scopes)
+		util.WriteJsonResponse(w, tokenIssueResponse{Token: token, Info: info})
+	}
+	return http.HandlerFunc(f)
+}
+
+// newV2TokenDeleteHandler handles DELETE requests for /v2/tokens/{id}: it
+// revokes the token with that ID, admin scope required. Unlike
+// /v2/tokens/revoke (which takes the raw secret and lets any caller revoke
+// their own token), this lets an admin revoke a token they don't hold.
+func newV2TokenDeleteHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+		if err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if err := s.submitterStore.RevokeTokenByID(id); err != nil {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Unknown token id"})
+			return
+		}
+		util.WriteJsonResponse(w, OperationResult{Status: "success", Message: "Token revoked"})
+	}
+	return http.HandlerFunc(f)
+}
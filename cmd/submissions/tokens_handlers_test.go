@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdminAuth_RejectsMissingToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "tokens-handlers-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+
+	handler := requireAdminAuth(s, newV2TokensGetHandler(s))
+	req := httptest.NewRequest(http.MethodGet, "/v2/tokens", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireAdminAuth_RejectsTokenWithoutAdminScope(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "tokens-handlers-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	token, err := s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := requireAdminAuth(s, newV2TokensGetHandler(s))
+	req := httptest.NewRequest(http.MethodGet, "/v2/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestNewV2TokensPostHandler_IssuesScopedTokenForExistingUser(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "tokens-handlers-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	_, err = s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := newV2TokensPostHandler(s)
+	body, _ := json.Marshal(tokenIssueRequest{
+		OwnerEmail:   "alice@example.com",
+		Scopes:       []shared.SubmitterScope{shared.ScopeSubmitProgram},
+		PerHourLimit: 10,
+		PerDayLimit:  100,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v2/tokens", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res tokenIssueResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.NotEmpty(t, res.Token)
+	assert.Equal(t, 10, res.Info.PerHourLimit)
+
+	user, tok, err := s.submitterStore.AuthenticateToken(res.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.SubmitterName)
+	assert.True(t, tok.HasScope(shared.ScopeSubmitProgram))
+	assert.False(t, tok.HasScope(shared.ScopeAdmin))
+}
+
+func TestNewV2TokensGetHandler_NeverReturnsTokenHash(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "tokens-handlers-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	_, err = s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+
+	handler := newV2TokensGetHandler(s)
+	req := httptest.NewRequest(http.MethodGet, "/v2/tokens", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "token_hash")
+}
+
+func TestNewV2TokenDeleteHandler_RevokesById(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "tokens-handlers-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, false, 60)
+	token, err := s.submitterStore.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+	tokens := s.submitterStore.ListTokens()
+	assert.Len(t, tokens, 1)
+
+	router := mux.NewRouter()
+	router.Handle("/v2/tokens/{id}", newV2TokenDeleteHandler(s)).Methods(http.MethodDelete)
+	req := httptest.NewRequest(http.MethodDelete, "/v2/tokens/1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, err = s.submitterStore.Authenticate(token)
+	assert.Error(t, err)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loda-lang/loda-api/shared"
+	"github.com/loda-lang/loda-api/util"
+)
+
+const (
+	// submitSessionsDirName is the dataDir subdirectory chunked upload
+	// sessions persist their metadata and assembled content under.
+	submitSessionsDirName = "sessions"
+
+	// SubmitChunkSize is the chunk size POST /submit/start hands a client,
+	// for it to honor on every following PUT /submit/chunk.
+	SubmitChunkSize = 4 << 20 // 4 MiB
+
+	// MaxSubmitSessionBytes bounds how much content a single chunked upload
+	// may assemble, so an abusive client can't fill the data directory one
+	// chunk at a time. It's generous relative to MaxProgramLength (a
+	// program's source is plain text, so even an unusually large one is
+	// nowhere near this), leaving headroom for whatever made the program
+	// too big for a single request in the first place.
+	MaxSubmitSessionBytes = 50 * MaxProgramLength
+
+	// SubmitSessionIdleTTL bounds how long an upload session may sit
+	// without a chunk before pruneExpiredUploadSessions reclaims it.
+	SubmitSessionIdleTTL = 30 * time.Minute
+)
+
+// submissionUploadSession tracks one in-progress chunked upload, created by
+// POST /submit/start and completed by POST /submit/finish. Its fields are
+// exactly what's persisted as JSON under dataDir/sessions/<id>.json, so a
+// restart can resume an upload right where the last chunk left off: the
+// assembled bytes live in the sibling <id>.data file, and HashState lets
+// the session's sha256 pick back up without rehashing <id>.data from byte
+// zero.
+type submissionUploadSession struct {
+	Id            string            `json:"id"`
+	Submission    shared.Submission `json:"submission"` // Id/Mode/Type/Submitter; Content is assembled separately in <id>.data
+	ReceivedBytes int64             `json:"received_bytes"`
+	LastActivity  time.Time         `json:"last_activity"`
+	HashState     []byte            `json:"hash_state,omitempty"`
+
+	hasher hash.Hash
+}
+
+// submitChunkResult is the JSON body PUT /submit/chunk responds with: either
+// the chunk landed and NextOffset is where the next one should start, or it
+// didn't (an offset mismatch, a full session) and Message explains why.
+type submitChunkResult struct {
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	NextOffset int64  `json:"next_offset"`
+}
+
+func (s *SubmissionsServer) sessionsDir() string {
+	return filepath.Join(s.dataDir, submitSessionsDirName)
+}
+
+func (s *SubmissionsServer) sessionMetaPath(id string) string {
+	return filepath.Join(s.sessionsDir(), id+".json")
+}
+
+func (s *SubmissionsServer) sessionDataPath(id string) string {
+	return filepath.Join(s.sessionsDir(), id+".data")
+}
+
+// newUploadSessionID generates a 128-bit random session id. Its
+// unguessability is what authorizes PUT /submit/chunk and POST
+// /submit/finish: whoever holds it is treated as the owner of that upload,
+// the same capability-style model resumable upload URLs use elsewhere.
+func newUploadSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("cannot generate session id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// persist snapshots sess's hasher and writes its metadata to
+// dataDir/sessions/<id>.json via the repo's usual temp-file-then-rename.
+// Caller must hold s.submissionsMutex.
+func (sess *submissionUploadSession) persist(s *SubmissionsServer) error {
+	state, err := sess.hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("cannot snapshot upload session hash state: %w", err)
+	}
+	sess.HashState = state
+	raw, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal upload session: %w", err)
+	}
+	path := s.sessionMetaPath(sess.Id)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0644); err != nil {
+		return fmt.Errorf("cannot write upload session: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// removeUploadSessionLocked discards a session's in-memory and on-disk
+// state. Caller must hold s.submissionsMutex.
+func (s *SubmissionsServer) removeUploadSessionLocked(id string) {
+	delete(s.uploadSessions, id)
+	os.Remove(s.sessionMetaPath(id))
+	os.Remove(s.sessionDataPath(id))
+}
+
+// loadUploadSessions repopulates s.uploadSessions from dataDir/sessions/ at
+// startup, so a client that was partway through a chunked upload when the
+// server restarted can pick up from ReceivedBytes instead of starting over.
+// A session whose hash state fails to restore is discarded outright rather
+// than resumed with a corrupted hasher.
+func (s *SubmissionsServer) loadUploadSessions() {
+	entries, err := os.ReadDir(s.sessionsDir())
+	if err != nil {
+		return
+	}
+	s.submissionsMutex.Lock()
+	defer s.submissionsMutex.Unlock()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		raw, err := os.ReadFile(s.sessionMetaPath(id))
+		if err != nil {
+			log.Printf("Cannot read upload session %s: %v", id, err)
+			continue
+		}
+		var sess submissionUploadSession
+		if err := json.Unmarshal(raw, &sess); err != nil {
+			log.Printf("Cannot decode upload session %s: %v", id, err)
+			continue
+		}
+		hasher := sha256.New()
+		if len(sess.HashState) > 0 {
+			if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(sess.HashState); err != nil {
+				log.Printf("Discarding upload session %s: cannot restore hash state: %v", id, err)
+				os.Remove(s.sessionMetaPath(id))
+				os.Remove(s.sessionDataPath(id))
+				continue
+			}
+		}
+		sess.hasher = hasher
+		s.uploadSessions[sess.Id] = &sess
+	}
+	if len(s.uploadSessions) > 0 {
+		log.Printf("Restored %d in-progress submission upload session(s)", len(s.uploadSessions))
+	}
+}
+
+// pruneExpiredUploadSessions discards every upload session that's been idle
+// longer than SubmitSessionIdleTTL. Called from the same ticker loop as
+// writeCheckpoint, so an abandoned chunked upload doesn't hold its assembled
+// bytes on disk forever.
+func (s *SubmissionsServer) pruneExpiredUploadSessions() {
+	s.submissionsMutex.Lock()
+	defer s.submissionsMutex.Unlock()
+	cutoff := time.Now().Add(-SubmitSessionIdleTTL)
+	for id, sess := range s.uploadSessions {
+		if sess.LastActivity.Before(cutoff) {
+			s.removeUploadSessionLocked(id)
+			log.Printf("Expired idle submission upload session %s", id)
+		}
+	}
+}
+
+// newSubmitStartPostHandler handles POST /submit/start, which begins a
+// chunked upload: the body is the same id/mode/type/submitter shape POST
+// /v2/submissions accepts, just without content, and the wrapping
+// requireSubmitterAuth middleware authenticates, scope-checks, and
+// rate-limits it exactly as it would a normal submission.
+func newSubmitStartPostHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		defer req.Body.Close()
+		var submission shared.Submission
+		if err := json.NewDecoder(req.Body).Decode(&submission); err != nil {
+			log.Printf("Invalid submission JSON: %v", err)
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if submission.Id.IsZero() {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Invalid or missing ID"})
+			return
+		}
+		if submission.Type != shared.TypeProgram {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Chunked upload is only supported for program submissions"})
+			return
+		}
+		if submission.Mode != shared.ModeAdd && submission.Mode != shared.ModeUpdate {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Unsupported submission mode for chunked upload"})
+			return
+		}
+		submission.Content = ""
+
+		id, err := newUploadSessionID()
+		if err != nil {
+			log.Printf("Cannot create upload session: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		sess := &submissionUploadSession{
+			Id:           id,
+			Submission:   submission,
+			LastActivity: time.Now(),
+			hasher:       sha256.New(),
+		}
+
+		s.submissionsMutex.Lock()
+		defer s.submissionsMutex.Unlock()
+		if err := os.MkdirAll(s.sessionsDir(), 0755); err != nil {
+			log.Printf("Cannot create upload sessions directory: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		if err := sess.persist(s); err != nil {
+			log.Printf("Cannot persist upload session: %v", err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		s.uploadSessions[id] = sess
+
+		util.WriteJsonResponse(w, struct {
+			Status    string `json:"status"`
+			SessionId string `json:"session_id"`
+			ChunkSize int    `json:"chunk_size"`
+		}{Status: "success", SessionId: id, ChunkSize: SubmitChunkSize})
+	}
+	return http.HandlerFunc(f)
+}
+
+// newSubmitChunkPutHandler handles PUT /submit/chunk?session=...&offset=...:
+// it appends the request body to the session's assembled content, provided
+// offset matches exactly what the session has received so far. A mismatch
+// means the client and server have fallen out of sync (e.g. a retried chunk
+// after a dropped response), so it's rejected with the offset the server
+// actually expects instead of silently accepting a gap or a duplicate.
+func newSubmitChunkPutHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		sessionId := req.URL.Query().Get("session")
+		offset, err := strconv.ParseInt(req.URL.Query().Get("offset"), 10, 64)
+		if sessionId == "" || err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+
+		defer req.Body.Close()
+		chunk, err := io.ReadAll(io.LimitReader(req.Body, SubmitChunkSize+1))
+		if err != nil {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+		if len(chunk) > SubmitChunkSize {
+			w.WriteHeader(http.StatusBadRequest)
+			util.WriteJsonResponse(w, submitChunkResult{Status: "error", Message: "Chunk exceeds the server-assigned chunk size"})
+			return
+		}
+
+		s.submissionsMutex.Lock()
+		sess, ok := s.uploadSessions[sessionId]
+		if !ok {
+			s.submissionsMutex.Unlock()
+			util.WriteHttpNotFound(w)
+			return
+		}
+		if offset != sess.ReceivedBytes {
+			expected := sess.ReceivedBytes
+			s.submissionsMutex.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			util.WriteJsonResponse(w, submitChunkResult{Status: "error", Message: "Offset does not match the next expected byte", NextOffset: expected})
+			return
+		}
+		if sess.ReceivedBytes+int64(len(chunk)) > MaxSubmitSessionBytes {
+			expected := sess.ReceivedBytes
+			s.submissionsMutex.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+			util.WriteJsonResponse(w, submitChunkResult{Status: "error", Message: "Upload exceeds the maximum submission size", NextOffset: expected})
+			return
+		}
+
+		file, err := os.OpenFile(s.sessionDataPath(sess.Id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = file.Write(chunk)
+			if err == nil {
+				err = file.Sync()
+			}
+			file.Close()
+		}
+		if err != nil {
+			s.submissionsMutex.Unlock()
+			log.Printf("Cannot write chunk for upload session %s: %v", sess.Id, err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		sess.hasher.Write(chunk)
+		sess.ReceivedBytes += int64(len(chunk))
+		sess.LastActivity = time.Now()
+		nextOffset := sess.ReceivedBytes
+		persistErr := sess.persist(s)
+		s.submissionsMutex.Unlock()
+		if persistErr != nil {
+			log.Printf("Cannot persist upload session %s: %v", sess.Id, persistErr)
+		}
+
+		// The chunk landed, but only /submit/finish marks the upload
+		// complete, so this is the resumable-upload protocol's familiar
+		// "308 resume incomplete" rather than a plain 200.
+		w.WriteHeader(http.StatusPermanentRedirect)
+		util.WriteJsonResponse(w, submitChunkResult{Status: "incomplete", NextOffset: nextOffset})
+	}
+	return http.HandlerFunc(f)
+}
+
+// newSubmitFinishPostHandler handles POST /submit/finish?session=...: it
+// reads back the session's assembled content, round-trips it through
+// shared.Submission's JSON (un)marshaling so the usual Operations/
+// MinerProfile extraction runs exactly as it would for a single-request
+// POST /v2/submissions, then hands it to checkSubmit/doSubmit like any
+// other submission.
+func newSubmitFinishPostHandler(s *SubmissionsServer) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			util.WriteHttpMethodNotAllowed(w)
+			return
+		}
+		sessionId := req.URL.Query().Get("session")
+		if sessionId == "" {
+			util.WriteHttpBadRequest(w)
+			return
+		}
+
+		s.submissionsMutex.Lock()
+		sess, ok := s.uploadSessions[sessionId]
+		if !ok {
+			s.submissionsMutex.Unlock()
+			util.WriteHttpNotFound(w)
+			return
+		}
+		submission := sess.Submission
+		sum := sess.hasher.Sum(nil)
+		dataPath := s.sessionDataPath(sess.Id)
+		s.submissionsMutex.Unlock()
+
+		content, err := os.ReadFile(dataPath)
+		if err != nil {
+			log.Printf("Cannot read assembled content for upload session %s: %v", sessionId, err)
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		submission.Content = string(content)
+
+		raw, err := json.Marshal(submission)
+		if err != nil {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		var finalSubmission shared.Submission
+		if err := json.Unmarshal(raw, &finalSubmission); err != nil {
+			util.WriteHttpInternalServerError(w)
+			return
+		}
+		if finalSubmission.Content == "" {
+			util.WriteJsonResponse(w, OperationResult{Status: "error", Message: "Missing content"})
+			return
+		}
+
+		res := OperationResult{}
+		if ok, rejected := s.checkSubmit(finalSubmission); !ok {
+			res = rejected
+		} else {
+			res = s.doSubmit(finalSubmission)
+		}
+
+		s.submissionsMutex.Lock()
+		s.removeUploadSessionLocked(sessionId)
+		s.submissionsMutex.Unlock()
+
+		util.WriteJsonResponse(w, struct {
+			OperationResult
+			ContentSHA256 string `json:"content_sha256"`
+		}{OperationResult: res, ContentSHA256: hex.EncodeToString(sum)})
+	}
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitChunkedUpload_HappyPath(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submit-session-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+
+	startHandler := newSubmitStartPostHandler(s)
+	startReq := httptest.NewRequest(http.MethodPost, "/submit/start", bytes.NewBufferString(`{"id":"A000045","mode":"add","type":"program","submitter":"alice"}`))
+	startRec := httptest.NewRecorder()
+	startHandler.ServeHTTP(startRec, startReq)
+	assert.Equal(t, http.StatusOK, startRec.Code)
+
+	var startResult struct {
+		Status    string `json:"status"`
+		SessionId string `json:"session_id"`
+		ChunkSize int    `json:"chunk_size"`
+	}
+	assert.NoError(t, json.Unmarshal(startRec.Body.Bytes(), &startResult))
+	assert.Equal(t, "success", startResult.Status)
+	assert.NotEmpty(t, startResult.SessionId)
+
+	chunkHandler := newSubmitChunkPutHandler(s)
+	content := "mov $0,1\nadd $0,2\n"
+	part1, part2 := content[:8], content[8:]
+
+	chunkReq := httptest.NewRequest(http.MethodPut, "/submit/chunk?session="+startResult.SessionId+"&offset=0", bytes.NewBufferString(part1))
+	chunkRec := httptest.NewRecorder()
+	chunkHandler.ServeHTTP(chunkRec, chunkReq)
+	assert.Equal(t, http.StatusPermanentRedirect, chunkRec.Code)
+	var chunkResult submitChunkResult
+	assert.NoError(t, json.Unmarshal(chunkRec.Body.Bytes(), &chunkResult))
+	assert.Equal(t, "incomplete", chunkResult.Status)
+	assert.Equal(t, int64(len(part1)), chunkResult.NextOffset)
+
+	chunkReq2 := httptest.NewRequest(http.MethodPut, "/submit/chunk?session="+startResult.SessionId+"&offset=8", bytes.NewBufferString(part2))
+	chunkRec2 := httptest.NewRecorder()
+	chunkHandler.ServeHTTP(chunkRec2, chunkReq2)
+	assert.Equal(t, http.StatusPermanentRedirect, chunkRec2.Code)
+	assert.NoError(t, json.Unmarshal(chunkRec2.Body.Bytes(), &chunkResult))
+	assert.Equal(t, int64(len(content)), chunkResult.NextOffset)
+
+	finishHandler := newSubmitFinishPostHandler(s)
+	finishReq := httptest.NewRequest(http.MethodPost, "/submit/finish?session="+startResult.SessionId, nil)
+	finishRec := httptest.NewRecorder()
+	finishHandler.ServeHTTP(finishRec, finishReq)
+	assert.Equal(t, http.StatusOK, finishRec.Code)
+
+	var finishResult struct {
+		Status        string `json:"status"`
+		ContentSHA256 string `json:"content_sha256"`
+	}
+	assert.NoError(t, json.Unmarshal(finishRec.Body.Bytes(), &finishResult))
+	assert.Equal(t, "success", finishResult.Status)
+	assert.NotEmpty(t, finishResult.ContentSHA256)
+
+	assert.Len(t, s.submissions, 1)
+	assert.Equal(t, content, s.submissions[0].Content)
+	assert.Equal(t, "A000045", s.submissions[0].Id.String())
+
+	// The session must be cleaned up once finished.
+	assert.Empty(t, s.uploadSessions)
+	_, err = os.Stat(s.sessionDataPath(startResult.SessionId))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSubmitChunk_RejectsOffsetMismatch(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submit-session-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+
+	startHandler := newSubmitStartPostHandler(s)
+	startReq := httptest.NewRequest(http.MethodPost, "/submit/start", bytes.NewBufferString(`{"id":"A000045","mode":"add","type":"program","submitter":"alice"}`))
+	startRec := httptest.NewRecorder()
+	startHandler.ServeHTTP(startRec, startReq)
+	var startResult struct {
+		SessionId string `json:"session_id"`
+	}
+	assert.NoError(t, json.Unmarshal(startRec.Body.Bytes(), &startResult))
+
+	chunkHandler := newSubmitChunkPutHandler(s)
+	chunkReq := httptest.NewRequest(http.MethodPut, "/submit/chunk?session="+startResult.SessionId+"&offset=5", bytes.NewBufferString("oops"))
+	chunkRec := httptest.NewRecorder()
+	chunkHandler.ServeHTTP(chunkRec, chunkReq)
+	assert.Equal(t, http.StatusConflict, chunkRec.Code)
+
+	var chunkResult submitChunkResult
+	assert.NoError(t, json.Unmarshal(chunkRec.Body.Bytes(), &chunkResult))
+	assert.Equal(t, "error", chunkResult.Status)
+	assert.Equal(t, int64(0), chunkResult.NextOffset)
+}
+
+func TestSubmitChunkedUpload_ResumesAfterRestart(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submit-session-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+
+	startHandler := newSubmitStartPostHandler(s)
+	startReq := httptest.NewRequest(http.MethodPost, "/submit/start", bytes.NewBufferString(`{"id":"A000045","mode":"add","type":"program","submitter":"alice"}`))
+	startRec := httptest.NewRecorder()
+	startHandler.ServeHTTP(startRec, startReq)
+	var startResult struct {
+		SessionId string `json:"session_id"`
+	}
+	assert.NoError(t, json.Unmarshal(startRec.Body.Bytes(), &startResult))
+
+	chunkHandler := newSubmitChunkPutHandler(s)
+	chunkReq := httptest.NewRequest(http.MethodPut, "/submit/chunk?session="+startResult.SessionId+"&offset=0", bytes.NewBufferString("mov $0,1\n"))
+	chunkRec := httptest.NewRecorder()
+	chunkHandler.ServeHTTP(chunkRec, chunkReq)
+	assert.Equal(t, http.StatusPermanentRedirect, chunkRec.Code)
+
+	// Simulate a restart: a fresh server pointed at the same data directory
+	// should pick the session back up with its received bytes intact.
+	s2 := newTestSubmissionsServer(tempDir, true, 60)
+	s2.loadUploadSessions()
+	assert.Len(t, s2.uploadSessions, 1)
+	resumed, ok := s2.uploadSessions[startResult.SessionId]
+	assert.True(t, ok)
+	assert.Equal(t, int64(len("mov $0,1\n")), resumed.ReceivedBytes)
+
+	finishHandler := newSubmitFinishPostHandler(s2)
+	finishReq := httptest.NewRequest(http.MethodPost, "/submit/finish?session="+startResult.SessionId, nil)
+	finishRec := httptest.NewRecorder()
+	finishHandler.ServeHTTP(finishRec, finishReq)
+	assert.Equal(t, http.StatusOK, finishRec.Code)
+	assert.Equal(t, "mov $0,1\n", s2.submissions[0].Content)
+}
+
+func TestSubmitStart_RejectsNonProgramType(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submit-session-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	s := newTestSubmissionsServer(tempDir, true, 60)
+
+	startHandler := newSubmitStartPostHandler(s)
+	startReq := httptest.NewRequest(http.MethodPost, "/submit/start", bytes.NewBufferString(`{"id":"A000045","mode":"remove","type":"bfile","submitter":"alice"}`))
+	startRec := httptest.NewRecorder()
+	startHandler.ServeHTTP(startRec, startReq)
+
+	var result OperationResult
+	assert.NoError(t, json.Unmarshal(startRec.Body.Bytes(), &result))
+	assert.Equal(t, "error", result.Status)
+}
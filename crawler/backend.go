@@ -0,0 +1,262 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loda-lang/loda-api/util"
+	"golang.org/x/time/rate"
+)
+
+// OeisBackend fetches OEIS content: a raw file (summary or b-file) and a
+// single sequence's full-text search result. It exists so Crawler and the
+// v1 HTTP handlers can share one rate limiter and mirror-fallback policy
+// instead of each hitting OEIS independently; MultiBackend is the only
+// implementation.
+type OeisBackend interface {
+	// FetchURL downloads url into localFile, substituting each of the
+	// backend's configured mirrors for url's host in turn until one
+	// succeeds or all have failed.
+	FetchURL(ctx context.Context, url, localFile string) error
+	// FetchSequence fetches sequence id's full-text OEIS record, returning
+	// its parsed Fields and the HTTP status observed on the attempt that
+	// decided the outcome.
+	FetchSequence(ctx context.Context, id int, silent bool) ([]Field, int, error)
+}
+
+// Defaults for MultiBackend's per-mirror retry schedule: the delay before
+// the first retry on the same mirror, doubling (plus jitter of the same
+// magnitude) on each further attempt, before giving up on that mirror and
+// moving to the next one. defaultMirrorBaseBackoff is a var rather than a
+// const so tests can shrink it.
+var defaultMirrorBaseBackoff = 500 * time.Millisecond
+
+const (
+	defaultMirrorMaxBackoff  = 30 * time.Second
+	defaultMirrorMaxAttempts = 3
+)
+
+// MultiBackend is an OeisBackend that tries a list of mirrors in priority
+// order, sharing a token-bucket rate limiter across every fetch it
+// performs, so a crawler tick and a concurrent cache-miss request can't
+// together exceed the configured requests/sec against OEIS. A failed
+// attempt (5xx, timeout, or network error) is retried on the same mirror
+// with exponential backoff and jitter up to defaultMirrorMaxAttempts times
+// before moving on to the next mirror; a 4xx response is treated as final
+// for that mirror and not retried.
+type MultiBackend struct {
+	// Mirrors are tried in order; Mirrors[0] is primary. Each must be a
+	// base URL ending in "/" (e.g. "https://oeis.org/").
+	Mirrors []string
+
+	httpClient *http.Client
+	limiter    *rate.Limiter
+}
+
+// NewMultiBackend creates a MultiBackend. mirrors must be non-empty.
+// requestsPerSecond and burst configure the token bucket shared by every
+// fetch the backend performs.
+func NewMultiBackend(httpClient *http.Client, mirrors []string, requestsPerSecond float64, burst int) *MultiBackend {
+	return &MultiBackend{
+		Mirrors:    mirrors,
+		httpClient: httpClient,
+		limiter:    rate.NewLimiter(rate.Limit(requestsPerSecond), burst),
+	}
+}
+
+// FetchURL implements OeisBackend. url is expected to start with one of
+// b.Mirrors (normally Mirrors[0], since that's what callers build URLs
+// from); the matching prefix is swapped out for each mirror in turn on
+// failure. A url that doesn't match any configured mirror is fetched as
+// given, still subject to the shared rate limit, with no fallback.
+func (b *MultiBackend) FetchURL(ctx context.Context, url, localFile string) error {
+	suffix, ok := cutAnyPrefix(url, b.Mirrors)
+	if !ok {
+		// Not built from one of our mirrors; fetch it as given, still
+		// subject to the shared rate limit, with no fallback.
+		return b.fetchFileWithRetry(ctx, url, localFile)
+	}
+	var lastErr error
+	for _, mirror := range b.Mirrors {
+		lastErr = b.fetchFileWithRetry(ctx, mirror+suffix, localFile)
+		if lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+// cutAnyPrefix reports whether url has one of mirrors as a prefix, cutting
+// it off if so.
+func cutAnyPrefix(url string, mirrors []string) (string, bool) {
+	for _, m := range mirrors {
+		if suffix, ok := strings.CutPrefix(url, m); ok {
+			return suffix, true
+		}
+	}
+	return "", false
+}
+
+// FetchSequence implements OeisBackend.
+func (b *MultiBackend) FetchSequence(ctx context.Context, id int, silent bool) ([]Field, int, error) {
+	var lastErr error
+	var lastStatus int
+	for _, mirror := range b.Mirrors {
+		url := fmt.Sprintf("%ssearch?q=id:A%06d&fmt=text", mirror, id)
+		fields, status, err := b.fetchSequenceWithRetry(ctx, url, silent)
+		if err == nil {
+			return fields, status, nil
+		}
+		lastErr = err
+		lastStatus = status
+	}
+	return nil, lastStatus, lastErr
+}
+
+// fetchFileWithRetry downloads url into localFile, retrying transient
+// failures with exponential backoff and jitter up to
+// defaultMirrorMaxAttempts times.
+func (b *MultiBackend) fetchFileWithRetry(ctx context.Context, url, localFile string) error {
+	var lastErr error
+	for attempt := 0; attempt < defaultMirrorMaxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepBackoff(ctx, attempt); err != nil {
+				return err
+			}
+		}
+		retryable, err := b.fetchFileOnce(ctx, url, localFile)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+	}
+	return lastErr
+}
+
+func (b *MultiBackend) fetchFileOnce(ctx context.Context, url, localFile string) (retryable bool, err error) {
+	if err := b.limiter.Wait(ctx); err != nil {
+		return false, err
+	}
+	return util.FetchFileWithOptions(ctx, b.httpClient, url, localFile, util.FetchFileOptions{})
+}
+
+// fetchSequenceWithRetry is FetchSequence's per-mirror retry loop, mirroring
+// fetchFileWithRetry but parsing Fields from the response body instead of
+// writing it to a file. A 429 or 503 honors any Retry-After the server sent
+// instead of the usual exponential schedule.
+func (b *MultiBackend) fetchSequenceWithRetry(ctx context.Context, url string, silent bool) ([]Field, int, error) {
+	var lastErr error
+	var lastStatus int
+	var retryAfter time.Duration
+	for attempt := 0; attempt < defaultMirrorMaxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepRetryDelay(ctx, attempt, retryAfter); err != nil {
+				return nil, lastStatus, err
+			}
+		}
+		fields, status, ra, retryable, err := b.fetchSequenceOnce(ctx, url, silent)
+		if err == nil {
+			return fields, status, nil
+		}
+		lastErr = err
+		lastStatus = status
+		retryAfter = ra
+		if !retryable {
+			break
+		}
+	}
+	return nil, lastStatus, lastErr
+}
+
+func (b *MultiBackend) fetchSequenceOnce(ctx context.Context, url string, silent bool) (fields []Field, status int, retryAfter time.Duration, retryable bool, err error) {
+	if err := b.limiter.Wait(ctx); err != nil {
+		return nil, 0, 0, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, 0, true, err
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return nil, status, parseRetryAfter(resp.Header.Get("Retry-After")), true, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	if status >= 400 {
+		return nil, status, 0, false, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if field, ferr := ParseField(scanner.Text()); ferr == nil {
+			fields = append(fields, field)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, status, 0, true, err
+	}
+	if len(fields) == 0 {
+		return nil, status, 0, false, fmt.Errorf("no fields found")
+	}
+	return fields, status, 0, false, nil
+}
+
+// sleepBackoff waits before retry attempt (1-based: 1 is the delay before
+// the second send), doubling defaultMirrorBaseBackoff each attempt up to
+// defaultMirrorMaxBackoff, plus a random jitter of the same magnitude.
+func sleepBackoff(ctx context.Context, attempt int) error {
+	return sleepRetryDelay(ctx, attempt, 0)
+}
+
+// sleepRetryDelay waits before a retry attempt: retryAfter, if positive,
+// overrides the usual exponential schedule (honoring a 429/503 Retry-After
+// header); otherwise it falls back to the same doubling-plus-jitter delay
+// sleepBackoff always used.
+func sleepRetryDelay(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	d := retryAfter
+	if d <= 0 {
+		d = defaultMirrorBaseBackoff * time.Duration(int64(1)<<uint(attempt-1))
+		if d > defaultMirrorMaxBackoff {
+			d = defaultMirrorMaxBackoff
+		}
+		d += time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter decodes a Retry-After header value, either delay-seconds
+// or an HTTP-date, returning 0 if v is empty or unparseable (or already in
+// the past) so the caller falls back to its own exponential backoff.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
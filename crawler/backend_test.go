@@ -0,0 +1,51 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiBackend_FetchURLFallsBackToSecondMirror(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	origBackoff := defaultMirrorBaseBackoff
+	defaultMirrorBaseBackoff = time.Millisecond
+	defer func() { defaultMirrorBaseBackoff = origBackoff }()
+
+	b := NewMultiBackend(http.DefaultClient, []string{bad.URL + "/", good.URL + "/"}, 1000, 1000)
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	err := b.FetchURL(context.Background(), bad.URL+"/names.gz", dst)
+	assert.NoError(t, err)
+	got, err := os.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(got))
+}
+
+func TestMultiBackend_FetchURLNotAMirrorFetchesVerbatim(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("verbatim"))
+	}))
+	defer srv.Close()
+
+	b := NewMultiBackend(http.DefaultClient, []string{"https://oeis.org/"}, 1000, 1000)
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	err := b.FetchURL(context.Background(), srv.URL, dst)
+	assert.NoError(t, err)
+	got, err := os.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "verbatim", string(got))
+}
@@ -0,0 +1,164 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// defaultOeisURL is the sole mirror NewCrawler falls back to when it isn't
+// given a backend explicitly; it matches the OeisWebsite constant the
+// oeis/sequences servers pass to v1.Deps.
+const defaultOeisURL = "https://oeis.org/"
+
+// defaultBackendRequestsPerSecond and defaultBackendBurst are conservative
+// defaults for a lone crawler hitting OEIS directly, with no HTTP handlers
+// sharing the same MultiBackend (see NewCrawlerWithBackend for that case).
+const (
+	defaultBackendRequestsPerSecond = 2
+	defaultBackendBurst             = 4
+)
+
+// maxTransientRetries caps how many times FetchNext requeues an id onto
+// MissingIds after a transient (non-404) fetch failure, so a persistently
+// broken id (or mirror) doesn't requeue itself forever.
+const maxTransientRetries = 3
+
+// Crawler walks the OEIS id space with a random coprime step, so repeated
+// runs don't all hammer the same sequences in the same order.
+type Crawler struct {
+	MaxId      int
+	CurrentId  int
+	StepSize   int
+	NumFetched int
+	MissingIds []int
+	rand       *rand.Rand
+	backend    OeisBackend
+
+	// retryCounts tracks, per id, how many times a transient fetch failure
+	// has requeued it onto MissingIds (see fetchWithRequeue).
+	retryCounts map[int]int
+}
+
+// NewCrawler creates a Crawler that fetches directly from oeis.org via
+// httpClient, with its own rate limiter not shared with anything else. Use
+// NewCrawlerWithBackend to share a MultiBackend (and its rate limiter and
+// mirror list) with the HTTP handlers serving the same OEIS data.
+func NewCrawler(httpClient *http.Client) *Crawler {
+	return NewCrawlerWithBackend(NewMultiBackend(httpClient, []string{defaultOeisURL}, defaultBackendRequestsPerSecond, defaultBackendBurst))
+}
+
+// NewCrawlerWithBackend creates a Crawler that fetches sequences through
+// backend, so it shares the rate limiter, mirror list, and retry policy of
+// any other caller (typically the v1 summary/b-file HTTP handlers) using
+// the same backend instance.
+func NewCrawlerWithBackend(backend OeisBackend) *Crawler {
+	return &Crawler{
+		backend:     backend,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		retryCounts: make(map[int]int),
+	}
+}
+
+func (c *Crawler) Init(ctx context.Context) error {
+	log.Print("Initializing crawler")
+	maxId, err := c.findMaxId(ctx)
+	if err != nil {
+		return err
+	}
+	c.MaxId = maxId
+	c.CurrentId = c.rand.Intn(maxId) + 1
+	for i := 0; i < maxId; i++ {
+		c.StepSize = c.rand.Intn(maxId) + 1
+		if gcd(c.StepSize, maxId) == 1 {
+			break
+		}
+	}
+	log.Printf("Set max ID: %d, current ID: %d, step size: %d", c.MaxId, c.CurrentId, c.StepSize)
+	return nil
+}
+
+// FetchSeq fetches sequence id's full-text OEIS record through c's backend,
+// which applies the shared rate limit, mirror fallback, and retry policy.
+func (c *Crawler) FetchSeq(ctx context.Context, id int, silent bool) ([]Field, int, error) {
+	if !silent {
+		log.Printf("Fetching A%06d", id)
+	}
+	return c.backend.FetchSequence(ctx, id, silent)
+}
+
+func (c *Crawler) FetchNext(ctx context.Context) ([]Field, int, error) {
+	// Fetch missing sequences first
+	if len(c.MissingIds) > 0 {
+		id := c.MissingIds[0]
+		c.MissingIds = c.MissingIds[1:]
+		c.NumFetched++
+		return c.fetchWithRequeue(ctx, id)
+	}
+	// Fetch the next sequence
+	if c.MaxId == 0 || c.NumFetched == c.MaxId {
+		err := c.Init(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		c.CurrentId = ((c.CurrentId + c.StepSize) % c.MaxId) + 1
+	}
+	c.NumFetched++
+	return c.fetchWithRequeue(ctx, c.CurrentId)
+}
+
+// fetchWithRequeue fetches id and tells apart a "definitely absent" 404
+// (which is just a gap in the OEIS id space, not a failure) from a
+// transient failure (5xx, 429, timeout, network error): only the latter
+// requeues id onto MissingIds, up to maxTransientRetries times, so a blip
+// doesn't strand id unfetched for the rest of the cycle.
+func (c *Crawler) fetchWithRequeue(ctx context.Context, id int) ([]Field, int, error) {
+	fields, status, err := c.FetchSeq(ctx, id, false)
+	if err == nil || status == http.StatusNotFound {
+		delete(c.retryCounts, id)
+		return fields, status, err
+	}
+	if c.retryCounts[id] < maxTransientRetries {
+		c.retryCounts[id]++
+		c.MissingIds = append(c.MissingIds, id)
+	} else {
+		delete(c.retryCounts, id)
+	}
+	return fields, status, err
+}
+
+// findMaxId binary-searches for the highest id OEIS has assigned, fetching
+// silently. A 404 means id is past the end (h = m); any other error is
+// transient (after MultiBackend's own retries have already been
+// exhausted), and is returned immediately rather than being treated as
+// "not found", which would otherwise bisect the search to the wrong max.
+func (c *Crawler) findMaxId(ctx context.Context) (int, error) {
+	l := 0
+	h := 1000000
+	for l < h {
+		m := (l + h) / 2
+		_, status, err := c.FetchSeq(ctx, m, true)
+		if err == nil {
+			l = m + 1
+			continue
+		}
+		if status == http.StatusNotFound {
+			h = m
+			continue
+		}
+		return 0, err
+	}
+	return h, nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		t := b
+		b = a % b
+		a = t
+	}
+	return a
+}
@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkFieldBasics(t *testing.T, fields []Field) {
+	assert.True(t, len(fields) > 0, "Expected some fields")
+}
+
+func findField(t *testing.T, fields []Field, key string) Field {
+	idx := slices.IndexFunc(fields, func(f Field) bool { return f.Key == key })
+	assert.NotEqual(t, -1, idx, "Expected a field with key %s", key)
+	return fields[idx]
+}
+
+func checkFieldDetails(t *testing.T, fields []Field, key string, seqId int, content string) {
+	f := findField(t, fields, key)
+	checkField(t, f, key, seqId, content)
+}
+
+func TestCrawler_Init(t *testing.T) {
+	c := NewCrawler(http.DefaultClient)
+	err := c.Init(context.Background())
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.True(t, c.MaxId > 0, "Unexpected max Id")
+	assert.True(t, c.CurrentId > 0 && c.CurrentId <= c.MaxId, "Unexpected current Id")
+	assert.True(t, c.StepSize > 0, "Unexpected step size")
+}
+
+func TestCrawler_FetchSeq(t *testing.T) {
+	c := NewCrawler(http.DefaultClient)
+	fields, status, err := c.FetchSeq(context.Background(), 30, false)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, http.StatusOK, status, "Expected OK status")
+	checkFieldDetails(t, fields, "N", 30, "Initial digit of n.")
+	checkFieldDetails(t, fields, "K", 30, "nonn,base,easy,nice,look")
+	checkFieldDetails(t, fields, "O", 30, "0,3")
+}
+
+func TestCrawler_FetchNext(t *testing.T) {
+	c := NewCrawler(http.DefaultClient)
+	for i := 0; i < 10; i++ {
+		fields, status, err := c.FetchNext(context.Background())
+		assert.Equal(t, http.StatusOK, status, "Expected OK status")
+		assert.Equal(t, nil, err, "Expected no error")
+		checkFieldBasics(t, fields)
+		findField(t, fields, "N")
+	}
+}
+
+// TestCrawler_FindMaxIdConvergesThroughTransientErrors checks that
+// findMaxId's binary search still lands on the true max id when some
+// requests along the way fail transiently (429/503, retried and
+// eventually honored by MultiBackend) instead of definitively (404).
+func TestCrawler_FindMaxIdConvergesThroughTransientErrors(t *testing.T) {
+	const trueMaxId = 50
+	var mu sync.Mutex
+	attempts := map[int]int{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		fmt.Sscanf(r.URL.Query().Get("q"), "id:A%d", &id)
+
+		// Fail every id's first attempt transiently (alternating 429 and
+		// 503), so MultiBackend's retry-with-backoff has to succeed
+		// before findMaxId sees a decisive answer for that id.
+		mu.Lock()
+		attempts[id]++
+		n := attempts[id]
+		mu.Unlock()
+		if n == 1 {
+			if id%2 == 0 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			} else {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+
+		if id > trueMaxId {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "%%N A%06d Test sequence.\n", id)
+	}))
+	defer srv.Close()
+
+	origBackoff := defaultMirrorBaseBackoff
+	defaultMirrorBaseBackoff = time.Millisecond
+	defer func() { defaultMirrorBaseBackoff = origBackoff }()
+
+	backend := NewMultiBackend(http.DefaultClient, []string{srv.URL + "/"}, 1000, 1000)
+	c := NewCrawlerWithBackend(backend)
+	err := c.Init(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, trueMaxId, c.MaxId)
+}
+
+// TestCrawler_FetchNextRequeuesTransientFailures checks that a transient
+// fetch failure requeues its id onto MissingIds (to be retried on a later
+// tick) instead of silently dropping it, while a 404 does not.
+func TestCrawler_FetchNextRequeuesTransientFailures(t *testing.T) {
+	var numRequests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&numRequests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	origBackoff := defaultMirrorBaseBackoff
+	defaultMirrorBaseBackoff = time.Millisecond
+	defer func() { defaultMirrorBaseBackoff = origBackoff }()
+
+	backend := NewMultiBackend(http.DefaultClient, []string{srv.URL + "/"}, 1000, 1000)
+	c := NewCrawlerWithBackend(backend)
+	c.MaxId = 100
+	c.CurrentId = 1
+	c.StepSize = 1
+
+	_, status, err := c.FetchNext(context.Background())
+	assert.Error(t, err)
+	assert.NotEqual(t, http.StatusNotFound, status, "first fetch should be the injected transient 503, not a 404")
+	assert.Len(t, c.MissingIds, 1, "transient failure should requeue its id onto MissingIds")
+
+	_, status, err = c.FetchNext(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, status, "a 404 must not be requeued")
+	assert.Empty(t, c.MissingIds)
+}
@@ -0,0 +1,34 @@
+package crawler
+
+import "sync"
+
+// FetchCancel mirrors the netstack deadlineTimer pattern: a single
+// mutex-protected channel that any number of goroutines can select on via
+// Done(), and that Cancel unblocks by closing. Unlike a plain chan bool, it
+// recreates the channel on every Cancel so a later fetch or flush can wait
+// on a fresh one instead of seeing a permanently-closed channel.
+type FetchCancel struct {
+	mutex sync.Mutex
+	ch    chan struct{}
+}
+
+func NewFetchCancel() *FetchCancel {
+	return &FetchCancel{ch: make(chan struct{})}
+}
+
+// Done returns the channel to select against; it closes when Cancel is
+// called.
+func (f *FetchCancel) Done() <-chan struct{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.ch
+}
+
+// Cancel wakes any goroutine currently blocked on Done(), then recreates
+// the channel so the next fetch or flush gets a fresh one.
+func (f *FetchCancel) Cancel() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	close(f.ch)
+	f.ch = make(chan struct{})
+}
@@ -1,4 +1,4 @@
-package main
+package crawler
 
 import (
 	"fmt"
@@ -10,6 +10,8 @@ var (
 	fieldRegexp = regexp.MustCompile(`%([A-Za-z])\s+A([0-9]+)\s+(.+)`)
 )
 
+// Field is a single OEIS metadata line, e.g. "%N A000042 ...", as returned
+// by the OEIS search API and stored in the per-key List files.
 type Field struct {
 	Key     string
 	SeqId   int
@@ -0,0 +1,83 @@
+package shared
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// fileChunk is a byte range within a file, aligned so that no line is split
+// across a chunk boundary.
+type fileChunk struct {
+	offset int64
+	length int64
+}
+
+// splitFileIntoChunks stats path and divides it into at most n byte ranges
+// of roughly equal size, each nudged forward to the next newline so a
+// caller can scan every chunk independently (e.g. via io.NewSectionReader)
+// without ever seeing a line cut in half. It returns a single chunk
+// spanning the whole file if n <= 1 or the file is too small to be worth
+// splitting.
+func splitFileIntoChunks(path string, n int) ([]fileChunk, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+
+	const minChunkSize = 1 << 20 // 1 MiB
+	if n <= 1 || size <= minChunkSize {
+		return []fileChunk{{offset: 0, length: size}}, nil
+	}
+	if int64(n) > size/minChunkSize {
+		n = int(size / minChunkSize)
+		if n < 1 {
+			n = 1
+		}
+	}
+
+	boundaries := make([]int64, n+1)
+	boundaries[0] = 0
+	boundaries[n] = size
+	step := size / int64(n)
+	reader := bufio.NewReader(file)
+	var pos int64
+	for i := 1; i < n; i++ {
+		target := step * int64(i)
+		if target <= pos {
+			target = pos + 1
+		}
+		if target >= size {
+			boundaries[i] = size
+			continue
+		}
+		if _, err := file.Seek(target, 0); err != nil {
+			return nil, fmt.Errorf("failed to seek file: %w", err)
+		}
+		reader.Reset(file)
+		pos = target
+		if line, err := reader.ReadString('\n'); err != nil {
+			boundaries[i] = size
+		} else {
+			pos = target + int64(len(line))
+			boundaries[i] = pos
+		}
+	}
+
+	chunks := make([]fileChunk, 0, n)
+	for i := 0; i < n; i++ {
+		start, end := boundaries[i], boundaries[i+1]
+		if end <= start {
+			continue
+		}
+		chunks = append(chunks, fileChunk{offset: start, length: end - start})
+	}
+	return chunks, nil
+}
@@ -6,17 +6,19 @@ import (
 	"github.com/loda-lang/loda-api/util"
 )
 
+// extractOperations, extractHeaderComments, extractIdAndName,
+// extractSubmitter, extractFormula, extractMinerProfile, updateIdAndName and
+// updateSubmitter all used to re-scan code themselves with
+// strings.Split/SplitN and prefix checks, each with its own slightly
+// different idea of where the header ends. They're now thin wrappers around
+// ParseProgramAST (see program_ast.go), which does that scan once.
+
 func extractOperations(code string) []string {
-	var operations []string
-	lines := strings.Split(code, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if len(line) == 0 || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
-			continue
-		}
-		operations = append(operations, line)
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return nil
 	}
-	return operations
+	return ast.Operations()
 }
 
 // extractOperationTypes extracts the unique operation types from operations.
@@ -25,7 +27,6 @@ func extractOperationTypes(operations []string) []string {
 	seen := make(map[string]bool)
 	var opTypes []string
 	for _, op := range operations {
-		// Get the first word (the operation type)
 		parts := strings.Fields(op)
 		if len(parts) > 0 {
 			opType := parts[0]
@@ -39,142 +40,63 @@ func extractOperationTypes(operations []string) []string {
 }
 
 func extractHeaderComments(code string) []string {
-	lines := strings.Split(code, "\n")
-	var header []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			continue
-		}
-		if strings.HasPrefix(line, ";") {
-			comment := strings.TrimSpace(line[1:])
-			header = append(header, comment)
-		} else {
-			break
-		}
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return nil
+	}
+	comments := make([]string, len(ast.Header))
+	for i, c := range ast.Header {
+		comments[i] = c.Text
 	}
-	return header
+	return comments
 }
 
 func extractIdAndName(code string) (util.UID, string) {
-	var id util.UID
-	var name string
-	header := extractHeaderComments(code)
-	for _, comment := range header {
-		parts := strings.SplitN(comment, ":", 2)
-		if len(parts) == 2 {
-			idStr := strings.TrimSpace(parts[0])
-			uid, err := util.NewUIDFromString(idStr)
-			if err == nil {
-				id = uid
-				name = strings.TrimSpace(parts[1])
-				break
-			}
-		}
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return util.UID{}, ""
 	}
-	return id, name
+	return ast.IdAndName()
 }
 
-var submitterPrefix = "Submitted by "
-
 func extractSubmitter(code string) *Submitter {
-	header := extractHeaderComments(code)
-	for _, comment := range header {
-		if after, ok := strings.CutPrefix(comment, submitterPrefix); ok {
-			name := strings.TrimSpace(after)
-			return &Submitter{Name: name}
-		}
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return nil
 	}
-	return nil
+	return ast.Submitter()
 }
 
 func extractFormula(code string) string {
-	header := extractHeaderComments(code)
-	for _, comment := range header {
-		if after, ok := strings.CutPrefix(comment, "Formula:"); ok {
-			return strings.TrimSpace(after)
-		}
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return ""
 	}
-	return ""
+	return ast.Formula()
 }
 
 func extractMinerProfile(code string) string {
-	// Miner profiles are not always in the header
-	lines := strings.Split(code, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if after, ok := strings.CutPrefix(line, "; Miner Profile:"); ok {
-			return strings.TrimSpace(after)
-		}
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return ""
 	}
-	return ""
+	return ast.MinerProfile()
 }
 
 func updateIdAndName(code string, id util.UID, name string) string {
-	lines := strings.Split(code, "\n")
-	isHeader := true
-	updated := false
-	resultLines := []string{}
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			resultLines = append(resultLines, line)
-			continue
-		}
-		if !strings.HasPrefix(line, ";") {
-			if !updated {
-				resultLines = append(resultLines, "; "+id.String()+": "+name)
-				updated = true
-			}
-			isHeader = false
-		}
-		if isHeader && !updated {
-			comment := strings.TrimSpace(line[1:])
-			parts := strings.SplitN(comment, ":", 2)
-			if len(parts) == 2 {
-				idStr := strings.TrimSpace(parts[0])
-				_, err := util.NewUIDFromString(idStr)
-				if err == nil {
-					line = "; " + id.String() + ": " + name
-					updated = true
-				}
-			}
-		}
-		resultLines = append(resultLines, line)
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return code
 	}
-	return strings.Join(resultLines, "\n")
+	ast.SetIdAndName(id, name)
+	return ast.String()
 }
 
 func updateSubmitter(code string, submitter *Submitter) string {
-	resultLines := []string{}
-	lines := strings.Split(code, "\n")
-	isHeader := true
-	updated := false
-	for _, line := range lines {
-		line := strings.TrimSpace(line)
-		if len(line) == 0 {
-			resultLines = append(resultLines, line)
-			continue
-		}
-		if !strings.HasPrefix(line, ";") {
-			if !updated && submitter != nil {
-				resultLines = append(resultLines, "; "+submitterPrefix+submitter.Name)
-				updated = true
-			}
-			isHeader = false
-		}
-		if isHeader && !updated {
-			comment := strings.TrimSpace(line[1:])
-			if strings.HasPrefix(comment, submitterPrefix) {
-				if submitter != nil {
-					line = "; " + submitterPrefix + submitter.Name
-				} else {
-					continue // remove the line
-				}
-				updated = true
-			}
-		}
-		resultLines = append(resultLines, line)
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		return code
 	}
-	return strings.Join(resultLines, "\n")
+	ast.SetSubmitter(submitter)
+	return ast.String()
 }
@@ -0,0 +1,229 @@
+package shared
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// CrawlPolicy bounds how hard Crawler is allowed to hit OEIS: a token-bucket
+// rate limit, a cap on concurrent in-flight requests, and a retry budget for
+// transient (429/5xx) failures. A nil *CrawlPolicy passed to NewCrawler
+// falls back to DefaultCrawlPolicy.
+type CrawlPolicy struct {
+	// RequestsPerSecond and Burst configure the token-bucket limiter every
+	// outgoing request waits on before it's sent.
+	RequestsPerSecond float64
+	Burst             int
+	// MaxConcurrency caps how many requests may be in flight to OEIS at
+	// once, independent of the rate limiter.
+	MaxConcurrency int
+	// MaxAttempts bounds how many times a request is sent in total (the
+	// first attempt plus retries) after a 429 or 5xx response. BaseBackoff is
+	// the delay before the first retry, doubling (plus jitter of the same
+	// magnitude) on each further attempt and capped at MaxBackoff, unless the
+	// response carried a Retry-After header, which takes precedence.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultCrawlPolicy is a conservative default for a background crawler
+// sharing OEIS with everyone else: one request per second, a burst of two,
+// at most two requests in flight, and up to four attempts per request.
+var DefaultCrawlPolicy = &CrawlPolicy{
+	RequestsPerSecond: 1,
+	Burst:             2,
+	MaxConcurrency:    2,
+	MaxAttempts:       4,
+	BaseBackoff:       time.Second,
+	MaxBackoff:        30 * time.Second,
+}
+
+// crawlLimiter turns a CrawlPolicy into the runtime state doRequest needs: a
+// token bucket and a concurrency semaphore.
+type crawlLimiter struct {
+	policy    *CrawlPolicy
+	tokens    *rate.Limiter
+	semaphore chan struct{}
+}
+
+func newCrawlLimiter(policy *CrawlPolicy) *crawlLimiter {
+	if policy == nil {
+		policy = DefaultCrawlPolicy
+	}
+	return &crawlLimiter{
+		policy:    policy,
+		tokens:    rate.NewLimiter(rate.Limit(policy.RequestsPerSecond), policy.Burst),
+		semaphore: make(chan struct{}, policy.MaxConcurrency),
+	}
+}
+
+// acquire waits for both a rate-limiter token and a free concurrency slot,
+// returning a release func the caller must call once the request completes.
+func (l *crawlLimiter) acquire(ctx context.Context) (func(), error) {
+	if err := l.tokens.Wait(ctx); err != nil {
+		return nil, err
+	}
+	select {
+	case l.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return func() { <-l.semaphore }, nil
+}
+
+// backoff returns how long to wait before retry attempt (1-based: 1 is the
+// delay before the second send), honoring retryAfter from a Retry-After
+// header if positive.
+func (l *crawlLimiter) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := l.policy.BaseBackoff * time.Duration(int64(1)<<uint(attempt-1))
+	if d > l.policy.MaxBackoff {
+		d = l.policy.MaxBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)+1))
+}
+
+// doRequest sends req, waiting on the crawl policy's rate limiter and
+// concurrency cap first, and retries 429 and 5xx responses with exponential
+// backoff (honoring a Retry-After header when the server sent one) up to
+// policy.MaxAttempts times in total. Any other response, successful or not,
+// is returned to the caller on the first try. req's body, if any, must be
+// safely re-sendable, since a retried attempt reuses it.
+func (c *Crawler) doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		atomic.AddInt64(&c.numRateLimited, 1)
+		return nil, err
+	}
+	defer release()
+
+	var lastErr error
+	var retryAfter time.Duration
+	for attempt := 1; attempt <= c.limiter.policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			atomic.AddInt64(&c.numRetried, 1)
+			select {
+			case <-time.After(c.limiter.backoff(attempt-1, retryAfter)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			lastErr = fmt.Errorf("HTTP error: %s", resp.Status)
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// parseRetryAfter parses a Retry-After header value, which per RFC 7231 is
+// either a number of seconds or an HTTP-date. It returns 0 (meaning "fall
+// back to the policy's own backoff schedule") if value is empty or neither
+// form parses.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// crawlRobotsURL is where OEIS's robots.txt is fetched from.
+const crawlRobotsURL = "https://oeis.org/robots.txt"
+
+// robotsRules is the subset of a robots.txt Crawler cares about: the
+// Disallow path prefixes that apply to all user agents. An empty value (no
+// rules loaded, or none applied to "*") allows everything, so a robots.txt
+// fetch failure can't itself stop the crawler from running -- robots.txt is
+// advisory, not a hard dependency.
+type robotsRules struct {
+	disallow []string
+}
+
+// allows reports whether path is permitted by r.
+func (r robotsRules) allows(path string) bool {
+	for _, prefix := range r.disallow {
+		if prefix != "" && strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchRobots fetches and parses OEIS's robots.txt. A fetch or parse
+// failure logs a warning and returns empty rules rather than an error.
+func (c *Crawler) fetchRobots(ctx context.Context) robotsRules {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, crawlRobotsURL, nil)
+	if err != nil {
+		return robotsRules{}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to fetch robots.txt, assuming no restrictions: %v", err)
+		return robotsRules{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return robotsRules{}
+	}
+	return parseRobots(resp.Body)
+}
+
+// parseRobots reads the Disallow rules of robots.txt's "*" user-agent
+// group. Groups for any other, more specific user agent are ignored, since
+// OEIS's robots.txt doesn't target loda-api specifically.
+func parseRobots(r io.Reader) robotsRules {
+	var rules robotsRules
+	applies := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(strings.ToLower(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			applies = value == "*"
+		case "disallow":
+			if applies && value != "" {
+				rules.disallow = append(rules.disallow, value)
+			}
+		}
+	}
+	return rules
+}
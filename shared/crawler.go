@@ -2,41 +2,199 @@ package shared
 
 import (
 	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math/big"
+	"math/bits"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/loda-lang/loda-api/util"
 )
 
+// crawlerRecentFetchFile records, per sequence ID, the last time it was
+// fetched, so AddNextId can reject a refresh request for an ID that was
+// just fetched instead of queuing a pointless repeat.
+const crawlerRecentFetchFile = "crawler_recent_fetches.json"
+
+// crawlerHTTPCacheFile records, per sequence ID, the ETag and Last-Modified
+// values OEIS last sent for it, so FetchSeq can send a conditional GET and
+// skip re-parsing a sequence that hasn't changed.
+const crawlerHTTPCacheFile = "crawler_http_cache.json"
+
+// crawlerStateFile persists the round-robin walk's cursor, step size and
+// visited set, so Init can resume the in-progress cycle after a restart
+// instead of picking a new random currentId/stepSize and re-fetching ids
+// already covered this cycle.
+const crawlerStateFile = "crawler_state.json"
+
+// crawlStateCheckpointInterval is how many FetchNext calls pass between
+// automatic Checkpoint calls, bounding how much walk progress a crash
+// between checkpoints can lose.
+const crawlStateCheckpointInterval = 50
+
+// crawlState is the on-disk shape of the round-robin walk's resumable
+// state. Visited is the base64-encoded big-endian bytes of a big.Int
+// bitmap with bit i set once id i has been visited this cycle.
+type crawlState struct {
+	MaxId      int    `json:"maxId"`
+	CurrentId  int    `json:"currentId"`
+	StepSize   int    `json:"stepSize"`
+	NumFetched int    `json:"numFetched"`
+	Visited    string `json:"visited,omitempty"`
+}
+
+// httpCacheEntry is the conditional-GET validators recorded for one
+// sequence ID.
+type httpCacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// Crawler walks the OEIS id space with a random coprime step, so repeated
+// runs don't all hammer the same sequences in the same order. User-triggered
+// refreshes (AddNextId) and the background missing-ids sweep (SetNextIds) are
+// queued separately -- highQueue is drained by FetchNext before normalQueue,
+// which in turn is drained before the round-robin sweep -- and both queues
+// are backed by a RefreshQueue so pending IDs survive a restart. FetchNext
+// pops its id off the in-memory slice right away, but only acks it off the
+// persisted queue after the fetch attempt finishes, so a crash mid-fetch
+// replays it on restart instead of losing it. The round-robin sweep fetches
+// sweepBatchSize ids at a time through FetchBatch's JSON endpoint instead of
+// one fmt=text request per id; sweepBuf holds the results FetchNext hasn't
+// handed out yet. Every outgoing request goes through doRequest, which
+// enforces limiter's rate limit and concurrency cap and retries 429/5xx
+// responses with backoff; robots is fetched once per Init and consulted
+// before each request, and httpCache lets FetchSeq send conditional GETs so
+// an unchanged sequence costs a 304 instead of a full re-parse. The
+// round-robin walk's cursor, step size and visited set are checkpointed to
+// disk (see Checkpoint) so Init can resume the in-progress cycle after a
+// restart instead of re-randomizing and re-visiting ids already covered.
 type Crawler struct {
 	maxId      int
 	currentId  int
 	stepSize   int
 	numFetched int
-	nextIds    []int
+	sweepBuf   []prefetchedSeq
+
+	highQueue   *RefreshQueue
+	normalQueue *RefreshQueue
+	highIds     []int
+	normalIds   []int
+
+	recentFetchTTL time.Duration
+	recentFetchMu  sync.Mutex
+	recentFetches  map[int]time.Time
+	recentFetchDir string
+	drainTimeout   time.Duration
+	fetchDeadline  time.Duration
+
+	limiter      *crawlLimiter
+	robots       robotsRules
+	httpCacheMu  sync.Mutex
+	httpCache    map[int]httpCacheEntry
+	httpCacheDir string
+
+	stateMu      sync.Mutex
+	stateDir     string
+	visited      *big.Int
+	visitedCount int
+
+	numSkipped304  int64
+	numRetried     int64
+	numRateLimited int64
+
 	rand       *rand.Rand
 	httpClient *http.Client
 	mutex      sync.Mutex
 }
 
-func NewCrawler(httpClient *http.Client) *Crawler {
-	return &Crawler{
-		httpClient: httpClient,
-		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+// NewCrawler creates a Crawler backed by the refresh queues and recent-fetch
+// log under dataDir. recentFetchTTL is how long a fetched ID is remembered
+// for dedup purposes (see AddNextId); drainTimeout bounds how long replaying
+// a persisted queue at startup may take (see cmd.Setup.RefreshDrainTimeout);
+// fetchDeadline bounds how long a single OEIS fetch may run before its
+// request context is cancelled (see cmd.Setup.CrawlerFetchTimeout); policy
+// bounds how hard the crawler is allowed to hit OEIS (see CrawlPolicy), or
+// falls back to DefaultCrawlPolicy if nil.
+func NewCrawler(httpClient *http.Client, dataDir string, recentFetchTTL, drainTimeout, fetchDeadline time.Duration, policy *CrawlPolicy) *Crawler {
+	c := &Crawler{
+		highQueue:      NewRefreshQueue(filepath.Join(dataDir, "crawl_queue_high")),
+		normalQueue:    NewRefreshQueue(filepath.Join(dataDir, "crawl_queue_normal")),
+		recentFetchTTL: recentFetchTTL,
+		recentFetchDir: dataDir,
+		drainTimeout:   drainTimeout,
+		fetchDeadline:  fetchDeadline,
+		limiter:        newCrawlLimiter(policy),
+		httpCacheDir:   dataDir,
+		stateDir:       dataDir,
+		httpClient:     httpClient,
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	c.recentFetches = c.loadRecentFetches()
+	c.httpCache = c.loadHTTPCache()
+	c.visited = big.NewInt(0)
+	if state := c.loadCrawlState(); state.MaxId > 0 {
+		c.maxId = state.MaxId
+		c.currentId = state.CurrentId
+		c.stepSize = state.StepSize
+		c.numFetched = state.NumFetched
+		if raw, err := base64.StdEncoding.DecodeString(state.Visited); err == nil {
+			c.visited.SetBytes(raw)
+			c.visitedCount = popcount(c.visited)
+		} else if state.Visited != "" {
+			log.Printf("Failed to decode persisted crawl visited set, starting fresh: %v", err)
+		}
+		log.Printf("Resumed crawl state: max id %d, current id %d, step size %d, %d/%d ids visited this cycle", c.maxId, c.currentId, c.stepSize, c.visitedCount, c.maxId)
+	}
+	ctx := context.Background()
+	if ids, err := c.drainAll(ctx, c.highQueue); err != nil {
+		log.Printf("Failed to replay persisted high-priority crawl queue: %v", err)
+	} else {
+		c.highIds = ids
+		c.persistHighQueue(ctx)
+	}
+	if ids, err := c.drainAll(ctx, c.normalQueue); err != nil {
+		log.Printf("Failed to replay persisted normal-priority crawl queue: %v", err)
+	} else {
+		c.normalIds = ids
+		c.persistNormalQueue(ctx)
 	}
+	return c
 }
 
-func (c *Crawler) Init() error {
+// Init (re-)initializes the round-robin walk. If it's called with maxId
+// unchanged from a prior Init (whether restored from disk by NewCrawler or
+// set by an earlier Init in this process) and the current cycle hasn't
+// covered every id yet, it resumes that cycle instead of re-randomizing
+// currentId/stepSize and resetting the visited set, so a periodic
+// re-initialization or a process restart can't cause ids already fetched
+// this cycle to be re-visited before the rest of the space is covered.
+func (c *Crawler) Init(ctx context.Context) error {
 	log.Print("Initializing crawler")
-	maxId, err := c.findMaxId()
+	c.robots = c.fetchRobots(ctx)
+	maxId, err := c.findMaxId(ctx)
 	if err != nil {
 		return err
 	}
 	if maxId == 0 {
 		return fmt.Errorf("no sequences found")
 	}
+	if c.maxId == maxId && c.currentId != 0 && c.visitedCount < maxId {
+		log.Printf("Resuming crawl cycle at id %d of %d (step size %d, %d visited)", c.currentId, c.maxId, c.stepSize, c.visitedCount)
+		c.Checkpoint(ctx)
+		return nil
+	}
 	c.maxId = maxId
 	c.currentId = c.rand.Intn(maxId) + 1
 	for i := 0; i < maxId; i++ {
@@ -45,23 +203,58 @@ func (c *Crawler) Init() error {
 			break
 		}
 	}
-	log.Printf("Found %d sequences", c.maxId)
+	c.stateMu.Lock()
+	c.visited = big.NewInt(0)
+	c.visitedCount = 0
+	c.stateMu.Unlock()
+	log.Printf("Found %d sequences, starting new crawl cycle (step size %d)", c.maxId, c.stepSize)
+	c.Checkpoint(ctx)
 	return nil
 }
 
-func (c *Crawler) FetchSeq(id int, silent bool) ([]Field, int, error) {
+// FetchSeq fetches a single sequence from OEIS. The underlying HTTP request
+// is bounded to fetchDeadline, derived from ctx, so a stalled upstream
+// response can't wedge the caller indefinitely. It goes through doRequest,
+// so it respects the crawl policy's rate limit, concurrency cap and retry
+// schedule, and sends a conditional GET using the ETag/Last-Modified
+// recorded from a previous fetch of id, if any; a 304 response is reported
+// as a successful, field-less fetch rather than an error.
+func (c *Crawler) FetchSeq(ctx context.Context, id int, silent bool) ([]Field, int, error) {
+	if !c.robots.allows("/search") {
+		return nil, 0, fmt.Errorf("disallowed by robots.txt: /search")
+	}
 	if !silent {
 		log.Printf("Fetching A%06d", id)
 	}
+	fetchCtx, cancel := context.WithTimeout(ctx, c.fetchDeadline)
+	defer cancel()
 	url := fmt.Sprintf("https://oeis.org/search?q=id:A%06d&fmt=text", id)
-	resp, err := c.httpClient.Get(url)
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if etag, lastModified := c.conditionalHeaders(id); etag != "" || lastModified != "" {
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	resp, err := c.doRequest(fetchCtx, req)
 	if err != nil {
 		return nil, 0, err
 	}
+	defer resp.Body.Close()
 	status := resp.StatusCode
+	if status == http.StatusNotModified {
+		atomic.AddInt64(&c.numSkipped304, 1)
+		return nil, status, nil
+	}
 	if status >= 400 {
 		return nil, status, fmt.Errorf("HTTP error: %s", resp.Status)
 	}
+	c.recordHTTPCache(id, resp)
 	scanner := bufio.NewScanner(resp.Body)
 	var fields []Field
 	for scanner.Scan() {
@@ -80,40 +273,352 @@ func (c *Crawler) FetchSeq(id int, silent bool) ([]Field, int, error) {
 	return fields, status, nil
 }
 
-func (c *Crawler) FetchNext() ([]Field, int, error) {
+// oeisJSONPageSize is the number of results OEIS's fmt=json search endpoint
+// returns per page; fetchJSONQuery walks its start= cursor across as many
+// pages as needed to collect every requested id in one logical call.
+const oeisJSONPageSize = 10
+
+// oeisJSONResponse is the shape of OEIS's fmt=json search response.
+type oeisJSONResponse struct {
+	Count   int              `json:"count"`
+	Results []oeisJSONRecord `json:"results"`
+}
+
+// oeisJSONRecord is a single sequence as returned by OEIS's fmt=json search
+// endpoint.
+type oeisJSONRecord struct {
+	Number  int      `json:"number"`
+	Name    string   `json:"name"`
+	Data    string   `json:"data"`
+	Keyword string   `json:"keyword"`
+	Offset  string   `json:"offset"`
+	Author  string   `json:"author"`
+	Formula []string `json:"formula"`
+	Xref    []string `json:"xref"`
+	Comment []string `json:"comment"`
+	Program []string `json:"program"`
+}
+
+// idQuery builds an OEIS search query matching any of ids, e.g.
+// "id:A000001 OR id:A000002", so FetchBatch and the round-robin sweep can
+// fetch an arbitrary set of ids in a single fmt=json round trip.
+func idQuery(ids []int) string {
+	terms := make([]string, len(ids))
+	for i, id := range ids {
+		terms[i] = fmt.Sprintf("id:A%06d", id)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// fetchJSONQuery runs query against OEIS's fmt=json search endpoint, paging
+// through start= until it has collected want results (or OEIS runs out of
+// matches), so a caller asking for many ids still gets them all in one
+// logical call even though OEIS only returns oeisJSONPageSize results per
+// HTTP request. want <= 0 means "fetch everything the query matches".
+func (c *Crawler) fetchJSONQuery(ctx context.Context, query string, want int) ([]oeisJSONRecord, error) {
+	var records []oeisJSONRecord
+	for start := 0; want <= 0 || len(records) < want; start += oeisJSONPageSize {
+		page, err := c.fetchJSONPage(ctx, query, start)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page...)
+		if len(page) < oeisJSONPageSize {
+			break // last page
+		}
+	}
+	if want > 0 && len(records) > want {
+		records = records[:want]
+	}
+	return records, nil
+}
+
+// fetchJSONPage fetches a single page of query's results starting at start.
+func (c *Crawler) fetchJSONPage(ctx context.Context, query string, start int) ([]oeisJSONRecord, error) {
+	if !c.robots.allows("/search") {
+		return nil, fmt.Errorf("disallowed by robots.txt: /search")
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, c.fetchDeadline)
+	defer cancel()
+	requestUrl := fmt.Sprintf("https://oeis.org/search?q=%s&fmt=json&start=%d", url.QueryEscape(query), start)
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doRequest(fetchCtx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	var page oeisJSONResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode OEIS response: %w", err)
+	}
+	return page.Results, nil
+}
+
+// recordToSequence converts a decoded OEIS JSON record into the same
+// Sequence schema SequenceIndex.Load builds from the local
+// stripped/names/keywords files, so downstream code doesn't care whether a
+// Sequence came from one of those files or a live crawl. Keywords OEIS has
+// that this build doesn't recognize are dropped rather than failing the
+// whole record, the same way LoadKeywordsFile handles them.
+func recordToSequence(r oeisJSONRecord) (Sequence, error) {
+	id, err := util.NewUID('A', int64(r.Number))
+	if err != nil {
+		return Sequence{}, fmt.Errorf("invalid sequence id: %w", err)
+	}
+	var keywords []string
+	for _, k := range strings.Split(r.Keyword, ",") {
+		k = strings.TrimSpace(k)
+		if IsKeyword(k) {
+			keywords = append(keywords, k)
+		}
+	}
+	encoded, err := EncodeKeywords(keywords)
+	if err != nil {
+		return Sequence{}, fmt.Errorf("invalid keywords: %w", err)
+	}
+	var authors []*Author
+	if r.Author != "" {
+		authors = append(authors, &Author{Name: r.Author})
+	}
+	return Sequence{
+		Id:       id,
+		Name:     r.Name,
+		Keywords: encoded,
+		Terms:    r.Data,
+		Authors:  authors,
+	}, nil
+}
+
+// recordToFields converts a decoded OEIS JSON record into the %-key Field
+// lines the List/ListIndex pipeline expects (see ListNames), one per list
+// entry, so the JSON-based sweep keeps feeding the same per-key lists
+// FetchSeq's fmt=text line parser used to.
+func recordToFields(r oeisJSONRecord) []Field {
+	var fields []Field
+	if r.Author != "" {
+		fields = append(fields, Field{Key: "A", SeqId: r.Number, Content: r.Author})
+	}
+	if r.Offset != "" {
+		fields = append(fields, Field{Key: "O", SeqId: r.Number, Content: r.Offset})
+	}
+	if r.Keyword != "" {
+		fields = append(fields, Field{Key: "K", SeqId: r.Number, Content: r.Keyword})
+	}
+	for _, f := range r.Formula {
+		fields = append(fields, Field{Key: "F", SeqId: r.Number, Content: f})
+	}
+	for _, comment := range r.Comment {
+		fields = append(fields, Field{Key: "C", SeqId: r.Number, Content: comment})
+	}
+	for _, program := range r.Program {
+		fields = append(fields, Field{Key: "o", SeqId: r.Number, Content: program})
+	}
+	return fields
+}
+
+// fetchSequences runs query against OEIS's fmt=json endpoint and converts
+// every matching record into a Sequence, skipping (and logging) any record
+// whose id or keywords turn out to be malformed rather than failing the
+// whole call.
+func (c *Crawler) fetchSequences(ctx context.Context, query string, want int) ([]Sequence, error) {
+	records, err := c.fetchJSONQuery(ctx, query, want)
+	if err != nil {
+		return nil, err
+	}
+	seqs := make([]Sequence, 0, len(records))
+	for _, r := range records {
+		seq, err := recordToSequence(r)
+		if err != nil {
+			log.Printf("Failed to parse OEIS record A%06d: %v", r.Number, err)
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
+
+// FetchBatch fetches every id in ids from OEIS's fmt=json search endpoint in
+// a single logical round trip (an OR'd id query, paginated via start= if
+// OEIS splits the results across more than one page), instead of the
+// fmt=text FetchSeq needing one request per id. An id OEIS has no match for
+// is simply absent from the result, not an error.
+func (c *Crawler) FetchBatch(ctx context.Context, ids []int) ([]Sequence, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return c.fetchSequences(ctx, idQuery(ids), len(ids))
+}
+
+// FetchRange fetches the count sequences starting at id start (i.e.
+// [start, start+count)) from OEIS's fmt=json search endpoint, using its
+// start= cursor to page through all of them in one logical call. It's meant
+// for bulk corpus sync over a known-contiguous range of ids, where
+// FetchBatch's arbitrary id list isn't as efficient a query.
+func (c *Crawler) FetchRange(ctx context.Context, start, count int) ([]Sequence, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	query := fmt.Sprintf("id:A%06d-A%06d", start, start+count-1)
+	return c.fetchSequences(ctx, query, count)
+}
+
+// FetchNext fetches the next sequence due for a crawl, or returns promptly
+// with ctx.Err() if ctx is cancelled before or during the fetch. id is the
+// sequence ID that was fetched (or would have been, on error), so a caller
+// tracking individual refreshes (see shared/operations) can tell which one
+// just completed without having to parse it back out of fields.
+func (c *Crawler) FetchNext(ctx context.Context) (fields []Field, id int, status int, err error) {
 	c.mutex.Lock()
-	// Fetch next sequences first
-	if len(c.nextIds) > 0 {
-		id := c.nextIds[0]
-		c.nextIds = c.nextIds[1:]
+	// Fetch user-triggered, high-priority refreshes first
+	if len(c.highIds) > 0 {
+		id := c.highIds[0]
+		c.highIds = c.highIds[1:]
+		c.mutex.Unlock()
+		c.numFetched++
+		c.markFetched(id)
+		fields, status, err := c.FetchSeq(ctx, id, false)
+		c.ackHighId(ctx, id)
+		c.checkpointIfDue(ctx)
+		return fields, id, status, err
+	}
+	// Then the background missing-ids sweep
+	if len(c.normalIds) > 0 {
+		id := c.normalIds[0]
+		c.normalIds = c.normalIds[1:]
 		c.mutex.Unlock()
 		c.numFetched++
-		return c.FetchSeq(id, false)
+		c.markFetched(id)
+		fields, status, err := c.FetchSeq(ctx, id, false)
+		c.ackNormalId(ctx, id)
+		c.checkpointIfDue(ctx)
+		return fields, id, status, err
 	}
 	c.mutex.Unlock()
-	// Fetch the next sequence
-	if c.maxId == 0 || c.numFetched == c.maxId {
-		err := c.Init()
-		if err != nil {
-			return nil, 0, err
+	// Round-robin sweep, served out of a prefetched batch so OEIS sees one
+	// JSON round trip per sweepBatchSize ids instead of one fmt=text request
+	// per id.
+	if len(c.sweepBuf) == 0 {
+		if err := c.fillSweepBuf(ctx); err != nil {
+			return nil, 0, 0, err
 		}
-	} else {
-		c.currentId = ((c.currentId + c.stepSize) % c.maxId) + 1
 	}
+	next := c.sweepBuf[0]
+	c.sweepBuf = c.sweepBuf[1:]
 	c.numFetched++
-	return c.FetchSeq(c.currentId, false)
+	c.markFetched(next.id)
+	c.checkpointIfDue(ctx)
+	return next.fields, next.id, next.status, next.err
+}
+
+// prefetchedSeq is one id's worth of a FetchNext sweep batch: either the
+// Fields recordToFields parsed out of its OEIS JSON record, or the status
+// and error FetchNext would have returned had OEIS had no match for it.
+type prefetchedSeq struct {
+	id     int
+	fields []Field
+	status int
+	err    error
 }
 
-// AddNextId adds an ID to the crawler's next IDs queue in a thread-safe manner.
-// Returns false if the queue has reached the maximum size, true otherwise.
-func (c *Crawler) AddNextId(id int, maxQueueSize int) bool {
+// sweepBatchSize is how many round-robin sweep ids fillSweepBuf fetches per
+// HTTP round trip.
+const sweepBatchSize = 20
+
+// fillSweepBuf advances the round-robin cursor sweepBatchSize steps and
+// fetches all of them in one JSON round trip, storing the results in
+// c.sweepBuf for FetchNext to drain one at a time. It leaves c.sweepBuf
+// empty and returns an error if the batch fetch itself fails; FetchNext will
+// just retry the fill on its next call.
+func (c *Crawler) fillSweepBuf(ctx context.Context) error {
+	ids := make([]int, 0, sweepBatchSize)
+	for i := 0; i < sweepBatchSize; i++ {
+		if c.maxId == 0 || c.visitedCount >= c.maxId {
+			if err := c.Init(ctx); err != nil {
+				return err
+			}
+		} else {
+			c.currentId = ((c.currentId + c.stepSize) % c.maxId) + 1
+		}
+		c.markVisited(c.currentId)
+		ids = append(ids, c.currentId)
+	}
+	records, err := c.fetchJSONQuery(ctx, idQuery(ids), len(ids))
+	if err != nil {
+		return err
+	}
+	byId := make(map[int]oeisJSONRecord, len(records))
+	for _, r := range records {
+		byId[r.Number] = r
+	}
+	buf := make([]prefetchedSeq, 0, len(ids))
+	for _, id := range ids {
+		if r, ok := byId[id]; ok {
+			buf = append(buf, prefetchedSeq{id: id, fields: recordToFields(r), status: http.StatusOK})
+		} else {
+			buf = append(buf, prefetchedSeq{id: id, status: http.StatusNotFound, err: fmt.Errorf("no fields found")})
+		}
+	}
+	c.sweepBuf = buf
+	return nil
+}
+
+// AddNextId queues id as a high-priority refresh, in front of the
+// round-robin sweep and the background missing-ids queue, and returns its
+// 1-based position in the high-priority queue. It rejects id, with an error
+// explaining why, if the high-priority queue is already at maxQueueSize, id
+// is already queued (in either priority band), or id was fetched within
+// recentFetchTTL.
+func (c *Crawler) AddNextId(ctx context.Context, id int, maxQueueSize int) (int, error) {
+	if recently, since := c.recentlyFetched(id); recently {
+		return 0, fmt.Errorf("sequence was already fetched %s ago", since.Round(time.Second))
+	}
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	if len(c.nextIds) >= maxQueueSize {
-		return false
+	for _, queued := range c.highIds {
+		if queued == id {
+			return 0, fmt.Errorf("sequence is already queued")
+		}
 	}
-	c.nextIds = append(c.nextIds, id)
-	return true
+	for _, queued := range c.normalIds {
+		if queued == id {
+			return 0, fmt.Errorf("sequence is already queued")
+		}
+	}
+	if len(c.highIds) >= maxQueueSize {
+		return 0, fmt.Errorf("crawler queue is full, please retry later")
+	}
+	uid, err := util.NewUID('A', int64(id))
+	if err != nil {
+		return 0, fmt.Errorf("invalid sequence id: %w", err)
+	}
+	if err := c.highQueue.Enqueue(uid); err != nil {
+		log.Printf("Failed to persist high-priority crawl queue entry %d: %v", id, err)
+	}
+	c.highIds = append(c.highIds, id)
+	return len(c.highIds), nil
+}
+
+// RemoveHighId removes id from the high-priority queue if it's still
+// pending there, reporting whether it was found. It's how a cancelled
+// operation (see shared/operations) keeps the crawler from fetching a
+// refresh nobody wants anymore.
+func (c *Crawler) RemoveHighId(ctx context.Context, id int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, queued := range c.highIds {
+		if queued == id {
+			c.highIds = append(c.highIds[:i], c.highIds[i+1:]...)
+			c.ackHighId(ctx, id)
+			return true
+		}
+	}
+	return false
 }
 
 // NumFetched returns the number of sequences fetched
@@ -126,20 +631,327 @@ func (c *Crawler) MaxId() int {
 	return c.maxId
 }
 
-// SetNextIds sets the next IDs to fetch
-func (c *Crawler) SetNextIds(ids []int) {
+// NumSkipped304 returns how many fetches were skipped because OEIS reported
+// the sequence unchanged (HTTP 304) since it was last fetched.
+func (c *Crawler) NumSkipped304() int {
+	return int(atomic.LoadInt64(&c.numSkipped304))
+}
+
+// NumRetried returns how many requests were retried after a 429 or 5xx
+// response.
+func (c *Crawler) NumRetried() int {
+	return int(atomic.LoadInt64(&c.numRetried))
+}
+
+// NumRateLimited returns how many requests failed to acquire a rate-limiter
+// token or concurrency slot (e.g. because ctx was canceled while waiting).
+func (c *Crawler) NumRateLimited() int {
+	return int(atomic.LoadInt64(&c.numRateLimited))
+}
+
+// SetNextIds replaces the normal-priority (background missing-ids sweep)
+// queue with ids.
+func (c *Crawler) SetNextIds(ctx context.Context, ids []int) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.nextIds = ids
+	c.normalIds = ids
+	c.persistNormalQueue(ctx)
+}
+
+// QueueDepth reports how many IDs are currently pending in each priority
+// band, for the /v2/sequences/queue observability endpoint.
+type QueueDepth struct {
+	High   int `json:"high"`
+	Normal int `json:"normal"`
+}
+
+// QueueDepth returns the current depth of the high- and normal-priority
+// queues.
+func (c *Crawler) QueueDepth() QueueDepth {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return QueueDepth{High: len(c.highIds), Normal: len(c.normalIds)}
+}
+
+// persistHighQueue rewrites the on-disk high-priority queue to match
+// c.highIds, so a crash before the next call doesn't lose or replay IDs that
+// were already popped off the in-memory queue. Caller must hold mutex.
+func (c *Crawler) persistHighQueue(ctx context.Context) {
+	c.persistRefreshQueue(ctx, c.highQueue, c.highIds)
+}
+
+// persistNormalQueue is persistHighQueue's counterpart for c.normalIds.
+// Caller must hold mutex.
+func (c *Crawler) persistNormalQueue(ctx context.Context) {
+	c.persistRefreshQueue(ctx, c.normalQueue, c.normalIds)
+}
+
+// ackHighId acks id off the persisted high-priority queue once FetchNext
+// has actually finished its fetch attempt, so a crash between popping id
+// off c.highIds and completing the fetch leaves id on disk to be replayed
+// on restart instead of losing it. It doesn't need c.mutex: c.highQueue has
+// its own.
+func (c *Crawler) ackHighId(ctx context.Context, id int) {
+	if err := c.highQueue.Ack(ctx, []int{id}); err != nil {
+		log.Printf("Failed to ack high-priority crawl queue entry %d: %v", id, err)
+	}
+}
+
+// ackNormalId is ackHighId's counterpart for c.normalQueue.
+func (c *Crawler) ackNormalId(ctx context.Context, id int) {
+	if err := c.normalQueue.Ack(ctx, []int{id}); err != nil {
+		log.Printf("Failed to ack normal-priority crawl queue entry %d: %v", id, err)
+	}
+}
+
+// drainAll calls rq.DequeueAll with ctx further bounded by c.drainTimeout (if
+// set), for a single RefreshQueue.DequeueAll call.
+func (c *Crawler) drainAll(ctx context.Context, rq *RefreshQueue) ([]int, error) {
+	if c.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.drainTimeout)
+		defer cancel()
+	}
+	return rq.DequeueAll(ctx)
+}
+
+// persistRefreshQueue drains rq and re-enqueues ids, so rq's on-disk state
+// matches ids exactly.
+func (c *Crawler) persistRefreshQueue(ctx context.Context, rq *RefreshQueue, ids []int) {
+	if _, err := c.drainAll(ctx, rq); err != nil {
+		log.Printf("Failed to clear crawl queue before persisting: %v", err)
+		return
+	}
+	for _, id := range ids {
+		uid, err := util.NewUID('A', int64(id))
+		if err != nil {
+			log.Printf("Failed to persist crawl queue entry %d: %v", id, err)
+			continue
+		}
+		if err := rq.Enqueue(uid); err != nil {
+			log.Printf("Failed to persist crawl queue entry %d: %v", id, err)
+		}
+	}
+}
+
+// markVisited records id as covered by the current round-robin walk cycle,
+// so Init knows not to start a fresh cycle until every id has been visited.
+func (c *Crawler) markVisited(id int) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.visited.Bit(id) == 0 {
+		c.visited.SetBit(c.visited, id, 1)
+		c.visitedCount++
+	}
+}
+
+// checkpointIfDue calls Checkpoint every crawlStateCheckpointInterval
+// fetches, bounding how much walk progress a crash between checkpoints can
+// lose.
+func (c *Crawler) checkpointIfDue(ctx context.Context) {
+	if c.numFetched%crawlStateCheckpointInterval == 0 {
+		c.Checkpoint(ctx)
+	}
+}
+
+// Checkpoint atomically persists the round-robin walk's cursor, step size
+// and visited set to crawlerStateFile, so NewCrawler can resume the
+// in-progress cycle instead of losing it to a restart. FetchNext calls it
+// automatically every crawlStateCheckpointInterval fetches; callers should
+// also call it once on shutdown to capture any progress since the last
+// automatic checkpoint.
+func (c *Crawler) Checkpoint(ctx context.Context) {
+	c.stateMu.Lock()
+	state := crawlState{
+		MaxId:      c.maxId,
+		CurrentId:  c.currentId,
+		StepSize:   c.stepSize,
+		NumFetched: c.numFetched,
+	}
+	if c.visited != nil {
+		state.Visited = base64.StdEncoding.EncodeToString(c.visited.Bytes())
+	}
+	c.stateMu.Unlock()
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Failed to marshal crawl state: %v", err)
+		return
+	}
+	path := c.crawlStatePath()
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Failed to write crawl state: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		log.Printf("Failed to rename crawl state: %v", err)
+	}
+}
+
+func (c *Crawler) crawlStatePath() string {
+	return filepath.Join(c.stateDir, crawlerStateFile)
+}
+
+// loadCrawlState reads a persisted crawl state from disk, tolerating a
+// missing or corrupt file by returning the zero value (no prior cycle).
+func (c *Crawler) loadCrawlState() crawlState {
+	var state crawlState
+	data, err := os.ReadFile(c.crawlStatePath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Failed to parse crawl state, starting fresh: %v", err)
+		return crawlState{}
+	}
+	return state
+}
+
+// popcount counts the set bits in n, for restoring visitedCount from a
+// persisted bitmap without re-walking every id that produced it.
+func popcount(n *big.Int) int {
+	count := 0
+	for _, w := range n.Bits() {
+		count += bits.OnesCount(uint(w))
+	}
+	return count
+}
+
+// markFetched records id as fetched just now, for recentlyFetched's TTL
+// dedup check, and prunes expired entries.
+func (c *Crawler) markFetched(id int) {
+	c.recentFetchMu.Lock()
+	defer c.recentFetchMu.Unlock()
+	now := time.Now()
+	c.recentFetches[id] = now
+	for fetchedId, at := range c.recentFetches {
+		if now.Sub(at) > c.recentFetchTTL {
+			delete(c.recentFetches, fetchedId)
+		}
+	}
+	c.saveRecentFetches()
+}
+
+// recentlyFetched reports whether id was fetched within recentFetchTTL, and
+// how long ago.
+func (c *Crawler) recentlyFetched(id int) (bool, time.Duration) {
+	if c.recentFetchTTL <= 0 {
+		return false, 0
+	}
+	c.recentFetchMu.Lock()
+	defer c.recentFetchMu.Unlock()
+	at, ok := c.recentFetches[id]
+	if !ok {
+		return false, 0
+	}
+	since := time.Since(at)
+	return since <= c.recentFetchTTL, since
+}
+
+func (c *Crawler) recentFetchPath() string {
+	return filepath.Join(c.recentFetchDir, crawlerRecentFetchFile)
+}
+
+// loadRecentFetches reads the recent-fetch log from disk, tolerating a
+// missing or corrupt file by starting from an empty log.
+func (c *Crawler) loadRecentFetches() map[int]time.Time {
+	fetches := map[int]time.Time{}
+	data, err := os.ReadFile(c.recentFetchPath())
+	if err != nil {
+		return fetches
+	}
+	if err := json.Unmarshal(data, &fetches); err != nil {
+		log.Printf("Failed to parse recent-fetch log, starting fresh: %v", err)
+		return map[int]time.Time{}
+	}
+	return fetches
+}
+
+// saveRecentFetches writes the recent-fetch log to disk via a temp file and
+// atomic rename. Caller must hold recentFetchMu.
+func (c *Crawler) saveRecentFetches() {
+	data, err := json.Marshal(c.recentFetches)
+	if err != nil {
+		log.Printf("Failed to marshal recent-fetch log: %v", err)
+		return
+	}
+	tmpPath := c.recentFetchPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Failed to write recent-fetch log: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, c.recentFetchPath()); err != nil {
+		log.Printf("Failed to rename recent-fetch log: %v", err)
+	}
+}
+
+func (c *Crawler) httpCachePath() string {
+	return filepath.Join(c.httpCacheDir, crawlerHTTPCacheFile)
+}
+
+// loadHTTPCache reads the per-id ETag/Last-Modified cache from disk,
+// tolerating a missing or corrupt file by starting from an empty cache.
+func (c *Crawler) loadHTTPCache() map[int]httpCacheEntry {
+	cache := map[int]httpCacheEntry{}
+	data, err := os.ReadFile(c.httpCachePath())
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Printf("Failed to parse HTTP cache, starting fresh: %v", err)
+		return map[int]httpCacheEntry{}
+	}
+	return cache
+}
+
+// saveHTTPCache writes the HTTP cache to disk via a temp file and atomic
+// rename. Caller must hold httpCacheMu.
+func (c *Crawler) saveHTTPCache() {
+	data, err := json.Marshal(c.httpCache)
+	if err != nil {
+		log.Printf("Failed to marshal HTTP cache: %v", err)
+		return
+	}
+	tmpPath := c.httpCachePath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Failed to write HTTP cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, c.httpCachePath()); err != nil {
+		log.Printf("Failed to rename HTTP cache: %v", err)
+	}
+}
+
+// conditionalHeaders returns the ETag/Last-Modified values last recorded for
+// id, if any, for use as If-None-Match/If-Modified-Since request headers.
+func (c *Crawler) conditionalHeaders(id int) (etag, lastModified string) {
+	c.httpCacheMu.Lock()
+	defer c.httpCacheMu.Unlock()
+	entry := c.httpCache[id]
+	return entry.ETag, entry.LastModified
+}
+
+// recordHTTPCache stores resp's ETag/Last-Modified headers for id, so the
+// next fetch of id can send a conditional GET.
+func (c *Crawler) recordHTTPCache(id int, resp *http.Response) {
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+	c.httpCacheMu.Lock()
+	defer c.httpCacheMu.Unlock()
+	c.httpCache[id] = httpCacheEntry{ETag: etag, LastModified: lastModified}
+	c.saveHTTPCache()
 }
 
-func (c *Crawler) findMaxId() (int, error) {
+func (c *Crawler) findMaxId(ctx context.Context) (int, error) {
 	l := 0
 	h := 1000000
 	var lastError error
 	for l < h {
 		m := (l + h) / 2
-		_, _, lastError := c.FetchSeq(m, true)
+		_, _, lastError := c.FetchSeq(ctx, m, true)
 		if lastError != nil {
 			h = m
 		} else {
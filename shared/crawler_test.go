@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseField(t *testing.T) {
+	f, err := ParseField("%N A000042 Unary representation of natural numbers.")
+	assert.NoError(t, err)
+	assert.Equal(t, Field{Key: "N", SeqId: 42, Content: "Unary representation of natural numbers."}, f)
+}
+
+func TestParseField_Invalid(t *testing.T) {
+	_, err := ParseField("not a field line")
+	assert.Error(t, err)
+}
+
+func TestIdQuery(t *testing.T) {
+	got := idQuery([]int{1, 45, 142})
+	want := "id:A000001 OR id:A000045 OR id:A000142"
+	assert.Equal(t, want, got)
+}
+
+func TestRecordToSequence(t *testing.T) {
+	r := oeisJSONRecord{
+		Number:  45,
+		Name:    "Fibonacci numbers.",
+		Data:    "0,1,1,2,3,5,8,13",
+		Keyword: "nonn,core,easy,made-up-keyword",
+		Author:  "_N. J. A. Sloane_, Jun 28 1995",
+	}
+	seq, err := recordToSequence(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "A000045", seq.Id.String())
+	assert.Equal(t, "Fibonacci numbers.", seq.Name)
+	assert.Equal(t, "0,1,1,2,3,5,8,13", seq.Terms)
+	assert.True(t, HasKeyword(seq.Keywords, "nonn"))
+	assert.True(t, HasKeyword(seq.Keywords, "core"))
+	assert.False(t, HasKeyword(seq.Keywords, "made-up-keyword"))
+	if assert.Equal(t, 1, len(seq.Authors)) {
+		assert.Equal(t, "_N. J. A. Sloane_, Jun 28 1995", seq.Authors[0].Name)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	robots := strings.NewReader(strings.Join([]string{
+		"User-agent: *",
+		"Disallow: /wiki",
+		"Disallow: /edit",
+		"",
+		"User-agent: SomeOtherBot",
+		"Disallow: /search",
+	}, "\n"))
+	rules := parseRobots(robots)
+	assert.False(t, rules.allows("/wiki/Main_Page"))
+	assert.False(t, rules.allows("/edit"))
+	assert.True(t, rules.allows("/search?q=id:A000045"))
+}
+
+func TestRobotsRules_AllowsEmpty(t *testing.T) {
+	var rules robotsRules
+	assert.True(t, rules.allows("/search"))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("not-a-value"))
+
+	future := time.Now().Add(2 * time.Minute)
+	d := parseRetryAfter(future.UTC().Format(http.TimeFormat))
+	assert.True(t, d > 0 && d <= 2*time.Minute)
+}
+
+func TestPopcount(t *testing.T) {
+	n := big.NewInt(0)
+	assert.Equal(t, 0, popcount(n))
+	n.SetBit(n, 3, 1)
+	n.SetBit(n, 5000, 1)
+	assert.Equal(t, 2, popcount(n))
+}
+
+func TestCrawler_CheckpointAndResume(t *testing.T) {
+	dataDir := t.TempDir()
+	c1 := NewCrawler(http.DefaultClient, dataDir, 0, 0, time.Second, nil)
+	c1.maxId = 100
+	c1.currentId = 7
+	c1.stepSize = 3
+	c1.markVisited(7)
+	c1.markVisited(10)
+	c1.Checkpoint(context.Background())
+
+	c2 := NewCrawler(http.DefaultClient, dataDir, 0, 0, time.Second, nil)
+	assert.Equal(t, 100, c2.maxId)
+	assert.Equal(t, 7, c2.currentId)
+	assert.Equal(t, 3, c2.stepSize)
+	assert.Equal(t, 2, c2.visitedCount)
+	assert.Equal(t, uint(1), c2.visited.Bit(7))
+	assert.Equal(t, uint(1), c2.visited.Bit(10))
+	assert.Equal(t, uint(0), c2.visited.Bit(8))
+}
+
+func TestRecordToFields(t *testing.T) {
+	r := oeisJSONRecord{
+		Number:  45,
+		Keyword: "nonn,core,easy",
+		Offset:  "0,4",
+		Author:  "_N. J. A. Sloane_",
+		Formula: []string{"a(n) = a(n-1) + a(n-2)."},
+		Comment: []string{"A classic sequence."},
+		Program: []string{"(PARI) a(n) = fibonacci(n)"},
+	}
+	fields := recordToFields(r)
+
+	byKey := make(map[string][]Field)
+	for _, f := range fields {
+		byKey[f.Key] = append(byKey[f.Key], f)
+	}
+	assert.Equal(t, "nonn,core,easy", byKey["K"][0].Content)
+	assert.Equal(t, "0,4", byKey["O"][0].Content)
+	assert.Equal(t, "_N. J. A. Sloane_", byKey["A"][0].Content)
+	assert.Equal(t, "a(n) = a(n-1) + a(n-2).", byKey["F"][0].Content)
+	assert.Equal(t, "A classic sequence.", byKey["C"][0].Content)
+	assert.Equal(t, "(PARI) a(n) = fibonacci(n)", byKey["o"][0].Content)
+	for _, f := range fields {
+		assert.Equal(t, 45, f.SeqId)
+	}
+}
@@ -8,12 +8,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/loda-lang/loda-api/util"
+	"golang.org/x/sync/errgroup"
 )
 
 type DataIndex struct {
@@ -24,71 +26,129 @@ type DataIndex struct {
 	Sequences  []Sequence
 	Submitters []*Submitter
 	NumUsages  map[string]int
+	// Concurrency bounds how many goroutines Load uses to parse source
+	// files in parallel. Defaults to runtime.GOMAXPROCS(0).
+	Concurrency int
+	// ProgramIndex is the inverted index over Programs that SearchPrograms
+	// queries. Load builds it once after Programs is populated, whether
+	// that came from a snapshot or a fresh parse; it is not itself part of
+	// the snapshot, since rebuilding it from Programs is cheap.
+	ProgramIndex *ProgramSearchIndex
+	// SequenceIndex is the inverted index over Sequences that
+	// SearchSequences queries for BM25-ranked matches. Built the same way
+	// and for the same reason as ProgramIndex.
+	SequenceIndex *SequenceSearchIndex
+	// ProgramTrigramIndex supports substring search over Programs' name and
+	// submitter name without scanning every entry. Load builds it the same
+	// way as ProgramIndex; a DataIndex constructed without it (as tests do)
+	// makes SearchPrograms fall back to exact-token matching instead.
+	ProgramTrigramIndex TrigramSearcher
+	// SequenceTrigramIndex is the same, over Sequences' name, submitter
+	// name, and author names.
+	SequenceTrigramIndex TrigramSearcher
+	// ProgramsSorted reports whether Programs is sorted by ascending Id, set
+	// once by Load (which always sorts it) or by isProgramsSortedByID for a
+	// DataIndex restored from elsewhere. FindProgramById binary-searches
+	// when this is true, and falls back to a linear scan otherwise, e.g. for
+	// a DataIndex a test constructs directly with Programs in arbitrary
+	// order.
+	ProgramsSorted bool
 }
 
 func NewDataIndex(dataDir string) *DataIndex {
 	oeisDir := filepath.Join(dataDir, "seqs", "oeis")
 	statsDir := filepath.Join(dataDir, "stats")
 	return &DataIndex{
-		DataDir:  dataDir,
-		StatsDir: statsDir,
-		OeisDir:  oeisDir,
+		DataDir:     dataDir,
+		StatsDir:    statsDir,
+		OeisDir:     oeisDir,
+		Concurrency: runtime.GOMAXPROCS(0),
 	}
 }
 
-// Load reads and parses the data files to populate the index.
-func (idx *DataIndex) Load() error {
-	namesPath := filepath.Join(idx.OeisDir, "names")
-	nameMap, err := LoadNamesFile(namesPath)
-	if err != nil {
-		return err
+// concurrency returns idx.Concurrency, falling back to GOMAXPROCS(0) for a
+// DataIndex constructed without NewDataIndex.
+func (idx *DataIndex) concurrency() int {
+	if idx.Concurrency > 0 {
+		return idx.Concurrency
 	}
-	// Extract extra keywords from names
-	nameKeywords, err := ExtractKeywordsFromFile(namesPath, " ")
-	if err != nil {
-		return err
+	return runtime.GOMAXPROCS(0)
+}
+
+// Load reads and parses the data files to populate the index. If a
+// snapshot written by a prior SaveSnapshot call is still fresh (every
+// source file's fingerprint is unchanged), it restores from that snapshot
+// instead of reparsing; otherwise it parses as usual and rewrites the
+// snapshot for the next start.
+func (idx *DataIndex) Load() error {
+	snapshotPath := filepath.Join(idx.DataDir, snapshotFileName)
+	if restored, err := LoadSnapshot(snapshotPath); err == nil {
+		idx.Programs = restored.Programs
+		idx.Sequences = restored.Sequences
+		idx.Submitters = restored.Submitters
+		idx.NumUsages = restored.NumUsages
+		idx.ProgramIndex = BuildProgramSearchIndex(idx.Programs)
+		idx.SequenceIndex = BuildSequenceSearchIndex(idx.Sequences)
+		idx.ProgramTrigramIndex = BuildTrigramIndex(programTrigramTexts(idx.Programs))
+		idx.SequenceTrigramIndex = BuildTrigramIndex(sequenceTrigramTexts(idx.Sequences))
+		idx.ProgramsSorted = isProgramsSortedByID(idx.Programs)
+		log.Printf("Restored %d sequences, %d programs, %d submitters from snapshot %s",
+			len(idx.Sequences), len(idx.Programs), len(idx.Submitters), snapshotPath)
+		return nil
 	}
+
+	namesPath := filepath.Join(idx.OeisDir, "names")
 	keywordsPath := filepath.Join(idx.OeisDir, "keywords")
-	keywordsMap, err := LoadKeywordsFile(keywordsPath)
-	if err != nil {
-		return err
-	}
 	strippedPath := filepath.Join(idx.OeisDir, "stripped")
-	sequences, err := LoadStrippedFile(strippedPath, nameMap)
-	if err != nil {
-		return err
-	}
 	commentsPath := filepath.Join(idx.OeisDir, "comments")
-
-	// Efficiently extract extra keywords from comments, formulas, and names
-	commentKeywords, err := ExtractKeywordsFromFile(commentsPath, ":")
-	if err != nil {
-		return err
-	}
 	formulasPath := filepath.Join(idx.OeisDir, "formulas")
-	formulaKeywords, err := ExtractKeywordsFromFormulas(formulasPath)
-	if err != nil {
-		return err
-	}
 	oeisProgramsPath := filepath.Join(idx.OeisDir, "programs")
-	idsWithPari, err := ExtractPariSeqs(oeisProgramsPath)
-	if err != nil {
-		return err
-	}
-
 	submittersPath := filepath.Join(idx.StatsDir, "submitters.csv")
-	submitters, err := LoadSubmittersCSV(submittersPath)
-	if err != nil {
-		return err
-	}
 	programsPath := filepath.Join(idx.StatsDir, "programs.csv")
-	programs, err := LoadProgramsCSV(programsPath, submitters)
-	if err != nil {
+	callGraphPath := filepath.Join(idx.StatsDir, "call_graph.csv")
+
+	// Wave 1: every file that can be parsed without first knowing another
+	// file's result. "stripped" (needs nameMap) and "programs.csv" (needs
+	// submitters) are held back to wave 2.
+	var nameMap map[string]string
+	var nameKeywords map[string]uint64
+	var keywordsMap map[string][]string
+	var commentKeywords map[string]uint64
+	var formulaKeywords map[string]uint64
+	var idsWithPari map[string]struct{}
+	var submitters []*Submitter
+	var programUsages map[string]string
+
+	g1 := new(errgroup.Group)
+	g1.SetLimit(idx.concurrency())
+	g1.Go(func() (err error) { nameMap, err = LoadNamesFile(namesPath); return })
+	g1.Go(func() (err error) { nameKeywords, err = ExtractKeywordsFromFile(namesPath, " "); return })
+	g1.Go(func() (err error) { keywordsMap, err = LoadKeywordsFile(keywordsPath); return })
+	g1.Go(func() (err error) {
+		commentKeywords, err = ExtractKeywordsFromFileConcurrent(commentsPath, ":", idx.concurrency())
+		return
+	})
+	g1.Go(func() (err error) { formulaKeywords, err = ExtractKeywordsFromFormulas(formulasPath); return })
+	g1.Go(func() (err error) { idsWithPari, err = ExtractPariSeqs(oeisProgramsPath); return })
+	g1.Go(func() (err error) { submitters, err = LoadSubmittersCSV(submittersPath); return })
+	g1.Go(func() (err error) { programUsages, err = extractProgramUsages(callGraphPath); return })
+	if err := g1.Wait(); err != nil {
 		return err
 	}
-	callGraphPath := filepath.Join(idx.StatsDir, "call_graph.csv")
-	programUsages, err := extractProgramUsages(callGraphPath)
-	if err != nil {
+
+	// Wave 2: "stripped" and "programs.csv" only depend on wave 1 results,
+	// but not on each other, so they run concurrently with one another.
+	var sequences []Sequence
+	var programs []Program
+
+	g2 := new(errgroup.Group)
+	g2.SetLimit(idx.concurrency())
+	g2.Go(func() (err error) {
+		sequences, err = LoadStrippedFileConcurrent(strippedPath, nameMap, idx.concurrency())
+		return
+	})
+	g2.Go(func() (err error) { programs, err = LoadProgramsCSV(programsPath, submitters); return })
+	if err := g2.Wait(); err != nil {
 		return err
 	}
 
@@ -163,12 +223,33 @@ func (idx *DataIndex) Load() error {
 	idx.Programs = programs
 	idx.Sequences = sequences
 	idx.NumUsages = numUsages
+	idx.ProgramIndex = BuildProgramSearchIndex(idx.Programs)
+	idx.SequenceIndex = BuildSequenceSearchIndex(idx.Sequences)
+	idx.ProgramTrigramIndex = BuildTrigramIndex(programTrigramTexts(idx.Programs))
+	idx.SequenceTrigramIndex = BuildTrigramIndex(sequenceTrigramTexts(idx.Sequences))
+	idx.ProgramsSorted = isProgramsSortedByID(idx.Programs)
 
 	log.Printf("Loaded %d sequences, %d programs, %d submitters",
 		len(sequences), len(programs), len(submitters))
+
+	if err := idx.SaveSnapshot(snapshotPath); err != nil {
+		log.Printf("Warning: failed to save data index snapshot: %v", err)
+	}
 	return nil
 }
 
+// isProgramsSortedByID reports whether programs is sorted by ascending Id.
+// FindProgramById uses this (cached as DataIndex.ProgramsSorted) to decide
+// whether it can binary-search instead of scanning.
+func isProgramsSortedByID(programs []Program) bool {
+	for i := 1; i < len(programs); i++ {
+		if programs[i].Id.IsLessThan(programs[i-1].Id) {
+			return false
+		}
+	}
+	return true
+}
+
 // LoadNamesFile reads the OEIS names file and returns a map from UID string to name.
 func LoadNamesFile(path string) (map[string]string, error) {
 	file, err := os.Open(path)
@@ -244,8 +325,59 @@ func LoadStrippedFile(path string, nameMap map[string]string) ([]Sequence, error
 		return nil, fmt.Errorf("failed to open stripped file: %w", err)
 	}
 	defer file.Close()
+	sequences, err := scanStrippedSequences(file, nameMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stripped file: %w", err)
+	}
+	return sequences, nil
+}
+
+// LoadStrippedFileConcurrent behaves like LoadStrippedFile, but splits the
+// file into concurrency newline-aligned byte ranges and scans each on its
+// own goroutine, merging the resulting sequences at the end. It falls back
+// to LoadStrippedFile for files too small to be worth splitting.
+func LoadStrippedFileConcurrent(path string, nameMap map[string]string, concurrency int) ([]Sequence, error) {
+	chunks, err := splitFileIntoChunks(path, concurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stripped file: %w", err)
+	}
+	if len(chunks) <= 1 {
+		return LoadStrippedFile(path, nameMap)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stripped file: %w", err)
+	}
+	defer file.Close()
+
+	results := make([][]Sequence, len(chunks))
+	g := new(errgroup.Group)
+	for i, c := range chunks {
+		i, c := i, c
+		g.Go(func() error {
+			seqs, err := scanStrippedSequences(io.NewSectionReader(file, c.offset, c.length), nameMap)
+			if err != nil {
+				return err
+			}
+			results[i] = seqs
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to read stripped file: %w", err)
+	}
 	var sequences []Sequence
-	scanner := bufio.NewScanner(file)
+	for _, seqs := range results {
+		sequences = append(sequences, seqs...)
+	}
+	return sequences, nil
+}
+
+// scanStrippedSequences parses stripped-file-formatted lines ("<id> <terms>")
+// from r into Sequences, using nameMap to fill in each sequence's name.
+func scanStrippedSequences(r io.Reader, nameMap map[string]string) ([]Sequence, error) {
+	var sequences []Sequence
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if len(line) == 0 || line[0] == '#' {
@@ -268,7 +400,7 @@ func LoadStrippedFile(path string, nameMap map[string]string) ([]Sequence, error
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read stripped file: %w", err)
+		return nil, err
 	}
 	return sequences, nil
 }
@@ -327,8 +459,62 @@ func ExtractKeywordsFromFile(path string, separator string) (map[string]uint64,
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
+	encoded, err := scanKeywordBits(file, separator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return encoded, nil
+}
+
+// ExtractKeywordsFromFileConcurrent behaves like ExtractKeywordsFromFile, but
+// splits the file into concurrency newline-aligned byte ranges and scans
+// each on its own goroutine, OR-ing the resulting bits per id together at
+// the end. It falls back to ExtractKeywordsFromFile for files too small to
+// be worth splitting.
+func ExtractKeywordsFromFileConcurrent(path string, separator string, concurrency int) (map[string]uint64, error) {
+	chunks, err := splitFileIntoChunks(path, concurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if len(chunks) <= 1 {
+		return ExtractKeywordsFromFile(path, separator)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	results := make([]map[string]uint64, len(chunks))
+	g := new(errgroup.Group)
+	for i, c := range chunks {
+		i, c := i, c
+		g.Go(func() error {
+			bits, err := scanKeywordBits(io.NewSectionReader(file, c.offset, c.length), separator)
+			if err != nil {
+				return err
+			}
+			results[i] = bits
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
 	encoded := make(map[string]uint64)
-	scanner := bufio.NewScanner(file)
+	for _, bits := range results {
+		for id, b := range bits {
+			encoded[id] |= b
+		}
+	}
+	return encoded, nil
+}
+
+// scanKeywordBits parses "<id><separator><text>" lines from r and returns a
+// map from UID to encoded extra keywords extracted from text.
+func scanKeywordBits(r io.Reader, separator string) (map[string]uint64, error) {
+	encoded := make(map[string]uint64)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if len(line) == 0 || line[0] == '#' {
@@ -345,7 +531,7 @@ func ExtractKeywordsFromFile(path string, separator string) (map[string]uint64,
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 	return encoded, nil
 }
@@ -0,0 +1,122 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSplitFileIntoChunksAlignsOnNewlines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lines.txt")
+	var sb strings.Builder
+	for i := 0; i < 5000; i++ {
+		sb.WriteString("A000001 some line of text\n")
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	chunks, err := splitFileIntoChunks(path, 4)
+	if err != nil {
+		t.Fatalf("splitFileIntoChunks failed: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks for a large file, got %d", len(chunks))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	var total int64
+	var lineCount int
+	for _, c := range chunks {
+		buf := make([]byte, c.length)
+		if _, err := file.ReadAt(buf, c.offset); err != nil {
+			t.Fatalf("ReadAt failed: %v", err)
+		}
+		if !strings.HasSuffix(string(buf), "\n") {
+			t.Errorf("chunk %+v does not end on a newline boundary", c)
+		}
+		lineCount += strings.Count(string(buf), "\n")
+		total += c.length
+	}
+	if total != info.Size() {
+		t.Errorf("chunks cover %d bytes, want %d", total, info.Size())
+	}
+	if lineCount != 5000 {
+		t.Errorf("chunks cover %d lines, want 5000", lineCount)
+	}
+}
+
+func TestSplitFileIntoChunksSmallFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "small.txt")
+	if err := os.WriteFile(path, []byte("A000001 1,2,3\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	chunks, err := splitFileIntoChunks(path, 4)
+	if err != nil {
+		t.Fatalf("splitFileIntoChunks failed: %v", err)
+	}
+	if len(chunks) != 1 {
+		t.Fatalf("expected a single chunk for a small file, got %d", len(chunks))
+	}
+}
+
+func TestLoadStrippedFileConcurrentMatchesSerial(t *testing.T) {
+	path := filepath.Join("..", "testdata", "seqs", "oeis", "stripped")
+	nameMap, err := LoadNamesFile(filepath.Join("..", "testdata", "seqs", "oeis", "names"))
+	if err != nil {
+		t.Fatalf("LoadNamesFile failed: %v", err)
+	}
+	serial, err := LoadStrippedFile(path, nameMap)
+	if err != nil {
+		t.Fatalf("LoadStrippedFile failed: %v", err)
+	}
+	concurrent, err := LoadStrippedFileConcurrent(path, nameMap, 4)
+	if err != nil {
+		t.Fatalf("LoadStrippedFileConcurrent failed: %v", err)
+	}
+	sortSequencesById(serial)
+	sortSequencesById(concurrent)
+	if !reflect.DeepEqual(serial, concurrent) {
+		t.Errorf("LoadStrippedFileConcurrent result differs from LoadStrippedFile:\n got  %+v\n want %+v", concurrent, serial)
+	}
+}
+
+func TestExtractKeywordsFromFileConcurrentMatchesSerial(t *testing.T) {
+	path := filepath.Join("..", "testdata", "seqs", "oeis", "comments")
+	serial, err := ExtractKeywordsFromFile(path, ":")
+	if err != nil {
+		t.Fatalf("ExtractKeywordsFromFile failed: %v", err)
+	}
+	concurrent, err := ExtractKeywordsFromFileConcurrent(path, ":", 4)
+	if err != nil {
+		t.Fatalf("ExtractKeywordsFromFileConcurrent failed: %v", err)
+	}
+	if len(serial) != len(concurrent) {
+		t.Errorf("ExtractKeywordsFromFileConcurrent returned %d ids, want %d", len(concurrent), len(serial))
+	}
+	for id, bits := range serial {
+		if concurrent[id] != bits {
+			t.Errorf("ExtractKeywordsFromFileConcurrent[%s]: got %d, want %d", id, concurrent[id], bits)
+		}
+	}
+}
+
+func sortSequencesById(sequences []Sequence) {
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i].Id.IsLessThan(sequences[j].Id)
+	})
+}
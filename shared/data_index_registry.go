@@ -0,0 +1,122 @@
+package shared
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dataIndexReloadDebounce is how long DataIndexRegistry waits after the
+// last filesystem event before reloading. Publishing a new OEIS/stats
+// snapshot typically rewrites several of the files Load reads in quick
+// succession, so reloading on every single event would reparse the whole
+// index many times over for one logical update.
+const dataIndexReloadDebounce = 2 * time.Second
+
+// DataIndexRegistry holds the current *DataIndex built from a data
+// directory and reloads it automatically whenever a file under its OEIS or
+// stats directories changes on disk, so operators can publish updated OEIS
+// data without restarting the API. Current returns a consistent snapshot:
+// an in-flight read keeps using the index it started with even if a reload
+// swaps in a new one concurrently.
+type DataIndexRegistry struct {
+	current  atomic.Pointer[DataIndex]
+	onReload func(old, new *DataIndex)
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+}
+
+// NewDataIndexRegistry loads dataDir and starts watching its OEIS and stats
+// directories for changes. onReload, if non-nil, is called after each
+// successful reload with the previous and new index. It is not called for
+// the initial load.
+func NewDataIndexRegistry(dataDir string, onReload func(old, new *DataIndex)) (*DataIndexRegistry, error) {
+	index := NewDataIndex(dataDir)
+	if err := index.Load(); err != nil {
+		return nil, fmt.Errorf("cannot load data index from %s: %w", dataDir, err)
+	}
+	r := &DataIndexRegistry{onReload: onReload, done: make(chan struct{})}
+	r.current.Store(index)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create file watcher: %w", err)
+	}
+	for _, dir := range []string{index.OeisDir, index.StatsDir} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("cannot watch %s: %w", dir, err)
+		}
+	}
+	r.watcher = watcher
+	go r.watch()
+	return r, nil
+}
+
+// Current returns the most recently loaded DataIndex.
+func (r *DataIndexRegistry) Current() *DataIndex {
+	return r.current.Load()
+}
+
+func (r *DataIndexRegistry) watch() {
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(dataIndexReloadDebounce, func() {
+					select {
+					case reload <- struct{}{}:
+					default:
+					}
+				})
+			} else {
+				timer.Reset(dataIndexReloadDebounce)
+			}
+		case <-reload:
+			timer = nil
+			r.reload()
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Data index file watcher error: %v", err)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// reload rebuilds the index from the data directory's current contents and
+// only swaps it in on success. A failed reload logs the error and keeps
+// serving the previous index.
+func (r *DataIndexRegistry) reload() {
+	old := r.current.Load()
+	index := NewDataIndex(old.DataDir)
+	if err := index.Load(); err != nil {
+		log.Printf("Keeping previous data index, reload of %s failed: %v", old.DataDir, err)
+		return
+	}
+	r.current.Store(index)
+	if r.onReload != nil {
+		r.onReload(old, index)
+	}
+	log.Printf("Reloaded data index from %s", old.DataDir)
+}
+
+// Close stops watching the data directories. The most recently loaded
+// index remains available from Current.
+func (r *DataIndexRegistry) Close() error {
+	close(r.done)
+	return r.watcher.Close()
+}
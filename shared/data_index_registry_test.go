@@ -0,0 +1,84 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeDataIndexRegistryTestSourceFiles(t *testing.T, dataDir string) {
+	t.Helper()
+	oeisDir := filepath.Join(dataDir, "seqs", "oeis")
+	statsDir := filepath.Join(dataDir, "stats")
+	if err := os.MkdirAll(oeisDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.MkdirAll(statsDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, name := range []string{"names", "keywords", "stripped", "comments", "formulas", "programs"} {
+		if err := os.WriteFile(filepath.Join(oeisDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	for _, name := range []string{"submitters.csv", "programs.csv", "call_graph.csv"} {
+		if err := os.WriteFile(filepath.Join(statsDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+}
+
+func TestDataIndexRegistry_LoadsInitialIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeDataIndexRegistryTestSourceFiles(t, dir)
+
+	registry, err := NewDataIndexRegistry(dir, nil)
+	if err != nil {
+		t.Fatalf("NewDataIndexRegistry failed: %v", err)
+	}
+	defer registry.Close()
+
+	if registry.Current() == nil {
+		t.Fatal("expected an initial index")
+	}
+}
+
+func TestDataIndexRegistry_InvalidPathFails(t *testing.T) {
+	if _, err := NewDataIndexRegistry(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Error("expected an error loading a nonexistent data directory")
+	}
+}
+
+func TestDataIndexRegistry_ReloadsOnFileChange(t *testing.T) {
+	dir := t.TempDir()
+	writeDataIndexRegistryTestSourceFiles(t, dir)
+
+	reloaded := make(chan *DataIndex, 1)
+	registry, err := NewDataIndexRegistry(dir, func(old, new *DataIndex) {
+		reloaded <- new
+	})
+	if err != nil {
+		t.Fatalf("NewDataIndexRegistry failed: %v", err)
+	}
+	defer registry.Close()
+	before := registry.Current()
+
+	namesPath := filepath.Join(dir, "seqs", "oeis", "names")
+	if err := os.WriteFile(namesPath, []byte("A000001 Changed name\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	select {
+	case newIndex := <-reloaded:
+		if newIndex == before {
+			t.Errorf("reload should have produced a new DataIndex")
+		}
+	case <-time.After(dataIndexReloadDebounce + 5*time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	if registry.Current() == before {
+		t.Errorf("Current() should reflect the reloaded index")
+	}
+}
@@ -0,0 +1,140 @@
+package shared
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// snapshotFileName is the gob snapshot written alongside the source files
+// that DataIndex.Load parses, so subsequent restarts can restore from it
+// instead of reparsing everything from scratch.
+const snapshotFileName = "data_index.gob"
+
+// fileFingerprint records a source file's size and modification time so a
+// snapshot can be recognized as stale once any of them have moved on.
+type fileFingerprint struct {
+	Path    string
+	Size    int64
+	ModTime int64
+}
+
+// dataIndexSnapshot is the gob-serialized form of a DataIndex.
+type dataIndexSnapshot struct {
+	DataDir      string
+	OeisDir      string
+	StatsDir     string
+	Fingerprints []fileFingerprint
+	Programs     []Program
+	Sequences    []Sequence
+	Submitters   []*Submitter
+	NumUsages    map[string]int
+}
+
+// sourceFiles returns the paths of every file Load parses, in the order
+// their fingerprints are stored in a snapshot.
+func (idx *DataIndex) sourceFiles() []string {
+	return []string{
+		filepath.Join(idx.OeisDir, "names"),
+		filepath.Join(idx.OeisDir, "keywords"),
+		filepath.Join(idx.OeisDir, "stripped"),
+		filepath.Join(idx.OeisDir, "comments"),
+		filepath.Join(idx.OeisDir, "formulas"),
+		filepath.Join(idx.OeisDir, "programs"),
+		filepath.Join(idx.StatsDir, "submitters.csv"),
+		filepath.Join(idx.StatsDir, "programs.csv"),
+		filepath.Join(idx.StatsDir, "call_graph.csv"),
+	}
+}
+
+// fingerprintFiles stats each path and returns its fingerprint.
+func fingerprintFiles(paths []string) ([]fileFingerprint, error) {
+	fingerprints := make([]fileFingerprint, len(paths))
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		fingerprints[i] = fileFingerprint{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	}
+	return fingerprints, nil
+}
+
+// SaveSnapshot gob-encodes the index's parsed state, together with a
+// fingerprint of every source file Load reads, and installs it atomically
+// at path (tmpfile+rename).
+func (idx *DataIndex) SaveSnapshot(path string) error {
+	fingerprints, err := fingerprintFiles(idx.sourceFiles())
+	if err != nil {
+		return err
+	}
+	snapshot := dataIndexSnapshot{
+		DataDir:      idx.DataDir,
+		OeisDir:      idx.OeisDir,
+		StatsDir:     idx.StatsDir,
+		Fingerprints: fingerprints,
+		Programs:     idx.Programs,
+		Sequences:    idx.Sequences,
+		Submitters:   idx.Submitters,
+		NumUsages:    idx.NumUsages,
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	if err := gob.NewEncoder(file).Encode(snapshot); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close snapshot file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot restores a DataIndex from a snapshot previously written by
+// SaveSnapshot. It returns an error if the snapshot is missing, corrupt, or
+// stale relative to the source files recorded in it, so the caller can fall
+// back to a full Load.
+func LoadSnapshot(path string) (*DataIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var snapshot dataIndexSnapshot
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	paths := make([]string, len(snapshot.Fingerprints))
+	for i, fp := range snapshot.Fingerprints {
+		paths[i] = fp.Path
+	}
+	current, err := fingerprintFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(current, snapshot.Fingerprints) {
+		return nil, fmt.Errorf("stale snapshot %s", path)
+	}
+
+	return &DataIndex{
+		DataDir:    snapshot.DataDir,
+		OeisDir:    snapshot.OeisDir,
+		StatsDir:   snapshot.StatsDir,
+		Programs:   snapshot.Programs,
+		Sequences:  snapshot.Sequences,
+		Submitters: snapshot.Submitters,
+		NumUsages:  snapshot.NumUsages,
+	}, nil
+}
@@ -0,0 +1,96 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+func writeSnapshotTestSourceFiles(t *testing.T, oeisDir string, statsDir string) {
+	t.Helper()
+	if err := os.MkdirAll(oeisDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.MkdirAll(statsDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, name := range []string{"names", "keywords", "stripped", "comments", "formulas", "programs"} {
+		if err := os.WriteFile(filepath.Join(oeisDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	for _, name := range []string{"submitters.csv", "programs.csv", "call_graph.csv"} {
+		if err := os.WriteFile(filepath.Join(statsDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+}
+
+func TestDataIndex_SaveAndLoadSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	oeisDir := filepath.Join(dir, "oeis")
+	statsDir := filepath.Join(dir, "stats")
+	writeSnapshotTestSourceFiles(t, oeisDir, statsDir)
+
+	id, err := util.NewUID('A', 45)
+	if err != nil {
+		t.Fatalf("NewUID failed: %v", err)
+	}
+	idx := &DataIndex{
+		DataDir:   dir,
+		OeisDir:   oeisDir,
+		StatsDir:  statsDir,
+		Programs:  []Program{{Id: id, Name: "fib"}},
+		Sequences: []Sequence{{Id: id, Name: "Fibonacci"}},
+		NumUsages: map[string]int{"A000045": 3},
+	}
+	snapshotPath := filepath.Join(dir, snapshotFileName)
+	if err := idx.SaveSnapshot(snapshotPath); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored, err := LoadSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if len(restored.Programs) != 1 || !restored.Programs[0].Id.Equals(id) || restored.Programs[0].Name != "fib" {
+		t.Errorf("unexpected restored programs: %+v", restored.Programs)
+	}
+	if len(restored.Sequences) != 1 || restored.Sequences[0].Name != "Fibonacci" {
+		t.Errorf("unexpected restored sequences: %+v", restored.Sequences)
+	}
+	if restored.NumUsages["A000045"] != 3 {
+		t.Errorf("unexpected restored NumUsages: %+v", restored.NumUsages)
+	}
+}
+
+func TestDataIndex_LoadSnapshotDetectsStaleness(t *testing.T) {
+	dir := t.TempDir()
+	oeisDir := filepath.Join(dir, "oeis")
+	statsDir := filepath.Join(dir, "stats")
+	writeSnapshotTestSourceFiles(t, oeisDir, statsDir)
+
+	idx := &DataIndex{DataDir: dir, OeisDir: oeisDir, StatsDir: statsDir}
+	snapshotPath := filepath.Join(dir, snapshotFileName)
+	if err := idx.SaveSnapshot(snapshotPath); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	// Simulate a source file rewritten after the snapshot was taken.
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(oeisDir, "names"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := LoadSnapshot(snapshotPath); err == nil {
+		t.Error("LoadSnapshot: expected a stale-snapshot error after a source file changed")
+	}
+}
+
+func TestDataIndex_LoadSnapshotMissingFile(t *testing.T) {
+	if _, err := LoadSnapshot(filepath.Join(t.TempDir(), "nope.gob")); err == nil {
+		t.Error("LoadSnapshot: expected an error for a missing snapshot file")
+	}
+}
@@ -0,0 +1,227 @@
+package shared
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Value is the result of evaluating an Expr. A big.Rat's arbitrary-precision
+// big.Int numerator and denominator cover both plain integer sequences and
+// the proper fractions produced by frac/sign-style formulas, so there is no
+// need for a separate integer representation.
+type Value = *big.Rat
+
+// Recurrence resolves one term of a self-referencing sequence name used as
+// a(n-1), b(n+2), etc. in a formula: given the requested index, it returns
+// the RHS expression to evaluate and the Env to evaluate it in (typically
+// env.WithVar binding the recurrence's own index variable).
+type Recurrence func(index int64) (Expr, Env)
+
+// Env supplies Eval's free variables (e.g. "n") and recursive sequence
+// bindings (e.g. "a" for a(n-1)) in a single namespace, mirroring how a
+// formula's variables and self-references share one namespace in its
+// source text.
+type Env struct {
+	Vars map[string]Value
+	Recs map[string]Recurrence
+}
+
+// WithVar returns a copy of env with name bound to value, leaving env
+// itself untouched, so evaluating a recurrence's body can extend the
+// environment for that one call (e.g. binding a(n-1)'s index variable)
+// without affecting the caller's Env.
+func (env Env) WithVar(name string, value Value) Env {
+	vars := make(map[string]Value, len(env.Vars)+1)
+	for k, v := range env.Vars {
+		vars[k] = v
+	}
+	vars[name] = value
+	return Env{Vars: vars, Recs: env.Recs}
+}
+
+// DefaultEvalBudget bounds the number of evaluation steps (node visits and
+// recursive a(n) calls) a single call to Eval may take, protecting against
+// a malformed or adversarial formula (e.g. a(n) = a(n) + 1, with no base
+// case) recursing forever.
+const DefaultEvalBudget = 100000
+
+// Eval evaluates e in env, using DefaultEvalBudget. See EvalBudget for a
+// configurable budget.
+func Eval(e Expr, env Env) (Value, error) {
+	return EvalBudget(e, env, DefaultEvalBudget)
+}
+
+// MustEval is like Eval but panics on error. It's for call sites (tests,
+// formulas already known to evaluate cleanly) that don't want to thread an
+// error return through.
+func MustEval(e Expr, env Env) Value {
+	v, err := Eval(e, env)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// EvalBudget evaluates e in env, much like EvaluateFormula does for a whole
+// Formula, but as a standalone primitive over any Expr and caller-supplied
+// Env. It fails once more than budget evaluation steps have been taken (see
+// DefaultEvalBudget), and detects cycles among recursive a(n)-style calls
+// (e.g. a(n) defined in terms of a(n)) so they fail fast with a descriptive
+// error instead of recursing until the budget, or the Go call stack, is
+// exhausted.
+func EvalBudget(e Expr, env Env, budget int) (Value, error) {
+	ev := &exprEvaluator{budget: budget, stack: map[string]bool{}, memo: map[string]Value{}}
+	return ev.eval(e, env)
+}
+
+// exprEvaluator threads the step budget, in-progress recurrence stack (for
+// cycle detection), and memoized recurrence results through one Eval call.
+type exprEvaluator struct {
+	budget int
+	stack  map[string]bool
+	memo   map[string]Value
+}
+
+func (ev *exprEvaluator) step() error {
+	ev.budget--
+	if ev.budget < 0 {
+		return fmt.Errorf("evaluation budget exceeded")
+	}
+	return nil
+}
+
+func (ev *exprEvaluator) eval(e Expr, env Env) (Value, error) {
+	if err := ev.step(); err != nil {
+		return nil, err
+	}
+	switch v := e.(type) {
+	case ConstExpr:
+		r, ok := new(big.Rat).SetString(v.Value)
+		if !ok {
+			return nil, fmt.Errorf("invalid numeric constant %q", v.Value)
+		}
+		return r, nil
+	case VarExpr:
+		val, ok := env.Vars[v.Name]
+		if !ok {
+			return nil, fmt.Errorf("unbound variable %q", v.Name)
+		}
+		return val, nil
+	case IndexedVarExpr:
+		idxVal, err := ev.eval(v.Index, env)
+		if err != nil {
+			return nil, err
+		}
+		idx, ok := asInt(idxVal)
+		if !ok {
+			return nil, fmt.Errorf("index of %s(...) is not an integer", v.Name)
+		}
+		return ev.evalRec(v.Name, idx.Int64(), env)
+	case FuncCallExpr:
+		return ev.evalFuncCall(v, env)
+	case BinaryExpr:
+		l, err := ev.eval(v.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		r, err := ev.eval(v.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		return evalBinaryOp(v.Op, l, r)
+	case UnaryExpr:
+		x, err := ev.eval(v.Expr, env)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Op {
+		case "-":
+			return new(big.Rat).Neg(x), nil
+		case "+":
+			return x, nil
+		case "abs":
+			return new(big.Rat).Abs(x), nil
+		default:
+			return nil, fmt.Errorf("unsupported unary operator %q", v.Op)
+		}
+	case CompareExpr:
+		l, err := ev.eval(v.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		r, err := ev.eval(v.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		if !compareOp(v.Op, l.Cmp(r)) {
+			return big.NewRat(0, 1), nil
+		}
+		return big.NewRat(1, 1), nil
+	case IfExpr:
+		cond, err := ev.eval(v.Cond, env)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Sign() != 0 {
+			return ev.eval(v.Then, env)
+		}
+		return ev.eval(v.Else, env)
+	default:
+		return nil, fmt.Errorf("unsupported expression node %T", e)
+	}
+}
+
+// evalRec evaluates the recurrence bound to name at index, memoizing the
+// result and detecting cycles (a recurrence that, directly or indirectly,
+// calls back into itself at the same index).
+func (ev *exprEvaluator) evalRec(name string, index int64, env Env) (Value, error) {
+	key := fmt.Sprintf("%s(%d)", name, index)
+	if val, ok := ev.memo[key]; ok {
+		return val, nil
+	}
+	if ev.stack[key] {
+		return nil, fmt.Errorf("cyclic recurrence: %s", key)
+	}
+	rec, ok := env.Recs[name]
+	if !ok {
+		return nil, fmt.Errorf("no recurrence defined for %q", name)
+	}
+	ev.stack[key] = true
+	defer delete(ev.stack, key)
+	rhs, recEnv := rec(index)
+	val, err := ev.eval(rhs, recEnv)
+	if err != nil {
+		return nil, err
+	}
+	ev.memo[key] = val
+	return val, nil
+}
+
+// evalFuncCall dispatches a FuncCallExpr. "if" is special-cased ahead of the
+// builtins table so only the taken branch is evaluated, matching IfExpr's
+// short-circuiting and letting formulas guard a(n-1) against out-of-range
+// indices (e.g. if(n==0,1,a(n-1))).
+func (ev *exprEvaluator) evalFuncCall(v FuncCallExpr, env Env) (Value, error) {
+	if v.FuncName == "if" {
+		if len(v.Args) != 3 {
+			return nil, fmt.Errorf("if expects 3 arguments, got %d", len(v.Args))
+		}
+		cond, err := ev.eval(v.Args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Sign() != 0 {
+			return ev.eval(v.Args[1], env)
+		}
+		return ev.eval(v.Args[2], env)
+	}
+	args := make([]*big.Rat, len(v.Args))
+	for i, a := range v.Args {
+		r, err := ev.eval(a, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = r
+	}
+	return evalBuiltin(v.FuncName, args)
+}
@@ -0,0 +1,119 @@
+package shared
+
+import (
+	"math/big"
+	"testing"
+)
+
+func mustParseExpr(t *testing.T, s string) Expr {
+	t.Helper()
+	e, err := ParseExpr(s)
+	if err != nil {
+		t.Fatalf("ParseExpr(%q) failed: %v", s, err)
+	}
+	return e
+}
+
+func TestEval_ArithmeticAndBuiltins(t *testing.T) {
+	tests := []struct {
+		expr string
+		vars map[string]Value
+		want *big.Rat
+	}{
+		{"n*(n+1)/2", map[string]Value{"n": big.NewRat(5, 1)}, big.NewRat(15, 1)},
+		{"floor(n/2)+ceiling(n/3)", map[string]Value{"n": big.NewRat(7, 1)}, big.NewRat(5, 1)},
+		{"binomial(2*n,n)", map[string]Value{"n": big.NewRat(3, 1)}, big.NewRat(20, 1)},
+		{"sign(n-3)", map[string]Value{"n": big.NewRat(1, 1)}, big.NewRat(-1, 1)},
+		{"n/2", map[string]Value{"n": big.NewRat(1, 1)}, big.NewRat(1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Eval(mustParseExpr(t, tt.expr), Env{Vars: tt.vars})
+			if err != nil {
+				t.Fatalf("Eval(%q) failed: %v", tt.expr, err)
+			}
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("Eval(%q) = %s, want %s", tt.expr, got.String(), tt.want.String())
+			}
+		})
+	}
+}
+
+func TestEval_IfFuncCallShortCircuits(t *testing.T) {
+	// if(cond,a,b) isn't produced by ParseExpr (which only parses the
+	// "if cond then a else b" keyword form into IfExpr), but Eval supports
+	// it as a FuncCallExpr too, for ASTs built programmatically. The "else"
+	// branch divides by zero, so short-circuiting is load-bearing here.
+	e := FuncCallExpr{FuncName: "if", Args: []Expr{
+		CompareExpr{Op: "==", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "0"}},
+		ConstExpr{Value: "1"},
+		BinaryExpr{Op: "/", Left: ConstExpr{Value: "1"}, Right: ConstExpr{Value: "0"}},
+	}}
+	got, err := Eval(e, Env{Vars: map[string]Value{"n": big.NewRat(0, 1)}})
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if got.Cmp(big.NewRat(1, 1)) != 0 {
+		t.Errorf("got %s, want 1", got.String())
+	}
+}
+
+func TestEval_RecurrenceWithMemoization(t *testing.T) {
+	// a(n) = a(n-1) + a(n-2), a(0) = 0, a(1) = 1
+	body := mustParseExpr(t, "a(n-1)+a(n-2)")
+	calls := 0
+	var a Recurrence
+	a = func(index int64) (Expr, Env) {
+		calls++
+		if index == 0 {
+			return mustParseExpr(t, "0"), Env{}
+		}
+		if index == 1 {
+			return mustParseExpr(t, "1"), Env{}
+		}
+		return body, Env{Vars: map[string]Value{"n": big.NewRat(index, 1)}, Recs: map[string]Recurrence{"a": a}}
+	}
+	env := Env{Recs: map[string]Recurrence{"a": a}}
+
+	got, err := Eval(mustParseExpr(t, "a(n)"), env.WithVar("n", big.NewRat(10, 1)))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if got.Cmp(big.NewRat(55, 1)) != 0 {
+		t.Errorf("a(10) = %s, want 55", got.String())
+	}
+	if calls > 11 {
+		t.Errorf("expected memoization to bound calls to ~11, got %d", calls)
+	}
+}
+
+func TestEval_CyclicRecurrenceFails(t *testing.T) {
+	var a Recurrence
+	a = func(index int64) (Expr, Env) {
+		return mustParseExpr(t, "a(n)"), Env{Vars: map[string]Value{"n": big.NewRat(index, 1)}, Recs: map[string]Recurrence{"a": a}}
+	}
+	env := Env{Vars: map[string]Value{"n": big.NewRat(5, 1)}, Recs: map[string]Recurrence{"a": a}}
+	if _, err := Eval(mustParseExpr(t, "a(n)"), env); err == nil {
+		t.Error("expected an error for a self-referencing cycle")
+	}
+}
+
+func TestEvalBudget_ExceededFails(t *testing.T) {
+	var a Recurrence
+	a = func(index int64) (Expr, Env) {
+		return mustParseExpr(t, "a(n-1)"), Env{Vars: map[string]Value{"n": big.NewRat(index, 1)}, Recs: map[string]Recurrence{"a": a}}
+	}
+	env := Env{Vars: map[string]Value{"n": big.NewRat(1000000, 1)}, Recs: map[string]Recurrence{"a": a}}
+	if _, err := EvalBudget(mustParseExpr(t, "a(n)"), env, 10); err == nil {
+		t.Error("expected the budget to be exceeded")
+	}
+}
+
+func TestMustEval_PanicsOnError(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustEval to panic on an unbound variable")
+		}
+	}()
+	MustEval(mustParseExpr(t, "n"), Env{})
+}
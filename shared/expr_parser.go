@@ -4,84 +4,177 @@ import (
 	"strings"
 )
 
-// ParseExpr parses a formula expression string into an AST (Expr).
-func ParseExpr(expr string) Expr {
+// SequenceName decides whether an identifier used as f(x) should be parsed
+// as a reference to a sequence term (IndexedVarExpr, e.g. a(n-1)) rather
+// than a generic function call (FuncCallExpr, e.g. binomial(n,2)). It
+// defaults to the OEIS convention of single lowercase letters (a, b, c, ...)
+// but can be overridden to recognize other naming schemes.
+var SequenceName = func(name string) bool {
+	return len(name) == 1 && name[0] >= 'a' && name[0] <= 'z'
+}
+
+// ParseExpr parses a formula expression string into an AST (Expr). It
+// returns a *ParseError (via the usual error interface) describing the
+// offending offset and token if expr is malformed.
+func ParseExpr(expr string) (Expr, error) {
 	tokenizer := NewTokenizer(strings.TrimSpace(expr))
-	return parseAssignment(tokenizer)
+	e, err := parseAssignment(tokenizer)
+	if err != nil {
+		return nil, err
+	}
+	if tok := tokenizer.Peek(); tok.Type != TokenEOF {
+		return nil, &ParseError{Offset: tok.Offset, Token: tok, Expected: "EOF"}
+	}
+	return e, nil
 }
 
-// assignment = compare ( '=' assignment )?
-func parseAssignment(t *Tokenizer) Expr {
-	lhs := parseCompare(t)
+// assignment = ifExpr ( '=' assignment )?
+func parseAssignment(t *Tokenizer) (Expr, error) {
+	lhs, err := parseIf(t)
+	if err != nil {
+		return nil, err
+	}
 	if t.Peek().Type == TokenOperator && t.Peek().Value == "=" {
 		t.Next()
-		rhs := parseAssignment(t)
-		return AssignExpr{LHS: lhs, RHS: rhs}
+		rhs, err := parseAssignment(t)
+		if err != nil {
+			return nil, err
+		}
+		return AssignExpr{LHS: lhs, RHS: rhs}, nil
 	}
-	return lhs
+	return lhs, nil
+}
+
+// ifExpr = 'if' compare 'then' ifExpr 'else' ifExpr | compare
+func parseIf(t *Tokenizer) (Expr, error) {
+	if t.Peek().Type == TokenIdent && t.Peek().Value == "if" {
+		t.Next()
+		cond, err := parseCompare(t)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.Expect(TokenIdent, "then"); err != nil {
+			return nil, err
+		}
+		thenExpr, err := parseIf(t)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.Expect(TokenIdent, "else"); err != nil {
+			return nil, err
+		}
+		elseExpr, err := parseIf(t)
+		if err != nil {
+			return nil, err
+		}
+		return IfExpr{Cond: cond, Then: thenExpr, Else: elseExpr}, nil
+	}
+	return parseCompare(t)
 }
 
 // compare = add ( ('=='|'!='|'<'|'<='|'>'|'>=') add )*
-func parseCompare(t *Tokenizer) Expr {
-	lhs := parseAdd(t)
+func parseCompare(t *Tokenizer) (Expr, error) {
+	lhs, err := parseAdd(t)
+	if err != nil {
+		return nil, err
+	}
 	for t.Peek().Type == TokenOperator && (t.Peek().Value == "==" || t.Peek().Value == "!=" || t.Peek().Value == "<" || t.Peek().Value == "<=" || t.Peek().Value == ">" || t.Peek().Value == ">=") {
 		op := t.Next().Value
-		rhs := parseAdd(t)
+		rhs, err := parseAdd(t)
+		if err != nil {
+			return nil, err
+		}
 		lhs = CompareExpr{Op: op, Left: lhs, Right: rhs}
 	}
-	return lhs
+	return lhs, nil
 }
 
 // add = mul ( ('+'|'-') mul )*
-func parseAdd(t *Tokenizer) Expr {
-	lhs := parseMul(t)
+func parseAdd(t *Tokenizer) (Expr, error) {
+	lhs, err := parseMul(t)
+	if err != nil {
+		return nil, err
+	}
 	for t.Peek().Type == TokenOperator && (t.Peek().Value == "+" || t.Peek().Value == "-") {
 		op := t.Next().Value
-		rhs := parseMul(t)
+		rhs, err := parseMul(t)
+		if err != nil {
+			return nil, err
+		}
 		lhs = BinaryExpr{Op: op, Left: lhs, Right: rhs}
 	}
-	return lhs
+	return lhs, nil
 }
 
 // mul = pow ( ('*'|'/'|'%') pow )*
-func parseMul(t *Tokenizer) Expr {
-	lhs := parsePow(t)
+func parseMul(t *Tokenizer) (Expr, error) {
+	lhs, err := parsePow(t)
+	if err != nil {
+		return nil, err
+	}
 	for t.Peek().Type == TokenOperator && (t.Peek().Value == "*" || t.Peek().Value == "/" || t.Peek().Value == "%") {
 		op := t.Next().Value
-		rhs := parsePow(t)
+		rhs, err := parsePow(t)
+		if err != nil {
+			return nil, err
+		}
 		lhs = BinaryExpr{Op: op, Left: lhs, Right: rhs}
 	}
-	return lhs
+	return lhs, nil
 }
 
 // pow = unary ( '^' pow )?
-func parsePow(t *Tokenizer) Expr {
-	lhs := parseUnary(t)
+func parsePow(t *Tokenizer) (Expr, error) {
+	lhs, err := parseUnary(t)
+	if err != nil {
+		return nil, err
+	}
 	if t.Peek().Type == TokenOperator && t.Peek().Value == "^" {
 		op := t.Next().Value
-		rhs := parsePow(t)
-		return BinaryExpr{Op: op, Left: lhs, Right: rhs}
+		rhs, err := parsePow(t)
+		if err != nil {
+			return nil, err
+		}
+		return BinaryExpr{Op: op, Left: lhs, Right: rhs}, nil
 	}
-	return lhs
+	return lhs, nil
 }
 
-// unary = ('-'|'+') unary | primary
-func parseUnary(t *Tokenizer) Expr {
+// unary = ('-'|'+') unary | 'abs' unary | primary
+func parseUnary(t *Tokenizer) (Expr, error) {
 	if t.Peek().Type == TokenOperator && (t.Peek().Value == "-" || t.Peek().Value == "+") {
 		op := t.Next().Value
-		expr := parseUnary(t)
-		return UnaryExpr{Op: op, Expr: expr}
+		expr, err := parseUnary(t)
+		if err != nil {
+			return nil, err
+		}
+		return UnaryExpr{Op: op, Expr: expr}, nil
+	}
+	// "abs x" is the prefix form; "abs(x)" is left as a regular function
+	// call (parsePrimary handles it) since the two are indistinguishable
+	// once whitespace is discarded.
+	if t.Peek().Type == TokenIdent && t.Peek().Value == "abs" {
+		lookahead := *t
+		lookahead.next()
+		if !(lookahead.Peek().Type == TokenParen && lookahead.Peek().Value == "(") {
+			t.Next()
+			expr, err := parseUnary(t)
+			if err != nil {
+				return nil, err
+			}
+			return UnaryExpr{Op: "abs", Expr: expr}, nil
+		}
 	}
 	return parsePrimary(t)
 }
 
 // primary = number | ident ( '(' args ')' )? | '(' expr ')'
-func parsePrimary(t *Tokenizer) Expr {
+func parsePrimary(t *Tokenizer) (Expr, error) {
 	tok := t.Peek()
 	switch tok.Type {
 	case TokenNumber:
 		t.Next()
-		return ConstExpr{Value: tok.Value}
+		return ConstExpr{Value: tok.Value}, nil
 	case TokenIdent:
 		name := t.Next().Value
 		// Function call or indexed variable
@@ -90,7 +183,11 @@ func parsePrimary(t *Tokenizer) Expr {
 			var args []Expr
 			if t.Peek().Type != TokenParen || t.Peek().Value != ")" {
 				for {
-					args = append(args, parseAssignment(t))
+					arg, err := parseAssignment(t)
+					if err != nil {
+						return nil, err
+					}
+					args = append(args, arg)
 					if t.Peek().Type == TokenComma {
 						t.Next()
 					} else {
@@ -98,19 +195,28 @@ func parsePrimary(t *Tokenizer) Expr {
 					}
 				}
 			}
-			t.Expect(TokenParen) // consume ')'
-			return FuncCallExpr{FuncName: name, Args: args}
+			if _, err := t.Expect(TokenParen, ")"); err != nil {
+				return nil, err
+			}
+			if len(args) == 1 && SequenceName(name) {
+				return IndexedVarExpr{Name: name, Index: args[0]}, nil
+			}
+			return FuncCallExpr{FuncName: name, Args: args}, nil
 		}
-		return VarExpr{Name: name}
+		return VarExpr{Name: name}, nil
 	case TokenParen:
 		if tok.Value == "(" {
 			t.Next()
-			expr := parseAssignment(t)
-			t.Expect(TokenParen) // consume ')'
-			return expr
+			expr, err := parseAssignment(t)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := t.Expect(TokenParen, ")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
 		}
 	}
-	// fallback: treat as constant
-	t.Next()
-	return ConstExpr{Value: tok.Value}
+	// Unrecognized token where an expression was expected.
+	return nil, &ParseError{Offset: tok.Offset, Token: tok, Expected: "expression"}
 }
@@ -0,0 +1,198 @@
+package shared
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"const", "2", "2"},
+		{"var", "n", "n"},
+		{"func call", "a(n)", "a(n)"},
+		{"binary", "a(n)+a(m)", "a(n)+a(m)"},
+		{"precedence", "a(n)*2+1", "a(n)*2+1"},
+		{"parens", "(a(n)+1)*2", "(a(n)+1)*2"},
+		{"power", "2^n", "2^n"},
+		{"compare", "a(n)==a(m)", "a(n)==a(m)"},
+		{"binomial", "binomial(n,2)", "binomial(n,2)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpr(%q) failed: %v", tt.expr, err)
+			}
+			if got := ExprToString(e); got != tt.want {
+				t.Errorf("ParseExpr(%q) round-tripped to %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpr_IndexedVarVsFuncCall(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Expr
+	}{
+		{"a(n-1)", IndexedVarExpr{Name: "a", Index: BinaryExpr{Op: "-", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "1"}}}},
+		{"binomial(n,2)", FuncCallExpr{FuncName: "binomial", Args: []Expr{VarExpr{Name: "n"}, ConstExpr{Value: "2"}}}},
+		{"a(n,k)", FuncCallExpr{FuncName: "a", Args: []Expr{VarExpr{Name: "n"}, VarExpr{Name: "k"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpr(%q) failed: %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseExpr(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpr_IfThenElse(t *testing.T) {
+	got, err := ParseExpr("if n==0 then 1 else n*a(n-1)")
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+	want := IfExpr{
+		Cond: CompareExpr{Op: "==", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "0"}},
+		Then: ConstExpr{Value: "1"},
+		Else: BinaryExpr{
+			Op:   "*",
+			Left: VarExpr{Name: "n"},
+			Right: IndexedVarExpr{Name: "a", Index: BinaryExpr{
+				Op: "-", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "1"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExpr(if/then/else) = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseExpr_AbsPrefix(t *testing.T) {
+	got, err := ParseExpr("abs n")
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+	want := UnaryExpr{Op: "abs", Expr: VarExpr{Name: "n"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExpr(%q) = %#v, want %#v", "abs n", got, want)
+	}
+	// "abs(n)" keeps its parenthesized, function-call shape.
+	got, err = ParseExpr("abs(n)")
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+	wantCall := FuncCallExpr{FuncName: "abs", Args: []Expr{VarExpr{Name: "n"}}}
+	if !reflect.DeepEqual(got, wantCall) {
+		t.Errorf("ParseExpr(%q) = %#v, want %#v", "abs(n)", got, wantCall)
+	}
+}
+
+func TestParseExpr_NegativeLiteralVsBinaryMinus(t *testing.T) {
+	got, err := ParseExpr("n-1")
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+	want := BinaryExpr{Op: "-", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExpr(%q) = %#v, want %#v", "n-1", got, want)
+	}
+
+	got, err = ParseExpr("(-1)^n")
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+	want2 := BinaryExpr{Op: "^", Left: ConstExpr{Value: "-1"}, Right: VarExpr{Name: "n"}}
+	if !reflect.DeepEqual(got, want2) {
+		t.Errorf("ParseExpr(%q) = %#v, want %#v", "(-1)^n", got, want2)
+	}
+}
+
+// TestParseExpr_TestdataRoundTrip parses every formula in testdata/formula.txt
+// expression-by-expression and checks both that ExprToString round-trips to
+// an equivalent expression (once whitespace and parentheses are normalized
+// away) and that re-parsing that string yields an identical AST.
+func TestParseExpr_TestdataRoundTrip(t *testing.T) {
+	f, err := os.Open("../testdata/formula.txt")
+	if err != nil {
+		t.Fatalf("failed to open formula.txt: %v", err)
+	}
+	defer f.Close()
+
+	norm := func(s string) string {
+		s = strings.NewReplacer("(", "", ")", "").Replace(s)
+		return strings.Join(strings.Fields(s), "")
+	}
+
+	scanner := bufio.NewScanner(f)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		for _, part := range splitTopLevel(line, ',') {
+			part = strings.TrimSpace(part)
+			ast1, err := ParseExpr(part)
+			if err != nil {
+				t.Errorf("line %d: ParseExpr(%q) failed: %v", lineno, part, err)
+				continue
+			}
+			s1 := ExprToString(ast1)
+			if norm(s1) != norm(part) {
+				t.Errorf("line %d: ExprToString(ParseExpr(%q)) = %q, not equivalent after normalization", lineno, part, s1)
+			}
+			ast2, err := ParseExpr(s1)
+			if err != nil {
+				t.Errorf("line %d: re-parsing %q failed: %v", lineno, s1, err)
+				continue
+			}
+			if !reflect.DeepEqual(ast1, ast2) {
+				t.Errorf("line %d: re-parsing %q produced a different AST: %#v vs %#v", lineno, s1, ast2, ast1)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scanner error: %v", err)
+	}
+}
+
+func TestParseExpr_InvalidInput(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"unclosed paren", "a(n-1"},
+		{"unclosed func call", "binomial(n,2"},
+		{"trailing operator", "a(n)+"},
+		{"trailing garbage", "a(n) b"},
+		{"missing then", "if n==0 1 else 2"},
+		{"missing else", "if n==0 then 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpr(tt.expr)
+			if err == nil {
+				t.Fatalf("ParseExpr(%q): expected an error, got none", tt.expr)
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseExpr(%q): expected a *ParseError, got %T", tt.expr, err)
+			}
+		})
+	}
+}
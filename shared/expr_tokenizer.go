@@ -17,8 +17,9 @@ const (
 )
 
 type Token struct {
-	Type  TokenType
-	Value string
+	Type   TokenType
+	Value  string
+	Offset int // byte offset of the token's first character in the input
 }
 
 type Tokenizer struct {
@@ -34,30 +35,35 @@ func NewTokenizer(input string) *Tokenizer {
 }
 
 func (t *Tokenizer) next() {
+	// A '-' only starts a negative numeric literal when it can't be a binary
+	// operator, i.e. when the previous token couldn't end an expression on
+	// its own (a number, identifier, or closing paren). Otherwise "a-1"
+	// would lex as the identifier "a" followed by the literal "-1" instead
+	// of a subtraction.
+	prevEndsValue := t.curr.Type == TokenNumber || t.curr.Type == TokenIdent || (t.curr.Type == TokenParen && t.curr.Value == ")")
 	t.skipWhitespace()
 	if t.pos >= len(t.input) {
-		t.curr = Token{Type: TokenEOF}
+		t.curr = Token{Type: TokenEOF, Offset: t.pos}
 		return
 	}
+	start := t.pos
 	ch := t.input[t.pos]
 	// Numbers (integer, negative, float)
-	if unicode.IsDigit(rune(ch)) || (ch == '-' && t.pos+1 < len(t.input) && unicode.IsDigit(rune(t.input[t.pos+1]))) {
-		start := t.pos
+	if unicode.IsDigit(rune(ch)) || (ch == '-' && !prevEndsValue && t.pos+1 < len(t.input) && unicode.IsDigit(rune(t.input[t.pos+1]))) {
 		t.pos++ // skip first digit or '-'
 		for t.pos < len(t.input) && (unicode.IsDigit(rune(t.input[t.pos])) || t.input[t.pos] == '.') {
 			t.pos++
 		}
-		t.curr = Token{Type: TokenNumber, Value: t.input[start:t.pos]}
+		t.curr = Token{Type: TokenNumber, Value: t.input[start:t.pos], Offset: start}
 		return
 	}
 	// Identifiers (variables, function names)
 	if unicode.IsLetter(rune(ch)) || ch == '_' {
-		start := t.pos
 		t.pos++
 		for t.pos < len(t.input) && (unicode.IsLetter(rune(t.input[t.pos])) || unicode.IsDigit(rune(t.input[t.pos])) || t.input[t.pos] == '_') {
 			t.pos++
 		}
-		t.curr = Token{Type: TokenIdent, Value: t.input[start:t.pos]}
+		t.curr = Token{Type: TokenIdent, Value: t.input[start:t.pos], Offset: start}
 		return
 	}
 	// Operators and punctuation
@@ -66,26 +72,26 @@ func (t *Tokenizer) next() {
 		op2 := t.input[t.pos : t.pos+2]
 		switch op2 {
 		case "==", "<=", ">=", "!=":
-			t.curr = Token{Type: TokenOperator, Value: op2}
+			t.curr = Token{Type: TokenOperator, Value: op2, Offset: start}
 			t.pos += 2
 			return
 		}
 	}
 	switch ch {
 	case '+', '-', '*', '/', '%', '^', '=', '<', '>', '!':
-		t.curr = Token{Type: TokenOperator, Value: string(ch)}
+		t.curr = Token{Type: TokenOperator, Value: string(ch), Offset: start}
 		t.pos++
 		return
 	case '(', ')':
-		t.curr = Token{Type: TokenParen, Value: string(ch)}
+		t.curr = Token{Type: TokenParen, Value: string(ch), Offset: start}
 		t.pos++
 		return
 	case ',':
-		t.curr = Token{Type: TokenComma, Value: ","}
+		t.curr = Token{Type: TokenComma, Value: ",", Offset: start}
 		t.pos++
 		return
 	}
-	t.curr = Token{Type: TokenOperator, Value: string(ch)}
+	t.curr = Token{Type: TokenOperator, Value: string(ch), Offset: start}
 	t.pos++
 }
 
@@ -105,10 +111,29 @@ func (t *Tokenizer) Next() Token {
 	return tok
 }
 
-func (t *Tokenizer) Expect(tt TokenType) Token {
+// Expect consumes the next token and checks that it has type tt and, for
+// tokens with more than one possible value (operators and parens), the
+// given value. It returns a *ParseError instead of panicking on mismatch.
+func (t *Tokenizer) Expect(tt TokenType, value string) (Token, error) {
 	tok := t.Next()
-	if tok.Type != tt {
-		panic(fmt.Sprintf("expected token %v, got %v", tt, tok.Type))
+	if tok.Type != tt || (value != "" && tok.Value != value) {
+		return tok, &ParseError{Offset: tok.Offset, Token: tok, Expected: value}
 	}
-	return tok
+	return tok, nil
+}
+
+// ParseError reports a formula parse failure with the byte offset and
+// token at which it occurred.
+type ParseError struct {
+	Offset   int
+	Token    Token
+	Expected string
+}
+
+func (e *ParseError) Error() string {
+	got := e.Token.Value
+	if e.Token.Type == TokenEOF {
+		got = "EOF"
+	}
+	return fmt.Sprintf("parse error at offset %d: expected '%s', got %s", e.Offset, e.Expected, got)
 }
@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var fieldRegexp = regexp.MustCompile(`%([A-Za-z])\s+A([0-9]+)\s+(.+)`)
+
+// Field is a single OEIS metadata line, e.g. "%N A000042 ...", as returned
+// by the OEIS search API and stored in the per-key List files (see
+// ListNames).
+type Field struct {
+	Key     string
+	SeqId   int
+	Content string
+}
+
+// ParseField parses a single "%X A000000 ..." line from OEIS's fmt=text
+// search output.
+func ParseField(line string) (Field, error) {
+	matches := fieldRegexp.FindStringSubmatch(line)
+	if len(matches) != 4 {
+		return Field{}, fmt.Errorf("field parse error")
+	}
+	seqId, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return Field{}, fmt.Errorf("field seqId conversion error")
+	}
+	return Field{
+		Key:     matches[1],
+		SeqId:   seqId,
+		Content: matches[3],
+	}, nil
+}
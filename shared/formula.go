@@ -2,7 +2,6 @@ package shared
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 )
 
@@ -70,21 +69,6 @@ type (
 	}
 )
 
-// ParseExpr parses a formula expression string into an AST (Expr).
-// This is a stub; full parsing logic should be implemented as needed.
-func ParseExpr(expr string) Expr {
-	expr = strings.TrimSpace(expr)
-	// For now, just return as ConstExpr or VarExpr if simple, else as raw string ConstExpr
-	if expr == "n" || regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`).MatchString(expr) {
-		return VarExpr{Name: expr}
-	}
-	if regexp.MustCompile(`^-?\d+$`).MatchString(expr) {
-		return ConstExpr{Value: expr}
-	}
-	// TODO: Implement full parser for arithmetic, function calls, etc.
-	return ConstExpr{Value: expr}
-}
-
 // ParseFormulaLine parses a single line from formula.txt into a Formula struct.
 func ParseFormulaLine(line string) (*Formula, error) {
 	// Remove comments and trim
@@ -95,16 +79,17 @@ func ParseFormulaLine(line string) (*Formula, error) {
 	// Split by commas, but only at top-level (not inside parentheses)
 	parts := splitTopLevel(line, ',')
 	var partsOrder []FormulaPart
-	assignRe := regexp.MustCompile(`^(.+?)\s*=\s*(.+)$`)
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
-		m := assignRe.FindStringSubmatch(part)
-		if m == nil {
-			return nil, fmt.Errorf("unrecognized formula part: %q", part)
+		e, err := ParseExpr(part)
+		if err != nil {
+			return nil, fmt.Errorf("formula part %q: %w", part, err)
+		}
+		assign, ok := e.(AssignExpr)
+		if !ok {
+			return nil, fmt.Errorf("formula part %q is not an assignment", part)
 		}
-		lhs := ParseExpr(m[1])
-		rhs := ParseExpr(m[2])
-		partsOrder = append(partsOrder, FormulaPart{LHS: lhs, RHS: rhs})
+		partsOrder = append(partsOrder, FormulaPart{LHS: assign.LHS, RHS: assign.RHS})
 	}
 	return &Formula{Parts: partsOrder}, nil
 }
@@ -172,6 +157,9 @@ func ExprToString(e Expr) string {
 		// Add parentheses for clarity
 		return fmt.Sprintf("(%s%s%s)", ExprToString(v.Left), v.Op, ExprToString(v.Right))
 	case UnaryExpr:
+		if isWordOp(v.Op) {
+			return fmt.Sprintf("%s %s", v.Op, ExprToString(v.Expr))
+		}
 		return fmt.Sprintf("%s%s", v.Op, ExprToString(v.Expr))
 	case AssignExpr:
 		return fmt.Sprintf("%s = %s", ExprToString(v.LHS), ExprToString(v.RHS))
@@ -184,3 +172,10 @@ func ExprToString(e Expr) string {
 		return "?"
 	}
 }
+
+// isWordOp reports whether op is spelled as a word (e.g. "abs") rather than
+// a symbol (e.g. "-"), so callers know whether to separate it from its
+// operand with a space.
+func isWordOp(op string) bool {
+	return len(op) > 0 && (op[0] >= 'a' && op[0] <= 'z' || op[0] >= 'A' && op[0] <= 'Z')
+}
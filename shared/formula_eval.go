@@ -0,0 +1,453 @@
+package shared
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// generalRule is a recurrence of the form a(n) = ... extracted from a
+// Formula's parts: the part's RHS, plus the name the index variable (n)
+// is bound to while evaluating it.
+type generalRule struct {
+	indexVar string
+	rhs      Expr
+}
+
+// EvaluateFormula evaluates a parsed Formula in-process using math/big,
+// computing the first n terms of its primary sequence: the one for which a
+// recurrence a(<var>) = ... is defined, following the OEIS convention that
+// the main sequence is named "a" and is the first such recurrence in the
+// formula. It understands initial conditions of the form a(0) = ..., a(1) =
+// ..., and the builtins binomial, floor, ceiling, mod, gcd, abs, sign,
+// factorial, min and max. It returns an error for any construct it doesn't support
+// (e.g. multiple independent recurrences, non-integer terms), so that
+// callers can fall back to evaluating the program itself.
+func EvaluateFormula(f *Formula, n int) ([]*big.Int, error) {
+	if f == nil || len(f.Parts) == 0 {
+		return nil, fmt.Errorf("empty formula")
+	}
+
+	generals := map[string]generalRule{}
+	initials := map[string]map[int64]Expr{}
+	var primary string
+
+	for _, part := range f.Parts {
+		lhs, ok := part.LHS.(IndexedVarExpr)
+		if !ok {
+			return nil, fmt.Errorf("unsupported formula LHS %#v", part.LHS)
+		}
+		switch idx := lhs.Index.(type) {
+		case VarExpr:
+			if _, exists := generals[lhs.Name]; exists {
+				return nil, fmt.Errorf("multiple recurrences defined for %s", lhs.Name)
+			}
+			generals[lhs.Name] = generalRule{indexVar: idx.Name, rhs: part.RHS}
+			if primary == "" {
+				primary = lhs.Name
+			}
+		case ConstExpr:
+			i, err := constToInt64(idx)
+			if err != nil {
+				return nil, fmt.Errorf("initial condition index: %w", err)
+			}
+			if initials[lhs.Name] == nil {
+				initials[lhs.Name] = map[int64]Expr{}
+			}
+			initials[lhs.Name][i] = part.RHS
+		default:
+			return nil, fmt.Errorf("unsupported formula LHS index %#v", lhs.Index)
+		}
+	}
+	if primary == "" {
+		return nil, fmt.Errorf("formula defines no recurrence a(n) = ...")
+	}
+
+	ev := &formulaEvaluator{generals: generals, initials: initials, memo: map[string]map[int64]*big.Int{}}
+	terms := make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		t, err := ev.term(primary, int64(i))
+		if err != nil {
+			return nil, err
+		}
+		terms[i] = t
+	}
+	return terms, nil
+}
+
+// formulaEvaluator holds the recurrences and initial conditions of a Formula
+// plus a memo of already-computed terms, so that recurrences are evaluated
+// with linear rather than exponential work.
+type formulaEvaluator struct {
+	generals map[string]generalRule
+	initials map[string]map[int64]Expr
+	memo     map[string]map[int64]*big.Int
+}
+
+func (ev *formulaEvaluator) term(name string, index int64) (*big.Int, error) {
+	if t, ok := ev.memo[name][index]; ok {
+		return t, nil
+	}
+	var rhs Expr
+	var vars map[string]int64
+	if cond, ok := ev.initials[name][index]; ok {
+		rhs = cond
+	} else {
+		if index < 0 {
+			return nil, fmt.Errorf("%s(%d): negative index has no defined value", name, index)
+		}
+		rule, ok := ev.generals[name]
+		if !ok {
+			return nil, fmt.Errorf("no recurrence or initial condition defined for %s(%d)", name, index)
+		}
+		rhs = rule.rhs
+		vars = map[string]int64{rule.indexVar: index}
+	}
+	v, err := ev.eval(rhs, vars)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := asInt(v)
+	if !ok {
+		return nil, fmt.Errorf("%s(%d) is not an integer: %s", name, index, v.String())
+	}
+	if ev.memo[name] == nil {
+		ev.memo[name] = map[int64]*big.Int{}
+	}
+	ev.memo[name][index] = result
+	return result, nil
+}
+
+func (ev *formulaEvaluator) eval(e Expr, vars map[string]int64) (*big.Rat, error) {
+	switch v := e.(type) {
+	case ConstExpr:
+		r, ok := new(big.Rat).SetString(v.Value)
+		if !ok {
+			return nil, fmt.Errorf("invalid numeric constant %q", v.Value)
+		}
+		return r, nil
+	case VarExpr:
+		i, ok := vars[v.Name]
+		if !ok {
+			return nil, fmt.Errorf("unbound variable %q", v.Name)
+		}
+		return new(big.Rat).SetInt64(i), nil
+	case IndexedVarExpr:
+		idxVal, err := ev.eval(v.Index, vars)
+		if err != nil {
+			return nil, err
+		}
+		idx, ok := asInt(idxVal)
+		if !ok {
+			return nil, fmt.Errorf("index of %s(...) is not an integer", v.Name)
+		}
+		t, err := ev.term(v.Name, idx.Int64())
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt(t), nil
+	case FuncCallExpr:
+		return ev.evalFunc(v, vars)
+	case BinaryExpr:
+		l, err := ev.eval(v.Left, vars)
+		if err != nil {
+			return nil, err
+		}
+		r, err := ev.eval(v.Right, vars)
+		if err != nil {
+			return nil, err
+		}
+		return evalBinaryOp(v.Op, l, r)
+	case UnaryExpr:
+		x, err := ev.eval(v.Expr, vars)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Op {
+		case "-":
+			return new(big.Rat).Neg(x), nil
+		case "+":
+			return x, nil
+		case "abs":
+			return new(big.Rat).Abs(x), nil
+		default:
+			return nil, fmt.Errorf("unsupported unary operator %q", v.Op)
+		}
+	case CompareExpr:
+		l, err := ev.eval(v.Left, vars)
+		if err != nil {
+			return nil, err
+		}
+		r, err := ev.eval(v.Right, vars)
+		if err != nil {
+			return nil, err
+		}
+		if !compareOp(v.Op, l.Cmp(r)) {
+			return big.NewRat(0, 1), nil
+		}
+		return big.NewRat(1, 1), nil
+	case IfExpr:
+		cond, err := ev.eval(v.Cond, vars)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Sign() != 0 {
+			return ev.eval(v.Then, vars)
+		}
+		return ev.eval(v.Else, vars)
+	default:
+		return nil, fmt.Errorf("unsupported expression node %T", e)
+	}
+}
+
+func (ev *formulaEvaluator) evalFunc(v FuncCallExpr, vars map[string]int64) (*big.Rat, error) {
+	args := make([]*big.Rat, len(v.Args))
+	for i, a := range v.Args {
+		r, err := ev.eval(a, vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = r
+	}
+	return evalBuiltin(v.FuncName, args)
+}
+
+// evalBuiltin evaluates the already-evaluated args of a function call named
+// name. It's the builtin table shared by formulaEvaluator.evalFunc and
+// exprEvaluator.evalFuncCall, so the two formula-evaluation entry points
+// (EvaluateFormula's whole-Formula driver and Eval's standalone Expr
+// primitive) agree on what binomial, floor, ceiling, mod, gcd, abs,
+// factorial, sign, min and max mean.
+func evalBuiltin(name string, args []*big.Rat) (*big.Rat, error) {
+	intArg := func(i int) (*big.Int, error) {
+		n, ok := asInt(args[i])
+		if !ok {
+			return nil, fmt.Errorf("%s: argument %d is not an integer", name, i)
+		}
+		return n, nil
+	}
+	switch name {
+	case "binomial":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("binomial expects 2 arguments, got %d", len(args))
+		}
+		n, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		k, err := intArg(1)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt(new(big.Int).Binomial(n.Int64(), k.Int64())), nil
+	case "floor":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("floor expects 1 argument, got %d", len(args))
+		}
+		return new(big.Rat).SetInt(ratFloor(args[0])), nil
+	case "ceiling", "ceil":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s expects 1 argument, got %d", name, len(args))
+		}
+		return new(big.Rat).SetInt(ratCeil(args[0])), nil
+	case "mod":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("mod expects 2 arguments, got %d", len(args))
+		}
+		a, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		m, err := intArg(1)
+		if err != nil {
+			return nil, err
+		}
+		if m.Sign() == 0 {
+			return nil, fmt.Errorf("mod by zero")
+		}
+		return new(big.Rat).SetInt(new(big.Int).Mod(a, m)), nil
+	case "gcd":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("gcd expects 2 arguments, got %d", len(args))
+		}
+		a, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		b, err := intArg(1)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt(gcdInt(a, b)), nil
+	case "abs":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("abs expects 1 argument, got %d", len(args))
+		}
+		return new(big.Rat).Abs(args[0]), nil
+	case "sign":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("sign expects 1 argument, got %d", len(args))
+		}
+		return big.NewRat(int64(args[0].Sign()), 1), nil
+	case "factorial":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("factorial expects 1 argument, got %d", len(args))
+		}
+		x, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		f, err := factorialInt(x)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt(f), nil
+	case "min":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("min expects at least 1 argument")
+		}
+		m := args[0]
+		for _, a := range args[1:] {
+			if a.Cmp(m) < 0 {
+				m = a
+			}
+		}
+		return m, nil
+	case "max":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("max expects at least 1 argument")
+		}
+		m := args[0]
+		for _, a := range args[1:] {
+			if a.Cmp(m) > 0 {
+				m = a
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported function %q", name)
+	}
+}
+
+func compareOp(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+func evalBinaryOp(op string, l, r *big.Rat) (*big.Rat, error) {
+	switch op {
+	case "+":
+		return new(big.Rat).Add(l, r), nil
+	case "-":
+		return new(big.Rat).Sub(l, r), nil
+	case "*":
+		return new(big.Rat).Mul(l, r), nil
+	case "/":
+		if r.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return new(big.Rat).Quo(l, r), nil
+	case "%":
+		li, ok := asInt(l)
+		ri, ok2 := asInt(r)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%% requires integer operands")
+		}
+		if ri.Sign() == 0 {
+			return nil, fmt.Errorf("%% by zero")
+		}
+		return new(big.Rat).SetInt(new(big.Int).Mod(li, ri)), nil
+	case "^":
+		exp, ok := asInt(r)
+		if !ok {
+			return nil, fmt.Errorf("^ requires an integer exponent")
+		}
+		return ratPow(l, exp.Int64())
+	default:
+		return nil, fmt.Errorf("unsupported binary operator %q", op)
+	}
+}
+
+// ratPow raises base to the non-negative integer power exp using repeated
+// squaring, since big.Rat has no built-in Exp.
+func ratPow(base *big.Rat, exp int64) (*big.Rat, error) {
+	if exp < 0 {
+		return nil, fmt.Errorf("negative exponents are not supported")
+	}
+	result := big.NewRat(1, 1)
+	b := new(big.Rat).Set(base)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result.Mul(result, b)
+		}
+		b.Mul(b, b)
+		exp >>= 1
+	}
+	return result, nil
+}
+
+// asInt reports whether r holds an exact integer value, returning it as a
+// big.Int if so.
+func asInt(r *big.Rat) (*big.Int, bool) {
+	if r.IsInt() {
+		return new(big.Int).Set(r.Num()), true
+	}
+	return nil, false
+}
+
+// ratFloor returns the largest integer <= r, using big.Int's Euclidean
+// DivMod (whose remainder is always non-negative since Denom() is always
+// positive).
+func ratFloor(r *big.Rat) *big.Int {
+	q, m := new(big.Int), new(big.Int)
+	q.DivMod(r.Num(), r.Denom(), m)
+	return q
+}
+
+func ratCeil(r *big.Rat) *big.Int {
+	f := ratFloor(r)
+	if new(big.Rat).SetInt(f).Cmp(r) == 0 {
+		return f
+	}
+	return new(big.Int).Add(f, big.NewInt(1))
+}
+
+func gcdInt(a, b *big.Int) *big.Int {
+	x, y := new(big.Int).Abs(a), new(big.Int).Abs(b)
+	for y.Sign() != 0 {
+		x, y = y, new(big.Int).Mod(x, y)
+	}
+	return x
+}
+
+func factorialInt(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, fmt.Errorf("factorial of a negative number is undefined")
+	}
+	result := big.NewInt(1)
+	for i := big.NewInt(2); i.Cmp(n) <= 0; i.Add(i, big.NewInt(1)) {
+		result.Mul(result, i)
+	}
+	return result, nil
+}
+
+func constToInt64(c ConstExpr) (int64, error) {
+	i, ok := new(big.Int).SetString(c.Value, 10)
+	if !ok {
+		return 0, fmt.Errorf("invalid integer constant %q", c.Value)
+	}
+	return i.Int64(), nil
+}
@@ -0,0 +1,85 @@
+package shared
+
+import (
+	"math/big"
+	"testing"
+)
+
+func bigInts(vals ...int64) []*big.Int {
+	out := make([]*big.Int, len(vals))
+	for i, v := range vals {
+		out[i] = big.NewInt(v)
+	}
+	return out
+}
+
+func TestEvaluateFormula(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		n    int
+		want []*big.Int
+	}{
+		{
+			"fibonacci",
+			"a(n) = a(n-1)+a(n-2), a(0) = 0, a(1) = 1",
+			10,
+			bigInts(0, 1, 1, 2, 3, 5, 8, 13, 21, 34),
+		},
+		{
+			"triangular numbers",
+			"a(n) = n*(n+1)/2",
+			8,
+			bigInts(0, 1, 3, 6, 10, 15, 21, 28),
+		},
+		{
+			"factorial via if-then-else",
+			"a(n) = if n==0 then 1 else n*a(n-1)",
+			8,
+			bigInts(1, 1, 2, 6, 24, 120, 720, 5040),
+		},
+		{
+			"central binomial coefficients",
+			"a(n) = binomial(2*n,n)",
+			6,
+			bigInts(1, 2, 6, 20, 70, 252),
+		},
+		{
+			"floor and ceiling",
+			"a(n) = floor(n/2)+ceiling(n/3)",
+			7,
+			bigInts(0, 1, 2, 2, 4, 4, 5),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFormulaLine(tt.line)
+			if err != nil {
+				t.Fatalf("ParseFormulaLine(%q) failed: %v", tt.line, err)
+			}
+			got, err := EvaluateFormula(f, tt.n)
+			if err != nil {
+				t.Fatalf("EvaluateFormula failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d terms, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Cmp(tt.want[i]) != 0 {
+					t.Errorf("term %d = %s, want %s", i, got[i].String(), tt.want[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateFormula_UnsupportedFallsBack(t *testing.T) {
+	// No recurrence or initial condition defined for "a" at all.
+	f, err := ParseFormulaLine("b(n) = a(n-1)")
+	if err != nil {
+		t.Fatalf("ParseFormulaLine failed: %v", err)
+	}
+	if _, err := EvaluateFormula(f, 3); err == nil {
+		t.Error("expected an error for a formula referencing an undefined sequence")
+	}
+}
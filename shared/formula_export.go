@@ -0,0 +1,341 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LaTeX returns the Formula rendered as a LaTeX math expression, e.g. for
+// embedding in a rendered OEIS page.
+func (f *Formula) LaTeX() string {
+	if f == nil {
+		return ""
+	}
+	var out []string
+	for _, p := range f.Parts {
+		out = append(out, fmt.Sprintf("%s = %s", ExprToLaTeX(p.LHS), ExprToLaTeX(p.RHS)))
+	}
+	return strings.Join(out, ", \\quad ")
+}
+
+// Pari returns the Formula rendered as a PARI/GP expression.
+func (f *Formula) Pari() string {
+	if f == nil {
+		return ""
+	}
+	var out []string
+	for _, p := range f.Parts {
+		out = append(out, fmt.Sprintf("%s = %s", ExprToPari(p.LHS), ExprToPari(p.RHS)))
+	}
+	return strings.Join(out, "; ")
+}
+
+// Mathematica returns the Formula rendered as a Mathematica expression.
+func (f *Formula) Mathematica() string {
+	if f == nil {
+		return ""
+	}
+	var out []string
+	for _, p := range f.Parts {
+		out = append(out, fmt.Sprintf("%s == %s", ExprToMathematica(p.LHS), ExprToMathematica(p.RHS)))
+	}
+	return strings.Join(out, ", ")
+}
+
+// SymPy returns the Formula rendered as Python source using SymPy's symbolic
+// functions (Rational, binomial, floor, Piecewise, ...).
+func (f *Formula) SymPy() string {
+	if f == nil {
+		return ""
+	}
+	var out []string
+	for _, p := range f.Parts {
+		out = append(out, fmt.Sprintf("%s = %s", ExprToSymPy(p.LHS), ExprToSymPy(p.RHS)))
+	}
+	return strings.Join(out, "; ")
+}
+
+// mathematicaFuncNames maps formula function names to Mathematica's
+// capitalized built-in names.
+var mathematicaFuncNames = map[string]string{
+	"floor":     "Floor",
+	"ceil":      "Ceiling",
+	"abs":       "Abs",
+	"gcd":       "GCD",
+	"lcm":       "LCM",
+	"binomial":  "Binomial",
+	"factorial": "Factorial",
+	"mod":       "Mod",
+	"sqrt":      "Sqrt",
+}
+
+// sympyFuncNames maps formula function names to their SymPy spelling.
+var sympyFuncNames = map[string]string{
+	"floor":     "floor",
+	"ceil":      "ceiling",
+	"abs":       "Abs",
+	"gcd":       "gcd",
+	"lcm":       "lcm",
+	"binomial":  "binomial",
+	"factorial": "factorial",
+	"mod":       "Mod",
+	"sqrt":      "sqrt",
+	"min":       "Min",
+	"max":       "Max",
+}
+
+// ExprToLaTeX converts an Expr AST node to a LaTeX math expression.
+func ExprToLaTeX(e Expr) string {
+	switch v := e.(type) {
+	case ConstExpr:
+		return v.Value
+	case VarExpr:
+		return v.Name
+	case IndexedVarExpr:
+		return fmt.Sprintf("%s(%s)", v.Name, ExprToLaTeX(v.Index))
+	case FuncCallExpr:
+		if v.FuncName == "floor" && len(v.Args) == 1 {
+			return fmt.Sprintf("\\lfloor %s \\rfloor", ExprToLaTeX(v.Args[0]))
+		}
+		if v.FuncName == "ceil" && len(v.Args) == 1 {
+			return fmt.Sprintf("\\lceil %s \\rceil", ExprToLaTeX(v.Args[0]))
+		}
+		if v.FuncName == "binomial" && len(v.Args) == 2 {
+			return fmt.Sprintf("\\binom{%s}{%s}", ExprToLaTeX(v.Args[0]), ExprToLaTeX(v.Args[1]))
+		}
+		var args []string
+		for _, arg := range v.Args {
+			args = append(args, ExprToLaTeX(arg))
+		}
+		return fmt.Sprintf("\\operatorname{%s}(%s)", v.FuncName, strings.Join(args, ", "))
+	case BinaryExpr:
+		if v.Op == "/" {
+			return fmt.Sprintf("\\frac{%s}{%s}", ExprToLaTeX(v.Left), ExprToLaTeX(v.Right))
+		}
+		left, right := ExprToLaTeX(v.Left), ExprToLaTeX(v.Right)
+		if formulaNeedsParens(v.Left) {
+			left = "(" + left + ")"
+		}
+		if formulaNeedsParens(v.Right) {
+			right = "(" + right + ")"
+		}
+		switch v.Op {
+		case "*":
+			return fmt.Sprintf("%s \\cdot %s", left, right)
+		case "^":
+			return fmt.Sprintf("%s^{%s}", left, ExprToLaTeX(v.Right))
+		default:
+			return fmt.Sprintf("%s %s %s", left, v.Op, right)
+		}
+	case UnaryExpr:
+		if v.Op == "abs" {
+			return fmt.Sprintf("\\left| %s \\right|", ExprToLaTeX(v.Expr))
+		}
+		expr := ExprToLaTeX(v.Expr)
+		if formulaNeedsParens(v.Expr) {
+			expr = "(" + expr + ")"
+		}
+		return fmt.Sprintf("%s%s", v.Op, expr)
+	case AssignExpr:
+		return fmt.Sprintf("%s = %s", ExprToLaTeX(v.LHS), ExprToLaTeX(v.RHS))
+	case CompareExpr:
+		return fmt.Sprintf("%s %s %s", ExprToLaTeX(v.Left), latexCompareOp(v.Op), ExprToLaTeX(v.Right))
+	case IfExpr:
+		return fmt.Sprintf("\\begin{cases} %s & \\text{if } %s \\\\ %s & \\text{otherwise} \\end{cases}",
+			ExprToLaTeX(v.Then), ExprToLaTeX(v.Cond), ExprToLaTeX(v.Else))
+	default:
+		return "?"
+	}
+}
+
+func latexCompareOp(op string) string {
+	switch op {
+	case "==":
+		return "="
+	case "!=":
+		return "\\neq"
+	case "<=":
+		return "\\leq"
+	case ">=":
+		return "\\geq"
+	default:
+		return op
+	}
+}
+
+// ExprToPari converts an Expr AST node to a PARI/GP expression.
+func ExprToPari(e Expr) string {
+	switch v := e.(type) {
+	case ConstExpr:
+		return v.Value
+	case VarExpr:
+		return v.Name
+	case IndexedVarExpr:
+		return fmt.Sprintf("%s(%s)", v.Name, ExprToPari(v.Index))
+	case FuncCallExpr:
+		// PARI idiomatically writes floor(a/b) as the euclidean division
+		// operator a\b rather than calling floor() on a rational.
+		if v.FuncName == "floor" && len(v.Args) == 1 {
+			if bin, ok := v.Args[0].(BinaryExpr); ok && bin.Op == "/" {
+				left, right := ExprToPari(bin.Left), ExprToPari(bin.Right)
+				if formulaNeedsParens(bin.Left) {
+					left = "(" + left + ")"
+				}
+				if formulaNeedsParens(bin.Right) {
+					right = "(" + right + ")"
+				}
+				return fmt.Sprintf("%s\\%s", left, right)
+			}
+		}
+		var args []string
+		for _, arg := range v.Args {
+			args = append(args, ExprToPari(arg))
+		}
+		return fmt.Sprintf("%s(%s)", v.FuncName, strings.Join(args, ", "))
+	case BinaryExpr:
+		left, right := ExprToPari(v.Left), ExprToPari(v.Right)
+		if formulaNeedsParens(v.Left) {
+			left = "(" + left + ")"
+		}
+		if formulaNeedsParens(v.Right) {
+			right = "(" + right + ")"
+		}
+		return fmt.Sprintf("%s%s%s", left, v.Op, right)
+	case UnaryExpr:
+		if v.Op == "abs" {
+			return fmt.Sprintf("abs(%s)", ExprToPari(v.Expr))
+		}
+		expr := ExprToPari(v.Expr)
+		if formulaNeedsParens(v.Expr) {
+			expr = "(" + expr + ")"
+		}
+		return fmt.Sprintf("%s%s", v.Op, expr)
+	case AssignExpr:
+		return fmt.Sprintf("%s = %s", ExprToPari(v.LHS), ExprToPari(v.RHS))
+	case CompareExpr:
+		return fmt.Sprintf("%s%s%s", ExprToPari(v.Left), v.Op, ExprToPari(v.Right))
+	case IfExpr:
+		return fmt.Sprintf("if(%s, %s, %s)", ExprToPari(v.Cond), ExprToPari(v.Then), ExprToPari(v.Else))
+	default:
+		return "?"
+	}
+}
+
+// ExprToMathematica converts an Expr AST node to a Mathematica expression.
+func ExprToMathematica(e Expr) string {
+	switch v := e.(type) {
+	case ConstExpr:
+		return v.Value
+	case VarExpr:
+		return v.Name
+	case IndexedVarExpr:
+		return fmt.Sprintf("%s[%s]", v.Name, ExprToMathematica(v.Index))
+	case FuncCallExpr:
+		var args []string
+		for _, arg := range v.Args {
+			args = append(args, ExprToMathematica(arg))
+		}
+		name, ok := mathematicaFuncNames[v.FuncName]
+		if !ok {
+			name = v.FuncName
+		}
+		return fmt.Sprintf("%s[%s]", name, strings.Join(args, ", "))
+	case BinaryExpr:
+		left, right := ExprToMathematica(v.Left), ExprToMathematica(v.Right)
+		if formulaNeedsParens(v.Left) {
+			left = "(" + left + ")"
+		}
+		if formulaNeedsParens(v.Right) {
+			right = "(" + right + ")"
+		}
+		op := v.Op
+		if op == "^" {
+			return fmt.Sprintf("%s^%s", left, right)
+		}
+		return fmt.Sprintf("%s%s%s", left, op, right)
+	case UnaryExpr:
+		if v.Op == "abs" {
+			return fmt.Sprintf("Abs[%s]", ExprToMathematica(v.Expr))
+		}
+		expr := ExprToMathematica(v.Expr)
+		if formulaNeedsParens(v.Expr) {
+			expr = "(" + expr + ")"
+		}
+		return fmt.Sprintf("%s%s", v.Op, expr)
+	case AssignExpr:
+		return fmt.Sprintf("%s == %s", ExprToMathematica(v.LHS), ExprToMathematica(v.RHS))
+	case CompareExpr:
+		// Mathematica's comparison operators are spelled the same way.
+		return fmt.Sprintf("%s%s%s", ExprToMathematica(v.Left), v.Op, ExprToMathematica(v.Right))
+	case IfExpr:
+		return fmt.Sprintf("If[%s, %s, %s]", ExprToMathematica(v.Cond), ExprToMathematica(v.Then), ExprToMathematica(v.Else))
+	default:
+		return "?"
+	}
+}
+
+// ExprToSymPy converts an Expr AST node to a SymPy expression.
+func ExprToSymPy(e Expr) string {
+	switch v := e.(type) {
+	case ConstExpr:
+		return v.Value
+	case VarExpr:
+		return v.Name
+	case IndexedVarExpr:
+		return fmt.Sprintf("%s(%s)", v.Name, ExprToSymPy(v.Index))
+	case FuncCallExpr:
+		var args []string
+		for _, arg := range v.Args {
+			args = append(args, ExprToSymPy(arg))
+		}
+		name, ok := sympyFuncNames[v.FuncName]
+		if !ok {
+			name = v.FuncName
+		}
+		return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+	case BinaryExpr:
+		if v.Op == "/" {
+			return fmt.Sprintf("Rational(%s, %s)", ExprToSymPy(v.Left), ExprToSymPy(v.Right))
+		}
+		left, right := ExprToSymPy(v.Left), ExprToSymPy(v.Right)
+		if formulaNeedsParens(v.Left) {
+			left = "(" + left + ")"
+		}
+		if formulaNeedsParens(v.Right) {
+			right = "(" + right + ")"
+		}
+		op := v.Op
+		if op == "^" {
+			op = "**"
+		}
+		return fmt.Sprintf("%s%s%s", left, op, right)
+	case UnaryExpr:
+		if v.Op == "abs" {
+			return fmt.Sprintf("Abs(%s)", ExprToSymPy(v.Expr))
+		}
+		expr := ExprToSymPy(v.Expr)
+		if formulaNeedsParens(v.Expr) {
+			expr = "(" + expr + ")"
+		}
+		return fmt.Sprintf("%s%s", v.Op, expr)
+	case AssignExpr:
+		return fmt.Sprintf("%s = %s", ExprToSymPy(v.LHS), ExprToSymPy(v.RHS))
+	case CompareExpr:
+		return fmt.Sprintf("%s%s%s", ExprToSymPy(v.Left), v.Op, ExprToSymPy(v.Right))
+	case IfExpr:
+		return fmt.Sprintf("Piecewise((%s, %s), (%s, True))", ExprToSymPy(v.Then), ExprToSymPy(v.Cond), ExprToSymPy(v.Else))
+	default:
+		return "?"
+	}
+}
+
+// formulaNeedsParens returns true if e should be parenthesized when used as
+// a subexpression of a binary or unary operation.
+func formulaNeedsParens(e Expr) bool {
+	switch e.(type) {
+	case BinaryExpr, CompareExpr, UnaryExpr, AssignExpr, IfExpr:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package shared
+
+import "testing"
+
+func TestFormulaLaTeX(t *testing.T) {
+	// a(n) = binomial(2*n,n)/(n+1)
+	f := &Formula{Parts: []FormulaPart{{
+		LHS: IndexedVarExpr{Name: "a", Index: VarExpr{Name: "n"}},
+		RHS: BinaryExpr{
+			Op: "/",
+			Left: FuncCallExpr{FuncName: "binomial", Args: []Expr{
+				BinaryExpr{Op: "*", Left: ConstExpr{Value: "2"}, Right: VarExpr{Name: "n"}},
+				VarExpr{Name: "n"},
+			}},
+			Right: BinaryExpr{Op: "+", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "1"}},
+		},
+	}}}
+	want := "a(n) = \\frac{\\binom{2 \\cdot n}{n}}{n + 1}"
+	if got := f.LaTeX(); got != want {
+		t.Errorf("LaTeX() = %q, want %q", got, want)
+	}
+}
+
+func TestFormulaPari(t *testing.T) {
+	// a(n) = a(n-1)+a(n-2)
+	f := &Formula{Parts: []FormulaPart{{
+		LHS: IndexedVarExpr{Name: "a", Index: VarExpr{Name: "n"}},
+		RHS: BinaryExpr{
+			Op:   "+",
+			Left: IndexedVarExpr{Name: "a", Index: BinaryExpr{Op: "-", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "1"}}},
+			Right: IndexedVarExpr{Name: "a", Index: BinaryExpr{Op: "-", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "2"}}},
+		},
+	}}}
+	want := "a(n) = a(n-1)+a(n-2)"
+	if got := f.Pari(); got != want {
+		t.Errorf("Pari() = %q, want %q", got, want)
+	}
+}
+
+func TestFormulaMathematica(t *testing.T) {
+	// a(n) = floor(n/2)
+	f := &Formula{Parts: []FormulaPart{{
+		LHS: IndexedVarExpr{Name: "a", Index: VarExpr{Name: "n"}},
+		RHS: FuncCallExpr{FuncName: "floor", Args: []Expr{
+			BinaryExpr{Op: "/", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "2"}},
+		}},
+	}}}
+	want := "a[n] == Floor[n/2]"
+	if got := f.Mathematica(); got != want {
+		t.Errorf("Mathematica() = %q, want %q", got, want)
+	}
+}
+
+func TestFormulaSymPy(t *testing.T) {
+	// a(n) = binomial(2*n,n)/(n+1)
+	f := &Formula{Parts: []FormulaPart{{
+		LHS: IndexedVarExpr{Name: "a", Index: VarExpr{Name: "n"}},
+		RHS: BinaryExpr{
+			Op: "/",
+			Left: FuncCallExpr{FuncName: "binomial", Args: []Expr{
+				BinaryExpr{Op: "*", Left: ConstExpr{Value: "2"}, Right: VarExpr{Name: "n"}},
+				VarExpr{Name: "n"},
+			}},
+			Right: BinaryExpr{Op: "+", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "1"}},
+		},
+	}}}
+	want := "a(n) = Rational(binomial(2*n, n), n+1)"
+	if got := f.SymPy(); got != want {
+		t.Errorf("SymPy() = %q, want %q", got, want)
+	}
+}
+
+func TestExprToPariFloorDiv(t *testing.T) {
+	// a(n) = floor(n/2), which PARI/GP idiomatically writes as n\2.
+	e := FuncCallExpr{FuncName: "floor", Args: []Expr{
+		BinaryExpr{Op: "/", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "2"}},
+	}}
+	want := "n\\2"
+	if got := ExprToPari(e); got != want {
+		t.Errorf("ExprToPari() = %q, want %q", got, want)
+	}
+}
+
+func TestFormulaPrinters(t *testing.T) {
+	// a(n) = floor(n/2)
+	f := &Formula{Parts: []FormulaPart{{
+		LHS: IndexedVarExpr{Name: "a", Index: VarExpr{Name: "n"}},
+		RHS: FuncCallExpr{FuncName: "floor", Args: []Expr{
+			BinaryExpr{Op: "/", Left: VarExpr{Name: "n"}, Right: ConstExpr{Value: "2"}},
+		}},
+	}}}
+	tests := []struct {
+		name    string
+		printer FormulaPrinter
+		want    string
+	}{
+		{"pari", PariPrinter{}, "a(n) = n\\2"},
+		{"mathematica", MathematicaPrinter{}, "a[n] == Floor[n/2]"},
+		{"latex", LatexPrinter{}, "a(n) = \\lfloor \\frac{n}{2} \\rfloor"},
+		{"sympy", SymPyPrinter{}, "a(n) = floor(Rational(n, 2))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.printer.Print(f)
+			if err != nil {
+				t.Fatalf("Print() returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Print() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if _, err := (PariPrinter{}).Print(nil); err == nil {
+		t.Error("Print(nil) should return an error")
+	}
+}
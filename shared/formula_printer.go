@@ -0,0 +1,51 @@
+package shared
+
+import "fmt"
+
+// FormulaPrinter renders a parsed Formula as source code for some target
+// language or computer algebra system. It lets callers like the export
+// command pick a renderer by name instead of switching on format strings
+// themselves.
+type FormulaPrinter interface {
+	Print(f *Formula) (string, error)
+}
+
+// PariPrinter renders a Formula as a PARI/GP expression.
+type PariPrinter struct{}
+
+func (PariPrinter) Print(f *Formula) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("nil formula")
+	}
+	return f.Pari(), nil
+}
+
+// MathematicaPrinter renders a Formula as a Mathematica expression.
+type MathematicaPrinter struct{}
+
+func (MathematicaPrinter) Print(f *Formula) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("nil formula")
+	}
+	return f.Mathematica(), nil
+}
+
+// LatexPrinter renders a Formula as a LaTeX math expression.
+type LatexPrinter struct{}
+
+func (LatexPrinter) Print(f *Formula) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("nil formula")
+	}
+	return f.LaTeX(), nil
+}
+
+// SymPyPrinter renders a Formula as SymPy Python source.
+type SymPyPrinter struct{}
+
+func (SymPyPrinter) Print(f *Formula) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("nil formula")
+	}
+	return f.SymPy(), nil
+}
@@ -2,11 +2,12 @@ package shared
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -58,9 +59,16 @@ func (l *List) Update(fields []Field) {
 	}
 }
 
-func (l *List) Flush(deduplicate bool) error {
+// Flush merges the buffered fields into the list file on disk, recompresses
+// it, and regenerates its fanout index. ctx bounds the merge: if it is
+// cancelled or its deadline expires, Flush stops at the next SeqId boundary
+// and returns ctx.Err(), leaving the buffered fields intact for a retry.
+func (l *List) Flush(ctx context.Context, deduplicate bool) error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	log.Printf("Flushing %s", l.name)
 	// Check and sort fields
 	if len(l.fields) == 0 {
@@ -91,7 +99,7 @@ func (l *List) Flush(deduplicate bool) error {
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	err = mergeLists(l.fields, old, target, deduplicate)
+	err = mergeLists(ctx, l.fields, old, target, deduplicate)
 	target.Close()
 	old.Close()
 	os.Remove(oldPath)
@@ -99,29 +107,46 @@ func (l *List) Flush(deduplicate bool) error {
 		return fmt.Errorf("failed to merge lists: %w", err)
 	}
 	// Compress new file
-	err = exec.Command("gzip", "-f", "-k", path).Run()
-	if err != nil {
+	if err := util.CompressFileKeep(path); err != nil {
 		return fmt.Errorf("failed to gzip file: %w", err)
 	}
+	// Regenerate the fanout index alongside the text file
+	if err := writeListIndex(path); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
 	l.fields = nil
 	return nil
 }
 
-func (l *List) FindMissingIds(maxId int, maxNumIds int) ([]int, int, error) {
+// FindMissingIds scans the list for SeqIds missing from [1, maxId], stopping
+// early once maxNumIds have been collected. ctx bounds the fallback text-file
+// scan (the indexed path below is fast enough not to need it); a cancelled or
+// expired ctx aborts the scan and returns ctx.Err().
+func (l *List) FindMissingIds(ctx context.Context, maxId int, maxNumIds int) ([]int, int, error) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
 	log.Printf("Finding missing %s", l.name)
 	path := filepath.Join(l.dataDir, l.name)
 	if !util.FileExists(path) {
 		log.Printf("No %s available", l.name)
 		return nil, 0, nil // not an error
 	}
+	// Prefer the fanout index: it already holds the sorted seqIds, so the
+	// gap scan doesn't need to reread and reparse the text file.
+	if entries, _, err := loadListIndex(path); err == nil {
+		ids, numMissing := findMissingIdsFromEntries(entries, maxId, maxNumIds)
+		log.Printf("Found %d/%d missing %s", len(ids), numMissing, l.name)
+		return ids, numMissing, nil
+	}
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
-	ids, numMissing, err := findMissingIds(file, maxId, maxNumIds)
+	ids, numMissing, err := findMissingIds(ctx, file, maxId, maxNumIds)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -161,118 +186,165 @@ func parseContinuationLine(line string) (string, error) {
 	return matches[1], nil
 }
 
-func mergeLists(fields []Field, old, target *os.File, deduplicate bool) error {
-	// Merges fields with old list and writes to target list
-	// If deduplicate is true, remove duplicate entries (same SeqId)
-	// Outputs in multi-line format: first line has "A000000: content", continuation lines have "  content"
+// mergeLists merges fields (already sorted by SeqId, then Content) with the
+// old list read from old, and writes the result to target in multi-line
+// format: first line has "A000000: content", continuation lines have
+// "  content". If deduplicate is true, only one entry is kept per SeqId.
+//
+// Both inputs are consumed as a merge-join over two sorted streams, so
+// memory use is bounded by a single SeqId's worth of entries rather than
+// the whole list, which matters once a list grows into the millions of
+// entries.
+//
+// ctx is checked once per SeqId; a cancelled or expired ctx stops the merge
+// early and returns ctx.Err(), leaving target partially written.
+func mergeLists(ctx context.Context, fields []Field, old, target *os.File, deduplicate bool) error {
+	oldEntries, err := newOldEntryReader(old)
+	if err != nil {
+		return fmt.Errorf("failed reading old list: %w", err)
+	}
+
+	i := 0
+	for oldEntries.hasNext() || i < len(fields) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var seqId int
+		switch {
+		case i >= len(fields):
+			seqId = oldEntries.peekSeqId()
+		case !oldEntries.hasNext():
+			seqId = fields[i].SeqId
+		default:
+			seqId = min(fields[i].SeqId, oldEntries.peekSeqId())
+		}
 
-	// Read all old entries grouped by SeqId
-	oldEntries := make(map[int][]string)
-	scanner := bufio.NewScanner(old)
-	var currentSeqId int = -1
+		var entries []string
+		seen := make(map[string]bool)
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if isContinuationLine(line) {
-			// This is a continuation line
-			if currentSeqId >= 0 {
-				content, err := parseContinuationLine(line)
-				if err != nil {
-					return err
-				}
-				oldEntries[currentSeqId] = append(oldEntries[currentSeqId], content)
+		// New entries take precedence, so merge them in before the old ones.
+		for i < len(fields) && fields[i].SeqId == seqId {
+			if !seen[fields[i].Content] {
+				entries = append(entries, fields[i].Content)
+				seen[fields[i].Content] = true
 			}
-		} else {
-			// This is a new entry
-			f, err := parseLine(line)
+			i++
+		}
+		if oldEntries.hasNext() && oldEntries.peekSeqId() == seqId {
+			oldContents, err := oldEntries.nextGroup()
 			if err != nil {
-				return err
+				return fmt.Errorf("failed reading old list: %w", err)
+			}
+			for _, content := range oldContents {
+				if !seen[content] {
+					entries = append(entries, content)
+					seen[content] = true
+				}
 			}
-			currentSeqId = f.SeqId
-			oldEntries[currentSeqId] = append(oldEntries[currentSeqId], f.Content)
 		}
-	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed reading old list: %w", err)
-	}
-
-	// Group new fields by SeqId
-	newEntries := make(map[int][]string)
-	for _, field := range fields {
-		newEntries[field.SeqId] = append(newEntries[field.SeqId], field.Content)
-	}
 
-	// Merge old and new entries
-	allSeqIds := make(map[int]bool)
-	for seqId := range oldEntries {
-		allSeqIds[seqId] = true
-	}
-	for seqId := range newEntries {
-		allSeqIds[seqId] = true
+		if deduplicate && len(entries) > 1 {
+			entries = entries[:1]
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(target, "A%06d: %s\n", seqId, entries[0]); err != nil {
+			return fmt.Errorf("failed writing field: %w", err)
+		}
+		for _, content := range entries[1:] {
+			if _, err := fmt.Fprintf(target, "  %s\n", content); err != nil {
+				return fmt.Errorf("failed writing continuation: %w", err)
+			}
+		}
 	}
 
-	// Convert to sorted slice
-	var seqIds []int
-	for seqId := range allSeqIds {
-		seqIds = append(seqIds, seqId)
-	}
-	sort.Ints(seqIds)
+	return nil
+}
 
-	// Write merged entries in multi-line format
-	for _, seqId := range seqIds {
-		var entries []string
+// oldEntryReader streams the old list file's entries in ascending SeqId
+// order one group at a time, so mergeLists never has to hold more than the
+// current group's lines in memory.
+type oldEntryReader struct {
+	scanner *bufio.Scanner
+	next    *Field
+}
 
-		// Merge old and new entries for this seqId
-		seen := make(map[string]bool)
+// newOldEntryReader wraps r and reads ahead to the first entry, if any.
+func newOldEntryReader(r io.Reader) (*oldEntryReader, error) {
+	o := &oldEntryReader{scanner: bufio.NewScanner(r)}
+	if err := o.advance(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
 
-		// Add new entries first (so they take precedence when deduplicating)
-		for _, content := range newEntries[seqId] {
-			if !seen[content] {
-				entries = append(entries, content)
-				seen[content] = true
-			}
+// advance reads the next non-continuation line into o.next, or leaves it
+// nil once the stream is exhausted.
+func (o *oldEntryReader) advance() error {
+	for o.scanner.Scan() {
+		line := o.scanner.Text()
+		if isContinuationLine(line) {
+			continue // malformed: a continuation line with no preceding entry
 		}
-
-		// Add old entries
-		for _, content := range oldEntries[seqId] {
-			if !seen[content] {
-				entries = append(entries, content)
-				seen[content] = true
-			}
+		f, err := parseLine(line)
+		if err != nil {
+			return err
 		}
+		o.next = &f
+		return nil
+	}
+	o.next = nil
+	return o.scanner.Err()
+}
 
-		// If deduplicate, keep only one entry
-		if deduplicate && len(entries) > 0 {
-			entries = entries[:1]
-		}
+// hasNext reports whether another group remains to be read.
+func (o *oldEntryReader) hasNext() bool {
+	return o.next != nil
+}
 
-		// Write entries in multi-line format
-		if len(entries) > 0 {
-			// First entry with full prefix
-			_, err := target.WriteString(fmt.Sprintf("A%06d: %s\n", seqId, entries[0]))
-			if err != nil {
-				return fmt.Errorf("failed writing field: %w", err)
-			}
+// peekSeqId returns the SeqId of the next group without consuming it.
+func (o *oldEntryReader) peekSeqId() int {
+	return o.next.SeqId
+}
 
-			// Continuation lines with 2-space indentation
-			for _, content := range entries[1:] {
-				_, err := target.WriteString(fmt.Sprintf("  %s\n", content))
-				if err != nil {
-					return fmt.Errorf("failed writing continuation: %w", err)
-				}
+// nextGroup consumes and returns the next group's contents: the lead
+// line's content followed by any continuation lines.
+func (o *oldEntryReader) nextGroup() ([]string, error) {
+	contents := []string{o.next.Content}
+	for o.scanner.Scan() {
+		line := o.scanner.Text()
+		if isContinuationLine(line) {
+			content, err := parseContinuationLine(line)
+			if err != nil {
+				return nil, err
 			}
+			contents = append(contents, content)
+			continue
 		}
+		f, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		o.next = &f
+		return contents, nil
 	}
-
-	return nil
+	if err := o.scanner.Err(); err != nil {
+		return nil, err
+	}
+	o.next = nil
+	return contents, nil
 }
 
-func findMissingIds(file *os.File, maxId int, maxNumIds int) ([]int, int, error) {
+func findMissingIds(ctx context.Context, file *os.File, maxId int, maxNumIds int) ([]int, int, error) {
 	ids := []int{}
 	nextId := 1
 	numMissing := 0
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
 		line := scanner.Text()
 		// Skip continuation lines
 		if isContinuationLine(line) {
@@ -305,5 +377,5 @@ func findMissingIds(file *os.File, maxId int, maxNumIds int) ([]int, int, error)
 func (l *List) ServeGzip(w http.ResponseWriter, r *http.Request) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	util.ServeBinary(w, r, filepath.Join(l.dataDir, l.name+".gz"))
+	util.ServeCompressedFile(w, r, filepath.Join(l.dataDir, l.name+".gz"))
 }
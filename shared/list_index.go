@@ -0,0 +1,445 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// On-disk layout of a list's companion ".idx" file, modeled on the fanout
+// table in git's idxfile: a header identifying the text file revision the
+// index was built from, a 256-entry cumulative fanout table keyed on the
+// first byte of each entry's zero-padded sequence id, a sorted array of
+// (seqId, offset, length) entries, and a trailing CRC32 over everything
+// before it. The fanout table lets a lookup narrow a binary search to a
+// single bucket before comparing a single SeqId.
+const (
+	listIndexMagic      = "LIDX"
+	listIndexVersion    = 1
+	listIndexFanoutSize = 256
+)
+
+// listIndexEntry locates one merged record (a primary "A%06d: ..." line
+// plus any continuation lines) within the list's text file.
+type listIndexEntry struct {
+	SeqId  uint32
+	Offset uint64
+	Length uint32
+}
+
+// listIndexHeader captures the size and modification time of the text file
+// an index was built from, so a stale index left behind by an older
+// revision of the file can be detected cheaply before trusting its offsets.
+type listIndexHeader struct {
+	TextSize    int64
+	TextModTime int64 // UnixNano
+}
+
+func listIndexPath(textPath string) string {
+	return textPath + ".idx"
+}
+
+// fanoutByte returns the fanout table key for seqId: the first byte of its
+// zero-padded, 6-digit decimal representation, matching the "A%06d" text
+// format written by Flush.
+func fanoutByte(seqId int) byte {
+	return fmt.Sprintf("%06d", seqId)[0]
+}
+
+// writeListIndex scans textPath and (re)writes its companion index file,
+// replacing any previous one via an atomic rename.
+func writeListIndex(textPath string) error {
+	info, err := os.Stat(textPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat list file: %w", err)
+	}
+	entries, err := scanListIndexEntries(textPath)
+	if err != nil {
+		return err
+	}
+	tmpPath := listIndexPath(textPath) + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create index file: %w", err)
+	}
+	crc := crc32.NewIEEE()
+	w := io.MultiWriter(file, crc)
+	fields := []interface{}{
+		[]byte(listIndexMagic),
+		uint32(listIndexVersion),
+		listIndexHeader{TextSize: info.Size(), TextModTime: info.ModTime().UnixNano()},
+		uint32(len(entries)),
+		buildFanoutTable(entries),
+	}
+	for _, f := range fields {
+		if err := binary.Write(w, binary.BigEndian, f); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write index header: %w", err)
+		}
+	}
+	for _, e := range entries {
+		if err := binary.Write(w, binary.BigEndian, e); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write index entry: %w", err)
+		}
+	}
+	if err := binary.Write(file, binary.BigEndian, crc.Sum32()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write index checksum: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close index file: %w", err)
+	}
+	if err := os.Rename(tmpPath, listIndexPath(textPath)); err != nil {
+		return fmt.Errorf("failed to install index file: %w", err)
+	}
+	return nil
+}
+
+// buildFanoutTable returns the 256-entry cumulative fanout table for
+// entries, which must already be sorted by SeqId: the entries for bucket b
+// are entries[table[b-1]:table[b]].
+func buildFanoutTable(entries []listIndexEntry) [listIndexFanoutSize]uint32 {
+	var table [listIndexFanoutSize]uint32
+	for _, e := range entries {
+		table[fanoutByte(int(e.SeqId))]++
+	}
+	var sum uint32
+	for i := 0; i < listIndexFanoutSize; i++ {
+		sum += table[i]
+		table[i] = sum
+	}
+	return table
+}
+
+// scanListIndexEntries reads the multi-line list file at textPath and
+// returns one entry per primary record, spanning its "A%06d: ..." line and
+// any following continuation lines.
+func scanListIndexEntries(textPath string) ([]listIndexEntry, error) {
+	file, err := os.Open(textPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open list file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []listIndexEntry
+	var current *listIndexEntry
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, readErr := reader.ReadString('\n')
+		if len(line) == 0 {
+			break
+		}
+		trimmed := strings.TrimSuffix(line, "\n")
+		if isContinuationLine(trimmed) {
+			if current != nil {
+				current.Length += uint32(len(line))
+			}
+		} else {
+			f, err := parseLine(trimmed)
+			if err != nil {
+				return nil, err
+			}
+			if current != nil {
+				entries = append(entries, *current)
+			}
+			current = &listIndexEntry{SeqId: uint32(f.SeqId), Offset: uint64(offset), Length: uint32(len(line))}
+		}
+		offset += int64(len(line))
+		if readErr != nil {
+			break
+		}
+	}
+	if current != nil {
+		entries = append(entries, *current)
+	}
+	return entries, nil
+}
+
+// loadListIndex reads and validates textPath's companion index file,
+// returning its entries and fanout table. It fails if the index is
+// missing, corrupt, or stale (built from a text file of a different size
+// or modification time than the one on disk), so callers always have a
+// clear reason to fall back to a linear scan.
+func loadListIndex(textPath string) ([]listIndexEntry, [listIndexFanoutSize]uint32, error) {
+	var fanout [listIndexFanoutSize]uint32
+	data, err := os.ReadFile(listIndexPath(textPath))
+	if err != nil {
+		return nil, fanout, fmt.Errorf("failed to read index file: %w", err)
+	}
+	if len(data) < 4 {
+		return nil, fanout, fmt.Errorf("index file is too short")
+	}
+	body, trailer := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(trailer) {
+		return nil, fanout, fmt.Errorf("index checksum mismatch")
+	}
+	r := bytes.NewReader(body)
+	magic := make([]byte, len(listIndexMagic))
+	var version uint32
+	var header listIndexHeader
+	var numEntries uint32
+	for _, f := range []interface{}{magic, &version, &header, &numEntries, &fanout} {
+		if err := binary.Read(r, binary.BigEndian, f); err != nil {
+			return nil, fanout, fmt.Errorf("failed to read index header: %w", err)
+		}
+	}
+	if string(magic) != listIndexMagic || version != listIndexVersion {
+		return nil, fanout, fmt.Errorf("unrecognized index format")
+	}
+	info, err := os.Stat(textPath)
+	if err != nil {
+		return nil, fanout, fmt.Errorf("failed to stat list file: %w", err)
+	}
+	if header.TextSize != info.Size() || header.TextModTime != info.ModTime().UnixNano() {
+		return nil, fanout, fmt.Errorf("stale index for %s", textPath)
+	}
+	entries := make([]listIndexEntry, numEntries)
+	for i := range entries {
+		if err := binary.Read(r, binary.BigEndian, &entries[i]); err != nil {
+			return nil, fanout, fmt.Errorf("failed to read index entry: %w", err)
+		}
+	}
+	return entries, fanout, nil
+}
+
+// findListIndexEntry uses the fanout table to narrow the search to entries'
+// seqId bucket, then binary searches within it.
+func findListIndexEntry(entries []listIndexEntry, fanout [listIndexFanoutSize]uint32, seqId int) (listIndexEntry, bool) {
+	lo, hi := fanoutBounds(fanout, seqId)
+	idx := lo + sort.Search(hi-lo, func(i int) bool {
+		return entries[lo+i].SeqId >= uint32(seqId)
+	})
+	if idx < hi && entries[idx].SeqId == uint32(seqId) {
+		return entries[idx], true
+	}
+	return listIndexEntry{}, false
+}
+
+// fanoutBounds returns the [lo, hi) index range of the fanout bucket
+// holding seqId.
+func fanoutBounds(fanout [listIndexFanoutSize]uint32, seqId int) (int, int) {
+	b := fanoutByte(seqId)
+	lo := 0
+	if b > 0 {
+		lo = int(fanout[b-1])
+	}
+	return lo, int(fanout[b])
+}
+
+// readListEntry seeks into file using entry's offset and length and parses
+// the merged record's content lines.
+func readListEntry(file *os.File, entry listIndexEntry) ([]string, error) {
+	if _, err := file.Seek(int64(entry.Offset), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek list file: %w", err)
+	}
+	buf := make([]byte, entry.Length)
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return nil, fmt.Errorf("failed to read list entry: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	contents := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i == 0 {
+			f, err := parseLine(line)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, f.Content)
+		} else {
+			c, err := parseContinuationLine(line)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, c)
+		}
+	}
+	return contents, nil
+}
+
+// Lookup returns the content lines stored for seqId, or nil if it is not
+// present. It uses the on-disk fanout index when available and falls back
+// to a linear scan of the text file if the index is missing or stale.
+func (l *List) Lookup(seqId int) ([]string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	path := filepath.Join(l.dataDir, l.name)
+	if !util.FileExists(path) {
+		return nil, nil
+	}
+	entries, fanout, err := loadListIndex(path)
+	if err != nil {
+		log.Printf("Looking up A%06d in %s without an index: %v", seqId, l.name, err)
+		return lookupLinear(path, seqId)
+	}
+	entry, ok := findListIndexEntry(entries, fanout, seqId)
+	if !ok {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return readListEntry(file, entry)
+}
+
+// LookupRange returns one Field per content line for every seqId in
+// [lo, hi], in ascending order. It uses the on-disk fanout index when
+// available and falls back to a linear scan of the text file if the index
+// is missing or stale.
+func (l *List) LookupRange(lo, hi int) ([]Field, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	path := filepath.Join(l.dataDir, l.name)
+	if !util.FileExists(path) {
+		return nil, nil
+	}
+	entries, fanout, err := loadListIndex(path)
+	if err != nil {
+		log.Printf("Looking up A%06d-A%06d in %s without an index: %v", lo, hi, l.name, err)
+		return lookupRangeLinear(path, lo, hi)
+	}
+	start, _ := fanoutBounds(fanout, lo)
+	idx := start + sort.Search(len(entries)-start, func(i int) bool {
+		return entries[start+i].SeqId >= uint32(lo)
+	})
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	var fields []Field
+	for ; idx < len(entries) && entries[idx].SeqId <= uint32(hi); idx++ {
+		contents, err := readListEntry(file, entries[idx])
+		if err != nil {
+			return nil, err
+		}
+		for _, content := range contents {
+			fields = append(fields, Field{SeqId: int(entries[idx].SeqId), Content: content})
+		}
+	}
+	return fields, nil
+}
+
+// lookupLinear scans the text file for seqId without the help of an index.
+func lookupLinear(path string, seqId int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	var contents []string
+	matched := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isContinuationLine(line) {
+			if matched {
+				c, err := parseContinuationLine(line)
+				if err != nil {
+					return nil, err
+				}
+				contents = append(contents, c)
+			}
+			continue
+		}
+		if matched {
+			break
+		}
+		f, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if f.SeqId == seqId {
+			matched = true
+			contents = append(contents, f.Content)
+		} else if f.SeqId > seqId {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading list: %w", err)
+	}
+	return contents, nil
+}
+
+// lookupRangeLinear scans the text file for seqIds in [lo, hi] without the
+// help of an index.
+func lookupRangeLinear(path string, lo, hi int) ([]Field, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	var fields []Field
+	current := -1
+	inRange := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isContinuationLine(line) {
+			if inRange {
+				c, err := parseContinuationLine(line)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, Field{SeqId: current, Content: c})
+			}
+			continue
+		}
+		f, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if f.SeqId > hi {
+			break
+		}
+		current = f.SeqId
+		inRange = f.SeqId >= lo
+		if inRange {
+			fields = append(fields, Field{SeqId: current, Content: f.Content})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading list: %w", err)
+	}
+	return fields, nil
+}
+
+// findMissingIdsFromEntries is the index-backed equivalent of
+// findMissingIds: it walks already-loaded index entries instead of
+// rescanning and reparsing the text file.
+func findMissingIdsFromEntries(entries []listIndexEntry, maxId int, maxNumIds int) ([]int, int) {
+	ids := []int{}
+	nextId := 1
+	numMissing := 0
+	for _, e := range entries {
+		seqId := int(e.SeqId)
+		for i := nextId; i < seqId && len(ids) < maxNumIds; i++ {
+			ids = append(ids, i)
+		}
+		if seqId > nextId {
+			numMissing += seqId - nextId
+		}
+		nextId = seqId + 1
+	}
+	for i := nextId; i <= maxId && len(ids) < maxNumIds; i++ {
+		ids = append(ids, i)
+	}
+	if maxId >= nextId {
+		numMissing += maxId + 1 - nextId
+	}
+	return ids, numMissing
+}
@@ -0,0 +1,113 @@
+package shared
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/loda-lang/loda-api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	listIndexTestFields = []Field{
+		{Key: "T", SeqId: 1, Content: "first entry for A000001"},
+		{Key: "T", SeqId: 1, Content: "second entry for A000001"},
+		{Key: "T", SeqId: 3, Content: "single entry for A000003"},
+		{Key: "T", SeqId: 7, Content: "single entry for A000007"},
+		{Key: "T", SeqId: 100, Content: "single entry for A000100"},
+	}
+)
+
+func TestList_FlushWritesIndex(t *testing.T) {
+	l := NewList("T", "test_index1", ".")
+	l.Update(listIndexTestFields)
+	err := l.Flush(context.Background(), false)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.True(t, util.FileExists("test_index1.idx"), "Expected index file to exist")
+	os.Remove("test_index1")
+	os.Remove("test_index1.gz")
+	os.Remove("test_index1.idx")
+}
+
+func TestList_Lookup(t *testing.T) {
+	l := NewList("T", "test_index2", ".")
+	l.Update(listIndexTestFields)
+	err := l.Flush(context.Background(), false)
+	assert.Equal(t, nil, err, "Expected no error")
+
+	contents, err := l.Lookup(1)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, []string{"first entry for A000001", "second entry for A000001"}, contents, "Unexpected contents")
+
+	contents, err = l.Lookup(7)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, []string{"single entry for A000007"}, contents, "Unexpected contents")
+
+	contents, err = l.Lookup(42)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Nil(t, contents, "Expected no contents for a missing seqId")
+
+	os.Remove("test_index2")
+	os.Remove("test_index2.gz")
+	os.Remove("test_index2.idx")
+}
+
+func TestList_LookupRange(t *testing.T) {
+	l := NewList("T", "test_index3", ".")
+	l.Update(listIndexTestFields)
+	err := l.Flush(context.Background(), false)
+	assert.Equal(t, nil, err, "Expected no error")
+
+	fields, err := l.LookupRange(2, 50)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, []Field{
+		{SeqId: 3, Content: "single entry for A000003"},
+		{SeqId: 7, Content: "single entry for A000007"},
+	}, fields, "Unexpected range")
+
+	os.Remove("test_index3")
+	os.Remove("test_index3.gz")
+	os.Remove("test_index3.idx")
+}
+
+func TestList_LookupFallsBackWhenIndexIsStale(t *testing.T) {
+	l := NewList("T", "test_index4", ".")
+	l.Update(listIndexTestFields)
+	err := l.Flush(context.Background(), false)
+	assert.Equal(t, nil, err, "Expected no error")
+
+	// Simulate a text file that was rewritten behind the index's back, e.g.
+	// by an older binary that doesn't know about the index.
+	time.Sleep(10 * time.Millisecond)
+	file, err := os.OpenFile("test_index4", os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Equal(t, nil, err, "Expected no error")
+	_, err = file.WriteString("A000200: appended without reindexing\n")
+	assert.Equal(t, nil, err, "Expected no error")
+	file.Close()
+
+	contents, err := l.Lookup(200)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, []string{"appended without reindexing"}, contents, "Expected a linear scan to still find the appended entry")
+
+	os.Remove("test_index4")
+	os.Remove("test_index4.gz")
+	os.Remove("test_index4.idx")
+}
+
+func TestList_FindMissingIdsUsesIndex(t *testing.T) {
+	l := NewList("T", "test_index5", ".")
+	l.Update(listIndexTestFields)
+	err := l.Flush(context.Background(), false)
+	assert.Equal(t, nil, err, "Expected no error")
+
+	ids, numMissing, err := l.FindMissingIds(context.Background(), 8, 4)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, 96, numMissing, "Unexpected number of missing ids")
+	assert.Equal(t, []int{2, 4, 5, 6}, ids, "Unexpected ids")
+
+	os.Remove("test_index5")
+	os.Remove("test_index5.gz")
+	os.Remove("test_index5.idx")
+}
@@ -0,0 +1,183 @@
+package shared
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// OpTypeMask is an opaque, variable-width bitmask of operation type ref IDs.
+// Unlike a plain uint64, it has no fixed ceiling on the number of distinct
+// operation types: internally it grows to as many 64-bit words as the
+// highest set ref_id requires, so adding new LODA opcodes beyond bit 63 no
+// longer needs a breaking encoding change.
+type OpTypeMask struct {
+	words []uint64
+}
+
+func wordIndex(bit uint) (int, uint) {
+	return int(bit / 64), bit % 64
+}
+
+// set sets the given bit (ref_id), growing the mask if necessary.
+func (m *OpTypeMask) set(bit uint) {
+	word, offset := wordIndex(bit)
+	if word >= len(m.words) {
+		grown := make([]uint64, word+1)
+		copy(grown, m.words)
+		m.words = grown
+	}
+	m.words[word] |= 1 << offset
+}
+
+// Has returns true if the given bit (ref_id) is set.
+func (m OpTypeMask) Has(bit uint) bool {
+	word, offset := wordIndex(bit)
+	if word >= len(m.words) {
+		return false
+	}
+	return m.words[word]&(1<<offset) != 0
+}
+
+// IsZero returns true if no bits are set.
+func (m OpTypeMask) IsZero() bool {
+	for _, w := range m.words {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge returns the bitwise OR of m and other.
+func (m OpTypeMask) Merge(other OpTypeMask) OpTypeMask {
+	n := len(m.words)
+	if len(other.words) > n {
+		n = len(other.words)
+	}
+	merged := make([]uint64, n)
+	for i := range merged {
+		if i < len(m.words) {
+			merged[i] = m.words[i]
+		}
+		if i < len(other.words) {
+			merged[i] |= other.words[i]
+		}
+	}
+	return OpTypeMask{words: merged}
+}
+
+// HasAll returns true if every bit set in required is also set in m.
+func (m OpTypeMask) HasAll(required OpTypeMask) bool {
+	for i, w := range required.words {
+		var have uint64
+		if i < len(m.words) {
+			have = m.words[i]
+		}
+		if have&w != w {
+			return false
+		}
+	}
+	return true
+}
+
+// HasNone returns true if none of the bits set in excluded are also set in m.
+func (m OpTypeMask) HasNone(excluded OpTypeMask) bool {
+	for i, w := range excluded.words {
+		var have uint64
+		if i < len(m.words) {
+			have = m.words[i]
+		}
+		if have&w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// trimmed returns m.words with trailing zero words removed.
+func (m OpTypeMask) trimmed() []uint64 {
+	n := len(m.words)
+	for n > 0 && m.words[n-1] == 0 {
+		n--
+	}
+	return m.words[:n]
+}
+
+// MarshalBinary encodes the mask as a varint word-count prefix followed by
+// the trimmed (trailing-zero-stripped) little-endian words, similar in
+// spirit to the fanout/index layout used by git's packed idx files.
+func (m OpTypeMask) MarshalBinary() ([]byte, error) {
+	words := m.trimmed()
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(words)))
+	out := make([]byte, n+8*len(words))
+	copy(out, prefix[:n])
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(out[n+8*i:], w)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary decodes a mask previously produced by MarshalBinary.
+func (m *OpTypeMask) UnmarshalBinary(data []byte) error {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return fmt.Errorf("invalid OpTypeMask encoding: bad varint length prefix")
+	}
+	rest := data[n:]
+	if uint64(len(rest)) < count*8 {
+		return fmt.Errorf("invalid OpTypeMask encoding: truncated word data")
+	}
+	words := make([]uint64, count)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(rest[8*i:])
+	}
+	m.words = words
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder via MarshalBinary, since OpTypeMask's
+// only field is unexported and gob cannot see it otherwise.
+func (m OpTypeMask) GobEncode() ([]byte, error) {
+	return m.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder, the counterpart to GobEncode.
+func (m *OpTypeMask) GobDecode(data []byte) error {
+	return m.UnmarshalBinary(data)
+}
+
+// MarshalJSON encodes the mask as a base64 string of its compact binary form.
+func (m OpTypeMask) MarshalJSON() ([]byte, error) {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(data))
+}
+
+// UnmarshalJSON decodes a mask encoded by MarshalJSON. For backward
+// compatibility with on-disk data written before masks became variable
+// width, it also accepts a plain JSON number (the old uint64 encoding) and
+// upgrades it transparently into a single-word mask.
+func (m *OpTypeMask) UnmarshalJSON(data []byte) error {
+	var legacy uint64
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*m = OpTypeMask{}
+		if legacy != 0 {
+			m.words = []uint64{legacy}
+		}
+		return nil
+	}
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("invalid OpTypeMask JSON: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid OpTypeMask base64: %w", err)
+	}
+	return m.UnmarshalBinary(raw)
+}
@@ -0,0 +1,116 @@
+package shared
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// OpTypeRegistry holds the current *OpTypeIndex loaded from an
+// operation_types.csv file and reloads it automatically whenever the file
+// changes on disk, so operators can add new LODA opcodes without restarting
+// the API. Current returns a consistent snapshot: an in-flight encode or
+// decode keeps using the index it started with even if a reload swaps in a
+// new one concurrently.
+type OpTypeRegistry struct {
+	path     string
+	current  atomic.Pointer[OpTypeIndex]
+	onReload func(old, new *OpTypeIndex)
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+}
+
+// NewOpTypeRegistry loads path and starts watching it for changes. onReload,
+// if non-nil, is called after each successful reload with the previous and
+// new index so callers can react to ref_id assignment changes, e.g. by
+// re-encoding cached Program.OpsMask values. It is not called for the
+// initial load.
+func NewOpTypeRegistry(path string, onReload func(old, new *OpTypeIndex)) (*OpTypeRegistry, error) {
+	index, err := loadOpTypeIndex(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load operation types from %s: %w", path, err)
+	}
+	r := &OpTypeRegistry{path: path, onReload: onReload, done: make(chan struct{})}
+	r.current.Store(index)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create file watcher: %w", err)
+	}
+	// Watch the containing directory rather than the file itself: a common
+	// way to publish a new version of a file is to write it under a
+	// temporary name and rename it into place, which replaces the inode
+	// fsnotify would otherwise be watching.
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("cannot watch %s: %w", dir, err)
+	}
+	r.watcher = watcher
+	go r.watch()
+	return r, nil
+}
+
+func loadOpTypeIndex(path string) (*OpTypeIndex, error) {
+	opTypes, err := LoadOperationTypesCSV(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewOpTypeIndex(opTypes)
+}
+
+// Current returns the most recently loaded, validated OpTypeIndex.
+func (r *OpTypeRegistry) Current() *OpTypeIndex {
+	return r.current.Load()
+}
+
+func (r *OpTypeRegistry) watch() {
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(r.path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			r.reload()
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Operation type file watcher error: %v", err)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// reload re-validates the file on disk (same uniqueness and contiguous
+// ref_id checks as NewOpTypeIndex) and only swaps it in on success. A failed
+// reload logs the error and keeps serving the previous index.
+func (r *OpTypeRegistry) reload() {
+	index, err := loadOpTypeIndex(r.path)
+	if err != nil {
+		log.Printf("Keeping previous operation type index, reload of %s failed: %v", r.path, err)
+		return
+	}
+	old := r.current.Swap(index)
+	if r.onReload != nil {
+		r.onReload(old, index)
+	}
+	log.Printf("Reloaded operation type index from %s", r.path)
+}
+
+// Close stops watching the file. The most recently loaded index remains
+// available from Current.
+func (r *OpTypeRegistry) Close() error {
+	close(r.done)
+	return r.watcher.Close()
+}
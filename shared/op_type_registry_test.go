@@ -0,0 +1,114 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const opTypeRegistryTestHeader = "name,ref_id,count\n"
+
+func writeOpTypesCSV(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(opTypeRegistryTestHeader+body), 0644); err != nil {
+		t.Fatalf("cannot write %s: %v", path, err)
+	}
+}
+
+func TestOpTypeRegistry_LoadsInitialIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "operation_types.csv")
+	writeOpTypesCSV(t, path, "mov,1,10\nadd,2,20\n")
+
+	registry, err := NewOpTypeRegistry(path, nil)
+	if err != nil {
+		t.Fatalf("NewOpTypeRegistry failed: %v", err)
+	}
+	defer registry.Close()
+
+	if !registry.Current().IsOperationType("mov") {
+		t.Errorf("expected initial index to know about mov")
+	}
+	if !registry.Current().IsOperationType("add") {
+		t.Errorf("expected initial index to know about add")
+	}
+}
+
+func TestOpTypeRegistry_InvalidPathFails(t *testing.T) {
+	if _, err := NewOpTypeRegistry(filepath.Join(t.TempDir(), "missing.csv"), nil); err == nil {
+		t.Error("expected an error loading a nonexistent file")
+	}
+}
+
+func TestOpTypeRegistry_ReloadsOnFileChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "operation_types.csv")
+	writeOpTypesCSV(t, path, "mov,1,10\n")
+
+	reloaded := make(chan *OpTypeIndex, 1)
+	registry, err := NewOpTypeRegistry(path, func(old, new *OpTypeIndex) {
+		reloaded <- new
+	})
+	if err != nil {
+		t.Fatalf("NewOpTypeRegistry failed: %v", err)
+	}
+	defer registry.Close()
+
+	if registry.Current().IsOperationType("add") {
+		t.Fatalf("add should not be known yet")
+	}
+
+	// Publish a new version the way an atomic writer would: write to a temp
+	// file in the same directory, then rename it into place.
+	tmp := path + ".tmp"
+	writeOpTypesCSV(t, tmp, "mov,1,10\nadd,2,20\n")
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("cannot rename %s: %v", tmp, err)
+	}
+
+	select {
+	case newIndex := <-reloaded:
+		if !newIndex.IsOperationType("add") {
+			t.Errorf("reloaded index should know about add")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	if !registry.Current().IsOperationType("add") {
+		t.Errorf("Current() should reflect the reloaded index")
+	}
+}
+
+func TestOpTypeRegistry_KeepsPreviousIndexOnInvalidReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "operation_types.csv")
+	writeOpTypesCSV(t, path, "mov,1,10\n")
+
+	registry, err := NewOpTypeRegistry(path, nil)
+	if err != nil {
+		t.Fatalf("NewOpTypeRegistry failed: %v", err)
+	}
+	defer registry.Close()
+	before := registry.Current()
+
+	// A reload with a duplicate ref_id fails NewOpTypeIndex's validation.
+	tmp := path + ".tmp"
+	writeOpTypesCSV(t, tmp, "mov,1,10\nadd,1,20\n")
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("cannot rename %s: %v", tmp, err)
+	}
+
+	// reload() runs synchronously from the watcher goroutine; call it
+	// directly here to avoid depending on fsnotify delivery timing for a
+	// negative assertion.
+	registry.reload()
+
+	if registry.Current() != before {
+		t.Errorf("an invalid reload must not replace the current index")
+	}
+	if !registry.Current().IsOperationType("mov") {
+		t.Errorf("previous index should still be usable after a failed reload")
+	}
+}
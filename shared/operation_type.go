@@ -1,7 +1,12 @@
 package shared
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
 )
 
 // OperationType represents a LODA operation type with its statistics
@@ -77,49 +82,90 @@ func (idx *OpTypeIndex) IsOperationType(s string) bool {
 	return ok
 }
 
-// EncodeOperationTypes encodes a list of operation types into a uint64 bitmask
-func (idx *OpTypeIndex) EncodeOperationTypes(ops []string) (uint64, error) {
-	var bits uint64
+// EncodeOperationTypes encodes a list of operation types into an OpTypeMask
+func (idx *OpTypeIndex) EncodeOperationTypes(ops []string) (OpTypeMask, error) {
+	var mask OpTypeMask
 	for _, op := range ops {
 		bit, ok := idx.nameToBit[op]
 		if !ok {
-			return 0, fmt.Errorf("unknown operation type: %s", op)
+			return OpTypeMask{}, fmt.Errorf("unknown operation type: %s", op)
 		}
-		bits |= 1 << bit
+		mask.set(bit)
 	}
-	return bits, nil
+	return mask, nil
 }
 
-// DecodeOperationTypes decodes a uint64 bitmask into a list of operation types
-func (idx *OpTypeIndex) DecodeOperationTypes(bits uint64) []string {
+// DecodeOperationTypes decodes an OpTypeMask into a list of operation types
+func (idx *OpTypeIndex) DecodeOperationTypes(mask OpTypeMask) []string {
 	var result []string
 	for i := 1; i <= idx.maxRefId; i++ {
-		if bits&(1<<uint(i)) != 0 {
+		if mask.Has(uint(i)) {
 			result = append(result, idx.types[i].Name)
 		}
 	}
 	return result
 }
 
-// HasOperationType returns true if the given operation type is present in the bits
-func (idx *OpTypeIndex) HasOperationType(bits uint64, op string) bool {
+// HasOperationType returns true if the given operation type is present in mask
+func (idx *OpTypeIndex) HasOperationType(mask OpTypeMask, op string) bool {
 	bit, ok := idx.nameToBit[op]
-	return ok && bits&(1<<bit) != 0
+	return ok && mask.Has(bit)
 }
 
-// HasAllOperationTypes returns true if all operation types in bits2 are present in bits1
-func (idx *OpTypeIndex) HasAllOperationTypes(bits1, bits2 uint64) bool {
-	return bits1&bits2 == bits2
+// HasAllOperationTypes returns true if all operation types in mask2 are present in mask1
+func (idx *OpTypeIndex) HasAllOperationTypes(mask1, mask2 OpTypeMask) bool {
+	return mask1.HasAll(mask2)
 }
 
-// HasNoOperationTypes returns true if none of the operation types in bits2 are present in bits1
-func (idx *OpTypeIndex) HasNoOperationTypes(bits1, bits2 uint64) bool {
-	return bits1&bits2 == 0
+// HasNoOperationTypes returns true if none of the operation types in mask2 are present in mask1
+func (idx *OpTypeIndex) HasNoOperationTypes(mask1, mask2 OpTypeMask) bool {
+	return mask1.HasNone(mask2)
 }
 
 // MergeOperationTypes merges two operation type bitmasks into one
-func (idx *OpTypeIndex) MergeOperationTypes(bits1, bits2 uint64) uint64 {
-	return bits1 | bits2
+func (idx *OpTypeIndex) MergeOperationTypes(mask1, mask2 OpTypeMask) OpTypeMask {
+	return mask1.Merge(mask2)
+}
+
+var expectedOperationTypeHeader = []string{"name", "ref_id", "count"}
+
+// LoadOperationTypesCSV reads an operation_types.csv file (as produced by the
+// stats service) and returns the operation types it describes, in file
+// order. The result is suitable for passing directly to NewOpTypeIndex.
+func LoadOperationTypesCSV(path string) ([]*OperationType, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(header, expectedOperationTypeHeader) {
+		return nil, fmt.Errorf("unexpected header in operation_types.csv: %v", header)
+	}
+	var opTypes []*OperationType
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		refId, err := strconv.Atoi(rec[1])
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(rec[2])
+		if err != nil {
+			return nil, err
+		}
+		opTypes = append(opTypes, &OperationType{Name: rec[0], RefId: refId, Count: count})
+	}
+	return opTypes, nil
 }
 
 // GetOperationTypes returns all operation types (excluding index 0)
@@ -0,0 +1,276 @@
+// Package operations tracks the lifecycle of long-running submissions (so
+// far, just sequence refreshes) from acceptance through to the crawler
+// actually fetching them, so a client can poll for the outcome instead of
+// the fire-and-forget response newV2SubmissionsPostHandler used to give.
+package operations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Status is the lifecycle state of an Operation.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSuccess   Status = "success"
+	StatusError     Status = "error"
+	StatusCancelled Status = "cancelled"
+)
+
+// Operation is a single tracked async submission.
+type Operation struct {
+	ID            string    `json:"id"`
+	Submitter     string    `json:"submitter"`
+	SeqId         int64     `json:"seq_id"`
+	Status        Status    `json:"status"`
+	QueuePosition int       `json:"queue_position,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Error         string    `json:"error,omitempty"`
+}
+
+const (
+	// StoreFile is the checkpoint filename Store persists to, alongside
+	// CheckpointFile in cmd/submissions.
+	StoreFile = "operations.json"
+
+	// MaxOperations bounds the store size: once full, the oldest terminal
+	// operation is evicted to make room for a new one.
+	MaxOperations = 10000
+
+	// TerminalTTL is how long a terminal (success/error/cancelled)
+	// operation is kept around before Prune removes it.
+	TerminalTTL = 24 * time.Hour
+)
+
+// Store is a checkpointed OperationStore keyed by Operation.ID, persisted as
+// a single JSON file rather than a real database, in keeping with how the
+// rest of this package favors small file-backed stores (see RefreshQueue,
+// SubmitterStore) over external dependencies.
+type Store struct {
+	path string
+
+	mutex sync.Mutex
+	order []string // insertion order, oldest first, for eviction and listing
+	byID  map[string]*Operation
+}
+
+// NewStore creates a Store backed by dataDir, loading any previously
+// checkpointed operations.
+func NewStore(dataDir string) *Store {
+	s := &Store{
+		path: filepath.Join(dataDir, StoreFile),
+		byID: make(map[string]*Operation),
+	}
+	if raw, err := os.ReadFile(s.path); err == nil {
+		var ops []*Operation
+		if err := json.Unmarshal(raw, &ops); err == nil {
+			for _, op := range ops {
+				s.byID[op.ID] = op
+				s.order = append(s.order, op.ID)
+			}
+		}
+	}
+	return s
+}
+
+// save persists the store via a temp file plus atomic rename. Caller must
+// hold mutex.
+func (s *Store) save() error {
+	ops := make([]*Operation, 0, len(s.order))
+	for _, id := range s.order {
+		ops = append(ops, s.byID[id])
+	}
+	raw, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal operation store: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0600); err != nil {
+		return fmt.Errorf("cannot write operation store: %w", err)
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// newID generates a fresh, hex-encoded operation ID.
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("cannot generate operation id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isTerminal reports whether status is one an operation never leaves.
+func isTerminal(status Status) bool {
+	return status == StatusSuccess || status == StatusError || status == StatusCancelled
+}
+
+// Create allocates a new pending Operation for seqId, submitted by
+// submitter and queued at queuePosition, evicting the oldest terminal
+// operation first if the store is already at MaxOperations.
+func (s *Store) Create(submitter string, seqId int64, queuePosition int) (Operation, error) {
+	id, err := newID()
+	if err != nil {
+		return Operation{}, err
+	}
+	now := time.Now()
+	op := &Operation{
+		ID:            id,
+		Submitter:     submitter,
+		SeqId:         seqId,
+		Status:        StatusPending,
+		QueuePosition: queuePosition,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.evictLocked()
+	s.byID[id] = op
+	s.order = append(s.order, id)
+	if err := s.save(); err != nil {
+		return Operation{}, err
+	}
+	return *op, nil
+}
+
+// evictLocked drops the oldest terminal operation once the store is at
+// MaxOperations, so a sustained flood of refreshes can't grow it without
+// bound. Caller must hold mutex.
+func (s *Store) evictLocked() {
+	if len(s.order) < MaxOperations {
+		return
+	}
+	for i, id := range s.order {
+		op := s.byID[id]
+		if op == nil || isTerminal(op.Status) {
+			delete(s.byID, id)
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get returns a copy of the operation with the given id.
+func (s *Store) Get(id string) (Operation, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	op, ok := s.byID[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return *op, true
+}
+
+// List returns every operation for submitter (or every operation, if
+// submitter is empty), most recently created first.
+func (s *Store) List(submitter string) []Operation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var results []Operation
+	for i := len(s.order) - 1; i >= 0; i-- {
+		op := s.byID[s.order[i]]
+		if op == nil || (submitter != "" && op.Submitter != submitter) {
+			continue
+		}
+		results = append(results, *op)
+	}
+	return results
+}
+
+// updateLocked applies fn to the operation with the given id, bumps
+// UpdatedAt, and persists the change. Caller must hold mutex. It's a no-op
+// if the id is unknown.
+func (s *Store) updateLocked(id string, fn func(*Operation)) error {
+	op, ok := s.byID[id]
+	if !ok {
+		return nil
+	}
+	fn(op)
+	op.UpdatedAt = time.Now()
+	return s.save()
+}
+
+// MarkRunning transitions a pending operation to running.
+func (s *Store) MarkRunning(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.updateLocked(id, func(op *Operation) { op.Status = StatusRunning })
+}
+
+// CompleteBySeqId marks every non-terminal operation for seqId as success
+// (fetchErr == nil) or error, called once the crawler actually fetches that
+// sequence.
+func (s *Store) CompleteBySeqId(seqId int64, fetchErr error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, id := range s.order {
+		op := s.byID[id]
+		if op == nil || op.SeqId != seqId || isTerminal(op.Status) {
+			continue
+		}
+		if err := s.updateLocked(id, func(op *Operation) {
+			if fetchErr != nil {
+				op.Status = StatusError
+				op.Error = fetchErr.Error()
+			} else {
+				op.Status = StatusSuccess
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cancel marks a still-pending operation as cancelled, reporting whether it
+// was pending (and so actually cancelled). A false result means the caller
+// shouldn't bother dequeuing it from the crawler either, since it's either
+// unknown or already past the pending state.
+func (s *Store) Cancel(id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	op, ok := s.byID[id]
+	if !ok || op.Status != StatusPending {
+		return false, nil
+	}
+	if err := s.updateLocked(id, func(op *Operation) { op.Status = StatusCancelled }); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Prune removes every terminal operation last updated more than TerminalTTL
+// ago, so the store doesn't grow without bound even well below
+// MaxOperations.
+func (s *Store) Prune() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cutoff := time.Now().Add(-TerminalTTL)
+	kept := s.order[:0]
+	changed := false
+	for _, id := range s.order {
+		op := s.byID[id]
+		if op != nil && isTerminal(op.Status) && op.UpdatedAt.Before(cutoff) {
+			delete(s.byID, id)
+			changed = true
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
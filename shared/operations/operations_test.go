@@ -0,0 +1,116 @@
+package operations
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_CreateAndGet(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "operations-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewStore(tempDir)
+	op, err := store.Create("alice", 45, 3)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, op.ID)
+	assert.Equal(t, StatusPending, op.Status)
+
+	got, ok := store.Get(op.ID)
+	assert.True(t, ok)
+	assert.Equal(t, op.ID, got.ID)
+	assert.Equal(t, int64(45), got.SeqId)
+}
+
+func TestStore_GetUnknown(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "operations-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewStore(tempDir)
+	_, ok := store.Get("does-not-exist")
+	assert.False(t, ok)
+}
+
+func TestStore_List(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "operations-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewStore(tempDir)
+	_, err = store.Create("alice", 1, 1)
+	assert.NoError(t, err)
+	_, err = store.Create("bob", 2, 1)
+	assert.NoError(t, err)
+
+	all := store.List("")
+	assert.Len(t, all, 2)
+
+	aliceOnly := store.List("alice")
+	assert.Len(t, aliceOnly, 1)
+	assert.Equal(t, "alice", aliceOnly[0].Submitter)
+}
+
+func TestStore_CompleteBySeqId(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "operations-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewStore(tempDir)
+	op, err := store.Create("alice", 45, 1)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.CompleteBySeqId(45, nil))
+	got, ok := store.Get(op.ID)
+	assert.True(t, ok)
+	assert.Equal(t, StatusSuccess, got.Status)
+
+	op2, err := store.Create("alice", 46, 1)
+	assert.NoError(t, err)
+	assert.NoError(t, store.CompleteBySeqId(46, errors.New("boom")))
+	got2, ok := store.Get(op2.ID)
+	assert.True(t, ok)
+	assert.Equal(t, StatusError, got2.Status)
+	assert.Equal(t, "boom", got2.Error)
+}
+
+func TestStore_Cancel(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "operations-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewStore(tempDir)
+	op, err := store.Create("alice", 45, 1)
+	assert.NoError(t, err)
+
+	cancelled, err := store.Cancel(op.ID)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+
+	got, ok := store.Get(op.ID)
+	assert.True(t, ok)
+	assert.Equal(t, StatusCancelled, got.Status)
+
+	// Already terminal: Cancel reports false and leaves status alone.
+	cancelled, err = store.Cancel(op.ID)
+	assert.NoError(t, err)
+	assert.False(t, cancelled)
+}
+
+func TestStore_PersistsAcrossRestart(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "operations-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewStore(tempDir)
+	op, err := store.Create("alice", 45, 1)
+	assert.NoError(t, err)
+
+	reloaded := NewStore(tempDir)
+	got, ok := reloaded.Get(op.ID)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", got.Submitter)
+}
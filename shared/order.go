@@ -0,0 +1,118 @@
+package shared
+
+import (
+	"strings"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// The idKey/nameKey/... helpers below extract each named comparator's sort
+// key from whichever concrete record type it applies to (Sequence and/or
+// Submission); a type the field doesn't apply to contributes a zero key,
+// so two records of different or unsupported types compare equal and a
+// util.Chain falls through to its next comparator instead of panicking.
+
+func idKey(v interface{}) string {
+	switch t := v.(type) {
+	case Sequence:
+		return t.Id.String()
+	case *Sequence:
+		return t.Id.String()
+	case Submission:
+		return t.Id.String()
+	case *Submission:
+		return t.Id.String()
+	}
+	return ""
+}
+
+func nameKey(v interface{}) string {
+	switch t := v.(type) {
+	case Sequence:
+		return t.Name
+	case *Sequence:
+		return t.Name
+	}
+	return ""
+}
+
+func termCountKey(v interface{}) int {
+	switch t := v.(type) {
+	case Sequence:
+		return len(t.TermsList())
+	case *Sequence:
+		return len(t.TermsList())
+	}
+	return 0
+}
+
+func submitterKey(v interface{}) string {
+	switch t := v.(type) {
+	case Sequence:
+		if t.Submitter != nil {
+			return t.Submitter.Name
+		}
+	case *Sequence:
+		if t.Submitter != nil {
+			return t.Submitter.Name
+		}
+	case Submission:
+		return t.Submitter
+	case *Submission:
+		return t.Submitter
+	}
+	return ""
+}
+
+func modeKey(v interface{}) string {
+	switch t := v.(type) {
+	case Submission:
+		return string(t.Mode)
+	case *Submission:
+		return string(t.Mode)
+	}
+	return ""
+}
+
+// ById, ByName, ByTermCount, BySubmitter and ByMode are the named
+// comparators OrderByRegistry exposes for an "order=" query parameter.
+// Each applies to whichever of Sequence or Submission carries the
+// corresponding field (see the ...Key helpers above); comparing across an
+// unrelated type, or a type missing the field, ties at zero.
+var (
+	ById        util.Comparator = func(a, b interface{}) int { return strings.Compare(idKey(a), idKey(b)) }
+	ByName      util.Comparator = func(a, b interface{}) int { return strings.Compare(nameKey(a), nameKey(b)) }
+	ByTermCount util.Comparator = func(a, b interface{}) int { return termCountKey(a) - termCountKey(b) }
+	BySubmitter util.Comparator = func(a, b interface{}) int { return strings.Compare(submitterKey(a), submitterKey(b)) }
+	ByMode      util.Comparator = func(a, b interface{}) int { return strings.Compare(modeKey(a), modeKey(b)) }
+)
+
+// OrderByRegistry maps an "order=" field name to its comparator, for
+// util.ParseListParams. SequenceOrderByRegistry and SubmissionOrderByRegistry
+// are the same map restricted to the fields each record type actually has,
+// so an irrelevant field name (e.g. "mode" for sequences) is rejected by
+// ParseOrder instead of silently ticking but doing nothing.
+var OrderByRegistry = map[string]util.Comparator{
+	"id":        ById,
+	"name":      ByName,
+	"termcount": ByTermCount,
+	"submitter": BySubmitter,
+	"mode":      ByMode,
+}
+
+// SequenceOrderByRegistry is the subset of OrderByRegistry meaningful for
+// ordering Sequence results (Index.Search's "order=" parameter).
+var SequenceOrderByRegistry = map[string]util.Comparator{
+	"id":        ById,
+	"name":      ByName,
+	"termcount": ByTermCount,
+	"submitter": BySubmitter,
+}
+
+// SubmissionOrderByRegistry is the subset of OrderByRegistry meaningful for
+// ordering Submission results (the submissions store's "order=" parameter).
+var SubmissionOrderByRegistry = map[string]util.Comparator{
+	"id":        ById,
+	"submitter": BySubmitter,
+	"mode":      ByMode,
+}
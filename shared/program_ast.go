@@ -0,0 +1,287 @@
+package shared
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// Comment is a single header-comment line of a LODA program, e.g.
+// "; A000045: Fibonacci numbers." parses to Comment{Text: "A000045:
+// Fibonacci numbers."} -- the leading "; " is stripped.
+type Comment struct {
+	Text string
+}
+
+// Instruction is a single body line of a LODA program: an operation
+// ("op target,source"), a directive ("#name value"), a standalone
+// "; ..." comment, or a blank line. Op is empty for a blank line or a
+// standalone comment; it starts with "#" for a directive, whose single
+// argument is kept in Target. An operation or directive line may carry a
+// trailing "; ..." comment, kept in Comment alongside Op/Target/Source.
+type Instruction struct {
+	Op      string
+	Target  string
+	Source  string
+	Comment string
+}
+
+// IsBlank reports whether i is an empty line.
+func (i Instruction) IsBlank() bool {
+	return i.Op == "" && i.Target == "" && i.Source == "" && i.Comment == ""
+}
+
+// IsComment reports whether i is a standalone "; ..." line rather than an
+// operation or directive with its own trailing comment.
+func (i Instruction) IsComment() bool {
+	return i.Op == "" && i.Comment != ""
+}
+
+// IsDirective reports whether i is a "#name value" assembler directive,
+// e.g. "#offset 5".
+func (i Instruction) IsDirective() bool {
+	return strings.HasPrefix(i.Op, "#")
+}
+
+// codeString renders i's operation/directive without its trailing comment,
+// e.g. Instruction{Op: "mov", Target: "$1", Source: "$0"} -> "mov $1,$0".
+func (i Instruction) codeString() string {
+	line := i.Op
+	if i.Target != "" || i.Source != "" {
+		line += " " + i.Target
+		if i.Source != "" {
+			line += "," + i.Source
+		}
+	}
+	return line
+}
+
+// String renders i back to a single line of LODA code.
+func (i Instruction) String() string {
+	switch {
+	case i.IsBlank():
+		return ""
+	case i.IsComment():
+		return "; " + i.Comment
+	default:
+		line := i.codeString()
+		if i.Comment != "" {
+			line += " ; " + i.Comment
+		}
+		return line
+	}
+}
+
+// ProgramAST is a LODA program parsed into its header comments and body
+// instructions by ParseProgramAST, replacing the repeated
+// strings.Split/SplitN scanning code.go used to do. Its methods are the
+// single source of truth the code.go extractors and updaters are built on;
+// it's a distinct type from Program, which is the program's *metadata*
+// (Id, Name, Operations, ...) as stored and served elsewhere -- ProgramAST
+// is how that metadata actually gets read from and written back to a
+// program's source.
+type ProgramAST struct {
+	Header []Comment
+	Body   []Instruction
+}
+
+// ParseProgramAST parses code into its header comments (every "; ..."
+// line, and any blank lines among them, up to the first operation or
+// directive) and body instructions (everything from there on, including a
+// leading run of blank lines that separated it from the header). It never
+// fails on malformed input: a body line it can't make sense of as an
+// operation still round-trips as a standalone comment or blank line.
+func ParseProgramAST(code string) (*ProgramAST, error) {
+	ast := &ProgramAST{}
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	inHeader := true
+	pendingBlanks := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inHeader {
+			if line == "" {
+				pendingBlanks++
+				continue
+			}
+			if strings.HasPrefix(line, ";") {
+				ast.Header = append(ast.Header, Comment{Text: strings.TrimSpace(line[1:])})
+				continue
+			}
+			inHeader = false
+			for ; pendingBlanks > 0; pendingBlanks-- {
+				ast.Body = append(ast.Body, Instruction{})
+			}
+		}
+		ast.Body = append(ast.Body, parseInstruction(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ast, nil
+}
+
+// parseInstruction parses a single trimmed, non-empty body line into an
+// Instruction: a standalone comment if the whole line is one, otherwise an
+// operation or directive split on its first "," into Target/Source, with
+// any trailing "; ..." split off into Comment first.
+func parseInstruction(line string) Instruction {
+	code, comment := splitTrailingComment(line)
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return Instruction{Comment: comment}
+	}
+	fields := strings.Fields(code)
+	op := fields[0]
+	rest := strings.TrimSpace(strings.TrimPrefix(code, op))
+	target, source, _ := strings.Cut(rest, ",")
+	return Instruction{
+		Op:      op,
+		Target:  strings.TrimSpace(target),
+		Source:  strings.TrimSpace(source),
+		Comment: comment,
+	}
+}
+
+// splitTrailingComment splits line on its first ";" into the code before
+// it and the trimmed comment text after (without the ";"), e.g.
+// "mov $1,$0 ; copy input" -> ("mov $1,$0 ", "copy input"). line is
+// returned unchanged with an empty comment if it has no ";".
+func splitTrailingComment(line string) (code, comment string) {
+	code, comment, found := strings.Cut(line, ";")
+	if !found {
+		return line, ""
+	}
+	return code, strings.TrimSpace(comment)
+}
+
+// String renders ast back to LODA source: every Header comment, then every
+// Body line, one per line.
+func (ast *ProgramAST) String() string {
+	lines := make([]string, 0, len(ast.Header)+len(ast.Body))
+	for _, c := range ast.Header {
+		lines = append(lines, "; "+c.Text)
+	}
+	for _, instr := range ast.Body {
+		lines = append(lines, instr.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IdAndName returns the program's ID and name parsed from its first
+// header comment of the form "A000045: Fibonacci numbers.", or a zero UID
+// and empty name if no header comment looks like one.
+func (ast *ProgramAST) IdAndName() (util.UID, string) {
+	for _, c := range ast.Header {
+		idStr, name, ok := strings.Cut(c.Text, ":")
+		if !ok {
+			continue
+		}
+		id, err := util.NewUIDFromString(strings.TrimSpace(idStr))
+		if err == nil {
+			return id, strings.TrimSpace(name)
+		}
+	}
+	return util.UID{}, ""
+}
+
+// SetIdAndName replaces the header comment IdAndName would have parsed
+// with "id: name", or appends one as the last header line if none
+// existed.
+func (ast *ProgramAST) SetIdAndName(id util.UID, name string) {
+	text := id.String() + ": " + name
+	for i, c := range ast.Header {
+		idStr, _, ok := strings.Cut(c.Text, ":")
+		if !ok {
+			continue
+		}
+		if _, err := util.NewUIDFromString(strings.TrimSpace(idStr)); err == nil {
+			ast.Header[i] = Comment{Text: text}
+			return
+		}
+	}
+	ast.Header = append(ast.Header, Comment{Text: text})
+}
+
+// submitterPrefix is the header comment prefix a program's submitter
+// credit is stored under, e.g. "; Submitted by N. J. A. Sloane".
+var submitterPrefix = "Submitted by "
+
+// Submitter returns the program's submitter, parsed from its
+// "Submitted by ..." header comment, or nil if it has none.
+func (ast *ProgramAST) Submitter() *Submitter {
+	for _, c := range ast.Header {
+		if after, ok := strings.CutPrefix(c.Text, submitterPrefix); ok {
+			return &Submitter{Name: strings.TrimSpace(after)}
+		}
+	}
+	return nil
+}
+
+// SetSubmitter replaces the header's "Submitted by ..." comment with
+// submitter's name, appends one as the last header line if there wasn't
+// one, or removes the line entirely if submitter is nil.
+func (ast *ProgramAST) SetSubmitter(submitter *Submitter) {
+	for i, c := range ast.Header {
+		if !strings.HasPrefix(c.Text, submitterPrefix) {
+			continue
+		}
+		if submitter == nil {
+			ast.Header = append(ast.Header[:i], ast.Header[i+1:]...)
+		} else {
+			ast.Header[i] = Comment{Text: submitterPrefix + submitter.Name}
+		}
+		return
+	}
+	if submitter != nil {
+		ast.Header = append(ast.Header, Comment{Text: submitterPrefix + submitter.Name})
+	}
+}
+
+// Formula returns the program's closed-form formula from its "Formula:
+// ..." header comment, or "" if it has none.
+func (ast *ProgramAST) Formula() string {
+	for _, c := range ast.Header {
+		if after, ok := strings.CutPrefix(c.Text, "Formula:"); ok {
+			return strings.TrimSpace(after)
+		}
+	}
+	return ""
+}
+
+// minerProfilePrefix is the comment prefix a program's miner profile is
+// recorded under. Unlike Submitter/Formula, a miner profile isn't always a
+// header comment, so MinerProfile also checks standalone body comments.
+const minerProfilePrefix = "Miner Profile:"
+
+// MinerProfile returns the program's miner profile from a "Miner Profile:
+// ..." comment, header or standalone body line, or "" if it has none.
+func (ast *ProgramAST) MinerProfile() string {
+	for _, c := range ast.Header {
+		if after, ok := strings.CutPrefix(c.Text, minerProfilePrefix); ok {
+			return strings.TrimSpace(after)
+		}
+	}
+	for _, instr := range ast.Body {
+		if !instr.IsComment() {
+			continue
+		}
+		if after, ok := strings.CutPrefix(instr.Comment, minerProfilePrefix); ok {
+			return strings.TrimSpace(after)
+		}
+	}
+	return ""
+}
+
+// Operations returns the code of every operation in Body, in order,
+// skipping blank lines, standalone comments and directives.
+func (ast *ProgramAST) Operations() []string {
+	var ops []string
+	for _, instr := range ast.Body {
+		if instr.IsBlank() || instr.IsComment() || instr.IsDirective() {
+			continue
+		}
+		ops = append(ops, instr.codeString())
+	}
+	return ops
+}
@@ -0,0 +1,149 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+func TestParseProgramAST_HeaderAndBody(t *testing.T) {
+	code := "; A000045: Fibonacci numbers.\n; Submitted by Christian Krause\n\nmov $1,1\nlpb $0\n  sub $0,1\n  add $1,$2\nlpe\n"
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	if len(ast.Header) != 2 {
+		t.Fatalf("expected 2 header comments, got %v", ast.Header)
+	}
+	if ast.Header[0].Text != "A000045: Fibonacci numbers." {
+		t.Errorf("Header[0]: got %q", ast.Header[0].Text)
+	}
+	ops := ast.Operations()
+	want := []string{"mov $1,1", "lpb $0", "sub $0,1", "add $1,$2", "lpe"}
+	if len(ops) != len(want) {
+		t.Fatalf("Operations: got %v, want %v", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("Operations[%d]: got %q, want %q", i, ops[i], want[i])
+		}
+	}
+}
+
+func TestParseProgramAST_IdAndNameAndSubmitter(t *testing.T) {
+	ast, err := ParseProgramAST("; A000045: Fibonacci numbers.\n; Submitted by Christian Krause\nmov $1,1\n")
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	id, name := ast.IdAndName()
+	if id.String() != "A000045" || name != "Fibonacci numbers." {
+		t.Errorf("IdAndName: got (%s, %q)", id.String(), name)
+	}
+	submitter := ast.Submitter()
+	if submitter == nil || submitter.Name != "Christian Krause" {
+		t.Errorf("Submitter: got %v", submitter)
+	}
+}
+
+func TestParseProgramAST_DirectiveAndInlineComment(t *testing.T) {
+	ast, err := ParseProgramAST("#offset 1\nmov $1,1 ; start at one\n; a standalone note\nadd $1,$2\n")
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	if len(ast.Body) != 4 {
+		t.Fatalf("expected 4 body lines, got %v", ast.Body)
+	}
+	if !ast.Body[0].IsDirective() || ast.Body[0].Target != "1" {
+		t.Errorf("Body[0]: got %+v", ast.Body[0])
+	}
+	if ast.Body[1].Comment != "start at one" {
+		t.Errorf("Body[1].Comment: got %q", ast.Body[1].Comment)
+	}
+	if !ast.Body[2].IsComment() || ast.Body[2].Comment != "a standalone note" {
+		t.Errorf("Body[2]: got %+v", ast.Body[2])
+	}
+	ops := ast.Operations()
+	if len(ops) != 2 || ops[0] != "mov $1,1" || ops[1] != "add $1,$2" {
+		t.Errorf("Operations: got %v", ops)
+	}
+}
+
+func TestParseProgramAST_MinerProfileOutsideHeader(t *testing.T) {
+	ast, err := ParseProgramAST("; A000045: Fibonacci numbers.\nmov $1,1\n; Miner Profile: default\nadd $1,$2\n")
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	if got := ast.MinerProfile(); got != "default" {
+		t.Errorf("MinerProfile: got %q", got)
+	}
+}
+
+func TestProgramASTStringRoundTrip(t *testing.T) {
+	code := "; A000045: Fibonacci numbers.\n; Submitted by Christian Krause\n\nmov $1,1\nadd $1,$2 ; step\n"
+	ast, err := ParseProgramAST(code)
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	if got := ast.String(); got != code[:len(code)-1] { // String doesn't add a trailing newline
+		t.Errorf("String round-trip:\ngot:  %q\nwant: %q", got, code[:len(code)-1])
+	}
+}
+
+func TestProgramASTSetIdAndName(t *testing.T) {
+	ast, err := ParseProgramAST("; A000045: Fibonacci numbers.\nmov $1,1\n")
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	id, _ := util.NewUIDFromString("A000040")
+	ast.SetIdAndName(id, "The prime numbers.")
+	gotId, gotName := ast.IdAndName()
+	if gotId.String() != "A000040" || gotName != "The prime numbers." {
+		t.Errorf("after SetIdAndName: got (%s, %q)", gotId.String(), gotName)
+	}
+	if len(ast.Header) != 1 {
+		t.Errorf("expected SetIdAndName to replace the existing line in place, got %v", ast.Header)
+	}
+}
+
+func TestProgramASTSetSubmitterAppendsAndRemoves(t *testing.T) {
+	ast, err := ParseProgramAST("; A000045: Fibonacci numbers.\nmov $1,1\n")
+	if err != nil {
+		t.Fatalf("ParseProgramAST: %v", err)
+	}
+	ast.SetSubmitter(&Submitter{Name: "Jane Doe"})
+	if len(ast.Header) != 2 || ast.Header[1].Text != submitterPrefix+"Jane Doe" {
+		t.Fatalf("after adding submitter: got %v", ast.Header)
+	}
+	ast.SetSubmitter(nil)
+	if len(ast.Header) != 1 {
+		t.Errorf("after removing submitter: got %v", ast.Header)
+	}
+}
+
+func TestExtractorsMatchProgramAST(t *testing.T) {
+	code := "; A000045: Fibonacci numbers.\n; Submitted by Christian Krause\n; Formula:a(n) = a(n-1) + a(n-2)\nmov $1,1\nadd $1,$2\n"
+	if ops := extractOperations(code); len(ops) != 2 {
+		t.Errorf("extractOperations: got %v", ops)
+	}
+	if id, name := extractIdAndName(code); id.String() != "A000045" || name != "Fibonacci numbers." {
+		t.Errorf("extractIdAndName: got (%s, %q)", id.String(), name)
+	}
+	if s := extractSubmitter(code); s == nil || s.Name != "Christian Krause" {
+		t.Errorf("extractSubmitter: got %v", s)
+	}
+	if f := extractFormula(code); f != "a(n) = a(n-1) + a(n-2)" {
+		t.Errorf("extractFormula: got %q", f)
+	}
+}
+
+func TestUpdateSubmitterDoesNotShadowLoopVariable(t *testing.T) {
+	// Regression test for the bug this AST was introduced to fix: the old
+	// updateSubmitter shadowed its range variable with a local "line :="
+	// instead of "line =", so its own reassignment to drop the submitter
+	// line was silently discarded.
+	code := "; A000045: Fibonacci numbers.\n; Submitted by Christian Krause\nmov $1,1\n"
+	got := updateSubmitter(code, nil)
+	if got != "; A000045: Fibonacci numbers.\nmov $1,1" {
+		t.Errorf("updateSubmitter(nil): got %q", got)
+	}
+}
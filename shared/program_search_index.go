@@ -0,0 +1,185 @@
+package shared
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ProgramSearchIndex is an inverted index over a DataIndex's Programs,
+// built once by DataIndex.Load so SearchPrograms can answer queries with
+// posting-list set operations instead of a linear scan over every program.
+type ProgramSearchIndex struct {
+	// tokens maps each lowercased, stemmed word found in a program's Name
+	// or Submitter.Name to the sorted offsets (indices into the Programs
+	// slice it was built from) of the programs containing it.
+	tokens map[string][]int
+	// keywords maps a single keyword's bit (see keywordToBit in keyword.go)
+	// to the sorted offsets of the programs having that keyword.
+	keywords map[uint64][]int
+	// size is the number of programs the index was built from, used as the
+	// full candidate set for a query with no tokens or keywords.
+	size int
+}
+
+// BuildProgramSearchIndex tokenizes every program's Name and
+// Submitter.Name and indexes its keywords, so SearchPrograms can intersect
+// and subtract posting lists instead of testing each program in turn.
+// Offsets are the programs' indices in the given slice, so callers must
+// rebuild the index whenever that slice is replaced, e.g. after a reload.
+func BuildProgramSearchIndex(programs []Program) *ProgramSearchIndex {
+	idx := &ProgramSearchIndex{
+		tokens:   make(map[string][]int),
+		keywords: make(map[uint64][]int),
+		size:     len(programs),
+	}
+	for offset, prog := range programs {
+		seen := make(map[string]struct{})
+		for _, tok := range tokenizeForSearch(prog.Name) {
+			seen[tok] = struct{}{}
+		}
+		if prog.Submitter != nil {
+			for _, tok := range tokenizeForSearch(prog.Submitter.Name) {
+				seen[tok] = struct{}{}
+			}
+		}
+		for tok := range seen {
+			idx.tokens[tok] = append(idx.tokens[tok], offset)
+		}
+		for i := range KeywordList {
+			bit := uint64(1) << uint(i)
+			if prog.Keywords&bit != 0 {
+				idx.keywords[bit] = append(idx.keywords[bit], offset)
+			}
+		}
+	}
+	return idx
+}
+
+// tokenizeForSearch splits s on runs of anything that isn't a letter or
+// digit (so it works for non-ASCII names too), lowercases each piece, and
+// stems it, so e.g. "Kolakoski sequences" indexes as ["kolakoski",
+// "sequence"].
+func tokenizeForSearch(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make([]string, len(fields))
+	for i, f := range fields {
+		tokens[i] = stemToken(strings.ToLower(f))
+	}
+	return tokens
+}
+
+// stemToken strips a trailing "es" or "s" from what looks like a plural,
+// e.g. "walks" -> "walk", "sequences" -> "sequence". Short words are left
+// alone so words like "is" or "gas" aren't mangled.
+func stemToken(s string) string {
+	switch {
+	case len(s) > 4 && strings.HasSuffix(s, "es"):
+		return s[:len(s)-2]
+	case len(s) > 3 && strings.HasSuffix(s, "s"):
+		return s[:len(s)-1]
+	default:
+		return s
+	}
+}
+
+// postings returns the sorted offsets of programs whose Name or
+// Submitter.Name contains token, after the same stemming used to build the
+// index.
+func (idx *ProgramSearchIndex) postings(token string) []int {
+	return idx.tokens[stemToken(token)]
+}
+
+// keywordPostings returns the sorted offsets of programs having the single
+// keyword bit.
+func (idx *ProgramSearchIndex) keywordPostings(bit uint64) []int {
+	return idx.keywords[bit]
+}
+
+// allOffsets returns every program offset 0..size-1, the candidate set for
+// a query with no tokens or keywords to intersect against.
+func (idx *ProgramSearchIndex) allOffsets() []int {
+	all := make([]int, idx.size)
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// programTrigramTexts returns each program's name and submitter name joined
+// by trigramFieldSep, for BuildTrigramIndex. Offsets match programs.
+func programTrigramTexts(programs []Program) []string {
+	texts := make([]string, len(programs))
+	for i, p := range programs {
+		fields := []string{p.Name}
+		if p.Submitter != nil {
+			fields = append(fields, p.Submitter.Name)
+		}
+		texts[i] = strings.Join(fields, trigramFieldSep)
+	}
+	return texts
+}
+
+// intersectSorted returns the sorted intersection of two sorted offset
+// slices.
+func intersectSorted(a, b []int) []int {
+	var out []int
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// unionSorted returns the sorted union of two sorted offset slices.
+func unionSorted(a, b []int) []int {
+	var out []int
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	out = append(out, b[j:]...)
+	return out
+}
+
+// subtractSorted returns a with every offset present in the sorted slice b
+// removed.
+func subtractSorted(a, b []int) []int {
+	if len(b) == 0 {
+		return a
+	}
+	var out []int
+	j := 0
+	for _, v := range a {
+		for j < len(b) && b[j] < v {
+			j++
+		}
+		if j < len(b) && b[j] == v {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,541 @@
+package shared
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// QueryNode is one node of a search query's parsed AST, as produced by
+// parseQueryAST and evaluated against a Program or Sequence via Eval.
+type QueryNode interface {
+	Eval(rec queryRecord) bool
+}
+
+// queryRecord is the subset of Program/Sequence fields QueryNode.Eval needs,
+// so the same AST evaluates both without FieldMatchNode/RangeNode knowing
+// which one they're matching against. Fields a record type doesn't have
+// (e.g. a Sequence's operations) return the zero value, so a scope for that
+// field simply never matches.
+type queryRecord interface {
+	queryName() string
+	querySubmitter() string
+	queryAuthors() []string
+	queryKeywords() uint64
+	queryUID() util.UID
+	queryOps() []string
+	queryFormula() string
+	queryUsages() string
+	queryLength() int
+}
+
+// AndNode matches when every child matches. An AndNode with no children is
+// the identity: it always matches, which is how a query that turns out to
+// need no AST constraint (e.g. just a bare ID) is represented.
+type AndNode struct {
+	Children []QueryNode
+}
+
+func (n *AndNode) Eval(rec queryRecord) bool {
+	for _, c := range n.Children {
+		if !c.Eval(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrNode matches when any child matches.
+type OrNode struct {
+	Children []QueryNode
+}
+
+func (n *OrNode) Eval(rec queryRecord) bool {
+	for _, c := range n.Children {
+		if c.Eval(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotNode matches when Child does not.
+type NotNode struct {
+	Child QueryNode
+}
+
+func (n *NotNode) Eval(rec queryRecord) bool {
+	return !n.Child.Eval(rec)
+}
+
+// FieldMatchNode matches when the text named by Field contains Value as a
+// case-insensitive substring. Field == "" is the default, unscoped match a
+// plain token or quoted phrase had before field scopes existed: name,
+// submitter name, or any author name. Field == "id" is the exception: it
+// compares Value, parsed as a UID, for equality rather than substring.
+type FieldMatchNode struct {
+	Field string
+	Value string
+}
+
+func (n *FieldMatchNode) Eval(rec queryRecord) bool {
+	lower := strings.ToLower(n.Value)
+	switch n.Field {
+	case "":
+		if strings.Contains(strings.ToLower(rec.queryName()), lower) {
+			return true
+		}
+		if strings.Contains(strings.ToLower(rec.querySubmitter()), lower) {
+			return true
+		}
+		for _, a := range rec.queryAuthors() {
+			if strings.Contains(strings.ToLower(a), lower) {
+				return true
+			}
+		}
+		return false
+	case "name":
+		return strings.Contains(strings.ToLower(rec.queryName()), lower)
+	case "submitter":
+		return strings.Contains(strings.ToLower(rec.querySubmitter()), lower)
+	case "author":
+		for _, a := range rec.queryAuthors() {
+			if strings.Contains(strings.ToLower(a), lower) {
+				return true
+			}
+		}
+		return false
+	case "op":
+		for _, op := range rec.queryOps() {
+			if strings.EqualFold(op, n.Value) {
+				return true
+			}
+		}
+		return false
+	case "formula":
+		return strings.Contains(strings.ToLower(rec.queryFormula()), lower)
+	case "usages":
+		for _, u := range strings.Fields(rec.queryUsages()) {
+			if strings.EqualFold(u, n.Value) {
+				return true
+			}
+		}
+		return false
+	case "id":
+		uid, err := util.NewUIDFromString(n.Value)
+		return err == nil && rec.queryUID().Equals(uid)
+	default:
+		return false
+	}
+}
+
+// RangeNode matches when Field's numeric value falls within [Min, Max],
+// whichever bounds HasMin/HasMax say are set. Currently the only supported
+// field is "length": a program's operation count, or a sequence's known
+// term count.
+type RangeNode struct {
+	Field          string
+	Min, Max       int
+	HasMin, HasMax bool
+}
+
+func (n *RangeNode) Eval(rec queryRecord) bool {
+	if n.Field != "length" {
+		return false
+	}
+	v := rec.queryLength()
+	if n.HasMin && v < n.Min {
+		return false
+	}
+	if n.HasMax && v > n.Max {
+		return false
+	}
+	return true
+}
+
+// KeywordNode matches when rec has (or, if Exclude, lacks) the keyword bit.
+type KeywordNode struct {
+	Bit     uint64
+	Exclude bool
+}
+
+func (n *KeywordNode) Eval(rec queryRecord) bool {
+	has := rec.queryKeywords()&n.Bit != 0
+	if n.Exclude {
+		return !has
+	}
+	return has
+}
+
+// programQueryRecord adapts a Program to queryRecord.
+type programQueryRecord struct{ p *Program }
+
+func (r programQueryRecord) queryName() string { return r.p.Name }
+func (r programQueryRecord) querySubmitter() string {
+	if r.p.Submitter == nil {
+		return ""
+	}
+	return r.p.Submitter.Name
+}
+func (r programQueryRecord) queryAuthors() []string { return nil }
+func (r programQueryRecord) queryKeywords() uint64  { return r.p.Keywords }
+func (r programQueryRecord) queryUID() util.UID     { return r.p.Id }
+func (r programQueryRecord) queryOps() []string     { return r.p.Operations }
+func (r programQueryRecord) queryFormula() string   { return r.p.Formula }
+func (r programQueryRecord) queryUsages() string    { return r.p.Usages }
+func (r programQueryRecord) queryLength() int       { return r.p.Length }
+
+// sequenceQueryRecord adapts a Sequence to queryRecord. Sequences have no
+// operations, formula, or usages, so those scopes never match one.
+type sequenceQueryRecord struct{ s *Sequence }
+
+func (r sequenceQueryRecord) queryName() string { return r.s.Name }
+func (r sequenceQueryRecord) querySubmitter() string {
+	if r.s.Submitter == nil {
+		return ""
+	}
+	return r.s.Submitter.Name
+}
+func (r sequenceQueryRecord) queryAuthors() []string {
+	names := make([]string, len(r.s.Authors))
+	for i, a := range r.s.Authors {
+		names[i] = a.Name
+	}
+	return names
+}
+func (r sequenceQueryRecord) queryKeywords() uint64 { return r.s.Keywords }
+func (r sequenceQueryRecord) queryUID() util.UID    { return r.s.Id }
+func (r sequenceQueryRecord) queryOps() []string    { return nil }
+func (r sequenceQueryRecord) queryFormula() string  { return "" }
+func (r sequenceQueryRecord) queryUsages() string   { return "" }
+func (r sequenceQueryRecord) queryLength() int      { return len(r.s.TermsList()) }
+
+// queryFields lists the field scopes parseFieldNode accepts.
+var queryFields = map[string]bool{
+	"name": true, "submitter": true, "author": true, "op": true,
+	"id": true, "length": true, "usages": true, "formula": true,
+}
+
+// parseQueryAST parses query into a QueryNode tree, supporting field-scoped
+// predicates (name:, submitter:, author:, op:, id:, length:, usages:,
+// formula:), quoted phrases, +/-/! prefixes, and a top-level OR with
+// parenthesised groups. It returns (nil, nil) for an empty query, meaning
+// "match everything". A malformed query (an unterminated quote, an
+// unbalanced parenthesis, an unknown field, a malformed range, ...) returns
+// an error; callers should treat that the same as an empty AST rather than
+// fail the whole search, since a search box shouldn't 500 on a typo.
+func parseQueryAST(query string) (QueryNode, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+// isAdvancedQuery reports whether node uses anything beyond a plain AND of
+// tokens and +/-keywords: a field scope, a range, an OR, or a NOT applied to
+// something other than a keyword. SearchPrograms and SearchSequences use
+// this to keep evaluating a simple query via posting-list intersection,
+// falling back to a per-record AST walk only once a query actually needs
+// it.
+func isAdvancedQuery(node QueryNode) bool {
+	switch n := node.(type) {
+	case nil:
+		return false
+	case *AndNode:
+		for _, c := range n.Children {
+			if isAdvancedQuery(c) {
+				return true
+			}
+		}
+		return false
+	case *OrNode:
+		return true
+	case *NotNode:
+		if _, ok := n.Child.(*KeywordNode); ok {
+			return false
+		}
+		return true
+	case *FieldMatchNode:
+		return n.Field != ""
+	case *RangeNode:
+		return true
+	case *KeywordNode:
+		return false
+	default:
+		return true
+	}
+}
+
+// lexQuery splits query into tokens: "(" and ")" are always their own
+// token; anything else runs until the next whitespace or parenthesis, with
+// "..."-quoted spans (supporting \-escapes) read as literal text, so e.g.
+// `submitter:"Neil Sloane"` lexes as the single token `submitter:Neil
+// Sloane`.
+func lexQuery(query string) ([]string, error) {
+	var tokens []string
+	r := []rune(query)
+	n := len(r)
+	i := 0
+	for i < n {
+		c := r[i]
+		if c == ' ' || c == '\t' || c == '\n' {
+			i++
+			continue
+		}
+		if c == '(' || c == ')' {
+			tokens = append(tokens, string(c))
+			i++
+			continue
+		}
+		var sb strings.Builder
+		for i < n {
+			c = r[i]
+			if c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')' {
+				break
+			}
+			if c == '"' {
+				i++
+				closed := false
+				for i < n {
+					if r[i] == '\\' && i+1 < n {
+						sb.WriteRune(r[i+1])
+						i += 2
+						continue
+					}
+					if r[i] == '"' {
+						closed = true
+						i++
+						break
+					}
+					sb.WriteRune(r[i])
+					i++
+				}
+				if !closed {
+					return nil, fmt.Errorf("unterminated quote in query")
+				}
+				continue
+			}
+			sb.WriteRune(c)
+			i++
+		}
+		tokens = append(tokens, sb.String())
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive-descent parser over lexQuery's token stream:
+//
+//	query  := orExpr
+//	orExpr := andExpr ("OR" andExpr)*
+//	andExpr:= term*                         // implicit AND
+//	term   := "(" orExpr ")" | atom
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *queryParser) next() (string, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+func (p *queryParser) parseOr() (QueryNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []QueryNode{first}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok != "OR" {
+			break
+		}
+		p.next()
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &OrNode{Children: children}, nil
+}
+
+func (p *queryParser) parseAnd() (QueryNode, error) {
+	var children []QueryNode
+	for {
+		tok, ok := p.peek()
+		if !ok || tok == "OR" || tok == ")" {
+			break
+		}
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if term != nil {
+			children = append(children, term)
+		}
+	}
+	if len(children) == 0 {
+		return &AndNode{}, nil
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &AndNode{Children: children}, nil
+}
+
+func (p *queryParser) parseTerm() (QueryNode, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	if tok == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closeTok, ok := p.next()
+		if !ok || closeTok != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return node, nil
+	}
+	if tok == ")" {
+		return nil, fmt.Errorf("unexpected closing parenthesis")
+	}
+	return parseAtomToken(tok)
+}
+
+// parseAtomToken turns a single lexed token into a QueryNode: nil, nil for
+// a bare UID (already enforced via SearchQuery.UIDTokens, so it contributes
+// no further AST constraint), a KeywordNode for a (+/-/!)keyword, a
+// field-scoped node for "field:value", or a default FieldMatchNode for
+// anything else.
+func parseAtomToken(tok string) (QueryNode, error) {
+	if tok == "" {
+		return nil, nil
+	}
+	if _, err := util.NewUIDFromString(tok); err == nil {
+		return nil, nil
+	}
+	exclude := false
+	rest := tok
+	if len(rest) > 1 && (rest[0] == '-' || rest[0] == '!') {
+		exclude = true
+		rest = rest[1:]
+	} else if len(rest) > 1 && rest[0] == '+' {
+		rest = rest[1:]
+	}
+	lower := strings.ToLower(rest)
+	if IsKeyword(lower) {
+		bit, _ := EncodeKeywords([]string{lower})
+		return &KeywordNode{Bit: bit, Exclude: exclude}, nil
+	}
+	var node QueryNode
+	if idx := strings.IndexByte(rest, ':'); idx > 0 {
+		field := strings.ToLower(rest[:idx])
+		value := rest[idx+1:]
+		fieldNode, err := parseFieldNode(field, value)
+		if err != nil {
+			return nil, err
+		}
+		node = fieldNode
+	} else {
+		node = &FieldMatchNode{Field: "", Value: rest}
+	}
+	if exclude {
+		return &NotNode{Child: node}, nil
+	}
+	return node, nil
+}
+
+func parseFieldNode(field, value string) (QueryNode, error) {
+	if !queryFields[field] {
+		return nil, fmt.Errorf("unknown query field %q", field)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("empty value for field %q", field)
+	}
+	if field == "length" {
+		return parseRangeNode(field, value)
+	}
+	return &FieldMatchNode{Field: field, Value: value}, nil
+}
+
+// parseRangeNode parses value as "N", "N..M", "<N", "<=N", ">N", or ">=N".
+func parseRangeNode(field, value string) (QueryNode, error) {
+	n := &RangeNode{Field: field}
+	switch {
+	case strings.Contains(value, ".."):
+		parts := strings.SplitN(value, "..", 2)
+		lo, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		hi, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		n.Min, n.HasMin = lo, true
+		n.Max, n.HasMax = hi, true
+	case strings.HasPrefix(value, "<="):
+		v, err := strconv.Atoi(value[2:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		n.Max, n.HasMax = v, true
+	case strings.HasPrefix(value, ">="):
+		v, err := strconv.Atoi(value[2:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		n.Min, n.HasMin = v, true
+	case strings.HasPrefix(value, "<"):
+		v, err := strconv.Atoi(value[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		n.Max, n.HasMax = v-1, true
+	case strings.HasPrefix(value, ">"):
+		v, err := strconv.Atoi(value[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		n.Min, n.HasMin = v+1, true
+	default:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed range %q: %w", value, err)
+		}
+		n.Min, n.HasMin = v, true
+		n.Max, n.HasMax = v, true
+	}
+	return n, nil
+}
@@ -1,98 +1,487 @@
 package shared
 
 import (
-	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/loda-lang/loda-api/util"
 )
 
-const RefreshQueueFile = "refresh_queue.txt"
+const (
+	// refreshQueueSegmentPrefix and refreshQueueSegmentSuffix bracket the
+	// zero-padded sequence number in a segment's file name, e.g.
+	// "refresh_queue.00000001.dat".
+	refreshQueueSegmentPrefix = "refresh_queue."
+	refreshQueueSegmentSuffix = ".dat"
 
-// RefreshQueue manages a file-based queue of sequence IDs to refresh
+	// RefreshQueueMetaFile records the write segment's sequence number, so a
+	// restart doesn't reuse the number of a segment deleted by a prior
+	// DequeueAll or Compact.
+	RefreshQueueMetaFile = "refresh_queue.meta"
+
+	// RefreshQueueSegmentMaxBytes bounds how large the active segment may
+	// grow before Enqueue rotates it into a sealed segment and starts a
+	// fresh one. Keeping segments small bounds how many pending entries a
+	// single corrupt frame can strand behind it.
+	RefreshQueueSegmentMaxBytes = 1 * 1024 * 1024
+
+	// refreshQueueFrameHeaderSize is the length of the length+CRC32 header
+	// that precedes every frame's payload.
+	refreshQueueFrameHeaderSize = 8
+)
+
+// refreshQueueMeta is persisted as RefreshQueueMetaFile and tracks which
+// segment is being written to and which is the oldest segment still
+// holding unconsumed entries.
+type refreshQueueMeta struct {
+	WriteSegment int64 `json:"write_segment"`
+	ReadSegment  int64 `json:"read_segment"`
+}
+
+// RefreshQueue is a crash-safe, disk-backed queue of sequence IDs to
+// refresh, modeled on NSQ's segmented disk queue. Entries are appended as
+// length-prefixed, CRC32-checked frames to a rolling set of segment files
+// (refresh_queue.NNNNNNNN.dat), capped at RefreshQueueSegmentMaxBytes, with
+// RefreshQueueMetaFile recording the read/write segment indices. Peek/Ack
+// let a consumer take pending IDs off the queue and only remove them from
+// disk once it's actually finished with them, so a crash between the two
+// can't drop one; DequeueAll collapses both into a single all-at-once call
+// for callers that don't need that split. Every scan skips any frame that
+// fails its CRC check instead of wedging the queue.
 type RefreshQueue struct {
-	dataDir string
-	mutex   sync.Mutex
+	dataDir         string
+	maxSegmentBytes int64
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+	meta  refreshQueueMeta
 }
 
-// NewRefreshQueue creates a new RefreshQueue
+// NewRefreshQueue creates a new RefreshQueue backed by dataDir, loading its
+// meta file if one already exists.
 func NewRefreshQueue(dataDir string) *RefreshQueue {
-	return &RefreshQueue{
-		dataDir: dataDir,
+	rq := &RefreshQueue{
+		dataDir:         dataDir,
+		maxSegmentBytes: RefreshQueueSegmentMaxBytes,
+		meta:            refreshQueueMeta{WriteSegment: 1, ReadSegment: 1},
+	}
+	if data, err := os.ReadFile(rq.metaPath()); err == nil {
+		var meta refreshQueueMeta
+		if err := json.Unmarshal(data, &meta); err == nil && meta.WriteSegment > 0 {
+			rq.meta = meta
+		}
 	}
+	return rq
 }
 
-// getQueuePath returns the path to the refresh queue file
-func (rq *RefreshQueue) getQueuePath() string {
-	return filepath.Join(rq.dataDir, RefreshQueueFile)
+func (rq *RefreshQueue) metaPath() string {
+	return filepath.Join(rq.dataDir, RefreshQueueMetaFile)
 }
 
-// Enqueue adds a sequence ID to the refresh queue
-func (rq *RefreshQueue) Enqueue(id util.UID) error {
-	rq.mutex.Lock()
-	defer rq.mutex.Unlock()
+func (rq *RefreshQueue) segmentPath(seq int64) string {
+	return filepath.Join(rq.dataDir, fmt.Sprintf("%s%08d%s", refreshQueueSegmentPrefix, seq, refreshQueueSegmentSuffix))
+}
 
-	queuePath := rq.getQueuePath()
-	file, err := os.OpenFile(queuePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// segments returns the sequence numbers of every existing segment file in
+// dataDir, lowest (oldest) first.
+func (rq *RefreshQueue) segments() ([]int64, error) {
+	matches, err := filepath.Glob(filepath.Join(rq.dataDir, refreshQueueSegmentPrefix+"*"+refreshQueueSegmentSuffix))
 	if err != nil {
-		return fmt.Errorf("failed to open refresh queue: %v", err)
+		return nil, fmt.Errorf("cannot list refresh queue segments: %w", err)
 	}
-	defer file.Close()
+	seqs := make([]int64, 0, len(matches))
+	for _, path := range matches {
+		if seq, ok := parseSegmentSeq(path); ok {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
 
-	// Write the numeric ID (without the 'A' prefix)
-	_, err = fmt.Fprintf(file, "%d\n", id.Number())
+func parseSegmentSeq(path string) (int64, bool) {
+	name := filepath.Base(path)
+	name = strings.TrimPrefix(name, refreshQueueSegmentPrefix)
+	name = strings.TrimSuffix(name, refreshQueueSegmentSuffix)
+	seq, err := strconv.ParseInt(name, 10, 64)
 	if err != nil {
-		return fmt.Errorf("failed to write to refresh queue: %v", err)
+		return 0, false
 	}
+	return seq, true
+}
 
+// saveMeta writes rq.meta to RefreshQueueMetaFile via a temp file plus
+// atomic rename, so a crash mid-write cannot leave a half-written meta
+// file. Caller must hold mutex.
+func (rq *RefreshQueue) saveMeta() error {
+	data, err := json.Marshal(rq.meta)
+	if err != nil {
+		return fmt.Errorf("cannot marshal refresh queue meta: %w", err)
+	}
+	tmpPath := rq.metaPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("cannot write refresh queue meta: %w", err)
+	}
+	if err := os.Rename(tmpPath, rq.metaPath()); err != nil {
+		return fmt.Errorf("cannot rename refresh queue meta: %w", err)
+	}
+	return nil
+}
+
+// appendFrame writes payload to w as a uint32 length, a uint32 CRC32 of
+// payload, and then payload itself, so a corrupt or truncated frame can be
+// detected on replay.
+func appendFrame(w io.Writer, payload []byte) error {
+	var header [refreshQueueFrameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(header[:]); err != nil {
+		return fmt.Errorf("cannot write frame header: %w", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return fmt.Errorf("cannot write frame payload: %w", err)
+	}
 	return nil
 }
 
-// DequeueAll reads all IDs from the queue and clears the file
-func (rq *RefreshQueue) DequeueAll() ([]int, error) {
+// countingReader wraps an io.Reader to track how many bytes have been
+// read, so readFrames can report the offset of a torn trailing frame.
+type countingReader struct {
+	r      io.Reader
+	offset int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+// readFrames reads length-prefixed, CRC32-checked frames from r, decoding
+// each payload as a big-endian int64 ID. A frame whose CRC doesn't match is
+// logged and skipped without aborting the scan, so a single bit-flipped
+// frame cannot wedge the rest of the segment. It returns the byte offset of
+// a torn trailing frame (an incomplete header or payload, e.g. from a crash
+// mid-append), or -1 if the segment ended cleanly.
+func readFrames(r io.Reader) ([]int, int64) {
+	var ids []int
+	reader := &countingReader{r: r}
+	for {
+		var header [refreshQueueFrameHeaderSize]byte
+		n, err := io.ReadFull(reader, header[:])
+		if err != nil {
+			if err == io.EOF && n == 0 {
+				return ids, -1
+			}
+			return ids, reader.offset - int64(n)
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		crc := binary.BigEndian.Uint32(header[4:8])
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return ids, reader.offset - int64(length)
+		}
+		if crc32.ChecksumIEEE(payload) != crc {
+			log.Printf("Skipping corrupt refresh queue frame at offset %d: checksum mismatch", reader.offset-int64(length))
+			continue
+		}
+		if len(payload) != 8 {
+			log.Printf("Skipping corrupt refresh queue frame at offset %d: unexpected payload length %d", reader.offset-int64(length), len(payload))
+			continue
+		}
+		ids = append(ids, int(int64(binary.BigEndian.Uint64(payload))))
+	}
+}
+
+// Enqueue adds a sequence ID to the refresh queue, appending it to the
+// active write segment and rotating to a fresh segment if it has grown too
+// large.
+func (rq *RefreshQueue) Enqueue(id util.UID) error {
 	rq.mutex.Lock()
 	defer rq.mutex.Unlock()
+	return rq.enqueueLocked(id.Number())
+}
 
-	queuePath := rq.getQueuePath()
-	
-	// Check if file exists
-	if !util.FileExists(queuePath) {
-		return []int{}, nil
+// enqueueLocked appends number to the active write segment. Caller must
+// hold mutex.
+func (rq *RefreshQueue) enqueueLocked(number int64) error {
+	if rq.file == nil {
+		if err := rq.openWriteSegment(); err != nil {
+			return err
+		}
+	}
+	var payload [8]byte
+	binary.BigEndian.PutUint64(payload[:], uint64(number))
+	if err := appendFrame(rq.file, payload[:]); err != nil {
+		return err
 	}
+	if err := rq.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync refresh queue segment: %w", err)
+	}
+	rq.size += refreshQueueFrameHeaderSize + int64(len(payload))
+	if rq.size >= rq.maxSegmentBytes {
+		if err := rq.rotate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	file, err := os.Open(queuePath)
+// openWriteSegment opens (creating if necessary) the active write segment.
+// If a prior crash left a torn trailing frame, it's truncated off first --
+// otherwise O_APPEND would start writing new frames right after the stale
+// header bytes, and the next scan would misread them as a corrupt frame
+// instead of the garbage they are, while also losing every frame appended
+// since the reopen. Caller must hold mutex.
+func (rq *RefreshQueue) openWriteSegment() error {
+	if err := os.MkdirAll(rq.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	path := rq.segmentPath(rq.meta.WriteSegment)
+	if _, err := os.Stat(path); err == nil {
+		if _, tornAt := rq.readSegment(rq.meta.WriteSegment); tornAt >= 0 {
+			log.Printf("Truncating refresh queue segment %s to %d bytes: torn trailing frame", path, tornAt)
+			if err := os.Truncate(path, tornAt); err != nil {
+				return fmt.Errorf("failed to truncate torn refresh queue segment: %w", err)
+			}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open refresh queue: %v", err)
+		return fmt.Errorf("failed to open refresh queue segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat refresh queue segment: %w", err)
+	}
+	rq.file = f
+	rq.size = info.Size()
+	return nil
+}
+
+// rotate seals the active segment and opens a fresh, empty one under the
+// next sequence number. Caller must hold mutex.
+func (rq *RefreshQueue) rotate() error {
+	if err := rq.file.Close(); err != nil {
+		return fmt.Errorf("failed to close refresh queue segment: %w", err)
 	}
-	defer file.Close()
+	rq.file = nil
+	rq.meta.WriteSegment++
+	if err := rq.saveMeta(); err != nil {
+		return err
+	}
+	return rq.openWriteSegment()
+}
 
+// Peek returns up to n pending IDs (every one of them if n <= 0), oldest
+// first, without removing them from the queue. A consumer that wants to
+// process them durably must call Ack once it's actually done with an ID --
+// until then, Peek keeps handing it out, so a crash between Peek and Ack
+// can't lose it. ctx is checked before each segment; on cancellation the
+// scan stops early and returns whatever it had already read.
+func (rq *RefreshQueue) Peek(ctx context.Context, n int) ([]int, error) {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	seqs, err := rq.segments()
+	if err != nil {
+		return nil, err
+	}
 	var ids []int
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+	for _, seq := range seqs {
+		if ctx.Err() != nil {
+			break
 		}
-		id, err := strconv.Atoi(line)
-		if err != nil {
-			// Skip invalid lines
+		segIds, _ := rq.readSegment(seq)
+		ids = append(ids, segIds...)
+		if n > 0 && len(ids) >= n {
+			return ids[:n], nil
+		}
+	}
+	return ids, nil
+}
+
+// Ack removes ids from the queue via a compaction pass: every pending entry
+// is read back from disk (same scan DequeueAll used to do destructively),
+// the acked ones are dropped, and the rest is rewritten. An ID a consumer
+// hasn't acked yet stays right where Peek found it, so a crash partway
+// through processing it just means the next Peek hands it out again
+// instead of it vanishing from the queue before anyone actually handled it.
+func (rq *RefreshQueue) Ack(ctx context.Context, ids []int) error {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	if len(ids) == 0 {
+		return nil
+	}
+	acked := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+	pending, err := rq.drainLocked(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range pending {
+		if acked[id] {
 			continue
 		}
-		ids = append(ids, id)
+		if err := rq.enqueueLocked(int64(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DequeueAll reads every pending ID and removes them all in one step. It's
+// Peek(ctx, 0) followed by Ack of everything Peek returned, collapsed into
+// a single call for callers -- startup replay, Compact -- that want the
+// whole queue at once and have nowhere better to ack from partway through.
+func (rq *RefreshQueue) DequeueAll(ctx context.Context) ([]int, error) {
+	ids, err := rq.Peek(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []int{}, nil
+	}
+	if err := rq.Ack(ctx, ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// drainLocked reads every pending ID across all segments, then deletes
+// them and advances the read/write segment past the now-obsolete ones.
+// ctx is checked before each segment; on cancellation it stops early
+// instead of touching the remaining segments. Caller must hold mutex.
+func (rq *RefreshQueue) drainLocked(ctx context.Context) ([]int, error) {
+	seqs, err := rq.segments()
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	consumed := 0
+	for _, seq := range seqs {
+		if ctx.Err() != nil {
+			break
+		}
+		segIds, tornAt := rq.readSegment(seq)
+		if tornAt >= 0 {
+			log.Printf("Refresh queue segment %d has a torn trailing frame at offset %d", seq, tornAt)
+			if err := os.Truncate(rq.segmentPath(seq), tornAt); err != nil && !os.IsNotExist(err) {
+				log.Printf("Cannot truncate torn refresh queue segment %d: %v", seq, err)
+			}
+		}
+		ids = append(ids, segIds...)
+		consumed++
+	}
+	if consumed == 0 {
+		return ids, nil
+	}
+	fullyDrained := consumed == len(seqs)
+	if fullyDrained && rq.file != nil {
+		if err := rq.file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close refresh queue segment: %w", err)
+		}
+		rq.file = nil
+	}
+	for _, seq := range seqs[:consumed] {
+		if err := os.Remove(rq.segmentPath(seq)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Cannot remove consumed refresh queue segment %d: %v", seq, err)
+		}
+	}
+	if !fullyDrained {
+		log.Printf("Refresh queue drain cancelled after %d/%d segments; leaving the rest for the next call", consumed, len(seqs))
+		return ids, nil
+	}
+	nextWriteSegment := rq.meta.WriteSegment
+	if len(seqs) > 0 {
+		nextWriteSegment++
 	}
+	rq.meta = refreshQueueMeta{WriteSegment: nextWriteSegment, ReadSegment: nextWriteSegment}
+	if err := rq.saveMeta(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading refresh queue: %v", err)
+// readSegment reads and returns every valid ID in segment seq, along with
+// the byte offset of a torn trailing frame, or -1 if it ended cleanly.
+func (rq *RefreshQueue) readSegment(seq int64) ([]int, int64) {
+	f, err := os.Open(rq.segmentPath(seq))
+	if err != nil {
+		log.Printf("Cannot open refresh queue segment %d: %v", seq, err)
+		return nil, -1
 	}
+	defer f.Close()
+	return readFrames(f)
+}
 
-	// Clear the file by truncating it
-	if err := os.Truncate(queuePath, 0); err != nil {
-		return nil, fmt.Errorf("failed to clear refresh queue: %v", err)
+// Depth returns the number of entries currently pending in the queue.
+func (rq *RefreshQueue) Depth() int64 {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	ids, err := rq.pendingLocked()
+	if err != nil {
+		log.Printf("Cannot compute refresh queue depth: %v", err)
+		return 0
 	}
+	return int64(len(ids))
+}
 
+// PendingIDs returns every ID currently pending across all segments without
+// removing them, for dedup checks against IDs already waiting in the queue.
+func (rq *RefreshQueue) PendingIDs() ([]int, error) {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	return rq.pendingLocked()
+}
+
+// pendingLocked reads every segment without consuming it. Caller must hold
+// mutex.
+func (rq *RefreshQueue) pendingLocked() ([]int, error) {
+	seqs, err := rq.segments()
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, seq := range seqs {
+		segIds, _ := rq.readSegment(seq)
+		ids = append(ids, segIds...)
+	}
 	return ids, nil
 }
+
+// Compact rewrites the queue with duplicate IDs removed, preserving the
+// order of first occurrence.
+func (rq *RefreshQueue) Compact() error {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	ids, err := rq.drainLocked(context.Background())
+	if err != nil {
+		return err
+	}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if err := rq.enqueueLocked(int64(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
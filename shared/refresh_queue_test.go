@@ -1,8 +1,10 @@
 package shared
 
 import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
 	"os"
-	"path/filepath"
 	"testing"
 
 	"github.com/loda-lang/loda-api/util"
@@ -31,7 +33,7 @@ func TestRefreshQueue_EnqueueAndDequeue(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Dequeue all IDs
-	ids, err := rq.DequeueAll()
+	ids, err := rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(ids))
 	assert.Contains(t, ids, 45)
@@ -39,7 +41,7 @@ func TestRefreshQueue_EnqueueAndDequeue(t *testing.T) {
 	assert.Contains(t, ids, 1)
 
 	// Verify queue is empty after dequeue
-	ids, err = rq.DequeueAll()
+	ids, err = rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(ids))
 }
@@ -54,7 +56,7 @@ func TestRefreshQueue_DequeueEmptyQueue(t *testing.T) {
 	rq := NewRefreshQueue(tempDir)
 
 	// Dequeue from empty queue should return empty list
-	ids, err := rq.DequeueAll()
+	ids, err := rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(ids))
 }
@@ -73,7 +75,7 @@ func TestRefreshQueue_MultipleEnqueueDequeue(t *testing.T) {
 	err = rq.Enqueue(id1)
 	assert.NoError(t, err)
 
-	ids, err := rq.DequeueAll()
+	ids, err := rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(ids))
 	assert.Contains(t, ids, 45)
@@ -86,7 +88,7 @@ func TestRefreshQueue_MultipleEnqueueDequeue(t *testing.T) {
 	err = rq.Enqueue(id3)
 	assert.NoError(t, err)
 
-	ids, err = rq.DequeueAll()
+	ids, err = rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(ids))
 	assert.Contains(t, ids, 142)
@@ -118,7 +120,7 @@ func TestRefreshQueue_ThreadSafety(t *testing.T) {
 	}
 
 	// Dequeue and verify we got all entries
-	ids, err := rq.DequeueAll()
+	ids, err := rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 10, len(ids))
 }
@@ -137,31 +139,289 @@ func TestRefreshQueue_PersistenceAcrossInstances(t *testing.T) {
 
 	// Create second refresh queue instance and dequeue
 	rq2 := NewRefreshQueue(tempDir)
-	ids, err := rq2.DequeueAll()
+	ids, err := rq2.DequeueAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(ids))
 	assert.Contains(t, ids, 45)
 }
 
-func TestRefreshQueue_InvalidLines(t *testing.T) {
-	// Create a temporary directory for testing
+func TestRefreshQueue_SegmentRotation(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
 	assert.NoError(t, err)
 	defer os.RemoveAll(tempDir)
 
-	// Create a queue file with some invalid lines
-	queuePath := filepath.Join(tempDir, RefreshQueueFile)
-	content := "123\ninvalid\n456\n\n789\n"
-	err = os.WriteFile(queuePath, []byte(content), 0644)
+	rq := NewRefreshQueue(tempDir)
+	rq.maxSegmentBytes = refreshQueueFrameHeaderSize + 8 // rotate after every entry
+
+	for i := 1; i <= 5; i++ {
+		id, err := util.NewUID('A', int64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, rq.Enqueue(id))
+	}
+	seqs, err := rq.segments()
+	assert.NoError(t, err)
+	assert.Greater(t, len(seqs), 1)
+
+	ids, err := rq.DequeueAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(ids))
+
+	seqs, err = rq.segments()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(seqs))
+}
+
+// writeFrame appends a single length+CRC32-prefixed frame to path, matching
+// the on-disk format RefreshQueue itself writes.
+func writeFrame(t *testing.T, path string, number int64, corrupt bool) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	defer f.Close()
+	var payload [8]byte
+	binary.BigEndian.PutUint64(payload[:], uint64(number))
+	crc := crc32.ChecksumIEEE(payload[:])
+	if corrupt {
+		crc ^= 0xffffffff
+	}
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc)
+	_, err = f.Write(header[:])
+	assert.NoError(t, err)
+	_, err = f.Write(payload[:])
 	assert.NoError(t, err)
+}
+
+func TestRefreshQueue_SkipsCorruptFrame(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
 
-	// Create refresh queue and dequeue
 	rq := NewRefreshQueue(tempDir)
-	ids, err := rq.DequeueAll()
+	segmentPath := rq.segmentPath(rq.meta.WriteSegment)
+	writeFrame(t, segmentPath, 123, false)
+	writeFrame(t, segmentPath, 456, true) // bit-flipped CRC
+	writeFrame(t, segmentPath, 789, false)
+
+	ids, err := rq.DequeueAll(context.Background())
 	assert.NoError(t, err)
-	// Should skip invalid lines and empty lines
-	assert.Equal(t, 3, len(ids))
+	assert.Equal(t, 2, len(ids))
 	assert.Contains(t, ids, 123)
-	assert.Contains(t, ids, 456)
 	assert.Contains(t, ids, 789)
+	assert.NotContains(t, ids, 456)
+}
+
+func TestRefreshQueue_RecoversFromTornTrailingFrame(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	segmentPath := rq.segmentPath(rq.meta.WriteSegment)
+	writeFrame(t, segmentPath, 111, false)
+	writeFrame(t, segmentPath, 222, false)
+
+	// Truncate the segment mid-frame, simulating a crash during the last
+	// append.
+	info, err := os.Stat(segmentPath)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Truncate(segmentPath, info.Size()-3))
+
+	ids, err := rq.DequeueAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ids))
+	assert.Contains(t, ids, 111)
+}
+
+func TestRefreshQueue_Depth(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	assert.Equal(t, int64(0), rq.Depth())
+
+	id1, _ := util.NewUIDFromString("A000045")
+	id2, _ := util.NewUIDFromString("A000142")
+	assert.NoError(t, rq.Enqueue(id1))
+	assert.NoError(t, rq.Enqueue(id2))
+	assert.Equal(t, int64(2), rq.Depth())
+
+	_, err = rq.DequeueAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rq.Depth())
+}
+
+func TestRefreshQueue_CompactDedupes(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	id1, _ := util.NewUIDFromString("A000045")
+	id2, _ := util.NewUIDFromString("A000142")
+	assert.NoError(t, rq.Enqueue(id1))
+	assert.NoError(t, rq.Enqueue(id2))
+	assert.NoError(t, rq.Enqueue(id1))
+
+	assert.Equal(t, int64(3), rq.Depth())
+	assert.NoError(t, rq.Compact())
+	assert.Equal(t, int64(2), rq.Depth())
+
+	ids, err := rq.DequeueAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ids))
+	assert.Contains(t, ids, 45)
+	assert.Contains(t, ids, 142)
+}
+
+func TestRefreshQueue_PeekDoesNotRemove(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	id1, _ := util.NewUIDFromString("A000045")
+	id2, _ := util.NewUIDFromString("A000142")
+	assert.NoError(t, rq.Enqueue(id1))
+	assert.NoError(t, rq.Enqueue(id2))
+
+	ids, err := rq.Peek(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{45}, ids)
+
+	// Peeking again without acking hands out the same entries.
+	ids, err = rq.Peek(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ids))
+	assert.Contains(t, ids, 45)
+	assert.Contains(t, ids, 142)
+}
+
+func TestRefreshQueue_AckRemovesOnlyAcked(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	id1, _ := util.NewUIDFromString("A000045")
+	id2, _ := util.NewUIDFromString("A000142")
+	assert.NoError(t, rq.Enqueue(id1))
+	assert.NoError(t, rq.Enqueue(id2))
+
+	assert.NoError(t, rq.Ack(context.Background(), []int{45}))
+
+	remaining, err := rq.Peek(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{142}, remaining)
+}
+
+// TestRefreshQueue_CrashBetweenPeekAndAckLosesNothing simulates a consumer
+// that peeks an entry, then crashes (a fresh RefreshQueue instance, as a new
+// process would see after a restart) before it gets a chance to ack it: the
+// entry must still be there for the next peek.
+func TestRefreshQueue_CrashBetweenPeekAndAckLosesNothing(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	id1, _ := util.NewUIDFromString("A000045")
+	assert.NoError(t, rq.Enqueue(id1))
+
+	peeked, err := rq.Peek(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{45}, peeked)
+	// Crash: no Ack call before the process restarts.
+
+	restarted := NewRefreshQueue(tempDir)
+	pending, err := restarted.Peek(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{45}, pending)
+
+	assert.NoError(t, restarted.Ack(context.Background(), peeked))
+	pending, err = restarted.Peek(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(pending))
+}
+
+func TestRefreshQueue_DequeueAllStopsOnCancelledContext(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	rq.maxSegmentBytes = refreshQueueFrameHeaderSize + 8 // rotate after every entry
+
+	for i := 1; i <= 3; i++ {
+		id, err := util.NewUID('A', int64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, rq.Enqueue(id))
+	}
+	seqs, err := rq.segments()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(seqs))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ids, err := rq.DequeueAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ids))
+
+	// No segment was touched, so a fresh, uncancelled drain still sees all
+	// three entries.
+	seqs, err = rq.segments()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(seqs))
+
+	ids, err = rq.DequeueAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ids))
+}
+
+func TestRefreshQueue_DequeueAllPartialCancellationLeavesRemainderQueued(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refresh-queue-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rq := NewRefreshQueue(tempDir)
+	rq.maxSegmentBytes = refreshQueueFrameHeaderSize + 8 // rotate after every entry
+
+	for i := 1; i <= 3; i++ {
+		id, err := util.NewUID('A', int64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, rq.Enqueue(id))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	seen := 0
+	rq.mutex.Lock()
+	ids, err := rq.drainLocked(cancelCtx{ctx, cancel, &seen, 1})
+	rq.mutex.Unlock()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ids))
+
+	remaining, err := rq.DequeueAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(remaining))
+}
+
+// cancelCtx wraps a context.Context so its Err() method cancels the
+// underlying context the first time it's been checked more than
+// cancelAfter times, simulating a deadline that fires partway through a
+// multi-segment scan.
+type cancelCtx struct {
+	context.Context
+	cancel      context.CancelFunc
+	checked     *int
+	cancelAfter int
+}
+
+func (c cancelCtx) Err() error {
+	*c.checked++
+	if *c.checked > c.cancelAfter {
+		c.cancel()
+	}
+	return c.Context.Err()
 }
@@ -1,122 +1,223 @@
 package shared
 
 import (
+	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/loda-lang/loda-api/util"
 )
 
-func FindProgramById(programs []Program, id util.UID) *Program {
-	d := id.Domain()
-	n := int64(id.Number())
-	if n >= 0 && n < int64(len(programs)) && programs[n].Id.Domain() == d {
-		k := programs[n].Id.Number()
-		if k == n {
-			return &programs[n]
-		} else if k < n {
-			// Search forward
-			for i := n + 1; i < int64(len(programs)); i++ {
-				if programs[i].Id.Domain() != d {
-					break
-				}
-				if programs[i].Id.Equals(id) {
-					return &programs[i]
-				}
-			}
-		} else {
-			// Search backward
-			for i := n - 1; i >= 0; i-- {
-				if programs[i].Id.Domain() != d {
-					break
-				}
-				if programs[i].Id.Equals(id) {
-					return &programs[i]
-				}
-			}
-		}
-	} else {
-		// Full search
-		for _, s := range programs {
-			if s.Id.Equals(id) {
-				return &s
+// FindProgramById returns the program with the given Id, or nil if idx has
+// none. Unlike sequences, idx.Programs is sparse (only a subset of IDs have
+// a program), so it can't be probed by Id.Number() the way FindSequenceById
+// probes idx.Sequences; instead, when idx.ProgramsSorted is set (Load always
+// sets it), this binary-searches the slice by Id via sort.Search. A
+// DataIndex whose Programs isn't known to be sorted (e.g. one a test
+// constructs directly) falls back to a linear scan.
+func FindProgramById(idx *DataIndex, id util.UID) *Program {
+	programs := idx.Programs
+	if !idx.ProgramsSorted {
+		for i := range programs {
+			if programs[i].Id.Equals(id) {
+				return &programs[i]
 			}
 		}
+		return nil
+	}
+	i := sort.Search(len(programs), func(i int) bool {
+		return !programs[i].Id.IsLessThan(id)
+	})
+	if i < len(programs) && programs[i].Id.Equals(id) {
+		return &programs[i]
 	}
 	return nil
 }
 
-// SearchPrograms returns paginated results and total count of all matches
-func SearchPrograms(programs []Program, query string, limit, skip int) ([]Program, int) {
-	// Split the query into lower-case tokens
-	var tokens []string
-	if query != "" {
-		tokens = strings.Fields(query)
-		for i, t := range tokens {
-			tokens[i] = strings.ToLower(t)
+// SearchPrograms returns paginated results and the total count of all
+// matches for query against idx.Programs. For a plain query of tokens and
+// +/-keywords, it intersects and subtracts posting lists from
+// idx.ProgramIndex (built lazily here if idx hasn't been through Load)
+// instead of scanning every program, and UID tokens match a program's own
+// ID or a substring of its Name; an empty query matches everything. A query
+// using field scopes, ranges, or OR (see query_ast.go) instead falls back
+// to searchProgramsAdvanced. If shuffle is true, matches are shuffled
+// before skip/limit is applied.
+func SearchPrograms(idx *DataIndex, query string, limit, skip int, shuffle bool) ([]Program, int) {
+	if idx.ProgramIndex == nil {
+		idx.ProgramIndex = BuildProgramSearchIndex(idx.Programs)
+	}
+	pidx := idx.ProgramIndex
+	sq := ParseSearchQuery(query, nil)
+
+	if sq.Advanced {
+		return searchProgramsAdvanced(idx, sq, limit, skip, shuffle)
+	}
+
+	// Intersect posting lists for every included token and keyword; an
+	// empty query (no tokens, no keywords) starts from every program.
+	var matches []int
+	started := false
+	for _, t := range sq.FilteredTokens {
+		offsets := programTokenMatches(idx, pidx, t, matches, started)
+		if !started {
+			matches, started = offsets, true
+			continue
 		}
+		matches = intersectSorted(matches, offsets)
+	}
+	for i := range KeywordList {
+		bit := uint64(1) << uint(i)
+		if sq.IncludedKeywords&bit == 0 {
+			continue
+		}
+		postings := pidx.keywordPostings(bit)
+		if !started {
+			matches, started = postings, true
+			continue
+		}
+		matches = intersectSorted(matches, postings)
+	}
+	if !started {
+		matches = pidx.allOffsets()
+	}
+	for i := range KeywordList {
+		bit := uint64(1) << uint(i)
+		if sq.ExcludedKeywords&bit == 0 {
+			continue
+		}
+		matches = subtractSorted(matches, pidx.keywordPostings(bit))
 	}
 
-	// Extract included/excluded keywords and remove them from tokens
-	var inc, exc []string
-	filteredTokens := tokens[:0] // reuse underlying array
-	for _, t := range tokens {
-		if IsKeyword(t) {
-			inc = append(inc, t)
-		} else if len(t) > 1 && t[0] == '+' && IsKeyword(t[1:]) {
-			inc = append(inc, t[1:])
-		} else if len(t) > 1 && (t[0] == '-' || t[0] == '!') && IsKeyword(t[1:]) {
-			exc = append(exc, t[1:])
-		} else {
-			filteredTokens = append(filteredTokens, t)
+	// UID tokens match a program's own ID or a substring of its Name; this
+	// is a post-filter rather than a posting list since it's rarely
+	// combined with other tokens.
+	if len(sq.UIDTokens) > 0 {
+		filtered := matches[:0]
+		for _, offset := range matches {
+			p := idx.Programs[offset]
+			ok := true
+			for _, uid := range sq.UIDTokens {
+				if !p.Id.Equals(uid) && !strings.Contains(p.Name, uid.String()) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				filtered = append(filtered, offset)
+			}
 		}
+		matches = filtered
 	}
-	included, err := EncodeKeywords(inc)
-	if err != nil {
-		return nil, 0
+
+	total := len(matches)
+	if shuffle {
+		shuffled := make([]int, len(matches))
+		copy(shuffled, matches)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		matches = shuffled
 	}
-	excluded, err := EncodeKeywords(exc)
-	if err != nil {
-		return nil, 0
+
+	if skip > len(matches) {
+		skip = len(matches)
 	}
+	page := matches[skip:]
+	if limit > 0 && limit < len(page) {
+		page = page[:limit]
+	}
+
+	results := make([]Program, len(page))
+	for i, offset := range page {
+		results[i] = idx.Programs[offset]
+	}
+	return results, total
+}
+
+// programTokenMatches returns the candidate offsets for AND-combining token
+// into matches (already collected from earlier tokens; hasMatches is false
+// before the first one). If idx.ProgramTrigramIndex is set, token is
+// resolved by substring search: trigram posting-list intersection for
+// tokens of 3+ runes, or else a linear Contains scan restricted to matches
+// so far. Without a trigram index, e.g. a DataIndex built directly in a
+// test, it falls back to pidx's exact stemmed-word postings.
+func programTokenMatches(idx *DataIndex, pidx *ProgramSearchIndex, token string, matches []int, hasMatches bool) []int {
+	if idx.ProgramTrigramIndex == nil {
+		return pidx.postings(token)
+	}
+	if offsets, ok := idx.ProgramTrigramIndex.Substring(token); ok {
+		return offsets
+	}
+	base := pidx.allOffsets()
+	if hasMatches {
+		base = matches
+	}
+	lower := strings.ToLower(token)
+	filtered := make([]int, 0, len(base))
+	for _, offset := range base {
+		if programContainsSubstring(idx.Programs[offset], lower) {
+			filtered = append(filtered, offset)
+		}
+	}
+	return filtered
+}
+
+// programContainsSubstring reports whether p's name or submitter name
+// contains lower, which must already be lowercased.
+func programContainsSubstring(p Program, lower string) bool {
+	if strings.Contains(strings.ToLower(p.Name), lower) {
+		return true
+	}
+	return p.Submitter != nil && strings.Contains(strings.ToLower(p.Submitter.Name), lower)
+}
 
-	count := 0
-	var results []Program
-	var total int
-	for _, prog := range programs {
-		// Check included and excluded keywords
-		if !HasAllKeywords(prog.Keywords, included) {
+// searchProgramsAdvanced handles a query whose AST uses a field scope,
+// range, or OR: none of that can be resolved from posting lists, so it
+// scans idx.Programs once, evaluating sq.AST (if any) against each one
+// alongside the same keyword and UID-token filtering the fast path applies.
+func searchProgramsAdvanced(idx *DataIndex, sq SearchQuery, limit, skip int, shuffle bool) ([]Program, int) {
+	var matches []int
+	for i := range idx.Programs {
+		p := &idx.Programs[i]
+		if sq.AST != nil && !sq.AST.Eval(programQueryRecord{p}) {
 			continue
 		}
-		if !HasNoKeywords(prog.Keywords, excluded) {
+		if !HasAllKeywords(p.Keywords, sq.IncludedKeywords) {
 			continue
 		}
-		match := true
-		// Query string filtering (case-insensitive, all tokens must be present in name or submitter)
-		if len(filteredTokens) > 0 {
-			nameLower := strings.ToLower(prog.Name)
-			submitterLower := ""
-			if prog.Submitter != nil {
-				submitterLower = strings.ToLower(prog.Submitter.Name)
-			}
-			for _, t := range filteredTokens {
-				if !strings.Contains(nameLower, t) && (submitterLower == "" || !strings.Contains(submitterLower, t)) {
-					match = false
+		if !HasNoKeywords(p.Keywords, sq.ExcludedKeywords) {
+			continue
+		}
+		if len(sq.UIDTokens) > 0 {
+			ok := true
+			for _, uid := range sq.UIDTokens {
+				if !p.Id.Equals(uid) && !strings.Contains(p.Name, uid.String()) {
+					ok = false
 					break
 				}
 			}
-			if !match {
+			if !ok {
 				continue
 			}
 		}
-		total++
-		if count < skip {
-			count++
-			continue
-		}
-		if limit > 0 && len(results) >= limit {
-			continue
-		}
-		results = append(results, prog)
+		matches = append(matches, i)
+	}
+
+	total := len(matches)
+	if shuffle {
+		rand.Shuffle(len(matches), func(i, j int) { matches[i], matches[j] = matches[j], matches[i] })
+	}
+
+	if skip > len(matches) {
+		skip = len(matches)
+	}
+	page := matches[skip:]
+	if limit > 0 && limit < len(page) {
+		page = page[:limit]
+	}
+
+	results := make([]Program, len(page))
+	for i, offset := range page {
+		results[i] = idx.Programs[offset]
 	}
 	return results, total
 }
@@ -0,0 +1,126 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// benchKeywordCycle gives synthetic programs a realistic mix of keywords to
+// intersect/subtract against, without every program sharing the same ones.
+var benchKeywordCycle = [][]string{
+	{"nonn", "easy"},
+	{"core", "easy", "nice"},
+	{"nonn", "mult"},
+	{"core", "nonn", "cons", "easy"},
+	{"hard", "more"},
+}
+
+// makeSyntheticPrograms builds n programs with varied names and keywords,
+// realistic enough to exercise the search index's tokenizer and posting
+// lists the way the real ~400k-program OEIS corpus would.
+func makeSyntheticPrograms(n int) []Program {
+	words := []string{"zero", "square", "prime", "partition", "walk", "triangle", "fraction", "lattice"}
+	programs := make([]Program, n)
+	for i := 0; i < n; i++ {
+		id, err := util.NewUIDFromString(fmt.Sprintf("A%06d", i+1))
+		if err != nil {
+			panic(err)
+		}
+		name := fmt.Sprintf("Number of %s sequences of length %d", words[i%len(words)], i%32)
+		keywords, err := EncodeKeywords(benchKeywordCycle[i%len(benchKeywordCycle)])
+		if err != nil {
+			panic(err)
+		}
+		programs[i] = Program{Id: id, Name: name, Keywords: keywords}
+	}
+	return programs
+}
+
+// linearSearchPrograms is the pre-index implementation SearchPrograms used
+// to use: a full scan over programs, substring-matching each token against
+// the lowercased name. Kept here only to benchmark the inverted index
+// against what it replaced.
+func linearSearchPrograms(programs []Program, query string, limit, skip int) ([]Program, int) {
+	var tokens []string
+	if query != "" {
+		tokens = strings.Fields(query)
+		for i, t := range tokens {
+			tokens[i] = strings.ToLower(t)
+		}
+	}
+	var inc, exc []string
+	filteredTokens := tokens[:0]
+	for _, t := range tokens {
+		if IsKeyword(t) {
+			inc = append(inc, t)
+		} else if len(t) > 1 && t[0] == '+' && IsKeyword(t[1:]) {
+			inc = append(inc, t[1:])
+		} else if len(t) > 1 && (t[0] == '-' || t[0] == '!') && IsKeyword(t[1:]) {
+			exc = append(exc, t[1:])
+		} else {
+			filteredTokens = append(filteredTokens, t)
+		}
+	}
+	included, err := EncodeKeywords(inc)
+	if err != nil {
+		return nil, 0
+	}
+	excluded, err := EncodeKeywords(exc)
+	if err != nil {
+		return nil, 0
+	}
+	count := 0
+	var results []Program
+	var total int
+	for _, prog := range programs {
+		if !HasAllKeywords(prog.Keywords, included) {
+			continue
+		}
+		if !HasNoKeywords(prog.Keywords, excluded) {
+			continue
+		}
+		match := true
+		if len(filteredTokens) > 0 {
+			nameLower := strings.ToLower(prog.Name)
+			for _, t := range filteredTokens {
+				if !strings.Contains(nameLower, t) {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		total++
+		if count < skip {
+			count++
+			continue
+		}
+		if limit > 0 && len(results) >= limit {
+			continue
+		}
+		results = append(results, prog)
+	}
+	return results, total
+}
+
+func BenchmarkSearchPrograms_Linear(b *testing.B) {
+	programs := makeSyntheticPrograms(400_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearSearchPrograms(programs, "+core square", 20, 0)
+	}
+}
+
+func BenchmarkSearchPrograms_InvertedIndex(b *testing.B) {
+	idx := &DataIndex{Programs: makeSyntheticPrograms(400_000)}
+	idx.ProgramIndex = BuildProgramSearchIndex(idx.Programs)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SearchPrograms(idx, "+core square", 20, 0, false)
+	}
+}
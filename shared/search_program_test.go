@@ -33,26 +33,78 @@ func mustKeywords(kw []string) uint64 {
 }
 
 func TestFindProgramById(t *testing.T) {
-	programs := makeTestData().Programs
+	idx := makeTestData()
 	// Test existing
-	p := FindProgramById(programs, mustUID("A000004"))
+	p := FindProgramById(idx, mustUID("A000004"))
 	if p == nil || p.Name != "The zero sequence." {
 		t.Errorf("FindById failed for A000004")
 	}
 	// Test non-existing
-	p = FindProgramById(programs, mustUID("A999999"))
+	p = FindProgramById(idx, mustUID("A999999"))
 	if p != nil {
 		t.Errorf("FindById should return nil for non-existent ID")
 	}
 	// Test first and last
-	if FindProgramById(programs, programs[0].Id) == nil {
+	if FindProgramById(idx, idx.Programs[0].Id) == nil {
 		t.Errorf("FindById failed for first program")
 	}
-	if FindProgramById(programs, programs[len(programs)-1].Id) == nil {
+	if FindProgramById(idx, idx.Programs[len(idx.Programs)-1].Id) == nil {
 		t.Errorf("FindById failed for last program")
 	}
 }
 
+// TestFindProgramByIdLargeCorpus builds a sparse, sorted corpus of
+// thousands of synthetic programs across two domains, to exercise
+// FindProgramById's sort.Search fast path: every real ID must resolve, gaps
+// between IDs must return nil rather than a neighbor, and domain boundaries
+// (first/last ID of each domain, and a domain with no programs at all) must
+// not be off by one.
+func TestFindProgramByIdLargeCorpus(t *testing.T) {
+	const numA, numB = 2000, 3000
+	var programs, aIds, bIds []Program
+	for n := 0; n < numA; n += 3 { // sparse: only every third A-number
+		uid, _ := util.NewUID('A', int64(n))
+		p := Program{Id: uid, Name: uid.String()}
+		programs = append(programs, p)
+		aIds = append(aIds, p)
+	}
+	for n := 0; n < numB; n += 2 { // sparse: only every other B-number
+		uid, _ := util.NewUID('B', int64(n))
+		p := Program{Id: uid, Name: uid.String()}
+		programs = append(programs, p)
+		bIds = append(bIds, p)
+	}
+	idx := &DataIndex{Programs: programs, ProgramsSorted: true}
+
+	for _, p := range programs {
+		if got := FindProgramById(idx, p.Id); got == nil || !got.Id.Equals(p.Id) {
+			t.Fatalf("FindProgramById(%s): not found", p.Id.String())
+		}
+	}
+	for _, s := range []string{"A000001", "A000002", "B000001", "A001999", "B002999"} {
+		if got := FindProgramById(idx, mustUID(s)); got != nil {
+			t.Errorf("FindProgramById(%s): expected nil for a gap, got %s", s, got.Id.String())
+		}
+	}
+
+	if FindProgramById(idx, aIds[0].Id) == nil {
+		t.Errorf("FindProgramById: first A program not found")
+	}
+	if FindProgramById(idx, aIds[len(aIds)-1].Id) == nil {
+		t.Errorf("FindProgramById: last A program not found")
+	}
+	if FindProgramById(idx, bIds[0].Id) == nil {
+		t.Errorf("FindProgramById: first B program not found")
+	}
+	if FindProgramById(idx, bIds[len(bIds)-1].Id) == nil {
+		t.Errorf("FindProgramById: last B program not found")
+	}
+	noPrograms, _ := util.NewUID('C', 0)
+	if got := FindProgramById(idx, noPrograms); got != nil {
+		t.Errorf("FindProgramById: expected nil for a domain with no programs, got %s", got.Id.String())
+	}
+}
+
 func TestSearchPrograms(t *testing.T) {
 	idx := makeTestData()
 	// Search by name substring
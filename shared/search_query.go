@@ -7,26 +7,52 @@ import (
 )
 
 type SearchQuery struct {
-	RawTokens        []string
-	Tokens           []string
-	UIDTokens        []util.UID
-	FilteredTokens   []string
+	RawTokens []string
+	Tokens    []string
+	UIDTokens []util.UID
+	// FilteredTokens are the plain, non-phrase, non-prefix tokens that must
+	// all match (AND) a sequence's name.
+	FilteredTokens []string
+	// PrefixTokens are tokens written as "foo*": a sequence matches if any
+	// indexed word starts with "foo" (see SequenceSearchIndex.prefixOffsets).
+	PrefixTokens []string
+	// Phrases are "quoted multi-word spans", each already tokenized the same
+	// way the sequence index is, that must appear as a contiguous run in a
+	// sequence's name (see SequenceSearchIndex.phraseOffsets). A single-word
+	// quoted span is just a plain token, not a phrase, and ends up in
+	// FilteredTokens instead.
+	Phrases          [][]string
 	IncludedKeywords uint64
 	ExcludedKeywords uint64
-	IncludedOps      uint64
-	ExcludedOps      uint64
+	IncludedOps      OpTypeMask
+	ExcludedOps      OpTypeMask
+	// AST is query parsed as a field-scoped, boolean expression (see
+	// query_ast.go), or nil if the query is empty or fails to parse. A query
+	// that fails to parse falls back to the fields above, as if the bad
+	// syntax weren't there, rather than erroring the whole search.
+	AST QueryNode
+	// Advanced is true if AST uses anything the posting-list fields above
+	// can't express: a field scope, a range, an OR, or a NOT on something
+	// other than a keyword. SearchPrograms and SearchSequences use this to
+	// decide whether they can stay on the indexed fast path.
+	Advanced bool
 }
 
 func ParseSearchQuery(query string, opTypeIndex *OpTypeIndex) SearchQuery {
+	// parseQueryAST parses the original query, quotes and all, so a
+	// field-scoped phrase like submitter:"Neil Sloane" is still recognized
+	// there even though extractPhrases below only pulls out unscoped ones.
+	phrases, unquoted := extractPhrases(query)
+
 	var rawTokens, tokens []string
-	if query != "" {
-		rawTokens = strings.Fields(query)
+	if unquoted != "" {
+		rawTokens = strings.Fields(unquoted)
 		tokens = make([]string, len(rawTokens))
 		for i, t := range rawTokens {
 			tokens[i] = strings.ToLower(t)
 		}
 	}
-	var incKw, excKw, incOps, excOps []string
+	var incKw, excKw, incOps, excOps, prefixTokens []string
 	filteredTokens := tokens[:0] // reuse underlying array
 	var uidTokens []util.UID
 	for i, t := range tokens {
@@ -46,6 +72,8 @@ func ParseSearchQuery(query string, opTypeIndex *OpTypeIndex) SearchQuery {
 			incOps = append(incOps, t[1:])
 		} else if opTypeIndex != nil && len(t) > 1 && (t[0] == '-' || t[0] == '!') && opTypeIndex.IsOperationType(t[1:]) {
 			excOps = append(excOps, t[1:])
+		} else if len(t) > 1 && strings.HasSuffix(t, "*") {
+			prefixTokens = append(prefixTokens, strings.TrimSuffix(t, "*"))
 		} else {
 			if uid, err := util.NewUIDFromString(raw); err == nil {
 				uidTokens = append(uidTokens, uid)
@@ -56,19 +84,71 @@ func ParseSearchQuery(query string, opTypeIndex *OpTypeIndex) SearchQuery {
 	}
 	includedKw, _ := EncodeKeywords(incKw)
 	excludedKw, _ := EncodeKeywords(excKw)
-	var includedOps, excludedOps uint64
+	var includedOps, excludedOps OpTypeMask
 	if opTypeIndex != nil {
 		includedOps, _ = opTypeIndex.EncodeOperationTypes(incOps)
 		excludedOps, _ = opTypeIndex.EncodeOperationTypes(excOps)
 	}
+	// A malformed query degrades to no AST constraint rather than failing
+	// the whole search; see parseQueryAST.
+	ast, err := parseQueryAST(query)
+	if err != nil {
+		ast = nil
+	}
 	return SearchQuery{
 		RawTokens:        rawTokens,
 		Tokens:           tokens,
 		UIDTokens:        uidTokens,
 		FilteredTokens:   filteredTokens,
+		PrefixTokens:     prefixTokens,
+		Phrases:          phrases,
 		IncludedKeywords: includedKw,
 		ExcludedKeywords: excludedKw,
 		IncludedOps:      includedOps,
 		ExcludedOps:      excludedOps,
+		AST:              ast,
+		Advanced:         isAdvancedQuery(ast),
+	}
+}
+
+// extractPhrases pulls every "..."-quoted, multi-word span out of query,
+// tokenizing each one the same way SequenceSearchIndex tokenizes a name,
+// and returns the remainder of query with those spans removed, so the
+// caller's normal whitespace tokenization never sees the quotes or their
+// contents. A quoted span with zero or one words is left in place instead,
+// since it's no different from (or, empty, weaker than) an unquoted token.
+func extractPhrases(query string) (phrases [][]string, rest string) {
+	var sb strings.Builder
+	r := []rune(query)
+	n := len(r)
+	for i := 0; i < n; i++ {
+		if r[i] != '"' {
+			sb.WriteRune(r[i])
+			continue
+		}
+		start := i
+		i++
+		var raw strings.Builder
+		for i < n && r[i] != '"' {
+			if r[i] == '\\' && i+1 < n {
+				raw.WriteRune(r[i+1])
+				i += 2
+				continue
+			}
+			raw.WriteRune(r[i])
+			i++
+		}
+		if i >= n {
+			// Unterminated quote: keep the rest of the query as plain text.
+			sb.WriteString(string(r[start:]))
+			break
+		}
+		if words := tokenizeForBM25(raw.String()); len(words) > 1 {
+			phrases = append(phrases, words)
+		} else {
+			sb.WriteString(raw.String())
+		}
+		sb.WriteRune(' ')
 	}
+	return phrases, sb.String()
 }
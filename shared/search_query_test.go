@@ -0,0 +1,146 @@
+package shared
+
+import (
+	"testing"
+)
+
+func TestParseSearchQueryLegacyUnaffected(t *testing.T) {
+	// Plain tokens and +/-keywords must still classify as not advanced, so
+	// SearchPrograms/SearchSequences stay on the posting-list fast path.
+	sq := ParseSearchQuery("kolakoski +core -hard", nil)
+	if sq.Advanced {
+		t.Errorf("plain token/keyword query should not be advanced")
+	}
+	if sq.AST == nil {
+		t.Errorf("expected a non-nil AST")
+	}
+	if len(sq.FilteredTokens) != 1 || sq.FilteredTokens[0] != "kolakoski" {
+		t.Errorf("FilteredTokens: got %v", sq.FilteredTokens)
+	}
+}
+
+func TestParseSearchQueryBareID(t *testing.T) {
+	// A bare UID-looking token must keep going through UIDTokens, not the
+	// AST, so it isn't classified as advanced.
+	sq := ParseSearchQuery("A000045", nil)
+	if sq.Advanced {
+		t.Errorf("bare ID query should not be advanced")
+	}
+	if len(sq.UIDTokens) != 1 {
+		t.Errorf("expected one UID token, got %d", len(sq.UIDTokens))
+	}
+}
+
+func TestParseSearchQueryFieldScopes(t *testing.T) {
+	sq := ParseSearchQuery(`name:kolakoski submitter:"Neil Sloane" op:mov length:10..20`, nil)
+	if !sq.Advanced {
+		t.Fatalf("expected advanced query")
+	}
+	p := Program{
+		Name:       "Kolakoski sequence",
+		Submitter:  &Submitter{Name: "Neil Sloane"},
+		Operations: []string{"mov", "add"},
+		Length:     12,
+	}
+	if !sq.AST.Eval(programQueryRecord{&p}) {
+		t.Errorf("expected program to match field-scoped query")
+	}
+	p.Length = 100
+	if sq.AST.Eval(programQueryRecord{&p}) {
+		t.Errorf("expected length:10..20 to reject length 100")
+	}
+}
+
+func TestParseSearchQueryOrAndPrecedence(t *testing.T) {
+	// "a b OR c" is (a AND b) OR c: AND binds tighter than OR.
+	sq := ParseSearchQuery(`name:a name:b OR name:c`, nil)
+	or, ok := sq.AST.(*OrNode)
+	if !ok || len(or.Children) != 2 {
+		t.Fatalf("expected a top-level OrNode with 2 children, got %#v", sq.AST)
+	}
+	if _, ok := or.Children[0].(*AndNode); !ok {
+		t.Errorf("expected left side of OR to be an AndNode, got %#v", or.Children[0])
+	}
+}
+
+func TestParseSearchQueryParenGrouping(t *testing.T) {
+	sq := ParseSearchQuery(`(name:zzz OR name:foo) length:<10`, nil)
+	p := Program{Name: "foo", Length: 5}
+	if !sq.AST.Eval(programQueryRecord{&p}) {
+		t.Errorf("expected grouped OR combined with AND to match")
+	}
+	p.Length = 20
+	if sq.AST.Eval(programQueryRecord{&p}) {
+		t.Errorf("expected length:<10 to reject length 20")
+	}
+}
+
+func TestParseSearchQueryQuotingAndEscaping(t *testing.T) {
+	sq := ParseSearchQuery(`"a \"quoted\" phrase"`, nil)
+	node, ok := sq.AST.(*FieldMatchNode)
+	if !ok {
+		t.Fatalf("expected a FieldMatchNode, got %#v", sq.AST)
+	}
+	want := `a "quoted" phrase`
+	if node.Value != want {
+		t.Errorf("phrase: got %q, want %q", node.Value, want)
+	}
+}
+
+func TestParseSearchQueryPrefixToken(t *testing.T) {
+	sq := ParseSearchQuery("fib* +core", nil)
+	if sq.Advanced {
+		t.Errorf("prefix token query should not be advanced")
+	}
+	if len(sq.PrefixTokens) != 1 || sq.PrefixTokens[0] != "fib" {
+		t.Errorf("PrefixTokens: got %v", sq.PrefixTokens)
+	}
+	if len(sq.FilteredTokens) != 0 {
+		t.Errorf("expected no plain filtered tokens, got %v", sq.FilteredTokens)
+	}
+}
+
+func TestParseSearchQueryPhrase(t *testing.T) {
+	sq := ParseSearchQuery(`"number of groups" core`, nil)
+	if sq.Advanced {
+		t.Errorf("unscoped quoted phrase should not be advanced")
+	}
+	if len(sq.Phrases) != 1 {
+		t.Fatalf("expected one phrase, got %v", sq.Phrases)
+	}
+	if got := sq.Phrases[0]; len(got) != 2 || got[0] != "number" || got[1] != "group" {
+		// "of" is a stopword and "groups" stems to "group", same as
+		// tokenizeForBM25 indexes a sequence's Name.
+		t.Errorf("Phrases[0]: got %v", got)
+	}
+}
+
+func TestParseSearchQuerySingleWordQuoteIsPlainToken(t *testing.T) {
+	sq := ParseSearchQuery(`"kolakoski"`, nil)
+	if len(sq.Phrases) != 0 {
+		t.Errorf("expected no phrases for a single-word quote, got %v", sq.Phrases)
+	}
+	if len(sq.FilteredTokens) != 1 || sq.FilteredTokens[0] != "kolakoski" {
+		t.Errorf("FilteredTokens: got %v", sq.FilteredTokens)
+	}
+}
+
+func TestParseSearchQueryMalformed(t *testing.T) {
+	// Malformed syntax degrades to no AST constraint rather than erroring,
+	// so a typo in the search box doesn't break the whole search.
+	for _, query := range []string{
+		`name:"unterminated`,
+		`(name:a`,
+		`length:notanumber`,
+		`unknownfield:x`,
+		`name:a)`,
+	} {
+		sq := ParseSearchQuery(query, nil)
+		if sq.AST != nil {
+			t.Errorf("query %q: expected nil AST, got %#v", query, sq.AST)
+		}
+		if sq.Advanced {
+			t.Errorf("query %q: expected Advanced=false when AST is nil", query)
+		}
+	}
+}
@@ -5,6 +5,10 @@ type SearchItem struct {
 	Id       string   `json:"id"`
 	Name     string   `json:"name"`
 	Keywords []string `json:"keywords"`
+	// Score is the query's relevance score for this item, currently only
+	// populated by sequence search (BM25). Omitted when zero, e.g. for
+	// program search or an empty query.
+	Score float64 `json:"score,omitempty"`
 }
 
 // SearchResult represents a paginated list of search items
@@ -1,11 +1,48 @@
 package shared
 
 import (
+	"iter"
+	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/loda-lang/loda-api/util"
 )
 
+// ScoredSequence is a Sequence matched by SearchSequences together with its
+// BM25 relevance score. Embedding Sequence lets callers keep using its
+// fields (Id, Name, Keywords, ...) directly.
+type ScoredSequence struct {
+	Sequence
+	Score float64
+}
+
+// SequenceSortBy selects how SearchSequences orders its matches.
+type SequenceSortBy string
+
+const (
+	// SortByID orders by sequence ID ascending, the natural order of
+	// idx.Sequences.
+	SortByID SequenceSortBy = "id"
+	// SortByScore orders by BM25 relevance descending.
+	SortByScore SequenceSortBy = "score"
+	// SortByName orders by Name, case-insensitive, ascending.
+	SortByName SequenceSortBy = "name"
+)
+
+// resolveSequenceSortBy applies SearchSequences' default: SortByScore when a
+// query was given (there's something to rank), SortByID otherwise, so an
+// empty sortBy keeps working the way it always has.
+func resolveSequenceSortBy(sortBy SequenceSortBy, query string) SequenceSortBy {
+	if sortBy != "" {
+		return sortBy
+	}
+	if query == "" {
+		return SortByID
+	}
+	return SortByScore
+}
+
 func FindSequenceById(idx *DataIndex, id util.UID) *Sequence {
 	d := id.Domain()
 	n := int64(id.Number())
@@ -45,75 +82,302 @@ func FindSequenceById(idx *DataIndex, id util.UID) *Sequence {
 	return nil
 }
 
-// Search returns paginated results and total count of all matches
-func SearchSequences(idx *DataIndex, query string, limit, skip int) ([]Sequence, int) {
-	sq := ParseSearchQuery(query)
-	count := 0
-	var results []Sequence
-	var total int
-	for _, seq := range idx.Sequences {
-		// Check included and excluded keywords
-		if !HasAllKeywords(seq.Keywords, sq.IncludedKeywords) {
+// SearchSequences returns paginated results and the total count of all
+// matches for query against idx.Sequences, ranked by BM25 relevance. It
+// intersects and subtracts posting lists from idx.SequenceIndex (built
+// lazily here if idx hasn't been through Load) instead of scanning every
+// sequence: all query tokens must match (AND), a "foo*" token matches any
+// indexed word with that prefix, a "quoted multi-word phrase" must occur as
+// a contiguous run, keywords combine with AND, UID tokens match a
+// sequence's own ID or a substring of its Name, and an empty query matches
+// everything. Matches scoring below minScore are dropped; pass 0 to keep
+// everything. sortBy orders the matches before pagination; an empty sortBy
+// resolves via resolveSequenceSortBy. orderBy, if non-nil (e.g. built by
+// util.ParseOrder against SequenceOrderByRegistry), overrides sortBy with
+// an arbitrary comparator chain instead of the three fixed orders sortBy
+// supports. If shuffle is true, matches are shuffled after sorting,
+// overriding both sortBy and orderBy.
+func SearchSequences(idx *DataIndex, query string, limit, skip int, shuffle bool, minScore float64, sortBy SequenceSortBy, orderBy util.Comparator) ([]ScoredSequence, int) {
+	seq, total := SearchSequencesIter(idx, query, skip, shuffle, minScore, sortBy, orderBy)
+	var results []ScoredSequence
+	for r := range seq {
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+		results = append(results, r)
+	}
+	return results, total
+}
+
+// SearchSequencesIter is SearchSequences without a limit: it does the same
+// matching, scoring, sorting and shuffling, but returns every match from
+// skip onward (not just the first limit of them) as a lazily-pulled
+// iter.Seq instead of a pre-allocated []ScoredSequence. A caller that only
+// wants the first N (e.g. a streaming HTTP handler honoring its own
+// "limit" query param) can stop ranging over it early instead of forcing
+// the full match count to be materialized first; SearchSequences itself is
+// just this plus a stop-after-limit loop.
+func SearchSequencesIter(idx *DataIndex, query string, skip int, shuffle bool, minScore float64, sortBy SequenceSortBy, orderBy util.Comparator) (iter.Seq[ScoredSequence], int) {
+	sortBy = resolveSequenceSortBy(sortBy, query)
+	if idx.SequenceIndex == nil {
+		idx.SequenceIndex = BuildSequenceSearchIndex(idx.Sequences)
+	}
+	sidx := idx.SequenceIndex
+	sq := ParseSearchQuery(query, nil)
+
+	if sq.Advanced {
+		return searchSequencesAdvancedIter(idx, sq, skip, shuffle, minScore, sortBy, orderBy)
+	}
+
+	// Intersect posting lists for every included token and keyword; an
+	// empty query (no tokens, no keywords) starts from every sequence.
+	var matches []int
+	started := false
+	for _, t := range sq.FilteredTokens {
+		offsets := sequenceTokenMatches(idx, sidx, t, matches, started)
+		if !started {
+			matches, started = offsets, true
 			continue
 		}
-		if !HasNoKeywords(seq.Keywords, sq.ExcludedKeywords) {
+		matches = intersectSorted(matches, offsets)
+	}
+	for _, prefix := range sq.PrefixTokens {
+		offsets := sidx.prefixOffsets(prefix)
+		if !started {
+			matches, started = offsets, true
 			continue
 		}
-		match := true
-		// Query string filtering (case-insensitive, all tokens must be present in name, submitter, or ID)
-		if len(sq.FilteredTokens) > 0 || len(sq.UIDTokens) > 0 {
-			nameLower := strings.ToLower(seq.Name)
-			submitterLower := ""
-			if seq.Submitter != nil {
-				submitterLower = strings.ToLower(seq.Submitter.Name)
-			}
-			// Build author names lowercased
-			var authorLowers []string
-			for _, a := range seq.Authors {
-				authorLowers = append(authorLowers, strings.ToLower(a.Name))
-			}
-			// Check UID tokens: match if the sequence ID equals the UID or the UID string is contained in the name
+		matches = intersectSorted(matches, offsets)
+	}
+	for _, phrase := range sq.Phrases {
+		offsets := sidx.phraseOffsets(phrase)
+		if !started {
+			matches, started = offsets, true
+			continue
+		}
+		matches = intersectSorted(matches, offsets)
+	}
+	for i := range KeywordList {
+		bit := uint64(1) << uint(i)
+		if sq.IncludedKeywords&bit == 0 {
+			continue
+		}
+		postings := sidx.keywordPostings(bit)
+		if !started {
+			matches, started = postings, true
+			continue
+		}
+		matches = intersectSorted(matches, postings)
+	}
+	if !started {
+		matches = sidx.allOffsets()
+	}
+	for i := range KeywordList {
+		bit := uint64(1) << uint(i)
+		if sq.ExcludedKeywords&bit == 0 {
+			continue
+		}
+		matches = subtractSorted(matches, sidx.keywordPostings(bit))
+	}
+
+	// UID tokens match a sequence's own ID or a substring of its Name; this
+	// is a post-filter rather than a posting list since it's rarely
+	// combined with other tokens.
+	if len(sq.UIDTokens) > 0 {
+		filtered := matches[:0]
+		for _, offset := range matches {
+			seq := idx.Sequences[offset]
+			ok := true
 			for _, uid := range sq.UIDTokens {
 				if !seq.Id.Equals(uid) && !strings.Contains(seq.Name, uid.String()) {
-					match = false
+					ok = false
 					break
 				}
 			}
-			// Check string tokens
-			if match && len(sq.FilteredTokens) > 0 {
-				for _, t := range sq.FilteredTokens {
-					found := false
-					if strings.Contains(nameLower, t) {
-						found = true
-					} else if submitterLower != "" && strings.Contains(submitterLower, t) {
-						found = true
-					} else {
-						for _, author := range authorLowers {
-							if strings.Contains(author, t) {
-								found = true
-								break
-							}
-						}
-					}
-					if !found {
-						match = false
-						break
-					}
+			if ok {
+				filtered = append(filtered, offset)
+			}
+		}
+		matches = filtered
+	}
+
+	scores := make(map[int]float64, len(matches))
+	if len(sq.FilteredTokens) > 0 {
+		matchSet := make(map[int]bool, len(matches))
+		for _, m := range matches {
+			matchSet[m] = true
+		}
+		for _, t := range sq.FilteredTokens {
+			idf := sidx.termIdf(t)
+			for _, p := range sidx.termPostings(t) {
+				if !matchSet[p.seqIdx] {
+					continue
 				}
+				norm := 1 - bm25B
+				if sidx.avgDocLen > 0 {
+					norm += bm25B * float64(sidx.docLen[p.seqIdx]) / sidx.avgDocLen
+				}
+				tf := float64(p.termFreq)
+				scores[p.seqIdx] += idf * (tf * (bm25K1 + 1)) / (tf + bm25K1*norm)
 			}
-			if !match {
-				continue
+		}
+	}
+
+	if minScore > 0 {
+		filtered := matches[:0]
+		for _, offset := range matches {
+			if scores[offset] >= minScore {
+				filtered = append(filtered, offset)
 			}
 		}
-		total++
-		if count < skip {
-			count++
+		matches = filtered
+	}
+
+	total := len(matches)
+
+	sortSequenceMatches(idx, matches, scores, sortBy, orderBy)
+	if shuffle {
+		rand.Shuffle(len(matches), func(i, j int) { matches[i], matches[j] = matches[j], matches[i] })
+	}
+
+	return iterateSequenceMatches(idx, matches, scores, skip), total
+}
+
+// iterateSequenceMatches applies skip to already-sorted matches and yields
+// the rest as ScoredSequences, one at a time, stopping as soon as yield
+// returns false instead of materializing every remaining match up front.
+func iterateSequenceMatches(idx *DataIndex, matches []int, scores map[int]float64, skip int) iter.Seq[ScoredSequence] {
+	if skip > len(matches) {
+		skip = len(matches)
+	}
+	page := matches[skip:]
+	return func(yield func(ScoredSequence) bool) {
+		for _, offset := range page {
+			if !yield(ScoredSequence{Sequence: idx.Sequences[offset], Score: scores[offset]}) {
+				return
+			}
+		}
+	}
+}
+
+// sequenceTokenMatches returns the candidate offsets for AND-combining
+// token into matches (already collected from earlier tokens; hasMatches is
+// false before the first one). If idx.SequenceTrigramIndex is set, token is
+// resolved by substring search: trigram posting-list intersection for
+// tokens of 3+ runes, or else a linear Contains scan restricted to matches
+// so far. Without a trigram index, e.g. a DataIndex built directly in a
+// test, it falls back to sidx's exact stemmed-word postings.
+func sequenceTokenMatches(idx *DataIndex, sidx *SequenceSearchIndex, token string, matches []int, hasMatches bool) []int {
+	if idx.SequenceTrigramIndex == nil {
+		return sidx.termOffsets(token)
+	}
+	if offsets, ok := idx.SequenceTrigramIndex.Substring(token); ok {
+		return offsets
+	}
+	base := sidx.allOffsets()
+	if hasMatches {
+		base = matches
+	}
+	lower := strings.ToLower(token)
+	filtered := make([]int, 0, len(base))
+	for _, offset := range base {
+		if sequenceContainsSubstring(idx.Sequences[offset], lower) {
+			filtered = append(filtered, offset)
+		}
+	}
+	return filtered
+}
+
+// sequenceContainsSubstring reports whether seq's name, submitter name, or
+// any author's name contains lower, which must already be lowercased.
+func sequenceContainsSubstring(seq Sequence, lower string) bool {
+	if strings.Contains(strings.ToLower(seq.Name), lower) {
+		return true
+	}
+	if seq.Submitter != nil && strings.Contains(strings.ToLower(seq.Submitter.Name), lower) {
+		return true
+	}
+	for _, a := range seq.Authors {
+		if strings.Contains(strings.ToLower(a.Name), lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortSequenceMatches orders matches (sequence offsets) in place. orderBy,
+// if non-nil, wins over sortBy: matches are ordered by comparing
+// idx.Sequences[offset] pairs directly, giving callers an arbitrary
+// composed comparator instead of just the three fixed sortBy orders.
+// Otherwise it falls back to sortBy. scores may be nil, in which case
+// SortByScore falls back to SortByID since there's nothing to rank by
+// (searchSequencesAdvancedIter's unranked results). SortByID is a no-op:
+// matches arrives already in ascending offset order, built from
+// intersecting/subtracting sorted posting lists or from a single ascending
+// scan over idx.Sequences.
+func sortSequenceMatches(idx *DataIndex, matches []int, scores map[int]float64, sortBy SequenceSortBy, orderBy util.Comparator) {
+	if orderBy != nil {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return orderBy(idx.Sequences[matches[i]], idx.Sequences[matches[j]]) < 0
+		})
+		return
+	}
+	switch sortBy {
+	case SortByScore:
+		if scores == nil {
+			return
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return scores[matches[i]] > scores[matches[j]] })
+	case SortByName:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return strings.ToLower(idx.Sequences[matches[i]].Name) < strings.ToLower(idx.Sequences[matches[j]].Name)
+		})
+	}
+}
+
+// searchSequencesAdvancedIter handles a query whose AST uses a field scope,
+// range, or OR: none of that can be resolved from posting lists, so it
+// scans idx.Sequences once, evaluating sq.AST (if any) against each one
+// alongside the same keyword and UID-token filtering the fast path applies.
+// Results are unranked (Score is always 0), since combining BM25 with an
+// arbitrary per-record predicate isn't worth the complexity here; minScore
+// above zero discards everything.
+func searchSequencesAdvancedIter(idx *DataIndex, sq SearchQuery, skip int, shuffle bool, minScore float64, sortBy SequenceSortBy, orderBy util.Comparator) (iter.Seq[ScoredSequence], int) {
+	if minScore > 0 {
+		return func(yield func(ScoredSequence) bool) {}, 0
+	}
+	var matches []int
+	for i := range idx.Sequences {
+		s := &idx.Sequences[i]
+		if sq.AST != nil && !sq.AST.Eval(sequenceQueryRecord{s}) {
 			continue
 		}
-		if limit > 0 && len(results) >= limit {
+		if !HasAllKeywords(s.Keywords, sq.IncludedKeywords) {
+			continue
+		}
+		if !HasNoKeywords(s.Keywords, sq.ExcludedKeywords) {
 			continue
 		}
-		results = append(results, seq)
+		if len(sq.UIDTokens) > 0 {
+			ok := true
+			for _, uid := range sq.UIDTokens {
+				if !s.Id.Equals(uid) && !strings.Contains(s.Name, uid.String()) {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+		matches = append(matches, i)
 	}
-	return results, total
+
+	total := len(matches)
+	sortSequenceMatches(idx, matches, nil, sortBy, orderBy)
+	if shuffle {
+		rand.Shuffle(len(matches), func(i, j int) { matches[i], matches[j] = matches[j], matches[i] })
+	}
+
+	return iterateSequenceMatches(idx, matches, nil, skip), total
 }
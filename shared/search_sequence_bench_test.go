@@ -0,0 +1,116 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// makeSyntheticSequences builds n sequences with varied names and keywords,
+// realistic enough to exercise the search index's tokenizer and posting
+// lists the way the real ~400k-sequence OEIS corpus would.
+func makeSyntheticSequences(n int) []Sequence {
+	words := []string{"zero", "square", "prime", "partition", "walk", "triangle", "fraction", "lattice"}
+	sequences := make([]Sequence, n)
+	for i := 0; i < n; i++ {
+		id, err := util.NewUIDFromString(fmt.Sprintf("A%06d", i+1))
+		if err != nil {
+			panic(err)
+		}
+		name := fmt.Sprintf("Number of %s sequences of length %d", words[i%len(words)], i%32)
+		keywords, err := EncodeKeywords(benchKeywordCycle[i%len(benchKeywordCycle)])
+		if err != nil {
+			panic(err)
+		}
+		sequences[i] = Sequence{Id: id, Name: name, Keywords: keywords}
+	}
+	return sequences
+}
+
+// linearSearchSequences is the pre-index implementation SearchSequences used
+// to use: a full scan over sequences, substring-matching each token against
+// the lowercased name. Kept here only to benchmark the inverted index
+// against what it replaced.
+func linearSearchSequences(sequences []Sequence, query string, limit, skip int) ([]Sequence, int) {
+	var tokens []string
+	if query != "" {
+		tokens = strings.Fields(query)
+		for i, t := range tokens {
+			tokens[i] = strings.ToLower(t)
+		}
+	}
+	var inc, exc []string
+	filteredTokens := tokens[:0]
+	for _, t := range tokens {
+		if IsKeyword(t) {
+			inc = append(inc, t)
+		} else if len(t) > 1 && t[0] == '+' && IsKeyword(t[1:]) {
+			inc = append(inc, t[1:])
+		} else if len(t) > 1 && (t[0] == '-' || t[0] == '!') && IsKeyword(t[1:]) {
+			exc = append(exc, t[1:])
+		} else {
+			filteredTokens = append(filteredTokens, t)
+		}
+	}
+	included, err := EncodeKeywords(inc)
+	if err != nil {
+		return nil, 0
+	}
+	excluded, err := EncodeKeywords(exc)
+	if err != nil {
+		return nil, 0
+	}
+	count := 0
+	var results []Sequence
+	var total int
+	for _, seq := range sequences {
+		if !HasAllKeywords(seq.Keywords, included) {
+			continue
+		}
+		if !HasNoKeywords(seq.Keywords, excluded) {
+			continue
+		}
+		match := true
+		if len(filteredTokens) > 0 {
+			nameLower := strings.ToLower(seq.Name)
+			for _, t := range filteredTokens {
+				if !strings.Contains(nameLower, t) {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		total++
+		if count < skip {
+			count++
+			continue
+		}
+		if limit > 0 && len(results) >= limit {
+			continue
+		}
+		results = append(results, seq)
+	}
+	return results, total
+}
+
+func BenchmarkSearchSequences_Linear(b *testing.B) {
+	sequences := makeSyntheticSequences(400_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearSearchSequences(sequences, "+core square", 20, 0)
+	}
+}
+
+func BenchmarkSearchSequences_InvertedIndex(b *testing.B) {
+	idx := &DataIndex{Sequences: makeSyntheticSequences(400_000)}
+	idx.SequenceIndex = BuildSequenceSearchIndex(idx.Sequences)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SearchSequences(idx, "+core square", 20, 0, false, 0, "", nil)
+	}
+}
@@ -55,7 +55,7 @@ func TestSearchSequences(t *testing.T) {
 	idx := loadTestIndex(t)
 
 	// Search by query string (name substring)
-	results, total := SearchSequences(idx, "Kolakoski", 0, 0)
+	results, total := SearchSequences(idx, "Kolakoski", 0, 0, false, 0, "", nil)
 	if total != 1 || len(results) != 1 {
 		t.Errorf("Search Kolakoski: got %d results, want 1", total)
 	} else if !strings.Contains(results[0].Name, "Kolakoski") {
@@ -63,7 +63,7 @@ func TestSearchSequences(t *testing.T) {
 	}
 
 	// Search by included keyword (as +core)
-	results, total = SearchSequences(idx, "+core", 0, 0)
+	results, total = SearchSequences(idx, "+core", 0, 0, false, 0, "", nil)
 	if total != 7 {
 		t.Errorf("Search +core: got total=%d, want 7", total)
 	}
@@ -82,7 +82,7 @@ func TestSearchSequences(t *testing.T) {
 	}
 
 	// Search by excluded keyword (as -hard)
-	results, total = SearchSequences(idx, "-hard", 0, 0)
+	results, total = SearchSequences(idx, "-hard", 0, 0, false, 0, "", nil)
 	if total != 9 {
 		t.Errorf("Search -hard: got total=%d, want 9", total)
 	}
@@ -96,17 +96,17 @@ func TestSearchSequences(t *testing.T) {
 	}
 
 	// Search with query tokens (all must match)
-	results, total = SearchSequences(idx, "groups order", 0, 0)
+	results, total = SearchSequences(idx, "groups order", 0, 0, false, 0, "", nil)
 	if total != 1 || len(results) != 1 || !strings.Contains(results[0].Name, "groups") || !strings.Contains(results[0].Name, "order") {
 		t.Errorf("Search groups order: got %d results, want 1 with correct name", total)
 	}
 
 	// Pagination: skip and limit
-	allResults, allTotal := SearchSequences(idx, "", 0, 0)
+	allResults, allTotal := SearchSequences(idx, "", 0, 0, false, 0, "", nil)
 	if allTotal != 10 || len(allResults) != 10 {
 		t.Fatalf("All results: got %d results, want 10", allTotal)
 	}
-	paged, _ := SearchSequences(idx, "", 2, 1)
+	paged, _ := SearchSequences(idx, "", 2, 1, false, 0, "", nil)
 	if len(paged) != 2 {
 		t.Errorf("Pagination: got %d results, want 2", len(paged))
 	}
@@ -115,8 +115,56 @@ func TestSearchSequences(t *testing.T) {
 	}
 }
 
+func TestSearchSequences_SortBy(t *testing.T) {
+	idx := loadTestIndex(t)
+
+	// A query defaults to SortByScore: results are non-increasing by score.
+	results, _ := SearchSequences(idx, "groups order number", 0, 0, false, 0, "", nil)
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("default sort: result %d scored higher than result %d", i, i-1)
+		}
+	}
+
+	// An empty query defaults to SortByID: results come back in ID order.
+	idResults, _ := SearchSequences(idx, "", 0, 0, false, 0, "", nil)
+	for i := 1; i < len(idResults); i++ {
+		if !idResults[i-1].Id.IsLessThan(idResults[i].Id) {
+			t.Errorf("default sort for empty query: %q did not come before %q", idResults[i-1].Id, idResults[i].Id)
+		}
+	}
+
+	// SortByID overrides the default score ordering a query would otherwise get.
+	byID, _ := SearchSequences(idx, "groups order number", 0, 0, false, 0, SortByID, nil)
+	for i := 1; i < len(byID); i++ {
+		if !byID[i-1].Id.IsLessThan(byID[i].Id) {
+			t.Errorf("SortByID: %q did not come before %q", byID[i-1].Id, byID[i].Id)
+		}
+	}
+
+	// SortByName orders case-insensitively by Name.
+	byName, _ := SearchSequences(idx, "", 0, 0, false, 0, SortByName, nil)
+	for i := 1; i < len(byName); i++ {
+		if strings.ToLower(byName[i-1].Name) > strings.ToLower(byName[i].Name) {
+			t.Errorf("SortByName: %q did not come before %q", byName[i-1].Name, byName[i].Name)
+		}
+	}
+}
+
+func TestSearchSequences_OrderByOverridesSortBy(t *testing.T) {
+	idx := loadTestIndex(t)
+
+	order := util.ParseOrder("name:desc", SequenceOrderByRegistry)
+	results, _ := SearchSequences(idx, "groups order number", 0, 0, false, 0, SortByScore, order)
+	for i := 1; i < len(results); i++ {
+		if strings.ToLower(results[i-1].Name) < strings.ToLower(results[i].Name) {
+			t.Errorf("orderBy name:desc: %q did not come before %q", results[i-1].Name, results[i].Name)
+		}
+	}
+}
+
 func checkSearchByID(t *testing.T, idx *DataIndex, query string, expectedID string) {
-	results, total := SearchSequences(idx, query, 0, 0)
+	results, total := SearchSequences(idx, query, 0, 0, false, 0, "", nil)
 	if total != 1 || len(results) != 1 {
 		t.Errorf("SearchSequences by ID (%s): got %d results, want 1", query, total)
 	} else if results[0].Id.String() != expectedID {
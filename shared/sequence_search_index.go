@@ -0,0 +1,294 @@
+package shared
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// bm25K1 and bm25B are the standard BM25 term-frequency saturation and
+// document-length normalization constants used by SearchSequences.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// sequencePosting records that the stemmed token it's filed under occurs
+// termFreq times in the sequence at seqIdx's Name, at positions (its
+// 0-based indices among Name's tokens, ascending), which phraseOffsets uses
+// to check that adjacent query words are actually adjacent in Name.
+type sequencePosting struct {
+	seqIdx    int
+	termFreq  int
+	positions []int
+}
+
+// SequenceSearchIndex is an inverted index over a DataIndex's Sequences,
+// built once by DataIndex.Load so SearchSequences can rank matches with
+// BM25 instead of a linear substring scan over every sequence's Name.
+type SequenceSearchIndex struct {
+	// terms maps each lowercased, stemmed word found in a sequence's Name to
+	// the postings (seqIdx, termFreq) of the sequences containing it,
+	// ordered by ascending seqIdx.
+	terms map[string][]sequencePosting
+	// keywords maps a single keyword's bit (see keywordToBit in keyword.go)
+	// to the sorted offsets of the sequences having that keyword.
+	keywords map[uint64][]int
+	// docLen is the token count of Sequences[i].Name, indexed by offset.
+	docLen []int
+	// avgDocLen is the mean of docLen, used to normalize BM25's length
+	// penalty. Zero when size is zero.
+	avgDocLen float64
+	// idf is the precomputed BM25 inverse document frequency of each term
+	// in terms.
+	idf map[string]float64
+	// sortedTerms is every key of terms, sorted ascending, so prefixOffsets
+	// can binary-search for a prefix's matching terms instead of scanning
+	// all of them.
+	sortedTerms []string
+	// size is the number of sequences the index was built from, used as
+	// the full candidate set for a query with no tokens or keywords.
+	size int
+}
+
+// BuildSequenceSearchIndex tokenizes every sequence's Name and indexes its
+// keywords, precomputing per-term IDF and the average document length BM25
+// needs at query time. Offsets are the sequences' indices in the given
+// slice, so callers must rebuild the index whenever that slice is replaced,
+// e.g. after a reload.
+func BuildSequenceSearchIndex(sequences []Sequence) *SequenceSearchIndex {
+	idx := &SequenceSearchIndex{
+		terms:    make(map[string][]sequencePosting),
+		keywords: make(map[uint64][]int),
+		docLen:   make([]int, len(sequences)),
+		size:     len(sequences),
+	}
+	var totalLen int
+	for offset, seq := range sequences {
+		positions := make(map[string][]int)
+		for pos, tok := range tokenizeForBM25(seq.Name) {
+			positions[tok] = append(positions[tok], pos)
+		}
+		for tok, pos := range positions {
+			idx.terms[tok] = append(idx.terms[tok], sequencePosting{seqIdx: offset, termFreq: len(pos), positions: pos})
+			idx.docLen[offset] += len(pos)
+		}
+		totalLen += idx.docLen[offset]
+		for i := range KeywordList {
+			bit := uint64(1) << uint(i)
+			if seq.Keywords&bit != 0 {
+				idx.keywords[bit] = append(idx.keywords[bit], offset)
+			}
+		}
+	}
+	if idx.size > 0 {
+		idx.avgDocLen = float64(totalLen) / float64(idx.size)
+	}
+	idx.idf = make(map[string]float64, len(idx.terms))
+	idx.sortedTerms = make([]string, 0, len(idx.terms))
+	for tok, postings := range idx.terms {
+		idx.idf[tok] = bm25Idf(len(postings), idx.size)
+		idx.sortedTerms = append(idx.sortedTerms, tok)
+	}
+	sort.Strings(idx.sortedTerms)
+	return idx
+}
+
+// bm25Idf is the BM25+ inverse document frequency for a term found in df of
+// n documents: log(1 + (n-df+0.5)/(df+0.5)). The "1 +" keeps the result
+// positive even when df > n/2, unlike the classic IDF formula, which would
+// otherwise make very common terms pull scores down instead of merely
+// contributing little.
+func bm25Idf(df, n int) float64 {
+	return math.Log(1 + (float64(n)-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// sequenceStopwords are common English words dropped when tokenizing a
+// sequence's Name, since they carry no ranking signal and would otherwise
+// inflate posting lists and document lengths for no benefit.
+var sequenceStopwords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {},
+	"by": {}, "for": {}, "from": {}, "has": {}, "in": {}, "is": {}, "it": {},
+	"of": {}, "on": {}, "or": {}, "that": {}, "the": {}, "to": {}, "was": {},
+	"were": {}, "with": {},
+}
+
+// accentFolds maps common Latin accented letters to their unaccented
+// equivalent, so a query for "uber" or "munchen" matches a name spelled
+// with "ü" or "ö" (OEIS names and author credits draw from many
+// non-English sources).
+var accentFolds = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ñ': 'n', 'ç': 'c',
+}
+
+// foldAndLower lowercases s and strips every accent accentFolds knows
+// about, so tokenization treats e.g. "é" and "e" as the same letter.
+func foldAndLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if folded, ok := accentFolds[r]; ok {
+			return folded
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+// tokenizeForBM25 splits s on runs of anything that isn't a letter or
+// digit, folds accents, lowercases and stems each piece with the same
+// lightweight suffix stemmer ProgramSearchIndex uses, and drops stopwords,
+// e.g. "The number of groups of order n" indexes as ["number", "group",
+// "order", "n"].
+func tokenizeForBM25(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		tok := stemToken(foldAndLower(f))
+		if _, stop := sequenceStopwords[tok]; stop {
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// termPostings returns the postings for token, after the same folding and
+// stemming used to build the index.
+func (idx *SequenceSearchIndex) termPostings(token string) []sequencePosting {
+	return idx.terms[stemToken(foldAndLower(token))]
+}
+
+// termOffsets returns the sorted sequence offsets matching token, discarding
+// the term frequencies termPostings carries for scoring.
+func (idx *SequenceSearchIndex) termOffsets(token string) []int {
+	postings := idx.termPostings(token)
+	offsets := make([]int, len(postings))
+	for i, p := range postings {
+		offsets[i] = p.seqIdx
+	}
+	return offsets
+}
+
+// termIdf returns the precomputed IDF for token, after the same folding and
+// stemming used to build the index. Zero for a token never seen at index
+// build time.
+func (idx *SequenceSearchIndex) termIdf(token string) float64 {
+	return idx.idf[stemToken(foldAndLower(token))]
+}
+
+// prefixOffsets returns the union of offsets for every indexed term
+// starting with prefix, located via binary search over the index's sorted
+// term list rather than scanning every term, e.g. a query for "fib*"
+// matches "fibonacci". prefix is folded and lowercased but not stemmed,
+// since a partial word isn't a real word to stem.
+func (idx *SequenceSearchIndex) prefixOffsets(prefix string) []int {
+	prefix = foldAndLower(prefix)
+	lo := sort.SearchStrings(idx.sortedTerms, prefix)
+	var union []int
+	for i := lo; i < len(idx.sortedTerms) && strings.HasPrefix(idx.sortedTerms[i], prefix); i++ {
+		union = unionSorted(union, idx.termOffsets(idx.sortedTerms[i]))
+	}
+	return union
+}
+
+// phraseOffsets returns the sorted offsets of sequences whose Name contains
+// every word of words as a contiguous, ordered run, using each word's
+// positional postings to check adjacency instead of re-scanning Name. A
+// word the index never saw means no sequence can match.
+func (idx *SequenceSearchIndex) phraseOffsets(words []string) []int {
+	if len(words) == 0 {
+		return nil
+	}
+	first := idx.termPostings(words[0])
+	var out []int
+	for _, p := range first {
+		if idx.phraseMatchesAt(words, p.seqIdx, p.positions) {
+			out = append(out, p.seqIdx)
+		}
+	}
+	return out
+}
+
+// phraseMatchesAt reports whether seqIdx's Name has words[1:] immediately
+// following one of startPositions, i.e. words[i] occurs at
+// startPositions[j]+i for some j and every i.
+func (idx *SequenceSearchIndex) phraseMatchesAt(words []string, seqIdx int, startPositions []int) bool {
+	restPositions := make([][]int, len(words)-1)
+	for i, w := range words[1:] {
+		positions, ok := postingsFor(idx.termPostings(w), seqIdx)
+		if !ok {
+			return false
+		}
+		restPositions[i] = positions
+	}
+	for _, start := range startPositions {
+		match := true
+		for i, positions := range restPositions {
+			if !containsSortedInt(positions, start+i+1) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// postingsFor returns the positions recorded for seqIdx in postings, which
+// is sorted ascending by seqIdx, via binary search.
+func postingsFor(postings []sequencePosting, seqIdx int) ([]int, bool) {
+	i := sort.Search(len(postings), func(i int) bool { return postings[i].seqIdx >= seqIdx })
+	if i < len(postings) && postings[i].seqIdx == seqIdx {
+		return postings[i].positions, true
+	}
+	return nil, false
+}
+
+// containsSortedInt reports whether v is present in the ascending slice xs.
+func containsSortedInt(xs []int, v int) bool {
+	i := sort.SearchInts(xs, v)
+	return i < len(xs) && xs[i] == v
+}
+
+// keywordPostings returns the sorted offsets of sequences having the single
+// keyword bit.
+func (idx *SequenceSearchIndex) keywordPostings(bit uint64) []int {
+	return idx.keywords[bit]
+}
+
+// allOffsets returns every sequence offset 0..size-1, the candidate set for
+// a query with no tokens or keywords to intersect against.
+func (idx *SequenceSearchIndex) allOffsets() []int {
+	all := make([]int, idx.size)
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// sequenceTrigramTexts returns each sequence's name, submitter name, and
+// author names joined by trigramFieldSep, for BuildTrigramIndex. Offsets
+// match sequences.
+func sequenceTrigramTexts(sequences []Sequence) []string {
+	texts := make([]string, len(sequences))
+	for i, seq := range sequences {
+		fields := []string{seq.Name}
+		if seq.Submitter != nil {
+			fields = append(fields, seq.Submitter.Name)
+		}
+		for _, a := range seq.Authors {
+			fields = append(fields, a.Name)
+		}
+		texts[i] = strings.Join(fields, trigramFieldSep)
+	}
+	return texts
+}
@@ -11,9 +11,10 @@ import (
 type Mode string
 
 const (
-	ModeAdd    Mode = "add"
-	ModeUpdate Mode = "update"
-	ModeRemove Mode = "remove"
+	ModeAdd     Mode = "add"
+	ModeUpdate  Mode = "update"
+	ModeRemove  Mode = "remove"
+	ModeRestore Mode = "restore" // undoes a prior bfile removal within its protection window
 )
 
 // Type represents the type of object being submitted
@@ -75,7 +76,7 @@ func (s *Submission) UnmarshalJSON(data []byte) error {
 	if mode == "delete" {
 		mode = ModeRemove
 	}
-	if mode != ModeAdd && mode != ModeUpdate && mode != ModeRemove {
+	if mode != ModeAdd && mode != ModeUpdate && mode != ModeRemove && mode != ModeRestore {
 		return fmt.Errorf("invalid mode: %s", aux.Mode)
 	}
 	// Validate type
@@ -83,9 +84,9 @@ func (s *Submission) UnmarshalJSON(data []byte) error {
 	if objType != TypeProgram && objType != TypeSequence && objType != TypeBFile {
 		return fmt.Errorf("invalid type: %s", aux.Type)
 	}
-	// Validate mode for bfile type (only remove allowed)
-	if objType == TypeBFile && mode != ModeRemove {
-		return fmt.Errorf("only remove mode is allowed for bfile type")
+	// Validate mode for bfile type (only remove and restore are allowed)
+	if objType == TypeBFile && mode != ModeRemove && mode != ModeRestore {
+		return fmt.Errorf("only remove or restore mode is allowed for bfile type")
 	}
 	s.Id = uid
 	s.Mode = mode
@@ -0,0 +1,79 @@
+package shared
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/loda-lang/loda-api/util"
+)
+
+// Filter matches Submissions against a 3-segment "<seqId>/<ops>/<submitter>"
+// pattern, e.g. "A00004?/mov,add/alice": the first and third segments are
+// glob patterns ("?" any character, "*" any run of characters) matched
+// against the formatted sequence id (UID.String, "A%06d") and the
+// submitter, and the second is a comma-separated list of operation names
+// that must all be present among the submission's decoded Operations. Any
+// segment left empty matches everything.
+type Filter struct {
+	matcher     *util.Matcher
+	requiredOps []string
+}
+
+// NewSubmissionFilter compiles pattern into a Filter.
+func NewSubmissionFilter(pattern string) (*Filter, error) {
+	parts := strings.Split(pattern, "/")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid submission filter %q: expected at most 3 segments (seqId/ops/submitter), got %d", pattern, len(parts))
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+
+	matcherPattern := globToRegexSource(parts[0]) + "//" + globToRegexSource(parts[2])
+	matcher, err := util.NewMatcher(matcherPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid submission filter %q: %w", pattern, err)
+	}
+
+	var requiredOps []string
+	for _, op := range strings.Split(parts[1], ",") {
+		if op = strings.TrimSpace(op); op != "" {
+			requiredOps = append(requiredOps, op)
+		}
+	}
+	return &Filter{matcher: matcher, requiredOps: requiredOps}, nil
+}
+
+// globToRegexSource translates a glob ("?" any character, "*" any run of
+// characters, everything else literal) into the source of an equivalent
+// regexp.
+func globToRegexSource(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '?':
+			b.WriteString(".")
+		case '*':
+			b.WriteString(".*")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// Match reports whether s satisfies the filter's seqId, required
+// operations, and submitter segments.
+func (f *Filter) Match(s Submission) bool {
+	if !f.matcher.MatchSegment(0, s.Id.String()) {
+		return false
+	}
+	for _, op := range f.requiredOps {
+		if !slices.Contains(s.Operations, op) {
+			return false
+		}
+	}
+	return f.matcher.MatchSegment(2, s.Submitter)
+}
@@ -0,0 +1,56 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/loda-lang/loda-api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFilterTestSubmission(t *testing.T, id string, submitter string, ops []string) Submission {
+	t.Helper()
+	uid, err := util.NewUIDFromString(id)
+	assert.NoError(t, err)
+	return Submission{Id: uid, Submitter: submitter, Operations: ops}
+}
+
+func TestNewSubmissionFilter_InvalidPattern(t *testing.T) {
+	_, err := NewSubmissionFilter("a/mov/b/extra")
+	assert.Error(t, err, "Expected an error for a pattern with more than 3 segments")
+}
+
+func TestSubmissionFilter_EmptyPatternMatchesAnything(t *testing.T) {
+	f, err := NewSubmissionFilter("")
+	assert.NoError(t, err)
+	sub := newFilterTestSubmission(t, "A000045", "alice", []string{"mov", "add"})
+	assert.True(t, f.Match(sub), "Expected an empty pattern to match any submission")
+}
+
+func TestSubmissionFilter_EmptySegmentsMeanAny(t *testing.T) {
+	// Only constrain the submitter; seqId and ops are left blank.
+	f, err := NewSubmissionFilter("//alice")
+	assert.NoError(t, err)
+	assert.True(t, f.Match(newFilterTestSubmission(t, "A000045", "alice", []string{"mov"})))
+	assert.False(t, f.Match(newFilterTestSubmission(t, "A000045", "bob", []string{"mov"})))
+}
+
+func TestSubmissionFilter_MatchesAgainstOperationTypeIndex(t *testing.T) {
+	opIndex := loadTestOpTypeIndex(t)
+	assert.True(t, opIndex.IsOperationType("mov"), "test fixture should know about mov")
+	assert.True(t, opIndex.IsOperationType("add"), "test fixture should know about add")
+
+	f, err := NewSubmissionFilter("A00004?/mov,add/alice")
+	assert.NoError(t, err)
+
+	matching := newFilterTestSubmission(t, "A000045", "alice", []string{"mov", "add", "sub"})
+	assert.True(t, f.Match(matching), "Expected a matching seqId, op set, and submitter to match")
+
+	wrongId := newFilterTestSubmission(t, "A000145", "alice", []string{"mov", "add"})
+	assert.False(t, f.Match(wrongId), "Expected a seqId outside the glob to be rejected")
+
+	missingOp := newFilterTestSubmission(t, "A000045", "alice", []string{"mov"})
+	assert.False(t, f.Match(missingOp), "Expected a submission missing a required op to be rejected")
+
+	wrongSubmitter := newFilterTestSubmission(t, "A000045", "bob", []string{"mov", "add"})
+	assert.False(t, f.Match(wrongSubmitter), "Expected a submitter outside the glob to be rejected")
+}
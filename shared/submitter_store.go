@@ -0,0 +1,290 @@
+package shared
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const SubmitterStoreFile = "submitters_auth.json"
+
+// SubmitterUser is a registered submitter identity: an email for contact
+// purposes and the submitter name that must match the "submitter" field of
+// any submission made with one of its tokens.
+type SubmitterUser struct {
+	ID            int64     `json:"id"`
+	Email         string    `json:"email"`
+	SubmitterName string    `json:"submitter_name"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SubmitterScope gates what a SubmitterToken may be used for, so a token
+// minted for day-to-day mining doesn't also double as an admin credential.
+type SubmitterScope string
+
+const (
+	ScopeSubmitProgram   SubmitterScope = "submit-program"
+	ScopeRefreshSequence SubmitterScope = "refresh-sequence"
+	ScopeAdmin           SubmitterScope = "admin"
+)
+
+// defaultTokenScopes are the scopes granted to the token Register mints
+// alongside a new user: enough to mine and request refreshes, but not to
+// reach the admin-only /v2/tokens surface.
+var defaultTokenScopes = []SubmitterScope{ScopeSubmitProgram, ScopeRefreshSequence}
+
+// SubmitterToken is a bearer token issued to a SubmitterUser. Only its
+// SHA-256 hash is ever persisted; the raw token is returned to the caller
+// once, at registration time, and never stored. PerHourLimit and
+// PerDayLimit are submission quotas enforced per-token (0 means
+// unlimited); Disabled lets an admin suspend a token without revoking it
+// outright.
+type SubmitterToken struct {
+	ID           int64            `json:"id"`
+	TokenHash    string           `json:"-"`
+	UserID       int64            `json:"user_id"`
+	Scopes       []SubmitterScope `json:"scopes"`
+	PerHourLimit int              `json:"per_hour_limit,omitempty"`
+	PerDayLimit  int              `json:"per_day_limit,omitempty"`
+	Disabled     bool             `json:"disabled"`
+	CreatedAt    time.Time        `json:"created_at"`
+	RevokedAt    *time.Time       `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether t is allowed to be used for scope.
+func (t SubmitterToken) HasScope(scope SubmitterScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// submitterStoreData is the on-disk representation of a SubmitterStore,
+// persisted as a single JSON file rather than a real SQL database, in
+// keeping with how the rest of this package favors small file-backed
+// stores (see RefreshQueue, data_index_snapshot.go) over external
+// dependencies.
+type submitterStoreData struct {
+	Users       []SubmitterUser  `json:"users"`
+	Tokens      []SubmitterToken `json:"tokens"`
+	NextUserID  int64            `json:"next_user_id"`
+	NextTokenID int64            `json:"next_token_id"`
+}
+
+// SubmitterStore registers submitter users and their bearer tokens, and
+// authenticates tokens back to the user that owns them.
+type SubmitterStore struct {
+	path string
+
+	mutex sync.Mutex
+	data  submitterStoreData
+}
+
+// NewSubmitterStore creates a SubmitterStore backed by dataDir, loading any
+// existing users and tokens.
+func NewSubmitterStore(dataDir string) *SubmitterStore {
+	s := &SubmitterStore{
+		path: filepath.Join(dataDir, SubmitterStoreFile),
+		data: submitterStoreData{NextUserID: 1, NextTokenID: 1},
+	}
+	if raw, err := os.ReadFile(s.path); err == nil {
+		var data submitterStoreData
+		if err := json.Unmarshal(raw, &data); err == nil {
+			s.data = data
+		}
+	}
+	return s
+}
+
+// save persists s.data via a temp file plus atomic rename. Caller must hold
+// mutex.
+func (s *SubmitterStore) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal submitter store: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0600); err != nil {
+		return fmt.Errorf("cannot write submitter store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("cannot rename submitter store: %w", err)
+	}
+	return nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash of a raw token, the form
+// in which tokens are persisted and compared.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRawToken generates a fresh 32-byte bearer token, hex-encoded.
+func newRawToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("cannot generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Register creates a new SubmitterUser with the given email and submitter
+// name, and a bearer token for it. The raw token is returned only here;
+// callers are expected to email it to the user (or, in dev, log it) since
+// it cannot be recovered afterwards.
+func (s *SubmitterStore) Register(email, submitterName string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	token, err := newRawToken()
+	if err != nil {
+		return "", err
+	}
+	user := SubmitterUser{
+		ID:            s.data.NextUserID,
+		Email:         email,
+		SubmitterName: submitterName,
+		CreatedAt:     time.Now(),
+	}
+	s.data.NextUserID++
+	s.data.Users = append(s.data.Users, user)
+	s.data.Tokens = append(s.data.Tokens, SubmitterToken{
+		ID:        s.data.NextTokenID,
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Scopes:    defaultTokenScopes,
+		CreatedAt: user.CreatedAt,
+	})
+	s.data.NextTokenID++
+	if err := s.save(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// IssueToken mints an additional bearer token for the user registered under
+// ownerEmail, with the given scopes and per-hour/per-day submission quotas
+// (0 means unlimited). It's how an admin hands out a scoped-down or
+// quota-limited token to an existing user without re-registering them. The
+// raw token is returned only here, same as Register.
+func (s *SubmitterStore) IssueToken(ownerEmail string, scopes []SubmitterScope, perHourLimit, perDayLimit int) (string, SubmitterToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var owner *SubmitterUser
+	for i, u := range s.data.Users {
+		if u.Email == ownerEmail {
+			owner = &s.data.Users[i]
+			break
+		}
+	}
+	if owner == nil {
+		return "", SubmitterToken{}, fmt.Errorf("unknown submitter user: %s", ownerEmail)
+	}
+	token, err := newRawToken()
+	if err != nil {
+		return "", SubmitterToken{}, err
+	}
+	issued := SubmitterToken{
+		ID:           s.data.NextTokenID,
+		TokenHash:    hashToken(token),
+		UserID:       owner.ID,
+		Scopes:       scopes,
+		PerHourLimit: perHourLimit,
+		PerDayLimit:  perDayLimit,
+		CreatedAt:    time.Now(),
+	}
+	s.data.NextTokenID++
+	s.data.Tokens = append(s.data.Tokens, issued)
+	if err := s.save(); err != nil {
+		return "", SubmitterToken{}, err
+	}
+	return token, issued, nil
+}
+
+// ListTokens returns every token in the store, oldest first. TokenHash is
+// never populated in the JSON encoding (see SubmitterToken), so this is
+// safe to serve straight to an admin endpoint.
+func (s *SubmitterStore) ListTokens() []SubmitterToken {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tokens := make([]SubmitterToken, len(s.data.Tokens))
+	copy(tokens, s.data.Tokens)
+	return tokens
+}
+
+// AuthenticateToken looks up the user and token for token. It returns an
+// error if the token is unknown, revoked, disabled, or its user no longer
+// exists.
+func (s *SubmitterStore) AuthenticateToken(token string) (*SubmitterUser, *SubmitterToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hash := hashToken(token)
+	for _, t := range s.data.Tokens {
+		if t.TokenHash != hash {
+			continue
+		}
+		if t.RevokedAt != nil {
+			return nil, nil, fmt.Errorf("token revoked")
+		}
+		if t.Disabled {
+			return nil, nil, fmt.Errorf("token disabled")
+		}
+		for _, u := range s.data.Users {
+			if u.ID == t.UserID {
+				user, tok := u, t
+				return &user, &tok, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("token has no owning user")
+	}
+	return nil, nil, fmt.Errorf("unknown token")
+}
+
+// Authenticate looks up the user owning token. It returns an error if the
+// token is unknown, revoked, disabled, or its user no longer exists.
+func (s *SubmitterStore) Authenticate(token string) (*SubmitterUser, error) {
+	user, _, err := s.AuthenticateToken(token)
+	return user, err
+}
+
+// RevokeToken marks token as revoked, so future Authenticate calls reject
+// it. It is a no-op error if the token is unknown.
+func (s *SubmitterStore) RevokeToken(token string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hash := hashToken(token)
+	for i, t := range s.data.Tokens {
+		if t.TokenHash != hash {
+			continue
+		}
+		now := time.Now()
+		s.data.Tokens[i].RevokedAt = &now
+		return s.save()
+	}
+	return fmt.Errorf("unknown token")
+}
+
+// RevokeTokenByID is RevokeToken for an admin that only knows a token's ID
+// (as returned by ListTokens), not the raw secret, which is never
+// persisted or listable.
+func (s *SubmitterStore) RevokeTokenByID(id int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, t := range s.data.Tokens {
+		if t.ID != id {
+			continue
+		}
+		now := time.Now()
+		s.data.Tokens[i].RevokedAt = &now
+		return s.save()
+	}
+	return fmt.Errorf("unknown token id: %d", id)
+}
@@ -0,0 +1,65 @@
+package shared
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitterStore_RegisterAndAuthenticate(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewSubmitterStore(tempDir)
+	token, err := store.Register("alice@example.com", "alice")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	user, err := store.Authenticate(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.SubmitterName)
+	assert.Equal(t, "alice@example.com", user.Email)
+}
+
+func TestSubmitterStore_AuthenticateUnknownToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewSubmitterStore(tempDir)
+	_, err = store.Authenticate("does-not-exist")
+	assert.Error(t, err)
+}
+
+func TestSubmitterStore_RevokeToken(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store := NewSubmitterStore(tempDir)
+	token, err := store.Register("bob@example.com", "bob")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.RevokeToken(token))
+	_, err = store.Authenticate(token)
+	assert.Error(t, err)
+
+	assert.Error(t, store.RevokeToken("does-not-exist"))
+}
+
+func TestSubmitterStore_PersistenceAcrossInstances(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "submitter-store-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	store1 := NewSubmitterStore(tempDir)
+	token, err := store1.Register("carol@example.com", "carol")
+	assert.NoError(t, err)
+
+	store2 := NewSubmitterStore(tempDir)
+	user, err := store2.Authenticate(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "carol", user.SubmitterName)
+}
@@ -0,0 +1,144 @@
+package shared
+
+import (
+	"sort"
+	"strings"
+)
+
+// TrigramSearcher locates programs or sequences whose indexed text contains
+// a substring, via trigram posting-list intersection instead of scanning
+// every entry. SearchPrograms and SearchSequences depend on this interface
+// rather than *TrigramIndex directly, so a DataIndex built without calling
+// Load (as tests do) can still search, by falling back to exact-token
+// matching instead of paying to build the index.
+type TrigramSearcher interface {
+	// Substring returns the sorted offsets whose indexed text contains tok,
+	// case-insensitively, and true. It returns (nil, false) for tok shorter
+	// than 3 runes, since those can't be decomposed into trigrams; the
+	// caller should fall back to a linear scan for those.
+	Substring(tok string) ([]int, bool)
+}
+
+// trigramFieldSep joins a text entry's fields (name, submitter, authors...)
+// so a trigram straddling two fields never collides with a real query
+// token, since tokens only ever contain letters and digits.
+const trigramFieldSep = "\x00"
+
+// TrigramIndex is a TrigramSearcher built once over a slice of searchable
+// text, one entry per program or sequence offset.
+type TrigramIndex struct {
+	// postings maps a case-folded trigram to the sorted offsets of entries
+	// whose text contains it.
+	postings map[string][]int
+	// texts holds each offset's case-folded text, for the Contains
+	// post-filter that weeds out false positives from trigram overlap.
+	texts []string
+}
+
+// BuildTrigramIndex indexes texts, one joined-fields string per
+// program/sequence offset in the slice it was built from (see
+// programTrigramTexts and sequenceTrigramTexts). Callers must rebuild it
+// whenever that slice is replaced, e.g. after a reload.
+func BuildTrigramIndex(texts []string) *TrigramIndex {
+	idx := &TrigramIndex{
+		postings: make(map[string][]int),
+		texts:    make([]string, len(texts)),
+	}
+	for offset, text := range texts {
+		folded := strings.ToLower(text)
+		idx.texts[offset] = folded
+		seen := make(map[string]struct{})
+		for _, tg := range trigrams(folded) {
+			seen[tg] = struct{}{}
+		}
+		for tg := range seen {
+			idx.postings[tg] = append(idx.postings[tg], offset)
+		}
+	}
+	return idx
+}
+
+// trigrams returns every 3-rune substring of s, in order, or nil if s has
+// fewer than 3 runes.
+func trigrams(s string) []string {
+	r := []rune(s)
+	if len(r) < 3 {
+		return nil
+	}
+	out := make([]string, 0, len(r)-2)
+	for i := 0; i+3 <= len(r); i++ {
+		out = append(out, string(r[i:i+3]))
+	}
+	return out
+}
+
+// Substring implements TrigramSearcher: it decomposes tok into trigrams,
+// intersects their posting lists with gallopingIntersect, and then runs
+// strings.Contains against the candidate set to discard matches that only
+// share trigrams with tok rather than containing it outright.
+func (idx *TrigramIndex) Substring(tok string) ([]int, bool) {
+	tok = strings.ToLower(tok)
+	tgs := trigrams(tok)
+	if tgs == nil {
+		return nil, false
+	}
+	var candidates []int
+	started := false
+	for _, tg := range tgs {
+		postings := idx.postings[tg]
+		if len(postings) == 0 {
+			return nil, true
+		}
+		if !started {
+			candidates, started = postings, true
+			continue
+		}
+		candidates = gallopingIntersect(candidates, postings)
+		if len(candidates) == 0 {
+			return nil, true
+		}
+	}
+	filtered := make([]int, 0, len(candidates))
+	for _, offset := range candidates {
+		if strings.Contains(idx.texts[offset], tok) {
+			filtered = append(filtered, offset)
+		}
+	}
+	return filtered, true
+}
+
+// gallopingIntersect returns the sorted intersection of a and b. Trigram
+// posting lists often differ wildly in size (a common trigram against a
+// rare one), so instead of a linear two-pointer walk, it gallops: for each
+// element of the shorter slice, it doubles its step through the longer one
+// to bracket a matching range, then binary-searches inside that bracket.
+func gallopingIntersect(a, b []int) []int {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	out := make([]int, 0, len(a))
+	j := 0
+	for _, v := range a {
+		if j >= len(b) {
+			break
+		}
+		step := 1
+		k := j
+		for k < len(b) && b[k] < v {
+			j = k
+			step *= 2
+			k += step
+		}
+		hi := k
+		if hi > len(b) {
+			hi = len(b)
+		}
+		off := sort.Search(hi-j, func(i int) bool { return b[j+i] >= v })
+		j += off
+		if j < len(b) && b[j] == v {
+			out = append(out, v)
+			j++
+		}
+	}
+	return out
+}
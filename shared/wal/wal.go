@@ -0,0 +1,374 @@
+// Package wal is a crash-safe, disk-backed write-ahead log of JSON records,
+// modeled on shared.RefreshQueue's segmented frame format. It exists to give
+// callers like cmd/submissions a durability floor tighter than a periodic
+// checkpoint: every Append is fsynced before it returns, so a crash can
+// never lose an already-acknowledged record, while Truncate lets the caller
+// drop the segments a later snapshot has made redundant.
+package wal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSegmentBytes is the segment size at which Append seals the
+	// active segment and starts a fresh one, if the caller doesn't need a
+	// different size.
+	DefaultMaxSegmentBytes = 64 * 1024 * 1024
+
+	// walFrameHeaderSize is the length of the length+CRC32 header that
+	// precedes every frame's payload.
+	walFrameHeaderSize = 8
+
+	walSegmentSuffix = ".log"
+)
+
+// walMeta is persisted as "<name>.meta" and tracks which segment is
+// currently being written to.
+type walMeta struct {
+	WriteSegment int64 `json:"write_segment"`
+}
+
+// Stats summarizes a WAL's on-disk state for operators, e.g. for a
+// GET .../wal/stats endpoint.
+type Stats struct {
+	Segments  int       `json:"segments"`
+	Bytes     int64     `json:"bytes"`
+	LastFsync time.Time `json:"last_fsync"`
+}
+
+// WAL appends JSON records as length-prefixed, CRC32-checked frames to a
+// rolling set of segment files (<name>.NNNNNNNN.log) under dataDir, capped
+// at maxSegmentBytes. Replay streams every record back in append order,
+// skipping any frame that fails its CRC check instead of wedging the log.
+// Truncate deletes every segment once a snapshot has captured their
+// contents.
+type WAL struct {
+	dataDir         string
+	name            string
+	maxSegmentBytes int64
+
+	mutex     sync.Mutex
+	file      *os.File
+	size      int64
+	meta      walMeta
+	lastFsync time.Time
+}
+
+// New creates a WAL named name (used as the segment and meta file prefix)
+// backed by dataDir, loading its meta file if one already exists.
+func New(dataDir string, name string, maxSegmentBytes int64) *WAL {
+	w := &WAL{
+		dataDir:         dataDir,
+		name:            name,
+		maxSegmentBytes: maxSegmentBytes,
+		meta:            walMeta{WriteSegment: 1},
+	}
+	if data, err := os.ReadFile(w.metaPath()); err == nil {
+		var meta walMeta
+		if err := json.Unmarshal(data, &meta); err == nil && meta.WriteSegment > 0 {
+			w.meta = meta
+		}
+	}
+	return w
+}
+
+func (w *WAL) metaPath() string {
+	return filepath.Join(w.dataDir, w.name+".meta")
+}
+
+func (w *WAL) segmentPath(seq int64) string {
+	return filepath.Join(w.dataDir, fmt.Sprintf("%s.%08d%s", w.name, seq, walSegmentSuffix))
+}
+
+// segments returns the sequence numbers of every existing segment file,
+// lowest (oldest) first.
+func (w *WAL) segments() ([]int64, error) {
+	matches, err := filepath.Glob(filepath.Join(w.dataDir, w.name+".*"+walSegmentSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("cannot list wal segments: %w", err)
+	}
+	seqs := make([]int64, 0, len(matches))
+	for _, path := range matches {
+		if seq, ok := w.parseSegmentSeq(path); ok {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (w *WAL) parseSegmentSeq(path string) (int64, bool) {
+	name := filepath.Base(path)
+	name = strings.TrimPrefix(name, w.name+".")
+	name = strings.TrimSuffix(name, walSegmentSuffix)
+	seq, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// saveMeta writes w.meta via a temp file plus atomic rename. Caller must
+// hold mutex.
+func (w *WAL) saveMeta() error {
+	data, err := json.Marshal(w.meta)
+	if err != nil {
+		return fmt.Errorf("cannot marshal wal meta: %w", err)
+	}
+	tmpPath := w.metaPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("cannot write wal meta: %w", err)
+	}
+	return os.Rename(tmpPath, w.metaPath())
+}
+
+func appendFrame(f *os.File, payload []byte) error {
+	var header [walFrameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+	if _, err := f.Write(header[:]); err != nil {
+		return fmt.Errorf("cannot write wal frame header: %w", err)
+	}
+	if _, err := f.Write(payload); err != nil {
+		return fmt.Errorf("cannot write wal frame payload: %w", err)
+	}
+	return nil
+}
+
+// readFrames reads length-prefixed, CRC32-checked frames from r, calling fn
+// with each payload in order. A frame whose CRC doesn't match is logged and
+// skipped without aborting the scan, so a single bit-flipped frame cannot
+// strand the records behind it.
+func readFrames(r io.Reader, fn func([]byte) error) error {
+	var offset int64
+	for {
+		var header [walFrameHeaderSize]byte
+		n, err := io.ReadFull(r, header[:])
+		if err != nil {
+			if err == io.EOF && n == 0 {
+				return nil
+			}
+			return nil // torn trailing frame: stop, nothing more to read
+		}
+		offset += int64(n)
+		length := binary.BigEndian.Uint32(header[0:4])
+		crc := binary.BigEndian.Uint32(header[4:8])
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil
+		}
+		offset += int64(length)
+		if crc32.ChecksumIEEE(payload) != crc {
+			log.Printf("Skipping corrupt wal frame at offset %d: checksum mismatch", offset-int64(length))
+			continue
+		}
+		if err := fn(payload); err != nil {
+			log.Printf("Skipping unreadable wal frame at offset %d: %v", offset-int64(length), err)
+		}
+	}
+}
+
+// Append marshals record as JSON and appends it to the active write
+// segment, fsyncing before it returns so the caller can treat a nil error
+// as a durability guarantee. It rotates to a fresh segment if the active
+// one has grown past maxSegmentBytes.
+func (w *WAL) Append(record interface{}) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("cannot marshal wal record: %w", err)
+	}
+	if w.file == nil {
+		if err := w.openWriteSegment(); err != nil {
+			return err
+		}
+	}
+	if err := appendFrame(w.file, payload); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync wal segment: %w", err)
+	}
+	w.lastFsync = time.Now()
+	w.size += walFrameHeaderSize + int64(len(payload))
+	if w.size >= w.maxSegmentBytes {
+		return w.rotate()
+	}
+	return nil
+}
+
+// openWriteSegment opens (creating if necessary) the active write segment.
+// If a prior crash left a torn trailing frame (e.g. a frame header promising
+// a payload that was never fully written), it's truncated off first, the
+// same way cmd/programs/wal.go's Load() truncates a torn tail on startup --
+// otherwise O_APPEND would start writing new frames right after the stale
+// header bytes, and the next Replay would misread them as a corrupt frame
+// instead of the garbage they are, while also losing every frame appended
+// since the reopen. Caller must hold mutex.
+func (w *WAL) openWriteSegment() error {
+	if w.dataDir != "" {
+		if err := os.MkdirAll(w.dataDir, 0755); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+	}
+	path := w.segmentPath(w.meta.WriteSegment)
+	tornAt, err := scanForTornTail(path)
+	if err != nil {
+		return fmt.Errorf("failed to scan wal segment for a torn tail: %w", err)
+	}
+	if tornAt >= 0 {
+		log.Printf("Truncating wal segment %s to %d bytes: torn trailing frame", path, tornAt)
+		if err := os.Truncate(path, tornAt); err != nil {
+			return fmt.Errorf("failed to truncate torn wal segment: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open wal segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat wal segment: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// scanForTornTail opens path and walks it with the same frame-parsing loop
+// readFrames uses, returning the offset of a torn trailing frame (an
+// incomplete header or payload left by a crash mid-append), or -1 if the
+// file ends cleanly, including when it doesn't exist yet. A checksum
+// mismatch is not torn -- like readFrames, it's skipped and the scan keeps
+// going, since it means a complete frame was corrupted, not that the writer
+// was interrupted mid-frame.
+func scanForTornTail(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, nil
+		}
+		return -1, err
+	}
+	defer f.Close()
+	var offset int64
+	for {
+		var header [walFrameHeaderSize]byte
+		n, err := io.ReadFull(f, header[:])
+		if err != nil {
+			if err == io.EOF && n == 0 {
+				return -1, nil
+			}
+			return offset, nil
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		crc := binary.BigEndian.Uint32(header[4:8])
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(f, payload); err != nil {
+			return offset, nil
+		}
+		offset += int64(walFrameHeaderSize) + int64(length)
+		if crc32.ChecksumIEEE(payload) != crc {
+			continue
+		}
+	}
+}
+
+// rotate seals the active segment and opens a fresh, empty one under the
+// next sequence number. Caller must hold mutex.
+func (w *WAL) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close wal segment: %w", err)
+	}
+	w.file = nil
+	w.meta.WriteSegment++
+	if err := w.saveMeta(); err != nil {
+		return err
+	}
+	return w.openWriteSegment()
+}
+
+// Replay calls fn with the payload of every record currently on disk,
+// oldest first, so a caller can rebuild in-memory state after loading its
+// last snapshot. It does not consume the records; call Truncate separately
+// once the caller has durably captured them in a new snapshot.
+func (w *WAL) Replay(fn func([]byte) error) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	seqs, err := w.segments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		f, err := os.Open(w.segmentPath(seq))
+		if err != nil {
+			log.Printf("Cannot open wal segment %d: %v", seq, err)
+			continue
+		}
+		err = readFrames(f, fn)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Truncate deletes every segment on disk and resets the write sequence, for
+// use once a snapshot has durably captured everything the WAL held.
+func (w *WAL) Truncate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	seqs, err := w.segments()
+	if err != nil {
+		return err
+	}
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("failed to close wal segment: %w", err)
+		}
+		w.file = nil
+	}
+	for _, seq := range seqs {
+		if err := os.Remove(w.segmentPath(seq)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Cannot remove truncated wal segment %d: %v", seq, err)
+		}
+	}
+	w.size = 0
+	w.meta = walMeta{WriteSegment: 1}
+	return w.saveMeta()
+}
+
+// Stats reports the WAL's current segment count, total bytes on disk, and
+// the time of the last successful fsync.
+func (w *WAL) Stats() Stats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	seqs, err := w.segments()
+	if err != nil {
+		log.Printf("Cannot list wal segments: %v", err)
+		return Stats{LastFsync: w.lastFsync}
+	}
+	var bytes int64
+	for _, seq := range seqs {
+		if info, err := os.Stat(w.segmentPath(seq)); err == nil {
+			bytes += info.Size()
+		}
+	}
+	return Stats{Segments: len(seqs), Bytes: bytes, LastFsync: w.lastFsync}
+}
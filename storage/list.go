@@ -0,0 +1,457 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/loda-lang/loda-api/crawler"
+	"github.com/loda-lang/loda-api/util"
+)
+
+var (
+	lineRegexp         = regexp.MustCompile(`A([0-9]+): (.+)`)
+	deltaSegmentRegexp = regexp.MustCompile(`^([0-9]+)\.delta\.gz$`)
+)
+
+// maxDeltaSegments bounds how many rolling delta journal segments
+// ReadDeltasSince can serve per list; once exceeded, the oldest segment is
+// pruned, so a client whose session has fallen further behind than this
+// must fall back to a full download.
+const maxDeltaSegments = 500
+
+// ErrDeltaResyncRequired is returned by ReadDeltasSince when session is
+// older than what the bounded delta journal still retains, so the gap
+// in between can no longer be served incrementally.
+var ErrDeltaResyncRequired = errors.New("full-resync-required")
+
+// List is the on-disk, gzipped, sorted-by-seqId file backing a single OEIS
+// field key (authors, comments, formulas, ...). Fields are buffered in
+// memory by Update and merged into the file by Flush.
+type List struct {
+	Name    string
+	key     string
+	dataDir string
+	fields  []crawler.Field
+	mutex   sync.Mutex
+}
+
+func NewList(key, name, dataDir string) *List {
+	return &List{
+		key:     key,
+		Name:    name,
+		dataDir: dataDir,
+	}
+}
+
+func (l *List) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.fields)
+}
+
+// Key returns the field key (e.g. "A", "C", "F") this list buffers.
+func (l *List) Key() string {
+	return l.key
+}
+
+func (l *List) Update(fields []crawler.Field) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, field := range fields {
+		if field.Key == l.key {
+			l.fields = append(l.fields, field)
+		}
+	}
+}
+
+// Flush merges the buffered fields into the list's gzipped file on disk.
+// The old content is streamed straight out of a gzip.Reader and the merged
+// result straight into a gzip.Writer over a temp file, which is then
+// renamed into place atomically; unlike the old gzip/gunzip shell-out, no
+// uncompressed copy of the list ever touches disk. ctx bounds the merge: if
+// it is cancelled or its deadline expires before the merge finishes, Flush
+// aborts and returns ctx.Err(), leaving the buffered fields intact for a
+// retry.
+func (l *List) Flush(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("Flushing %s", l.Name)
+	// Check and sort fields
+	if len(l.fields) == 0 {
+		return nil
+	}
+	sort.Slice(l.fields, func(i, j int) bool {
+		f := l.fields[i]
+		g := l.fields[j]
+		return (f.SeqId < g.SeqId) || (f.SeqId == g.SeqId && f.Content < g.Content)
+	})
+	path := filepath.Join(l.dataDir, l.Name)
+	gzPath := path + ".gz"
+	tmpPath := gzPath + ".tmp"
+
+	var old io.Reader = new(bytes.Reader)
+	if util.FileExists(gzPath) {
+		oldFile, err := os.Open(gzPath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer oldFile.Close()
+		oldGz, err := gzip.NewReader(oldFile)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip reader: %w", err)
+		}
+		defer oldGz.Close()
+		old = oldGz
+	}
+
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	target := util.GetGzipWriter(tmpFile)
+	err = mergeLists(ctx, l.fields, old, target)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	util.PutGzipWriter(target)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to merge lists: %w", err)
+	}
+	if err := os.Rename(tmpPath, gzPath); err != nil {
+		return fmt.Errorf("failed to rename merged file: %w", err)
+	}
+	if _, err := l.appendDeltaLocked(ctx, l.fields); err != nil {
+		// The full list is already flushed to disk; a broken delta journal
+		// only degrades incremental polling, so it's logged, not fatal.
+		log.Printf("Failed appending delta for %s: %v", l.Name, err)
+	}
+	l.fields = nil
+	return nil
+}
+
+// FindMissingIds scans the gzipped list directly via a gzip.Reader, with no
+// decompress/recompress round trip to a plain-text copy. ctx bounds the
+// scan; a cancelled or expired ctx aborts it and returns ctx.Err().
+func (l *List) FindMissingIds(ctx context.Context, maxId int, maxNumIds int) ([]int, int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	log.Printf("Finding missing %s", l.Name)
+	gzPath := filepath.Join(l.dataDir, l.Name) + ".gz"
+	if !util.FileExists(gzPath) {
+		log.Printf("No %s available", l.Name)
+		return nil, 0, nil // not an error
+	}
+	file, err := os.Open(gzPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open gzip reader: %w", err)
+	}
+	defer gz.Close()
+	ids, numMissing, err := findMissingIds(ctx, gz, maxId, maxNumIds)
+	if err != nil {
+		return nil, 0, err
+	}
+	log.Printf("Found %d/%d missing %s", len(ids), numMissing, l.Name)
+	return ids, numMissing, nil
+}
+
+// deltaDir is the directory holding this list's rolling delta journal, one
+// gzipped segment file per flushed batch.
+func (l *List) deltaDir() string {
+	return filepath.Join(l.dataDir, "deltas", l.Name)
+}
+
+func (l *List) deltaSegmentPath(session int64) string {
+	return filepath.Join(l.deltaDir(), fmt.Sprintf("%d.delta.gz", session))
+}
+
+// deltaSegments returns the session ids of all delta segments currently on
+// disk, sorted oldest first.
+func (l *List) deltaSegments() ([]int64, error) {
+	matches, err := filepath.Glob(filepath.Join(l.deltaDir(), "*.delta.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed listing delta segments: %w", err)
+	}
+	sessions := make([]int64, 0, len(matches))
+	for _, m := range matches {
+		sub := deltaSegmentRegexp.FindStringSubmatch(filepath.Base(m))
+		if sub == nil {
+			continue
+		}
+		session, err := strconv.ParseInt(sub[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i] < sessions[j] })
+	return sessions, nil
+}
+
+// AppendDelta writes fields as a new segment of the list's rolling delta
+// journal and returns its session id, a monotonically increasing sequence
+// number that ReadDeltasSince uses to resume from. It acquires the list's
+// mutex itself; Flush calls the unlocked appendDeltaLocked instead since it
+// already holds the lock.
+func (l *List) AppendDelta(ctx context.Context, fields []crawler.Field) (int64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.appendDeltaLocked(ctx, fields)
+}
+
+func (l *List) appendDeltaLocked(ctx context.Context, fields []crawler.Field) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	sessions, err := l.deltaSegments()
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		if len(sessions) == 0 {
+			return 0, nil
+		}
+		return sessions[len(sessions)-1], nil
+	}
+	if err := os.MkdirAll(l.deltaDir(), os.ModePerm); err != nil {
+		return 0, fmt.Errorf("failed creating delta dir: %w", err)
+	}
+	session := int64(1)
+	if len(sessions) > 0 {
+		session = sessions[len(sessions)-1] + 1
+	}
+	path := l.deltaSegmentPath(session)
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed creating delta segment: %w", err)
+	}
+	target := util.GetGzipWriter(f)
+	for _, field := range fields {
+		if _, err := io.WriteString(target, formatField(field)+"\n"); err != nil {
+			target.Close()
+			util.PutGzipWriter(target)
+			f.Close()
+			os.Remove(tmpPath)
+			return 0, fmt.Errorf("failed writing delta segment: %w", err)
+		}
+	}
+	if err := target.Close(); err != nil {
+		util.PutGzipWriter(target)
+		f.Close()
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed closing delta segment: %w", err)
+	}
+	util.PutGzipWriter(target)
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed closing delta segment: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, fmt.Errorf("failed renaming delta segment: %w", err)
+	}
+	l.pruneDeltaSegments(append(sessions, session))
+	return session, nil
+}
+
+// pruneDeltaSegments removes the oldest delta segments once more than
+// maxDeltaSegments exist, so the journal doesn't grow without bound.
+func (l *List) pruneDeltaSegments(sessions []int64) {
+	if len(sessions) <= maxDeltaSegments {
+		return
+	}
+	for _, session := range sessions[:len(sessions)-maxDeltaSegments] {
+		if err := os.Remove(l.deltaSegmentPath(session)); err != nil {
+			log.Printf("Failed pruning delta segment %d for %s: %v", session, l.Name, err)
+		}
+	}
+}
+
+// ReadDeltasSince streams every delta segment newer than session as gzipped
+// "A######: content" lines, directly to target, and returns the session id
+// of the newest segment included (or session unchanged if nothing is new).
+// If session is older than the oldest segment the bounded journal still
+// retains, it returns ErrDeltaResyncRequired instead, so the caller can
+// fall back to a full download. ctx bounds the read.
+func (l *List) ReadDeltasSince(ctx context.Context, session int64, target io.Writer) (int64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return session, err
+	}
+	sessions, err := l.deltaSegments()
+	if err != nil {
+		return session, err
+	}
+	if len(sessions) == 0 {
+		return session, nil
+	}
+	if session < sessions[0]-1 {
+		return session, ErrDeltaResyncRequired
+	}
+	gz := util.GetGzipWriter(target)
+	defer util.PutGzipWriter(gz)
+	newSession := session
+	for _, s := range sessions {
+		if s <= session {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			gz.Close()
+			return newSession, err
+		}
+		if err := copyDeltaSegment(gz, l.deltaSegmentPath(s)); err != nil {
+			gz.Close()
+			return newSession, err
+		}
+		newSession = s
+	}
+	if err := gz.Close(); err != nil {
+		return newSession, fmt.Errorf("failed closing delta response: %w", err)
+	}
+	return newSession, nil
+}
+
+func copyDeltaSegment(target io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed opening delta segment: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed opening delta segment reader: %w", err)
+	}
+	defer gz.Close()
+	if _, err := io.Copy(target, gz); err != nil {
+		return fmt.Errorf("failed copying delta segment: %w", err)
+	}
+	return nil
+}
+
+func formatField(field crawler.Field) string {
+	return fmt.Sprintf("A%06d: %s", field.SeqId, field.Content)
+}
+
+func parseLine(line string) (crawler.Field, error) {
+	matches := lineRegexp.FindStringSubmatch(line)
+	if len(matches) != 3 {
+		return crawler.Field{}, fmt.Errorf("failed parsing line: %s", line)
+	}
+	seqId, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return crawler.Field{}, fmt.Errorf("failed parsing seqId: %w", err)
+	}
+	return crawler.Field{
+		Key:     "",
+		SeqId:   seqId,
+		Content: matches[2],
+	}, nil
+}
+
+func mergeLists(ctx context.Context, fields []crawler.Field, old io.Reader, target io.Writer) error {
+	// Merges fields with old list and writes to target list
+	i := 0
+	scanner := bufio.NewScanner(old)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Read and parse old line
+		line := scanner.Text()
+		f, err := parseLine(line)
+		if err != nil {
+			return err
+		}
+		// Write all new fields with smaller seqId
+		for i < len(fields) && (fields[i].SeqId < f.SeqId || (fields[i].SeqId == f.SeqId && fields[i].Content < f.Content)) {
+			_, err := io.WriteString(target, formatField(fields[i])+"\n")
+			if err != nil {
+				return fmt.Errorf("failed writing field: %w", err)
+			}
+			i++
+		}
+		// Write old line if it is not the same as the new field
+		if i >= len(fields) || fields[i].SeqId != f.SeqId || fields[i].Content != f.Content {
+			_, err = io.WriteString(target, line+"\n")
+			if err != nil {
+				return fmt.Errorf("failed writing line: %w", err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed reading old list: %w", err)
+	}
+	// Write remaining new fields
+	for i < len(fields) {
+		_, err := io.WriteString(target, formatField(fields[i])+"\n")
+		if err != nil {
+			return fmt.Errorf("failed writing field: %w", err)
+		}
+		i++
+	}
+	return nil
+}
+
+func findMissingIds(ctx context.Context, r io.Reader, maxId int, maxNumIds int) ([]int, int, error) {
+	ids := []int{}
+	nextId := 1
+	numMissing := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		line := scanner.Text()
+		f, err := parseLine(line)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := nextId; i < f.SeqId && len(ids) < maxNumIds; i++ {
+			ids = append(ids, i)
+		}
+		numMissing += f.SeqId - nextId + 1
+		nextId = f.SeqId + 1
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed reading list: %w", err)
+	}
+	for i := nextId; i <= maxId && len(ids) < maxNumIds; i++ {
+		ids = append(ids, i)
+	}
+	numMissing += maxId - nextId - 1
+	return ids, numMissing, nil
+}
+
+func (l *List) ServeGzip(w http.ResponseWriter, r *http.Request) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	util.ServeBinary(w, r, filepath.Join(l.dataDir, l.Name+".gz"))
+}
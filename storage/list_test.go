@@ -1,15 +1,19 @@
-package main
+package storage
 
 import (
+	"bytes"
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/loda-lang/loda-api/crawler"
 	"github.com/loda-lang/loda-api/util"
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	testFields = []Field{
+	testFields = []crawler.Field{
 		{Key: "S", SeqId: 1, Content: "test1"},
 		{Key: "T", SeqId: 2, Content: "test2"},
 		{Key: "T", SeqId: 2, Content: "test3"},
@@ -27,15 +31,16 @@ func TestList_Update(t *testing.T) {
 func TestList_Flush(t *testing.T) {
 	l := NewList("T", "test1", ".")
 	l.Update(testFields)
-	err := l.Flush()
+	err := l.Flush(context.Background())
 	assert.Equal(t, nil, err, "Expected no error")
 	assert.Equal(t, 0, l.Len(), "Unexpected length")
 	assert.True(t, util.FileExists("test1.gz"), "Expected file to exist")
 	os.Remove("test1.gz")
+	os.RemoveAll(filepath.Join(".", "deltas", "test1"))
 }
 
 func testFindMissingIds(t *testing.T, l *List, maxId, maxNumIds, expectedNumMissing int, expected []int) {
-	ids, numMissing, err := l.FindMissingIds(maxId, maxNumIds)
+	ids, numMissing, err := l.FindMissingIds(context.Background(), maxId, maxNumIds)
 	assert.Equal(t, nil, err, "Expected no error")
 	assert.Equal(t, expectedNumMissing, numMissing, "Unexpected number of missing ids")
 	assert.Equal(t, expected, ids, "Unexpected ids")
@@ -44,7 +49,7 @@ func testFindMissingIds(t *testing.T, l *List, maxId, maxNumIds, expectedNumMiss
 func TestList_FindMissingIds(t *testing.T) {
 	l := NewList("T", "test2", ".")
 	l.Update(testFields)
-	l.Flush()
+	l.Flush(context.Background())
 	testFindMissingIds(t, l, 5, 2, 3, []int{1, 3})
 	testFindMissingIds(t, l, 6, 2, 4, []int{1, 3})
 	testFindMissingIds(t, l, 6, 3, 4, []int{1, 3, 4})
@@ -52,4 +57,32 @@ func TestList_FindMissingIds(t *testing.T) {
 	testFindMissingIds(t, l, 6, 5, 4, []int{1, 3, 4, 6})
 	testFindMissingIds(t, l, 7, 5, 5, []int{1, 3, 4, 6, 7})
 	os.Remove("test2.gz")
+	os.RemoveAll(filepath.Join(".", "deltas", "test2"))
+}
+
+func TestList_ReadDeltasSince(t *testing.T) {
+	l := NewList("T", "test3", ".")
+	defer os.Remove("test3.gz")
+	defer os.RemoveAll(filepath.Join(".", "deltas", "test3"))
+
+	l.Update(testFields)
+	err := l.Flush(context.Background())
+	assert.Equal(t, nil, err, "Expected no error")
+
+	var buf bytes.Buffer
+	session, err := l.ReadDeltasSince(context.Background(), 0, &buf)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, int64(1), session, "Unexpected session")
+	assert.True(t, buf.Len() > 0, "Expected some delta content")
+
+	// Polling again with the latest session returns no new content.
+	buf.Reset()
+	session, err = l.ReadDeltasSince(context.Background(), session, &buf)
+	assert.Equal(t, nil, err, "Expected no error")
+	assert.Equal(t, int64(1), session, "Unexpected session")
+
+	// A session older than the first retained segment requires a full resync.
+	l2 := NewList("T", "test3", ".")
+	_, err = l2.ReadDeltasSince(context.Background(), -1, &buf)
+	assert.Equal(t, ErrDeltaResyncRequired, err, "Expected a full resync to be required")
 }
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Comparator compares two values of the same underlying record type,
+// mirroring the gostl-style comparator shape: negative if a sorts before
+// b, zero if they're equal (or the comparator doesn't apply to their
+// concrete type), positive if a sorts after b.
+type Comparator func(a, b interface{}) int
+
+// Chain composes comparators in priority order: the first one to return
+// non-zero decides, and later comparators only break ties left by earlier
+// ones.
+func Chain(comparators ...Comparator) Comparator {
+	return func(a, b interface{}) int {
+		for _, cmp := range comparators {
+			if c := cmp(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// Reverse flips a comparator's sense, for a field's ":desc" direction.
+func Reverse(cmp Comparator) Comparator {
+	return func(a, b interface{}) int { return -cmp(a, b) }
+}
+
+// ParseOrder decodes an "order=name:asc,id:desc"-style query value into a
+// single comparator that chains each field via Chain, in the order given,
+// resolving field names against registry. An unknown field name, or a
+// direction other than "asc"/"desc" (asc is the default), is skipped
+// rather than erroring, the same lenient best-effort handling
+// ParseLimitSkip already gives its own parameters. Returns nil if order is
+// empty or none of its fields resolve.
+func ParseOrder(order string, registry map[string]Comparator) Comparator {
+	if order == "" {
+		return nil
+	}
+	var chain []Comparator
+	for _, field := range strings.Split(order, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, dir, _ := strings.Cut(field, ":")
+		cmp, ok := registry[name]
+		if !ok {
+			continue
+		}
+		if dir == "desc" {
+			cmp = Reverse(cmp)
+		}
+		chain = append(chain, cmp)
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return Chain(chain...)
+}
+
+// ParseListParams is ParseLimitSkipShuffle plus an "order=" query
+// parameter decoded via ParseOrder against registry, for handlers that
+// page over a comparator-sortable result set (e.g. Submission, Sequence)
+// instead of a single hard-coded order.
+func ParseListParams(req *http.Request, defaultLimit, maxLimit int, registry map[string]Comparator) (limit, skip int, shuffle bool, orderBy Comparator) {
+	limit, skip, shuffle = ParseLimitSkipShuffle(req, defaultLimit, maxLimit)
+	orderBy = ParseOrder(req.URL.Query().Get("order"), registry)
+	return
+}
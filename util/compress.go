@@ -0,0 +1,207 @@
+package util
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipWriterPool reuses gzip.Writer instances across every in-process
+// compression call site (CompressFile/CompressFileKeep here and
+// storage.List's flush/delta pipelines), so a busy crawler cycle doesn't
+// allocate a fresh flate compressor per call.
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// GetGzipWriter returns a gzip.Writer from gzipWriterPool, reset to write
+// to w. Callers must return it via PutGzipWriter once they're done with it
+// (after Close, which still flushes through to w).
+func GetGzipWriter(w io.Writer) *gzip.Writer {
+	gz := gzipWriterPool.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return gz
+}
+
+// PutGzipWriter returns gz to gzipWriterPool. It does not close or flush
+// gz; callers must do that first.
+func PutGzipWriter(gz *gzip.Writer) {
+	gzipWriterPool.Put(gz)
+}
+
+// CompressFile gzip-compresses src in-process, writing the result to
+// src+".gz", and removes src on success. It replaces a shell-out to
+// `gzip -f src`.
+func CompressFile(src string) error {
+	if err := compressFileTo(src, src+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// CompressFileKeep behaves like CompressFile but leaves src in place. It
+// replaces a shell-out to `gzip -f -k src`.
+func CompressFileKeep(src string) error {
+	return compressFileTo(src, src+".gz")
+}
+
+// CompressFileAtomic gzip-compresses src into dst via a temp file plus
+// rename, so a concurrent reader of dst (e.g. an HTTP handler serving a
+// cached file while it's being refreshed) never observes a partially
+// written one. src is removed on success, like CompressFile.
+func CompressFileAtomic(src, dst string) error {
+	tmpDst := dst + ".tmp"
+	if err := compressFileTo(src, tmpDst); err != nil {
+		os.Remove(tmpDst)
+		return err
+	}
+	if err := os.Rename(tmpDst, dst); err != nil {
+		os.Remove(tmpDst)
+		return fmt.Errorf("failed to rename %s: %w", tmpDst, err)
+	}
+	return os.Remove(src)
+}
+
+func compressFileTo(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	gz := GetGzipWriter(out)
+	_, copyErr := io.Copy(gz, in)
+	closeErr := gz.Close()
+	PutGzipWriter(gz)
+	if closeErr == nil {
+		closeErr = out.Close()
+	} else {
+		out.Close()
+	}
+	if copyErr != nil {
+		return fmt.Errorf("failed to gzip %s: %w", src, copyErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to gzip %s: %w", src, closeErr)
+	}
+	return nil
+}
+
+// DecompressFile gunzips src, which must end in ".gz", in-process and
+// writes the result alongside it with the suffix removed. It replaces a
+// shell-out to `gzip -d -k src`; unlike plain `gzip -d`, src is left in
+// place, matching how callers use it to materialize a plain-text copy of a
+// file they still want to serve compressed.
+func DecompressFile(src string) error {
+	if !strings.HasSuffix(src, ".gz") {
+		return fmt.Errorf("not a .gz file: %s", src)
+	}
+	dst := strings.TrimSuffix(src, ".gz")
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip reader for %s: %w", src, err)
+	}
+	defer gz.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	_, copyErr := io.Copy(out, gz)
+	closeErr := out.Close()
+	if copyErr != nil {
+		return fmt.Errorf("failed to gunzip %s: %w", src, copyErr)
+	}
+	return closeErr
+}
+
+// ServeCompressedFile serves the gzip-compressed file at gzPath (as written
+// by CompressFile/CompressFileKeep), negotiating the wire encoding against
+// the client's Accept-Encoding header instead of always shipping raw gzip
+// bytes as an opaque octet-stream:
+//   - a client that accepts gzip gets the file passed through unmodified,
+//     with a proper Content-Encoding: gzip header
+//   - a client that accepts deflate but not gzip gets it transparently
+//     re-encoded
+//   - a client that accepts neither gets it decompressed on the fly
+func ServeCompressedFile(w http.ResponseWriter, req *http.Request, gzPath string) {
+	log.Printf("Serving %s to %s", filepath.Base(gzPath), req.UserAgent())
+	in, err := os.Open(gzPath)
+	if err != nil {
+		WriteHttpNotFound(w)
+		return
+	}
+	defer in.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Add("Vary", "Accept-Encoding")
+	accepted := parseAcceptEncoding(req.Header.Get("Accept-Encoding"))
+
+	switch {
+	case accepted["gzip"]:
+		w.Header().Set("Content-Encoding", "gzip")
+		if fi, err := in.Stat(); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(fi.Size(), 10))
+		}
+		io.Copy(w, in)
+	case accepted["deflate"]:
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			WriteHttpInternalServerError(w)
+			return
+		}
+		defer gz.Close()
+		w.Header().Set("Content-Encoding", "deflate")
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		io.Copy(fw, gz)
+		fw.Close()
+	default:
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			WriteHttpInternalServerError(w)
+			return
+		}
+		defer gz.Close()
+		io.Copy(w, gz)
+	}
+}
+
+// parseAcceptEncoding returns the set of content codings a client declared
+// acceptable via an Accept-Encoding header, per RFC 7231 §5.3.4. A coding
+// explicitly disabled with "q=0" is excluded.
+func parseAcceptEncoding(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range fields[1:] {
+			p = strings.TrimSpace(p)
+			if v, ok := strings.CutPrefix(p, "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[coding] = q > 0
+	}
+	return accepted
+}
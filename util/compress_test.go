@@ -0,0 +1,184 @@
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressFileRemovesSource(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CompressFile(src); err != nil {
+		t.Fatalf("CompressFile failed: %v", err)
+	}
+	if FileExists(src) {
+		t.Errorf("expected %s to be removed", src)
+	}
+	if !FileExists(src + ".gz") {
+		t.Fatalf("expected %s.gz to exist", src)
+	}
+	if err := DecompressFile(src + ".gz"); err != nil {
+		t.Fatalf("DecompressFile failed: %v", err)
+	}
+	got, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("got %q, want %q", got, "hello world")
+	}
+}
+
+func TestCompressFileKeepLeavesSource(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CompressFileKeep(src); err != nil {
+		t.Fatalf("CompressFileKeep failed: %v", err)
+	}
+	if !FileExists(src) {
+		t.Errorf("expected %s to still exist", src)
+	}
+	if !FileExists(src + ".gz") {
+		t.Fatalf("expected %s.gz to exist", src)
+	}
+}
+
+func TestCompressFileAtomicRemovesSourceAndLeavesDstReadable(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	dst := filepath.Join(dir, "cached.txt.gz")
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CompressFileAtomic(src, dst); err != nil {
+		t.Fatalf("CompressFileAtomic failed: %v", err)
+	}
+	if FileExists(src) {
+		t.Errorf("expected %s to be removed", src)
+	}
+	if FileExists(dst + ".tmp") {
+		t.Errorf("expected the temp file to be cleaned up")
+	}
+	if err := DecompressFile(dst); err != nil {
+		t.Fatalf("DecompressFile failed: %v", err)
+	}
+	got, err := os.ReadFile(strings.TrimSuffix(dst, ".gz"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("got %q, want %q", got, "hello world")
+	}
+}
+
+func TestDecompressFileRejectsNonGzSuffix(t *testing.T) {
+	if err := DecompressFile(filepath.Join(t.TempDir(), "data.txt")); err == nil {
+		t.Error("expected an error for a path not ending in .gz")
+	}
+}
+
+func TestServeCompressedFileGzipClientGetsPassthrough(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CompressFileKeep(src); err != nil {
+		t.Fatalf("CompressFileKeep failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/data.txt.gz", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	ServeCompressedFile(w, req, src+".gz")
+
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want %q", got, "gzip")
+	}
+	gzBytes, err := os.ReadFile(src + ".gz")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if w.Body.String() != string(gzBytes) {
+		t.Error("expected the raw gzip bytes to be passed through unmodified")
+	}
+}
+
+func TestServeCompressedFileNoCompressionClientGetsPlainBytes(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CompressFileKeep(src); err != nil {
+		t.Fatalf("CompressFileKeep failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/data.txt.gz", nil)
+	w := httptest.NewRecorder()
+	ServeCompressedFile(w, req, src+".gz")
+
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if w.Body.String() != "hello world" {
+		t.Errorf("got body %q, want %q", w.Body.String(), "hello world")
+	}
+}
+
+func TestGetGzipWriterRoundTripsAfterReuse(t *testing.T) {
+	var buf1 bytes.Buffer
+	gz1 := GetGzipWriter(&buf1)
+	if _, err := io.WriteString(gz1, "first"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := gz1.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	PutGzipWriter(gz1)
+
+	var buf2 bytes.Buffer
+	gz2 := GetGzipWriter(&buf2)
+	if _, err := io.WriteString(gz2, "second"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := gz2.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	PutGzipWriter(gz2)
+
+	r, err := gzip.NewReader(&buf2)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(got) != "second" {
+		t.Errorf("got %q, want %q", got, "second")
+	}
+}
+
+func TestParseAcceptEncodingRespectsZeroQuality(t *testing.T) {
+	accepted := parseAcceptEncoding("gzip;q=0, deflate")
+	if accepted["gzip"] {
+		t.Error("gzip;q=0 should not be accepted")
+	}
+	if !accepted["deflate"] {
+		t.Error("deflate should be accepted")
+	}
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// DeadlineTimer mirrors gonet/gvisor's deadlineTimer: a single reusable
+// timer backing two independent cancel channels, one for reads and one for
+// writes, so a caller can select on ReadCancel()/WriteCancel() to notice a
+// deadline without each goroutine owning its own timer. Unlike a plain
+// context.WithDeadline, SetReadDeadline/SetWriteDeadline/SetDeadline can be
+// called again on the same DeadlineTimer to extend or clear the deadline,
+// which crawler.FetchCancel's single Cancel() can't express, making this a
+// better fit for a long-lived goroutine (e.g. a crawler loop) whose
+// deadline moves over its lifetime instead of being set once.
+type DeadlineTimer struct {
+	mutex   sync.Mutex
+	timer   *time.Timer
+	readCh  chan struct{}
+	writeCh chan struct{}
+}
+
+// NewDeadlineTimer creates a DeadlineTimer with no deadline set.
+func NewDeadlineTimer() *DeadlineTimer {
+	return &DeadlineTimer{
+		readCh:  make(chan struct{}),
+		writeCh: make(chan struct{}),
+	}
+}
+
+// ReadCancel returns the channel that closes once a read deadline set by
+// SetReadDeadline or SetDeadline expires.
+func (d *DeadlineTimer) ReadCancel() <-chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.readCh
+}
+
+// WriteCancel returns the channel that closes once a write deadline set by
+// SetWriteDeadline or SetDeadline expires.
+func (d *DeadlineTimer) WriteCancel() <-chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.writeCh
+}
+
+// SetReadDeadline arms ReadCancel() to close at t, or clears a pending read
+// deadline if t is the zero Time.
+func (d *DeadlineTimer) SetReadDeadline(t time.Time) {
+	d.setDeadline(t, true, false)
+}
+
+// SetWriteDeadline arms WriteCancel() to close at t, or clears a pending
+// write deadline if t is the zero Time.
+func (d *DeadlineTimer) SetWriteDeadline(t time.Time) {
+	d.setDeadline(t, false, true)
+}
+
+// SetDeadline arms both ReadCancel() and WriteCancel() to close at t, or
+// clears both if t is the zero Time.
+func (d *DeadlineTimer) SetDeadline(t time.Time) {
+	d.setDeadline(t, true, true)
+}
+
+// setDeadline resets d's single underlying timer, replacing any channel
+// that a previous deadline already closed with a fresh one, so the next
+// SetReadDeadline/SetWriteDeadline call starts clean instead of leaking the
+// old timer or leaving a permanently-closed channel behind.
+func (d *DeadlineTimer) setDeadline(t time.Time, read, write bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if read {
+		d.readCh = freshen(d.readCh)
+	}
+	if write {
+		d.writeCh = freshen(d.writeCh)
+	}
+	if t.IsZero() {
+		return
+	}
+	d.timer = time.AfterFunc(time.Until(t), func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		if read {
+			close(d.readCh)
+		}
+		if write {
+			close(d.writeCh)
+		}
+	})
+}
+
+// freshen returns ch if it hasn't fired yet, or a newly-made channel in its
+// place if it has, so a closed-by-timeout channel is never handed back out
+// for a later deadline to also wait on.
+func freshen(ch chan struct{}) chan struct{} {
+	select {
+	case <-ch:
+		return make(chan struct{})
+	default:
+		return ch
+	}
+}
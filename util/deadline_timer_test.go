@@ -0,0 +1,59 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeadlineTimer_FiresReadAndWriteIndependently(t *testing.T) {
+	d := NewDeadlineTimer()
+	d.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+
+	select {
+	case <-d.ReadCancel():
+	case <-time.After(time.Second):
+		t.Fatal("ReadCancel never fired")
+	}
+	select {
+	case <-d.WriteCancel():
+		t.Fatal("WriteCancel fired, but no write deadline was set")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestDeadlineTimer_SetDeadlineAgainExtendsIt(t *testing.T) {
+	d := NewDeadlineTimer()
+	d.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	d.SetDeadline(time.Now().Add(time.Hour))
+
+	select {
+	case <-d.ReadCancel():
+		t.Fatal("ReadCancel fired before the extended deadline")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestDeadlineTimer_ZeroTimeClearsDeadline(t *testing.T) {
+	d := NewDeadlineTimer()
+	d.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	d.SetDeadline(time.Time{})
+
+	select {
+	case <-d.ReadCancel():
+		t.Fatal("ReadCancel fired after the deadline was cleared")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestDeadlineTimer_FreshChannelAfterFiring(t *testing.T) {
+	d := NewDeadlineTimer()
+	d.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+	<-d.ReadCancel()
+
+	d.SetReadDeadline(time.Now().Add(time.Hour))
+	select {
+	case <-d.ReadCancel():
+		t.Fatal("ReadCancel fired immediately; expected a fresh channel for the new deadline")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,185 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchFileWithOptions_ConditionalGetReturns304LeavesFileUntouched(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("first"))
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	if _, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{}); err != nil {
+		t.Fatalf("initial fetch failed: %v", err)
+	}
+	if _, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{}); err != nil {
+		t.Fatalf("conditional fetch failed: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != "first" {
+		t.Errorf("got %q, want %q", string(got), "first")
+	}
+}
+
+func TestFetchFileWithOptions_ResumesFromPartialDownload(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if rng := req.Header.Get("Range"); rng != "" {
+			if rng != "bytes=5-" {
+				t.Errorf("got Range %q, want %q", rng, "bytes=5-")
+			}
+			w.Header().Set("Content-Range", "bytes 5-9/10")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("world"))
+			return
+		}
+		w.Write([]byte("helloworld"))
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(dst+".part", []byte("hello"), 0644); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if _, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{}); err != nil {
+		t.Fatalf("FetchFileWithOptions failed: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != "helloworld" {
+		t.Errorf("got %q, want %q", string(got), "helloworld")
+	}
+}
+
+func TestFetchFileWithOptions_FallsBackToFullDownloadWhenServerIgnoresRange(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("fresh"))
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(dst+".part", []byte("stale-partial"), 0644); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if _, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{}); err != nil {
+		t.Fatalf("FetchFileWithOptions failed: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != "fresh" {
+		t.Errorf("got %q, want %q", string(got), "fresh")
+	}
+}
+
+func TestFetchFileWithOptions_SHA256MismatchFailsAndRemovesPart(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	retryable, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{
+		ExpectedSHA256: "0000000000000000000000000000000000000000000000000000000000000000",
+	})
+	if err == nil {
+		t.Fatal("expected a sha256 mismatch error")
+	}
+	if retryable {
+		t.Error("a sha256 mismatch should not be retryable")
+	}
+	if _, statErr := os.Stat(dst + ".part"); !os.IsNotExist(statErr) {
+		t.Errorf("expected %s.part to be removed after a sha256 mismatch", dst)
+	}
+	if _, statErr := os.Stat(dst); !os.IsNotExist(statErr) {
+		t.Errorf("expected %s to not be created after a sha256 mismatch", dst)
+	}
+}
+
+func TestFetchFileWithOptions_SHA256MatchSucceeds(t *testing.T) {
+	const content = "hello"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer upstream.Close()
+
+	sum, err := sha256String(content)
+	if err != nil {
+		t.Fatalf("sha256String failed: %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	if _, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{ExpectedSHA256: sum}); err != nil {
+		t.Fatalf("FetchFileWithOptions failed: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("got %q, want %q", string(got), content)
+	}
+}
+
+func sha256String(s string) (string, error) {
+	dir, err := os.MkdirTemp("", "fetch_file_test")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tmp")
+	if err := os.WriteFile(path, []byte(s), 0644); err != nil {
+		return "", err
+	}
+	return sha256File(path)
+}
+
+func TestFetchFileWithOptions_ServerErrorIsRetryable(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	retryable, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{})
+	if err == nil {
+		t.Fatal("expected an error for a 500 response")
+	}
+	if !retryable {
+		t.Error("a 500 response should be retryable")
+	}
+}
+
+func TestFetchFileWithOptions_ClientErrorIsNotRetryable(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	retryable, err := FetchFileWithOptions(context.Background(), http.DefaultClient, upstream.URL, dst, FetchFileOptions{})
+	if err == nil {
+		t.Fatal("expected an error for a 404 response")
+	}
+	if retryable {
+		t.Error("a 404 response should not be retryable")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,37 @@ func MustDirExist(path string) {
 	}
 }
 
+// RemoveStaleTempFiles deletes every direct child of dir whose name ends in
+// ".tmp" or ".lock". Every writer in this codebase follows the same
+// write-tmp-then-rename convention (see writeCheckpoint, wal.saveMeta,
+// FileRateLimiter.Allow), so a "*.tmp" surviving at startup can only be the
+// leftovers of a write that never reached its rename — it was never the
+// live file and is always safe to discard. This should be called once,
+// before a server starts reading or writing its data directory, the same
+// way Git clears stale lock files left behind by a killed process before
+// running gc.
+func RemoveStaleTempFiles(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".tmp") && !strings.HasSuffix(name, ".lock") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			log.Printf("Cannot remove stale temp file %s: %v", path, err)
+		} else {
+			log.Printf("Removed stale temp file left over from a prior crash: %s", path)
+		}
+	}
+}
+
 func FileExists(path string) bool {
 	_, err := os.Stat(path)
 	return !errors.Is(err, os.ErrNotExist)
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "stale-temp-files-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keep := filepath.Join(dir, "checkpoint.json")
+	assert.NoError(t, os.WriteFile(keep, []byte("{}"), 0644))
+	staleTmp := filepath.Join(dir, "checkpoint.json.tmp")
+	assert.NoError(t, os.WriteFile(staleTmp, []byte("{"), 0644))
+	staleLock := filepath.Join(dir, "alice.json.lock")
+	assert.NoError(t, os.WriteFile(staleLock, []byte(""), 0644))
+	subDir := filepath.Join(dir, "seqs.tmp")
+	assert.NoError(t, os.MkdirAll(subDir, os.ModePerm))
+
+	RemoveStaleTempFiles(dir)
+
+	assert.True(t, FileExists(keep), "a file without a stale suffix must survive")
+	assert.False(t, FileExists(staleTmp), "a leftover .tmp file must be removed")
+	assert.False(t, FileExists(staleLock), "a leftover .lock file must be removed")
+	_, err = os.Stat(subDir)
+	assert.NoError(t, err, "a directory ending in .tmp must not be removed")
+}
+
+func TestRemoveStaleTempFiles_MissingDir(t *testing.T) {
+	// Should not panic or error on a directory that doesn't exist yet.
+	RemoveStaleTempFiles(filepath.Join(os.TempDir(), "does-not-exist-stale-temp-files"))
+}
@@ -1,14 +1,15 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func WriteHttpStatus(w http.ResponseWriter, statusCode int, message string) {
@@ -46,6 +47,30 @@ func WriteHttpTooManyRequests(w http.ResponseWriter) {
 	WriteHttpStatus(w, http.StatusTooManyRequests, "Too Many Requests")
 }
 
+// WriteHttpTooManyRequestsRetryAfter is WriteHttpTooManyRequests, plus a
+// Retry-After header (in whole seconds, rounded up) telling the client how
+// long to wait before trying again.
+func WriteHttpTooManyRequestsRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(retryAfter.Round(time.Second) / time.Second)
+	if retryAfter > 0 && seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteHttpTooManyRequests(w)
+}
+
+func WriteHttpUnauthorized(w http.ResponseWriter) {
+	WriteHttpStatus(w, http.StatusUnauthorized, "Unauthorized")
+}
+
+func WriteHttpForbidden(w http.ResponseWriter) {
+	WriteHttpStatus(w, http.StatusForbidden, "Forbidden")
+}
+
+func WriteHttpConflict(w http.ResponseWriter) {
+	WriteHttpStatus(w, http.StatusConflict, "Conflict")
+}
+
 func WriteHttpInternalServerError(w http.ResponseWriter) {
 	WriteHttpStatus(w, http.StatusInternalServerError, "Internal Server Error")
 }
@@ -58,6 +83,28 @@ func WriteJsonResponse(w http.ResponseWriter, value interface{}) {
 	}
 }
 
+// WriteJsonResponseCtx is WriteJsonResponse, but responds 504 Gateway
+// Timeout instead of encoding value if ctx has already expired by the time
+// it's called — e.g. ctx is a request context and an upstream fetch earlier
+// in the handler ate the whole deadline. It can't interrupt an encode
+// already in progress, so callers with a slow value (very large or
+// lazily-computed) should check ctx themselves before this point too.
+func WriteJsonResponseCtx(ctx context.Context, w http.ResponseWriter, value interface{}) {
+	if ctx.Err() != nil {
+		WriteHttpStatus(w, http.StatusGatewayTimeout, "Gateway Timeout")
+		return
+	}
+	WriteJsonResponse(w, value)
+}
+
+// WriteOpenSearchSuggestions writes query, names, descriptions, and urls as
+// the OpenSearch Suggestions array format a browser's search box expects:
+// [query, [names...], [descriptions...], [urls...]]. The three slices must
+// be the same length; any of them may be empty.
+func WriteOpenSearchSuggestions(w http.ResponseWriter, query string, names, descriptions, urls []string) {
+	WriteJsonResponse(w, []interface{}{query, names, descriptions, urls})
+}
+
 func HandleNotFound(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Not found: %s", r.URL.String())
 	WriteHttpNotFound(w)
@@ -80,26 +127,36 @@ func CORSHandler(h http.Handler) http.Handler {
 	})
 }
 
-func FetchFile(httpClient *http.Client, url string, localFile string) error {
-	os.Remove(localFile)
-	file, err := os.Create(localFile)
-	if err != nil {
-		return err
-	}
-	log.Print("Fetching " + url)
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP error: %s", resp.Status)
-	}
-	defer resp.Body.Close()
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return err
+// TimeoutMiddleware bounds how long a handler may run: it attaches a
+// context.WithTimeout(d) to the request (so the handler's own ctx.Err()
+// checks see the same deadline) before delegating to http.TimeoutHandler,
+// which sends a 503 if the handler hasn't written a response within d.
+func TimeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		withDeadline := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+		return http.TimeoutHandler(withDeadline, d, "Request timed out\n")
 	}
-	return nil
+}
+
+// FetchFile is FetchFileCtx with context.Background(), for callers that
+// don't have a request or caller deadline to propagate.
+func FetchFile(httpClient *http.Client, url string, localFile string) error {
+	return FetchFileCtx(context.Background(), httpClient, url, localFile)
+}
+
+// FetchFileCtx downloads url into localFile, aborting the download (and
+// returning ctx.Err()) if ctx is cancelled or its deadline expires before
+// the request completes, instead of blocking indefinitely on a stuck
+// server or slow network. It's FetchFileWithOptions with no options; see
+// that function for the conditional-GET, resume, and verification support
+// it adds on top of a plain download.
+func FetchFileCtx(ctx context.Context, httpClient *http.Client, url string, localFile string) error {
+	_, err := FetchFileWithOptions(ctx, httpClient, url, localFile, FetchFileOptions{})
+	return err
 }
 
 func ParseAuthInfo(auth string) (string, string) {
@@ -110,7 +167,18 @@ func ParseAuthInfo(auth string) (string, string) {
 	return a[0], a[1]
 }
 
+// ServeBinary serves path as application/octet-stream. It responds 504
+// Gateway Timeout instead of serving the file if req's context has already
+// expired by the time it's called — e.g. an upstream fetch earlier in the
+// handler ate the whole deadline set by TimeoutMiddleware. It can't
+// interrupt http.ServeFile once started, so it only protects against
+// starting a serve that's already too late, not one that turns slow
+// mid-transfer.
 func ServeBinary(w http.ResponseWriter, req *http.Request, path string) {
+	if req.Context().Err() != nil {
+		WriteHttpStatus(w, http.StatusGatewayTimeout, "Gateway Timeout")
+		return
+	}
 	log.Printf("Serving %s to %s", filepath.Base(path), req.UserAgent())
 	w.Header().Set("Content-Type", "application/octet-stream")
 	http.ServeFile(w, req, path)
@@ -136,3 +204,12 @@ func ParseLimitSkip(req *http.Request, defaultLimit, maxLimit int) (limit, skip
 	}
 	return
 }
+
+// ParseLimitSkipShuffle is ParseLimitSkip plus a "shuffle" query param: when
+// it parses as true, the caller should randomize matches before paginating
+// instead of returning them in index order.
+func ParseLimitSkipShuffle(req *http.Request, defaultLimit, maxLimit int) (limit, skip int, shuffle bool) {
+	limit, skip = ParseLimitSkip(req, defaultLimit, maxLimit)
+	shuffle, _ = strconv.ParseBool(req.URL.Query().Get("shuffle"))
+	return
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware_PassesThroughFastHandler(t *testing.T) {
+	handler := TimeoutMiddleware(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestTimeoutMiddleware_CancelsSlowHandlerContext(t *testing.T) {
+	done := make(chan error, 1)
+	handler := TimeoutMiddleware(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		done <- r.Context().Err()
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("got ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler's context was never cancelled")
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestFetchFileCtx_AbortsOnCancelledContext(t *testing.T) {
+	blocked := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-blocked
+	}))
+	defer upstream.Close()
+	defer close(blocked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	err := FetchFileCtx(ctx, http.DefaultClient, upstream.URL, dst)
+	if err == nil {
+		t.Fatal("expected an error for an already-cancelled context")
+	}
+}
+
+func TestFetchFileCtx_SucceedsOnLiveContext(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	if err := FetchFileCtx(context.Background(), http.DefaultClient, upstream.URL, dst); err != nil {
+		t.Fatalf("FetchFileCtx failed: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("got %q, want %q", string(got), "hello")
+	}
+}
@@ -1,44 +1,284 @@
 package util
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// influxBatchSize is how many points are flushed to InfluxDB in a single
+// write; influxMaxLatency is the longest a point waits in the pending buffer
+// before being flushed as a partial batch.
+const (
+	influxBatchSize  = 500
+	influxMaxLatency = 1 * time.Second
+)
+
+// influxQueueDepth bounds how many already-batched writes may be queued for
+// the sender at once. Once full, the oldest queued batch is dropped rather
+// than blocking callers of Write.
+const influxQueueDepth = 16
+
+// influxMaxAttempts bounds how many times a batch write is retried before
+// it's dropped.
+const influxMaxAttempts = 4
+
+// influxBaseBackoff is the delay before the first retry of a failed batch
+// write; each further retry doubles it, plus a random jitter of the same
+// magnitude. It's a var rather than a const so tests can shrink it.
+var influxBaseBackoff = 500 * time.Millisecond
+
+// influxPoint is one buffered metric point awaiting a batched write.
+type influxPoint struct {
+	name   string
+	labels map[string]string
+	value  int
+	at     time.Time
+}
+
+// InfluxDbStats reports counters about the background writer, for
+// observability.
+type InfluxDbStats struct {
+	Written int64 `json:"written"`
+	Dropped int64 `json:"dropped"`
+	Errors  int64 `json:"errors"`
+}
+
+// InfluxDbClient writes metric points to InfluxDB over the line protocol.
+// Write only buffers the point; a background goroutine batches pending
+// points by size and latency and sends them asynchronously, retrying
+// transient failures with exponential backoff and jitter. If the sender
+// falls behind, the oldest queued batch is dropped so Write never blocks.
 type InfluxDbClient struct {
 	host       string
 	username   string
 	password   string
 	httpClient *http.Client
+
+	mutex   sync.Mutex
+	pending []influxPoint
+
+	batches chan []influxPoint
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	written int64
+	dropped int64
+	errors  int64
 }
 
 func NewInfluxDbClient(host string, username string, password string) *InfluxDbClient {
 	log.Printf("Using InfluxDB %s", host)
-	return &InfluxDbClient{
+	c := &InfluxDbClient{
 		host:       host,
 		username:   username,
 		password:   password,
 		httpClient: &http.Client{},
+		batches:    make(chan []influxPoint, influxQueueDepth),
+		done:       make(chan struct{}),
 	}
+	c.wg.Add(2)
+	go c.batchLoop()
+	go c.sendLoop()
+	return c
 }
 
+// Write enqueues a metric point for asynchronous, batched delivery to
+// InfluxDB, timestamped with the current time. It never blocks on network
+// I/O.
 func (c *InfluxDbClient) Write(name string, labels map[string]string, value int) {
-	data := name
-	for k, v := range labels {
-		data = fmt.Sprintf("%s,%s=%s", data, k, v)
+	c.WriteAt(name, labels, value, time.Now())
+}
+
+// WriteAt is Write with an explicit timestamp, for callers backfilling or
+// aligning points to an external clock.
+func (c *InfluxDbClient) WriteAt(name string, labels map[string]string, value int, at time.Time) {
+	c.mutex.Lock()
+	c.pending = append(c.pending, influxPoint{name: name, labels: labels, value: value, at: at})
+	shouldFlush := len(c.pending) >= influxBatchSize
+	c.mutex.Unlock()
+	if shouldFlush {
+		c.flush()
+	}
+}
+
+// Stats returns a snapshot of the background writer's counters.
+func (c *InfluxDbClient) Stats() InfluxDbStats {
+	return InfluxDbStats{
+		Written: atomic.LoadInt64(&c.written),
+		Dropped: atomic.LoadInt64(&c.dropped),
+		Errors:  atomic.LoadInt64(&c.errors),
+	}
+}
+
+// Close stops the background writer after flushing any pending points. It
+// returns ctx.Err() if ctx is done before the flush completes.
+func (c *InfluxDbClient) Close(ctx context.Context) error {
+	close(c.done)
+	stopped := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// batchLoop flushes pending into batches on a timer, so a point never waits
+// longer than influxMaxLatency even if the batch never reaches
+// influxBatchSize.
+func (c *InfluxDbClient) batchLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(influxMaxLatency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.done:
+			c.flush()
+			close(c.batches)
+			return
+		}
+	}
+}
+
+// flush moves whatever has accumulated in pending onto the batches queue,
+// dropping the oldest queued batch if the sender has fallen behind.
+func (c *InfluxDbClient) flush() {
+	c.mutex.Lock()
+	if len(c.pending) == 0 {
+		c.mutex.Unlock()
+		return
+	}
+	batch := c.pending
+	c.pending = nil
+	c.mutex.Unlock()
+
+	select {
+	case c.batches <- batch:
+		return
+	default:
+	}
+	select {
+	case <-c.batches:
+		atomic.AddInt64(&c.dropped, 1)
+	default:
+	}
+	select {
+	case c.batches <- batch:
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+	}
+}
+
+// sendLoop is the only reader of batches, so writes to InfluxDB are never
+// reordered relative to each other.
+func (c *InfluxDbClient) sendLoop() {
+	defer c.wg.Done()
+	for batch := range c.batches {
+		c.writeBatch(batch)
+	}
+}
+
+// writeBatch encodes points as newline-delimited line protocol and POSTs
+// them, retrying transient failures with exponential backoff and jitter.
+func (c *InfluxDbClient) writeBatch(points []influxPoint) {
+	var buf bytes.Buffer
+	for _, p := range points {
+		buf.WriteString(encodeLine(p))
+		buf.WriteByte('\n')
 	}
-	data = fmt.Sprintf("%s value=%v", data, value)
+	data := buf.Bytes()
 	url := fmt.Sprintf("%s/write?db=loda", c.host)
-	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(data))
-	if err != nil {
-		log.Fatal(err)
+
+	for attempt := 1; attempt <= influxMaxAttempts; attempt++ {
+		if attempt > 1 {
+			backoff := influxBaseBackoff * time.Duration(int64(1)<<uint(attempt-2))
+			time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			log.Printf("Error building InfluxDB write request: %v", err)
+			atomic.AddInt64(&c.errors, 1)
+			return
+		}
+		req.SetBasicAuth(c.username, c.password)
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			log.Printf("Error writing %d point(s) to InfluxDB (attempt %d/%d): %v", len(points), attempt, influxMaxAttempts, err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode >= 500 {
+			log.Printf("InfluxDB write failed with status %s (attempt %d/%d)", res.Status, attempt, influxMaxAttempts)
+			continue
+		}
+		if res.StatusCode >= 400 {
+			log.Printf("InfluxDB rejected write with status %s, dropping %d point(s)", res.Status, len(points))
+			atomic.AddInt64(&c.errors, 1)
+			return
+		}
+		atomic.AddInt64(&c.written, int64(len(points)))
+		return
+	}
+	log.Printf("Giving up on InfluxDB write of %d point(s) after %d attempts", len(points), influxMaxAttempts)
+	atomic.AddInt64(&c.errors, 1)
+}
+
+// encodeLine renders p as a single InfluxDB line-protocol line, escaping the
+// measurement, tag keys and tag values per the line protocol spec:
+// https://docs.influxdata.com/influxdb/v1/write_protocols/line_protocol_reference/
+func encodeLine(p influxPoint) string {
+	var b strings.Builder
+	b.WriteString(escapeMeasurement(p.name))
+	for _, k := range sortedKeys(p.labels) {
+		b.WriteByte(',')
+		b.WriteString(escapeTag(k))
+		b.WriteByte('=')
+		b.WriteString(escapeTag(p.labels[k]))
 	}
-	req.SetBasicAuth(c.username, c.password)
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		log.Printf("Error writing to InfluxDB: %v", err)
+	b.WriteString(" value=")
+	b.WriteString(strconv.Itoa(p.value))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.at.UnixNano(), 10))
+	return b.String()
+}
+
+var measurementReplacer = strings.NewReplacer(",", "\\,", " ", "\\ ")
+
+// escapeMeasurement escapes the commas and spaces that are significant in
+// the line protocol measurement position.
+func escapeMeasurement(s string) string {
+	return measurementReplacer.Replace(s)
+}
+
+var tagReplacer = strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ")
+
+// escapeTag escapes the commas, equals signs and spaces that are significant
+// in a line protocol tag key or value.
+func escapeTag(s string) string {
+	return tagReplacer.Replace(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	res.Body.Close()
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	influxBaseBackoff = time.Millisecond
+}
+
+func TestInfluxDbClient_WriteFlushesOnClose(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	c := NewInfluxDbClient(server.URL, "user", "pass")
+	c.Write("cpuhours", map[string]string{"platform": "amd64"}, 42)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if !strings.HasPrefix(body, "cpuhours,platform=amd64 value=42 ") {
+		t.Errorf("got line %q, want a cpuhours line with platform=amd64 and value=42", body)
+	}
+	if got := c.Stats().Written; got != 1 {
+		t.Errorf("got Stats().Written = %d, want 1", got)
+	}
+}
+
+func TestInfluxDbClient_EscapesSpecialCharactersInTags(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	c := NewInfluxDbClient(server.URL, "", "")
+	c.Write("req count", map[string]string{"path": "a=b, c"}, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if !strings.HasPrefix(body, `req\ count,path=a\=b\,\ c value=1 `) {
+		t.Errorf("got line %q, want measurement and tag escaped per line protocol", body)
+	}
+}
+
+func TestInfluxDbClient_RetriesOn5xxThenSucceeds(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}))
+	defer server.Close()
+
+	c := NewInfluxDbClient(server.URL, "", "")
+	c.Write("cpuhours", nil, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("got %d attempts, want 3", calls)
+	}
+	if got := c.Stats().Written; got != 1 {
+		t.Errorf("got Stats().Written = %d, want 1", got)
+	}
+}
+
+func TestInfluxDbClient_DropsOldestBatchWhenSenderStalled(t *testing.T) {
+	block := make(chan struct{})
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-block
+	}))
+	defer server.Close()
+
+	c := NewInfluxDbClient(server.URL, "", "")
+	for i := 0; i < influxQueueDepth+2; i++ {
+		c.Write("cpuhours", nil, i)
+		c.flush()
+	}
+	close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if got := c.Stats().Dropped; got == 0 {
+		t.Errorf("got Stats().Dropped = %d, want at least 1", got)
+	}
+}
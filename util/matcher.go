@@ -0,0 +1,44 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matcher compiles a slash-separated pattern into one independent, anchored
+// regexp per segment, the way Go's "go test -run" flag matches
+// Test/Subtest/... path components: pattern "A00004./mov|add/alice" yields
+// three segments, each matched against its own value. An empty segment (or
+// a value position beyond the pattern's segment count) always matches, so a
+// caller can leave any field unconstrained.
+type Matcher struct {
+	segments []*regexp.Regexp
+}
+
+// NewMatcher compiles pattern. Each "/"-separated segment is its own
+// regexp, anchored so it must match the full value.
+func NewMatcher(pattern string) (*Matcher, error) {
+	parts := strings.Split(pattern, "/")
+	segments := make([]*regexp.Regexp, len(parts))
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + part + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern segment %d (%q): %w", i, part, err)
+		}
+		segments[i] = re
+	}
+	return &Matcher{segments: segments}, nil
+}
+
+// MatchSegment reports whether value matches pattern segment i. A segment
+// index beyond the pattern's length, or an empty segment, always matches.
+func (m *Matcher) MatchSegment(i int, value string) bool {
+	if i < 0 || i >= len(m.segments) || m.segments[i] == nil {
+		return true
+	}
+	return m.segments[i].MatchString(value)
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestNewMatcher_InvalidPattern(t *testing.T) {
+	_, err := NewMatcher("[/b/c")
+	if err == nil {
+		t.Fatal("NewMatcher: expected error for an unbalanced character class")
+	}
+}
+
+func TestMatcher_EmptySegmentMatchesAnything(t *testing.T) {
+	m, err := NewMatcher("A00004.//alice")
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+	if !m.MatchSegment(1, "mov") {
+		t.Errorf("MatchSegment(1): expected empty segment to match any value")
+	}
+	if !m.MatchSegment(1, "") {
+		t.Errorf("MatchSegment(1): expected empty segment to match the empty value")
+	}
+	if m.MatchSegment(3, "anything") == false {
+		t.Errorf("MatchSegment(3): expected a segment index beyond the pattern to match")
+	}
+}
+
+func TestMatcher_RegexpSegments(t *testing.T) {
+	m, err := NewMatcher("A00004.")
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+	if !m.MatchSegment(0, "A000045") {
+		t.Errorf("expected A00004. to match A000045")
+	}
+	if m.MatchSegment(0, "A000456") {
+		t.Errorf("expected A00004. not to match A000456 (extra trailing character)")
+	}
+
+	m, err = NewMatcher("mov|add")
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+	if !m.MatchSegment(0, "mov") || !m.MatchSegment(0, "add") {
+		t.Errorf("expected mov|add to match either alternative")
+	}
+	if m.MatchSegment(0, "sub") {
+		t.Errorf("expected mov|add not to match an unlisted alternative")
+	}
+}
+
+func TestMatcher_SegmentsAreAnchored(t *testing.T) {
+	m, err := NewMatcher("a.b")
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+	if !m.MatchSegment(0, "axb") {
+		t.Errorf("expected a.b to match axb")
+	}
+	if m.MatchSegment(0, "axbc") {
+		t.Errorf("expected a.b to be anchored and not match axbc")
+	}
+}
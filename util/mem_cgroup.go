@@ -0,0 +1,274 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupFsRoot is where the cgroup filesystem is mounted on essentially
+// every Linux distribution and container runtime. A var, not a const, so
+// tests can point it at a temp directory.
+var cgroupFsRoot = "/sys/fs/cgroup"
+
+// cgroupVersion identifies which cgroup hierarchy a process belongs to.
+type cgroupVersion int
+
+const (
+	cgroupNone cgroupVersion = iota
+	cgroupV1
+	cgroupV2
+)
+
+// cgroupV1NoLimitBytes is the sentinel memory.limit_in_bytes reports when a
+// v1 memory cgroup has no limit set (close to the max signed 64-bit byte
+// count, rounded down to a page boundary). Anything near this size is
+// treated the same as v2's "max".
+const cgroupV1NoLimitBytes = uint64(1) << 62
+
+// parseCgroupPath reads a "/proc/<pid>/cgroup" style file and returns the
+// memory controller's cgroup path: the v1 hierarchy whose controller list
+// contains "memory" if one exists (hybrid and legacy v1 hosts), else the v2
+// unified hierarchy ("0::<path>") if present. cgroupNone if neither line is
+// found, e.g. not running in a cgroup at all.
+func parseCgroupPath(r io.Reader) (version cgroupVersion, path string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		hierarchyId, controllers, cgPath := parts[0], parts[1], parts[2]
+		if hierarchyId == "0" && controllers == "" {
+			version, path = cgroupV2, cgPath
+			continue
+		}
+		for _, c := range strings.Split(controllers, ",") {
+			if c == "memory" {
+				return cgroupV1, cgPath
+			}
+		}
+	}
+	return version, path
+}
+
+// processCgroup returns pid's memory-controller cgroup, read from
+// "/proc/<pid>/cgroup".
+func processCgroup(pid string) (version cgroupVersion, path string) {
+	f, err := os.Open(filepath.Join("/proc", pid, "cgroup"))
+	if err != nil {
+		return cgroupNone, ""
+	}
+	defer f.Close()
+	return parseCgroupPath(f)
+}
+
+// parseMemoryBytes parses the contents of a cgroup memory.max /
+// memory.limit_in_bytes / memory.current / memory.usage_in_bytes file: a
+// plain byte count, or v2's "max" for no limit. ok is false for "max" or
+// anything unparseable, since there's no byte figure to report.
+func parseMemoryBytes(content string) (bytes uint64, ok bool) {
+	content = strings.TrimSpace(content)
+	if content == "max" {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(content, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseMemoryStatField extracts a single field's byte count from the
+// contents of a cgroup memory.stat file, e.g. parseMemoryStatField(content,
+// "inactive_file") for v1 or parseMemoryStatField(content, "file") for v2.
+func parseMemoryStatField(content, field string) (bytes uint64, ok bool) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == field {
+			v, err := strconv.ParseUint(fields[1], 10, 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+// readFileString reads path and returns its contents, or "" if it can't be
+// read, e.g. the cgroup controller file doesn't exist on this host.
+func readFileString(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// cgroupMemoryKB is a cgroup's memory accounting in KB: UsageKB is always
+// populated; LimitKB and AvailableKB are 0 if the cgroup has no limit set,
+// in which case the cgroup imposes no constraint beyond the host's own.
+type cgroupMemoryKB struct {
+	LimitKB     int
+	UsageKB     int
+	AvailableKB int
+	HasLimit    bool
+}
+
+// reclaimableFieldFor is the memory.stat field that approximates page cache
+// the kernel can reclaim under pressure, mirroring how /proc/meminfo's
+// MemAvailable treats reclaimable cache as not really "used".
+func reclaimableFieldFor(version cgroupVersion) string {
+	if version == cgroupV2 {
+		return "file"
+	}
+	return "inactive_file"
+}
+
+// readCgroupMemoryKB reads the memory controller's limit and current usage
+// for the cgroup at path under root (cgroupFsRoot in production), using
+// memory.max/memory.current for v2 or memory.limit_in_bytes/
+// memory.usage_in_bytes for v1. AvailableKB is limit-usage, credited back
+// the reclaimable page cache memory.stat reports, so a cgroup near its
+// limit but mostly full of reclaimable cache isn't reported as exhausted.
+// ok is false if the usage file can't be read at all.
+func readCgroupMemoryKB(root string, version cgroupVersion, path string) (info cgroupMemoryKB, ok bool) {
+	var base string
+	var limitFile, usageFile string
+	switch version {
+	case cgroupV2:
+		base = filepath.Join(root, path)
+		limitFile, usageFile = "memory.max", "memory.current"
+	case cgroupV1:
+		base = filepath.Join(root, "memory", path)
+		limitFile, usageFile = "memory.limit_in_bytes", "memory.usage_in_bytes"
+	default:
+		return cgroupMemoryKB{}, false
+	}
+
+	usageContent, found := readFileString(filepath.Join(base, usageFile))
+	if !found {
+		return cgroupMemoryKB{}, false
+	}
+	usageBytes, parsed := parseMemoryBytes(usageContent)
+	if !parsed {
+		return cgroupMemoryKB{}, false
+	}
+	info.UsageKB = int(usageBytes / 1024)
+
+	if limitContent, found := readFileString(filepath.Join(base, limitFile)); found {
+		if limitBytes, parsed := parseMemoryBytes(limitContent); parsed && limitBytes < cgroupV1NoLimitBytes {
+			info.HasLimit = true
+			info.LimitKB = int(limitBytes / 1024)
+		}
+	}
+
+	if info.HasLimit {
+		reclaimableKB := 0
+		if statContent, found := readFileString(filepath.Join(base, "memory.stat")); found {
+			if reclaimableBytes, parsed := parseMemoryStatField(statContent, reclaimableFieldFor(version)); parsed {
+				reclaimableKB = int(reclaimableBytes / 1024)
+			}
+		}
+		info.AvailableKB = info.LimitKB - info.UsageKB + reclaimableKB
+		if info.AvailableKB < 0 {
+			info.AvailableKB = 0
+		}
+	}
+	return info, true
+}
+
+// currentCgroupMemoryKB returns the calling process's own cgroup memory
+// accounting, or ok=false if it isn't in a memory cgroup (non-Linux, or a
+// host with no cgroup memory controller at all).
+func currentCgroupMemoryKB() (info cgroupMemoryKB, ok bool) {
+	version, path := processCgroup("self")
+	if version == cgroupNone {
+		return cgroupMemoryKB{}, false
+	}
+	return readCgroupMemoryKB(cgroupFsRoot, version, path)
+}
+
+// MemoryPressure holds the PSI (Pressure Stall Information) "some" and
+// "full" averages for memory, as percentages of time over the trailing
+// 10/60/300 seconds that at least one ("some") or every ("full") task was
+// stalled waiting on memory. GetMemoryPressure reads this from
+// memory.pressure so a caller can back off new work before the kernel
+// starts OOM-killing rather than only reacting to a hard limit being hit.
+type MemoryPressure struct {
+	Some10, Some60, Some300 float64
+	Full10, Full60, Full300 float64
+}
+
+// parseMemoryPressure parses the contents of a memory.pressure (cgroup v2)
+// or /proc/pressure/memory file, e.g.:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parseMemoryPressure(content string) (MemoryPressure, bool) {
+	var p MemoryPressure
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		kind := fields[0]
+		if kind != "some" && kind != "full" {
+			continue
+		}
+		avg10, avg60, avg300, ok := parsePressureAvgs(fields[1:])
+		if !ok {
+			continue
+		}
+		found = true
+		if kind == "some" {
+			p.Some10, p.Some60, p.Some300 = avg10, avg60, avg300
+		} else {
+			p.Full10, p.Full60, p.Full300 = avg10, avg60, avg300
+		}
+	}
+	return p, found
+}
+
+// parsePressureAvgs parses the "avg10=..." "avg60=..." "avg300=..." fields
+// of one line of a PSI pressure file.
+func parsePressureAvgs(fields []string) (avg10, avg60, avg300 float64, ok bool) {
+	values := map[string]float64{}
+	for _, f := range fields {
+		key, val, found := strings.Cut(f, "=")
+		if !found {
+			continue
+		}
+		if v, err := strconv.ParseFloat(val, 64); err == nil {
+			values[key] = v
+		}
+	}
+	avg10, ok10 := values["avg10"]
+	avg60, ok60 := values["avg60"]
+	avg300, ok300 := values["avg300"]
+	return avg10, avg60, avg300, ok10 && ok60 && ok300
+}
+
+// GetMemoryPressure returns the current process's memory PSI pressure: its
+// cgroup's memory.pressure if it's in one, else the host-wide
+// /proc/pressure/memory. ok is false if neither file exists, e.g. the
+// kernel was built without CONFIG_PSI or this isn't Linux.
+func GetMemoryPressure() (MemoryPressure, bool) {
+	if version, path := processCgroup("self"); version != cgroupNone {
+		base := cgroupFsRoot
+		if version == cgroupV1 {
+			base = filepath.Join(base, "memory")
+		}
+		if content, found := readFileString(filepath.Join(base, path, "memory.pressure")); found {
+			return parseMemoryPressure(content)
+		}
+	}
+	if content, found := readFileString("/proc/pressure/memory"); found {
+		return parseMemoryPressure(content)
+	}
+	return MemoryPressure{}, false
+}
@@ -0,0 +1,179 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCgroupPath_V2Unified(t *testing.T) {
+	version, path := parseCgroupPath(strings.NewReader("0::/system.slice/loda-miner.service\n"))
+	if version != cgroupV2 || path != "/system.slice/loda-miner.service" {
+		t.Errorf("got (%v, %q)", version, path)
+	}
+}
+
+func TestParseCgroupPath_V1Hybrid(t *testing.T) {
+	content := "12:pids:/user.slice\n11:memory:/user.slice/user-1000.slice\n0::/user.slice/user-1000.slice/session.scope\n"
+	version, path := parseCgroupPath(strings.NewReader(content))
+	if version != cgroupV1 || path != "/user.slice/user-1000.slice" {
+		t.Errorf("got (%v, %q)", version, path)
+	}
+}
+
+func TestParseCgroupPath_None(t *testing.T) {
+	version, _ := parseCgroupPath(strings.NewReader(""))
+	if version != cgroupNone {
+		t.Errorf("got %v, want cgroupNone", version)
+	}
+}
+
+func TestParseMemoryBytes(t *testing.T) {
+	if _, ok := parseMemoryBytes("max\n"); ok {
+		t.Errorf("expected ok=false for \"max\"")
+	}
+	v, ok := parseMemoryBytes("1073741824\n")
+	if !ok || v != 1073741824 {
+		t.Errorf("got (%d, %v)", v, ok)
+	}
+}
+
+func TestParseMemoryStatField(t *testing.T) {
+	content := "cache 104857600\ninactive_file 52428800\nactive_file 52428800\n"
+	v, ok := parseMemoryStatField(content, "inactive_file")
+	if !ok || v != 52428800 {
+		t.Errorf("got (%d, %v)", v, ok)
+	}
+	if _, ok := parseMemoryStatField(content, "missing_field"); ok {
+		t.Errorf("expected ok=false for a field that isn't present")
+	}
+}
+
+func TestReadCgroupMemoryKB_V2WithLimit(t *testing.T) {
+	root := t.TempDir()
+	cgPath := "/system.slice/loda-miner.service"
+	mustWriteFile(t, filepath.Join(root, cgPath, "memory.max"), "1073741824\n")    // 1 GiB
+	mustWriteFile(t, filepath.Join(root, cgPath, "memory.current"), "734003200\n") // ~700 MiB
+	mustWriteFile(t, filepath.Join(root, cgPath, "memory.stat"), "file 104857600\n")
+
+	info, ok := readCgroupMemoryKB(root, cgroupV2, cgPath)
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if !info.HasLimit || info.LimitKB != 1048576 {
+		t.Errorf("LimitKB: got %d, HasLimit %v", info.LimitKB, info.HasLimit)
+	}
+	if info.UsageKB != 716800 {
+		t.Errorf("UsageKB: got %d", info.UsageKB)
+	}
+	wantAvailable := 1048576 - 716800 + 102400
+	if info.AvailableKB != wantAvailable {
+		t.Errorf("AvailableKB: got %d, want %d", info.AvailableKB, wantAvailable)
+	}
+}
+
+func TestReadCgroupMemoryKB_V2Unlimited(t *testing.T) {
+	root := t.TempDir()
+	cgPath := "/system.slice/loda-miner.service"
+	mustWriteFile(t, filepath.Join(root, cgPath, "memory.max"), "max\n")
+	mustWriteFile(t, filepath.Join(root, cgPath, "memory.current"), "104857600\n")
+
+	info, ok := readCgroupMemoryKB(root, cgroupV2, cgPath)
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if info.HasLimit {
+		t.Errorf("expected HasLimit=false for \"max\"")
+	}
+	if info.UsageKB != 102400 {
+		t.Errorf("UsageKB: got %d", info.UsageKB)
+	}
+}
+
+func TestReadCgroupMemoryKB_V1WithLimit(t *testing.T) {
+	root := t.TempDir()
+	cgPath := "/system.slice/loda-miner.service"
+	mustWriteFile(t, filepath.Join(root, "memory", cgPath, "memory.limit_in_bytes"), "1073741824\n")
+	mustWriteFile(t, filepath.Join(root, "memory", cgPath, "memory.usage_in_bytes"), "734003200\n")
+	mustWriteFile(t, filepath.Join(root, "memory", cgPath, "memory.stat"), "inactive_file 104857600\n")
+
+	info, ok := readCgroupMemoryKB(root, cgroupV1, cgPath)
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if !info.HasLimit || info.LimitKB != 1048576 {
+		t.Errorf("LimitKB: got %d, HasLimit %v", info.LimitKB, info.HasLimit)
+	}
+	wantAvailable := 1048576 - 716800 + 102400
+	if info.AvailableKB != wantAvailable {
+		t.Errorf("AvailableKB: got %d, want %d", info.AvailableKB, wantAvailable)
+	}
+}
+
+func TestReadCgroupMemoryKB_V1NoLimitSentinel(t *testing.T) {
+	root := t.TempDir()
+	cgPath := "/user.slice"
+	mustWriteFile(t, filepath.Join(root, "memory", cgPath, "memory.limit_in_bytes"), "9223372036854771712\n")
+	mustWriteFile(t, filepath.Join(root, "memory", cgPath, "memory.usage_in_bytes"), "104857600\n")
+
+	info, ok := readCgroupMemoryKB(root, cgroupV1, cgPath)
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if info.HasLimit {
+		t.Errorf("expected HasLimit=false for the v1 \"no limit\" sentinel")
+	}
+}
+
+func TestReadCgroupMemoryKB_MissingUsageFile(t *testing.T) {
+	root := t.TempDir()
+	if _, ok := readCgroupMemoryKB(root, cgroupV2, "/does-not-exist.slice"); ok {
+		t.Errorf("expected ok=false when memory.current doesn't exist")
+	}
+}
+
+func TestIsSystemdUnitCgroup(t *testing.T) {
+	cases := map[string]bool{
+		"/system.slice/loda-miner@1.service": true,
+		"/user.slice/user-1000.slice":        true,
+		"/docker/abc123":                     false,
+		"":                                   false,
+	}
+	for path, want := range cases {
+		if got := isSystemdUnitCgroup(path); got != want {
+			t.Errorf("isSystemdUnitCgroup(%q): got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestParseMemoryPressure(t *testing.T) {
+	content := "some avg10=1.23 avg60=4.56 avg300=7.89 total=12345\n" +
+		"full avg10=0.01 avg60=0.02 avg300=0.03 total=678\n"
+	p, ok := parseMemoryPressure(content)
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if p.Some10 != 1.23 || p.Some60 != 4.56 || p.Some300 != 7.89 {
+		t.Errorf("some: got %+v", p)
+	}
+	if p.Full10 != 0.01 || p.Full60 != 0.02 || p.Full300 != 0.03 {
+		t.Errorf("full: got %+v", p)
+	}
+}
+
+func TestParseMemoryPressure_Empty(t *testing.T) {
+	if _, ok := parseMemoryPressure(""); ok {
+		t.Errorf("expected ok=false for empty content")
+	}
+}
+
+func mustWriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll(%q): %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile(%q): %v", path, err)
+	}
+}
@@ -9,7 +9,27 @@ import (
 )
 
 // GetFreeMemoryBytes returns available system memory in KB (Linux only). Returns 0 on error or non-Linux systems.
+//
+// Inside a container this is the tighter of the host's own MemAvailable and
+// the current process's cgroup's available memory, since the host figure
+// alone reflects limits the cgroup doesn't actually have room for -- a
+// scheduler sizing a miner pool off GetFreeMemoryKB would otherwise happily
+// overcommit past the container's memory limit and get OOM-killed.
 func GetFreeMemoryKB() int {
+	hostKB := getHostFreeMemoryKB()
+	cgroup, ok := currentCgroupMemoryKB()
+	if !ok || !cgroup.HasLimit {
+		return hostKB
+	}
+	if cgroup.AvailableKB < hostKB {
+		return cgroup.AvailableKB
+	}
+	return hostKB
+}
+
+// getHostFreeMemoryKB returns the host's MemAvailable from /proc/meminfo,
+// ignoring any cgroup the calling process might be confined to.
+func getHostFreeMemoryKB() int {
 	f, err := os.Open("/proc/meminfo")
 	if err != nil {
 		return 0
@@ -33,8 +53,17 @@ func GetFreeMemoryKB() int {
 
 // GetProcessesMemoryUsageKB returns a map of process name to total memory usage (in KB) for all running processes starting with that name.
 // Only works on Linux.
+//
+// A matched process running under a systemd slice (a ".service"/".slice"
+// cgroup, the common case for a loda-miner managed by systemd) is counted
+// by its cgroup's memory.current rather than its own VmRSS: several of a
+// miner's forked workers share the same cgroup, and summing their VmRSS
+// would double-count the pages they share. Each such cgroup is counted only
+// once no matter how many matched pids live in it. A process outside any
+// systemd-unit cgroup still falls back to summing VmRSS, same as before.
 func GetProcessesMemoryUsageKB(processNames []string) (map[string]int, error) {
 	result := make(map[string]int)
+	seenCgroups := make(map[string]bool)
 	procEntries, err := os.ReadDir("/proc")
 	if err != nil {
 		return nil, err
@@ -63,6 +92,18 @@ func GetProcessesMemoryUsageKB(processNames []string) (map[string]int, error) {
 		if matched == "" {
 			continue
 		}
+
+		if version, path := processCgroup(pid); version != cgroupNone && isSystemdUnitCgroup(path) {
+			if seenCgroups[path] {
+				continue
+			}
+			if info, ok := readCgroupMemoryKB(cgroupFsRoot, version, path); ok {
+				result[matched] += info.UsageKB
+				seenCgroups[path] = true
+				continue
+			}
+		}
+
 		statusPath := filepath.Join("/proc", pid, "status")
 		statusBytes, err := os.ReadFile(statusPath)
 		if err != nil {
@@ -84,3 +125,9 @@ func GetProcessesMemoryUsageKB(processNames []string) (map[string]int, error) {
 	}
 	return result, nil
 }
+
+// isSystemdUnitCgroup reports whether cgroup path looks like a systemd
+// slice or service unit, e.g. "/system.slice/loda-miner@1.service".
+func isSystemdUnitCgroup(path string) bool {
+	return strings.Contains(path, ".service") || strings.Contains(path, ".slice")
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+)
+
+// WriteNDJSONStream writes each value seq yields as its own line of JSON
+// (newline-delimited JSON, Content-Type application/x-ndjson), flushing
+// after every record so a client starts receiving results as they're found
+// instead of waiting for the whole response to buffer in memory on either
+// side, the way WriteJsonResponse does for a single large slice. If r's
+// Accept-Encoding includes "gzip", the body is wrapped in a gzip.Writer and
+// Content-Encoding: gzip is set, the same way a browser or curl
+// --compressed would expect. It stops early, without writing an error
+// response (the status line and headers are already sent by the time
+// streaming starts), if r.Context() is cancelled between records.
+func WriteNDJSONStream(w http.ResponseWriter, r *http.Request, seq iter.Seq[any]) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(out)
+	ctx := r.Context()
+	for v := range seq {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := enc.Encode(v); err != nil {
+			return
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// SliceSeq adapts an already-materialized slice into an iter.Seq[any], for
+// a caller that wants to stream a fully-paginated result (e.g. a
+// submissions list handler) through WriteNDJSONStream without writing the
+// same range-and-yield loop at every call site.
+func SliceSeq[T any](s []T) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for _, v := range s {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func seqFromInts(vs ...int) func(yield func(any) bool) {
+	return func(yield func(any) bool) {
+		for _, v := range vs {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func TestWriteNDJSONStream_WritesOneJSONValuePerLine(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	WriteNDJSONStream(rec, req, seqFromInts(1, 2, 3))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q, want application/x-ndjson", ct)
+	}
+	var got []int
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var v int
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+}
+
+func TestWriteNDJSONStream_GzipsWhenAccepted(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	WriteNDJSONStream(rec, req, seqFromInts(42))
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("got Content-Encoding %q, want gzip", enc)
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+	defer gz.Close()
+	var v int
+	if err := json.NewDecoder(gz).Decode(&v); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if v != 42 {
+		t.Errorf("got %d, want 42", v)
+	}
+}
+
+func TestWriteNDJSONStream_StopsOnCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	WriteNDJSONStream(rec, req, seqFromInts(1, 2, 3))
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("got %d bytes written, want 0 for an already-cancelled context", rec.Body.Len())
+	}
+}
+
+func TestSliceSeq_YieldsEveryElementInOrder(t *testing.T) {
+	var got []int
+	SliceSeq([]int{1, 2, 3})(func(v any) bool {
+		got = append(got, v.(int))
+		return true
+	})
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+}
+
+func TestSliceSeq_StopsWhenYieldReturnsFalse(t *testing.T) {
+	n := 0
+	SliceSeq([]int{1, 2, 3})(func(v any) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("got %d calls to yield, want 1", n)
+	}
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HTTPMetrics receives per-request outcomes from RequestMetricsMiddleware.
+// Each server backs it with its own Prometheus collectors registered on its
+// own /metrics registry (see e.g. cmd/oeis/metrics.go), so the middleware
+// stays generic while each server keeps control of its label sets and
+// bucket boundaries.
+type HTTPMetrics interface {
+	ObserveRequest(route, method string, status int, bytes int64, duration time.Duration)
+}
+
+// statusCapturingWriter wraps a ResponseWriter so RequestMetricsMiddleware
+// can observe the status code and byte count after the handler returns,
+// neither of which http.ResponseWriter otherwise exposes.
+type statusCapturingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusCapturingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusCapturingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// RequestMetricsMiddleware records m.ObserveRequest for every request and
+// emits a single structured log line (method, path, status, bytes,
+// duration, user-agent), replacing the ad-hoc log.Printf calls that used to
+// be sprinkled through individual handlers. Register it with
+// router.Use(util.RequestMetricsMiddleware(m)) so it wraps every route,
+// including the 404 handler.
+func RequestMetricsMiddleware(m HTTPMetrics) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusCapturingWriter{ResponseWriter: w}
+			h.ServeHTTP(sw, r)
+			duration := time.Since(start)
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := routeTemplate(r)
+			m.ObserveRequest(route, r.Method, status, sw.bytes, duration)
+			log.Printf("%s %s status=%d bytes=%d duration=%s ua=%q",
+				r.Method, r.URL.Path, status, sw.bytes, duration, r.UserAgent())
+		})
+	}
+}
+
+// routeTemplate returns the matched mux route's path template (e.g.
+// "/v1/oeis/b{id:[0-9]+}.txt.gz") so per-route metrics don't explode into
+// one series per concrete ID. It falls back to the raw request path if the
+// request wasn't routed through mux, e.g. a handler invoked directly in a
+// unit test.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
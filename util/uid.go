@@ -1,6 +1,8 @@
 package util
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
 	"strconv"
 )
@@ -65,3 +67,31 @@ func (u UID) IsGreaterThan(other UID) bool {
 func (u UID) IsZero() bool {
 	return (u.domain == 0 || u.domain == 'A') && u.number == 0
 }
+
+// uidWire is the exported mirror of UID's unexported fields, used only to
+// round-trip UID through encoding/gob (which ignores unexported fields).
+type uidWire struct {
+	Domain byte
+	Number int64
+}
+
+// GobEncode implements gob.GobEncoder so UID survives encoding/gob despite
+// its fields being unexported.
+func (u UID) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(uidWire{Domain: u.domain, Number: u.number}); err != nil {
+		return nil, fmt.Errorf("failed to gob-encode UID: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, the counterpart to GobEncode.
+func (u *UID) GobDecode(data []byte) error {
+	var wire uidWire
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wire); err != nil {
+		return fmt.Errorf("failed to gob-decode UID: %w", err)
+	}
+	u.domain = wire.Domain
+	u.number = wire.Number
+	return nil
+}
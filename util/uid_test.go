@@ -1,6 +1,8 @@
 package util
 
 import (
+	"bytes"
+	"encoding/gob"
 	"testing"
 )
 
@@ -96,6 +98,21 @@ func TestUIDComparison(t *testing.T) {
 	}
 }
 
+func TestUIDGobRoundTrip(t *testing.T) {
+	u, _ := NewUID('A', 123456)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(u); err != nil {
+		t.Fatalf("gob encode failed: %v", err)
+	}
+	var decoded UID
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("gob decode failed: %v", err)
+	}
+	if !u.Equals(decoded) {
+		t.Errorf("UID gob round trip: got %v, want %v", decoded, u)
+	}
+}
+
 func TestUIDIsZero(t *testing.T) {
 	u, _ := NewUID('A', 0)
 	if !u.IsZero() {